@@ -35,6 +35,7 @@ import (
 	"github.com/redhat-cip/skydive/storage/etcd"
 	"github.com/redhat-cip/skydive/topology/graph"
 	tprobes "github.com/redhat-cip/skydive/topology/probes"
+	"github.com/redhat-cip/skydive/topology/synthetic"
 )
 
 type Agent struct {
@@ -80,6 +81,9 @@ func (a *Agent) Start() {
 		// expose a flow server through the client connection
 		flow.NewServer(a.FlowTableAlloctor, a.WSClient)
 
+		// run the probe jobs of the analyzer
+		synthetic.NewRunnerFromConfig(a.WSClient)
+
 		// send a first reset event to the analyzers
 		a.Graph.DelSubGraph(a.Root)
 	}
@@ -162,6 +166,8 @@ func NewAgent() *Agent {
 		panic(err)
 	}
 
+	shttp.RegisterPprofFromConfig(hserver)
+
 	wsServer := shttp.NewWSServerFromConfig(hserver, "/ws")
 
 	m := graph.Metadata{"Name": hostname, "Type": "host"}
@@ -174,11 +180,21 @@ func NewAgent() *Agent {
 	root := g.NewNode(graph.Identifier(hostname), m)
 
 	api.RegisterTopologyApi("agent", g, hserver)
+	status := api.RegisterStatusApi("agent", hserver, wsServer)
+	api.RegisterRuntimeApi("agent", hserver)
+	api.RegisterVersionApi(hserver)
 
 	gserver := graph.NewServer(g, wsServer)
 
 	fta := flow.NewTableAllocator()
 
+	decoders, err := flow.NewDecoderChainFromConfig()
+	if err != nil {
+		panic(err)
+	}
+	fta.SetDecoderChain(decoders)
+	status.PacketDecoders = decoders
+
 	return &Agent{
 		Graph:             g,
 		WSServer:          wsServer,
@@ -0,0 +1,350 @@
+/*
+ * Copyright (C) 2015 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package analyzer
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/abbot/go-http-auth"
+	"golang.org/x/net/context"
+	"gopkg.in/yaml.v2"
+
+	"github.com/redhat-cip/skydive/config"
+	shttp "github.com/redhat-cip/skydive/http"
+	"github.com/redhat-cip/skydive/logging"
+)
+
+// states of the checklist items, the analyzer being ready when no item is
+// missing
+const (
+	ChecklistOK      = "ok"
+	ChecklistWarning = "warning"
+	ChecklistMissing = "missing"
+)
+
+// ChecklistItem is the state of a dependency of the analyzer along with
+// what to do when it is not ok
+type ChecklistItem struct {
+	Name   string
+	State  string
+	Detail string
+	Hint   string `json:",omitempty"`
+}
+
+// Checklist is served by /api/bootstrap/checklist in bootstrap mode
+type Checklist struct {
+	Ready bool
+	Items []ChecklistItem
+}
+
+func (c Checklist) String() string {
+	lines := []string{"Bootstrap checklist:"}
+	for _, item := range c.Items {
+		line := fmt.Sprintf("  [%-7s] %-8s %s", item.State, item.Name, item.Detail)
+		if item.Hint != "" {
+			line += " (" + item.Hint + ")"
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// starterConfigKeys are the settings written to the starter configuration
+var starterConfigKeys = []string{
+	"analyzer.listen",
+	"analyzer.storage",
+	"analyzer.flowtable_expire",
+	"analyzer.flowtable_update",
+	"storage.elasticsearch",
+	"graph.backend",
+	"etcd.embedded",
+	"etcd.port",
+	"etcd.data_dir",
+	"etcd.servers",
+	"auth.type",
+}
+
+// Bootstrap helps getting a first analyzer running: it checks the
+// dependencies of the analyzer, logs the checklist whenever it changes and
+// serves it along with a starter configuration
+type Bootstrap struct {
+	server   *Server
+	interval time.Duration
+	logged   string
+	quit     chan struct{}
+	wg       sync.WaitGroup
+}
+
+func (b *Bootstrap) checkEtcd() ChecklistItem {
+	item := ChecklistItem{Name: "etcd"}
+	servers := strings.Join(config.GetConfig().GetStringSlice("etcd.servers"), ",")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if _, err := b.server.EtcdClient.KeysApi.Get(ctx, "/", nil); err != nil {
+		item.State = ChecklistMissing
+		item.Detail = fmt.Sprintf("%s unreachable: %s", servers, err.Error())
+		item.Hint = "enable etcd.embedded or set etcd.servers"
+		return item
+	}
+
+	item.State = ChecklistOK
+	if b.server.EmbeddedEtcd != nil {
+		item.Detail = "embedded, data in " + config.GetConfig().GetString("etcd.data_dir")
+	} else {
+		item.Detail = servers
+	}
+	return item
+}
+
+func (b *Bootstrap) checkStorage() ChecklistItem {
+	item := ChecklistItem{Name: "storage"}
+	if b.server.Storage == nil {
+		item.State = ChecklistMissing
+		item.Detail = "no storage configured, the flows won't persist"
+		item.Hint = "set analyzer.storage to elasticsearch"
+		return item
+	}
+
+	item.State = ChecklistOK
+	if t := config.GetConfig().GetString("analyzer.storage"); t != "" {
+		item.Detail = t
+	} else {
+		item.Detail = "configured"
+	}
+	return item
+}
+
+func (b *Bootstrap) checkAgents() ChecklistItem {
+	item := ChecklistItem{Name: "agents"}
+
+	// the agents say hello with their host, the other clients don't
+	var hosts []string
+	for _, client := range b.server.WSServer.GetStatus().Clients {
+		if client.Host != "" {
+			hosts = append(hosts, client.Host)
+		}
+	}
+
+	if len(hosts) == 0 {
+		item.State = ChecklistMissing
+		item.Detail = "no agent connected yet"
+		item.Hint = fmt.Sprintf("start skydive agent with analyzers: %s:%d", b.server.HTTPServer.Addr, b.server.HTTPServer.Port)
+		return item
+	}
+
+	item.State = ChecklistOK
+	item.Detail = fmt.Sprintf("%d connected: %s", len(hosts), strings.Join(hosts, ", "))
+	return item
+}
+
+func (b *Bootstrap) checkFlows() ChecklistItem {
+	item := ChecklistItem{Name: "flows"}
+	if count := len(b.server.FlowTable.GetFlows()); count > 0 {
+		item.State = ChecklistOK
+		item.Detail = fmt.Sprintf("%d flows in the flow table", count)
+		return item
+	}
+
+	item.State = ChecklistWarning
+	item.Detail = "no flow received yet"
+	item.Hint = "create a capture with skydive client capture create"
+	return item
+}
+
+func (b *Bootstrap) checkAuth() ChecklistItem {
+	item := ChecklistItem{Name: "auth"}
+	if t := config.GetConfig().GetString("auth.type"); t != "noauth" {
+		item.State = ChecklistOK
+		item.Detail = t
+		return item
+	}
+
+	item.State = ChecklistWarning
+	item.Detail = "authentication disabled, anyone reaching the API can use it"
+	item.Hint = "set auth.type to basic or keystone"
+	return item
+}
+
+// Checklist returns the current state of the dependencies
+func (b *Bootstrap) Checklist() Checklist {
+	checklist := Checklist{
+		Items: []ChecklistItem{
+			b.checkEtcd(),
+			b.checkStorage(),
+			b.checkAgents(),
+			b.checkFlows(),
+			b.checkAuth(),
+		},
+	}
+
+	checklist.Ready = true
+	for _, item := range checklist.Items {
+		if item.State == ChecklistMissing {
+			checklist.Ready = false
+		}
+	}
+	return checklist
+}
+
+// logChecklist logs the checklist if it changed since it was last logged
+func (b *Bootstrap) logChecklist() {
+	checklist := b.Checklist()
+
+	// the details of the flows change with each flow, only their state is
+	// compared
+	states := make([]string, len(checklist.Items))
+	for i, item := range checklist.Items {
+		states[i] = item.Name + ":" + item.State
+		if item.Name != "flows" {
+			states[i] += ":" + item.Detail
+		}
+	}
+	if s := strings.Join(states, ","); s != b.logged {
+		b.logged = s
+		logging.GetLogger().Notice(checklist.String())
+	}
+}
+
+// StarterConfig returns a configuration file holding the effective
+// settings, to be hardened by the operator
+func StarterConfig() ([]byte, error) {
+	settings := make(map[string]interface{})
+	for _, key := range starterConfigKeys {
+		if key == "storage.elasticsearch" && config.GetConfig().GetString("analyzer.storage") != "elasticsearch" {
+			continue
+		}
+
+		path := strings.Split(key, ".")
+		section := settings
+		for _, name := range path[:len(path)-1] {
+			sub, ok := section[name].(map[string]interface{})
+			if !ok {
+				sub = make(map[string]interface{})
+				section[name] = sub
+			}
+			section = sub
+		}
+		section[path[len(path)-1]] = config.GetConfig().Get(key)
+	}
+
+	b, err := yaml.Marshal(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	header := "# Starter configuration generated by the analyzer bootstrap mode.\n" +
+		"# Configure a storage and an authentication before production use.\n"
+	return append([]byte(header), b...), nil
+}
+
+func (b *Bootstrap) checklistIndex(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(b.Checklist()); err != nil {
+		panic(err)
+	}
+}
+
+func (b *Bootstrap) starterConfig(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
+	c, err := StarterConfig()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-yaml")
+	w.WriteHeader(http.StatusOK)
+	w.Write(c)
+}
+
+func (b *Bootstrap) registerEndpoints(r *shttp.Server) {
+	routes := []shttp.Route{
+		{
+			"BootstrapChecklist",
+			"GET",
+			"/api/bootstrap/checklist",
+			b.checklistIndex,
+		},
+		{
+			"BootstrapConfig",
+			"GET",
+			"/api/bootstrap/config",
+			b.starterConfig,
+		},
+	}
+
+	r.RegisterRoutes(routes)
+}
+
+func (b *Bootstrap) Start() {
+	b.quit = make(chan struct{})
+	b.wg.Add(1)
+
+	go func() {
+		defer b.wg.Done()
+
+		ticker := time.NewTicker(b.interval)
+		defer ticker.Stop()
+
+		b.logChecklist()
+		for {
+			select {
+			case <-ticker.C:
+				b.logChecklist()
+			case <-b.quit:
+				return
+			}
+		}
+	}()
+}
+
+func (b *Bootstrap) Stop() {
+	if b.quit != nil {
+		close(b.quit)
+		b.wg.Wait()
+		b.quit = nil
+	}
+}
+
+func NewBootstrap(s *Server, interval time.Duration) *Bootstrap {
+	b := &Bootstrap{
+		server:   s,
+		interval: interval,
+	}
+	b.registerEndpoints(s.HTTPServer)
+
+	return b
+}
+
+func NewBootstrapFromConfig(s *Server) *Bootstrap {
+	interval := time.Duration(config.GetConfig().GetInt("analyzer.bootstrap.log_interval")) * time.Second
+	return NewBootstrap(s, interval)
+}
@@ -0,0 +1,251 @@
+/*
+ * Copyright (C) 2015 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package analyzer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/redhat-cip/skydive/config"
+	"github.com/redhat-cip/skydive/flow"
+	shttp "github.com/redhat-cip/skydive/http"
+	"github.com/redhat-cip/skydive/storage"
+)
+
+func freePort(t *testing.T) int {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+// memoryStorage keeps the stored flows in memory
+type memoryStorage struct {
+	sync.Mutex
+	flows []*flow.Flow
+}
+
+func (s *memoryStorage) Start() {
+}
+
+func (s *memoryStorage) Stop() {
+}
+
+func (s *memoryStorage) StoreFlows(flows []*flow.Flow) error {
+	s.Lock()
+	s.flows = append(s.flows, flows...)
+	s.Unlock()
+	return nil
+}
+
+func (s *memoryStorage) SearchFlows(filters storage.Filters) ([]*flow.Flow, error) {
+	s.Lock()
+	defer s.Unlock()
+	return s.flows, nil
+}
+
+func (s *memoryStorage) CountFlows(filters storage.Filters) (int, error) {
+	s.Lock()
+	defer s.Unlock()
+	return len(s.flows), nil
+}
+
+// agentSimulator connects to the analyzer and sends flows as an agent does
+type agentSimulator struct {
+	ws   *shttp.WSAsyncClient
+	conn net.Conn
+}
+
+func newAgentSimulator(t *testing.T, addr string, port int) *agentSimulator {
+	ws, err := shttp.NewWSAsyncClient(addr, port, "/ws", nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	ws.Connect()
+
+	conn, err := net.Dial("udp", net.JoinHostPort(addr, strconv.Itoa(port)))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	return &agentSimulator{ws: ws, conn: conn}
+}
+
+func (a *agentSimulator) sendFlow(t *testing.T, f *flow.Flow) {
+	data, err := f.GetData()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := a.conn.Write(data); err != nil {
+		t.Fatal(err.Error())
+	}
+}
+
+func (a *agentSimulator) stop() {
+	a.ws.Disconnect()
+	a.conn.Close()
+}
+
+func getChecklist(t *testing.T, url string) (checklist Checklist) {
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(&checklist); err != nil {
+		t.Fatal(err.Error())
+	}
+	return
+}
+
+func itemStates(checklist Checklist) map[string]string {
+	states := make(map[string]string)
+	for _, item := range checklist.Items {
+		states[item.Name] = item.State
+	}
+	return states
+}
+
+// waitChecklist polls the checklist until the items have the given states
+func waitChecklist(t *testing.T, url string, expected map[string]string) Checklist {
+	var checklist Checklist
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(100 * time.Millisecond) {
+		checklist = getChecklist(t, url)
+
+		states := itemStates(checklist)
+		match := true
+		for name, state := range expected {
+			if states[name] != state {
+				match = false
+			}
+		}
+		if match {
+			return checklist
+		}
+	}
+
+	t.Fatalf("checklist didn't reach %v: %s", expected, checklist)
+	return checklist
+}
+
+func TestBootstrapChecklist(t *testing.T) {
+	dataDir, err := ioutil.TempDir("", "skydive-bootstrap-etcd")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dataDir)
+
+	port, etcdPort := freePort(t), freePort(t)
+
+	cfg := config.GetConfig()
+	cfg.Set("analyzer.listen", "127.0.0.1:"+strconv.Itoa(port))
+	cfg.Set("analyzer.bootstrap.enabled", true)
+	cfg.Set("analyzer.bootstrap.log_interval", 3600)
+	cfg.Set("flow_tcp.port", freePort(t))
+	cfg.Set("etcd.embedded", true)
+	cfg.Set("etcd.port", etcdPort)
+	cfg.Set("etcd.data_dir", dataDir)
+	cfg.Set("etcd.servers", []string{"http://127.0.0.1:" + strconv.Itoa(etcdPort)})
+	defer cfg.Set("analyzer.bootstrap.enabled", false)
+
+	server, err := NewServerFromConfig()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := server.Start(); err != nil {
+		t.Fatal(err.Error())
+	}
+	defer server.Stop()
+
+	url := fmt.Sprintf("http://127.0.0.1:%d/api/bootstrap/checklist", port)
+
+	// fresh analyzer: only etcd is there
+	checklist := waitChecklist(t, url, map[string]string{
+		"etcd":    ChecklistOK,
+		"storage": ChecklistMissing,
+		"agents":  ChecklistMissing,
+		"flows":   ChecklistWarning,
+		"auth":    ChecklistWarning,
+	})
+	if checklist.Ready {
+		t.Fatalf("analyzer shouldn't be ready: %s", checklist)
+	}
+
+	agent := newAgentSimulator(t, "127.0.0.1", port)
+	defer agent.stop()
+	waitChecklist(t, url, map[string]string{"agents": ChecklistOK})
+
+	agent.sendFlow(t, &flow.Flow{
+		UUID:       "flow1",
+		LayersPath: "Ethernet/IPv4/TCP",
+		Statistics: &flow.FlowStatistics{Start: time.Now().Unix(), Last: time.Now().Unix()},
+	})
+	waitChecklist(t, url, map[string]string{"flows": ChecklistOK})
+
+	server.SetStorage(&memoryStorage{})
+	checklist = waitChecklist(t, url, map[string]string{
+		"etcd":    ChecklistOK,
+		"storage": ChecklistOK,
+		"agents":  ChecklistOK,
+		"flows":   ChecklistOK,
+	})
+	if !checklist.Ready {
+		t.Errorf("analyzer should be ready: %s", checklist)
+	}
+
+	resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/api/bootstrap/config", port))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer resp.Body.Close()
+
+	var starter struct {
+		Analyzer struct {
+			Listen string
+		}
+		Etcd struct {
+			Embedded bool
+			Port     int
+		}
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	if err := yaml.Unmarshal(body, &starter); err != nil {
+		t.Fatalf("invalid starter configuration %s: %s", body, err.Error())
+	}
+	if starter.Analyzer.Listen != "127.0.0.1:"+strconv.Itoa(port) || !starter.Etcd.Embedded || starter.Etcd.Port != etcdPort {
+		t.Errorf("starter configuration doesn't hold the effective settings: %s", body)
+	}
+}
@@ -23,22 +23,59 @@
 package analyzer
 
 import (
+	"encoding/binary"
+	"fmt"
+	"io"
 	"net"
 	"strconv"
+	"sync"
 	"time"
 
+	"github.com/redhat-cip/skydive/config"
 	"github.com/redhat-cip/skydive/flow"
 	"github.com/redhat-cip/skydive/logging"
 )
 
+// maximum number of frames kept until acknowledged by the analyzer, the
+// oldest ones being dropped beyond
+const maxPendingFrames = 1000
+
+// delays between the attempts to reconnect to the analyzer, doubled after
+// each failure
+const (
+	minReconnectBackoff = time.Second
+	maxReconnectBackoff = 30 * time.Second
+)
+
+type pendingFrame struct {
+	seq   uint64
+	flows []*flow.Flow
+}
+
 type Client struct {
-	Addr string
-	Port int
+	Addr      string
+	Port      int
+	Transport string
+	// maximum size of the datagrams batching the flows sent over UDP, 0
+	// sending a flow per datagram
+	BatchSize int
 
 	connection net.Conn
+	lock       sync.Mutex
+	ack        bool
+	epoch      int64
+	seq        uint64
+	acked      uint64
+	pending    []pendingFrame
+	backoff    time.Duration
+	retryAt    time.Time
 }
 
 func (c *Client) SendFlow(f *flow.Flow) error {
+	if c.Transport == "tcp" {
+		return c.sendFrame([]*flow.Flow{f})
+	}
+
 	data, err := f.GetData()
 	if err != nil {
 		return err
@@ -50,6 +87,27 @@ func (c *Client) SendFlow(f *flow.Flow) error {
 }
 
 func (c *Client) SendFlows(flows []*flow.Flow) {
+	if c.Transport == "tcp" {
+		if err := c.sendFrame(flows); err != nil {
+			logging.GetLogger().Errorf("Unable to send flows: %s", err.Error())
+		}
+		return
+	}
+
+	if c.BatchSize > 0 {
+		datagrams, err := flow.EncodeBatches(flows, c.BatchSize)
+		if err != nil {
+			logging.GetLogger().Errorf("Unable to encode flows: %s", err.Error())
+			return
+		}
+		for _, data := range datagrams {
+			if _, err := c.connection.Write(data); err != nil {
+				logging.GetLogger().Errorf("Unable to send flows: %s", err.Error())
+			}
+		}
+		return
+	}
+
 	for _, flow := range flows {
 		err := c.SendFlow(flow)
 		if err != nil {
@@ -58,6 +116,157 @@ func (c *Client) SendFlows(flows []*flow.Flow) {
 	}
 }
 
+func (c *Client) sendFrame(flows []*flow.Flow) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.seq++
+	if c.ack {
+		if len(c.pending) == maxPendingFrames {
+			logging.GetLogger().Warningf("No ack received for %d frames, dropping frame %d", maxPendingFrames, c.pending[0].seq)
+			c.pending = c.pending[1:]
+		}
+		c.pending = append(c.pending, pendingFrame{seq: c.seq, flows: flows})
+	}
+
+	if c.connection != nil {
+		err := writeFrame(c.connection, c.epoch, c.seq, flows)
+		if err == nil || err == ErrFrameTooLarge {
+			return err
+		}
+
+		logging.GetLogger().Warningf("Flow connection to %s:%d lost, reconnecting: %s", c.Addr, c.Port, err.Error())
+		c.connection.Close()
+		c.connection = nil
+	}
+
+	// reconnect and replay the frames not yet acknowledged
+	if err := c.reconnect(); err != nil {
+		return err
+	}
+
+	if !c.ack {
+		return writeFrame(c.connection, c.epoch, c.seq, flows)
+	}
+
+	for _, frame := range c.pending {
+		if err := writeFrame(c.connection, c.epoch, frame.seq, frame.flows); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *Client) readAcks(conn net.Conn) {
+	ack := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(conn, ack); err != nil {
+			return
+		}
+		seq := binary.BigEndian.Uint64(ack)
+
+		c.lock.Lock()
+		c.acked = seq
+		i := 0
+		for i < len(c.pending) && c.pending[i].seq <= seq {
+			i++
+		}
+		c.pending = c.pending[i:]
+		c.lock.Unlock()
+	}
+}
+
+// Acked returns the sequence number of the last frame acknowledged by the
+// analyzer.
+func (c *Client) Acked() uint64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.acked
+}
+
+// Pending returns the number of frames waiting for an acknowledgement.
+func (c *Client) Pending() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return len(c.pending)
+}
+
+// reconnect connects to the analyzer unless the last attempt failed less
+// than the backoff delay ago, the delay growing with the failures
+func (c *Client) reconnect() error {
+	now := time.Now()
+	if now.Before(c.retryAt) {
+		return fmt.Errorf("analyzer %s:%d unreachable, next attempt in %s", c.Addr, c.Port, c.retryAt.Sub(now))
+	}
+
+	if err := c.connect(); err != nil {
+		if c.backoff *= 2; c.backoff < minReconnectBackoff {
+			c.backoff = minReconnectBackoff
+		} else if c.backoff > maxReconnectBackoff {
+			c.backoff = maxReconnectBackoff
+		}
+		c.retryAt = now.Add(c.backoff)
+		return err
+	}
+
+	if c.backoff > 0 {
+		logging.GetLogger().Infof("Flow connection to %s:%d restored", c.Addr, c.Port)
+	}
+	c.backoff = 0
+	c.retryAt = time.Time{}
+	return nil
+}
+
+func (c *Client) connect() error {
+	if c.connection != nil {
+		c.connection.Close()
+	}
+
+	if c.Transport == "tcp" {
+		srv, err := net.ResolveTCPAddr("tcp", c.Addr+":"+strconv.FormatInt(int64(c.Port), 10))
+		if err != nil {
+			return err
+		}
+
+		connection, err := net.DialTCP("tcp", nil, srv)
+		if err != nil {
+			return err
+		}
+		c.connection = connection
+
+		if c.ack {
+			go c.readAcks(connection)
+		}
+
+		return nil
+	}
+
+	srv, err := net.ResolveUDPAddr("udp", c.Addr+":"+strconv.FormatInt(int64(c.Port), 10))
+	if err != nil {
+		return err
+	}
+
+	connection, err := net.DialUDP("udp", nil, srv)
+	if err != nil {
+		return err
+	}
+	c.connection = connection
+
+	return nil
+}
+
+func (c *Client) Close() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.connection != nil {
+		c.connection.Close()
+	}
+}
+
 func (c *Client) AsyncFlowsUpdate(ft *flow.Table, every time.Duration) {
 	ticker := time.NewTicker(every)
 	defer ticker.Stop()
@@ -70,19 +279,47 @@ func (c *Client) AsyncFlowsUpdate(ft *flow.Table, every time.Duration) {
 }
 
 func NewClient(addr string, port int) (*Client, error) {
-	client := &Client{Addr: addr, Port: port}
+	client := &Client{Addr: addr, Port: port, Transport: "udp"}
 
-	srv, err := net.ResolveUDPAddr("udp", addr+":"+strconv.FormatInt(int64(port), 10))
-	if err != nil {
+	if err := client.connect(); err != nil {
 		return nil, err
 	}
 
-	connection, err := net.DialUDP("udp", nil, srv)
+	return client, nil
+}
+
+// NewTCPClient returns a client sending the flows in frames over TCP, keeping
+// them until acknowledged by the analyzer if ack is true. The client is
+// returned even if the analyzer is unreachable, reconnecting with backoff
+// when sending the flows.
+func NewTCPClient(addr string, port int, ack bool) (*Client, error) {
+	// the frames of the client are numbered in the epoch of its creation
+	client := &Client{Addr: addr, Port: port, Transport: "tcp", ack: ack, epoch: time.Now().UnixNano()}
+
+	client.lock.Lock()
+	defer client.lock.Unlock()
+
+	if err := client.reconnect(); err != nil {
+		logging.GetLogger().Warningf("Unable to connect to the analyzer %s:%d, will retry: %s", addr, port, err.Error())
+	}
+
+	return client, nil
+}
+
+// NewClientFromConfig returns a client using the agent.flow.transport, the
+// TCP one connecting to the flow_tcp.port of the analyzer, the UDP one
+// batching the flows in datagrams of agent.flow.udp_batch_size bytes.
+func NewClientFromConfig(addr string, port int) (*Client, error) {
+	if config.GetConfig().GetString("agent.flow.transport") == "tcp" {
+		ack := config.GetConfig().GetString("flow_tcp.ack") != AckNone
+		return NewTCPClient(addr, config.GetConfig().GetInt("flow_tcp.port"), ack)
+	}
+
+	client, err := NewClient(addr, port)
 	if err != nil {
 		return nil, err
 	}
-
-	client.connection = connection
+	client.BatchSize = config.GetConfig().GetInt("agent.flow.udp_batch_size")
 
 	return client, nil
 }
@@ -0,0 +1,332 @@
+/*
+ * Copyright (C) 2015 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package analyzer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/redhat-cip/skydive/config"
+	"github.com/redhat-cip/skydive/flow"
+	"github.com/redhat-cip/skydive/flow/ingestion"
+	shttp "github.com/redhat-cip/skydive/http"
+	"github.com/redhat-cip/skydive/logging"
+)
+
+// Over TCP the flows are sent in frames made of a header, the payload length,
+// the epoch of the agent and the frame sequence number, followed by the
+// length prefixed flows. The epoch, the start time of the agent, numbers its
+// runs, the frames of a run being numbered from 1. When enabled, the analyzer
+// acknowledges the frames whose flows were analyzed by sending back the
+// sequence number of the last one.
+const (
+	AckNone  = "none"
+	AckFrame = "frame"
+	AckBatch = "batch"
+
+	frameHeaderSize = 20
+	maxFrameSize    = 16 * 1024 * 1024
+)
+
+var ErrFrameTooLarge = errors.New("Flow frame too large")
+
+type FlowTCPServer struct {
+	Addr      string
+	Port      int
+	AckMode   string
+	AckFrames int
+	Sequencer *ingestion.Sequencer
+	handler   func(flows []*flow.Flow)
+	listener  net.Listener
+	running   atomic.Value
+	connsLock sync.Mutex
+	conns     map[net.Conn]bool
+	wg        sync.WaitGroup
+}
+
+func writeFrame(w io.Writer, epoch int64, seq uint64, flows []*flow.Flow) error {
+	var payload bytes.Buffer
+	for _, f := range flows {
+		data, err := f.GetData()
+		if err != nil {
+			return err
+		}
+		binary.Write(&payload, binary.BigEndian, uint32(len(data)))
+		payload.Write(data)
+	}
+
+	if payload.Len() > maxFrameSize {
+		return ErrFrameTooLarge
+	}
+
+	frame := make([]byte, frameHeaderSize, frameHeaderSize+payload.Len())
+	binary.BigEndian.PutUint32(frame, uint32(payload.Len()))
+	binary.BigEndian.PutUint64(frame[4:], uint64(epoch))
+	binary.BigEndian.PutUint64(frame[12:], seq)
+
+	_, err := w.Write(append(frame, payload.Bytes()...))
+	return err
+}
+
+func readFrame(r io.Reader) (int64, uint64, []*flow.Flow, error) {
+	header := make([]byte, frameHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, 0, nil, err
+	}
+
+	size := binary.BigEndian.Uint32(header)
+	epoch := int64(binary.BigEndian.Uint64(header[4:]))
+	seq := binary.BigEndian.Uint64(header[12:])
+	if size > maxFrameSize {
+		return 0, 0, nil, ErrFrameTooLarge
+	}
+
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, 0, nil, err
+	}
+
+	var flows []*flow.Flow
+	for len(payload) > 0 {
+		if len(payload) < 4 {
+			return 0, 0, nil, fmt.Errorf("Truncated flow in frame %d", seq)
+		}
+		l := binary.BigEndian.Uint32(payload)
+		if uint32(len(payload)-4) < l {
+			return 0, 0, nil, fmt.Errorf("Truncated flow in frame %d", seq)
+		}
+
+		f, err := flow.FromData(payload[4 : 4+l])
+		if err != nil {
+			return 0, 0, nil, err
+		}
+		flows = append(flows, f)
+
+		payload = payload[4+l:]
+	}
+
+	return epoch, seq, flows, nil
+}
+
+// MatchFlowFrame matches, on a multiplexed port, the connections of the
+// agents sending their flows over TCP, starting with the header of a frame
+// numbered from 1
+func MatchFlowFrame(peek []byte) int {
+	if len(peek) == 0 {
+		return shttp.MuxNeedMore
+	}
+	if peek[0] > byte(maxFrameSize>>24) {
+		return shttp.MuxNoMatch
+	}
+	if len(peek) < frameHeaderSize {
+		return shttp.MuxNeedMore
+	}
+
+	if binary.BigEndian.Uint32(peek) > maxFrameSize || binary.BigEndian.Uint64(peek[12:]) == 0 {
+		return shttp.MuxNoMatch
+	}
+	return shttp.MuxMatched
+}
+
+// agentAddr returns the address identifying the agent of a connection, as
+// the one of its datagrams
+func agentAddr(conn net.Conn) string {
+	if addr, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+		return addr.IP.String()
+	}
+	return conn.RemoteAddr().String()
+}
+
+func (s *FlowTCPServer) handleConn(conn net.Conn) {
+	defer s.wg.Done()
+	defer s.closeConn(conn)
+
+	agent := agentAddr(conn)
+	unacked := 0
+	for s.running.Load() == true {
+		epoch, seq, flows, err := readFrame(conn)
+		if err != nil {
+			if err != io.EOF && s.running.Load() == true {
+				logging.GetLogger().Errorf("Error while reading flows from %s: %s", conn.RemoteAddr(), err.Error())
+				logging.GetJournal(logging.JournalParseErrors).Record("Flows from %s: %s", conn.RemoteAddr(), err.Error())
+			}
+			return
+		}
+
+		// the frames retransmitted are acknowledged again without being
+		// analyzed twice
+		if s.Sequencer == nil || s.Sequencer.Accept(agent, epoch, seq) {
+			s.handler(flows)
+		}
+
+		unacked++
+		if s.AckMode == AckNone || (s.AckMode == AckBatch && unacked < s.AckFrames) {
+			continue
+		}
+
+		ack := make([]byte, 8)
+		binary.BigEndian.PutUint64(ack, seq)
+		if _, err := conn.Write(ack); err != nil {
+			logging.GetLogger().Errorf("Unable to acknowledge frame %d to %s: %s", seq, conn.RemoteAddr(), err.Error())
+			return
+		}
+		unacked = 0
+	}
+}
+
+func (s *FlowTCPServer) closeConn(conn net.Conn) {
+	s.connsLock.Lock()
+	delete(s.conns, conn)
+	s.connsLock.Unlock()
+
+	conn.Close()
+}
+
+func (s *FlowTCPServer) Listen() error {
+	addr, err := net.ResolveTCPAddr("tcp", s.Addr+":"+strconv.FormatInt(int64(s.Port), 10))
+	if err != nil {
+		return err
+	}
+
+	if s.listener, err = net.ListenTCP("tcp", addr); err != nil {
+		return err
+	}
+	s.running.Store(true)
+
+	return nil
+}
+
+// ListenOn serves the connections of a listener, the one of the flows of a
+// multiplexed port, instead of binding the address
+func (s *FlowTCPServer) ListenOn(l net.Listener) {
+	s.listener = l
+	s.running.Store(true)
+}
+
+// ListenFile listens on the socket of another server, given by File,
+// instead of binding the address
+func (s *FlowTCPServer) ListenFile(f *os.File) error {
+	listener, err := net.FileListener(f)
+	if err != nil {
+		return err
+	}
+
+	tcpListener, ok := listener.(*net.TCPListener)
+	if !ok {
+		listener.Close()
+		return fmt.Errorf("Not a TCP socket: %s", f.Name())
+	}
+
+	s.listener = tcpListener
+	s.running.Store(true)
+
+	return nil
+}
+
+// File returns a copy of the listening socket
+func (s *FlowTCPServer) File() (*os.File, error) {
+	if s.running.Load() != true {
+		return nil, errors.New("Flow server not listening")
+	}
+
+	listener, ok := s.listener.(*net.TCPListener)
+	if !ok {
+		return nil, errors.New("Flow server listening on a multiplexed port")
+	}
+	return listener.File()
+}
+
+func (s *FlowTCPServer) Serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if s.running.Load() == true && err != shttp.ErrMuxClosed {
+				logging.GetLogger().Errorf("Error while accepting flow connection: %s", err.Error())
+			}
+			return
+		}
+
+		s.connsLock.Lock()
+		s.conns[conn] = true
+		s.connsLock.Unlock()
+
+		s.wg.Add(1)
+		go s.handleConn(conn)
+	}
+}
+
+func (s *FlowTCPServer) Stop() {
+	if s.running.Load() != true {
+		return
+	}
+	s.running.Store(false)
+	s.listener.Close()
+
+	s.connsLock.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.connsLock.Unlock()
+
+	s.wg.Wait()
+}
+
+func NewFlowTCPServer(addr string, port int, ackMode string, ackFrames int, handler func(flows []*flow.Flow)) (*FlowTCPServer, error) {
+	switch ackMode {
+	case AckNone, AckFrame:
+	case AckBatch:
+		if ackFrames <= 0 {
+			return nil, fmt.Errorf("invalid number of frames per ack (%d)", ackFrames)
+		}
+	default:
+		return nil, fmt.Errorf("unknown ack mode %s", ackMode)
+	}
+
+	s := &FlowTCPServer{
+		Addr:      addr,
+		Port:      port,
+		AckMode:   ackMode,
+		AckFrames: ackFrames,
+		handler:   handler,
+		conns:     make(map[net.Conn]bool),
+	}
+	s.running.Store(false)
+
+	return s, nil
+}
+
+func NewFlowTCPServerFromConfig(addr string, handler func(flows []*flow.Flow)) (*FlowTCPServer, error) {
+	port := config.GetConfig().GetInt("flow_tcp.port")
+	ackMode := config.GetConfig().GetString("flow_tcp.ack")
+	ackFrames := config.GetConfig().GetInt("flow_tcp.ack_frames")
+
+	return NewFlowTCPServer(addr, port, ackMode, ackFrames, handler)
+}
@@ -0,0 +1,348 @@
+/*
+ * Copyright (C) 2015 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package analyzer
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redhat-cip/skydive/flow"
+	"github.com/redhat-cip/skydive/flow/ingestion"
+	shttp "github.com/redhat-cip/skydive/http"
+)
+
+type frameRecorder struct {
+	sync.Mutex
+	frames []int
+}
+
+func (r *frameRecorder) analyzeFlows(flows []*flow.Flow) {
+	r.Lock()
+	r.frames = append(r.frames, len(flows))
+	r.Unlock()
+}
+
+func (r *frameRecorder) count() int {
+	r.Lock()
+	defer r.Unlock()
+	return len(r.frames)
+}
+
+func startFlowTCPServer(t *testing.T, ackMode string, ackFrames int) (*FlowTCPServer, *frameRecorder) {
+	recorder := &frameRecorder{}
+	s, err := NewFlowTCPServer("127.0.0.1", 0, ackMode, ackFrames, recorder.analyzeFlows)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if err := s.Listen(); err != nil {
+		t.Fatal(err.Error())
+	}
+	s.Port = s.listener.Addr().(*net.TCPAddr).Port
+	go s.Serve()
+
+	return s, recorder
+}
+
+func testFlows(n int) []*flow.Flow {
+	flows := make([]*flow.Flow, n)
+	for i := range flows {
+		flows[i] = &flow.Flow{UUID: strconv.Itoa(i)}
+	}
+	return flows
+}
+
+func waitAcked(c *Client, seq uint64) bool {
+	for i := 0; i < 100; i++ {
+		if c.Acked() == seq {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
+
+func TestFlowTCPAckPerFrame(t *testing.T) {
+	s, recorder := startFlowTCPServer(t, AckFrame, 0)
+	defer s.Stop()
+
+	c, err := NewTCPClient("127.0.0.1", s.Port, true)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer c.Close()
+
+	for i := 1; i <= 5; i++ {
+		c.SendFlows(testFlows(i))
+
+		if !waitAcked(c, uint64(i)) {
+			t.Fatalf("Expected frame %d to be acked, got %d", i, c.Acked())
+		}
+		if n := recorder.count(); n != i {
+			t.Fatalf("Frame %d acked while %d frames analyzed", i, n)
+		}
+	}
+
+	if c.Pending() != 0 {
+		t.Errorf("Expected no pending frame, got %d", c.Pending())
+	}
+
+	recorder.Lock()
+	defer recorder.Unlock()
+	for i, n := range recorder.frames {
+		if n != i+1 {
+			t.Errorf("Expected %d flows in frame %d, got %d", i+1, i+1, n)
+		}
+	}
+}
+
+func TestFlowTCPAckBatch(t *testing.T) {
+	s, recorder := startFlowTCPServer(t, AckBatch, 3)
+	defer s.Stop()
+
+	c, err := NewTCPClient("127.0.0.1", s.Port, true)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer c.Close()
+
+	for i := 0; i < 7; i++ {
+		c.SendFlows(testFlows(2))
+	}
+
+	if !waitAcked(c, 6) {
+		t.Fatalf("Expected frame 6 to be acked, got %d", c.Acked())
+	}
+	if n := recorder.count(); n < 6 {
+		t.Errorf("Frame 6 acked while %d frames analyzed", n)
+	}
+
+	// the last frame is kept until the next batch
+	if c.Pending() != 1 {
+		t.Errorf("Expected 1 pending frame, got %d", c.Pending())
+	}
+}
+
+func TestFlowTCPNoAck(t *testing.T) {
+	s, recorder := startFlowTCPServer(t, AckNone, 0)
+	defer s.Stop()
+
+	c, err := NewTCPClient("127.0.0.1", s.Port, false)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer c.Close()
+
+	for i := 0; i < 3; i++ {
+		c.SendFlows(testFlows(1))
+	}
+
+	for i := 0; recorder.count() != 3 && i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := recorder.count(); n != 3 {
+		t.Fatalf("Expected 3 frames analyzed, got %d", n)
+	}
+
+	if c.Acked() != 0 || c.Pending() != 0 {
+		t.Errorf("Unexpected ack %d or pending frames %d", c.Acked(), c.Pending())
+	}
+}
+
+func TestFlowTCPInvalidAckMode(t *testing.T) {
+	if _, err := NewFlowTCPServer("127.0.0.1", 0, "always", 0, nil); err == nil {
+		t.Error("Expected an error for an unknown ack mode")
+	}
+	if _, err := NewFlowTCPServer("127.0.0.1", 0, AckBatch, 0, nil); err == nil {
+		t.Error("Expected an error for a batch of 0 frames")
+	}
+}
+
+func TestFlowTCPReconnectBackoff(t *testing.T) {
+	s, _ := startFlowTCPServer(t, AckFrame, 0)
+	port := s.Port
+	s.Stop()
+
+	// the analyzer being down, the client is created anyway
+	c, err := NewTCPClient("127.0.0.1", port, true)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer c.Close()
+
+	if err := c.sendFrame(testFlows(1)); err == nil || !strings.Contains(err.Error(), "next attempt in") {
+		t.Errorf("Expected the reconnection to be delayed, got %v", err)
+	}
+	c.lock.Lock()
+	backoff := c.backoff
+	c.lock.Unlock()
+	if backoff != minReconnectBackoff {
+		t.Errorf("Expected a backoff of %s, got %s", minReconnectBackoff, backoff)
+	}
+
+	// a failed attempt doubles the backoff
+	c.lock.Lock()
+	c.retryAt = time.Time{}
+	c.lock.Unlock()
+	if err := c.sendFrame(testFlows(2)); err == nil {
+		t.Error("Expected the analyzer to be unreachable")
+	}
+	c.lock.Lock()
+	backoff = c.backoff
+	c.lock.Unlock()
+	if backoff != 2*minReconnectBackoff {
+		t.Errorf("Expected a backoff of %s, got %s", 2*minReconnectBackoff, backoff)
+	}
+
+	// once the analyzer is back, the pending frames are replayed
+	recorder := &frameRecorder{}
+	if s, err = NewFlowTCPServer("127.0.0.1", port, AckFrame, 0, recorder.analyzeFlows); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := s.Listen(); err != nil {
+		t.Fatal(err.Error())
+	}
+	go s.Serve()
+	defer s.Stop()
+
+	c.lock.Lock()
+	c.retryAt = time.Time{}
+	c.lock.Unlock()
+	if err := c.sendFrame(testFlows(3)); err != nil {
+		t.Fatal(err.Error())
+	}
+	if !waitAcked(c, 3) {
+		t.Fatalf("Expected the frames to be acked, got %d", c.Acked())
+	}
+	if n := recorder.count(); n != 3 {
+		t.Errorf("Expected the 3 frames to be analyzed, got %d", n)
+	}
+	c.lock.Lock()
+	backoff = c.backoff
+	c.lock.Unlock()
+	if backoff != 0 {
+		t.Errorf("Expected the backoff to be reset, got %s", backoff)
+	}
+}
+
+func TestFlowTCPDuplicateFrames(t *testing.T) {
+	s, recorder := startFlowTCPServer(t, AckFrame, 0)
+	defer s.Stop()
+	s.Sequencer, _ = ingestion.NewSequencer(16, "", 0)
+
+	conn, err := net.Dial("tcp", "127.0.0.1:"+strconv.Itoa(s.Port))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer conn.Close()
+
+	// the frames retransmitted are acknowledged without being analyzed
+	// again
+	ack := make([]byte, 8)
+	for _, seq := range []uint64{1, 2, 2, 1, 3} {
+		if err := writeFrame(conn, 42, seq, testFlows(1)); err != nil {
+			t.Fatal(err.Error())
+		}
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		if _, err := io.ReadFull(conn, ack); err != nil {
+			t.Fatalf("Frame %d not acked: %s", seq, err.Error())
+		}
+	}
+
+	if n := recorder.count(); n != 3 {
+		t.Errorf("Expected 3 frames analyzed, got %d", n)
+	}
+
+	expected := ingestion.SequenceStatus{Agent: "127.0.0.1", Epoch: 42, Last: 3, Received: 3, Duplicates: 2}
+	if status := s.Sequencer.Status(); len(status) != 1 || status[0] != expected {
+		t.Errorf("Expected the status %+v, got %+v", expected, status)
+	}
+}
+
+func TestMatchFlowFrame(t *testing.T) {
+	var frame bytes.Buffer
+	if err := writeFrame(&frame, 42, 1, testFlows(3)); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if result := MatchFlowFrame(frame.Bytes()[:4]); result != shttp.MuxNeedMore {
+		t.Errorf("Expected more bytes to be needed, got %d", result)
+	}
+	if result := MatchFlowFrame(frame.Bytes()); result != shttp.MuxMatched {
+		t.Errorf("Expected the frame to match, got %d", result)
+	}
+	for _, peek := range []string{"GET / HTTP/1.1", "\x16\x03\x01", "\x00\x00\x00\x10" + strings.Repeat("\x00", 16)} {
+		if result := MatchFlowFrame([]byte(peek)); result != shttp.MuxNoMatch {
+			t.Errorf("%q: expected no match, got %d", peek, result)
+		}
+	}
+}
+
+func TestFlowTCPMultiplexed(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	mux := shttp.NewMux(l, time.Second, 64)
+	mux.Match("http", shttp.MatchHTTP1)
+
+	recorder := &frameRecorder{}
+	s, err := NewFlowTCPServer("127.0.0.1", 0, AckFrame, 0, recorder.analyzeFlows)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	s.ListenOn(mux.Match("flow", MatchFlowFrame))
+	go s.Serve()
+	go mux.Serve()
+	defer mux.Close()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer conn.Close()
+
+	ack := make([]byte, 8)
+	for seq := uint64(1); seq <= 2; seq++ {
+		if err := writeFrame(conn, 42, seq, testFlows(2)); err != nil {
+			t.Fatal(err.Error())
+		}
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		if _, err := io.ReadFull(conn, ack); err != nil {
+			t.Fatalf("Frame %d not acked: %s", seq, err.Error())
+		}
+	}
+
+	if n := recorder.count(); n != 2 {
+		t.Errorf("Expected 2 frames analyzed, got %d", n)
+	}
+	if stats := mux.Stats(); stats.Accepted["flow"] != 1 {
+		t.Errorf("Expected the connection to be routed to the flow server, got %+v", stats)
+	}
+}
@@ -0,0 +1,205 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package analyzer
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/redhat-cip/skydive/handover"
+	"github.com/redhat-cip/skydive/logging"
+)
+
+func closeFiles(files map[string]*os.File) {
+	for _, f := range files {
+		f.Close()
+	}
+}
+
+// HandoverFiles returns copies of the sockets of the API, of the admin API
+// and of the flows sent over UDP and TCP, as listened
+func (s *Server) HandoverFiles() (map[string]*os.File, error) {
+	files, err := s.HTTPServer.Files()
+	if err != nil {
+		return nil, err
+	}
+
+	if s.flowTransports["udp"] {
+		s.udpLock.Lock()
+		conn := s.conn
+		s.udpLock.Unlock()
+
+		if conn == nil {
+			closeFiles(files)
+			return nil, errors.New("Flow socket not bound")
+		}
+
+		f, err := conn.File()
+		if err != nil {
+			closeFiles(files)
+			return nil, err
+		}
+		files["udp"] = f
+	}
+
+	if s.flowTransports["tcp"] {
+		f, err := s.FlowTCPServer.File()
+		if err != nil {
+			closeFiles(files)
+			return nil, err
+		}
+		files["tcp"] = f
+	}
+
+	return files, nil
+}
+
+// HandoverDrain stops reading the flows, the datagrams left in the socket
+// buffer being read by the new analyzer, processes the queued ones and
+// waits for the API requests in progress, then extracts the flow table
+func (s *Server) HandoverDrain() *handover.State {
+	s.stopUDPServer()
+
+	s.FlowTCPServer.Stop()
+	if s.FairQueue != nil {
+		s.FairQueue.Drain()
+	}
+	if s.Workers != nil {
+		s.Workers.Drain()
+	}
+
+	if err := s.HTTPServer.Drain(s.Handover.Timeout); err != nil {
+		logging.GetLogger().Warningf("API requests still in progress after the handover: %s", err.Error())
+	}
+
+	state := &handover.State{Flows: s.FlowTable.Extract()}
+	if s.FairQueue != nil {
+		state.Agents = s.FairQueue.Status()
+	}
+	if s.Sequencer != nil {
+		state.Sequences = s.Sequencer.State()
+	}
+
+	return state
+}
+
+// HandoverRestore puts the flows back in the flow table, to be stored when
+// the analyzer stops
+func (s *Server) HandoverRestore(state *handover.State) {
+	s.FlowTable.Merge(state.Flows)
+	if s.Sequencer != nil {
+		s.Sequencer.Restore(state.Sequences)
+	}
+}
+
+// listenFiles listens on the sockets handed over instead of binding the
+// addresses
+func (s *Server) listenFiles(files map[string]*os.File) error {
+	if err := s.HTTPServer.ListenFiles(files); err != nil {
+		return err
+	}
+
+	if s.flowTransports["udp"] {
+		f, ok := files["udp"]
+		if !ok {
+			return errors.New("No udp socket handed over")
+		}
+		conn, err := net.FilePacketConn(f)
+		if err != nil {
+			return err
+		}
+		udpConn, ok := conn.(*net.UDPConn)
+		if !ok {
+			conn.Close()
+			return fmt.Errorf("Not a UDP socket: %s", f.Name())
+		}
+		s.udpLock.Lock()
+		s.conn = udpConn
+		s.udpLock.Unlock()
+	}
+
+	if s.flowTransports["tcp"] {
+		f, ok := files["tcp"]
+		if !ok {
+			return errors.New("No tcp socket handed over")
+		}
+		return s.FlowTCPServer.ListenFile(f)
+	}
+	return nil
+}
+
+// closeListeners closes the sockets handed over when the handover is
+// aborted
+func (s *Server) closeListeners() {
+	s.HTTPServer.Stop()
+	s.udpLock.Lock()
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+	s.udpLock.Unlock()
+	s.FlowTCPServer.Stop()
+}
+
+// takeOver starts the subsystems on the sockets handed over, the handover
+// being aborted if one of them fails, the previous analyzer going on
+// serving. Once committed, the flows and the agent state of the previous
+// analyzer are taken over before reading the flows.
+func (s *Server) takeOver(r *handover.Receiver, subsystems []subsystem, ingestion []subsystem, timeout time.Duration) error {
+	err := s.listenFiles(r.Sockets)
+	if err == nil {
+		err = startSubsystems(subsystems, timeout)
+	}
+	if err != nil {
+		s.closeListeners()
+		r.Abort(err.Error())
+		return err
+	}
+
+	state, err := r.Commit()
+	if err != nil {
+		logging.GetLogger().Errorf("State of the previous analyzer not handed over: %s", err.Error())
+	} else {
+		s.FlowTable.Merge(state.Flows)
+		if s.FairQueue != nil {
+			s.FairQueue.Restore(state.Agents)
+		}
+		if s.Sequencer != nil {
+			s.Sequencer.Restore(state.Sequences)
+		}
+	}
+
+	return startSubsystems(ingestion, timeout)
+}
+
+// serveHandover listens for a new analyzer to hand over to
+func (s *Server) serveHandover() {
+	if err := s.Handover.Listen(); err != nil {
+		logging.GetLogger().Errorf("Handover disabled: %s", err.Error())
+		return
+	}
+	go s.Handover.Serve()
+}
@@ -0,0 +1,331 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package analyzer
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redhat-cip/skydive/flow"
+	"github.com/redhat-cip/skydive/flow/ingestion"
+	"github.com/redhat-cip/skydive/flow/mappings"
+	"github.com/redhat-cip/skydive/handover"
+	shttp "github.com/redhat-cip/skydive/http"
+	"github.com/redhat-cip/skydive/logging"
+	"github.com/redhat-cip/skydive/topology/alert"
+	"github.com/redhat-cip/skydive/topology/graph"
+)
+
+// newHandoverServer returns an analyzer reduced to the API, the flow
+// sockets and the flow table, storing its flows in memory
+func newHandoverServer(t *testing.T, path string) (*Server, *memoryStorage) {
+	b, _ := graph.NewMemoryBackend()
+	g, _ := graph.NewGraph(b)
+
+	st := &memoryStorage{}
+	s := &Server{
+		HTTPServer:          shttp.NewServer("analyzer", "127.0.0.1", 0, shttp.NewNoAuthenticationBackend()),
+		FlowTable:           flow.NewTable(),
+		FlowMappingPipeline: mappings.NewFlowMappingPipeline(),
+		AlertServer:         &alert.AlertServer{AlertManager: alert.NewAlertManager(g, nil)},
+		flowsLogSampler:     logging.NewSampler(1000, 0),
+		datagramLogSampler:  logging.NewSampler(1000, 0),
+		truncatedLogSampler: logging.NewSampler(1, 0),
+		droppedLogSampler:   logging.NewSampler(1, 0),
+		Storage:             st,
+		flowTransports:      map[string]bool{"udp": true, "tcp": true},
+	}
+	s.FlowTable.RegisterExpire(s.flowExpire, time.Hour, time.Hour)
+
+	var err error
+	if s.FlowTCPServer, err = NewFlowTCPServer("127.0.0.1", 0, AckNone, 0, s.AnalyzeFlows); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Datagrams, err = ingestion.NewDatagramReader(65535); err != nil {
+		t.Fatal(err.Error())
+	}
+	s.Handover = handover.New(path, 5*time.Second, s)
+
+	return s, st
+}
+
+func startHandoverServer(t *testing.T, s *Server) {
+	if err := s.startHTTPServer(s.HTTPServer); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := s.startUDPServer(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := s.startTCPServer(); err != nil {
+		t.Fatal(err.Error())
+	}
+	s.serveHandover()
+}
+
+func stopHandoverServer(s *Server) {
+	s.Handover.Stop()
+	s.stopUDPServer()
+	s.FlowTCPServer.Stop()
+	s.HTTPServer.Stop()
+	s.wgServers.Wait()
+}
+
+func counterFlow(uuid string, start int64, packets int64) *flow.Flow {
+	return &flow.Flow{
+		UUID: uuid,
+		Statistics: &flow.FlowStatistics{
+			Start: start,
+			Last:  time.Now().Unix(),
+			Endpoints: []*flow.FlowEndpointsStatistics{
+				{AB: &flow.FlowEndpointStatistics{Packets: uint64(packets)}, BA: &flow.FlowEndpointStatistics{}},
+			},
+		},
+	}
+}
+
+func flowPackets(f *flow.Flow) int64 {
+	if f == nil || f.Statistics == nil || len(f.Statistics.Endpoints) == 0 {
+		return -1
+	}
+	return int64(f.Statistics.Endpoints[0].AB.Packets)
+}
+
+// waitFlows waits for the flows of the table to have the packet counters
+func waitFlows(ft *flow.Table, packets map[string]int64) error {
+	for i := 0; i < 500; i++ {
+		missing := ""
+		for uuid, n := range packets {
+			if got := flowPackets(ft.GetFlow(uuid)); got != n {
+				missing = fmt.Sprintf("flow %s has %d packets, %d expected", uuid, got, n)
+				break
+			}
+		}
+		if missing == "" {
+			return nil
+		}
+		if i == 499 {
+			return errors.New(missing)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return nil
+}
+
+func handoverSocket(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "skydive-handover")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return filepath.Join(dir, "analyzer.sock"), func() { os.RemoveAll(dir) }
+}
+
+func TestHandoverWithFlowGenerator(t *testing.T) {
+	path, cleanup := handoverSocket(t)
+	defer cleanup()
+
+	old, oldStorage := newHandoverServer(t, path)
+	startHandoverServer(t, old)
+	defer stopHandoverServer(old)
+
+	addr := old.conn.LocalAddr().(*net.UDPAddr)
+	client, err := NewClient("127.0.0.1", addr.Port)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer client.Close()
+
+	// flows seen only by the previous analyzer, known by the new one once
+	// handed over
+	start := time.Now().Unix() - 100
+	idle := make(map[string]int64)
+	for i := 0; i < 5; i++ {
+		uuid := fmt.Sprintf("idle-%d", i)
+		idle[uuid] = int64(10 + i)
+		client.SendFlow(counterFlow(uuid, start, idle[uuid]))
+	}
+	if err := waitFlows(old.FlowTable, idle); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// the generator keeps updating the cumulative counters of active flows
+	var lock sync.Mutex
+	active := make(map[string]int64)
+	stop := make(chan bool)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for n := int64(1); ; n++ {
+			lock.Lock()
+			for i := 0; i < 5; i++ {
+				uuid := fmt.Sprintf("active-%d", i)
+				active[uuid] = n
+				client.SendFlow(counterFlow(uuid, start, n))
+			}
+			lock.Unlock()
+
+			select {
+			case <-stop:
+				return
+			case <-time.After(2 * time.Millisecond):
+			}
+		}
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+
+	s, newStorage := newHandoverServer(t, path)
+	receiver, err := s.Handover.Receive()
+	if err != nil || receiver == nil {
+		t.Fatalf("Expected the sockets to be handed over: %v", err)
+	}
+
+	api := []subsystem{{"http", func() error { return s.startHTTPServer(s.HTTPServer) }, s.HTTPServer.Stop}}
+	ingestion := []subsystem{{"udp", s.startUDPServer, nil}, {"tcp", s.startTCPServer, nil}}
+	if err := s.takeOver(receiver, api, ingestion, time.Second); err != nil {
+		t.Fatal(err.Error())
+	}
+	s.serveHandover()
+	defer stopHandoverServer(s)
+
+	select {
+	case <-old.Handover.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("The previous analyzer didn't hand over")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	close(stop)
+	wg.Wait()
+
+	// the last counters are sent again in case a datagram was lost
+	for i := 0; i < 3; i++ {
+		for uuid, n := range active {
+			client.SendFlow(counterFlow(uuid, start, n))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := waitFlows(s.FlowTable, idle); err != nil {
+		t.Errorf("Flows of the previous analyzer not handed over: %s", err.Error())
+	}
+	if err := waitFlows(s.FlowTable, active); err != nil {
+		t.Errorf("Flow updates lost: %s", err.Error())
+	}
+
+	status := old.Handover.Status()
+	if status.State != handover.HandedOver || status.Flows < len(idle)+len(active) || status.Peer != os.Getpid() {
+		t.Errorf("Wrong status of the previous analyzer: %+v", status)
+	}
+	if status = s.Handover.Status(); status.State != handover.Received || status.Flows != old.Handover.Status().Flows {
+		t.Errorf("Wrong status of the new analyzer: %+v", status)
+	}
+
+	// each flow is stored once, by the new analyzer, with its last counters
+	old.FlowTable.UnregisterAll()
+	s.FlowTable.UnregisterAll()
+
+	if len(oldStorage.flows) != 0 {
+		t.Errorf("Expected the previous analyzer to store no flow, got %d", len(oldStorage.flows))
+	}
+
+	stored := make(map[string]int64)
+	for _, f := range newStorage.flows {
+		if _, ok := stored[f.UUID]; ok {
+			t.Errorf("Flow %s stored twice", f.UUID)
+		}
+		stored[f.UUID] = flowPackets(f)
+		if f.Statistics.Start != start {
+			t.Errorf("Flow %s lost its first seen time: %d", f.UUID, f.Statistics.Start)
+		}
+	}
+	for _, expected := range []map[string]int64{idle, active} {
+		for uuid, n := range expected {
+			if stored[uuid] != n {
+				t.Errorf("Flow %s stored with %d packets, %d expected", uuid, stored[uuid], n)
+			}
+		}
+	}
+
+	// the new analyzer listens for the next handover
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("Expected the new analyzer to listen on the handover socket: %s", err.Error())
+	}
+}
+
+func TestHandoverAbort(t *testing.T) {
+	path, cleanup := handoverSocket(t)
+	defer cleanup()
+
+	old, _ := newHandoverServer(t, path)
+	startHandoverServer(t, old)
+	defer stopHandoverServer(old)
+
+	s, _ := newHandoverServer(t, path)
+	receiver, err := s.Handover.Receive()
+	if err != nil || receiver == nil {
+		t.Fatalf("Expected the sockets to be handed over: %v", err)
+	}
+
+	// the new analyzer fails to start
+	failing := []subsystem{{"storage", func() error { return errors.New("connection refused") }, nil}}
+	if err := s.takeOver(receiver, failing, nil, time.Second); err == nil {
+		t.Fatal("Expected the handover to be aborted")
+	}
+
+	for i := 0; i < 100 && old.Handover.Status().State != handover.Aborted; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if status := old.Handover.Status(); status.State != handover.Aborted || status.Error == "" {
+		t.Errorf("Expected the handover to be aborted, got %+v", status)
+	}
+	if status := s.Handover.Status(); status.State != handover.Aborted {
+		t.Errorf("Expected the new analyzer to report the abort, got %+v", status)
+	}
+
+	// the previous analyzer keeps reading the flows
+	addr := old.conn.LocalAddr().(*net.UDPAddr)
+	client, err := NewClient("127.0.0.1", addr.Port)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer client.Close()
+
+	client.SendFlow(counterFlow("flow", time.Now().Unix(), 1))
+	if err := waitFlows(old.FlowTable, map[string]int64{"flow": 1}); err != nil {
+		t.Errorf("The previous analyzer stopped serving: %s", err.Error())
+	}
+
+	select {
+	case <-old.Handover.Done():
+		t.Error("The previous analyzer shouldn't exit")
+	default:
+	}
+}
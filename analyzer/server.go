@@ -23,25 +23,34 @@
 package analyzer
 
 import (
+	"errors"
+	"fmt"
 	"net"
 	"net/http"
 	"os"
 	"strconv"
 	"sync"
-	"sync/atomic"
 	"time"
 
+	"golang.org/x/net/context"
+
 	"github.com/redhat-cip/skydive/api"
 	"github.com/redhat-cip/skydive/config"
 	"github.com/redhat-cip/skydive/flow"
+	"github.com/redhat-cip/skydive/flow/ingestion"
+	"github.com/redhat-cip/skydive/flow/live"
 	"github.com/redhat-cip/skydive/flow/mappings"
+	"github.com/redhat-cip/skydive/handover"
 	shttp "github.com/redhat-cip/skydive/http"
 	"github.com/redhat-cip/skydive/logging"
+	"github.com/redhat-cip/skydive/metrics"
 	"github.com/redhat-cip/skydive/storage"
 	"github.com/redhat-cip/skydive/storage/elasticsearch"
 	"github.com/redhat-cip/skydive/storage/etcd"
+	"github.com/redhat-cip/skydive/storage/kafka"
 	"github.com/redhat-cip/skydive/topology/alert"
 	"github.com/redhat-cip/skydive/topology/graph"
+	"github.com/redhat-cip/skydive/topology/synthetic"
 )
 
 type Server struct {
@@ -50,107 +59,662 @@ type Server struct {
 	GraphServer         *graph.GraphServer
 	AlertServer         *alert.AlertServer
 	FlowMappingPipeline *mappings.FlowMappingPipeline
+	FlowDebugServer     *mappings.FlowDebugServer
+	LiveFlowServer      *live.LiveFlowServer
+	FlowEdges           *flow.EdgeProjector
+	Significance        *storage.SignificanceFilter
+	captureSignificance *captureSignificance
+	Bootstrap           *Bootstrap
+	flowsLogSampler     *logging.Sampler
+	datagramLogSampler  *logging.Sampler
+	truncatedLogSampler *logging.Sampler
+	droppedLogSampler   *logging.Sampler
 	Storage             storage.Storage
+	WAL                 *storage.WAL
+	KafkaSink           *kafka.FlowSink
+	FlowEvents          *flow.FlowEventDispatcher
+	ReportScheduler     *api.ReportScheduler
+	ProbeScheduler      *synthetic.Scheduler
 	FlowTable           *flow.Table
+	FlowAggregates      *api.FlowAggregates
+	FlowRollups         *api.FlowRollups
+	FlowBackfill        *api.FlowBackfill
 	conn                *net.UDPConn
+	Datagrams           *ingestion.DatagramReader
+	NetFlow             *ingestion.NetFlowListener
+	SFlow               *ingestion.SFlowListener
+	FlowTCPServer       *FlowTCPServer
+	flowTransports      map[string]bool
+	FairQueue           *ingestion.FairQueue
+	Workers             *ingestion.WorkerPool
+	Sequencer           *ingestion.Sequencer
 	EmbeddedEtcd        *etcd.EmbeddedEtcd
 	EtcdClient          *etcd.EtcdClient
-	running             atomic.Value
-	wgServers           sync.WaitGroup
+	Handover            *handover.Handover
+	Mux                 *shttp.Mux
+	// guards the flow socket, the cancellation of the reading of its
+	// datagrams and the stop of the analyzer, no socket being bound once
+	// stopped
+	udpLock    sync.Mutex
+	cancelUDP  context.CancelFunc
+	udpStopped bool
+	wgServers  sync.WaitGroup
+	wgUDP      sync.WaitGroup
+	stopOnce   sync.Once
 }
 
-func (s *Server) flowExpireUpdate(flows []*flow.Flow) {
-	if s.Storage != nil {
-		s.Storage.StoreFlows(flows)
+// ErrServerStopped is returned when starting a stopped analyzer
+var ErrServerStopped = errors.New("Analyzer stopped")
+
+// storeFlows stores the flows, the insignificant ones being folded into
+// noise records once expired
+func (s *Server) storeFlows(flows []*flow.Flow, expired bool) {
+	if s.Significance != nil {
+		flows = s.Significance.Filter(flows, expired)
+	}
+
+	if s.WAL != nil {
+		if err := s.WAL.StoreFlows(flows); err != nil {
+			metrics.StorageErrors.Inc()
+			logging.GetLogger().Errorf("Unable to store %d flows: %s", len(flows), err.Error())
+			return
+		}
+		metrics.FlowsStored.Add(float64(len(flows)))
 		logging.GetLogger().Debugf("%d flows stored", len(flows))
+	} else if s.Storage != nil {
+		if err := s.Storage.StoreFlows(flows); err != nil {
+			metrics.StorageErrors.Inc()
+			logging.GetLogger().Errorf("Unable to store %d flows: %s", len(flows), err.Error())
+			return
+		}
+		metrics.FlowsStored.Add(float64(len(flows)))
+		logging.GetLogger().Debugf("%d flows stored", len(flows))
+	}
+}
+
+func (s *Server) flowExpireUpdate(flows []*flow.Flow) {
+	s.storeFlows(flows, false)
+
+	if s.FlowEdges != nil {
+		s.FlowEdges.OnFlowsUpdated(flows)
+	}
+}
+
+func (s *Server) flowExpire(flows []*flow.Flow) {
+	start := time.Now()
+	defer func() {
+		metrics.FlowTableExpireDuration.Observe(time.Since(start).Seconds())
+	}()
+	metrics.FlowsExpired.Add(float64(len(flows)))
+
+	s.storeFlows(flows, true)
+
+	if s.FlowEdges != nil {
+		s.FlowEdges.OnFlowsUpdated(flows)
+	}
+
+	if s.LiveFlowServer != nil {
+		s.LiveFlowServer.OnFlowsExpired(flows)
+	}
+
+	if s.FlowEdges != nil {
+		s.FlowEdges.OnFlowsExpired(flows)
+	}
+
+	// a sink subscribed to the lifecycle events gets the expired ones
+	if s.KafkaSink != nil && s.KafkaSink.Events == nil {
+		if err := s.KafkaSink.StoreFlows(flows); err != nil {
+			logging.GetLogger().Errorf("Unable to export flows to Kafka: %s", err.Error())
+		}
+	}
+}
+
+// storageEventListener stores the flows of the lifecycle events, indexed
+// by UUID so that a flow is updated in place
+type storageEventListener struct {
+	server *Server
+}
+
+func (l *storageEventListener) OnFlowEvents(events []*flow.FlowEvent) {
+	if l.server.Storage == nil {
+		return
+	}
+
+	flows := make([]*flow.Flow, len(events))
+	for i, e := range events {
+		flows[i] = e.Flow
+	}
+	if err := l.server.Storage.StoreFlows(flows); err != nil {
+		metrics.StorageErrors.Inc()
+		logging.GetLogger().Errorf("Unable to store the flow events: %s", err.Error())
 	}
 }
 
+// SetFlowEventsFromConfig subscribes the sinks to the lifecycle events of
+// the flows of the flow table. The storage keeps storing the expired and
+// periodically updated flows, and upserts the created and updated ones only
+// when analyzer.flow_events.storage_upsert is set.
+func (s *Server) SetFlowEventsFromConfig() {
+	cfg := config.GetConfig()
+
+	if s.KafkaSink != nil && s.KafkaSink.Events != nil {
+		s.FlowEvents.Subscribe(s.KafkaSink, s.KafkaSink.Events)
+	}
+	if s.LiveFlowServer != nil {
+		s.FlowEvents.Subscribe(s.LiveFlowServer, flow.FlowEventTypes{flow.FlowCreated: true, flow.FlowUpdated: true, flow.FlowExpired: true})
+	}
+	if cfg.GetBool("analyzer.flow_events.storage_upsert") {
+		s.FlowEvents.Subscribe(&storageEventListener{server: s}, flow.FlowEventTypes{flow.FlowCreated: true, flow.FlowUpdated: true})
+	}
+
+	if s.FlowEvents.Subscribed() {
+		s.FlowTable.SetEventListener(s.FlowEvents, flow.FlowUpdatePolicy{
+			Interval:   int64(cfg.GetInt("analyzer.flow_events.update_interval")),
+			BytesRatio: cfg.GetFloat64("analyzer.flow_events.update_ratio"),
+		})
+	}
+}
+
+// reportAlertListener records the fired alerts for the alert sections of
+// the reports
+type reportAlertListener struct {
+	scheduler *api.ReportScheduler
+}
+
+func (l *reportAlertListener) OnAlert(msg *alert.AlertMessage) {
+	l.scheduler.RecordAlert(msg.UUID, msg.Timestamp)
+}
+
 func (s *Server) AnalyzeFlows(flows []*flow.Flow) {
-	s.FlowTable.Update(flows)
+	metrics.FlowsReceived.Add(float64(len(flows)))
+
+	// enhanced first so that the lifecycle events carry the enhanced flows
 	s.FlowMappingPipeline.Enhance(flows)
+	s.FlowTable.Update(flows)
+	if s.LiveFlowServer != nil {
+		s.LiveFlowServer.OnFlowsUpdated(flows)
+	}
+	s.AlertServer.AlertManager.EvalFlows(flows)
 
-	logging.GetLogger().Debugf("%d flows received", len(flows))
+	if ok, skipped := s.flowsLogSampler.Allow(); ok {
+		logging.GetLogger().Debugf("%d flows received, %d messages skipped", len(flows), skipped)
+	}
 }
 
-func (s *Server) handleUDPFlowPacket() {
-	s.conn.SetDeadline(time.Now().Add(200 * time.Millisecond))
-	data := make([]byte, 4096)
+// analyzeFlowData analyzes the flows of a datagram, a single flow or a
+// batch whose malformed records are skipped
+func (s *Server) analyzeFlowData(agent string, data []byte) {
+	flows, errs := flow.DecodeBatch(data)
+	if len(errs) > 0 {
+		metrics.FlowParseErrors.Add(float64(len(errs)))
+		if s.Datagrams != nil {
+			s.Datagrams.Malformed(len(errs))
+		}
+	}
+	for _, err := range errs {
+		logging.GetLogger().Errorf("Error while parsing flow from %s: %s", agent, err.Error())
+		logging.GetJournal(logging.JournalParseErrors).Record("Flow from %s: %s", agent, err.Error())
+	}
+
+	if len(flows) > 0 {
+		s.AnalyzeFlows(flows)
+	}
+}
 
-	for s.running.Load() == true {
-		n, _, err := s.conn.ReadFromUDP(data)
+// handleUDPFlowPacket reads the datagrams until the context is cancelled,
+// the read in progress being then interrupted by stopUDPServer
+func (s *Server) handleUDPFlowPacket(ctx context.Context, conn *net.UDPConn) {
+	data := s.Datagrams.Buffer()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+		n, addr, err := conn.ReadFromUDP(data)
 		if err != nil {
-			if err.(net.Error).Timeout() == true {
-				s.conn.SetDeadline(time.Now().Add(200 * time.Millisecond))
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
 				continue
 			}
-			if s.running.Load() == false {
-				return
+			if ctx.Err() == nil {
+				metrics.UDPReadErrors.Inc()
+				logging.GetLogger().Errorf("Error while reading: %s", err.Error())
 			}
-			logging.GetLogger().Errorf("Error while reading: %s", err.Error())
 			return
 		}
 
-		f, err := flow.FromData(data[0:n])
-		if err != nil {
-			logging.GetLogger().Errorf("Error while parsing flow: %s", err.Error())
+		if ok, skipped := s.datagramLogSampler.Allow(); ok {
+			logging.GetLogger().Debugf("Datagram of %d bytes received from %s, %d messages skipped", n, addr.IP.String(), skipped)
 		}
 
-		s.AnalyzeFlows([]*flow.Flow{f})
+		// a truncated flow would fail to parse
+		if !s.Datagrams.Check(addr.IP.String(), n) {
+			metrics.UDPDatagramsTruncated.Inc()
+			if ok, skipped := s.truncatedLogSampler.Allow(); ok {
+				logging.GetLogger().Warningf("Datagram from %s larger than %d bytes dropped, %d messages skipped", addr.IP.String(), s.Datagrams.MaxSize, skipped)
+			}
+			continue
+		}
+
+		if s.FairQueue == nil && s.Workers == nil {
+			s.analyzeFlowData(addr.IP.String(), data[0:n])
+			continue
+		}
+
+		// the buffer is reused, the queued datagram needs its own copy
+		datagram := make([]byte, n)
+		copy(datagram, data[0:n])
+		if s.FairQueue != nil {
+			s.FairQueue.Enqueue(addr.IP.String(), datagram)
+		} else if !s.Workers.Enqueue(addr.IP.String(), datagram) {
+			metrics.UDPDatagramsDropped.Inc()
+			if ok, skipped := s.droppedLogSampler.Allow(); ok {
+				logging.GetLogger().Warningf("Datagram from %s dropped, ingestion queue full, %d messages skipped", addr.IP.String(), skipped)
+			}
+		}
 	}
 }
 
-func (s *Server) ListenAndServe() {
-	s.running.Store(true)
+// startUDPServer reads the flows on the socket handed over, or binds the
+// address of the API
+func (s *Server) startUDPServer() error {
+	s.udpLock.Lock()
+	defer s.udpLock.Unlock()
 
-	if s.Storage != nil {
-		s.Storage.Start()
+	if s.udpStopped {
+		return ErrServerStopped
 	}
 
-	s.AlertServer.AlertManager.Start()
+	if s.conn == nil {
+		host := s.HTTPServer.Addr + ":" + strconv.FormatInt(int64(s.HTTPServer.Port), 10)
+		addr, err := net.ResolveUDPAddr("udp", host)
+		if err != nil {
+			return err
+		}
+
+		if s.conn, err = net.ListenUDP("udp", addr); err != nil {
+			return err
+		}
+	}
 
-	s.wgServers.Add(3)
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancelUDP = cancel
+
+	conn := s.conn
+	s.wgServers.Add(1)
+	s.wgUDP.Add(1)
 	go func() {
 		defer s.wgServers.Done()
-		s.HTTPServer.ListenAndServe()
+		defer s.wgUDP.Done()
+		defer conn.Close()
+
+		s.handleUDPFlowPacket(ctx, conn)
 	}()
 
+	return nil
+}
+
+// stopUDPServer stops reading the datagrams, returning once the reader
+// exited
+func (s *Server) stopUDPServer() {
+	s.udpLock.Lock()
+	if s.cancelUDP != nil {
+		s.cancelUDP()
+		s.cancelUDP = nil
+		// interrupts the read in progress
+		s.conn.SetReadDeadline(time.Now())
+	}
+	s.udpLock.Unlock()
+
+	s.wgUDP.Wait()
+}
+
+// closeUDPServer stops reading the datagrams for good, closing the socket
+// even if it was never read, and prevents it from being bound again
+func (s *Server) closeUDPServer() {
+	s.udpLock.Lock()
+	s.udpStopped = true
+	s.udpLock.Unlock()
+
+	s.stopUDPServer()
+
+	s.udpLock.Lock()
+	if s.conn != nil {
+		s.conn.Close()
+	}
+	s.udpLock.Unlock()
+}
+
+func (s *Server) startTCPServer() error {
+	if s.FlowTCPServer.running.Load() != true {
+		if err := s.FlowTCPServer.Listen(); err != nil {
+			return err
+		}
+	}
+
+	s.wgServers.Add(1)
 	go func() {
 		defer s.wgServers.Done()
-		s.WSServer.ListenAndServe()
+		s.FlowTCPServer.Serve()
 	}()
 
+	return nil
+}
+
+func (s *Server) startHTTPServer(server *shttp.Server) error {
+	if !server.Listening() {
+		if err := server.Listen(); err != nil {
+			return err
+		}
+	}
+
+	s.wgServers.Add(1)
 	go func() {
 		defer s.wgServers.Done()
+		server.Serve()
+	}()
 
-		host := s.HTTPServer.Addr + ":" + strconv.FormatInt(int64(s.HTTPServer.Port), 10)
-		addr, err := net.ResolveUDPAddr("udp", host)
-		s.conn, err = net.ListenUDP("udp", addr)
-		if err != nil {
-			panic(err)
+	return nil
+}
+
+// listenMux binds the listen address, the API and the flows sent over TCP
+// being then served on it
+func (s *Server) listenMux() error {
+	l, err := net.Listen("tcp", fmt.Sprintf("%s:%d", s.HTTPServer.Addr, s.HTTPServer.Port))
+	if err != nil {
+		return err
+	}
+	s.Mux.Listener = l
+
+	if err = s.HTTPServer.ListenOn(s.Mux.Match("http", shttp.MatchHTTP1)); err != nil {
+		l.Close()
+		return err
+	}
+	if s.flowTransports["tcp"] {
+		s.FlowTCPServer.ListenOn(s.Mux.Match("flow", MatchFlowFrame))
+	}
+
+	return nil
+}
+
+func (s *Server) startMux() error {
+	s.wgServers.Add(1)
+	go func() {
+		defer s.wgServers.Done()
+		if err := s.Mux.Serve(); err != nil {
+			logging.GetLogger().Errorf("Multiplexed port closed: %s", err.Error())
 		}
-		defer s.conn.Close()
+	}()
 
-		s.handleUDPFlowPacket()
+	return nil
+}
+
+func (s *Server) startWSServer(server *shttp.WSServer) error {
+	s.wgServers.Add(1)
+	go func() {
+		defer s.wgServers.Done()
+		server.ListenAndServe()
 	}()
 
-	go s.FlowTable.Start()
+	return nil
 }
 
+// Start starts all the subsystems of the analyzer. The returned error, a
+// StartupError, names the subsystems that failed to start or didn't start
+// within the analyzer.startup_timeout, the other ones being stopped.
+//
+// With a handover socket, the sockets of the analyzer running are taken
+// over, the flows being read once its flows were handed over. If one of the
+// subsystems fails to start, the handover is aborted, the running analyzer
+// going on serving.
+func (s *Server) Start() error {
+	var receiver *handover.Receiver
+	if s.Handover != nil {
+		var err error
+		if receiver, err = s.Handover.Receive(); err != nil {
+			return fmt.Errorf("Handover from the running analyzer failed: %s", err.Error())
+		}
+	}
+
+	// bound before the servers start, not to bind their own ports
+	if s.Mux != nil {
+		if err := s.listenMux(); err != nil {
+			return err
+		}
+	}
+
+	var ingestion []subsystem
+	if s.flowTransports["udp"] {
+		ingestion = append(ingestion, subsystem{"udp", s.startUDPServer, s.stopUDPServer})
+	}
+	if s.flowTransports["tcp"] {
+		ingestion = append(ingestion, subsystem{"tcp", s.startTCPServer, s.FlowTCPServer.Stop})
+	}
+	if s.NetFlow != nil {
+		ingestion = append(ingestion, subsystem{"netflow", s.NetFlow.Start, s.NetFlow.Stop})
+	}
+	if s.SFlow != nil {
+		ingestion = append(ingestion, subsystem{"sflow", s.SFlow.Start, s.SFlow.Stop})
+	}
+
+	var subsystems []subsystem
+	if s.Mux != nil {
+		subsystems = append(subsystems, subsystem{"mux", s.startMux, func() { s.Mux.Close() }})
+	}
+
+	subsystems = append(subsystems, []subsystem{
+		{"http", func() error { return s.startHTTPServer(s.HTTPServer) }, s.HTTPServer.Stop},
+		{"websocket", func() error { return s.startWSServer(s.WSServer) }, s.WSServer.Stop},
+		{"ingestion", func() error {
+			if s.FairQueue != nil {
+				s.FairQueue.Start()
+			}
+			if s.Workers != nil {
+				s.Workers.Start()
+			}
+			if s.Sequencer != nil {
+				s.Sequencer.Start()
+			}
+			return nil
+		}, s.stopIngestion},
+		{"alert manager", func() error {
+			s.AlertServer.AlertManager.Start()
+			return nil
+		}, s.AlertServer.AlertManager.Stop},
+		{"flow table", func() error {
+			go s.FlowTable.Start()
+			return nil
+		}, s.FlowTable.Stop},
+		{"flow aggregates", func() error {
+			s.FlowAggregates.Start()
+			return nil
+		}, s.FlowAggregates.Stop},
+		{"report scheduler", func() error {
+			s.ReportScheduler.Start()
+			return nil
+		}, s.ReportScheduler.Stop},
+		{"probe scheduler", func() error {
+			s.ProbeScheduler.Start()
+			return nil
+		}, s.ProbeScheduler.Stop},
+	}...)
+
+	if s.Storage != nil {
+		subsystems = append(subsystems, subsystem{"storage", func() error {
+			s.Storage.Start()
+			if s.WAL != nil {
+				s.WAL.Start()
+			}
+			if s.captureSignificance != nil {
+				s.captureSignificance.Start()
+			}
+			return nil
+		}, s.stopStorage})
+	}
+
+	if s.FlowRollups != nil {
+		subsystems = append(subsystems, subsystem{"flow rollups", func() error {
+			s.FlowRollups.Start()
+			return nil
+		}, s.FlowRollups.Stop})
+	}
+
+	if s.FlowBackfill != nil {
+		subsystems = append(subsystems, subsystem{"flow backfill", func() error {
+			s.FlowBackfill.Start()
+			return nil
+		}, s.FlowBackfill.Stop})
+	}
+
+	if s.KafkaSink != nil {
+		subsystems = append(subsystems, subsystem{"kafka", func() error {
+			s.KafkaSink.Start()
+			return nil
+		}, s.KafkaSink.Stop})
+	}
+
+	if s.FlowDebugServer != nil {
+		subsystems = append(subsystems, subsystem{"flow debug", func() error {
+			return s.startWSServer(s.FlowDebugServer.WSServer)
+		}, s.FlowDebugServer.WSServer.Stop})
+	}
+
+	if s.LiveFlowServer != nil {
+		subsystems = append(subsystems, subsystem{"live flows", func() error {
+			return s.startWSServer(s.LiveFlowServer.WSServer)
+		}, s.LiveFlowServer.WSServer.Stop})
+	}
+
+	if s.Bootstrap != nil {
+		subsystems = append(subsystems, subsystem{"bootstrap", func() error {
+			s.Bootstrap.Start()
+			return nil
+		}, s.Bootstrap.Stop})
+	}
+
+	timeout := time.Duration(config.GetConfig().GetInt("analyzer.startup_timeout")) * time.Second
+
+	var err error
+	if receiver != nil {
+		err = s.takeOver(receiver, subsystems, ingestion, timeout)
+	} else {
+		err = startSubsystems(append(subsystems, ingestion...), timeout)
+	}
+
+	if err == nil && s.Handover != nil {
+		s.serveHandover()
+	}
+	return err
+}
+
+// ListenAndServe starts the analyzer, returning the error of the subsystem
+// failing to start, if any
+func (s *Server) ListenAndServe() error {
+	return s.Start()
+}
+
+// stopIngestion stops the workers and checkpoints the receive windows of
+// the frames of the agents
+func (s *Server) stopIngestion() {
+	if s.FairQueue != nil {
+		s.FairQueue.Stop()
+	}
+	if s.Workers != nil {
+		s.Workers.Stop()
+	}
+	if s.Sequencer != nil {
+		s.Sequencer.Stop()
+	}
+}
+
+// stopStorage stops replaying the write-ahead log before the storage
+func (s *Server) stopStorage() {
+	// the noise records of the intervals in progress
+	if s.Significance != nil {
+		if records := s.Significance.Flush(); len(records) > 0 {
+			s.storeFlows(records, false)
+		}
+	}
+	if s.captureSignificance != nil {
+		s.captureSignificance.Stop()
+	}
+
+	if s.WAL != nil {
+		s.WAL.Stop()
+	}
+	if s.Storage != nil {
+		s.Storage.Stop()
+	}
+}
+
+// Stop stops the analyzer, whether started or not, the next calls doing
+// nothing
 func (s *Server) Stop() {
-	s.running.Store(false)
+	s.stopOnce.Do(s.stop)
+}
+
+func (s *Server) stop() {
+	if s.Handover != nil {
+		s.Handover.Stop()
+	}
+	s.closeUDPServer()
+	if s.NetFlow != nil {
+		s.NetFlow.Stop()
+	}
+	if s.SFlow != nil {
+		s.SFlow.Stop()
+	}
+	if s.Bootstrap != nil {
+		s.Bootstrap.Stop()
+	}
+	if s.Mux != nil {
+		s.Mux.Close()
+	}
+	if s.FlowTCPServer != nil {
+		s.FlowTCPServer.Stop()
+	}
+	s.stopIngestion()
 	s.FlowTable.Stop()
 	s.FlowTable.UnregisterAll()
-	s.WSServer.Stop()
+	if s.FlowAggregates != nil {
+		s.FlowAggregates.Stop()
+	}
+	if s.FlowRollups != nil {
+		s.FlowRollups.Stop()
+	}
+	if s.FlowBackfill != nil {
+		s.FlowBackfill.Stop()
+	}
+	if s.WSServer != nil {
+		s.WSServer.Stop()
+	}
+	if s.FlowDebugServer != nil {
+		s.FlowDebugServer.WSServer.Stop()
+	}
+	if s.LiveFlowServer != nil {
+		s.LiveFlowServer.WSServer.Stop()
+	}
 	s.HTTPServer.Stop()
 	if s.EmbeddedEtcd != nil {
 		s.EmbeddedEtcd.Stop()
 	}
-	if s.Storage != nil {
-		s.Storage.Stop()
+	s.stopStorage()
+	if s.KafkaSink != nil {
+		s.KafkaSink.Stop()
 	}
 	s.AlertServer.AlertManager.Stop()
-	s.EtcdClient.Stop()
+	if s.ReportScheduler != nil {
+		s.ReportScheduler.Stop()
+	}
+	if s.ProbeScheduler != nil {
+		s.ProbeScheduler.Stop()
+	}
+	if s.EtcdClient != nil {
+		s.EtcdClient.Stop()
+	}
 	s.wgServers.Wait()
 	if tr, ok := http.DefaultTransport.(interface {
 		CloseIdleConnections()
@@ -159,27 +723,73 @@ func (s *Server) Stop() {
 	}
 }
 
-func (s *Server) SetStorage(storage storage.Storage) {
-	s.Storage = storage
+func (s *Server) SetStorage(st storage.Storage) {
+	s.Storage = storage.NewAliasedStorageFromConfig(st)
+
+	// the expired flows are stored in batches fitting a bulk of the storage
+	if limiter, ok := st.(storage.BulkLimiter); ok && s.FlowTable != nil {
+		maxBulk := limiter.MaxBulkSize()
+		batch := config.GetConfig().GetInt("analyzer.flowtable_expire_batch")
+		if maxBulk > 0 && (batch == 0 || batch > maxBulk) {
+			logging.GetLogger().Infof("Expiring the flows in batches of %d flows, the bulk size of the storage", maxBulk)
+			s.FlowTable.SetExpireBatchSize(maxBulk)
+		}
+	}
+	s.AlertServer.AlertManager.SetStorage(s.Storage)
 }
 
 func (s *Server) SetStorageFromConfig() {
 	if t := config.GetConfig().GetString("analyzer.storage"); t != "" {
 		switch t {
 		case "elasticsearch":
-			storage, err := elasticsearch.New()
+			if field := config.GetConfig().GetString("storage.routing.field"); field != "" {
+				router, err := elasticsearch.NewRouter()
+				if err != nil {
+					logging.GetLogger().Fatalf("Can't connect to ElasticSearch server: %v", err)
+				}
+				routed, err := storage.NewRoutedStorageFromConfig(router)
+				if err != nil {
+					logging.GetLogger().Fatalf("Can't route the flows on %s: %v", field, err)
+				}
+				s.SetStorage(routed)
+				break
+			}
+
+			es, err := elasticsearch.New()
 			if err != nil {
 				logging.GetLogger().Fatalf("Can't connect to ElasticSearch server: %v", err)
 			}
-			s.SetStorage(storage)
+			s.SetStorage(es)
 		default:
 			logging.GetLogger().Fatalf("Storage type unknown: %s", t)
 			os.Exit(1)
 		}
 		logging.GetLogger().Infof("Using %s as storage", t)
+
+		wal, err := storage.NewWALFromConfig(s.Storage)
+		if err != nil {
+			logging.GetLogger().Fatalf("Can't open the write-ahead log of the storage: %v", err)
+		}
+		s.WAL = wal
 	}
 }
 
+func (s *Server) SetFlowExportFromConfig() error {
+	switch t := config.GetConfig().GetString("analyzer.flow_export"); t {
+	case "":
+	case "kafka":
+		sink, err := kafka.NewFlowSinkFromConfig()
+		if err != nil {
+			return err
+		}
+		s.KafkaSink = sink
+		logging.GetLogger().Infof("Exporting the expired flows to the Kafka topic %s", sink.Topic)
+	default:
+		return fmt.Errorf("Flow export type unknown: %s", t)
+	}
+	return nil
+}
+
 func NewServerFromConfig() (*Server, error) {
 	embedEtcd := config.GetConfig().GetBool("etcd.embedded")
 
@@ -198,9 +808,15 @@ func NewServerFromConfig() (*Server, error) {
 		return nil, err
 	}
 
+	shttp.RegisterPprofFromConfig(httpServer)
+
 	wsServer := shttp.NewWSServerFromConfig(httpServer, "/ws")
 
-	api.RegisterTopologyApi("analyzer", g, httpServer)
+	topologyApi := api.RegisterTopologyApi("analyzer", g, httpServer)
+	topologyApi.Keys = graph.NewMetadataKeyCatalogFromConfig("analyzer", g)
+	api.RegisterRuntimeApi("analyzer", httpServer)
+	api.RegisterVersionApi(httpServer)
+	metrics.RegisterHandler(httpServer)
 
 	var etcdServer *etcd.EmbeddedEtcd
 	if embedEtcd {
@@ -227,17 +843,24 @@ func NewServerFromConfig() (*Server, error) {
 	if err != nil {
 		return nil, err
 	}
+	topologyApi.Captures = api.CaptureLookup(captureHandler)
 
 	alertHandler := &api.BasicApiHandler{
 		ResourceHandler: &api.AlertHandler{},
 		EtcdKeyAPI:      etcdClient.KeysApi,
 	}
+	alertManager := alert.NewAlertManager(g, alertHandler)
+
+	// registered before the generic routes of the alerts to take precedence
+	// over the one showing an alert
+	alertContextApi := api.RegisterAlertContextApi(alertManager, g, httpServer)
+
 	err = apiServer.RegisterApiHandler(alertHandler)
 	if err != nil {
 		return nil, err
 	}
 
-	alertManager := alert.NewAlertManager(g, alertHandler)
+	api.RegisterBackupApi(apiServer, g)
 
 	aserver := alert.NewServer(alertManager, wsServer)
 	gserver := graph.NewServer(g, wsServer)
@@ -245,9 +868,53 @@ func NewServerFromConfig() (*Server, error) {
 	gfe := mappings.NewGraphFlowEnhancer(g)
 	ofe := mappings.NewOvsFlowEnhancer(g)
 
-	pipeline := mappings.NewFlowMappingPipeline(gfe, ofe)
+	enhancers := []mappings.FlowEnhancer{gfe, ofe}
+	asn, err := mappings.NewASNFlowEnhancerFromConfig()
+	if err != nil {
+		return nil, err
+	}
+	if asn != nil {
+		enhancers = append(enhancers, asn)
+	}
+	if ja3 := mappings.NewJA3FlowEnhancerFromConfig(); ja3 != nil {
+		enhancers = append(enhancers, ja3)
+	}
+
+	schema, err := mappings.NewAttributeSchemaFromConfig(enhancers...)
+	if err != nil {
+		return nil, err
+	}
+
+	pipeline := mappings.NewFlowMappingPipeline(enhancers...)
+	pipeline.SetAttributeSchema(schema, config.GetConfig().GetBool("analyzer.flow_schema.strict"))
+	pipeline.SetCorrelationWarning(config.GetConfig().GetFloat64("analyzer.flow_correlation.warning_ratio"),
+		config.GetConfig().GetInt("analyzer.flow_correlation.warning_batches"))
+	pipeline.SetProvenance(config.GetConfig().GetInt("analyzer.flow_explain.cache_size"))
+	pipeline.SetKeyCatalog(mappings.NewFlowKeyCatalogFromConfig())
+
+	// the streams of the flows require the credentials of the flow API
+	flowCredentials := shttp.AuthenticationOptsFromConfig("analyzer")
+
+	// stream of the enhanced flows with the changes done by each enhancer
+	var debugServer *mappings.FlowDebugServer
+	if config.GetConfig().GetBool("analyzer.debug.flow_stream") {
+		debugServer = mappings.NewFlowDebugServer(shttp.NewWSServerFromConfig(httpServer, "/ws/debug/flows"))
+		debugServer.WSServer.Credentials = flowCredentials
+		pipeline.SetDebugListener(debugServer)
+		logging.GetLogger().Warning("Flow debug stream enabled on /ws/debug/flows")
+	}
+
+	var liveServer *live.LiveFlowServer
+	if config.GetConfig().GetBool("analyzer.live_flows.enabled") {
+		liveServer = live.NewLiveFlowServerFromConfig(shttp.NewWSServerFromConfig(httpServer, "/ws/flows"))
+		liveServer.WSServer.Credentials = flowCredentials
+	}
 
 	flowtable := flow.NewTable()
+	flowtable.SetExpireBatchSize(config.GetConfig().GetInt("analyzer.flowtable_expire_batch"))
+	flowtable.SetSkewTolerance(time.Duration(config.GetConfig().GetInt("analyzer.flowtable_skew_tolerance")) * time.Second)
+	alertManager.SetFlowTable(flowtable)
+	metrics.MustRegister(flowtable, wsServer)
 
 	server := &Server{
 		HTTPServer:          httpServer,
@@ -255,21 +922,145 @@ func NewServerFromConfig() (*Server, error) {
 		GraphServer:         gserver,
 		AlertServer:         aserver,
 		FlowMappingPipeline: pipeline,
+		FlowDebugServer:     debugServer,
+		LiveFlowServer:      liveServer,
 		FlowTable:           flowtable,
+		FlowEvents:          flow.NewFlowEventDispatcher(),
+		flowsLogSampler:     logging.NewSamplerFromConfig("analyzer_flows"),
+		datagramLogSampler:  logging.NewSamplerFromConfig("analyzer_datagrams"),
+		truncatedLogSampler: logging.NewSamplerFromConfig("analyzer_truncated_datagrams"),
+		droppedLogSampler:   logging.NewSamplerFromConfig("analyzer_dropped_datagrams"),
 		EmbeddedEtcd:        etcdServer,
 		EtcdClient:          etcdClient,
 	}
 	server.SetStorageFromConfig()
+	if err = server.SetFlowExportFromConfig(); err != nil {
+		return nil, err
+	}
+	server.SetFlowEventsFromConfig()
+	if server.FlowEdges, err = flow.NewEdgeProjectorFromConfig(g); err != nil {
+		return nil, err
+	}
+	if server.Significance = storage.NewSignificanceFilterFromConfig(); server.Significance != nil {
+		server.captureSignificance = newCaptureSignificance(g, captureHandler)
+		server.Significance.Captures = server.captureSignificance.byProbeNode
+		server.Significance.Retain = alertManager.MatchFlow
+	}
 
-	api.RegisterFlowApi("analyzer", flowtable, server.Storage, httpServer)
+	switch transport := config.GetAnalyzerFlowTransport(); transport {
+	case "udp", "tcp":
+		server.flowTransports = map[string]bool{transport: true}
+	case "both":
+		server.flowTransports = map[string]bool{"udp": true, "tcp": true}
+	default:
+		return nil, fmt.Errorf("Unknown flow transport %s", transport)
+	}
+	if server.FlowTCPServer, err = NewFlowTCPServerFromConfig(httpServer.Addr, server.AnalyzeFlows); err != nil {
+		return nil, err
+	}
+	if server.Datagrams, err = ingestion.NewDatagramReaderFromConfig(); err != nil {
+		return nil, err
+	}
+	server.NetFlow = ingestion.NewNetFlowListenerFromConfig(server.AnalyzeFlows)
+	server.SFlow = ingestion.NewSFlowListenerFromConfig(server.AnalyzeFlows)
+	if server.FairQueue, err = ingestion.NewFairQueueFromConfig(server.analyzeFlowData); err != nil {
+		return nil, err
+	}
+	if server.FairQueue != nil {
+		metrics.SetUDPQueue(server.FairQueue)
+	} else {
+		server.Workers = ingestion.NewWorkerPoolFromConfig(server.analyzeFlowData)
+		metrics.SetUDPQueue(server.Workers)
+	}
+	if server.Sequencer, err = ingestion.NewSequencerFromConfig(); err != nil {
+		return nil, err
+	}
+	server.FlowTCPServer.Sequencer = server.Sequencer
+
+	flowApi := api.RegisterFlowApi("analyzer", flowtable, server.Storage, httpServer)
+	flowApi.Pipeline = pipeline
+	server.FlowAggregates = flowApi.Aggregates
+	if server.FlowRollups = api.NewFlowRollupsFromConfig(server.Storage); server.FlowRollups != nil {
+		api.RegisterFlowRollupApi(server.FlowRollups, httpServer)
+	}
+	if server.FlowBackfill = api.NewFlowBackfillFromConfig(g, server.Storage); server.FlowBackfill != nil {
+		api.RegisterFlowBackfillApi(server.FlowBackfill, httpServer)
+	}
+	api.RegisterQueryApi("analyzer", g, flowtable, server.Storage, httpServer)
+	api.RegisterFlowTraceApi(pipeline, flowtable, httpServer)
+	api.RegisterFlowTableApi(flowtable, httpServer)
+	alertContextApi.FlowTable = flowtable
+	topologyApi.FlowTable = flowtable
+	topologyApi.Storage = server.Storage
+	alertContextApi.Storage = server.Storage
+
+	if server.ReportScheduler, err = api.RegisterReportApi("analyzer", apiServer, g, flowtable, server.Storage); err != nil {
+		return nil, err
+	}
+	alertManager.AddEventListener(&reportAlertListener{scheduler: server.ReportScheduler})
+
+	if server.ProbeScheduler, err = synthetic.RegisterProbeJobApi(apiServer, g, wsServer, alertManager); err != nil {
+		return nil, err
+	}
+
+	if _, err = api.RegisterBundleApi(apiServer); err != nil {
+		return nil, err
+	}
+
+	wsServers := []*shttp.WSServer{wsServer}
+	if debugServer != nil {
+		wsServers = append(wsServers, debugServer.WSServer)
+	}
+	if liveServer != nil {
+		wsServers = append(wsServers, liveServer.WSServer)
+	}
+	statusApi := api.RegisterStatusApi("analyzer", httpServer, wsServers...)
+	statusApi.FlowMappingPipeline = pipeline
+	statusApi.FairQueue = server.FairQueue
+	statusApi.Workers = server.Workers
+	statusApi.Sequencer = server.Sequencer
+	statusApi.Datagrams = server.Datagrams
+	statusApi.NetFlow = server.NetFlow
+	statusApi.SFlow = server.SFlow
+	statusApi.KafkaSink = server.KafkaSink
+	statusApi.GraphServer = wsServer
+	statusApi.FlowTable = flowtable
+	statusApi.Storage = server.Storage
+	statusApi.EtcdKeyAPI = etcdClient.KeysApi
+	statusApi.EmbeddedEtcd = etcdServer != nil
+
+	server.Handover = handover.NewFromConfig(server)
+	statusApi.Handover = server.Handover
+
+	switch mode := config.GetConfig().GetString("analyzer.listen_mode"); mode {
+	case "separate":
+	case "multiplexed":
+		if server.Handover != nil {
+			return nil, errors.New("The handover requires the separate listen mode")
+		}
+		peekTimeout := time.Duration(config.GetConfig().GetInt("analyzer.mux.peek_timeout")) * time.Millisecond
+		server.Mux = shttp.NewMux(nil, peekTimeout, 64)
+		statusApi.Mux = server.Mux
+	default:
+		return nil, fmt.Errorf("Unknown listen mode %s", mode)
+	}
+	statusApi.WAL = server.WAL
+
+	api.RegisterSupportApi("analyzer", statusApi, flowtable, g, httpServer)
 
 	analyzerExpire := config.GetAnalyerExpire()
 	agentExpire := config.GetAgentExpire()
-	flowtable.RegisterExpire(server.flowExpireUpdate, analyzerExpire, agentExpire)
+	flowtable.RegisterExpire(server.flowExpire, analyzerExpire, agentExpire)
+	logging.GetLogger().Infof("Flow table expiring every %s the flows inactive for %s", analyzerExpire, agentExpire)
 
 	analyzerUpdate := config.GetAnalyerUpdate()
 	agentUpdate := config.GetAgentUpdate()
 	flowtable.RegisterUpdated(server.flowExpireUpdate, analyzerUpdate, agentUpdate)
 
+	if config.GetConfig().GetBool("analyzer.bootstrap.enabled") {
+		server.Bootstrap = NewBootstrapFromConfig(server)
+		logging.GetLogger().Warning("Bootstrap mode, checklist served on /api/bootstrap/checklist")
+	}
+
 	return server, nil
 }
@@ -0,0 +1,76 @@
+/*
+ * Copyright (C) 2015 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package analyzer
+
+import (
+	"strings"
+	"testing"
+
+	gologging "github.com/op/go-logging"
+
+	"github.com/redhat-cip/skydive/flow"
+	"github.com/redhat-cip/skydive/flow/mappings"
+	"github.com/redhat-cip/skydive/logging"
+	"github.com/redhat-cip/skydive/topology/alert"
+	"github.com/redhat-cip/skydive/topology/graph"
+)
+
+// captureDebugLogs records the messages logged at the debug level by the
+// loggers of the package
+func captureDebugLogs() *gologging.MemoryBackend {
+	backend := gologging.NewMemoryBackend(1000)
+	leveled := gologging.AddModuleLevel(backend)
+	leveled.SetLevel(gologging.DEBUG, "")
+	logging.GetLogger().SetBackend(leveled)
+	return backend
+}
+
+func TestAnalyzeFlowsLogSampling(t *testing.T) {
+	b, _ := graph.NewMemoryBackend()
+	g, _ := graph.NewGraph(b)
+
+	s := &Server{
+		FlowTable:           flow.NewTable(),
+		FlowMappingPipeline: mappings.NewFlowMappingPipeline(),
+		AlertServer:         &alert.AlertServer{AlertManager: alert.NewAlertManager(g, nil)},
+		flowsLogSampler:     logging.NewSampler(10, 0),
+	}
+
+	backend := captureDebugLogs()
+	defer logging.InitLogger()
+
+	for i := 0; i != 100; i++ {
+		s.AnalyzeFlows([]*flow.Flow{{UUID: "flow"}})
+	}
+
+	logged := 0
+	for node := backend.Head(); node != nil; node = node.Next() {
+		if strings.Contains(node.Record.Message(), "flows received") {
+			logged++
+		}
+	}
+
+	if logged != 10 {
+		t.Errorf("Expected 1 in 10 batches to be logged, got %d", logged)
+	}
+}
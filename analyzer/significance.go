@@ -0,0 +1,123 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package analyzer
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/redhat-cip/skydive/api"
+	"github.com/redhat-cip/skydive/storage"
+	"github.com/redhat-cip/skydive/topology"
+	"github.com/redhat-cip/skydive/topology/graph"
+)
+
+// captureSignificance keeps the significance thresholds of the captures
+// setting some, resolved to their probe nodes on demand as the interfaces
+// come and go
+type captureSignificance struct {
+	sync.RWMutex
+	graph      *graph.Graph
+	handler    api.ApiHandler
+	watcher    api.StoppableWatcher
+	thresholds map[string]storage.FlowSignificance
+}
+
+func (c *captureSignificance) onApiWatcherEvent(action string, id string, resource api.ApiResource) {
+	c.Lock()
+	defer c.Unlock()
+
+	switch action {
+	case "init", "create", "set", "update":
+		if capture := resource.(*api.Capture); capture.Significance != nil {
+			c.thresholds[capture.ProbePath] = *capture.Significance
+		} else {
+			delete(c.thresholds, capture.ProbePath)
+		}
+	case "expire", "delete":
+		delete(c.thresholds, id)
+	}
+}
+
+// probeNodes returns the nodes of a probe path, a leading * matching all
+// the hosts
+func (c *captureSignificance) probeNodes(probePath string) []*graph.Node {
+	if !strings.HasPrefix(probePath, "*") {
+		if n := topology.LookupNodeFromNodePathString(c.graph, probePath); n != nil {
+			return []*graph.Node{n}
+		}
+		return nil
+	}
+
+	var nodes []*graph.Node
+	for _, host := range c.graph.LookupNodes(graph.Metadata{"Type": "host"}) {
+		name, _ := host.Metadata()["Name"].(string)
+		if n := topology.LookupNodeFromNodePathString(c.graph, strings.Replace(probePath, "*", name+"[Type=host]", 1)); n != nil {
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes
+}
+
+// byProbeNode returns the thresholds of the captures by probe node
+func (c *captureSignificance) byProbeNode() map[string]storage.FlowSignificance {
+	c.RLock()
+	thresholds := make(map[string]storage.FlowSignificance, len(c.thresholds))
+	for probePath, t := range c.thresholds {
+		thresholds[probePath] = t
+	}
+	c.RUnlock()
+
+	if len(thresholds) == 0 {
+		return nil
+	}
+
+	c.graph.RLock()
+	defer c.graph.RUnlock()
+
+	nodes := make(map[string]storage.FlowSignificance)
+	for probePath, t := range thresholds {
+		for _, n := range c.probeNodes(probePath) {
+			nodes[string(n.ID)] = t
+		}
+	}
+	return nodes
+}
+
+func (c *captureSignificance) Start() {
+	c.watcher = c.handler.AsyncWatch(c.onApiWatcherEvent)
+}
+
+func (c *captureSignificance) Stop() {
+	if c.watcher != nil {
+		c.watcher.Stop()
+	}
+}
+
+func newCaptureSignificance(g *graph.Graph, handler api.ApiHandler) *captureSignificance {
+	return &captureSignificance{
+		graph:      g,
+		handler:    handler,
+		thresholds: make(map[string]storage.FlowSignificance),
+	}
+}
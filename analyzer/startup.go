@@ -0,0 +1,128 @@
+/*
+ * Copyright (C) 2015 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package analyzer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// subsystem is a part of the analyzer started along with the others. start
+// has to return once the subsystem is ready to serve, the serving itself
+// being done in background. stop, if any, is used to shut the subsystem
+// down when another one failed to start.
+type subsystem struct {
+	name  string
+	start func() error
+	stop  func()
+}
+
+// StartupError holds the errors of the subsystems that failed to start
+type StartupError struct {
+	Errors map[string]error
+}
+
+func (e *StartupError) Error() string {
+	var names []string
+	for name := range e.Errors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var msgs []string
+	for _, name := range names {
+		msgs = append(msgs, fmt.Sprintf("%s: %s", name, e.Errors[name].Error()))
+	}
+	return "Failed to start " + strings.Join(msgs, ", ")
+}
+
+type subsystemResult struct {
+	subsystem subsystem
+	err       error
+}
+
+func (s subsystem) shutdown() {
+	if s.stop != nil {
+		s.stop()
+	}
+}
+
+// startSubsystems starts the subsystems concurrently, a subsystem not ready
+// after the timeout is considered as failed. If one of them fails, the
+// started ones are stopped, the late ones being stopped once started.
+func startSubsystems(subsystems []subsystem, timeout time.Duration) error {
+	results := make(chan subsystemResult, len(subsystems))
+	for _, sub := range subsystems {
+		go func(sub subsystem) {
+			results <- subsystemResult{subsystem: sub, err: sub.start()}
+		}(sub)
+	}
+
+	errs := make(map[string]error)
+	done := make(map[string]bool)
+	var started []subsystem
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	remaining := len(subsystems)
+	for timedOut := false; remaining > 0 && !timedOut; {
+		select {
+		case result := <-results:
+			remaining--
+			done[result.subsystem.name] = true
+			if result.err != nil {
+				errs[result.subsystem.name] = result.err
+			} else {
+				started = append(started, result.subsystem)
+			}
+		case <-timer.C:
+			for _, sub := range subsystems {
+				if !done[sub.name] {
+					errs[sub.name] = fmt.Errorf("not started after %s", timeout)
+				}
+			}
+			timedOut = true
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	for i := len(started) - 1; i >= 0; i-- {
+		started[i].shutdown()
+	}
+
+	go func(remaining int) {
+		for ; remaining > 0; remaining-- {
+			if result := <-results; result.err == nil {
+				result.subsystem.shutdown()
+			}
+		}
+	}(remaining)
+
+	return &StartupError{Errors: errs}
+}
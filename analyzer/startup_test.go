@@ -0,0 +1,295 @@
+/*
+ * Copyright (C) 2015 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package analyzer
+
+import (
+	"errors"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redhat-cip/skydive/flow"
+	"github.com/redhat-cip/skydive/flow/ingestion"
+	shttp "github.com/redhat-cip/skydive/http"
+)
+
+func TestStartSubsystems(t *testing.T) {
+	subsystems := []subsystem{
+		{"http", func() error { return nil }, nil},
+		{"storage", func() error { return nil }, nil},
+	}
+
+	if err := startSubsystems(subsystems, time.Second); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+}
+
+func TestStartSubsystemsFailure(t *testing.T) {
+	// keep the port busy so that the udp subsystem fails to bind it
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer conn.Close()
+
+	httpStopped := false
+	subsystems := []subsystem{
+		{"http", func() error { return nil }, func() { httpStopped = true }},
+		{"udp", func() error {
+			c, err := net.ListenUDP("udp", conn.LocalAddr().(*net.UDPAddr))
+			if err == nil {
+				c.Close()
+			}
+			return err
+		}, nil},
+		{"storage", func() error { return errors.New("connection refused") }, nil},
+	}
+
+	err = startSubsystems(subsystems, time.Second)
+	se, ok := err.(*StartupError)
+	if !ok {
+		t.Fatalf("Expected a StartupError, got: %v", err)
+	}
+
+	if len(se.Errors) != 2 || se.Errors["udp"] == nil || se.Errors["storage"] == nil {
+		t.Errorf("Wrong failed subsystems: %v", se.Errors)
+	}
+
+	if !strings.Contains(err.Error(), "udp: ") || !strings.Contains(err.Error(), "storage: connection refused") {
+		t.Errorf("Subsystems not named in the error: %s", err.Error())
+	}
+
+	if !httpStopped {
+		t.Error("Started subsystems should be stopped on failure")
+	}
+}
+
+func TestUDPServerBindFailure(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer conn.Close()
+
+	addr := conn.LocalAddr().(*net.UDPAddr)
+	s := &Server{HTTPServer: &shttp.Server{Addr: addr.IP.String(), Port: addr.Port}}
+
+	if err := s.startUDPServer(); err == nil {
+		t.Fatal("Binding an already used port should fail")
+	}
+}
+
+func TestUDPServerStop(t *testing.T) {
+	s, _ := newHandoverServer(t, "")
+	if err := s.startUDPServer(); err != nil {
+		t.Fatal(err.Error())
+	}
+	// the reader blocked in a read
+	time.Sleep(50 * time.Millisecond)
+
+	start := time.Now()
+	s.stopUDPServer()
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("Expected the read in progress to be interrupted, stopped after %s", elapsed)
+	}
+
+	// the reader closed the socket when exiting
+	if _, _, err := s.conn.ReadFromUDP(make([]byte, 1)); err == nil {
+		t.Error("Expected the socket to be closed")
+	}
+
+	// stopping again doesn't block
+	s.stopUDPServer()
+}
+
+func TestServerStopBeforeStart(t *testing.T) {
+	s, _ := newHandoverServer(t, "")
+	s.Stop()
+	s.Stop()
+
+	if err := s.startUDPServer(); err != ErrServerStopped {
+		t.Errorf("Expected a stopped analyzer not to bind its flow socket, got %v", err)
+	}
+}
+
+func TestServerStartStop(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		s, _ := newHandoverServer(t, "")
+
+		started := make(chan error, 1)
+		go func() {
+			started <- s.startUDPServer()
+		}()
+		s.Stop()
+
+		switch err := <-started; err {
+		case nil:
+			// bound before the stop, the socket was closed by the stop
+			if _, _, err := s.conn.ReadFromUDP(make([]byte, 1)); err == nil {
+				t.Fatal("Expected the socket to be closed")
+			}
+		case ErrServerStopped:
+		default:
+			t.Fatal(err.Error())
+		}
+
+		s.Stop()
+	}
+}
+
+func TestAnalyzeMalformedData(t *testing.T) {
+	s, _ := newHandoverServer(t, "")
+
+	// the garbage either fails to parse or decodes as odd flows, never
+	// panicking
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		data := make([]byte, r.Intn(512))
+		r.Read(data)
+		s.analyzeFlowData("127.0.0.1", data)
+	}
+	s.analyzeFlowData("127.0.0.1", []byte{0x00, 0x01, 0x00, 0x02, 0x00, 0x03, 0xff, 0xff, 0xff})
+
+	if stats := s.Datagrams.Stats(); stats.Malformed == 0 {
+		t.Errorf("Expected the malformed flows to be counted, got %+v", stats)
+	}
+}
+
+func TestUDPServerLargeDatagram(t *testing.T) {
+	s, _ := newHandoverServer(t, "")
+	if err := s.startUDPServer(); err != nil {
+		t.Fatal(err.Error())
+	}
+	defer func() {
+		s.stopUDPServer()
+	}()
+
+	c, err := NewClient("127.0.0.1", s.conn.LocalAddr().(*net.UDPAddr).Port)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer c.Close()
+
+	// a flow larger than a page isn't truncated
+	large := strings.Repeat("x", 8192)
+	if err := c.SendFlow(&flow.Flow{UUID: "large", Attributes: map[string]string{"Large": large}}); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for i := 0; i < 100; i++ {
+		if f := s.FlowTable.GetFlow("large"); f != nil {
+			if f.Attributes["Large"] != large {
+				t.Errorf("Expected the flow to be received whole")
+			}
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Error("Expected the large flow to be received")
+}
+
+func TestUDPServerTruncatedDatagram(t *testing.T) {
+	s, _ := newHandoverServer(t, "")
+	s.Datagrams, _ = ingestion.NewDatagramReader(1024)
+	if err := s.startUDPServer(); err != nil {
+		t.Fatal(err.Error())
+	}
+	defer func() {
+		s.stopUDPServer()
+	}()
+
+	c, err := NewClient("127.0.0.1", s.conn.LocalAddr().(*net.UDPAddr).Port)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer c.Close()
+
+	// the datagram larger than the buffer is dropped, not parsed
+	c.SendFlow(&flow.Flow{UUID: "large", Attributes: map[string]string{"Large": strings.Repeat("x", 2048)}})
+	c.SendFlow(&flow.Flow{UUID: "small"})
+
+	for i := 0; i < 100 && s.FlowTable.GetFlow("small") == nil; i++ {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if s.FlowTable.GetFlow("small") == nil || s.FlowTable.GetFlow("large") != nil {
+		t.Fatal("Expected only the small flow to be received")
+	}
+
+	stats := s.Datagrams.Stats()
+	if stats.Received != 2 || stats.Truncated != 1 || stats.Agents["127.0.0.1"] != 1 {
+		t.Errorf("Expected the large datagram to be counted as truncated, got %+v", stats)
+	}
+}
+
+func TestUDPServerBatch(t *testing.T) {
+	s, _ := newHandoverServer(t, "")
+	if err := s.startUDPServer(); err != nil {
+		t.Fatal(err.Error())
+	}
+	defer func() {
+		s.stopUDPServer()
+	}()
+
+	c, err := NewClient("127.0.0.1", s.conn.LocalAddr().(*net.UDPAddr).Port)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer c.Close()
+	c.BatchSize = 1400
+
+	c.SendFlows(testFlows(50))
+
+	for i := 0; i < 100 && s.FlowTable.GetFlow("49") == nil; i++ {
+		time.Sleep(20 * time.Millisecond)
+	}
+	for i := 0; i < 50; i++ {
+		if s.FlowTable.GetFlow(strconv.Itoa(i)) == nil {
+			t.Errorf("Expected the flow %d to be received", i)
+		}
+	}
+	if stats := s.Datagrams.Stats(); stats.Received != 1 {
+		t.Errorf("Expected the flows in a single datagram, got %+v", stats)
+	}
+}
+
+func TestStartSubsystemsTimeout(t *testing.T) {
+	block := make(chan bool)
+	defer close(block)
+
+	subsystems := []subsystem{
+		{"http", func() error { return nil }, nil},
+		{"alert manager", func() error {
+			<-block
+			return nil
+		}, nil},
+	}
+
+	err := startSubsystems(subsystems, 100*time.Millisecond)
+	se, ok := err.(*StartupError)
+	if !ok || len(se.Errors) != 1 || se.Errors["alert manager"] == nil {
+		t.Fatalf("Expected a timeout of the alert manager, got: %v", err)
+	}
+}
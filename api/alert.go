@@ -23,26 +23,37 @@
 package api
 
 import (
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/nu7hatch/gouuid"
+
+	"github.com/redhat-cip/skydive/config"
+	"github.com/redhat-cip/skydive/flow"
 )
 
 const (
 	FIXED = 1 + iota
 	THRESHOLD
+	// fires when no flow matched the filter during the window
+	ABSENCE
 )
 
 type Alert struct {
 	UUID        string
 	Name        string `valid:"nonzero"`
 	Description string `valid:"nonzero"`
-	Select      string `valid:"nonzero"`
-	Test        string `valid:"nonzero"`
+	Select      string
+	Test        string
 	Action      string `valid:"nonzero"`
 	Type        int
 	Count       int
 	CreateTime  time.Time
+	// absence alerts only, Window in second
+	FlowFilter string `json:",omitempty"`
+	Window     int    `json:",omitempty"`
+	Bundle     string `json:",omitempty"`
 }
 
 type AlertHandler struct {
@@ -58,6 +69,29 @@ func NewAlert() *Alert {
 	}
 }
 
+// Validate checks the fields depending on the alert type
+func (a *Alert) Validate() error {
+	switch a.Type {
+	case ABSENCE:
+		if _, err := flow.ParseFilter(a.FlowFilter); err != nil {
+			return fmt.Errorf("Invalid flow filter: %s", err.Error())
+		}
+
+		interval := config.GetConfig().GetInt("analyzer.alert_absence_interval")
+		if a.Window < interval {
+			return fmt.Errorf("Window of %ds shorter than the evaluation interval of %ds", a.Window, interval)
+		}
+	default:
+		if a.Select == "" || a.Test == "" {
+			return errors.New("Select and Test are mandatory")
+		}
+		if _, err := ParseExpression(a.Test, NewExpressionLimitsFromConfig()); err != nil {
+			return fmt.Errorf("Invalid test: %s", err.Error())
+		}
+	}
+	return nil
+}
+
 func (a *AlertHandler) New() ApiResource {
 	return &Alert{}
 }
@@ -69,3 +103,11 @@ func (a *AlertHandler) Name() string {
 func (a *Alert) ID() string {
 	return a.UUID
 }
+
+func (a *Alert) BundleID() string {
+	return a.Bundle
+}
+
+func (a *Alert) SetBundleID(id string) {
+	a.Bundle = id
+}
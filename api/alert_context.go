@@ -0,0 +1,339 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/abbot/go-http-auth"
+	"github.com/gorilla/mux"
+
+	"github.com/redhat-cip/skydive/config"
+	"github.com/redhat-cip/skydive/flow"
+	shttp "github.com/redhat-cip/skydive/http"
+	"github.com/redhat-cip/skydive/logging"
+	"github.com/redhat-cip/skydive/storage"
+	"github.com/redhat-cip/skydive/topology/graph"
+)
+
+// AlertFlowRef references a flow that triggered an alert
+type AlertFlowRef struct {
+	UUID       string
+	TrackingID string `json:",omitempty"`
+}
+
+// AlertFlowQuery is the flow search of the evaluation window of a fire, the
+// bounds being given in seconds since epoch. It is run again to list the
+// flows beyond the ones recorded.
+type AlertFlowQuery struct {
+	FlowFilter string `json:",omitempty"`
+	From       int64
+	To         int64
+}
+
+// AlertFire records what triggered an alert: the nodes and the values of
+// the identifiers of the test that satisfied it, the flows of the
+// evaluation window, up to a limit, MoreFlows giving the number of the
+// others.
+type AlertFire struct {
+	Alert     string
+	N         int
+	Timestamp time.Time
+	Test      string                 `json:",omitempty"`
+	Values    map[string]interface{} `json:",omitempty"`
+	Nodes     []string               `json:",omitempty"`
+	Flows     []AlertFlowRef         `json:",omitempty"`
+	MoreFlows int                    `json:",omitempty"`
+	Query     AlertFlowQuery
+}
+
+// AlertFireStore gives the fires recorded by the alert manager, nil if the
+// fire is unknown or was forgotten
+type AlertFireStore interface {
+	GetAlertFire(alert string, n int) *AlertFire
+}
+
+// AlertFireID returns the identifier of the nth fire of an alert
+func AlertFireID(alert string, n int) string {
+	return fmt.Sprintf("%s/%d", alert, n)
+}
+
+// ParseAlertFireID returns the alert and the number of a fire identifier
+func ParseAlertFireID(id string) (string, int, error) {
+	i := strings.LastIndex(id, "/")
+	if i == -1 {
+		return "", 0, fmt.Errorf("Malformed fire identifier, <alert>/<n> expected: %s", id)
+	}
+
+	n, err := strconv.Atoi(id[i+1:])
+	if err != nil || n < 1 {
+		return "", 0, fmt.Errorf("Invalid fire number: %s", id[i+1:])
+	}
+	return id[:i], n, nil
+}
+
+// AlertFlowPage is a page of the flows of the evaluation window of a fire,
+// Next giving the path of the following one
+type AlertFlowPage struct {
+	Flows     []*flow.Flow
+	MoreFlows int
+	Next      string `json:",omitempty"`
+}
+
+// AlertContext is what triggered a fire: the test rendered with the values
+// that satisfied it, the nodes in their current state, the graph not
+// keeping their history, and the first page of the flows of the window
+type AlertContext struct {
+	AlertFlowPage
+	Fire         *AlertFire
+	Expression   string
+	Nodes        []*graph.Node
+	MissingNodes []string `json:",omitempty"`
+}
+
+type AlertContextApi struct {
+	Fires     AlertFireStore
+	Graph     *graph.Graph
+	FlowTable *flow.Table
+	Storage   storage.Storage
+	MaxFlows  int
+	// credentials required by the routes, the fires giving their flows,
+	// none when not set
+	Credentials *shttp.AuthenticationOpts
+}
+
+// renderFire returns the test of the fire with the identifiers replaced by
+// their values, or the condition of the absence alerts
+func renderFire(fire *AlertFire) string {
+	if fire.Test == "" {
+		from, to := time.Unix(fire.Query.From, 0).UTC(), time.Unix(fire.Query.To, 0).UTC()
+		return fmt.Sprintf("no flow matching %s from %s to %s", fire.Query.FlowFilter, from.Format(time.RFC3339), to.Format(time.RFC3339))
+	}
+	return RenderExpression(fire.Test, fire.Values)
+}
+
+// searchFlows runs the query of the fire on the storage, or on the flow
+// table without storage, the flows being sorted by UUID
+func (c *AlertContextApi) searchFlows(q AlertFlowQuery) ([]*flow.Flow, error) {
+	filter, err := flow.ParseFilter(q.FlowFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	var flows []*flow.Flow
+	switch {
+	case c.Storage == nil && c.FlowTable == nil:
+		return nil, nil
+	case c.Storage == nil:
+		flows = c.FlowTable.GetFlows()
+	default:
+		filters := storage.Filters(filter.FieldsOnly())
+		filters["Statistics.Last"] = storage.Range{Gte: q.From}
+		filters["Statistics.Start"] = storage.Range{Lte: q.To}
+		if flows, err = c.Storage.SearchFlows(filters); err != nil {
+			return nil, err
+		}
+	}
+
+	var matched []*flow.Flow
+	for _, f := range flows {
+		if matchFlowRange(f, q.From, q.To) && filter.Match(f) {
+			matched = append(matched, f)
+		}
+	}
+	sort.Sort(sortByUUID(matched))
+
+	return matched, nil
+}
+
+// FlowPage returns the flows of the window of the fire from offset
+func (c *AlertContextApi) FlowPage(fire *AlertFire, offset int) (*AlertFlowPage, error) {
+	flows, err := c.searchFlows(fire.Query)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &AlertFlowPage{Flows: []*flow.Flow{}}
+	if offset >= len(flows) {
+		return page, nil
+	}
+
+	flows = flows[offset:]
+	if c.MaxFlows > 0 && len(flows) > c.MaxFlows {
+		page.MoreFlows = len(flows) - c.MaxFlows
+		page.Next = fmt.Sprintf("/api/alert/%s/fires/%d/flows?offset=%d", fire.Alert, fire.N, offset+c.MaxFlows)
+		flows = flows[:c.MaxFlows]
+	}
+	page.Flows = flows
+
+	return page, nil
+}
+
+// Context returns the context of the nth fire of the alert, nil if unknown
+func (c *AlertContextApi) Context(alert string, n int) (*AlertContext, error) {
+	fire := c.Fires.GetAlertFire(alert, n)
+	if fire == nil {
+		return nil, nil
+	}
+
+	page, err := c.FlowPage(fire, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := &AlertContext{
+		AlertFlowPage: *page,
+		Fire:          fire,
+		Expression:    renderFire(fire),
+		Nodes:         []*graph.Node{},
+	}
+
+	if len(fire.Nodes) > 0 && c.Graph != nil {
+		c.Graph.RLock()
+		for _, id := range fire.Nodes {
+			if node := c.Graph.GetNode(graph.Identifier(id)); node != nil {
+				ctx.Nodes = append(ctx.Nodes, node)
+			} else {
+				ctx.MissingNodes = append(ctx.MissingNodes, id)
+			}
+		}
+		c.Graph.RUnlock()
+	}
+
+	return ctx, nil
+}
+
+// fire returns the fire of the route variables, replying with the error
+func (c *AlertContextApi) fire(w http.ResponseWriter, r *auth.AuthenticatedRequest) (string, int, bool) {
+	vars := mux.Vars(&r.Request)
+
+	n, err := strconv.Atoi(vars["n"])
+	if err != nil || n < 1 {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("Invalid fire number: " + vars["n"]))
+		return "", 0, false
+	}
+	return vars["id"], n, true
+}
+
+func (c *AlertContextApi) sendJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		logging.GetLogger().Criticalf("Failed to send alert context: %s", err.Error())
+	}
+}
+
+func (c *AlertContextApi) context(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
+	id, n, ok := c.fire(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, err := c.Context(id, n)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if ctx == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	c.sendJSON(w, ctx)
+}
+
+func (c *AlertContextApi) flows(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
+	id, n, ok := c.fire(w, r)
+	if !ok {
+		return
+	}
+
+	offset := 0
+	if value := r.URL.Query().Get("offset"); value != "" {
+		var err error
+		if offset, err = strconv.Atoi(value); err != nil || offset < 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("Invalid offset: " + value))
+			return
+		}
+	}
+
+	fire := c.Fires.GetAlertFire(id, n)
+	if fire == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	page, err := c.FlowPage(fire, offset)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	c.sendJSON(w, page)
+}
+
+func (c *AlertContextApi) registerEndpoints(r *shttp.Server) {
+	routes := []shttp.Route{
+		{
+			"AlertFireContext",
+			"GET",
+			"/api/alert/{id}/fires/{n}/context",
+			c.context,
+		},
+		{
+			"AlertFireFlows",
+			"GET",
+			"/api/alert/{id}/fires/{n}/flows",
+			c.flows,
+		},
+	}
+
+	r.RegisterRoutes(shttp.RequireRoutesCredentials(c.Credentials, routes))
+}
+
+// RegisterAlertContextApi registers the endpoints giving the context of the
+// fires of the alerts, the flow lists being cut at
+// analyzer.alert_context.max_flows. The flows are searched in the Storage,
+// or in the FlowTable without storage, both set afterwards.
+func RegisterAlertContextApi(fires AlertFireStore, g *graph.Graph, r *shttp.Server) *AlertContextApi {
+	c := &AlertContextApi{
+		Fires:       fires,
+		Graph:       g,
+		MaxFlows:    config.GetConfig().GetInt("analyzer.alert_context.max_flows"),
+		Credentials: shttp.AuthenticationOptsFromConfig("analyzer"),
+	}
+
+	c.registerEndpoints(r)
+
+	return c
+}
@@ -0,0 +1,156 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package api
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/redhat-cip/skydive/config"
+)
+
+func TestAlertValidate(t *testing.T) {
+	config.GetConfig().Set("analyzer.alert_absence_interval", 10)
+
+	alert := NewAlert()
+	if err := alert.Validate(); err == nil {
+		t.Error("Select and Test should be mandatory for fixed alerts")
+	}
+
+	alert.Type = ABSENCE
+	alert.FlowFilter = "IPV4.A=192.168.0.1"
+	alert.Window = 5
+	if err := alert.Validate(); err == nil {
+		t.Error("Window shorter than the evaluation interval should be rejected")
+	}
+
+	alert.Window = 3600
+	if err := alert.Validate(); err != nil {
+		t.Errorf("Unexpected error: %s", err.Error())
+	}
+
+	alert.FlowFilter = "Attributes.Tag.segment=pci"
+	if err := alert.Validate(); err != nil {
+		t.Errorf("Tags should be accepted by the flow filters: %s", err.Error())
+	}
+
+	alert.FlowFilter = "Unknown=1"
+	if err := alert.Validate(); err == nil {
+		t.Error("Invalid flow filter should be rejected")
+	}
+}
+
+// balancedSum returns a sum of 2^depth operands nested depth levels deep
+func balancedSum(depth int) string {
+	if depth == 0 {
+		return "M"
+	}
+	return "(" + balancedSum(depth-1) + "+" + balancedSum(depth-1) + ")"
+}
+
+func TestAlertValidateTest(t *testing.T) {
+	alert := NewAlert()
+	alert.Select = "Name"
+
+	for _, test := range []string{
+		`State == "UP" && MTU > 1400`,
+		`len(Name) > 3 && Name[0:3] == "eth"`,
+		`float64(MTU) / 2 > 700.5`,
+	} {
+		alert.Test = test
+		if err := alert.Validate(); err != nil {
+			t.Errorf("%s should be accepted: %s", test, err.Error())
+		}
+	}
+
+	hostiles := []struct {
+		test string
+		err  string
+	}{
+		{`true) == true; for {}; (true`, "column 5: expected 'EOF', found ')'"},
+		{`func() bool { for {} }()`, "column 1: only calls to named functions are allowed"},
+		{`MTU > 0 && func() bool { return true }()`, "column 12: only calls to named functions are allowed"},
+		{`len(make([]byte, 1 << 40)) > 0`, "column 5: call to make not allowed, permitted functions: float64, int, int64, len, string, uint64"},
+		{`<-ch`, "column 1: channel receives are not allowed"},
+		{`1 << 100000000 > 0`, "column 6: shift count 100000000 larger than 63"},
+		{`123456789012345678901234567890123456789 > MTU`, "column 1: numeric literal longer than 32 digits"},
+		{`[]string{Name}[0] == "eth0"`, "column 1: composite literals are not allowed"},
+		{strings.Repeat("(", 40) + "true" + strings.Repeat(")", 40), "column 33: nested more than 32 levels deep"},
+		{balancedSum(7) + " > 0", "more than 200 operands and operators"},
+		{strings.Repeat("a", 1025), "column 1025: expression longer than 1024 characters"},
+	}
+
+	for _, hostile := range hostiles {
+		alert.Test = hostile.test
+		err := alert.Validate()
+		if err == nil {
+			t.Errorf("%s should be rejected", hostile.test)
+			continue
+		}
+		if !strings.Contains(err.Error(), hostile.err) {
+			t.Errorf("%s rejected with %q, expected %q", hostile.test, err.Error(), hostile.err)
+		}
+	}
+
+	config.GetConfig().Set("analyzer.alert_sandbox.functions", []string{"len", "make"})
+	defer config.GetConfig().Set("analyzer.alert_sandbox.functions", []string{"len", "int", "int64", "uint64", "float64", "string"})
+
+	alert.Test = `len(make([]byte, 4)) == 4`
+	if err := alert.Validate(); err == nil || !strings.Contains(err.Error(), "ArrayType not allowed") {
+		t.Errorf("array types should be rejected, got %v", err)
+	}
+
+	alert.Test = `float64(MTU) > 1.5`
+	if err := alert.Validate(); err == nil || !strings.Contains(err.Error(), "call to float64 not allowed, permitted functions: len, make") {
+		t.Errorf("float64 should not be permitted anymore, got %v", err)
+	}
+}
+
+func TestRenderExpression(t *testing.T) {
+	test := `len(Name) > 3 && Name[0:3] == "eth" && float64(MTU) / 2 > 700.5`
+
+	e, err := ParseExpression(test, NewExpressionLimitsFromConfig())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if idents := ExpressionIdents(e); len(idents) != 2 || idents[0] != "Name" || idents[1] != "MTU" {
+		t.Errorf("Expected the identifiers without the functions, got %v", idents)
+	}
+
+	rendered := RenderExpression(test, map[string]interface{}{"Name": "eth0", "MTU": 1500})
+	if rendered != `len("eth0") > 3 && "eth0"[0:3] == "eth" && float64(1500) / 2 > 700.5` {
+		t.Errorf("Wrong rendered expression: %s", rendered)
+	}
+}
+
+func TestParseAlertFireID(t *testing.T) {
+	if id, n, err := ParseAlertFireID(AlertFireID("alert", 12)); err != nil || id != "alert" || n != 12 {
+		t.Errorf("Wrong fire identifier: %s, %d, %v", id, n, err)
+	}
+
+	for _, id := range []string{"alert", "alert/0", "alert/x"} {
+		if _, _, err := ParseAlertFireID(id); err == nil {
+			t.Errorf("%s should be rejected", id)
+		}
+	}
+}
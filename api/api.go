@@ -35,13 +35,18 @@ import (
 
 	shttp "github.com/redhat-cip/skydive/http"
 	"github.com/redhat-cip/skydive/logging"
+	"github.com/redhat-cip/skydive/topology/graph"
 	"github.com/redhat-cip/skydive/version"
 )
 
 type ApiServer struct {
 	HTTPServer *shttp.Server
 	EtcdKeyAPI etcd.KeysAPI
-	handlers   map[string]ApiHandler
+	// graph whose elements of the GraphOrigin origin, written by the users,
+	// are backed up along with the resources
+	Graph       *graph.Graph
+	GraphOrigin string
+	handlers    map[string]ApiHandler
 }
 
 type HandlerFunc func(w http.ResponseWriter, r *http.Request)
@@ -67,6 +72,22 @@ func (a *ApiServer) AsyncWatch(n string, f ApiWatcherCallback) StoppableWatcher
 }
 
 func (a *ApiServer) RegisterApiHandler(handler ApiHandler) error {
+	return a.registerApiHandler(handler, false, nil)
+}
+
+// RegisterApiHandlerWithCredentials registers resources whose routes
+// require the credentials given, ex: the ones giving access to the flows
+func (a *ApiServer) RegisterApiHandlerWithCredentials(handler ApiHandler, opts *shttp.AuthenticationOpts) error {
+	return a.registerApiHandler(handler, false, opts)
+}
+
+// RegisterAdminApiHandler registers resources shown on the listen address
+// but created and deleted only on the administrative one
+func (a *ApiServer) RegisterAdminApiHandler(handler ApiHandler) error {
+	return a.registerApiHandler(handler, true, nil)
+}
+
+func (a *ApiServer) registerApiHandler(handler ApiHandler, admin bool, opts *shttp.AuthenticationOpts) error {
 	name := handler.Name()
 	title := strings.Title(name)
 
@@ -120,8 +141,17 @@ func (a *ApiServer) RegisterApiHandler(handler ApiHandler) error {
 					return
 				}
 
+				if v, ok := resource.(ApiResourceValidator); ok {
+					if err := v.Validate(); err != nil {
+						w.WriteHeader(http.StatusBadRequest)
+						w.Write([]byte(err.Error()))
+						return
+					}
+				}
+
 				if err := handler.Create(resource); err != nil {
 					w.WriteHeader(http.StatusBadRequest)
+					w.Write([]byte(err.Error()))
 					return
 				}
 
@@ -149,6 +179,15 @@ func (a *ApiServer) RegisterApiHandler(handler ApiHandler) error {
 					return
 				}
 
+				// the resources of a bundle are deleted along with it
+				if resource, ok := handler.Get(id); ok && r.URL.Query().Get("force") != "true" {
+					if b, ok := resource.(ApiResourceBundled); ok && b.BundleID() != "" {
+						w.WriteHeader(http.StatusConflict)
+						w.Write([]byte(fmt.Sprintf("%s %s belongs to the bundle %s, delete the bundle or force the deletion", name, id, b.BundleID())))
+						return
+					}
+				}
+
 				if err := handler.Delete(id); err != nil {
 					w.WriteHeader(http.StatusBadRequest)
 					return
@@ -160,7 +199,15 @@ func (a *ApiServer) RegisterApiHandler(handler ApiHandler) error {
 		},
 	}
 
-	a.HTTPServer.RegisterRoutes(routes)
+	routes = shttp.RequireRoutesCredentials(opts, routes)
+
+	if admin {
+		// the Index and Show routes stay public
+		a.HTTPServer.RegisterRoutes(routes[:2])
+		a.HTTPServer.RegisterAdminRoutes(routes[2:])
+	} else {
+		a.HTTPServer.RegisterRoutes(routes)
+	}
 
 	if _, err := a.EtcdKeyAPI.Set(context.Background(), "/"+name, "", &etcd.SetOptions{Dir: true}); err != nil {
 		if _, err = a.EtcdKeyAPI.Get(context.Background(), "/"+name, nil); err != nil {
@@ -0,0 +1,546 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package api
+
+import (
+	// aliased, bytes being the discovery of the flow bytes
+	stdbytes "bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"sort"
+
+	"github.com/abbot/go-http-auth"
+	"golang.org/x/crypto/openpgp"
+
+	"github.com/redhat-cip/skydive/config"
+	shttp "github.com/redhat-cip/skydive/http"
+	"github.com/redhat-cip/skydive/logging"
+	"github.com/redhat-cip/skydive/topology/graph"
+)
+
+const (
+	// BackupVersion has to be increased each time the format changes
+	BackupVersion = 2
+
+	// BackupGraphName is the name of the graph elements in the restore
+	// policies and reports
+	BackupGraphName = "graph"
+)
+
+const (
+	RestorePolicySkip      = "skip"
+	RestorePolicyOverwrite = "overwrite"
+	RestorePolicyFail      = "fail"
+)
+
+// BackupGraphElement is a node, or an edge from Parent to Child, of the
+// graph
+type BackupGraphElement struct {
+	ID       graph.Identifier
+	Metadata graph.Metadata
+	Parent   graph.Identifier `json:",omitempty"`
+	Child    graph.Identifier `json:",omitempty"`
+}
+
+// BackupGraph holds the elements of the graph written by the users with the
+// Gremlin write steps, the ones reported by the agents being not backed up
+type BackupGraph struct {
+	Nodes []*BackupGraphElement
+	Edges []*BackupGraphElement
+}
+
+// Backup holds the API resources, by type and id, and the graph elements
+// written by the users. Authentication material never leaves the analyzer.
+// Once sealed, the resources holding secrets, the credentials of a webhook
+// URL for instance, are redacted, their originals being encrypted with the
+// passphrase of the backup into Secrets.
+type Backup struct {
+	Version           int
+	ConfigFingerprint string
+	Objects           map[string]map[string]json.RawMessage
+	Graph             *BackupGraph `json:",omitempty"`
+	// OpenPGP message holding the originals of the redacted resources
+	Secrets []byte `json:",omitempty"`
+}
+
+// Seal redacts the secrets of the resources, the originals of the resources
+// holding some being encrypted with the passphrase, or left out without
+// passphrase. It returns the number of the resources redacted.
+func (b *Backup) Seal(passphrase string) (int, error) {
+	originals := make(map[string]map[string]json.RawMessage)
+	redacted := 0
+	for name, objects := range b.Objects {
+		for id, data := range objects {
+			var value interface{}
+			if err := json.Unmarshal([]byte(data), &value); err != nil {
+				return 0, fmt.Errorf("Unable to decode %s %s: %s", name, id, err.Error())
+			}
+
+			sanitized := config.Sanitize(value)
+			if reflect.DeepEqual(value, sanitized) {
+				continue
+			}
+
+			redactedData, err := json.Marshal(sanitized)
+			if err != nil {
+				return 0, fmt.Errorf("Unable to redact %s %s: %s", name, id, err.Error())
+			}
+			objects[id] = json.RawMessage(redactedData)
+			redacted++
+
+			if originals[name] == nil {
+				originals[name] = make(map[string]json.RawMessage)
+			}
+			originals[name][id] = data
+		}
+	}
+
+	if redacted == 0 || passphrase == "" {
+		return redacted, nil
+	}
+
+	data, err := json.Marshal(originals)
+	if err != nil {
+		return 0, err
+	}
+
+	var secrets stdbytes.Buffer
+	w, err := openpgp.SymmetricallyEncrypt(&secrets, []byte(passphrase), nil, nil)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return 0, err
+	}
+	if err := w.Close(); err != nil {
+		return 0, err
+	}
+	b.Secrets = secrets.Bytes()
+
+	return redacted, nil
+}
+
+// Open decrypts the secrets of a sealed backup with its passphrase, the
+// redacted resources being replaced by their originals
+func (b *Backup) Open(passphrase string) error {
+	if len(b.Secrets) == 0 {
+		return nil
+	}
+	if passphrase == "" {
+		return errors.New("The backup holds encrypted secrets, its passphrase is required")
+	}
+
+	// the prompt is called again as long as the passphrase is wrong
+	prompted := false
+	prompt := func(keys []openpgp.Key, symmetric bool) ([]byte, error) {
+		if prompted {
+			return nil, errors.New("wrong passphrase")
+		}
+		prompted = true
+		return []byte(passphrase), nil
+	}
+
+	md, err := openpgp.ReadMessage(stdbytes.NewReader(b.Secrets), nil, prompt, nil)
+	if err != nil {
+		return fmt.Errorf("Unable to decrypt the secrets of the backup: %s", err.Error())
+	}
+
+	data, err := ioutil.ReadAll(md.UnverifiedBody)
+	if err != nil {
+		return fmt.Errorf("Unable to decrypt the secrets of the backup: %s", err.Error())
+	}
+
+	var originals map[string]map[string]json.RawMessage
+	if err := json.Unmarshal(data, &originals); err != nil {
+		return fmt.Errorf("Unable to decode the secrets of the backup: %s", err.Error())
+	}
+
+	for name, objects := range originals {
+		if b.Objects[name] == nil {
+			b.Objects[name] = make(map[string]json.RawMessage)
+		}
+		for id, data := range objects {
+			b.Objects[name][id] = data
+		}
+	}
+	b.Secrets = nil
+
+	return nil
+}
+
+type RestoreRequest struct {
+	Backup *Backup
+	// conflict policy by resource type, skip by default
+	Policies map[string]string
+}
+
+type RestoreReport struct {
+	ConfigMismatch bool
+	Restored       map[string][]string
+	Skipped        map[string][]string
+}
+
+func (a *ApiServer) Backup() (*Backup, error) {
+	backup := &Backup{
+		Version:           BackupVersion,
+		ConfigFingerprint: config.Fingerprint(),
+		Objects:           make(map[string]map[string]json.RawMessage),
+	}
+
+	for name, handler := range a.handlers {
+		objects := make(map[string]json.RawMessage)
+		for id, resource := range handler.Index() {
+			data, err := json.Marshal(resource)
+			if err != nil {
+				return nil, fmt.Errorf("Unable to export %s %s: %s", name, id, err.Error())
+			}
+			objects[id] = json.RawMessage(data)
+		}
+		backup.Objects[name] = objects
+	}
+
+	if a.Graph != nil {
+		backup.Graph = a.backupGraph()
+	}
+
+	return backup, nil
+}
+
+type backupGraphElements []*BackupGraphElement
+
+func (e backupGraphElements) Len() int           { return len(e) }
+func (e backupGraphElements) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
+func (e backupGraphElements) Less(i, j int) bool { return e[i].ID < e[j].ID }
+
+// graphElement is a node or an edge of the graph
+type graphElement interface {
+	Metadata() graph.Metadata
+	Host() string
+}
+
+func (a *ApiServer) isWritten(e graphElement) bool {
+	origin, _ := e.Metadata()[graph.OriginKey].(string)
+	return origin == a.GraphOrigin
+}
+
+// copyMetadata returns a copy of the metadata, encoded once the graph is
+// unlocked
+func copyMetadata(m graph.Metadata) graph.Metadata {
+	metadata := make(graph.Metadata)
+	for k, v := range m {
+		metadata[k] = v
+	}
+	return metadata
+}
+
+// backupGraph returns the nodes and the edges of the graph origin
+func (a *ApiServer) backupGraph() *BackupGraph {
+	a.Graph.RLock()
+	defer a.Graph.RUnlock()
+
+	bg := &BackupGraph{}
+	for _, n := range a.Graph.GetNodes() {
+		if a.isWritten(n) {
+			bg.Nodes = append(bg.Nodes, &BackupGraphElement{ID: n.ID, Metadata: copyMetadata(n.Metadata())})
+		}
+	}
+	for _, e := range a.Graph.GetEdges() {
+		if a.isWritten(e) {
+			parent, child := a.Graph.GetEdgeNodes(e)
+			if parent == nil || child == nil {
+				continue
+			}
+			bg.Edges = append(bg.Edges, &BackupGraphElement{ID: e.ID, Metadata: copyMetadata(e.Metadata()), Parent: parent.ID, Child: child.ID})
+		}
+	}
+	sort.Sort(backupGraphElements(bg.Nodes))
+	sort.Sort(backupGraphElements(bg.Edges))
+
+	return bg
+}
+
+func restorePolicy(policies map[string]string, name string) (string, error) {
+	switch policy := policies[name]; policy {
+	case "":
+		return RestorePolicySkip, nil
+	case RestorePolicySkip, RestorePolicyOverwrite, RestorePolicyFail:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("Unknown restore policy for %s: %s", name, policy)
+	}
+}
+
+// Restore applies a backup, the conflicts are all checked before writing
+// anything so that a failed restore leaves the analyzer untouched. Restoring
+// twice the same backup leads to the same state.
+func (a *ApiServer) Restore(backup *Backup, policies map[string]string) (*RestoreReport, error) {
+	if backup.Version > BackupVersion {
+		return nil, fmt.Errorf("Unsupported backup version %d, expected %d at most", backup.Version, BackupVersion)
+	}
+	if len(backup.Secrets) != 0 {
+		return nil, errors.New("The backup holds encrypted secrets, open it with its passphrase first")
+	}
+
+	type restoreItem struct {
+		handler  ApiHandler
+		resource ApiResource
+		conflict bool
+	}
+
+	report := &RestoreReport{
+		ConfigMismatch: backup.ConfigFingerprint != config.Fingerprint(),
+		Restored:       make(map[string][]string),
+		Skipped:        make(map[string][]string),
+	}
+
+	var names []string
+	for name := range backup.Objects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var items []restoreItem
+	for _, name := range names {
+		handler, ok := a.handlers[name]
+		if !ok {
+			return nil, fmt.Errorf("Unknown resource type: %s", name)
+		}
+
+		policy, err := restorePolicy(policies, name)
+		if err != nil {
+			return nil, err
+		}
+
+		for id, data := range backup.Objects[name] {
+			resource := handler.New()
+			if err := json.Unmarshal([]byte(data), resource); err != nil {
+				return nil, fmt.Errorf("Unable to decode %s %s: %s", name, id, err.Error())
+			}
+
+			_, conflict := handler.Get(resource.ID())
+			if conflict && policy == RestorePolicyFail {
+				return nil, fmt.Errorf("%s %s already exists", name, id)
+			}
+
+			if conflict && policy == RestorePolicySkip {
+				report.Skipped[name] = append(report.Skipped[name], id)
+				continue
+			}
+
+			items = append(items, restoreItem{handler: handler, resource: resource, conflict: conflict})
+		}
+	}
+
+	var graphItems []graphRestoreItem
+	if backup.Graph != nil {
+		if a.Graph == nil {
+			return nil, errors.New("No graph to restore the graph elements into")
+		}
+
+		a.Graph.Lock()
+		defer a.Graph.Unlock()
+
+		var err error
+		if graphItems, err = a.planGraphRestore(backup.Graph, policies, report); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, item := range items {
+		if err := item.handler.Create(item.resource); err != nil {
+			return report, fmt.Errorf("Unable to restore %s %s: %s", item.handler.Name(), item.resource.ID(), err.Error())
+		}
+		report.Restored[item.handler.Name()] = append(report.Restored[item.handler.Name()], item.resource.ID())
+	}
+
+	for _, item := range graphItems {
+		a.restoreGraphElement(item)
+		report.Restored[BackupGraphName] = append(report.Restored[BackupGraphName], string(item.element.ID))
+	}
+
+	return report, nil
+}
+
+type graphRestoreItem struct {
+	element  *BackupGraphElement
+	edge     bool
+	conflict bool
+}
+
+// planGraphElement returns whether an element is to be restored according
+// to the policy, the elements reported by the agents being never overwritten
+func (a *ApiServer) planGraphElement(element *BackupGraphElement, existing graphElement, policy string, report *RestoreReport) (bool, error) {
+	if existing == nil {
+		return true, nil
+	}
+
+	switch {
+	case policy == RestorePolicyFail:
+		return false, fmt.Errorf("%s %s already exists", BackupGraphName, element.ID)
+	case policy == RestorePolicySkip:
+		report.Skipped[BackupGraphName] = append(report.Skipped[BackupGraphName], string(element.ID))
+		return false, nil
+	case !a.isWritten(existing):
+		origin, _ := existing.Metadata()[graph.OriginKey].(string)
+		if origin == "" {
+			origin = "agent " + existing.Host()
+		}
+		return false, &graph.OriginError{ID: element.ID, Origin: origin}
+	}
+	return true, nil
+}
+
+// planGraphRestore checks the conflicts of the graph elements, the graph
+// being locked. The edges linking a node missing, not reported yet by its
+// agent for instance, are skipped.
+func (a *ApiServer) planGraphRestore(bg *BackupGraph, policies map[string]string, report *RestoreReport) ([]graphRestoreItem, error) {
+	policy, err := restorePolicy(policies, BackupGraphName)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []graphRestoreItem
+	nodes := make(map[graph.Identifier]bool)
+	for _, n := range bg.Nodes {
+		var existing graphElement
+		if node := a.Graph.GetNode(n.ID); node != nil {
+			existing = node
+		}
+
+		restore, err := a.planGraphElement(n, existing, policy, report)
+		if err != nil {
+			return nil, err
+		}
+		if restore {
+			items = append(items, graphRestoreItem{element: n, conflict: existing != nil})
+		}
+		nodes[n.ID] = true
+	}
+
+	for _, e := range bg.Edges {
+		if (!nodes[e.Parent] && a.Graph.GetNode(e.Parent) == nil) || (!nodes[e.Child] && a.Graph.GetNode(e.Child) == nil) {
+			report.Skipped[BackupGraphName] = append(report.Skipped[BackupGraphName], string(e.ID))
+			continue
+		}
+
+		var existing graphElement
+		if edge := a.Graph.GetEdge(e.ID); edge != nil {
+			existing = edge
+		}
+
+		restore, err := a.planGraphElement(e, existing, policy, report)
+		if err != nil {
+			return nil, err
+		}
+		if restore {
+			items = append(items, graphRestoreItem{element: e, edge: true, conflict: existing != nil})
+		}
+	}
+
+	return items, nil
+}
+
+// restoreGraphElement creates or overwrites an element, set to the origin
+// of the graph to stay writable
+func (a *ApiServer) restoreGraphElement(item graphRestoreItem) {
+	e := item.element
+	metadata := copyMetadata(e.Metadata)
+	metadata[graph.OriginKey] = a.GraphOrigin
+
+	switch {
+	case item.conflict && item.edge:
+		a.Graph.SetMetadata(a.Graph.GetEdge(e.ID), metadata)
+	case item.conflict:
+		a.Graph.SetMetadata(a.Graph.GetNode(e.ID), metadata)
+	case item.edge:
+		a.Graph.NewEdge(e.ID, a.Graph.GetNode(e.Parent), a.Graph.GetNode(e.Child), metadata)
+	default:
+		a.Graph.NewNode(e.ID, metadata)
+	}
+}
+
+func (a *ApiServer) backupIndex(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
+	backup, err := a.Backup()
+	if err != nil {
+		logging.GetLogger().Errorf("Backup failed: %s", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(backup); err != nil {
+		logging.GetLogger().Criticalf("Failed to send backup: %s", err.Error())
+	}
+}
+
+func (a *ApiServer) restore(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
+	var request RestoreRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Backup == nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	report, err := a.Restore(request.Backup, request.Policies)
+	if err != nil {
+		logging.GetLogger().Errorf("Restore failed: %s", err.Error())
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	logging.GetJournal(logging.JournalAudit).RecordContext(shttp.RequestContext(&r.Request), "Backup restored by %q, policies: %v", r.Username, request.Policies)
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		logging.GetLogger().Criticalf("Failed to send restore report: %s", err.Error())
+	}
+}
+
+// RegisterBackupApi registers the backup and restore endpoints on the admin
+// listener, the graph elements written by the users being backed up too
+func RegisterBackupApi(a *ApiServer, g *graph.Graph) {
+	a.Graph = g
+	a.GraphOrigin = config.GetConfig().GetString("analyzer.gremlin_write.origin")
+
+	routes := []shttp.Route{
+		{
+			"Backup",
+			"GET",
+			"/api/admin/backup",
+			a.backupIndex,
+		},
+		{
+			"Restore",
+			"POST",
+			"/api/admin/restore",
+			a.restore,
+		},
+	}
+
+	a.HTTPServer.RegisterAdminRoutes(routes)
+}
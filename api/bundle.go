@@ -0,0 +1,596 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/abbot/go-http-auth"
+	"github.com/gorilla/mux"
+
+	shttp "github.com/redhat-cip/skydive/http"
+	"github.com/redhat-cip/skydive/logging"
+)
+
+// actions applied on the resources of a bundle
+const (
+	BundleActionCreate    = "create"
+	BundleActionUpdate    = "update"
+	BundleActionUnchanged = "unchanged"
+	BundleActionDelete    = "delete"
+)
+
+// health of the resources of a bundle
+const (
+	BundleResourceOK       = "ok"
+	BundleResourceMissing  = "missing"
+	BundleResourceModified = "modified"
+)
+
+// creation order of the resource types, the captures first so that the
+// alerts and the reports find their flows
+var bundleTypes = []string{"capture", "alert", "report"}
+
+// BundleObject is a resource declared in a bundle. The string values of
+// Spec may reference another object of the bundle by its Name, ${name} being
+// replaced by the ID of this object and ${name.Field} by one of its fields.
+type BundleObject struct {
+	Name string
+	Type string
+	Spec json.RawMessage
+}
+
+// BundleResource is a resource created by a bundle
+type BundleResource struct {
+	Type   string
+	ID     string
+	Action string `json:",omitempty"`
+}
+
+// Bundle declares a set of resources applied as a whole: applying a bundle
+// again updates, creates and deletes its resources to match the declaration
+type Bundle struct {
+	Name       string `valid:"nonzero"`
+	Objects    []BundleObject
+	Resources  map[string]BundleResource `json:",omitempty"`
+	UpdateTime time.Time
+}
+
+type BundleHandler struct {
+}
+
+func (b *BundleHandler) New() ApiResource {
+	return &Bundle{}
+}
+
+func (b *BundleHandler) Name() string {
+	return "bundle"
+}
+
+func (b *Bundle) ID() string {
+	return b.Name
+}
+
+var bundleRefRegexp = regexp.MustCompile(`\$\{([a-zA-Z0-9_-]+)(\.[a-zA-Z0-9_]+)?\}`)
+
+func bundleTypeRank(kind string) int {
+	for i, t := range bundleTypes {
+		if t == kind {
+			return i
+		}
+	}
+	return len(bundleTypes)
+}
+
+// bundleRefs returns the names of the objects referenced by the spec
+func bundleRefs(spec json.RawMessage) []string {
+	var refs []string
+	for _, match := range bundleRefRegexp.FindAllStringSubmatch(string(spec), -1) {
+		refs = append(refs, match[1])
+	}
+	return refs
+}
+
+// Validate checks that the objects are uniquely named, of a known type and
+// that their references can be ordered
+func (b *Bundle) Validate() error {
+	if b.Name == "" || strings.Contains(b.Name, "/") {
+		return fmt.Errorf("Invalid bundle name: %s", b.Name)
+	}
+
+	names := make(map[string]bool)
+	for _, object := range b.Objects {
+		if object.Name == "" {
+			return errors.New("Objects must be named")
+		}
+		if names[object.Name] {
+			return fmt.Errorf("Object %s declared twice", object.Name)
+		}
+		names[object.Name] = true
+
+		if bundleTypeRank(object.Type) == len(bundleTypes) {
+			return fmt.Errorf("Unknown type %s of %s, expected one of %s", object.Type, object.Name, strings.Join(bundleTypes, ", "))
+		}
+	}
+
+	for _, object := range b.Objects {
+		for _, ref := range bundleRefs(object.Spec) {
+			if !names[ref] {
+				return fmt.Errorf("Object %s references the unknown object %s", object.Name, ref)
+			}
+		}
+	}
+
+	_, err := b.order()
+	return err
+}
+
+// order returns the objects sorted so that each one comes after the ones it
+// references, by type otherwise
+func (b *Bundle) order() ([]BundleObject, error) {
+	var ordered []BundleObject
+	done := make(map[string]bool)
+
+	for len(ordered) != len(b.Objects) {
+		next := -1
+		for i, object := range b.Objects {
+			if done[object.Name] {
+				continue
+			}
+
+			ready := true
+			for _, ref := range bundleRefs(object.Spec) {
+				if !done[ref] {
+					ready = false
+					break
+				}
+			}
+
+			if ready && (next == -1 || bundleTypeRank(object.Type) < bundleTypeRank(b.Objects[next].Type)) {
+				next = i
+			}
+		}
+
+		if next == -1 {
+			return nil, errors.New("Circular references between the objects of the bundle")
+		}
+		done[b.Objects[next].Name] = true
+		ordered = append(ordered, b.Objects[next])
+	}
+
+	return ordered, nil
+}
+
+// resolveRefs replaces the references of the string values of the spec
+func resolveRefs(value interface{}, resolve func(name string, field string) (string, error)) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		var err error
+		resolved := bundleRefRegexp.ReplaceAllStringFunc(v, func(ref string) string {
+			match := bundleRefRegexp.FindStringSubmatch(ref)
+			s, e := resolve(match[1], strings.TrimPrefix(match[2], "."))
+			if e != nil {
+				err = e
+			}
+			return s
+		})
+		return resolved, err
+	case map[string]interface{}:
+		for key, child := range v {
+			resolved, err := resolveRefs(child, resolve)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = resolved
+		}
+	case []interface{}:
+		for i, child := range v {
+			resolved, err := resolveRefs(child, resolve)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = resolved
+		}
+	}
+	return value, nil
+}
+
+// bundleStep is a planned change of a resource of a bundle
+type bundleStep struct {
+	name     string
+	handler  ApiHandler
+	resource ApiResource
+	current  ApiResource
+	action   string
+}
+
+// BundleApiHandler applies the bundles on the resources of the API server,
+// the bundles themselves being stored by the wrapped handler
+type BundleApiHandler struct {
+	ApiHandler
+	sync.Mutex
+	api *ApiServer
+}
+
+// newBundleResource returns the resource of the given type, the resources
+// identified by an UUID keeping the one of their previous version
+func newBundleResource(kind string, previous ApiResource) ApiResource {
+	switch kind {
+	case "alert":
+		alert := NewAlert()
+		if p, ok := previous.(*Alert); ok {
+			alert.UUID, alert.CreateTime = p.UUID, p.CreateTime
+		}
+		return alert
+	case "report":
+		report := NewReport()
+		if p, ok := previous.(*Report); ok {
+			report.UUID, report.CreateTime = p.UUID, p.CreateTime
+		}
+		return report
+	default:
+		return &Capture{}
+	}
+}
+
+func sameResource(a ApiResource, b ApiResource) bool {
+	ja, _ := json.Marshal(a)
+	jb, _ := json.Marshal(b)
+	return reflect.DeepEqual(ja, jb)
+}
+
+// plan resolves the objects of the bundle and compares them with the
+// current resources. The resources of the previous version of the bundle
+// which are no more declared are planned for deletion.
+func (h *BundleApiHandler) plan(bundle *Bundle, previous *Bundle) ([]bundleStep, error) {
+	objects, err := bundle.order()
+	if err != nil {
+		return nil, err
+	}
+
+	var steps []bundleStep
+	resolved := make(map[string]ApiResource)
+
+	for _, object := range objects {
+		handler := h.api.GetHandler(object.Type)
+		if handler == nil {
+			return nil, fmt.Errorf("No %s handler for %s", object.Type, object.Name)
+		}
+
+		var prev ApiResource
+		if previous != nil {
+			if ref, ok := previous.Resources[object.Name]; ok && ref.Type == object.Type {
+				prev, _ = handler.Get(ref.ID)
+			}
+		}
+
+		var spec interface{}
+		if err := json.Unmarshal(object.Spec, &spec); err != nil {
+			return nil, fmt.Errorf("Invalid spec of %s: %s", object.Name, err.Error())
+		}
+
+		spec, err = resolveRefs(spec, func(name string, field string) (string, error) {
+			ref := resolved[name]
+			if field == "" {
+				return ref.ID(), nil
+			}
+			value := reflect.Indirect(reflect.ValueOf(ref)).FieldByName(field)
+			if !value.IsValid() {
+				return "", fmt.Errorf("Object %s has no field %s", name, field)
+			}
+			return fmt.Sprintf("%v", value.Interface()), nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		data, _ := json.Marshal(spec)
+		resource := newBundleResource(object.Type, prev)
+		if err := json.Unmarshal(data, resource); err != nil {
+			return nil, fmt.Errorf("Invalid spec of %s: %s", object.Name, err.Error())
+		}
+		resource.(ApiResourceBundled).SetBundleID(bundle.Name)
+
+		if v, ok := resource.(ApiResourceValidator); ok {
+			if err := v.Validate(); err != nil {
+				return nil, fmt.Errorf("Invalid %s %s: %s", object.Type, object.Name, err.Error())
+			}
+		}
+		resolved[object.Name] = resource
+
+		step := bundleStep{name: object.Name, handler: handler, resource: resource, action: BundleActionCreate}
+		if current, ok := handler.Get(resource.ID()); ok {
+			// the resources of the bundle may have lost their label when
+			// modified individually
+			owned := prev != nil && prev.ID() == current.ID()
+			if b, ok := current.(ApiResourceBundled); !owned && (!ok || b.BundleID() != bundle.Name) {
+				return nil, fmt.Errorf("%s %s already exists outside of the bundle", object.Type, resource.ID())
+			}
+			step.current = current
+			step.action = BundleActionUpdate
+			if sameResource(current, resource) {
+				step.action = BundleActionUnchanged
+			}
+		}
+		steps = append(steps, step)
+	}
+
+	if previous != nil {
+		// the deletions come last, in the reverse order of the creations
+		var deletions []bundleStep
+		for name, ref := range previous.Resources {
+			if r, ok := resolved[name]; ok && r.ID() == ref.ID && bundle.objectType(name) == ref.Type {
+				continue
+			}
+
+			handler := h.api.GetHandler(ref.Type)
+			if handler == nil {
+				continue
+			}
+			if current, ok := handler.Get(ref.ID); ok {
+				deletions = append(deletions, bundleStep{name: name, handler: handler, resource: current, current: current, action: BundleActionDelete})
+			}
+		}
+		sortBundleDeletions(deletions)
+		steps = append(steps, deletions...)
+	}
+
+	return steps, nil
+}
+
+func (b *Bundle) objectType(name string) string {
+	for _, object := range b.Objects {
+		if object.Name == name {
+			return object.Type
+		}
+	}
+	return ""
+}
+
+type sortBundleDeletionsByType []bundleStep
+
+func (s sortBundleDeletionsByType) Len() int {
+	return len(s)
+}
+
+func (s sortBundleDeletionsByType) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s sortBundleDeletionsByType) Less(i, j int) bool {
+	ri, rj := bundleTypeRank(s[i].handler.Name()), bundleTypeRank(s[j].handler.Name())
+	if ri == rj {
+		return s[i].name < s[j].name
+	}
+	return ri > rj
+}
+
+// sortBundleDeletions sorts the deletions in the reverse order of the types
+func sortBundleDeletions(steps []bundleStep) {
+	sort.Sort(sortBundleDeletionsByType(steps))
+}
+
+// execute applies the steps, the applied ones being rolled back on failure
+func (h *BundleApiHandler) execute(steps []bundleStep) error {
+	for i, step := range steps {
+		var err error
+		switch step.action {
+		case BundleActionCreate, BundleActionUpdate:
+			err = step.handler.Create(step.resource)
+		case BundleActionDelete:
+			err = step.handler.Delete(step.resource.ID())
+		}
+
+		if err != nil {
+			h.rollback(steps[:i])
+			return fmt.Errorf("Unable to %s %s: %s", step.action, step.name, err.Error())
+		}
+	}
+	return nil
+}
+
+func (h *BundleApiHandler) rollback(steps []bundleStep) {
+	for i := len(steps) - 1; i >= 0; i-- {
+		step := steps[i]
+
+		var err error
+		switch {
+		case step.action == BundleActionUnchanged:
+			continue
+		case step.current == nil:
+			err = step.handler.Delete(step.resource.ID())
+		default:
+			err = step.handler.Create(step.current)
+		}
+
+		if err != nil {
+			logging.GetLogger().Errorf("Unable to roll back %s of the bundle: %s", step.name, err.Error())
+		}
+	}
+}
+
+func (h *BundleApiHandler) previous(name string) *Bundle {
+	if resource, ok := h.ApiHandler.Get(name); ok {
+		return resource.(*Bundle)
+	}
+	return nil
+}
+
+// Create applies the bundle, creating, updating and deleting its resources
+// so that they match the declaration. Either all the changes are applied or
+// none of them.
+func (h *BundleApiHandler) Create(resource ApiResource) error {
+	h.Lock()
+	defer h.Unlock()
+
+	bundle := resource.(*Bundle)
+	previous := h.previous(bundle.Name)
+
+	steps, err := h.plan(bundle, previous)
+	if err != nil {
+		return err
+	}
+
+	if err := h.execute(steps); err != nil {
+		return err
+	}
+
+	bundle.Resources = make(map[string]BundleResource)
+	for _, step := range steps {
+		if step.action != BundleActionDelete {
+			bundle.Resources[step.name] = BundleResource{Type: step.handler.Name(), ID: step.resource.ID(), Action: step.action}
+		}
+	}
+	bundle.UpdateTime = time.Now().UTC()
+
+	if err := h.ApiHandler.Create(bundle); err != nil {
+		h.rollback(steps)
+		return err
+	}
+
+	return nil
+}
+
+// Delete deletes the bundle along with its resources
+func (h *BundleApiHandler) Delete(id string) error {
+	h.Lock()
+	defer h.Unlock()
+
+	bundle := h.previous(id)
+	if bundle == nil {
+		return fmt.Errorf("Bundle %s not found", id)
+	}
+
+	steps, err := h.plan(&Bundle{Name: bundle.Name}, bundle)
+	if err != nil {
+		return err
+	}
+
+	if err := h.execute(steps); err != nil {
+		return err
+	}
+
+	if err := h.ApiHandler.Delete(id); err != nil {
+		h.rollback(steps)
+		return err
+	}
+
+	return nil
+}
+
+// BundleResourceStatus is the health of a resource of a bundle
+type BundleResourceStatus struct {
+	Name   string
+	Type   string
+	ID     string
+	Status string
+}
+
+// Status compares the resources of the bundle with its declaration
+func (h *BundleApiHandler) Status(id string) ([]BundleResourceStatus, error) {
+	h.Lock()
+	defer h.Unlock()
+
+	bundle := h.previous(id)
+	if bundle == nil {
+		return nil, fmt.Errorf("Bundle %s not found", id)
+	}
+
+	objects, err := bundle.order()
+	if err != nil {
+		return nil, err
+	}
+
+	steps, err := h.plan(bundle, bundle)
+	if err != nil {
+		return nil, err
+	}
+
+	status := make([]BundleResourceStatus, 0, len(objects))
+	for i, object := range objects {
+		ref := bundle.Resources[object.Name]
+		s := BundleResourceStatus{Name: object.Name, Type: object.Type, ID: ref.ID, Status: BundleResourceOK}
+		switch steps[i].action {
+		case BundleActionCreate:
+			s.Status = BundleResourceMissing
+		case BundleActionUpdate:
+			s.Status = BundleResourceModified
+		}
+		status = append(status, s)
+	}
+
+	return status, nil
+}
+
+func (h *BundleApiHandler) bundleStatus(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
+	status, err := h.Status(mux.Vars(&r.Request)["name"])
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		logging.GetLogger().Criticalf("Failed to display bundle status: %s", err.Error())
+	}
+}
+
+// RegisterBundleApi registers the bundle resources and the route giving the
+// health of their resources, GET /api/bundle/<name>/status
+func RegisterBundleApi(a *ApiServer) (*BundleApiHandler, error) {
+	handler := &BundleApiHandler{
+		ApiHandler: &BasicApiHandler{
+			ResourceHandler: &BundleHandler{},
+			EtcdKeyAPI:      a.EtcdKeyAPI,
+		},
+		api: a,
+	}
+
+	// registered before the generic routes of the resources to take
+	// precedence over the one showing a bundle
+	a.HTTPServer.RegisterRoutes([]shttp.Route{
+		{
+			"BundleStatus",
+			"GET",
+			"/api/bundle/{name}/status",
+			handler.bundleStatus,
+		},
+	})
+
+	if err := a.RegisterApiHandler(handler); err != nil {
+		return nil, err
+	}
+
+	return handler, nil
+}
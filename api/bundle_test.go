@@ -0,0 +1,213 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// failingApiHandler refuses to create the resources
+type failingApiHandler struct {
+	*memoryApiHandler
+}
+
+func (h *failingApiHandler) Create(resource ApiResource) error {
+	return errors.New("create failure")
+}
+
+func newBundleTestHandler() (*BundleApiHandler, *ApiServer) {
+	a := newMemoryApiServer()
+	return &BundleApiHandler{
+		ApiHandler: &memoryApiHandler{ResourceHandler: &BundleHandler{}, resources: make(map[string]ApiResource)},
+		api:        a,
+	}, a
+}
+
+func newTestBundle(objects ...BundleObject) *Bundle {
+	return &Bundle{Name: "web", Objects: objects}
+}
+
+func bundleObject(name string, kind string, spec string) BundleObject {
+	return BundleObject{Name: name, Type: kind, Spec: json.RawMessage(spec)}
+}
+
+// the alert being declared first, it has to be created after the capture it
+// references
+func webBundle(mtu string) *Bundle {
+	return newTestBundle(
+		bundleObject("web-mtu", "alert", `{"Name": "web-mtu", "Description": "MTU of ${web-capture.BPFFilter}", "Select": "${web-capture}", "Test": "MTU > `+mtu+`", "Action": "http://localhost/alert"}`),
+		bundleObject("web-capture", "capture", `{"ProbePath": "G.V().Has('Name', 'eth0')", "BPFFilter": "port 80"}`),
+	)
+}
+
+func TestBundle_apply(t *testing.T) {
+	h, a := newBundleTestHandler()
+
+	bundle := webBundle("1500")
+	if err := bundle.Validate(); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := h.Create(bundle); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	capture, ok := a.Get("capture", "G.V().Has('Name', 'eth0')")
+	if !ok || capture.(*Capture).Bundle != "web" {
+		t.Fatalf("Capture not created by the bundle: %+v", capture)
+	}
+
+	ref := bundle.Resources["web-mtu"]
+	alert, ok := a.Get("alert", ref.ID)
+	if !ok {
+		t.Fatalf("Alert not created: %+v", bundle.Resources)
+	}
+	if alert.(*Alert).Select != "G.V().Has('Name', 'eth0')" || alert.(*Alert).Description != "MTU of port 80" || alert.(*Alert).Bundle != "web" {
+		t.Errorf("Wrong alert references: %+v", alert)
+	}
+
+	// applying the same bundle again changes nothing
+	again := webBundle("1500")
+	if err := h.Create(again); err != nil {
+		t.Fatal(err.Error())
+	}
+	for name, resource := range again.Resources {
+		if resource.Action != BundleActionUnchanged {
+			t.Errorf("%s shouldn't have changed: %+v", name, resource)
+		}
+	}
+	if again.Resources["web-mtu"].ID != ref.ID || len(a.Index("alert")) != 1 {
+		t.Errorf("The alert should keep its ID: %+v", again.Resources)
+	}
+
+	// declarative update, the capture is not declared anymore
+	updated := newTestBundle(bundleObject("web-mtu", "alert", `{"Name": "web-mtu", "Description": "MTU", "Select": "G.V()", "Test": "MTU > 9000", "Action": "http://localhost/alert"}`))
+	if err := h.Create(updated); err != nil {
+		t.Fatal(err.Error())
+	}
+	if updated.Resources["web-mtu"].Action != BundleActionUpdate {
+		t.Errorf("The alert should be updated: %+v", updated.Resources)
+	}
+	if len(a.Index("capture")) != 0 {
+		t.Error("The capture should be deleted")
+	}
+	if alert, _ := a.Get("alert", ref.ID); alert.(*Alert).Test != "MTU > 9000" {
+		t.Errorf("Alert not updated: %+v", alert)
+	}
+
+	if err := h.Delete("web"); err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(a.Index("alert")) != 0 || len(h.Index()) != 0 {
+		t.Error("The bundle and its resources should be deleted")
+	}
+}
+
+func TestBundle_rollback(t *testing.T) {
+	h, a := newBundleTestHandler()
+	if err := h.Create(webBundle("1500")); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	alerts := a.handlers["alert"].(*memoryApiHandler)
+	a.handlers["alert"] = &failingApiHandler{memoryApiHandler: alerts}
+
+	// the capture update is rolled back when the alert update fails
+	bundle := webBundle("9000")
+	bundle.Objects[1] = bundleObject("web-capture", "capture", `{"ProbePath": "G.V().Has('Name', 'eth0')", "BPFFilter": "port 8080"}`)
+	if err := h.Create(bundle); err == nil {
+		t.Fatal("The bundle shouldn't be applied")
+	}
+
+	if capture, _ := a.Get("capture", "G.V().Has('Name', 'eth0')"); capture.(*Capture).BPFFilter != "port 80" {
+		t.Errorf("Capture not rolled back: %+v", capture)
+	}
+	if b, _ := h.Get("web"); b.(*Bundle).Objects[0].Spec == nil || len(b.(*Bundle).Resources) != 2 {
+		t.Errorf("The previous bundle should be kept: %+v", b)
+	}
+
+	// a new bundle leaves nothing behind
+	a.handlers["capture"].Delete("G.V().Has('Name', 'eth0')")
+	h.ApiHandler.Delete("web")
+	if err := h.Create(webBundle("1500")); err == nil {
+		t.Fatal("The bundle shouldn't be applied")
+	}
+	if len(a.Index("capture")) != 0 || len(h.Index()) != 0 {
+		t.Error("The created capture should be deleted")
+	}
+}
+
+func TestBundle_conflict(t *testing.T) {
+	h, a := newBundleTestHandler()
+	a.Create("capture", NewCapture("G.V().Has('Name', 'eth0')", ""))
+
+	if err := h.Create(webBundle("1500")); err == nil {
+		t.Error("A bundle shouldn't take over an existing capture")
+	}
+	if len(a.Index("alert")) != 0 {
+		t.Error("Nothing should be created on conflict")
+	}
+}
+
+func TestBundle_status(t *testing.T) {
+	h, a := newBundleTestHandler()
+	bundle := webBundle("1500")
+	if err := h.Create(bundle); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	alert, _ := a.Get("alert", bundle.Resources["web-mtu"].ID)
+	alert.(*Alert).Test = "MTU > 1"
+	a.Delete("capture", "G.V().Has('Name', 'eth0')")
+
+	status, err := h.Status("web")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expected := map[string]string{"web-capture": BundleResourceMissing, "web-mtu": BundleResourceModified}
+	if len(status) != 2 {
+		t.Fatalf("Wrong status: %+v", status)
+	}
+	for _, s := range status {
+		if s.Status != expected[s.Name] {
+			t.Errorf("Wrong status of %s: %s", s.Name, s.Status)
+		}
+	}
+}
+
+func TestBundle_validate(t *testing.T) {
+	invalid := []*Bundle{
+		newTestBundle(bundleObject("a", "capture", `{}`), bundleObject("a", "capture", `{}`)),
+		newTestBundle(bundleObject("a", "query", `{}`)),
+		newTestBundle(bundleObject("a", "alert", `{"Select": "${b}"}`)),
+		newTestBundle(bundleObject("a", "alert", `{"Select": "${b}"}`), bundleObject("b", "alert", `{"Select": "${a}"}`)),
+	}
+
+	for i, bundle := range invalid {
+		if err := bundle.Validate(); err == nil {
+			t.Errorf("Bundle %d should be invalid", i)
+		}
+	}
+}
@@ -22,9 +22,22 @@
 
 package api
 
+import (
+	"github.com/redhat-cip/skydive/flow"
+	"github.com/redhat-cip/skydive/storage"
+	"github.com/redhat-cip/skydive/topology"
+)
+
+// Capture starts the flow probes on the interfaces of ProbePath. The Tags,
+// in the Tag. namespace, are added to the attributes of the flows, updated
+// tags applying to the flows created from then on only. The Significance
+// thresholds replace the analyzer ones for the flows of the capture.
 type Capture struct {
-	ProbePath string `json:",omitempty" valid:"nonzero"`
-	BPFFilter string `json:",omitempty"`
+	ProbePath    string                    `json:",omitempty" valid:"nonzero"`
+	BPFFilter    string                    `json:",omitempty"`
+	Bundle       string                    `json:",omitempty"`
+	Tags         map[string]string         `json:",omitempty"`
+	Significance *storage.FlowSignificance `json:",omitempty"`
 }
 
 type CaptureHandler struct {
@@ -37,6 +50,10 @@ func NewCapture(probePath string, bpfFilter string) *Capture {
 	}
 }
 
+func (c *Capture) Validate() error {
+	return flow.ValidateTags(c.Tags)
+}
+
 func (c *CaptureHandler) New() ApiResource {
 	return &Capture{}
 }
@@ -48,3 +65,24 @@ func (c *CaptureHandler) Name() string {
 func (c *Capture) ID() string {
 	return c.ProbePath
 }
+
+func (c *Capture) BundleID() string {
+	return c.Bundle
+}
+
+func (c *Capture) SetBundleID(id string) {
+	c.Bundle = id
+}
+
+// CaptureLookup returns the probe path and the tags of the captures
+// stored by the handler
+func CaptureLookup(h *BasicApiHandler) topology.CaptureLookup {
+	return func(id string) (string, map[string]string, bool) {
+		resource, ok := h.Get(id)
+		if !ok {
+			return "", nil, false
+		}
+		c := resource.(*Capture)
+		return c.ProbePath, c.Tags, true
+	}
+}
@@ -0,0 +1,259 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package api
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/redhat-cip/skydive/config"
+)
+
+// ExpressionLimits are the structural limits of the alert test expressions
+// and the functions they are allowed to call, type conversions included
+type ExpressionLimits struct {
+	MaxLength int
+	MaxNodes  int
+	MaxDepth  int
+	Functions []string
+}
+
+// ExpressionError locates the part of an expression rejected by the
+// validation, Column starting at 1
+type ExpressionError struct {
+	Column int
+	Msg    string
+}
+
+func (e *ExpressionError) Error() string {
+	return fmt.Sprintf("column %d: %s", e.Column, e.Msg)
+}
+
+// maximum shift count and number of digits of the numeric literals, the
+// constants being evaluated with an arbitrary precision
+const (
+	maxShift         = 63
+	maxLiteralDigits = 32
+)
+
+type expressionValidator struct {
+	limits    ExpressionLimits
+	functions map[string]bool
+	nodes     int
+	depth     int
+	err       *ExpressionError
+}
+
+func (v *expressionValidator) fail(n ast.Node, format string, args ...interface{}) {
+	if v.err == nil {
+		v.err = &ExpressionError{Column: int(n.Pos()), Msg: fmt.Sprintf(format, args...)}
+	}
+}
+
+func (v *expressionValidator) permitted() string {
+	if len(v.limits.Functions) == 0 {
+		return "none"
+	}
+	return strings.Join(v.limits.Functions, ", ")
+}
+
+func (v *expressionValidator) Visit(n ast.Node) ast.Visitor {
+	if n == nil {
+		v.depth--
+		return nil
+	}
+	if v.err != nil {
+		return nil
+	}
+
+	v.nodes++
+	if v.nodes > v.limits.MaxNodes {
+		v.fail(n, "more than %d operands and operators", v.limits.MaxNodes)
+		return nil
+	}
+
+	v.depth++
+	if v.depth > v.limits.MaxDepth {
+		v.fail(n, "nested more than %d levels deep", v.limits.MaxDepth)
+		return nil
+	}
+
+	switch n := n.(type) {
+	case *ast.CallExpr:
+		fun, ok := n.Fun.(*ast.Ident)
+		if !ok {
+			v.fail(n, "only calls to named functions are allowed, permitted functions: %s", v.permitted())
+			return nil
+		}
+		if !v.functions[fun.Name] {
+			v.fail(n, "call to %s not allowed, permitted functions: %s", fun.Name, v.permitted())
+			return nil
+		}
+		if n.Ellipsis.IsValid() {
+			v.fail(n, "variadic call to %s not allowed", fun.Name)
+			return nil
+		}
+	case *ast.FuncLit:
+		v.fail(n, "function literals are not allowed")
+	case *ast.CompositeLit:
+		v.fail(n, "composite literals are not allowed")
+	case *ast.UnaryExpr:
+		if n.Op == token.ARROW {
+			v.fail(n, "channel receives are not allowed")
+		}
+	case *ast.BinaryExpr:
+		if n.Op == token.SHL || n.Op == token.SHR {
+			if lit, ok := n.Y.(*ast.BasicLit); ok {
+				if count, err := strconv.ParseUint(lit.Value, 0, 64); err != nil || count > maxShift {
+					v.fail(lit, "shift count %s larger than %d", lit.Value, maxShift)
+				}
+			}
+		}
+	case *ast.BasicLit:
+		if n.Kind != token.STRING && n.Kind != token.CHAR && len(n.Value) > maxLiteralDigits {
+			v.fail(n, "numeric literal longer than %d digits", maxLiteralDigits)
+		}
+	case *ast.StarExpr, *ast.ArrayType, *ast.MapType, *ast.ChanType, *ast.FuncType,
+		*ast.InterfaceType, *ast.StructType, *ast.TypeAssertExpr, *ast.KeyValueExpr:
+		v.fail(n, "%s not allowed", strings.TrimPrefix(fmt.Sprintf("%T", n), "*ast."))
+	}
+
+	if v.err != nil {
+		return nil
+	}
+	return v
+}
+
+// ParseExpression parses an alert test expression and checks it against
+// the limits
+func ParseExpression(expr string, limits ExpressionLimits) (ast.Expr, error) {
+	if len(expr) > limits.MaxLength {
+		return nil, &ExpressionError{Column: limits.MaxLength + 1, Msg: fmt.Sprintf("expression longer than %d characters", limits.MaxLength)}
+	}
+
+	e, err := parser.ParseExpr(expr)
+	if err != nil {
+		if list, ok := err.(scanner.ErrorList); ok && len(list) > 0 {
+			return nil, &ExpressionError{Column: list[0].Pos.Column, Msg: list[0].Msg}
+		}
+		return nil, err
+	}
+
+	v := &expressionValidator{limits: limits, functions: make(map[string]bool)}
+	for _, f := range limits.Functions {
+		v.functions[f] = true
+	}
+	ast.Walk(v, e)
+	if v.err != nil {
+		return nil, v.err
+	}
+
+	return e, nil
+}
+
+// ExpressionIdents returns the identifiers an expression refers to, the
+// called functions excluded
+func ExpressionIdents(e ast.Expr) []string {
+	seen := make(map[string]bool)
+	var idents []string
+	ast.Inspect(e, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.CallExpr:
+			for _, arg := range n.Args {
+				for _, ident := range ExpressionIdents(arg) {
+					if !seen[ident] {
+						seen[ident] = true
+						idents = append(idents, ident)
+					}
+				}
+			}
+			return false
+		case *ast.Ident:
+			if !seen[n.Name] {
+				seen[n.Name] = true
+				idents = append(idents, n.Name)
+			}
+		}
+		return true
+	})
+	return idents
+}
+
+// RenderExpression returns the expression with the identifiers replaced by
+// their values, given as Go literals
+func RenderExpression(expr string, values map[string]interface{}) string {
+	src := []byte(expr)
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(src))
+
+	var s scanner.Scanner
+	s.Init(file, src, nil, 0)
+
+	var rendered []byte
+	last := 0
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		if tok != token.IDENT {
+			continue
+		}
+
+		value, ok := values[lit]
+		if !ok {
+			continue
+		}
+
+		offset := file.Offset(pos)
+		rendered = append(rendered, src[last:offset]...)
+		if str, ok := value.(string); ok {
+			rendered = append(rendered, strconv.Quote(str)...)
+		} else {
+			rendered = append(rendered, fmt.Sprint(value)...)
+		}
+		last = offset + len(lit)
+	}
+
+	return string(append(rendered, src[last:]...))
+}
+
+func NewExpressionLimitsFromConfig() ExpressionLimits {
+	cfg := config.GetConfig()
+
+	functions := cfg.GetStringSlice("analyzer.alert_sandbox.functions")
+	sort.Strings(functions)
+
+	return ExpressionLimits{
+		MaxLength: cfg.GetInt("analyzer.alert_sandbox.max_length"),
+		MaxNodes:  cfg.GetInt("analyzer.alert_sandbox.max_nodes"),
+		MaxDepth:  cfg.GetInt("analyzer.alert_sandbox.max_depth"),
+		Functions: functions,
+	}
+}
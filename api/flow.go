@@ -26,96 +26,835 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/abbot/go-http-auth"
 	"github.com/gorilla/mux"
 
+	"github.com/redhat-cip/skydive/config"
 	"github.com/redhat-cip/skydive/flow"
+	"github.com/redhat-cip/skydive/flow/mappings"
 	shttp "github.com/redhat-cip/skydive/http"
 	"github.com/redhat-cip/skydive/logging"
 	"github.com/redhat-cip/skydive/storage"
 )
 
 type FlowApi struct {
-	Service   string
-	FlowTable *flow.Table
-	Storage   storage.Storage
+	Service     string
+	FlowTable   *flow.Table
+	Storage     storage.Storage
+	NATCollapse bool
+	// annotate the conversation links with the directions observed
+	Symmetry bool
+	Pipeline *mappings.FlowMappingPipeline
+	// ignore the unknown filter keys of the searches instead of rejecting
+	// them
+	LenientFilters bool
+	// number of the flows returned by the searches without a limit, 0
+	// meaning no limit
+	DefaultLimit int
+	// results of the conversations and of the discovery, computed on a
+	// snapshot of the table at each request when not set
+	Aggregates *FlowAggregates
+	// credentials required by the routes in addition to the authentication
+	// of the server, none when not set
+	Credentials *shttp.AuthenticationOpts
 }
 
-func (f *FlowApi) flowSearch(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
+// FlowExplanation is a flow along with the provenance of the fields set by
+// the enhancers, as recorded by the pipeline
+type FlowExplanation struct {
+	Flow       *flow.Flow
+	Provenance []mappings.FlowFieldProvenance
+}
+
+func filtersFromRequest(r *auth.AuthenticatedRequest) storage.Filters {
 	filters := make(storage.Filters)
 	for k, v := range r.URL.Query() {
 		filters[k] = v[0]
 	}
+	return filters
+}
+
+// writeStorageError replies with a bad request for the filters the storage
+// can't translate
+func writeStorageError(w http.ResponseWriter, err error) {
+	if _, ok := err.(*storage.FilterError); ok {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	w.WriteHeader(http.StatusNotFound)
+}
 
+// filterAliases returns the aliases of the filter keys of the storage
+func (f *FlowApi) filterAliases() storage.Aliases {
+	if as, ok := f.Storage.(*storage.AliasedStorage); ok {
+		return as.Aliases
+	}
+	return nil
+}
+
+// unknownFilterKeys returns the keys of the filters being neither a flow
+// field, an alias nor one of them suffixed with an operator, sorted. The
+// operators are checked by the storage.
+func (f *FlowApi) unknownFilterKeys(filters storage.Filters) []string {
+	aliases := f.filterAliases()
+
+	var unknown []string
+	for key := range filters {
+		if _, ok := aliases[key]; ok || flow.IsFilterKey(key) {
+			continue
+		}
+		if _, _, ok := aliases.Operator(key); !ok {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+// checkFilterKeys rejects the filters with unknown keys, listing the valid
+// ones, or removes these keys with a warning header in lenient mode.
+// Returns false once replied.
+func (f *FlowApi) checkFilterKeys(w http.ResponseWriter, r *auth.AuthenticatedRequest, filters storage.Filters) bool {
+	unknown := f.unknownFilterKeys(filters)
+	if len(unknown) == 0 {
+		return true
+	}
+
+	if f.LenientFilters {
+		for _, key := range unknown {
+			delete(filters, key)
+		}
+		w.Header().Set("Warning", fmt.Sprintf(`199 skydive "Unknown filter keys ignored: %s"`, strings.Join(unknown, ", ")))
+		logging.GetContextLogger(shttp.RequestContext(&r.Request)).Warningf("Unknown filter keys of a flow search ignored: %s", strings.Join(unknown, ", "))
+		return true
+	}
+
+	var valid []string
+	for alias := range f.filterAliases() {
+		valid = append(valid, alias)
+	}
+	sort.Strings(valid)
+	valid = append(valid, flow.FilterKeys()...)
+
+	w.WriteHeader(http.StatusBadRequest)
+	w.Write([]byte(fmt.Sprintf("Unknown filter keys: %s, valid keys: %s", strings.Join(unknown, ", "), strings.Join(valid, ", "))))
+	return false
+}
+
+func (f *FlowApi) flowSearch(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 	if f.Storage == nil {
 		w.WriteHeader(http.StatusNotFound)
 		return
 	}
 
-	flows, err := f.Storage.SearchFlows(filters)
+	filters := filtersFromRequest(r)
+
+	// explain=true returns the flows along with the provenance of their
+	// enriched fields
+	explain := false
+	if e, ok := filters["explain"]; ok {
+		delete(filters, "explain")
+		explain, _ = strconv.ParseBool(e.(string))
+	}
+
+	// compact=true returns the interned flows with the references of their
+	// attribute sets, resolved by /api/flow/attributes/{ref}
+	ctx := shttp.RequestContext(&r.Request)
+	if c, ok := filters["compact"]; ok {
+		delete(filters, "compact")
+		if compact, _ := strconv.ParseBool(c.(string)); compact {
+			ctx = storage.WithCompactAttributes(ctx)
+		}
+	}
+
+	// limit returns a page of the flows along with their total, the next
+	// pages being requested with offset
+	q, paged, ok := pageFromRequest(w, r, filters)
+	if !ok {
+		return
+	}
+	q.Filters = filters
+
+	// sort=field[:asc|desc] orders the flows by a numeric field, from the
+	// most recent one by default
+	sorted := false
+	delete(filters, "sort")
+	if s := r.URL.Query().Get("sort"); s != "" {
+		var err error
+		if q.Sort, err = f.filterAliases().ParseSort(s); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("Invalid sort: " + err.Error()))
+			return
+		}
+		sorted = true
+	}
+
+	// the searches without a limit return at most DefaultLimit flows, still
+	// as an array, a Warning header telling the flows left out
+	limited := !paged && f.DefaultLimit > 0
+	if limited {
+		q.Limit = f.DefaultLimit
+	}
+
+	if !f.checkFilterKeys(w, r, filters) {
+		return
+	}
+
+	var flows []*flow.Flow
+	var total int
+	var err error
+	cs, searcher := f.Storage.(storage.ContextSearcher)
+	switch {
+	case paged || limited || sorted:
+		flows, total, err = storage.SearchFlowsPaged(ctx, f.Storage, q)
+	case searcher:
+		// the searches are cancelled when the client goes away
+		flows, err = cs.SearchFlowsContext(ctx, filters)
+	default:
+		flows, err = f.Storage.SearchFlows(filters)
+	}
+	if err != nil {
+		writeStorageError(w, err)
+		return
+	}
+
+	if limited && total > len(flows) {
+		w.Header().Set("Warning", fmt.Sprintf(`199 skydive "Search limited to %d of %d flows, page with limit and offset"`, len(flows), total))
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	var result interface{} = flows
+	if explain {
+		result = f.explain(flows)
+	}
+	if paged {
+		page := &FlowPage{Flows: result, Total: total}
+		if next := q.Offset + len(flows); next < total {
+			page.NextOffset = next
+		}
+		result = page
+	}
+
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		panic(err)
+	}
+}
+
+// FlowPage is a page of the flows of a search, NextOffset being the offset
+// of the next page, omitted on the last one
+type FlowPage struct {
+	Flows      interface{} `json:"flows"`
+	Total      int         `json:"total"`
+	NextOffset int         `json:"next_offset,omitempty"`
+}
+
+// FlowCount is the number of the flows matching the filters of a count
+type FlowCount struct {
+	Count int `json:"count"`
+}
+
+// pageFromRequest removes the limit and offset parameters from the filters,
+// replying with a bad request if they're invalid. The search is paged only
+// when a limit is given.
+func pageFromRequest(w http.ResponseWriter, r *auth.AuthenticatedRequest, filters storage.Filters) (q storage.SearchQuery, paged bool, ok bool) {
+	delete(filters, "limit")
+	delete(filters, "offset")
+
+	var err error
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if q.Limit, err = strconv.Atoi(l); err != nil || q.Limit <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("Invalid limit: " + l))
+			return q, false, false
+		}
+		paged = true
+	}
+
+	if o := r.URL.Query().Get("offset"); o != "" {
+		if q.Offset, err = strconv.Atoi(o); err != nil || q.Offset < 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("Invalid offset: " + o))
+			return q, false, false
+		}
+		if !paged {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("The offset requires a limit"))
+			return q, false, false
+		}
+	}
+
+	return q, paged, true
+}
+
+// flowAttributes returns the attribute set referenced by the interned flows
+func (f *FlowApi) flowAttributes(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	resolver, ok := f.Storage.(storage.AttributeResolver)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	ref := mux.Vars(&r.Request)["ref"]
+	sets, err := resolver.ResolveAttributes([]string{ref})
 	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	attrs, ok := sets[ref]
+	if !ok {
 		w.WriteHeader(http.StatusNotFound)
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(attrs); err != nil {
+		panic(err)
+	}
+}
+
+// flowKeys returns the catalog of the flow fields and attributes
+func (f *FlowApi) flowKeys(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
+	if f.Pipeline == nil {
+		serveKeyCatalog(w, r, nil)
+		return
+	}
+	serveKeyCatalog(w, r, f.Pipeline.KeyCatalog())
+}
+
+// FlowSchema is the registry of the flow attributes, with the violations of
+// the declarations counted in strict mode
+type FlowSchema struct {
+	Attributes []mappings.AttributeDeclaration
+	Strict     bool
+	Stats      mappings.AttributeSchemaStats
+}
 
-	if err := json.NewEncoder(w).Encode(flows); err != nil {
+// flowSchema returns the registry of the flow attributes
+func (f *FlowApi) flowSchema(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	if f.Pipeline == nil || f.Pipeline.AttributeSchema() == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	schema := f.Pipeline.AttributeSchema()
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(FlowSchema{
+		Attributes: schema.Declarations(),
+		Strict:     f.Pipeline.StrictAttributeSchema(),
+		Stats:      schema.Stats(),
+	}); err != nil {
+		panic(err)
+	}
+}
+
+func (f *FlowApi) explain(flows []*flow.Flow) []FlowExplanation {
+	explanations := []FlowExplanation{}
+	for _, fl := range flows {
+		explanation := FlowExplanation{Flow: fl, Provenance: []mappings.FlowFieldProvenance{}}
+		if f.Pipeline != nil {
+			if p := f.Pipeline.Provenance(fl.UUID); p != nil {
+				explanation.Provenance = p
+			}
+		}
+		explanations = append(explanations, explanation)
+	}
+	return explanations
+}
+
+// FlowFeedResult holds the flows stored after the cursor of the request,
+// Cursor being the one to give to the next request. Cursor is a string as
+// it exceeds the integers exactly represented by JavaScript.
+type FlowFeedResult struct {
+	Flows  []*flow.Flow
+	Cursor string
+}
+
+// flowFeed returns the flows stored since the previous call, the cursor it
+// returned being given back by the client
+func (f *FlowApi) flowFeed(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	if f.Storage == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	feed, ok := f.Storage.(storage.FlowFeed)
+	if !ok {
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+
+	filters := filtersFromRequest(r)
+	delete(filters, "cursor")
+	delete(filters, "limit")
+
+	var cursor int64
+	if c := r.URL.Query().Get("cursor"); c != "" {
+		var err error
+		if cursor, err = strconv.ParseInt(c, 10, 64); err != nil || cursor < 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("Invalid cursor: " + c))
+			return
+		}
+	}
+
+	max := config.GetConfig().GetInt("analyzer.query_max_results")
+	limit := max
+	if l := r.URL.Query().Get("limit"); l != "" {
+		var err error
+		if limit, err = strconv.Atoi(l); err != nil || limit <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("Invalid limit: " + l))
+			return
+		}
+		if limit > max {
+			limit = max
+		}
+	}
+
+	flows, next, err := feed.SearchFlowsSince(filters, cursor, limit)
+	if err == storage.ErrFlowFeedNotSupported {
+		w.WriteHeader(http.StatusNotImplemented)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	if err != nil {
+		writeStorageError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	result := &FlowFeedResult{Flows: flows, Cursor: strconv.FormatInt(next, 10)}
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		panic(err)
+	}
+}
+
+func (f *FlowApi) flowCount(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	if f.Storage == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	// the parameters of the searches not being filters are ignored, a
+	// search and its count sharing their query
+	filters := filtersFromRequest(r)
+	for _, param := range []string{"explain", "compact", "limit", "offset", "sort"} {
+		delete(filters, param)
+	}
+
+	if !f.checkFilterKeys(w, r, filters) {
+		return
+	}
+
+	count, err := f.Storage.CountFlows(filters)
+	if err != nil {
+		writeStorageError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(&FlowCount{Count: count}); err != nil {
 		panic(err)
 	}
 }
 
 func (f *FlowApi) serveDataIndex(w http.ResponseWriter, r *auth.AuthenticatedRequest, message string) {
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	if notModified(w, &r.Request, bodyETag([]byte(message))) {
+		return
+	}
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte(message))
 }
 
+type conversationLink struct {
+	source     string
+	target     string
+	value      uint64
+	directed   bool
+	translated bool
+	// packets seen from the source to the target and back
+	forward  uint64
+	backward uint64
+	// tags shared by all the flows of the link
+	tags map[string]string
+}
+
+// shareTags keeps the tags of the link also carried by the given ones
+func (l *conversationLink) shareTags(tags map[string]string) {
+	for k, v := range l.tags {
+		if tags[k] != v {
+			delete(l.tags, k)
+		}
+	}
+}
+
+// symmetric returns whether the traffic was seen in both directions
+func (l *conversationLink) symmetric() bool {
+	return l.forward > 0 && l.backward > 0
+}
+
+// flowTimeRange restricts an aggregation to the flows seen between From
+// and To, unix timestamps, a zero bound being open
+type flowTimeRange struct {
+	From int64
+	To   int64
+}
+
+// filter returns the flows whose first and last seen timestamps overlap the
+// range
+func (t flowTimeRange) filter(flows []*flow.Flow) []*flow.Flow {
+	if t.From == 0 && t.To == 0 {
+		return flows
+	}
+
+	filtered := []*flow.Flow{}
+	for _, f := range flows {
+		fs := f.GetStatistics()
+		if fs == nil {
+			continue
+		}
+		if (t.From != 0 && fs.Last < t.From) || (t.To != 0 && fs.Start > t.To) {
+			continue
+		}
+		filtered = append(filtered, f)
+	}
+	return filtered
+}
+
+// timeRangeFromRequest returns the range given by the from and to query
+// parameters, replying with an error if they are invalid
+func timeRangeFromRequest(w http.ResponseWriter, r *auth.AuthenticatedRequest) (flowTimeRange, bool) {
+	var t flowTimeRange
+	var err error
+
+	if t.From, err = parseRollupTime(r, "from"); err != nil || t.From < 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("Invalid from value: " + r.URL.Query().Get("from")))
+		return t, false
+	}
+	if t.To, err = parseRollupTime(r, "to"); err != nil || t.To < 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("Invalid to value: " + r.URL.Query().Get("to")))
+		return t, false
+	}
+	if t.To != 0 && t.From > t.To {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("Invalid time range, from is after to"))
+		return t, false
+	}
+	return t, true
+}
+
+// conversationOptions are the options of the conversation requests
+type conversationOptions struct {
+	groupByASN bool
+	// annotate the links with the symmetry of their traffic
+	symmetry bool
+	// keep only the links seen in a single direction
+	asymmetricOnly bool
+	// keep only the flows seen in the range
+	timeRange flowTimeRange
+}
+
+type conversationJSONNode struct {
+	Name  string `json:"name"`
+	Group int    `json:"group"`
+	ASN   string `json:"asn,omitempty"`
+}
+
+type conversationJSONLink struct {
+	Source    int               `json:"source"`
+	Target    int               `json:"target"`
+	Value     uint64            `json:"value"`
+	Directed  bool              `json:"directed"`
+	Symmetric *bool             `json:"symmetric,omitempty"`
+	Tags      map[string]string `json:"tags,omitempty"`
+}
+
+type conversationJSON struct {
+	Nodes []conversationJSONNode `json:"nodes"`
+	Links []conversationJSONLink `json:"links"`
+}
+
+type sortByUUID []*flow.Flow
+
+func (s sortByUUID) Len() int {
+	return len(s)
+}
+
+func (s sortByUUID) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s sortByUUID) Less(i, j int) bool {
+	return s[i].UUID < s[j].UUID
+}
+
+type sortByEndpoints []conversationJSONLink
+
+func (s sortByEndpoints) Len() int {
+	return len(s)
+}
+
+func (s sortByEndpoints) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s sortByEndpoints) Less(i, j int) bool {
+	if s[i].Source != s[j].Source {
+		return s[i].Source < s[j].Source
+	}
+	if s[i].Target != s[j].Target {
+		return s[i].Target < s[j].Target
+	}
+	if s[i].Directed != s[j].Directed {
+		return !s[i].Directed
+	}
+	return s[i].Value < s[j].Value
+}
+
+// natTranslations returns the mapping between the translated addresses and
+// the original ones, as provided by the agents through the flow attributes
+func natTranslations(flows []*flow.Flow) map[string]string {
+	translations := make(map[string]string)
+	for _, f := range flows {
+		ipv4 := f.GetStatistics().GetEndpointsType(flow.FlowEndpointType_IPV4)
+		if ipv4 == nil {
+			continue
+		}
+
+		// the flows decoded from partial records may miss a side
+		if nat, ok := f.Attributes[flow.FlowAttributeNATA]; ok && ipv4.AB != nil && nat != ipv4.AB.Value {
+			translations[nat] = ipv4.AB.Value
+		}
+		if nat, ok := f.Attributes[flow.FlowAttributeNATB]; ok && ipv4.BA != nil && nat != ipv4.BA.Value {
+			translations[nat] = ipv4.BA.Value
+		}
+	}
+	return translations
+}
+
+// jsonFlowConversationEthernetPath returns the endpoints of the given type
+// and the conversations between them. The output doesn't depend on the
+// order of the flows in the table: the nodes are sorted by endpoint value,
+// the links by source and target indexes and the groups are the indexes
+// of the sorted layers paths.
 func (f *FlowApi) jsonFlowConversationEthernetPath(EndpointType flow.FlowEndpointType) string {
+	return f.jsonFlowConversation(EndpointType, conversationOptions{symmetry: f.Symmetry})
+}
+
+// jsonFlowConversation returns the conversation, the groups being the
+// indexes of the sorted ASNs of the endpoints when groupByASN is set, 0
+// for the endpoints without ASN. With asymmetricOnly, only the links whose
+// traffic was seen in a single direction and their endpoints are returned.
+func (f *FlowApi) jsonFlowConversation(EndpointType flow.FlowEndpointType, opts conversationOptions) string {
+	// the ranges, given freely by the requests, aren't cached
+	var key string
+	if opts.timeRange == (flowTimeRange{}) {
+		key = fmt.Sprintf("conversation/%s/%t/%+v", EndpointType, f.NATCollapse, opts)
+	}
+	return f.aggregate(key, func(flows []*flow.Flow) string {
+		return f.conversation(opts.timeRange.filter(flows), EndpointType, opts)
+	})
+}
+
+// aggregate returns the result of the aggregation of the flow table, cached
+// under key unless empty
+func (f *FlowApi) aggregate(key string, compute aggregateFunc) string {
+	if f.Aggregates != nil && key != "" {
+		return f.Aggregates.Get(key, compute)
+	}
+	return compute(f.FlowTable.Snapshot())
+}
+
+// conversation computes the conversation of the flows
+func (f *FlowApi) conversation(flows []*flow.Flow, EndpointType flow.FlowEndpointType, opts conversationOptions) string {
 	//	{"nodes":[{"name":"Myriel","group":1}, ... ],"links":[{"source":1,"target":0,"value":1},...]}
 
-	nodes := []string{}
-	links := []string{}
+	sort.Sort(sortByUUID(flows))
 
-	pathMap := make(map[string]int)
-	layerMap := make(map[string]int)
+	// pre and post NAT endpoints are collapsed into the same conversation
+	var translations map[string]string
+	if f.NATCollapse && EndpointType == flow.FlowEndpointType_IPV4 {
+		translations = natTranslations(flows)
+	}
 
-	for _, f := range f.FlowTable.GetFlows() {
+	var paths []string
+	pathSeen := make(map[string]bool)
+	nodePath := make(map[string]string)
+	nodeASN := make(map[string]string)
+	collapsed := make(map[[2]string]*conversationLink)
+	links := []*conversationLink{}
+
+	for _, f := range flows {
 		layerFlow := f.GetStatistics().GetEndpointsType(EndpointType)
 		if layerFlow == nil {
 			continue
 		}
 
-		if _, found := pathMap[f.LayersPath]; found {
-			pathMap[f.LayersPath] = len(pathMap)
+		if !pathSeen[f.LayersPath] {
+			pathSeen[f.LayersPath] = true
+			paths = append(paths, f.LayersPath)
 		}
 
 		AB := layerFlow.AB.Value
 		BA := layerFlow.BA.Value
 
-		if _, found := layerMap[AB]; !found {
-			layerMap[AB] = len(layerMap)
-			nodes = append(nodes, fmt.Sprintf(`{"name":"%s","group":%d}`, AB, pathMap[f.LayersPath]))
+		translated := false
+		if original, ok := translations[AB]; ok {
+			AB, translated = original, true
 		}
-		if _, found := layerMap[BA]; !found {
-			layerMap[BA] = len(layerMap)
-			nodes = append(nodes, fmt.Sprintf(`{"name":"%s","group":%d}`, BA, pathMap[f.LayersPath]))
+		if original, ok := translations[BA]; ok {
+			BA, translated = original, true
+		}
+
+		if _, found := nodePath[AB]; !found {
+			nodePath[AB] = f.LayersPath
+		}
+		if _, found := nodePath[BA]; !found {
+			nodePath[BA] = f.LayersPath
+		}
+		if asn, ok := f.Attributes[flow.FlowAttributeASNA]; ok && nodeASN[AB] == "" {
+			nodeASN[AB] = asn
+		}
+		if asn, ok := f.Attributes[flow.FlowAttributeASNB]; ok && nodeASN[BA] == "" {
+			nodeASN[BA] = asn
+		}
+
+		// links go from the client to the server when roles are known
+		link := &conversationLink{
+			source:     AB,
+			target:     BA,
+			value:      layerFlow.AB.Bytes + layerFlow.BA.Bytes,
+			translated: translated,
+			forward:    layerFlow.AB.Packets,
+			backward:   layerFlow.BA.Packets,
+			tags:       f.GetTags(),
+		}
+		switch {
+		case f.A_Role == flow.FlowRoleClient && f.B_Role == flow.FlowRoleServer:
+			link.directed = true
+		case f.A_Role == flow.FlowRoleServer && f.B_Role == flow.FlowRoleClient:
+			link.source, link.target, link.directed = link.target, link.source, true
+			link.forward, link.backward = link.backward, link.forward
+		}
+
+		if translations != nil {
+			key := [2]string{link.source, link.target}
+			if existing, found := collapsed[key]; found && (translated || existing.translated) {
+				existing.value += link.value
+				existing.forward += link.forward
+				existing.backward += link.backward
+				existing.shareTags(link.tags)
+				continue
+			}
+			collapsed[key] = link
 		}
 
-		link := fmt.Sprintf(`{"source":%d,"target":%d,"value":%d}`, layerMap[AB], layerMap[BA], layerFlow.AB.Bytes+layerFlow.BA.Bytes)
 		links = append(links, link)
 	}
 
-	return fmt.Sprintf(`{"nodes":[%s], "links":[%s]}`, strings.Join(nodes, ","), strings.Join(links, ","))
-}
+	if opts.asymmetricOnly {
+		asymmetric := links[:0]
+		for _, link := range links {
+			if !link.symmetric() {
+				asymmetric = append(asymmetric, link)
+			}
+		}
+		links = asymmetric
 
-func (f *FlowApi) conversationLayer(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
-	vars := mux.Vars(&r.Request)
-	layer := vars["layer"]
+		endpoints := make(map[string]string)
+		for _, link := range links {
+			endpoints[link.source] = nodePath[link.source]
+			endpoints[link.target] = nodePath[link.target]
+		}
+		nodePath = endpoints
+	}
+
+	sort.Strings(paths)
+	pathIndex := make(map[string]int)
+	for i, path := range paths {
+		pathIndex[path] = i
+	}
+
+	names := make([]string, 0, len(nodePath))
+	for name := range nodePath {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	conversation := conversationJSON{
+		Nodes: make([]conversationJSONNode, len(names)),
+		Links: make([]conversationJSONLink, len(links)),
+	}
+
+	var asnIndex map[string]int
+	if opts.groupByASN {
+		var asns []string
+		asnIndex = make(map[string]int)
+		for _, asn := range nodeASN {
+			if _, found := asnIndex[asn]; !found {
+				asnIndex[asn] = 0
+				asns = append(asns, asn)
+			}
+		}
+		sort.Strings(asns)
+		for i, asn := range asns {
+			asnIndex[asn] = i + 1
+		}
+	}
+
+	nodeIndex := make(map[string]int)
+	for i, name := range names {
+		nodeIndex[name] = i
+		conversation.Nodes[i] = conversationJSONNode{Name: name, Group: pathIndex[nodePath[name]], ASN: nodeASN[name]}
+		if opts.groupByASN {
+			conversation.Nodes[i].Group = asnIndex[nodeASN[name]]
+		}
+	}
+
+	for i, link := range links {
+		conversation.Links[i] = conversationJSONLink{
+			Source:   nodeIndex[link.source],
+			Target:   nodeIndex[link.target],
+			Value:    link.value,
+			Directed: link.directed,
+			Tags:     link.tags,
+		}
+		if opts.symmetry || opts.asymmetricOnly {
+			symmetric := link.symmetric()
+			conversation.Links[i].Symmetric = &symmetric
+		}
+	}
+	sort.Sort(sortByEndpoints(conversation.Links))
 
+	b, err := json.Marshal(conversation)
+	if err != nil {
+		logging.GetLogger().Errorf("Unable to marshal the conversation: %s", err.Error())
+		return `{"nodes":[],"links":[]}`
+	}
+	return string(b)
+}
+
+func layerEndpointType(layer string) flow.FlowEndpointType {
 	ltype := flow.FlowEndpointType_ETHERNET
 	switch layer {
 	case "ethernet":
@@ -129,7 +868,283 @@ func (f *FlowApi) conversationLayer(w http.ResponseWriter, r *auth.Authenticated
 	case "sctp":
 		ltype = flow.FlowEndpointType_SCTPPORT
 	}
-	f.serveDataIndex(w, r, f.jsonFlowConversationEthernetPath(ltype))
+	return ltype
+}
+
+// legacyConversationLink is a link of a conversation as served to the
+// clients of the API version 1, not knowing the directions
+type legacyConversationLink struct {
+	Source int    `json:"source"`
+	Target int    `json:"target"`
+	Value  uint64 `json:"value"`
+}
+
+// legacyConversation returns the conversation in the format of the API
+// version 1
+func legacyConversation(message string) string {
+	var conversation conversationJSON
+	if err := json.Unmarshal([]byte(message), &conversation); err != nil {
+		return message
+	}
+
+	legacy := struct {
+		Nodes []conversationJSONNode   `json:"nodes"`
+		Links []legacyConversationLink `json:"links"`
+	}{
+		Nodes: conversation.Nodes,
+		Links: make([]legacyConversationLink, len(conversation.Links)),
+	}
+	for i, link := range conversation.Links {
+		legacy.Links[i] = legacyConversationLink{Source: link.Source, Target: link.Target, Value: link.Value}
+	}
+
+	b, err := json.Marshal(legacy)
+	if err != nil {
+		return message
+	}
+	return string(b)
+}
+
+func (f *FlowApi) conversationLayer(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
+	vars := mux.Vars(&r.Request)
+
+	query := r.URL.Query()
+
+	// the nodes are grouped by layers path or by ASN with ?group=asn, the
+	// symmetry annotation of the links being set by ?symmetry=true|false
+	opts := conversationOptions{
+		groupByASN:     query.Get("group") == "asn",
+		symmetry:       f.Symmetry,
+		asymmetricOnly: query.Get("asymmetric") == "true",
+	}
+	if symmetry := query.Get("symmetry"); symmetry != "" {
+		opts.symmetry = symmetry == "true"
+	}
+
+	// the flows seen between ?from and ?to, unix timestamps
+	var ok bool
+	if opts.timeRange, ok = timeRangeFromRequest(w, r); !ok {
+		return
+	}
+
+	conversation := f.jsonFlowConversation(layerEndpointType(vars["layer"]), opts)
+	if shttp.ClientAPIMajor(&r.Request) < shttp.APIMajor() {
+		conversation = legacyConversation(conversation)
+	}
+	f.serveDataIndex(w, r, conversation)
+}
+
+// conversationPort is the traffic of a conversation carried by a port
+type conversationPort struct {
+	Protocol string
+	Port     string
+	Flows    int
+	Bytes    uint64
+	Packets  uint64
+}
+
+type conversationPorts struct {
+	A       string
+	B       string
+	Flows   int
+	Bytes   uint64
+	Packets uint64
+	Ports   []conversationPort
+}
+
+type sortByTraffic []conversationPort
+
+func (s sortByTraffic) Len() int {
+	return len(s)
+}
+
+func (s sortByTraffic) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s sortByTraffic) Less(i, j int) bool {
+	if s[i].Bytes != s[j].Bytes {
+		return s[i].Bytes > s[j].Bytes
+	}
+	if s[i].Protocol != s[j].Protocol {
+		return s[i].Protocol < s[j].Protocol
+	}
+	return s[i].Port < s[j].Port
+}
+
+var transportEndpointTypes = map[flow.FlowEndpointType]string{
+	flow.FlowEndpointType_TCPPORT:  "tcp",
+	flow.FlowEndpointType_UDPPORT:  "udp",
+	flow.FlowEndpointType_SCTPPORT: "sctp",
+}
+
+// flowPort returns the port carrying the flow traffic, the server port if
+// the roles are known, the lowest one otherwise
+func flowPort(f *flow.Flow) (string, string) {
+	for _, ep := range f.GetStatistics().Endpoints {
+		protocol, ok := transportEndpointTypes[ep.Type]
+		if !ok {
+			continue
+		}
+
+		if server := f.GetServerEndpoint(ep.Type); server != nil {
+			return protocol, server.Value
+		}
+
+		a, errA := strconv.Atoi(ep.AB.Value)
+		b, errB := strconv.Atoi(ep.BA.Value)
+		if errA == nil && errB == nil && b < a {
+			return protocol, ep.BA.Value
+		}
+		return protocol, ep.AB.Value
+	}
+	return "", ""
+}
+
+// conversationPorts breaks down by port the traffic between the endpoints a
+// and b of the given type, in both directions. The top ports are returned
+// along with, if limited, the remaining traffic as an empty port.
+func (f *FlowApi) conversationPorts(EndpointType flow.FlowEndpointType, a string, b string, top int) *conversationPorts {
+	flows := f.FlowTable.Snapshot()
+
+	var translations map[string]string
+	if f.NATCollapse && EndpointType == flow.FlowEndpointType_IPV4 {
+		translations = natTranslations(flows)
+	}
+
+	conversation := &conversationPorts{A: a, B: b, Ports: []conversationPort{}}
+	ports := make(map[[2]string]*conversationPort)
+
+	for _, fl := range flows {
+		layerFlow := fl.GetStatistics().GetEndpointsType(EndpointType)
+		if layerFlow == nil {
+			continue
+		}
+
+		AB, BA := layerFlow.AB.Value, layerFlow.BA.Value
+		if original, ok := translations[AB]; ok {
+			AB = original
+		}
+		if original, ok := translations[BA]; ok {
+			BA = original
+		}
+		if !(AB == a && BA == b) && !(AB == b && BA == a) {
+			continue
+		}
+
+		flowBytes := layerFlow.AB.Bytes + layerFlow.BA.Bytes
+		flowPackets := layerFlow.AB.Packets + layerFlow.BA.Packets
+
+		conversation.Flows++
+		conversation.Bytes += flowBytes
+		conversation.Packets += flowPackets
+
+		protocol, port := flowPort(fl)
+		key := [2]string{protocol, port}
+		if _, ok := ports[key]; !ok {
+			ports[key] = &conversationPort{Protocol: protocol, Port: port}
+		}
+		ports[key].Flows++
+		ports[key].Bytes += flowBytes
+		ports[key].Packets += flowPackets
+	}
+
+	for _, port := range ports {
+		conversation.Ports = append(conversation.Ports, *port)
+	}
+	sort.Sort(sortByTraffic(conversation.Ports))
+
+	if top > 0 && len(conversation.Ports) > top {
+		others := conversationPort{}
+		for _, port := range conversation.Ports[top:] {
+			others.Flows += port.Flows
+			others.Bytes += port.Bytes
+			others.Packets += port.Packets
+		}
+		conversation.Ports = append(conversation.Ports[:top], others)
+	}
+
+	return conversation
+}
+
+func (f *FlowApi) conversationPortsLayer(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
+	vars := mux.Vars(&r.Request)
+	query := r.URL.Query()
+
+	a, b := query.Get("a"), query.Get("b")
+	if a == "" || b == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("The conversation endpoints a and b are expected"))
+		return
+	}
+
+	top := 0
+	if value := query.Get("top"); value != "" {
+		var err error
+		if top, err = strconv.Atoi(value); err != nil || top < 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("Invalid top value: " + value))
+			return
+		}
+	}
+
+	data, err := json.Marshal(f.conversationPorts(layerEndpointType(vars["layer"]), a, b, top))
+	if err != nil {
+		panic(err)
+	}
+	f.serveDataIndex(w, r, string(data))
+}
+
+type topServer struct {
+	Server      string
+	Connections int
+}
+
+type sortByConnections []topServer
+
+func (s sortByConnections) Len() int {
+	return len(s)
+}
+
+func (s sortByConnections) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s sortByConnections) Less(i, j int) bool {
+	if s[i].Connections == s[j].Connections {
+		return s[i].Server < s[j].Server
+	}
+	return s[i].Connections > s[j].Connections
+}
+
+// topServers groups the flows by server endpoint, counting the inbound
+// connections of each server. Flows with unknown roles are ignored.
+func (f *FlowApi) topServers(EndpointType flow.FlowEndpointType) []topServer {
+	servers := make(map[string]int)
+	for _, fl := range f.FlowTable.Snapshot() {
+		if ep := fl.GetServerEndpoint(EndpointType); ep != nil {
+			servers[ep.Value]++
+		}
+	}
+
+	top := make([]topServer, 0, len(servers))
+	for server, connections := range servers {
+		top = append(top, topServer{Server: server, Connections: connections})
+	}
+	sort.Sort(sortByConnections(top))
+
+	return top
+}
+
+func (f *FlowApi) topServersLayer(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
+	vars := mux.Vars(&r.Request)
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(f.topServers(layerEndpointType(vars["layer"]))); err != nil {
+		panic(err)
+	}
 }
 
 type discoType int
@@ -137,36 +1152,48 @@ type discoType int
 const (
 	bytes discoType = 1 + iota
 	packets
+	flowCount
 )
 
+var discoTypes = map[string]discoType{
+	"bytes":   bytes,
+	"packets": packets,
+	"flows":   flowCount,
+}
+
 type discoNode struct {
 	name     string
 	size     uint64
 	children map[string]*discoNode
 }
 
-func (d *discoNode) marshalJSON() ([]byte, error) {
-	str := "{"
-	str += fmt.Sprintf(`"name":"%s",`, d.name)
-	if d.size > 0 {
-		str += fmt.Sprintf(`"size": %d,`, d.size)
-	}
-	str += fmt.Sprintf(`"children": [`)
-	idx := 0
-	for _, child := range d.children {
-		bytes, err := child.marshalJSON()
-		if err != nil {
-			return []byte(str), err
-		}
-		str += string(bytes)
-		if idx != len(d.children)-1 {
-			str += ","
-		}
-		idx++
+type discoJSONNode struct {
+	Name     string          `json:"name"`
+	Size     uint64          `json:"size,omitempty"`
+	Children []discoJSONNode `json:"children"`
+}
+
+// jsonNode returns the node with its children sorted by name
+func (d *discoNode) jsonNode() discoJSONNode {
+	names := make([]string, 0, len(d.children))
+	for name := range d.children {
+		names = append(names, name)
 	}
-	str += "]"
-	str += "}"
-	return []byte(str), nil
+	sort.Strings(names)
+
+	node := discoJSONNode{
+		Name:     d.name,
+		Size:     d.size,
+		Children: make([]discoJSONNode, len(names)),
+	}
+	for i, name := range names {
+		node.Children[i] = d.children[name].jsonNode()
+	}
+	return node
+}
+
+func (d *discoNode) marshalJSON() ([]byte, error) {
+	return json.Marshal(d.jsonNode())
 }
 
 func newDiscoNode() *discoNode {
@@ -175,70 +1202,119 @@ func newDiscoNode() *discoNode {
 	}
 }
 
-func (f *FlowApi) jsonFlowDiscovery(DiscoType discoType) string {
-	// {"name":"root","children":[{"name":"Ethernet","children":[{"name":"IPv4","children":
-	//		[{"name":"UDP","children":[{"name":"Payload","size":360,"children":[]}]},
-	//     {"name":"TCP","children":[{"name":"Payload","size":240,"children":[]}]}]}]}]}
+// discoveryPath returns the position of the flow in the hierarchy, either
+// given by the values of the fields or by the layers by default
+func discoveryPath(f *flow.Flow, fields []string) []string {
+	if len(fields) == 0 {
+		return strings.Split(f.LayersPath, "/")
+	}
 
-	pathMap := make(map[string]flow.FlowEndpointStatistics)
+	path := make([]string, len(fields))
+	for i, field := range fields {
+		if path[i] = f.GetFilterValue(field); path[i] == "" {
+			path[i] = "unknown"
+		}
+	}
+	return path
+}
+
+// flowDiscovery returns the hierarchy of the flows of the table
+func (f *FlowApi) flowDiscovery(DiscoType discoType, fields []string) *discoNode {
+	return discoverFlows(f.FlowTable.Snapshot(), DiscoType, fields)
+}
 
-	for _, f := range f.FlowTable.GetFlows() {
+// discoverFlows returns the hierarchy of the flows, the leaves holding the
+// bytes, the packets or the number of their flows
+func discoverFlows(flows []*flow.Flow, DiscoType discoType, fields []string) *discoNode {
+	root := newDiscoNode()
+	root.name = "root"
+
+	for _, f := range flows {
 		eth := f.GetStatistics().GetEndpointsType(flow.FlowEndpointType_ETHERNET)
 		if eth == nil {
 			continue
 		}
 
-		p, _ := pathMap[f.LayersPath]
-		p.Bytes += eth.AB.Bytes
-		p.Bytes += eth.BA.Bytes
-		p.Packets += eth.AB.Packets
-		p.Packets += eth.BA.Packets
-		pathMap[f.LayersPath] = p
-	}
-
-	root := newDiscoNode()
-	root.name = "root"
-	for path, stat := range pathMap {
 		node := root
-		layers := strings.Split(path, "/")
-		for i, layer := range layers {
-			l, found := node.children[layer]
+		for _, name := range discoveryPath(f, fields) {
+			l, found := node.children[name]
 			if !found {
-				node.children[layer] = newDiscoNode()
-				l = node.children[layer]
-				l.name = layer
-			}
-			if len(layers)-1 == i {
-				switch DiscoType {
-				case bytes:
-					l.size = stat.Bytes
-				case packets:
-					l.size = stat.Packets
-				}
+				l = newDiscoNode()
+				l.name = name
+				node.children[name] = l
 			}
 			node = l
 		}
+
+		switch DiscoType {
+		case bytes:
+			node.size += eth.AB.Bytes + eth.BA.Bytes
+		case packets:
+			node.size += eth.AB.Packets + eth.BA.Packets
+		case flowCount:
+			node.size++
+		}
 	}
 
-	bytes, err := root.marshalJSON()
-	if err != nil {
-		logging.GetLogger().Fatal(err)
+	return root
+}
+
+func (f *FlowApi) jsonFlowDiscovery(DiscoType discoType, fields []string) string {
+	return f.jsonFlowDiscoveryInRange(DiscoType, fields, flowTimeRange{})
+}
+
+// jsonFlowDiscoveryInRange returns the hierarchy of the flows seen in the
+// time range
+func (f *FlowApi) jsonFlowDiscoveryInRange(DiscoType discoType, fields []string, timeRange flowTimeRange) string {
+	// {"name":"root","children":[{"name":"Ethernet","children":[{"name":"IPv4","children":
+	//		[{"name":"UDP","children":[{"name":"Payload","size":360,"children":[]}]},
+	//     {"name":"TCP","children":[{"name":"Payload","size":240,"children":[]}]}]}]}]}
+
+	// the ranges and the fields, given freely by the requests, aren't cached
+	var key string
+	if timeRange == (flowTimeRange{}) && len(fields) == 0 {
+		key = fmt.Sprintf("discovery/%d", DiscoType)
 	}
-	return string(bytes)
+	return f.aggregate(key, func(flows []*flow.Flow) string {
+		bytes, err := discoverFlows(timeRange.filter(flows), DiscoType, fields).marshalJSON()
+		if err != nil {
+			logging.GetLogger().Fatal(err)
+		}
+		return string(bytes)
+	})
 }
 
 func (f *FlowApi) discoveryType(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
 	vars := mux.Vars(&r.Request)
-	discoType := vars["type"]
-	dtype := bytes
-	switch discoType {
-	case "bytes":
-		dtype = bytes
-	case "packets":
-		dtype = packets
+	dtype, ok := discoTypes[vars["type"]]
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("Unknown discovery type: " + vars["type"]))
+		return
+	}
+
+	// ordered list of the fields giving the hierarchy, ex: IPV4.A,TCPPORT.B
+	var fields []string
+	if path := r.URL.Query().Get("path"); path != "" {
+		fields = strings.Split(path, ",")
+		for _, field := range fields {
+			if !flow.IsFilterKey(field) {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte("Unknown path field: " + field))
+				return
+			}
+		}
+	}
+
+	// the flows seen between ?from and ?to, unix timestamps
+	timeRange, ok := timeRangeFromRequest(w, r)
+	if !ok {
+		return
 	}
-	f.serveDataIndex(w, r, f.jsonFlowDiscovery(dtype))
+
+	f.serveDataIndex(w, r, f.jsonFlowDiscoveryInRange(dtype, fields, timeRange))
 }
+
 func (f *FlowApi) registerEndpoints(r *shttp.Server) {
 	routes := []shttp.Route{
 		{
@@ -247,12 +1323,54 @@ func (f *FlowApi) registerEndpoints(r *shttp.Server) {
 			"/api/flow/search",
 			f.flowSearch,
 		},
+		{
+			"FlowFeed",
+			"GET",
+			"/api/flow/feed",
+			f.flowFeed,
+		},
+		{
+			"FlowAttributes",
+			"GET",
+			"/api/flow/attributes/{ref}",
+			f.flowAttributes,
+		},
+		{
+			"FlowKeys",
+			"GET",
+			"/api/flow/keys",
+			f.flowKeys,
+		},
+		{
+			"FlowSchema",
+			"GET",
+			"/api/flow/schema",
+			f.flowSchema,
+		},
+		{
+			"FlowCount",
+			"GET",
+			"/api/flow/count",
+			f.flowCount,
+		},
 		{
 			"ConversationLayer",
 			"GET",
 			"/api/flow/conversation/{layer}",
 			f.conversationLayer,
 		},
+		{
+			"ConversationPorts",
+			"GET",
+			"/api/flow/conversation/{layer}/ports",
+			f.conversationPortsLayer,
+		},
+		{
+			"TopServers",
+			"GET",
+			"/api/flow/servers/{layer}",
+			f.topServersLayer,
+		},
 		{
 			"Discovery",
 			"GET",
@@ -261,15 +1379,33 @@ func (f *FlowApi) registerEndpoints(r *shttp.Server) {
 		},
 	}
 
-	r.RegisterRoutes(routes)
+	r.RegisterRoutes(shttp.RequireRoutesCredentials(f.Credentials, routes))
 }
 
-func RegisterFlowApi(s string, f *flow.Table, st storage.Storage, r *shttp.Server) {
+func RegisterFlowApi(s string, f *flow.Table, st storage.Storage, r *shttp.Server) *FlowApi {
 	fa := &FlowApi{
-		Service:   s,
-		FlowTable: f,
-		Storage:   st,
+		Service:     s,
+		FlowTable:   f,
+		Storage:     st,
+		NATCollapse: config.GetConfig().GetBool("analyzer.conversation_nat_collapse"),
+		Symmetry:    config.GetConfig().GetBool("analyzer.conversation_symmetry"),
+		Aggregates:  NewFlowAggregatesFromConfig(f),
+		Credentials: shttp.AuthenticationOptsFromConfig(s),
+	}
+
+	if limit := config.GetConfig().GetInt("analyzer.flow_search.default_limit"); limit > 0 {
+		fa.DefaultLimit = limit
+	}
+
+	switch mode := config.GetConfig().GetString("analyzer.flow_search.unknown_filters"); mode {
+	case "lenient":
+		fa.LenientFilters = true
+	case "strict":
+	default:
+		logging.GetLogger().Errorf("Unknown mode of analyzer.flow_search.unknown_filters: %s, strict used", mode)
 	}
 
 	fa.registerEndpoints(r)
+
+	return fa
 }
@@ -0,0 +1,249 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package api
+
+import (
+	"sync"
+	"time"
+
+	"github.com/redhat-cip/skydive/config"
+	"github.com/redhat-cip/skydive/flow"
+)
+
+// aggregateIdleIntervals is the number of precompute intervals, at least
+// MaxAge, after which an aggregation not requested anymore stops being
+// precomputed
+const aggregateIdleIntervals = 10
+
+// defaultMaxAggregates is the default number of aggregations kept
+const defaultMaxAggregates = 256
+
+type aggregateFunc func(flows []*flow.Flow) string
+
+// flowAggregate is the last result of an aggregation of the flow table
+type flowAggregate struct {
+	compute   aggregateFunc
+	result    string
+	computed  time.Time
+	requested time.Time
+	// closed once the computation in progress is done
+	computing chan struct{}
+}
+
+// FlowAggregates computes the aggregations of the flow table, the
+// conversations and the discovery, on a snapshot of the table so that the
+// table lock is held only while copying the flows. The results are served
+// while younger than MaxAge, the concurrent requests of the same aggregation
+// sharing a single computation. With an Interval, the aggregations requested
+// lately are recomputed in the background so that the requests are served
+// the precomputed results. The aggregations not requested lately are
+// forgotten, at most MaxEntries being kept, the least recently requested
+// ones being forgotten first.
+type FlowAggregates struct {
+	sync.Mutex
+	Table      *flow.Table
+	MaxAge     time.Duration
+	Interval   time.Duration
+	MaxEntries int
+	aggregates map[string]*flowAggregate
+	running    bool
+	quit       chan bool
+	wg         sync.WaitGroup
+}
+
+// Get returns the result of the aggregation identified by key, computed
+// if none is younger than MaxAge
+func (a *FlowAggregates) Get(key string, compute aggregateFunc) string {
+	now := time.Now()
+
+	a.Lock()
+	agg, ok := a.aggregates[key]
+	if !ok {
+		a.evict(now, 1)
+		agg = &flowAggregate{compute: compute}
+		a.aggregates[key] = agg
+	}
+	agg.requested = now
+
+	for {
+		if !agg.computed.IsZero() && now.Sub(agg.computed) <= a.MaxAge {
+			result := agg.result
+			a.Unlock()
+			return result
+		}
+		if agg.computing == nil {
+			break
+		}
+
+		// wait for the computation in progress
+		computing := agg.computing
+		a.Unlock()
+		<-computing
+		a.Lock()
+	}
+	computing := agg.begin()
+	a.Unlock()
+
+	return a.compute(agg, a.Table.Snapshot(), computing)
+}
+
+// begin marks the computation of the aggregate as in progress, called with
+// the lock held
+func (agg *flowAggregate) begin() chan struct{} {
+	agg.computing = make(chan struct{})
+	return agg.computing
+}
+
+// compute runs the aggregation on the flows, the requests arriving
+// meanwhile waiting for its result
+func (a *FlowAggregates) compute(agg *flowAggregate, flows []*flow.Flow, computing chan struct{}) string {
+	start := time.Now()
+	result := agg.compute(flows)
+
+	a.Lock()
+	agg.result, agg.computed = result, start
+	agg.computing = nil
+	a.Unlock()
+	close(computing)
+
+	return result
+}
+
+// evict forgets the aggregations not requested lately and, to leave room
+// for the given number of new ones, the least recently requested ones above
+// MaxEntries. The aggregations being computed are kept. Called with the
+// lock held.
+func (a *FlowAggregates) evict(now time.Time, room int) {
+	idle := aggregateIdleIntervals * a.Interval
+	if idle < a.MaxAge {
+		idle = a.MaxAge
+	}
+	idleSince := now.Add(-idle)
+
+	for key, agg := range a.aggregates {
+		if agg.computing == nil && agg.requested.Before(idleSince) {
+			delete(a.aggregates, key)
+		}
+	}
+
+	for a.MaxEntries > 0 && len(a.aggregates)+room > a.MaxEntries {
+		var oldest string
+		var oldestAgg *flowAggregate
+		for key, agg := range a.aggregates {
+			if agg.computing == nil && (oldestAgg == nil || agg.requested.Before(oldestAgg.requested)) {
+				oldest, oldestAgg = key, agg
+			}
+		}
+		if oldestAgg == nil {
+			return
+		}
+		delete(a.aggregates, oldest)
+	}
+}
+
+// refresh recomputes the aggregations requested lately on a single
+// snapshot, forgetting the other ones
+func (a *FlowAggregates) refresh() {
+	a.Lock()
+	a.evict(time.Now(), 0)
+	refreshed := make(map[*flowAggregate]chan struct{})
+	for _, agg := range a.aggregates {
+		if agg.computing == nil {
+			refreshed[agg] = agg.begin()
+		}
+	}
+	a.Unlock()
+
+	if len(refreshed) == 0 {
+		return
+	}
+
+	flows := a.Table.Snapshot()
+	for agg, computing := range refreshed {
+		a.compute(agg, flows, computing)
+	}
+}
+
+func (a *FlowAggregates) run(quit chan bool) {
+	defer a.wg.Done()
+
+	ticker := time.NewTicker(a.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			a.refresh()
+		case <-quit:
+			return
+		}
+	}
+}
+
+// Start precomputes the aggregations every Interval, if set
+func (a *FlowAggregates) Start() {
+	if a.Interval <= 0 {
+		return
+	}
+
+	a.Lock()
+	a.running = true
+	a.quit = make(chan bool)
+	a.Unlock()
+
+	a.wg.Add(1)
+	go a.run(a.quit)
+}
+
+func (a *FlowAggregates) Stop() {
+	a.Lock()
+	if a.running {
+		close(a.quit)
+		a.running = false
+	}
+	a.Unlock()
+
+	a.wg.Wait()
+}
+
+func NewFlowAggregates(t *flow.Table, maxAge time.Duration, interval time.Duration) *FlowAggregates {
+	return &FlowAggregates{
+		Table:      t,
+		MaxAge:     maxAge,
+		Interval:   interval,
+		MaxEntries: defaultMaxAggregates,
+		aggregates: make(map[string]*flowAggregate),
+	}
+}
+
+// NewFlowAggregatesFromConfig returns the aggregates configured by
+// analyzer.flow_aggregates
+func NewFlowAggregatesFromConfig(t *flow.Table) *FlowAggregates {
+	cfg := config.GetConfig()
+	maxAge := time.Duration(cfg.GetInt("analyzer.flow_aggregates.max_staleness")) * time.Second
+	interval := time.Duration(cfg.GetInt("analyzer.flow_aggregates.precompute_interval")) * time.Second
+
+	aggregates := NewFlowAggregates(t, maxAge, interval)
+	aggregates.MaxEntries = cfg.GetInt("analyzer.flow_aggregates.max_entries")
+	return aggregates
+}
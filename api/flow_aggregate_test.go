@@ -0,0 +1,209 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package api
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/redhat-cip/skydive/flow"
+)
+
+func newLargeFlowTable(n int) *flow.Table {
+	flows := make([]*flow.Flow, n)
+	for i := range flows {
+		a := fmt.Sprintf("10.%d.%d.%d", i>>16&0xff, i>>8&0xff, i&0xff)
+		flows[i] = newNATTestFlow(fmt.Sprintf("flow-%d", i), a, fmt.Sprintf("192.168.0.%d", i%250), 100, nil)
+	}
+	return flow.NewTableFromFlows(flows)
+}
+
+func TestFlowAggregatesPrecomputed(t *testing.T) {
+	ft := newLargeFlowTable(20000)
+
+	// the conversation computed at each request
+	fa := &FlowApi{FlowTable: ft}
+	start := time.Now()
+	fa.jsonFlowConversationEthernetPath(flow.FlowEndpointType_IPV4)
+	computation := time.Since(start)
+
+	maxAge, interval := 4*computation+100*time.Millisecond, 20*time.Millisecond
+	fa.Aggregates = NewFlowAggregates(ft, maxAge, interval)
+	fa.Aggregates.Start()
+	defer fa.Aggregates.Stop()
+
+	// the first request computes the conversation, the next ones being
+	// served the precomputed one
+	fa.jsonFlowConversationEthernetPath(flow.FlowEndpointType_IPV4)
+
+	// the table keeps being updated meanwhile
+	stop := make(chan bool)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			ft.Update([]*flow.Flow{newNATTestFlow(fmt.Sprintf("flow-%d", i%20000), "10.0.0.1", "192.168.0.1", uint64(i), nil)})
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	for i := 0; i < 20; i++ {
+		start := time.Now()
+		fa.jsonFlowConversationEthernetPath(flow.FlowEndpointType_IPV4)
+		if elapsed := time.Since(start); elapsed > computation/4 && elapsed > 5*time.Millisecond {
+			t.Errorf("Precomputed conversation served in %s, the computation taking %s", elapsed, computation)
+		}
+		time.Sleep(interval / 2)
+	}
+
+	close(stop)
+	wg.Wait()
+
+	// a new flow shows up once the conversation is precomputed again
+	ft.Update([]*flow.Flow{newNATTestFlow("new", "172.16.0.1", "192.168.0.1", 100, nil)})
+	updated := time.Now()
+
+	for !strings.Contains(fa.jsonFlowConversationEthernetPath(flow.FlowEndpointType_IPV4), "172.16.0.1") {
+		if time.Since(updated) > maxAge+computation {
+			t.Fatalf("New flow not in the conversation after %s", time.Since(updated))
+		}
+		time.Sleep(interval / 2)
+	}
+
+	fa.Aggregates.Lock()
+	for key, agg := range fa.Aggregates.aggregates {
+		if age := time.Since(agg.computed); age > maxAge {
+			t.Errorf("Aggregate %s computed %s ago", key, age)
+		}
+	}
+	fa.Aggregates.Unlock()
+}
+
+func TestFlowAggregatesSharedComputation(t *testing.T) {
+	ft := newLargeFlowTable(10)
+	aggregates := NewFlowAggregates(ft, 0, 0)
+
+	var computations int32
+	release := make(chan bool)
+	compute := func(flows []*flow.Flow) string {
+		atomic.AddInt32(&computations, 1)
+		<-release
+		return fmt.Sprintf("%d", len(flows))
+	}
+
+	results := make(chan string, 5)
+	go func() { results <- aggregates.Get("key", compute) }()
+	for atomic.LoadInt32(&computations) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// the requests arriving during the computation wait for the next one
+	for i := 0; i < 4; i++ {
+		go func() { results <- aggregates.Get("key", compute) }()
+	}
+	time.Sleep(50 * time.Millisecond)
+	release <- true
+	close(release)
+
+	for i := 0; i < 5; i++ {
+		if result := <-results; result != "10" {
+			t.Errorf("Wrong result: %s", result)
+		}
+	}
+	if n := atomic.LoadInt32(&computations); n != 2 {
+		t.Errorf("Expected the waiting requests to share a computation, got %d computations", n)
+	}
+}
+
+func TestFlowAggregatesEviction(t *testing.T) {
+	ft := newLargeFlowTable(10)
+	compute := func(flows []*flow.Flow) string {
+		return fmt.Sprintf("%d", len(flows))
+	}
+
+	keys := func(a *FlowAggregates) []string {
+		a.Lock()
+		defer a.Unlock()
+
+		var keys []string
+		for key := range a.aggregates {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		return keys
+	}
+
+	// the least recently requested aggregations are forgotten first
+	bounded := NewFlowAggregates(ft, time.Hour, 0)
+	bounded.MaxEntries = 3
+	for _, key := range []string{"a", "b", "c", "a", "d", "e"} {
+		bounded.Get(key, compute)
+		time.Sleep(time.Millisecond)
+	}
+	if k := keys(bounded); !reflect.DeepEqual(k, []string{"a", "d", "e"}) {
+		t.Errorf("Expected the aggregations requested lately to be kept, got %v", k)
+	}
+
+	// without precomputation, the aggregations older than MaxAge are
+	// forgotten on the next request
+	idle := NewFlowAggregates(ft, 10*time.Millisecond, 0)
+	idle.Get("a", compute)
+	time.Sleep(20 * time.Millisecond)
+	idle.Get("b", compute)
+	if k := keys(idle); !reflect.DeepEqual(k, []string{"b"}) {
+		t.Errorf("Expected the idle aggregation to be forgotten, got %v", k)
+	}
+}
+
+func TestFlowAggregatesNotCachedRequests(t *testing.T) {
+	ft := newLargeFlowTable(10)
+	fa := &FlowApi{FlowTable: ft, Aggregates: NewFlowAggregates(ft, time.Hour, 0)}
+
+	for i := int64(1); i <= 10; i++ {
+		fa.jsonFlowConversation(flow.FlowEndpointType_IPV4, conversationOptions{timeRange: flowTimeRange{From: i}})
+		fa.jsonFlowDiscoveryInRange(bytes, nil, flowTimeRange{To: i})
+	}
+	fa.jsonFlowDiscovery(bytes, []string{"IPV4.A"})
+
+	if entries := len(fa.Aggregates.aggregates); entries != 0 {
+		t.Errorf("Expected the ranged and custom aggregations not to be cached, got %d", entries)
+	}
+
+	fa.jsonFlowConversationEthernetPath(flow.FlowEndpointType_IPV4)
+	fa.jsonFlowDiscovery(bytes, nil)
+	if entries := len(fa.Aggregates.aggregates); entries != 2 {
+		t.Errorf("Expected the conversation and the discovery to be cached, got %d", entries)
+	}
+}
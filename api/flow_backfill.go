@@ -0,0 +1,522 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/abbot/go-http-auth"
+	"golang.org/x/net/context"
+
+	"github.com/redhat-cip/skydive/config"
+	"github.com/redhat-cip/skydive/flow"
+	shttp "github.com/redhat-cip/skydive/http"
+	"github.com/redhat-cip/skydive/logging"
+	"github.com/redhat-cip/skydive/storage"
+	"github.com/redhat-cip/skydive/topology/graph"
+)
+
+// number of backfill results kept for the status endpoint
+const maxBackfillResults = 20
+
+// ErrBackfillNodeNotFound is returned by a backfill of an unknown node
+var ErrBackfillNodeNotFound = errors.New("No node with this ID")
+
+// FlowBackfillRequest asks for the backfill of the stored flows of the
+// addresses of a node, DryRun only counting the flows to attribute
+type FlowBackfillRequest struct {
+	Node   string
+	DryRun bool
+}
+
+// FlowBackfillResult reports a backfill, Keys being the endpoints the
+// stored flows were searched for, as <type>=<address>
+type FlowBackfillResult struct {
+	Node     string
+	Keys     []string
+	Matched  int
+	Updated  int
+	DryRun   bool   `json:",omitempty"`
+	Error    string `json:",omitempty"`
+	Started  time.Time
+	Finished time.Time
+}
+
+// backfillKey is an address of a node, the only node having it
+type backfillKey struct {
+	endpointType string
+	value        string
+}
+
+func (k backfillKey) String() string {
+	return k.endpointType + "=" + k.value
+}
+
+// FlowBackfill attributes to the interfaces the stored flows of the last
+// Window which were stored before the graph knew about them. The flows of
+// the MAC and IPv4 addresses of a node, when no other node has them, get
+// their missing IfSrcNodeUUID or IfDstNodeUUID and the BackfilledAt
+// attribute, being stored again. The flows already attributed being left
+// untouched, a backfill can be run again safely.
+//
+// The nodes added or updated, as selected by Events, are backfilled in the
+// background when their addresses changed since their last backfill. At
+// most MaxFlows flows are updated by a backfill, at RateLimit flows per
+// second.
+type FlowBackfill struct {
+	graph.DefaultGraphListener
+	sync.RWMutex
+	Graph      *graph.Graph
+	Storage    storage.Storage
+	Window     time.Duration
+	MaxFlows   int
+	RateLimit  int
+	Events     []string
+	jobLock    sync.Mutex
+	queue      chan string
+	pending    map[string]bool
+	backfilled map[string]string
+	results    []*FlowBackfillResult
+	cancel     context.CancelFunc
+	wg         sync.WaitGroup
+}
+
+// backfillAddresses returns the addresses of a value of the IPV4 metadata,
+// without their prefix length
+func backfillAddresses(value interface{}) []string {
+	s, _ := value.(string)
+
+	var addresses []string
+	for _, cidr := range strings.Split(s, ",") {
+		if cidr = strings.TrimSpace(cidr); cidr != "" {
+			addresses = append(addresses, strings.SplitN(cidr, "/", 2)[0])
+		}
+	}
+	return addresses
+}
+
+// nodeKeys returns the addresses of the node no other node has, called
+// with the graph lock held
+func (b *FlowBackfill) nodeKeys(n *graph.Node) []backfillKey {
+	var keys []backfillKey
+
+	for _, field := range []string{"MAC", "ExtID.attached-mac"} {
+		mac, _ := n.Metadata()[field].(string)
+		if mac == "" {
+			continue
+		}
+		if nodes := b.Graph.LookupNodes(graph.Metadata{field: mac}); len(nodes) == 1 {
+			keys = append(keys, backfillKey{"ETHERNET", mac})
+		}
+	}
+
+	addresses := backfillAddresses(n.Metadata()["IPV4"])
+	if len(addresses) == 0 {
+		return keys
+	}
+
+	owners := make(map[string]int)
+	for _, node := range b.Graph.GetNodes() {
+		for _, address := range backfillAddresses(node.Metadata()["IPV4"]) {
+			owners[address]++
+		}
+	}
+	for _, address := range addresses {
+		if owners[address] == 1 {
+			keys = append(keys, backfillKey{"IPV4", address})
+		}
+	}
+
+	return keys
+}
+
+// signature identifies the addresses of a node, the node being backfilled
+// again once they changed
+func backfillSignature(n *graph.Node) string {
+	m := n.Metadata()
+	mac, _ := m["MAC"].(string)
+	attached, _ := m["ExtID.attached-mac"].(string)
+	ipv4, _ := m["IPV4"].(string)
+	return mac + "|" + attached + "|" + ipv4
+}
+
+func (b *FlowBackfill) search(ctx context.Context, filters storage.Filters) ([]*flow.Flow, error) {
+	if cs, ok := b.Storage.(storage.ContextSearcher); ok {
+		return cs.SearchFlowsContext(ctx, filters)
+	}
+	return b.Storage.SearchFlows(filters)
+}
+
+// match returns the stored flows of the key missing the attribution of the
+// node, setting it unless dryRun
+func (b *FlowBackfill) match(ctx context.Context, node string, key backfillKey, since int64, now time.Time, dryRun bool, matched map[string]*flow.Flow) error {
+	for _, side := range []string{"A", "B"} {
+		flows, err := b.search(ctx, storage.Filters{
+			key.endpointType + "." + side: key.value,
+			"Statistics.Last":             storage.Range{Gte: since},
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, f := range flows {
+			if b.MaxFlows > 0 && len(matched) >= b.MaxFlows {
+				return nil
+			}
+			if m, ok := matched[f.UUID]; ok {
+				f = m
+			}
+
+			field := &f.IfSrcNodeUUID
+			if side == "B" {
+				field = &f.IfDstNodeUUID
+			}
+			if *field != "" {
+				continue
+			}
+
+			matched[f.UUID] = f
+			if !dryRun {
+				*field = node
+				if f.Attributes == nil {
+					f.Attributes = make(map[string]string)
+				}
+				f.Attributes[flow.FlowAttributeBackfilledAt] = strconv.FormatInt(now.Unix(), 10)
+			}
+		}
+	}
+	return nil
+}
+
+// store stores the flows again, a bulk at a time, waiting between the
+// bulks to stay under the rate limit
+func (b *FlowBackfill) store(ctx context.Context, flows []*flow.Flow) (int, error) {
+	size := 100
+	if limiter, ok := b.Storage.(storage.BulkLimiter); ok && limiter.MaxBulkSize() > 0 {
+		size = limiter.MaxBulkSize()
+	}
+	if b.RateLimit > 0 && size > b.RateLimit {
+		size = b.RateLimit
+	}
+
+	stored := 0
+	for i, bulk := range storage.SplitBulks(flows, size) {
+		if i > 0 && b.RateLimit > 0 {
+			select {
+			case <-time.After(time.Duration(len(bulk)) * time.Second / time.Duration(b.RateLimit)):
+			case <-ctx.Done():
+				return stored, ctx.Err()
+			}
+		}
+
+		var err error
+		if acked, ok := b.Storage.(storage.AckedStorage); ok {
+			err = acked.StoreFlowsAcked(bulk)
+		} else {
+			err = b.Storage.StoreFlows(bulk)
+		}
+		if err != nil {
+			return stored, err
+		}
+		stored += len(bulk)
+	}
+	return stored, nil
+}
+
+func (b *FlowBackfill) backfill(ctx context.Context, node string, dryRun bool) (*FlowBackfillResult, error) {
+	b.Graph.RLock()
+	n := b.Graph.GetNode(graph.Identifier(node))
+	if n == nil {
+		b.Graph.RUnlock()
+		return nil, ErrBackfillNodeNotFound
+	}
+	keys := b.nodeKeys(n)
+	signature := backfillSignature(n)
+	b.Graph.RUnlock()
+
+	now := time.Now()
+	result := &FlowBackfillResult{Node: node, Keys: []string{}, DryRun: dryRun, Started: now}
+
+	matched := make(map[string]*flow.Flow)
+	var err error
+	for _, key := range keys {
+		result.Keys = append(result.Keys, key.String())
+		if err = b.match(ctx, node, key, now.Add(-b.Window).Unix(), now, dryRun, matched); err != nil {
+			break
+		}
+	}
+	result.Matched = len(matched)
+
+	if err == nil && !dryRun && len(matched) > 0 {
+		flows := make([]*flow.Flow, 0, len(matched))
+		for _, f := range matched {
+			flows = append(flows, f)
+		}
+		sort.Sort(sortByUUID(flows))
+		result.Updated, err = b.store(ctx, flows)
+	}
+
+	result.Finished = time.Now()
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	b.Lock()
+	if err == nil && !dryRun {
+		b.backfilled[node] = signature
+	}
+	b.results = append(b.results, result)
+	if len(b.results) > maxBackfillResults {
+		b.results = b.results[len(b.results)-maxBackfillResults:]
+	}
+	b.Unlock()
+
+	return result, err
+}
+
+// Backfill backfills the stored flows of the addresses of the node, one
+// backfill being run at a time
+func (b *FlowBackfill) Backfill(ctx context.Context, node string, dryRun bool) (*FlowBackfillResult, error) {
+	b.jobLock.Lock()
+	defer b.jobLock.Unlock()
+
+	result, err := b.backfill(ctx, node, dryRun)
+	if result != nil && !dryRun {
+		logging.GetLogger().Infof("Backfill of the flows of %s %v: %d flows updated out of %d", node, result.Keys, result.Updated, result.Matched)
+	}
+	return result, err
+}
+
+// Results returns the last backfills, the oldest first
+func (b *FlowBackfill) Results() []*FlowBackfillResult {
+	b.RLock()
+	defer b.RUnlock()
+
+	results := make([]*FlowBackfillResult, len(b.results))
+	for i, r := range b.results {
+		c := *r
+		results[i] = &c
+	}
+	return results
+}
+
+func (b *FlowBackfill) hasEvent(event string) bool {
+	for _, e := range b.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// enqueue schedules the backfill of the node if its addresses changed,
+// called with the graph lock held, never blocking the graph
+func (b *FlowBackfill) enqueue(n *graph.Node) {
+	signature := backfillSignature(n)
+	if signature == "||" {
+		return
+	}
+
+	b.Lock()
+	defer b.Unlock()
+
+	id := string(n.ID)
+	if b.cancel == nil || b.pending[id] || b.backfilled[id] == signature {
+		return
+	}
+
+	select {
+	case b.queue <- id:
+		b.pending[id] = true
+	default:
+		logging.GetLogger().Warningf("Backfill queue full, the flows of %s are not backfilled", id)
+	}
+}
+
+func (b *FlowBackfill) OnNodeAdded(n *graph.Node) {
+	if b.hasEvent("NodeAdded") {
+		b.enqueue(n)
+	}
+}
+
+func (b *FlowBackfill) OnNodeUpdated(n *graph.Node) {
+	if b.hasEvent("NodeUpdated") {
+		b.enqueue(n)
+	}
+}
+
+func (b *FlowBackfill) OnNodeDeleted(n *graph.Node) {
+	b.Lock()
+	delete(b.backfilled, string(n.ID))
+	b.Unlock()
+}
+
+func (b *FlowBackfill) run(ctx context.Context) {
+	defer b.wg.Done()
+
+	for {
+		select {
+		case node := <-b.queue:
+			b.Lock()
+			delete(b.pending, node)
+			b.Unlock()
+
+			if _, err := b.Backfill(ctx, node, false); err != nil && err != ErrBackfillNodeNotFound {
+				logging.GetLogger().Errorf("Backfill of the flows of %s failed: %s", node, err.Error())
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Start starts the backfills triggered by the graph events
+func (b *FlowBackfill) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	b.Lock()
+	b.cancel = cancel
+	b.Unlock()
+
+	b.wg.Add(1)
+	go b.run(ctx)
+
+	b.Graph.AddEventListener(b)
+}
+
+// Stop stops the backfills, cancelling the running one
+func (b *FlowBackfill) Stop() {
+	b.Graph.RemoveEventListener(b)
+
+	b.Lock()
+	if b.cancel != nil {
+		b.cancel()
+		b.cancel = nil
+	}
+	b.Unlock()
+
+	b.wg.Wait()
+}
+
+func NewFlowBackfill(g *graph.Graph, s storage.Storage, window time.Duration, maxFlows int, rateLimit int, events []string) *FlowBackfill {
+	return &FlowBackfill{
+		Graph:      g,
+		Storage:    s,
+		Window:     window,
+		MaxFlows:   maxFlows,
+		RateLimit:  rateLimit,
+		Events:     events,
+		queue:      make(chan string, 1000),
+		pending:    make(map[string]bool),
+		backfilled: make(map[string]string),
+	}
+}
+
+// NewFlowBackfillFromConfig returns the backfill configured by
+// analyzer.flow_backfill, nil without storage or when disabled
+func NewFlowBackfillFromConfig(g *graph.Graph, s storage.Storage) *FlowBackfill {
+	cfg := config.GetConfig()
+	if s == nil || !cfg.GetBool("analyzer.flow_backfill.enabled") {
+		return nil
+	}
+
+	return NewFlowBackfill(g, s, time.Duration(cfg.GetInt("analyzer.flow_backfill.window"))*time.Second,
+		cfg.GetInt("analyzer.flow_backfill.max_flows"), cfg.GetInt("analyzer.flow_backfill.rate_limit"),
+		cfg.GetStringSlice("analyzer.flow_backfill.events"))
+}
+
+type FlowBackfillApi struct {
+	Backfill *FlowBackfill
+}
+
+func writeBackfillJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logging.GetLogger().Criticalf("Failed to send flow backfill: %s", err.Error())
+	}
+}
+
+func (a *FlowBackfillApi) backfill(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
+	var request FlowBackfillRequest
+	data, _ := ioutil.ReadAll(r.Body)
+	if err := json.Unmarshal(data, &request); err != nil || request.Node == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("A Node is required"))
+		return
+	}
+
+	ctx := shttp.RequestContext(&r.Request)
+	result, err := a.Backfill.Backfill(ctx, request.Node, request.DryRun)
+	if err == ErrBackfillNodeNotFound {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	if !request.DryRun {
+		logging.GetJournal(logging.JournalAudit).RecordContext(ctx, "Backfill of the flows of %s by %s: %d flows updated out of %d", request.Node, r.Username, result.Updated, result.Matched)
+	}
+
+	status := http.StatusOK
+	if err != nil {
+		status = http.StatusInternalServerError
+	}
+	writeBackfillJSON(w, status, result)
+}
+
+func (a *FlowBackfillApi) results(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
+	writeBackfillJSON(w, http.StatusOK, a.Backfill.Results())
+}
+
+func (a *FlowBackfillApi) registerEndpoints(r *shttp.Server) {
+	r.RegisterAdminRoutes([]shttp.Route{
+		{
+			"FlowBackfill",
+			"POST",
+			"/api/admin/flow/backfill",
+			a.backfill,
+		},
+		{
+			"FlowBackfillResults",
+			"GET",
+			"/api/admin/flow/backfill",
+			a.results,
+		},
+	})
+}
+
+// RegisterFlowBackfillApi registers, on the admin listener, the endpoints
+// running a backfill and reporting the last ones
+func RegisterFlowBackfillApi(backfill *FlowBackfill, r *shttp.Server) {
+	a := &FlowBackfillApi{Backfill: backfill}
+	a.registerEndpoints(r)
+}
@@ -0,0 +1,322 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package api
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/abbot/go-http-auth"
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+
+	"github.com/redhat-cip/skydive/flow"
+	shttp "github.com/redhat-cip/skydive/http"
+	"github.com/redhat-cip/skydive/logging"
+	"github.com/redhat-cip/skydive/storage"
+	"github.com/redhat-cip/skydive/topology/graph"
+)
+
+// upsertStorage stores the flows by UUID, counting the stores of each
+type upsertStorage struct {
+	sync.Mutex
+	fakeStorage
+	stores map[string]int
+}
+
+func (s *upsertStorage) storeCount() int {
+	s.Lock()
+	defer s.Unlock()
+	return len(s.stores)
+}
+
+func (s *upsertStorage) StoreFlows(flows []*flow.Flow) error {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, f := range flows {
+		s.stores[f.UUID]++
+		f = proto.Clone(f).(*flow.Flow)
+
+		replaced := false
+		for i, stored := range s.flows {
+			if stored.UUID == f.UUID {
+				s.flows[i], replaced = f, true
+			}
+		}
+		if !replaced {
+			s.flows = append(s.flows, f)
+		}
+	}
+	return nil
+}
+
+func (s *upsertStorage) SearchFlows(filters storage.Filters) ([]*flow.Flow, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	var flows []*flow.Flow
+	for _, f := range s.flows {
+		match := true
+		for k, v := range filters {
+			if r, ok := v.(storage.Range); ok {
+				value, _ := f.GetFieldInt64(k)
+				match = match && r.Match(value)
+			} else {
+				match = match && f.GetFilterValue(k) == v
+			}
+		}
+		if match {
+			flows = append(flows, proto.Clone(f).(*flow.Flow))
+		}
+	}
+	return flows, nil
+}
+
+func newBackfillFlow(uuid string, last int64, srcMAC string, dstMAC string, srcIP string, dstIP string) *flow.Flow {
+	return &flow.Flow{
+		UUID: uuid,
+		Statistics: &flow.FlowStatistics{
+			Start: last - 5,
+			Last:  last,
+			Endpoints: []*flow.FlowEndpointsStatistics{
+				{
+					Type: flow.FlowEndpointType_ETHERNET,
+					AB:   &flow.FlowEndpointStatistics{Value: srcMAC},
+					BA:   &flow.FlowEndpointStatistics{Value: dstMAC},
+				},
+				{
+					Type: flow.FlowEndpointType_IPV4,
+					AB:   &flow.FlowEndpointStatistics{Value: srcIP},
+					BA:   &flow.FlowEndpointStatistics{Value: dstIP},
+				},
+			},
+		},
+	}
+}
+
+func newBackfillTest(t *testing.T) (*graph.Graph, *upsertStorage) {
+	now := time.Now().Unix()
+	st := &upsertStorage{stores: make(map[string]int)}
+	st.fakeStorage.flows = []*flow.Flow{
+		newBackfillFlow("f1", now-60, "aa:aa:aa:aa:aa:01", "aa:aa:aa:aa:aa:02", "10.0.0.1", "10.0.0.2"),
+		newBackfillFlow("f2", now-60, "aa:aa:aa:aa:aa:02", "aa:aa:aa:aa:aa:01", "10.0.0.2", "10.0.0.1"),
+		newBackfillFlow("f3", now-60, "aa:aa:aa:aa:aa:03", "aa:aa:aa:aa:aa:02", "10.0.0.3", "10.0.0.1"),
+		// out of the window
+		newBackfillFlow("f4", now-7200, "aa:aa:aa:aa:aa:01", "aa:aa:aa:aa:aa:02", "10.0.0.1", "10.0.0.2"),
+	}
+	st.fakeStorage.flows[1].IfDstNodeUUID = "other"
+
+	backend, _ := graph.NewMemoryBackend()
+	g, _ := graph.NewGraph(backend)
+	return g, st
+}
+
+func TestFlowBackfill(t *testing.T) {
+	g, st := newBackfillTest(t)
+	b := NewFlowBackfill(g, st, time.Hour, 0, 0, nil)
+
+	n := g.NewNode(graph.GenID(), graph.Metadata{"Name": "eth0", "MAC": "aa:aa:aa:aa:aa:01", "IPV4": "10.0.0.1/24"})
+	node := string(n.ID)
+
+	// f1 by its source MAC, f3 by its destination IP, f2 already attributed
+	result, err := b.Backfill(context.Background(), node, true)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if result.Matched != 2 || result.Updated != 0 || len(st.stores) != 0 || strings.Join(result.Keys, " ") != "ETHERNET=aa:aa:aa:aa:aa:01 IPV4=10.0.0.1" {
+		t.Fatalf("Expected a dry run matching 2 flows, got %+v", result)
+	}
+
+	if result, err = b.Backfill(context.Background(), node, false); err != nil || result.Updated != 2 {
+		t.Fatalf("Expected 2 flows to be updated, got %+v, %v", result, err)
+	}
+
+	// the backfill being idempotent, a second one doesn't store anything
+	if result, err = b.Backfill(context.Background(), node, false); err != nil || result.Matched != 0 || result.Updated != 0 {
+		t.Fatalf("Expected no flow to be updated again, got %+v, %v", result, err)
+	}
+
+	for _, f := range st.flows {
+		switch f.UUID {
+		case "f1":
+			if f.IfSrcNodeUUID != node || f.IfDstNodeUUID != "" || f.Attributes[flow.FlowAttributeBackfilledAt] == "" || st.stores["f1"] != 1 {
+				t.Errorf("Expected f1 to be attributed once, got %+v stored %d times", f, st.stores["f1"])
+			}
+		case "f3":
+			if f.IfDstNodeUUID != node || f.IfSrcNodeUUID != "" || st.stores["f3"] != 1 {
+				t.Errorf("Expected f3 to be attributed once, got %+v stored %d times", f, st.stores["f3"])
+			}
+		default:
+			if st.stores[f.UUID] != 0 {
+				t.Errorf("Expected %s to be left untouched, got %+v", f.UUID, f)
+			}
+		}
+	}
+
+	if results := b.Results(); len(results) != 3 || !results[0].DryRun {
+		t.Errorf("Expected the 3 backfills to be reported, got %+v", results)
+	}
+
+	if _, err = b.Backfill(context.Background(), "unknown", false); err != ErrBackfillNodeNotFound {
+		t.Errorf("Expected an unknown node error, got %v", err)
+	}
+}
+
+func TestFlowBackfillAmbiguous(t *testing.T) {
+	g, st := newBackfillTest(t)
+	b := NewFlowBackfill(g, st, time.Hour, 0, 0, nil)
+
+	// a MAC or an address shared by two nodes can't attribute the flows
+	n := g.NewNode(graph.GenID(), graph.Metadata{"MAC": "aa:aa:aa:aa:aa:01", "IPV4": "10.0.0.1/24"})
+	g.NewNode(graph.GenID(), graph.Metadata{"MAC": "aa:aa:aa:aa:aa:01", "IPV4": "10.0.0.1/8, 172.16.0.1/16"})
+
+	result, err := b.Backfill(context.Background(), string(n.ID), false)
+	if err != nil || len(result.Keys) != 0 || result.Matched != 0 || len(st.stores) != 0 {
+		t.Errorf("Expected no flow to be attributed, got %+v, %v", result, err)
+	}
+}
+
+func TestFlowBackfillEvents(t *testing.T) {
+	g, st := newBackfillTest(t)
+	b := NewFlowBackfill(g, st, time.Hour, 1, 100, []string{"NodeAdded", "NodeUpdated"})
+	b.Start()
+	defer b.Stop()
+
+	// waits for the backfill storing the flows to be done
+	waitStores := func(count int) {
+		for i := 0; i < 100 && st.storeCount() < count; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		b.jobLock.Lock()
+		b.jobLock.Unlock()
+	}
+
+	// MaxFlows bounds each backfill, the node updates backfilling the
+	// remaining flows once its addresses changed
+	g.Lock()
+	n := g.NewNode(graph.GenID(), graph.Metadata{"Name": "eth0", "MAC": "aa:aa:aa:aa:aa:01"})
+	g.Unlock()
+	waitStores(1)
+
+	g.Lock()
+	g.AddMetadata(n, "MTU", 1500)
+	g.AddMetadata(n, "IPV4", "10.0.0.1/24")
+	g.Unlock()
+	waitStores(2)
+
+	st.Lock()
+	defer st.Unlock()
+	if st.stores["f1"] != 1 || st.stores["f3"] != 1 || len(st.stores) != 2 {
+		t.Errorf("Expected f1 and f3 to be stored once, got %v", st.stores)
+	}
+}
+
+func TestFlowBackfillApi(t *testing.T) {
+	g, st := newBackfillTest(t)
+	a := &FlowBackfillApi{Backfill: NewFlowBackfill(g, st, time.Hour, 0, 0, nil)}
+	n := g.NewNode(graph.GenID(), graph.Metadata{"MAC": "aa:aa:aa:aa:aa:01"})
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/api/admin/flow/backfill", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		a.backfill(w, &auth.AuthenticatedRequest{Request: *req, Username: "admin"})
+		return w
+	}
+
+	if w := post(`{"Node": "` + string(n.ID) + `", "DryRun": true}`); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"Matched":1`) {
+		t.Errorf("Expected a dry run count, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(st.stores) != 0 {
+		t.Errorf("Expected the dry run not to store flows, got %v", st.stores)
+	}
+	if w := post(`{"Node": "unknown"}`); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+	if w := post(`{}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+}
+
+// requestIDStorage records the request IDs of the searches
+type requestIDStorage struct {
+	*upsertStorage
+	lock       sync.Mutex
+	requestIDs []string
+}
+
+func (s *requestIDStorage) SearchFlowsContext(ctx context.Context, filters storage.Filters) ([]*flow.Flow, error) {
+	s.lock.Lock()
+	s.requestIDs = append(s.requestIDs, logging.ContextField(ctx, "request_id"))
+	s.lock.Unlock()
+	return s.SearchFlows(filters)
+}
+
+func TestFlowBackfillApi_requestID(t *testing.T) {
+	g, st := newBackfillTest(t)
+	rs := &requestIDStorage{upsertStorage: st}
+	n := g.NewNode(graph.GenID(), graph.Metadata{"MAC": "aa:aa:aa:aa:aa:01"})
+
+	server := shttp.NewServer("analyzer", "127.0.0.1", 0, shttp.NewNoAuthenticationBackend())
+	(&FlowBackfillApi{Backfill: NewFlowBackfill(g, rs, time.Hour, 0, 0, nil)}).registerEndpoints(server)
+
+	ts := httptest.NewServer(server.AdminRouter)
+	defer ts.Close()
+
+	host, port, _ := net.SplitHostPort(strings.TrimPrefix(ts.URL, "http://"))
+	p, _ := strconv.Atoi(port)
+	client := shttp.NewRestClient(host, p, &shttp.AuthenticationOpts{})
+
+	resp, err := client.Request("POST", "api/admin/flow/backfill", strings.NewReader(`{"Node": "`+string(n.ID)+`"}`))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	resp.Body.Close()
+
+	// the ID generated by the client is returned, audited and given to the
+	// storage
+	id := resp.Request.Header.Get(shttp.RequestIDHeader)
+	if id == "" || resp.Header.Get(shttp.RequestIDHeader) != id {
+		t.Fatalf("Expected the request ID %q of the client in the reply, got %q", id, resp.Header.Get(shttp.RequestIDHeader))
+	}
+
+	entries := logging.GetJournal(logging.JournalAudit).Entries()
+	if len(entries) == 0 || entries[len(entries)-1].RequestID != id {
+		t.Errorf("Expected the audit entry to hold the request ID %q, got %+v", id, entries)
+	}
+
+	if len(rs.requestIDs) == 0 {
+		t.Fatal("No search of the storage")
+	}
+	for _, received := range rs.requestIDs {
+		if received != id {
+			t.Errorf("Expected the storage to receive the request ID %q, got %q", id, received)
+		}
+	}
+}
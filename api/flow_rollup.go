@@ -0,0 +1,557 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/abbot/go-http-auth"
+	"golang.org/x/net/context"
+
+	"github.com/redhat-cip/skydive/config"
+	"github.com/redhat-cip/skydive/flow"
+	shttp "github.com/redhat-cip/skydive/http"
+	"github.com/redhat-cip/skydive/logging"
+	"github.com/redhat-cip/skydive/storage"
+)
+
+// The states of a rollup recompute
+const (
+	RollupRecomputeRunning   = "running"
+	RollupRecomputeDone      = "done"
+	RollupRecomputeCancelled = "cancelled"
+	RollupRecomputeFailed    = "failed"
+)
+
+// ErrRecomputeRunning is returned when a recompute is started while another
+// one is running
+var ErrRecomputeRunning = errors.New("A recompute of the flow rollups is already running")
+
+// FlowRollup sums the stored flows whose Statistics.Last falls in the
+// interval starting at Start, a unix timestamp. The bytes and the packets
+// are the ones of the outermost layer of the flows.
+type FlowRollup struct {
+	Start   int64
+	Flows   int
+	Bytes   uint64
+	Packets uint64
+}
+
+// RollupRecomputeRequest gives the range, in unix timestamps, of the
+// rollups to recompute, widened to whole intervals
+type RollupRecomputeRequest struct {
+	From int64
+	To   int64
+}
+
+// RollupRecompute reports the progress of a recompute, the rollups of the
+// range being replaced once all the intervals are computed
+type RollupRecompute struct {
+	From      int64
+	To        int64
+	Intervals int
+	Done      int
+	State     string
+	Error     string `json:",omitempty"`
+	Started   time.Time
+	Finished  time.Time `json:",omitempty"`
+	cancel    context.CancelFunc
+}
+
+// FlowRollups rolls up the stored flows by Interval, each interval being
+// computed from the storage once closed, Delay after its end, so that the
+// updates of its flows were stored. The rollups of the last Retention
+// intervals are kept. A recompute computes the rollups of a range again
+// from the storage, in the background, one at a time.
+type FlowRollups struct {
+	sync.RWMutex
+	Storage   storage.Storage
+	Interval  time.Duration
+	Delay     time.Duration
+	Retention int
+	rollups   map[int64]*FlowRollup
+	next      int64
+	recompute *RollupRecompute
+	running   bool
+	quit      chan bool
+	wg        sync.WaitGroup
+}
+
+func rollupCounters(f *flow.Flow) (bytes uint64, packets uint64) {
+	if f.Statistics == nil || len(f.Statistics.Endpoints) == 0 || f.Statistics.Endpoints[0] == nil {
+		return 0, 0
+	}
+	if e := f.Statistics.Endpoints[0].AB; e != nil {
+		bytes, packets = bytes+e.Bytes, packets+e.Packets
+	}
+	if e := f.Statistics.Endpoints[0].BA; e != nil {
+		bytes, packets = bytes+e.Bytes, packets+e.Packets
+	}
+	return
+}
+
+// rollupFlows sums the flows of the interval starting at start
+func rollupFlows(flows []*flow.Flow, start int64) *FlowRollup {
+	rollup := &FlowRollup{Start: start}
+	for _, f := range flows {
+		bytes, packets := rollupCounters(f)
+		rollup.Flows++
+		rollup.Bytes += bytes
+		rollup.Packets += packets
+	}
+	return rollup
+}
+
+func (r *FlowRollups) interval() int64 {
+	return int64(r.Interval / time.Second)
+}
+
+// align widens the range to whole intervals
+func (r *FlowRollups) align(from int64, to int64) (int64, int64) {
+	interval := r.interval()
+	from -= from % interval
+	if to%interval != 0 {
+		to += interval - to%interval
+	}
+	return from, to
+}
+
+// computeInterval rolls up the stored flows of the interval starting at start
+func (r *FlowRollups) computeInterval(ctx context.Context, start int64) (*FlowRollup, error) {
+	filters := storage.Filters{
+		"Statistics.Last": storage.Range{Gte: start, Lt: start + r.interval()},
+	}
+
+	var flows []*flow.Flow
+	var err error
+	if cs, ok := r.Storage.(storage.ContextSearcher); ok {
+		flows, err = cs.SearchFlowsContext(ctx, filters)
+	} else {
+		flows, err = r.Storage.SearchFlows(filters)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return rollupFlows(flows, start), nil
+}
+
+// replace replaces the rollups of the range by the computed ones
+func (r *FlowRollups) replace(from int64, to int64, computed []*FlowRollup) {
+	r.Lock()
+	defer r.Unlock()
+
+	for start := from; start < to; start += r.interval() {
+		delete(r.rollups, start)
+	}
+	for _, rollup := range computed {
+		r.rollups[rollup.Start] = rollup
+	}
+
+	if r.Retention > 0 && len(r.rollups) > r.Retention {
+		starts := make([]int64, 0, len(r.rollups))
+		for start := range r.rollups {
+			starts = append(starts, start)
+		}
+		sort.Sort(sortInt64s(starts))
+		for _, start := range starts[:len(starts)-r.Retention] {
+			delete(r.rollups, start)
+		}
+	}
+}
+
+type sortInt64s []int64
+
+func (s sortInt64s) Len() int {
+	return len(s)
+}
+
+func (s sortInt64s) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s sortInt64s) Less(i, j int) bool {
+	return s[i] < s[j]
+}
+
+type sortRollupsByStart []*FlowRollup
+
+func (s sortRollupsByStart) Len() int {
+	return len(s)
+}
+
+func (s sortRollupsByStart) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s sortRollupsByStart) Less(i, j int) bool {
+	return s[i].Start < s[j].Start
+}
+
+// Get returns the rollups of the intervals starting in the range, sorted
+// by time, the range being unbounded when to is 0
+func (r *FlowRollups) Get(from int64, to int64) []*FlowRollup {
+	r.RLock()
+	defer r.RUnlock()
+
+	rollups := []*FlowRollup{}
+	for start, rollup := range r.rollups {
+		if start >= from && (to == 0 || start < to) {
+			c := *rollup
+			rollups = append(rollups, &c)
+		}
+	}
+	sort.Sort(sortRollupsByStart(rollups))
+	return rollups
+}
+
+// tick rolls up the intervals closed since the last tick
+func (r *FlowRollups) tick(now time.Time) {
+	closed := now.Add(-r.Delay).Unix()
+	closed -= closed % r.interval()
+
+	r.Lock()
+	next := r.next
+	if next == 0 {
+		next = closed - r.interval()
+	}
+	r.Unlock()
+
+	var computed []*FlowRollup
+	for start := next; start+r.interval() <= closed; start += r.interval() {
+		rollup, err := r.computeInterval(context.Background(), start)
+		if err != nil {
+			logging.GetLogger().Errorf("Unable to roll up the flows of %s: %s", time.Unix(start, 0).UTC().Format(time.RFC3339), err.Error())
+			break
+		}
+		computed = append(computed, rollup)
+		next = start + r.interval()
+	}
+
+	if len(computed) > 0 {
+		r.replace(computed[0].Start, next, computed)
+	}
+
+	r.Lock()
+	r.next = next
+	r.Unlock()
+}
+
+func (r *FlowRollups) runRecompute(ctx context.Context, rc *RollupRecompute) {
+	defer r.wg.Done()
+
+	var computed []*FlowRollup
+	var err error
+	for start := rc.From; start < rc.To; start += r.interval() {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+
+		var rollup *FlowRollup
+		if rollup, err = r.computeInterval(ctx, start); err != nil {
+			break
+		}
+		computed = append(computed, rollup)
+
+		r.Lock()
+		rc.Done++
+		r.Unlock()
+	}
+
+	if err == nil {
+		r.replace(rc.From, rc.To, computed)
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	rc.Finished = time.Now()
+	switch {
+	case err == nil:
+		rc.State = RollupRecomputeDone
+	case ctx.Err() == context.Canceled:
+		rc.State = RollupRecomputeCancelled
+	default:
+		rc.State = RollupRecomputeFailed
+		rc.Error = err.Error()
+	}
+	rc.cancel()
+
+	logging.GetLogger().Infof("Recompute of the flow rollups from %d to %d %s after %d intervals", rc.From, rc.To, rc.State, rc.Done)
+	logging.GetJournal(logging.JournalAudit).Record("Recompute of the flow rollups from %d to %d %s after %d intervals", rc.From, rc.To, rc.State, rc.Done)
+}
+
+// Recompute starts the recompute of the rollups of the range, failing if
+// one is already running
+func (r *FlowRollups) Recompute(from int64, to int64) (*RollupRecompute, error) {
+	if from <= 0 || to <= from {
+		return nil, errors.New("Invalid range, From and To are required and From has to precede To")
+	}
+	from, to = r.align(from, to)
+
+	r.Lock()
+	defer r.Unlock()
+
+	if r.recompute != nil && r.recompute.State == RollupRecomputeRunning {
+		return nil, ErrRecomputeRunning
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r.recompute = &RollupRecompute{
+		From:      from,
+		To:        to,
+		Intervals: int((to - from) / r.interval()),
+		State:     RollupRecomputeRunning,
+		Started:   time.Now(),
+		cancel:    cancel,
+	}
+	rc := *r.recompute
+
+	r.wg.Add(1)
+	go r.runRecompute(ctx, r.recompute)
+
+	return &rc, nil
+}
+
+// RecomputeStatus returns the progress of the last recompute, nil if none
+// was started
+func (r *FlowRollups) RecomputeStatus() *RollupRecompute {
+	r.RLock()
+	defer r.RUnlock()
+
+	if r.recompute == nil {
+		return nil
+	}
+	rc := *r.recompute
+	return &rc
+}
+
+// CancelRecompute cancels the running recompute, the rollups being left
+// as they were. It returns false when none is running.
+func (r *FlowRollups) CancelRecompute() bool {
+	r.RLock()
+	defer r.RUnlock()
+
+	if r.recompute == nil || r.recompute.State != RollupRecomputeRunning {
+		return false
+	}
+	r.recompute.cancel()
+	return true
+}
+
+func (r *FlowRollups) run(quit chan bool) {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			r.tick(now)
+		case <-quit:
+			return
+		}
+	}
+}
+
+func (r *FlowRollups) Start() {
+	r.Lock()
+	r.running = true
+	r.quit = make(chan bool)
+	r.Unlock()
+
+	r.wg.Add(1)
+	go r.run(r.quit)
+}
+
+// Stop stops the rollups and cancels the running recompute
+func (r *FlowRollups) Stop() {
+	r.CancelRecompute()
+
+	r.Lock()
+	if r.running {
+		close(r.quit)
+		r.running = false
+	}
+	r.Unlock()
+
+	r.wg.Wait()
+}
+
+func NewFlowRollups(s storage.Storage, interval time.Duration, delay time.Duration, retention int) *FlowRollups {
+	return &FlowRollups{
+		Storage:   s,
+		Interval:  interval,
+		Delay:     delay,
+		Retention: retention,
+		rollups:   make(map[int64]*FlowRollup),
+	}
+}
+
+// NewFlowRollupsFromConfig returns the rollups configured by
+// analyzer.flow_rollups, nil without storage or when disabled
+func NewFlowRollupsFromConfig(s storage.Storage) *FlowRollups {
+	cfg := config.GetConfig()
+	interval := time.Duration(cfg.GetInt("analyzer.flow_rollups.interval")) * time.Second
+	if s == nil || interval <= 0 {
+		return nil
+	}
+
+	delay := time.Duration(cfg.GetInt("analyzer.flow_rollups.delay")) * time.Second
+	return NewFlowRollups(s, interval, delay, cfg.GetInt("analyzer.flow_rollups.retention"))
+}
+
+type FlowRollupApi struct {
+	Rollups *FlowRollups
+	// credentials required by the rollups endpoint, none when not set
+	Credentials *shttp.AuthenticationOpts
+}
+
+func parseRollupTime(r *auth.AuthenticatedRequest, key string) (int64, error) {
+	value := r.URL.Query().Get(key)
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+func writeRollupJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logging.GetLogger().Criticalf("Failed to send flow rollups: %s", err.Error())
+	}
+}
+
+// index returns the rollups of the range given by the from and to query
+// parameters, unix timestamps
+func (a *FlowRollupApi) index(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
+	from, err := parseRollupTime(r, "from")
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("Invalid from value: " + r.URL.Query().Get("from")))
+		return
+	}
+	to, err := parseRollupTime(r, "to")
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("Invalid to value: " + r.URL.Query().Get("to")))
+		return
+	}
+
+	writeRollupJSON(w, http.StatusOK, a.Rollups.Get(from, to))
+}
+
+func (a *FlowRollupApi) recompute(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
+	var request RollupRecomputeRequest
+	data, _ := ioutil.ReadAll(r.Body)
+	if err := json.Unmarshal(data, &request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	rc, err := a.Rollups.Recompute(request.From, request.To)
+	if err != nil {
+		if err == ErrRecomputeRunning {
+			w.WriteHeader(http.StatusConflict)
+		} else {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+		w.Write([]byte(err.Error()))
+		return
+	}
+	ctx := shttp.RequestContext(&r.Request)
+	logging.GetContextLogger(ctx).Infof("Recompute of the flow rollups from %d to %d started by %s", rc.From, rc.To, r.Username)
+	logging.GetJournal(logging.JournalAudit).RecordContext(ctx, "Recompute of the flow rollups from %d to %d started by %s", rc.From, rc.To, r.Username)
+
+	writeRollupJSON(w, http.StatusAccepted, rc)
+}
+
+func (a *FlowRollupApi) recomputeStatus(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
+	rc := a.Rollups.RecomputeStatus()
+	if rc == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	writeRollupJSON(w, http.StatusOK, rc)
+}
+
+func (a *FlowRollupApi) cancelRecompute(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
+	if !a.Rollups.CancelRecompute() {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	logging.GetJournal(logging.JournalAudit).RecordContext(shttp.RequestContext(&r.Request), "Recompute of the flow rollups cancelled by %s", r.Username)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (a *FlowRollupApi) registerEndpoints(r *shttp.Server) {
+	r.RegisterRoutes(shttp.RequireRoutesCredentials(a.Credentials, []shttp.Route{
+		{
+			"FlowRollups",
+			"GET",
+			"/api/flow/rollups",
+			a.index,
+		},
+	}))
+
+	r.RegisterAdminRoutes([]shttp.Route{
+		{
+			"FlowRollupsRecompute",
+			"POST",
+			"/api/admin/flow/rollups/recompute",
+			a.recompute,
+		},
+		{
+			"FlowRollupsRecomputeStatus",
+			"GET",
+			"/api/admin/flow/rollups/recompute",
+			a.recomputeStatus,
+		},
+		{
+			"FlowRollupsRecomputeCancel",
+			"DELETE",
+			"/api/admin/flow/rollups/recompute",
+			a.cancelRecompute,
+		},
+	})
+}
+
+// RegisterFlowRollupApi registers the rollups endpoint and, on the admin
+// listener, the recompute ones
+func RegisterFlowRollupApi(rollups *FlowRollups, r *shttp.Server) {
+	a := &FlowRollupApi{
+		Rollups:     rollups,
+		Credentials: shttp.AuthenticationOptsFromConfig("analyzer"),
+	}
+	a.registerEndpoints(r)
+}
@@ -0,0 +1,174 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abbot/go-http-auth"
+	"golang.org/x/net/context"
+
+	"github.com/redhat-cip/skydive/flow"
+	"github.com/redhat-cip/skydive/storage"
+)
+
+func newRollupFlow(uuid string, last int64, bytes uint64, packets uint64) *flow.Flow {
+	return &flow.Flow{
+		UUID: uuid,
+		Statistics: &flow.FlowStatistics{
+			Start: last - 5,
+			Last:  last,
+			Endpoints: []*flow.FlowEndpointsStatistics{
+				{
+					AB: &flow.FlowEndpointStatistics{Bytes: bytes, Packets: packets},
+					BA: &flow.FlowEndpointStatistics{Bytes: bytes / 2, Packets: packets / 2},
+				},
+			},
+		},
+	}
+}
+
+// blockingStorage blocks the searches until their context is cancelled
+type blockingStorage struct {
+	fakeStorage
+	searching chan bool
+}
+
+func (s *blockingStorage) SearchFlowsContext(ctx context.Context, filters storage.Filters) ([]*flow.Flow, error) {
+	s.searching <- true
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func waitRecompute(t *testing.T, rollups *FlowRollups) *RollupRecompute {
+	for i := 0; i < 100; i++ {
+		if rc := rollups.RecomputeStatus(); rc != nil && rc.State != RollupRecomputeRunning {
+			return rc
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("The recompute didn't finish")
+	return nil
+}
+
+func TestFlowRollupsRecompute(t *testing.T) {
+	st := &fakeStorage{}
+	for i := 0; i < 50; i++ {
+		last := int64(1000 + i*37)
+		st.flows = append(st.flows, newRollupFlow("flow"+string(rune('A'+i)), last, uint64(100*i+1), uint64(i+1)))
+	}
+
+	rollups := NewFlowRollups(st, time.Minute, 0, 0)
+
+	// stale rollups, to be replaced
+	rollups.replace(960, 1020, []*FlowRollup{{Start: 960, Flows: 99}})
+	rollups.replace(3000, 3060, []*FlowRollup{{Start: 3000, Flows: 1}})
+
+	rc, err := rollups.Recompute(1000, 2900)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if rc.From != 960 || rc.To != 2940 || rc.Intervals != 33 {
+		t.Fatalf("Expected the range to be aligned on the interval, got: %+v", rc)
+	}
+
+	if rc = waitRecompute(t, rollups); rc.State != RollupRecomputeDone || rc.Done != rc.Intervals {
+		t.Fatalf("Expected the recompute to be done, got: %+v", rc)
+	}
+
+	// brute force, from all the flows at once
+	expected := make(map[int64]*FlowRollup)
+	for _, f := range st.flows {
+		start := f.Statistics.Last - f.Statistics.Last%60
+		if start < 960 || start >= 2940 {
+			continue
+		}
+		if _, ok := expected[start]; !ok {
+			expected[start] = &FlowRollup{Start: start}
+		}
+		e := f.Statistics.Endpoints[0]
+		expected[start].Flows++
+		expected[start].Bytes += e.AB.Bytes + e.BA.Bytes
+		expected[start].Packets += e.AB.Packets + e.BA.Packets
+	}
+
+	got := make(map[int64]*FlowRollup)
+	for _, rollup := range rollups.Get(960, 2940) {
+		if rollup.Flows > 0 {
+			got[rollup.Start] = rollup
+		}
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Recomputed rollups %v don't match the expected ones %v", got, expected)
+	}
+
+	if len(rollups.Get(3000, 0)) != 1 {
+		t.Error("The rollups out of the range shouldn't be replaced")
+	}
+
+	if _, err = rollups.Recompute(2000, 1000); err == nil {
+		t.Error("Expected an error for an inverted range")
+	}
+}
+
+func TestFlowRollupsRecomputeCancel(t *testing.T) {
+	st := &blockingStorage{searching: make(chan bool, 1)}
+	rollups := NewFlowRollups(st, time.Minute, 0, 0)
+	rollups.replace(960, 1020, []*FlowRollup{{Start: 960, Flows: 42}})
+
+	fa := &FlowRollupApi{Rollups: rollups}
+	recompute := func() int {
+		req, _ := http.NewRequest("POST", "/api/admin/flow/rollups/recompute", strings.NewReader(`{"From": 960, "To": 1200}`))
+		w := httptest.NewRecorder()
+		fa.recompute(w, &auth.AuthenticatedRequest{Request: *req, Username: "admin"})
+		return w.Code
+	}
+
+	if code := recompute(); code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d", code)
+	}
+	<-st.searching
+
+	if code := recompute(); code != http.StatusConflict {
+		t.Fatalf("Expected status 409 while running, got %d", code)
+	}
+
+	req, _ := http.NewRequest("DELETE", "/api/admin/flow/rollups/recompute", nil)
+	w := httptest.NewRecorder()
+	fa.cancelRecompute(w, &auth.AuthenticatedRequest{Request: *req, Username: "admin"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	if rc := waitRecompute(t, rollups); rc.State != RollupRecomputeCancelled {
+		t.Fatalf("Expected the recompute to be cancelled, got: %+v", rc)
+	}
+	if r := rollups.Get(960, 1020); len(r) != 1 || r[0].Flows != 42 {
+		t.Errorf("A cancelled recompute shouldn't replace the rollups, got: %v", r)
+	}
+}
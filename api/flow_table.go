@@ -0,0 +1,90 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/abbot/go-http-auth"
+
+	"github.com/redhat-cip/skydive/flow"
+	shttp "github.com/redhat-cip/skydive/http"
+	"github.com/redhat-cip/skydive/logging"
+)
+
+// FlowTableClearReport is the reply of a flow table clear
+type FlowTableClearReport struct {
+	Cleared int
+	Flushed bool
+}
+
+type FlowTableApi struct {
+	FlowTable *flow.Table
+}
+
+// clear empties the flow table, the flows being stored and exported as
+// expired first with ?flush=true
+func (t *FlowTableApi) clear(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
+	flush := false
+	if f := r.URL.Query().Get("flush"); f != "" {
+		var err error
+		if flush, err = strconv.ParseBool(f); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("Invalid flush value: " + f))
+			return
+		}
+	}
+
+	report := FlowTableClearReport{Cleared: t.FlowTable.Clear(flush), Flushed: flush}
+	ctx := shttp.RequestContext(&r.Request)
+	logging.GetContextLogger(ctx).Infof("Flow table cleared by %s: %d flows, flushed: %v", r.Username, report.Cleared, flush)
+	logging.GetJournal(logging.JournalAudit).RecordContext(ctx, "Flow table cleared by %s: %d flows, flushed: %v", r.Username, report.Cleared, flush)
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(&report); err != nil {
+		logging.GetLogger().Criticalf("Failed to send flow table clear report: %s", err.Error())
+	}
+}
+
+func (t *FlowTableApi) registerEndpoints(r *shttp.Server) {
+	routes := []shttp.Route{
+		{
+			"FlowTableClear",
+			"POST",
+			"/api/admin/flow/clear",
+			t.clear,
+		},
+	}
+
+	r.RegisterAdminRoutes(routes)
+}
+
+// RegisterFlowTableApi registers the flow table endpoints on the admin
+// listener
+func RegisterFlowTableApi(f *flow.Table, r *shttp.Server) {
+	t := &FlowTableApi{FlowTable: f}
+	t.registerEndpoints(r)
+}
@@ -0,0 +1,136 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/abbot/go-http-auth"
+
+	"github.com/redhat-cip/skydive/flow"
+)
+
+func clearFlowTable(t *testing.T, ta *FlowTableApi, query string) (int, FlowTableClearReport) {
+	req, err := http.NewRequest("POST", "/api/admin/flow/clear"+query, nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	w := httptest.NewRecorder()
+	ta.clear(w, &auth.AuthenticatedRequest{Request: *req, Username: "admin"})
+
+	var report FlowTableClearReport
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	return w.Code, report
+}
+
+func tableFlows(prefix string, count int) []*flow.Flow {
+	var flows []*flow.Flow
+	for i := 0; i < count; i++ {
+		flows = append(flows, &flow.Flow{UUID: prefix + strconv.Itoa(i), Statistics: &flow.FlowStatistics{Last: time.Now().Unix()}})
+	}
+	return flows
+}
+
+func TestFlowTableApi_clear(t *testing.T) {
+	var lock sync.Mutex
+	var flushed []*flow.Flow
+
+	table := flow.NewTable()
+	table.RegisterExpire(func(flows []*flow.Flow) {
+		lock.Lock()
+		flushed = append(flushed, flows...)
+		lock.Unlock()
+	}, time.Hour, time.Hour)
+	defer table.UnregisterAll()
+
+	ta := &FlowTableApi{FlowTable: table}
+
+	table.Update(tableFlows("flow", 10))
+	code, report := clearFlowTable(t, ta, "")
+	if code != http.StatusOK || report.Cleared != 10 || report.Flushed {
+		t.Fatalf("Expected 10 flows cleared, got %d: %+v", code, report)
+	}
+	if flows := table.GetFlows(); len(flows) != 0 || len(flushed) != 0 {
+		t.Errorf("Expected the table to be emptied without flush, got %d flows, %d flushed", len(flows), len(flushed))
+	}
+
+	table.Update(tableFlows("flow", 5))
+	if code, report = clearFlowTable(t, ta, "?flush=true"); code != http.StatusOK || report.Cleared != 5 || !report.Flushed {
+		t.Fatalf("Expected 5 flows flushed, got %d: %+v", code, report)
+	}
+	if flows := table.GetFlows(); len(flows) != 0 || len(flushed) != 5 {
+		t.Errorf("Expected the table to be flushed then emptied, got %d flows, %d flushed", len(flows), len(flushed))
+	}
+
+	if code, _ = clearFlowTable(t, ta, "?flush=maybe"); code != http.StatusBadRequest {
+		t.Errorf("Expected an invalid flush to be refused, got %d", code)
+	}
+}
+
+func TestFlowTableApi_clearConcurrentIngestion(t *testing.T) {
+	var lock sync.Mutex
+	flushed := 0
+
+	table := flow.NewTable()
+	table.RegisterExpire(func(flows []*flow.Flow) {
+		lock.Lock()
+		flushed += len(flows)
+		lock.Unlock()
+	}, time.Hour, time.Hour)
+	ta := &FlowTableApi{FlowTable: table}
+
+	// every ingested flow is either cleared and flushed or left in the table
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			table.Update(tableFlows("batch"+strconv.Itoa(i)+"-", 10))
+		}
+	}()
+
+	cleared := 0
+	for i := 0; i < 20; i++ {
+		_, report := clearFlowTable(t, ta, "?flush=true")
+		cleared += report.Cleared
+	}
+	wg.Wait()
+
+	left := len(table.GetFlows())
+	table.UnregisterAll()
+
+	if cleared+left != 1000 || flushed != 1000 {
+		t.Errorf("Expected the 1000 flows to be cleared or left, got %d cleared, %d left, %d flushed", cleared, left, flushed)
+	}
+}
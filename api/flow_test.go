@@ -24,13 +24,130 @@ package api
 
 import (
 	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/abbot/go-http-auth"
 	v "github.com/gima/govalid/v1"
+	gcontext "github.com/gorilla/context"
+	"github.com/gorilla/mux"
+	"golang.org/x/net/context"
 
 	"github.com/redhat-cip/skydive/flow"
+	"github.com/redhat-cip/skydive/flow/mappings"
+	shttp "github.com/redhat-cip/skydive/http"
+	"github.com/redhat-cip/skydive/logging"
+	"github.com/redhat-cip/skydive/storage"
+	"github.com/redhat-cip/skydive/version"
 )
 
+type fakeStorage struct {
+	flows []*flow.Flow
+}
+
+func (s *fakeStorage) Start() {
+}
+
+func (s *fakeStorage) Stop() {
+}
+
+func (s *fakeStorage) StoreFlows(flows []*flow.Flow) error {
+	s.flows = append(s.flows, flows...)
+	return nil
+}
+
+func (s *fakeStorage) matchFlow(f *flow.Flow, filters storage.Filters) bool {
+	for k, v := range filters {
+		switch v := v.(type) {
+		case storage.Range:
+			if value, ok := f.GetFieldInt64(k); !ok || !v.Match(value) {
+				return false
+			}
+		case int64:
+			if value, ok := f.GetFieldInt64(k); !ok || value != v {
+				return false
+			}
+		case storage.In:
+			var value interface{}
+			if i, ok := f.GetFieldInt64(k); ok {
+				value = i
+			} else {
+				value, _ = f.GetFieldString(k)
+			}
+			found := false
+			for _, in := range v {
+				found = found || in == value
+			}
+			if !found {
+				return false
+			}
+		default:
+			if value, _ := f.GetFieldString(k); value != v {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (s *fakeStorage) SearchFlows(filters storage.Filters) ([]*flow.Flow, error) {
+	var flows []*flow.Flow
+	for _, f := range s.flows {
+		if s.matchFlow(f, filters) {
+			flows = append(flows, f)
+		}
+	}
+	return flows, nil
+}
+
+// SearchFlowsSince numbers the flows by their position in the storage
+func (s *fakeStorage) SearchFlowsSince(filters storage.Filters, cursor int64, limit int) ([]*flow.Flow, int64, error) {
+	var flows []*flow.Flow
+	for i := int(cursor); i < len(s.flows) && len(flows) < limit; i++ {
+		if s.matchFlow(s.flows[i], filters) {
+			flows = append(flows, s.flows[i])
+		}
+		cursor = int64(i + 1)
+	}
+	return flows, cursor, nil
+}
+
+func (s *fakeStorage) CountFlows(filters storage.Filters) (int, error) {
+	flows, err := s.SearchFlows(filters)
+	return len(flows), err
+}
+
+func newFakeRequest(t *testing.T, url string) *auth.AuthenticatedRequest {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return &auth.AuthenticatedRequest{Request: *req}
+}
+
+// newDiscoveryRequest returns a request holding the discovery type of its
+// path, as set by the router
+func newDiscoveryRequest(t *testing.T, url string) *auth.AuthenticatedRequest {
+	req := newFakeRequest(t, url)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/flow/discovery/{type}", func(w http.ResponseWriter, r *http.Request) {
+		for k, v := range gcontext.GetAll(r) {
+			gcontext.Set(&req.Request, k, v)
+		}
+	})
+	router.ServeHTTP(httptest.NewRecorder(), &newFakeRequest(t, url).Request)
+
+	return req
+}
+
 func TestFlowTable_jsonFlowConversationEthernetPath(t *testing.T) {
 	ft := flow.NewTestFlowTableComplex(t)
 	fa := &FlowApi{
@@ -64,12 +181,46 @@ func TestFlowTable_jsonFlowConversationEthernetPath(t *testing.T) {
 	}
 }
 
+func TestFlowApi_conversationRoute(t *testing.T) {
+	fa := &FlowApi{FlowTable: flow.NewTestFlowTableComplex(t)}
+	server := shttp.NewServer("analyzer", "127.0.0.1", 0, shttp.NewNoAuthenticationBackend())
+	fa.registerEndpoints(server)
+
+	ts := httptest.NewServer(server.Router)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/api/flow/conversation/ipv4")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer resp.Body.Close()
+
+	var conversation struct {
+		Nodes []struct {
+			Name string `json:"name"`
+		} `json:"nodes"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&conversation); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// the layer of the path selects the endpoints
+	if len(conversation.Nodes) == 0 {
+		t.Fatal("Conversation should not be empty")
+	}
+	for _, node := range conversation.Nodes {
+		if net.ParseIP(node.Name) == nil {
+			t.Errorf("Expected the IPv4 endpoints, got %s", node.Name)
+		}
+	}
+}
+
 func test_jsonFlowDiscovery(t *testing.T, DiscoType discoType) {
 	ft := flow.NewTestFlowTableComplex(t)
 	fa := &FlowApi{
 		FlowTable: ft,
 	}
-	disco := fa.jsonFlowDiscovery(DiscoType)
+	disco := fa.jsonFlowDiscovery(DiscoType, nil)
 
 	if disco == `{"name":"root","children":[]}` {
 		t.Error("disco should not be empty")
@@ -107,4 +258,1410 @@ func TestFlowTable_jsonFlowDiscovery(t *testing.T) {
 	t.Log("jsonFlowDiscovery BYTES : ok")
 	test_jsonFlowDiscovery(t, packets)
 	t.Log("jsonFlowDiscovery PACKETS : ok")
+	test_jsonFlowDiscovery(t, flowCount)
+	t.Log("jsonFlowDiscovery FLOWS : ok")
+}
+
+type testDiscoNode struct {
+	Name     string          `json:"name"`
+	Size     uint64          `json:"size"`
+	Children []testDiscoNode `json:"children"`
+}
+
+func (d *testDiscoNode) child(name string) *testDiscoNode {
+	for i := range d.Children {
+		if d.Children[i].Name == name {
+			return &d.Children[i]
+		}
+	}
+	return nil
+}
+
+func (d *testDiscoNode) lookup(path ...string) *testDiscoNode {
+	node := d
+	for _, name := range path {
+		if node = node.child(name); node == nil {
+			return nil
+		}
+	}
+	return node
+}
+
+func newDiscoveryTestFlow(uuid string, probe string, a string, b string, port string, bytes uint64) *flow.Flow {
+	return &flow.Flow{
+		UUID:          uuid,
+		LayersPath:    "Ethernet/IPv4/TCP",
+		ProbeNodeUUID: probe,
+		Statistics: &flow.FlowStatistics{
+			Endpoints: []*flow.FlowEndpointsStatistics{
+				{
+					Type: flow.FlowEndpointType_ETHERNET,
+					AB:   &flow.FlowEndpointStatistics{Value: "00:00:00:00:00:01", Bytes: bytes},
+					BA:   &flow.FlowEndpointStatistics{Value: "00:00:00:00:00:02", Bytes: bytes},
+				},
+				{
+					Type: flow.FlowEndpointType_IPV4,
+					AB:   &flow.FlowEndpointStatistics{Value: a},
+					BA:   &flow.FlowEndpointStatistics{Value: b},
+				},
+				{
+					Type: flow.FlowEndpointType_TCPPORT,
+					AB:   &flow.FlowEndpointStatistics{Value: "34567"},
+					BA:   &flow.FlowEndpointStatistics{Value: port},
+				},
+			},
+		},
+	}
+}
+
+func TestFlowApi_discoveryPath(t *testing.T) {
+	fa := &FlowApi{
+		FlowTable: flow.NewTableFromFlows([]*flow.Flow{
+			newDiscoveryTestFlow("flow1", "probe1", "10.0.0.1", "10.0.0.10", "80", 100),
+			newDiscoveryTestFlow("flow2", "probe1", "10.0.0.2", "10.0.0.10", "80", 10),
+			newDiscoveryTestFlow("flow3", "probe2", "10.0.0.1", "10.0.0.20", "443", 1),
+		}),
+	}
+
+	decode := func(fields []string) *testDiscoNode {
+		var root testDiscoNode
+		if err := json.Unmarshal([]byte(fa.jsonFlowDiscovery(bytes, fields)), &root); err != nil {
+			t.Fatal("JSON parsing failed:", err)
+		}
+		return &root
+	}
+
+	// server address then server port
+	root := decode([]string{"IPV4.B", "TCPPORT.B"})
+	if len(root.Children) != 2 {
+		t.Fatalf("Expected 2 servers, got %+v", root.Children)
+	}
+	if node := root.lookup("10.0.0.10", "80"); node == nil || node.Size != 220 || len(node.Children) != 0 {
+		t.Errorf("Wrong node for 10.0.0.10/80: %+v", node)
+	}
+	if node := root.lookup("10.0.0.20", "443"); node == nil || node.Size != 2 {
+		t.Errorf("Wrong node for 10.0.0.20/443: %+v", node)
+	}
+
+	// probe then client address
+	root = decode([]string{"ProbeNodeUUID", "IPV4.A"})
+	if probe := root.lookup("probe1"); probe == nil || len(probe.Children) != 2 {
+		t.Fatalf("Expected 2 clients seen by probe1, got %+v", probe)
+	}
+	for path, size := range map[[2]string]uint64{{"probe1", "10.0.0.1"}: 200, {"probe1", "10.0.0.2"}: 20, {"probe2", "10.0.0.1"}: 2} {
+		if node := root.lookup(path[0], path[1]); node == nil || node.Size != size {
+			t.Errorf("Wrong node for %v: %+v", path, node)
+		}
+	}
+
+	// values not available are grouped
+	root = decode([]string{"Attributes.NAT_A"})
+	if node := root.lookup("unknown"); node == nil || node.Size != 222 {
+		t.Errorf("Flows without value should be grouped: %+v", root)
+	}
+}
+
+func TestFlowApi_groupByASN(t *testing.T) {
+	db, err := mappings.LoadASNDatabase("../flow/mappings/testdata/ipasn.dat")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	flows := []*flow.Flow{
+		newDiscoveryTestFlow("flow1", "probe1", "10.0.0.1", "8.8.8.8", "53", 100),
+		newDiscoveryTestFlow("flow2", "probe1", "10.0.0.2", "8.8.4.4", "53", 10),
+		newDiscoveryTestFlow("flow3", "probe1", "10.0.0.1", "1.1.1.1", "443", 1),
+		newDiscoveryTestFlow("flow4", "probe1", "10.0.0.3", "10.0.0.4", "22", 5),
+	}
+	pipeline := mappings.NewFlowMappingPipeline(mappings.NewASNFlowEnhancer(db))
+	pipeline.Enhance(flows)
+
+	fa := &FlowApi{FlowTable: flow.NewTableFromFlows(flows)}
+
+	var root testDiscoNode
+	if err := json.Unmarshal([]byte(fa.jsonFlowDiscovery(bytes, []string{"Attributes.ASN_B"})), &root); err != nil {
+		t.Fatal("JSON parsing failed:", err)
+	}
+	for asn, size := range map[string]uint64{"15169": 200, "3356": 20, "13335": 2, "unknown": 10} {
+		if node := root.lookup(asn); node == nil || node.Size != size {
+			t.Errorf("Wrong node for ASN %s: %+v", asn, node)
+		}
+	}
+
+	var conversation struct {
+		Nodes []conversationJSONNode `json:"nodes"`
+	}
+	if err := json.Unmarshal([]byte(fa.jsonFlowConversation(flow.FlowEndpointType_IPV4, conversationOptions{groupByASN: true})), &conversation); err != nil {
+		t.Fatal("JSON parsing failed:", err)
+	}
+
+	// groups are the indexes of the sorted ASNs, 0 for the private endpoints
+	expected := map[string]conversationJSONNode{
+		"1.1.1.1":  {ASN: "13335", Group: 1},
+		"8.8.8.8":  {ASN: "15169", Group: 2},
+		"8.8.4.4":  {ASN: "3356", Group: 3},
+		"10.0.0.1": {Group: 0},
+		"10.0.0.4": {Group: 0},
+	}
+	for _, node := range conversation.Nodes {
+		if e, ok := expected[node.Name]; ok && (node.ASN != e.ASN || node.Group != e.Group) {
+			t.Errorf("Wrong node %s: %+v", node.Name, node)
+		}
+	}
+	if len(conversation.Nodes) != 7 {
+		t.Errorf("Expected 7 endpoints, got %+v", conversation.Nodes)
+	}
+}
+
+func TestFlowApi_discoveryInvalidPath(t *testing.T) {
+	fa := &FlowApi{
+		FlowTable: flow.NewTable(),
+	}
+
+	w := httptest.NewRecorder()
+	fa.discoveryType(w, newDiscoveryRequest(t, "/api/flow/discovery/bytes?path=IPV4.A,Unknown"))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	fa.discoveryType(w, newDiscoveryRequest(t, "/api/flow/discovery/bytes?path=IPV4.A,TCPPORT.B"))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+}
+
+func TestFlowApi_discoveryTypes(t *testing.T) {
+	fa := &FlowApi{
+		FlowTable: flow.NewTableFromFlows([]*flow.Flow{
+			newDiscoveryTestFlow("flow1", "probe1", "10.0.0.1", "10.0.0.10", "80", 100),
+			newDiscoveryTestFlow("flow2", "probe1", "10.0.0.2", "10.0.0.10", "80", 10),
+			newDiscoveryTestFlow("flow3", "probe2", "10.0.0.1", "10.0.0.20", "443", 1),
+		}),
+	}
+
+	for dtype, sizes := range map[string]map[string]uint64{
+		"bytes": {"10.0.0.10": 220, "10.0.0.20": 2},
+		"flows": {"10.0.0.10": 2, "10.0.0.20": 1},
+	} {
+		w := httptest.NewRecorder()
+		fa.discoveryType(w, newDiscoveryRequest(t, "/api/flow/discovery/"+dtype+"?path=IPV4.B"))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200 for %s, got %d", dtype, w.Code)
+		}
+
+		var root testDiscoNode
+		if err := json.Unmarshal(w.Body.Bytes(), &root); err != nil {
+			t.Fatal("JSON parsing failed:", err)
+		}
+		for server, size := range sizes {
+			if node := root.lookup(server); node == nil || node.Size != size {
+				t.Errorf("Wrong %s node for %s: %+v", dtype, server, node)
+			}
+		}
+	}
+
+	w := httptest.NewRecorder()
+	fa.discoveryType(w, newDiscoveryRequest(t, "/api/flow/discovery/errors"))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an unknown type, got %d", w.Code)
+	}
+}
+
+func TestFlowApi_timeRange(t *testing.T) {
+	var flows []*flow.Flow
+	for i, b := range []string{"10.0.0.10", "10.0.0.20", "10.0.0.30"} {
+		f := newDiscoveryTestFlow("flow"+strconv.Itoa(i+1), "probe1", "10.0.0.1", b, "80", 100)
+		f.Statistics.Start, f.Statistics.Last = int64(100+200*i), int64(200+200*i)
+		flows = append(flows, f)
+	}
+	fa := &FlowApi{FlowTable: flow.NewTableFromFlows(flows)}
+
+	for _, test := range []struct {
+		timeRange flowTimeRange
+		expected  []string
+	}{
+		{flowTimeRange{}, []string{"10.0.0.10", "10.0.0.20", "10.0.0.30"}},
+		{flowTimeRange{From: 250, To: 450}, []string{"10.0.0.20"}},
+		{flowTimeRange{From: 200}, []string{"10.0.0.10", "10.0.0.20", "10.0.0.30"}},
+		{flowTimeRange{To: 299}, []string{"10.0.0.10"}},
+		{flowTimeRange{From: 700}, nil},
+	} {
+		var root struct {
+			Children []struct {
+				Name string `json:"name"`
+			} `json:"children"`
+		}
+		if err := json.Unmarshal([]byte(fa.jsonFlowDiscoveryInRange(bytes, []string{"IPV4.B"}, test.timeRange)), &root); err != nil {
+			t.Fatal(err.Error())
+		}
+		var names []string
+		for _, child := range root.Children {
+			names = append(names, child.Name)
+		}
+		if !reflect.DeepEqual(names, test.expected) {
+			t.Errorf("Expected the discovery of %v in %+v, got %v", test.expected, test.timeRange, names)
+		}
+
+		var conversation conversationJSON
+		if err := json.Unmarshal([]byte(fa.jsonFlowConversation(flow.FlowEndpointType_IPV4, conversationOptions{timeRange: test.timeRange})), &conversation); err != nil {
+			t.Fatal(err.Error())
+		}
+		if len(conversation.Links) != len(test.expected) {
+			t.Errorf("Expected %d links in %+v, got %+v", len(test.expected), test.timeRange, conversation.Links)
+		}
+	}
+
+	for _, query := range []string{"from=abc", "to=-1", "from=300&to=200"} {
+		w := httptest.NewRecorder()
+		fa.discoveryType(w, newDiscoveryRequest(t, "/api/flow/discovery/bytes?"+query))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got %d", query, w.Code)
+		}
+
+		w = httptest.NewRecorder()
+		fa.conversationLayer(w, newFakeRequest(t, "/api/flow/conversation/ipv4?"+query))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got %d", query, w.Code)
+		}
+	}
+}
+
+func TestFlowApi_flowCount(t *testing.T) {
+	ft := flow.NewTable()
+	st := &fakeStorage{}
+	st.StoreFlows(flow.GenerateTestFlows(t, ft, 1, "probe-1"))
+	st.StoreFlows(flow.GenerateTestFlows(t, ft, 2, "probe-2"))
+
+	fa := &FlowApi{
+		FlowTable: ft,
+		Storage:   st,
+	}
+
+	for _, query := range []string{"", "?ProbeNodeUUID=probe-1", "?LayersPath=Ethernet/IPv4/UDP/Payload", "?ProbeNodeUUID=unknown"} {
+		w := httptest.NewRecorder()
+		fa.flowSearch(w, newFakeRequest(t, "/api/flow/search"+query))
+
+		var flows []*flow.Flow
+		if err := json.NewDecoder(w.Body).Decode(&flows); err != nil {
+			t.Fatal(err.Error())
+		}
+
+		w = httptest.NewRecorder()
+		fa.flowCount(w, newFakeRequest(t, "/api/flow/count"+query))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", w.Code)
+		}
+
+		var count FlowCount
+		if err := json.NewDecoder(w.Body).Decode(&count); err != nil {
+			t.Fatal(err.Error())
+		}
+
+		if count.Count != len(flows) {
+			t.Errorf("Count %d doesn't match search result length %d for query '%s'", count.Count, len(flows), query)
+		}
+	}
+
+	// the parameters of the searches are ignored
+	w := httptest.NewRecorder()
+	fa.flowCount(w, newFakeRequest(t, "/api/flow/count?ProbeNodeUUID=probe-2&limit=1&offset=1&explain=true"))
+	expected, _ := st.CountFlows(storage.Filters{"ProbeNodeUUID": "probe-2"})
+	var count FlowCount
+	if err := json.NewDecoder(w.Body).Decode(&count); err != nil || count.Count != expected {
+		t.Errorf("Expected the %d flows of probe-2, got %d: %v", expected, count.Count, err)
+	}
+
+	// the unknown filter keys are rejected as by the searches
+	w = httptest.NewRecorder()
+	fa.flowCount(w, newFakeRequest(t, "/api/flow/count?Protocol=TCP"))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an unknown filter key, got %d", w.Code)
+	}
+}
+
+func pollFlowFeed(t *testing.T, fa *FlowApi, query string) *FlowFeedResult {
+	w := httptest.NewRecorder()
+	fa.flowFeed(w, newFakeRequest(t, "/api/flow/feed"+query))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var result FlowFeedResult
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatal(err.Error())
+	}
+	return &result
+}
+
+func TestFlowApi_flowFeed(t *testing.T) {
+	ft := flow.NewTable()
+	st := &fakeStorage{}
+	st.StoreFlows(flow.GenerateTestFlows(t, ft, 1, "probe-1"))
+
+	fa := &FlowApi{
+		FlowTable: ft,
+		Storage:   storage.NewAliasedStorage(st, storage.Aliases{"probe": "ProbeNodeUUID"}),
+	}
+
+	first := pollFlowFeed(t, fa, "")
+	if len(first.Flows) != len(st.flows) {
+		t.Fatalf("The first poll should return all the flows, got %d", len(first.Flows))
+	}
+
+	added := flow.GenerateTestFlows(t, ft, 2, "probe-2")
+	st.StoreFlows(added)
+
+	second := pollFlowFeed(t, fa, "?cursor="+first.Cursor)
+	if len(second.Flows) != len(added) {
+		t.Fatalf("The second poll should return only the added flows, got %d", len(second.Flows))
+	}
+	for i, f := range second.Flows {
+		if f.UUID != added[i].UUID {
+			t.Errorf("Flow %s not added after the first poll", f.UUID)
+		}
+	}
+
+	if third := pollFlowFeed(t, fa, "?cursor="+second.Cursor); len(third.Flows) != 0 || third.Cursor != second.Cursor {
+		t.Errorf("Nothing should be returned without new flows: %+v", third)
+	}
+
+	// the filters and the limit apply to the delta
+	st.StoreFlows(flow.GenerateTestFlows(t, ft, 3, "probe-3"))
+	st.StoreFlows(flow.GenerateTestFlows(t, ft, 4, "probe-4"))
+
+	filtered := pollFlowFeed(t, fa, "?probe=probe-4&cursor="+second.Cursor)
+	if len(filtered.Flows) == 0 {
+		t.Fatal("The flows of probe-4 should be returned")
+	}
+	for _, f := range filtered.Flows {
+		if f.ProbeNodeUUID != "probe-4" {
+			t.Errorf("Flow of %s not filtered", f.ProbeNodeUUID)
+		}
+	}
+
+	limited := pollFlowFeed(t, fa, "?limit=1&cursor="+second.Cursor)
+	if len(limited.Flows) != 1 || limited.Flows[0].ProbeNodeUUID != "probe-3" {
+		t.Errorf("Only the oldest flow should be returned: %+v", limited.Flows)
+	}
+
+	for _, query := range []string{"?cursor=abc", "?limit=0", "?unknown=1"} {
+		w := httptest.NewRecorder()
+		fa.flowFeed(w, newFakeRequest(t, "/api/flow/feed"+query))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got %d", query, w.Code)
+		}
+	}
+}
+
+func newDurationTestFlow(uuid string, start int64, last int64) *flow.Flow {
+	return &flow.Flow{
+		UUID:       uuid,
+		LayersPath: "Ethernet/IPv4/TCP",
+		Statistics: &flow.FlowStatistics{Start: start, Last: last},
+		Duration:   last - start,
+	}
+}
+
+func TestFlowApi_searchByDuration(t *testing.T) {
+	st := &fakeStorage{}
+	st.StoreFlows([]*flow.Flow{
+		newDurationTestFlow("scan-1", 1000, 1000),
+		newDurationTestFlow("scan-2", 2000, 2001),
+		newDurationTestFlow("web", 3000, 3030),
+		newDurationTestFlow("exfiltration", 0, 7200),
+	})
+
+	fa := &FlowApi{
+		FlowTable: flow.NewTable(),
+		Storage:   storage.NewAliasedStorage(st, storage.Aliases{"duration": "Duration"}),
+	}
+
+	tests := []struct {
+		query string
+		uuids []string
+	}{
+		{"Duration=gt:3600", []string{"exfiltration"}},
+		{"Duration=gte:30", []string{"web", "exfiltration"}},
+		{"Duration=lt:2", []string{"scan-1", "scan-2"}},
+		{"Duration=lte:0", []string{"scan-1"}},
+		{"Duration=30", []string{"web"}},
+		{"Duration=gt:0&LayersPath=Ethernet/IPv4/TCP", []string{"scan-2", "web", "exfiltration"}},
+		{"duration=gt:3600", []string{"exfiltration"}},
+		{"Duration.gt=3600", []string{"exfiltration"}},
+		{"Duration.gte=2&duration.lt=3600", []string{"web"}},
+		{"Statistics.Last.gte=2001&Statistics.Last.lte=3030", []string{"scan-2", "web"}},
+		{"Statistics.Last__gt=2001&duration__lt=3600", []string{"web"}},
+		{"Duration__in=0,7200", []string{"scan-1", "exfiltration"}},
+		{"UUID__in=web,scan-2,unknown", []string{"scan-2", "web"}},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		fa.flowSearch(w, newFakeRequest(t, "/api/flow/search?"+test.query))
+
+		var flows []*flow.Flow
+		if err := json.NewDecoder(w.Body).Decode(&flows); err != nil {
+			t.Fatal(err.Error())
+		}
+
+		var uuids []string
+		for _, f := range flows {
+			uuids = append(uuids, f.UUID)
+		}
+		if !reflect.DeepEqual(uuids, test.uuids) {
+			t.Errorf("%s: expected %v, got %v", test.query, test.uuids, uuids)
+		}
+	}
+
+	for _, query := range []string{"Duration=longer:10", "unknown_alias=1", "Duration.longer=10", "LayersPath__lt=10"} {
+		w := httptest.NewRecorder()
+		fa.flowSearch(w, newFakeRequest(t, "/api/flow/search?"+query))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", query, w.Code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	fa.flowSearch(w, newFakeRequest(t, "/api/flow/search?Duration.longer=10"))
+	if body := w.Body.String(); !strings.Contains(body, "Unknown operator: longer") {
+		t.Errorf("Expected the unknown operator to be explained, got %s", body)
+	}
+}
+
+func TestFlowApi_searchUnknownFilterKeys(t *testing.T) {
+	st := &fakeStorage{}
+	st.StoreFlows([]*flow.Flow{
+		newDurationTestFlow("web", 3000, 3030),
+		newDurationTestFlow("exfiltration", 0, 7200),
+	})
+
+	fa := &FlowApi{
+		FlowTable: flow.NewTable(),
+		Storage:   storage.NewAliasedStorage(st, storage.Aliases{"duration": "Duration"}),
+	}
+
+	// strict, the default, rejects the search listing the valid keys
+	w := httptest.NewRecorder()
+	fa.flowSearch(w, newFakeRequest(t, "/api/flow/search?duration=gt:3600&Protocol=TCP&explain=false"))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d", w.Code)
+	}
+	body := w.Body.String()
+	for _, expected := range []string{"Unknown filter keys: Protocol,", "valid keys: duration, A_Role", "Attributes.<name>", "TCPPORT.A"} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Expected %q in the reply: %s", expected, body)
+		}
+	}
+
+	// lenient ignores the unknown keys with a warning
+	fa.LenientFilters = true
+	w = httptest.NewRecorder()
+	fa.flowSearch(w, newFakeRequest(t, "/api/flow/search?duration=gt:3600&Protocol=TCP"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if warning := w.Header().Get("Warning"); warning != `199 skydive "Unknown filter keys ignored: Protocol"` {
+		t.Errorf("Wrong warning header: %s", warning)
+	}
+
+	var flows []*flow.Flow
+	if err := json.NewDecoder(w.Body).Decode(&flows); err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(flows) != 1 || flows[0].UUID != "exfiltration" {
+		t.Errorf("Expected the search without the unknown key, got %v", flows)
+	}
+
+	// no warning without unknown key
+	w = httptest.NewRecorder()
+	fa.flowSearch(w, newFakeRequest(t, "/api/flow/search?duration=gt:3600"))
+	if w.Code != http.StatusOK || w.Header().Get("Warning") != "" {
+		t.Errorf("Expected no warning, got %d: %s", w.Code, w.Header().Get("Warning"))
+	}
+}
+
+type srcNodeEnhancer struct {
+}
+
+func (e *srcNodeEnhancer) Enhance(f *flow.Flow) {
+	if f.IfSrcNodeUUID == "" {
+		f.IfSrcNodeUUID = "node-" + f.UUID
+	}
+}
+
+func TestFlowApi_searchExplain(t *testing.T) {
+	flows := []*flow.Flow{{UUID: "enhanced"}, {UUID: "stored"}}
+
+	pipeline := mappings.NewFlowMappingPipeline(&srcNodeEnhancer{})
+	pipeline.SetProvenance(10)
+	pipeline.Enhance(flows[:1])
+
+	st := &fakeStorage{}
+	st.StoreFlows(flows)
+
+	fa := &FlowApi{
+		FlowTable: flow.NewTable(),
+		Storage:   storage.NewAliasedStorage(st, storage.Aliases{}),
+		Pipeline:  pipeline,
+	}
+
+	w := httptest.NewRecorder()
+	fa.flowSearch(w, newFakeRequest(t, "/api/flow/search?explain=true"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var explanations []FlowExplanation
+	if err := json.NewDecoder(w.Body).Decode(&explanations); err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(explanations) != 2 {
+		t.Fatalf("Expected 2 flows, got %+v", explanations)
+	}
+
+	provenance := explanations[0].Provenance
+	if len(provenance) != 1 || provenance[0].Field != "IfSrcNodeUUID" || provenance[0].Value != "node-enhanced" || provenance[0].Stage != "srcNodeEnhancer" {
+		t.Errorf("Wrong provenance of the enhanced flow: %+v", provenance)
+	}
+	if len(explanations[1].Provenance) != 0 {
+		t.Errorf("Expected no provenance for the flow not enhanced, got %+v", explanations[1].Provenance)
+	}
+}
+
+func newNATTestFlow(uuid string, a string, b string, bytes uint64, attributes map[string]string) *flow.Flow {
+	return &flow.Flow{
+		UUID:       uuid,
+		LayersPath: "Ethernet/IPv4/TCP",
+		Statistics: &flow.FlowStatistics{
+			Endpoints: []*flow.FlowEndpointsStatistics{
+				{
+					Type: flow.FlowEndpointType_IPV4,
+					AB:   &flow.FlowEndpointStatistics{Value: a, Bytes: bytes},
+					BA:   &flow.FlowEndpointStatistics{Value: b, Bytes: bytes},
+				},
+			},
+		},
+		Attributes: attributes,
+	}
+}
+
+func decodeConversation(t *testing.T, conversation string) (nodes []interface{}, links []interface{}) {
+	var decoded struct {
+		Nodes []interface{} `json:"nodes"`
+		Links []interface{} `json:"links"`
+	}
+	if err := json.Unmarshal([]byte(conversation), &decoded); err != nil {
+		t.Fatal("JSON parsing failed:", err)
+	}
+	return decoded.Nodes, decoded.Links
+}
+
+// pagedStorage limits the searches itself, recording the pages requested
+// and their context
+type pagedStorage struct {
+	fakeStorage
+	limit, offset int
+	ctx           context.Context
+}
+
+func (s *pagedStorage) SearchFlowsPaged(ctx context.Context, q storage.SearchQuery) ([]*flow.Flow, int, error) {
+	s.limit, s.offset, s.ctx = q.Limit, q.Offset, ctx
+	flows, _ := s.SearchFlows(q.Filters)
+	return storage.PageFlows(flows, q), len(flows), nil
+}
+
+func TestFlowApi_searchPaged(t *testing.T) {
+	st := &pagedStorage{}
+	for i := 1; i <= 5; i++ {
+		st.StoreFlows([]*flow.Flow{{UUID: strconv.Itoa(i), Statistics: &flow.FlowStatistics{Last: int64(i)}}})
+	}
+	fa := &FlowApi{Storage: storage.NewAliasedStorage(st, storage.Aliases{})}
+
+	search := func(url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		fa.flowSearch(w, newFakeRequest(t, url))
+		return w
+	}
+
+	for _, test := range []struct {
+		url        string
+		uuids      []string
+		nextOffset int
+	}{
+		{"/api/flow/search?limit=2", []string{"5", "4"}, 2},
+		{"/api/flow/search?limit=2&offset=2", []string{"3", "2"}, 4},
+		{"/api/flow/search?limit=2&offset=4", []string{"1"}, 0},
+		{"/api/flow/search?limit=2&offset=10", []string{}, 0},
+	} {
+		w := search(test.url)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d: %s", test.url, w.Code, w.Body.String())
+		}
+
+		var page struct {
+			Flows      []*flow.Flow `json:"flows"`
+			Total      int          `json:"total"`
+			NextOffset *int         `json:"next_offset"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+			t.Fatal(err.Error())
+		}
+
+		var uuids []string
+		for _, f := range page.Flows {
+			uuids = append(uuids, f.UUID)
+		}
+		if strings.Join(uuids, ",") != strings.Join(test.uuids, ",") || page.Total != 5 {
+			t.Errorf("%s: expected the flows %v of 5, got %v of %d", test.url, test.uuids, uuids, page.Total)
+		}
+		if (test.nextOffset == 0 && page.NextOffset != nil) || (test.nextOffset != 0 && (page.NextOffset == nil || *page.NextOffset != test.nextOffset)) {
+			t.Errorf("%s: expected the next offset %d, got %v", test.url, test.nextOffset, page.NextOffset)
+		}
+	}
+
+	// the page is given to the storage
+	if st.limit != 2 || st.offset != 10 {
+		t.Errorf("Expected the storage to page the search, got limit %d offset %d", st.limit, st.offset)
+	}
+
+	// without limit, all the flows are returned as before
+	w := search("/api/flow/search")
+	var flows []*flow.Flow
+	if err := json.NewDecoder(w.Body).Decode(&flows); err != nil || len(flows) != 5 {
+		t.Errorf("Expected the 5 flows, got %d: %v", len(flows), err)
+	}
+
+	for _, url := range []string{
+		"/api/flow/search?limit=2&offset=-1",
+		"/api/flow/search?limit=-2",
+		"/api/flow/search?limit=abc",
+		"/api/flow/search?offset=2",
+	} {
+		if w := search(url); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", url, w.Code)
+		}
+	}
+}
+
+func TestFlowApi_searchSorted(t *testing.T) {
+	st := &pagedStorage{}
+	st.StoreFlows([]*flow.Flow{
+		newDurationTestFlow("web", 3000, 3030),
+		newDurationTestFlow("exfiltration", 0, 7200),
+		newDurationTestFlow("scan", 1000, 1000),
+	})
+	fa := &FlowApi{Storage: storage.NewAliasedStorage(st, storage.Aliases{"duration": "Duration"})}
+
+	for query, expected := range map[string][]string{
+		"sort=Statistics.Last:desc":                           {"exfiltration", "web", "scan"},
+		"sort=Statistics.Start":                               {"exfiltration", "scan", "web"},
+		"sort=duration:desc&LayersPath__in=Ethernet/IPv4/TCP": {"exfiltration", "web", "scan"},
+		"sort=Duration&limit=2":                               {"scan", "web"},
+	} {
+		w := httptest.NewRecorder()
+		fa.flowSearch(w, newFakeRequest(t, "/api/flow/search?"+query))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d: %s", query, w.Code, w.Body.String())
+		}
+
+		var flows []*flow.Flow
+		if strings.Contains(query, "limit") {
+			var page struct {
+				Flows []*flow.Flow `json:"flows"`
+			}
+			json.NewDecoder(w.Body).Decode(&page)
+			flows = page.Flows
+		} else {
+			json.NewDecoder(w.Body).Decode(&flows)
+		}
+
+		var uuids []string
+		for _, f := range flows {
+			uuids = append(uuids, f.UUID)
+		}
+		if !reflect.DeepEqual(uuids, expected) {
+			t.Errorf("%s: expected %v, got %v", query, expected, uuids)
+		}
+	}
+
+	for _, query := range []string{"sort=Bytes:desc", "sort=UUID", "sort=Duration:sideways"} {
+		w := httptest.NewRecorder()
+		fa.flowSearch(w, newFakeRequest(t, "/api/flow/search?"+query))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", query, w.Code)
+		}
+	}
+}
+
+func TestFlowApi_searchDefaultLimit(t *testing.T) {
+	st := &pagedStorage{}
+	for i := 1; i <= 5; i++ {
+		st.StoreFlows([]*flow.Flow{{UUID: strconv.Itoa(i), Statistics: &flow.FlowStatistics{Last: int64(i)}}})
+	}
+	fa := &FlowApi{Storage: st, DefaultLimit: 3}
+
+	// without limit, the latest flows are returned as an array
+	w := httptest.NewRecorder()
+	fa.flowSearch(w, newFakeRequest(t, "/api/flow/search"))
+	var flows []*flow.Flow
+	if err := json.NewDecoder(w.Body).Decode(&flows); err != nil || len(flows) != 3 || flows[0].UUID != "5" {
+		t.Fatalf("Expected the 3 latest flows, got %d: %v", len(flows), err)
+	}
+	if st.limit != 3 || st.offset != 0 {
+		t.Errorf("Expected the storage to limit the search, got limit %d offset %d", st.limit, st.offset)
+	}
+	if warning := w.Header().Get("Warning"); warning != `199 skydive "Search limited to 3 of 5 flows, page with limit and offset"` {
+		t.Errorf("Expected a warning for the flows left out, got %q", warning)
+	}
+
+	// an explicit limit overrides the default one
+	w = httptest.NewRecorder()
+	fa.flowSearch(w, newFakeRequest(t, "/api/flow/search?limit=5"))
+	if w.Code != http.StatusOK || w.Header().Get("Warning") != "" || st.limit != 5 {
+		t.Errorf("Expected the 5 flows without warning, got %d %q limit %d", w.Code, w.Header().Get("Warning"), st.limit)
+	}
+
+	// no warning when all the flows fit
+	fa.DefaultLimit = 10
+	w = httptest.NewRecorder()
+	fa.flowSearch(w, newFakeRequest(t, "/api/flow/search"))
+	if w.Header().Get("Warning") != "" {
+		t.Errorf("Expected no warning, got %q", w.Header().Get("Warning"))
+	}
+
+	// the limited searches keep the request ID and the cancellation of the
+	// request
+	req := newFakeRequest(t, "/api/flow/search")
+	ctx, cancel := context.WithCancel(logging.NewContext(context.Background(), "request_id", "limited"))
+	cancel()
+	shttp.SetRequestContext(&req.Request, ctx)
+	defer gcontext.Clear(&req.Request)
+	fa.flowSearch(httptest.NewRecorder(), req)
+	if logging.ContextField(st.ctx, "request_id") != "limited" || st.ctx.Err() != context.Canceled {
+		t.Errorf("Expected the search to be given the context of the request")
+	}
+}
+
+func TestFlowApi_flowSchema(t *testing.T) {
+	fa := &FlowApi{Pipeline: mappings.NewFlowMappingPipeline()}
+
+	w := httptest.NewRecorder()
+	fa.flowSchema(w, newFakeRequest(t, "/api/flow/schema"))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 without schema, got %d", w.Code)
+	}
+
+	schema, err := mappings.NewAttributeSchema(mappings.NewJA3FlowEnhancer(false))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	fa.Pipeline.SetAttributeSchema(schema, true)
+
+	w = httptest.NewRecorder()
+	fa.flowSchema(w, newFakeRequest(t, "/api/flow/schema"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var result FlowSchema
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatal(err.Error())
+	}
+	if !result.Strict || result.Stats.Violations != 0 {
+		t.Errorf("Expected the strict mode without violation, got %+v", result)
+	}
+	for _, decl := range result.Attributes {
+		if decl.Name == flow.FlowAttributeJA3 && !reflect.DeepEqual(decl.Enhancers, []string{"flow", "JA3FlowEnhancer"}) {
+			t.Errorf("Expected JA3 to be set by the agents and the JA3 enhancer, got %+v", decl)
+		}
+	}
+}
+
+func TestFlowApi_conversationNATCollapse(t *testing.T) {
+	// same conversation seen before and after the source NAT
+	ft := flow.NewTableFromFlows([]*flow.Flow{
+		newNATTestFlow("pre", "10.0.0.1", "8.8.8.8", 100, map[string]string{flow.FlowAttributeNATA: "203.0.113.1"}),
+		newNATTestFlow("post", "203.0.113.1", "8.8.8.8", 100, nil),
+	})
+
+	fa := &FlowApi{
+		FlowTable:   ft,
+		NATCollapse: true,
+	}
+
+	nodes, links := decodeConversation(t, fa.jsonFlowConversationEthernetPath(flow.FlowEndpointType_IPV4))
+	if len(nodes) != 2 || len(links) != 1 {
+		t.Fatalf("Pre and post NAT endpoints should be collapsed, got nodes %v links %v", nodes, links)
+	}
+
+	if value := links[0].(map[string]interface{})["value"].(float64); value != 400 {
+		t.Errorf("Collapsed link should aggregate both flows, got %f", value)
+	}
+
+	fa.NATCollapse = false
+	nodes, links = decodeConversation(t, fa.jsonFlowConversationEthernetPath(flow.FlowEndpointType_IPV4))
+	if len(nodes) != 3 || len(links) != 2 {
+		t.Errorf("Endpoints shouldn't be collapsed when disabled, got nodes %v links %v", nodes, links)
+	}
+}
+
+func TestFlowApi_natTranslationsMissingSide(t *testing.T) {
+	missingBA := newNATTestFlow("missing-ba", "10.0.0.1", "8.8.8.8", 100, map[string]string{
+		flow.FlowAttributeNATA: "203.0.113.1",
+		flow.FlowAttributeNATB: "198.51.100.1",
+	})
+	missingBA.Statistics.Endpoints[0].BA = nil
+
+	missingAB := newNATTestFlow("missing-ab", "10.0.0.2", "8.8.4.4", 100, map[string]string{
+		flow.FlowAttributeNATA: "203.0.113.2",
+		flow.FlowAttributeNATB: "198.51.100.2",
+	})
+	missingAB.Statistics.Endpoints[0].AB = nil
+
+	translations := natTranslations([]*flow.Flow{missingBA, missingAB})
+	expected := map[string]string{"203.0.113.1": "10.0.0.1", "198.51.100.2": "8.8.4.4"}
+	if !reflect.DeepEqual(translations, expected) {
+		t.Errorf("Expected the translations %v of the sides present, got %v", expected, translations)
+	}
+}
+
+func TestFlowApi_conversationTags(t *testing.T) {
+	ft := flow.NewTableFromFlows([]*flow.Flow{
+		newNATTestFlow("pre", "10.0.0.1", "8.8.8.8", 100, map[string]string{
+			flow.FlowAttributeNATA: "203.0.113.1",
+			"Tag.segment":          "pci",
+			"Tag.ticket":           "CHG-1234",
+		}),
+		newNATTestFlow("post", "203.0.113.1", "8.8.8.8", 100, map[string]string{"Tag.segment": "pci"}),
+	})
+
+	fa := &FlowApi{
+		FlowTable:   ft,
+		NATCollapse: true,
+	}
+
+	// only the tags shared by all the flows of the link are reported
+	_, links := decodeConversation(t, fa.jsonFlowConversationEthernetPath(flow.FlowEndpointType_IPV4))
+	if len(links) != 1 {
+		t.Fatalf("Expected a collapsed link, got %v", links)
+	}
+	tags, _ := links[0].(map[string]interface{})["tags"].(map[string]interface{})
+	if len(tags) != 1 || tags["Tag.segment"] != "pci" {
+		t.Errorf("Expected the shared tag only, got %v", tags)
+	}
+
+	fa.NATCollapse = false
+	_, links = decodeConversation(t, fa.jsonFlowConversationEthernetPath(flow.FlowEndpointType_IPV4))
+	for _, link := range links {
+		tags, _ := link.(map[string]interface{})["tags"].(map[string]interface{})
+		if tags["Tag.segment"] != "pci" || tags[flow.FlowAttributeNATA] != nil {
+			t.Errorf("Expected the tags of the flow, got %v", tags)
+		}
+	}
+}
+
+func newSymmetryTestFlow(uuid string, a string, b string, abPackets uint64, baPackets uint64) *flow.Flow {
+	f := newNATTestFlow(uuid, a, b, 100, nil)
+	f.Statistics.Endpoints[0].AB.Packets = abPackets
+	f.Statistics.Endpoints[0].BA.Packets = baPackets
+	return f
+}
+
+func TestFlowApi_conversationSymmetry(t *testing.T) {
+	server := newSymmetryTestFlow("server", "10.0.0.5", "10.0.0.6", 0, 7)
+	server.A_Role, server.B_Role = flow.FlowRoleServer, flow.FlowRoleClient
+
+	fa := &FlowApi{
+		FlowTable: flow.NewTableFromFlows([]*flow.Flow{
+			newSymmetryTestFlow("bidirectional", "10.0.0.1", "10.0.0.2", 5, 3),
+			newSymmetryTestFlow("oneway", "10.0.0.3", "10.0.0.4", 10, 0),
+			server,
+		}),
+	}
+
+	decode := func(opts conversationOptions) ([]conversationJSONNode, []conversationJSONLink) {
+		var conversation conversationJSON
+		if err := json.Unmarshal([]byte(fa.jsonFlowConversation(flow.FlowEndpointType_IPV4, opts)), &conversation); err != nil {
+			t.Fatal("JSON parsing failed:", err)
+		}
+		return conversation.Nodes, conversation.Links
+	}
+
+	_, links := decode(conversationOptions{})
+	for _, link := range links {
+		if link.Symmetric != nil {
+			t.Errorf("Links shouldn't be annotated by default: %+v", link)
+		}
+	}
+
+	nodes, links := decode(conversationOptions{symmetry: true})
+	if len(links) != 3 {
+		t.Fatalf("Expected 3 links, got %+v", links)
+	}
+	for _, link := range links {
+		symmetric := nodes[link.Source].Name == "10.0.0.1"
+		if link.Symmetric == nil || *link.Symmetric != symmetric {
+			t.Errorf("Wrong symmetry for the link %s -> %s: %+v", nodes[link.Source].Name, nodes[link.Target].Name, link)
+		}
+	}
+
+	nodes, links = decode(conversationOptions{asymmetricOnly: true})
+	if len(nodes) != 4 || len(links) != 2 {
+		t.Fatalf("Expected the 2 asymmetric links and their endpoints, got nodes %+v links %+v", nodes, links)
+	}
+	for _, link := range links {
+		if link.Symmetric == nil || *link.Symmetric {
+			t.Errorf("Only asymmetric links expected: %+v", link)
+		}
+	}
+
+	// the client to server link only seen from the server
+	if link := links[1]; nodes[link.Source].Name != "10.0.0.6" || !link.Directed {
+		t.Errorf("Wrong link for the server flow: %+v", link)
+	}
+
+	// each direction of the NATed conversation seen by a different flow
+	pre := newSymmetryTestFlow("pre", "10.0.0.1", "8.8.8.8", 5, 0)
+	pre.Attributes = map[string]string{flow.FlowAttributeNATA: "203.0.113.1"}
+	fa = &FlowApi{
+		FlowTable:   flow.NewTableFromFlows([]*flow.Flow{pre, newSymmetryTestFlow("post", "203.0.113.1", "8.8.8.8", 0, 5)}),
+		NATCollapse: true,
+	}
+
+	_, links = decode(conversationOptions{symmetry: true})
+	if len(links) != 1 || links[0].Symmetric == nil || !*links[0].Symmetric {
+		t.Errorf("Collapsed conversation should be symmetric: %+v", links)
+	}
+}
+
+func TestFlowApi_conversationLegacyClient(t *testing.T) {
+	f := newNATTestFlow("flow", "00:00:00:00:00:01", "00:00:00:00:00:02", 100, nil)
+	f.Statistics.Endpoints[0].Type = flow.FlowEndpointType_ETHERNET
+	fa := &FlowApi{FlowTable: flow.NewTableFromFlows([]*flow.Flow{f})}
+
+	// the layer defaulting to ethernet
+	conversation := func(clientVersion string) map[string]interface{} {
+		req := newFakeRequest(t, "/api/flow/conversation/ethernet")
+		if clientVersion != "" {
+			req.Header.Set(shttp.ClientVersionHeader, clientVersion)
+		}
+
+		w := httptest.NewRecorder()
+		fa.conversationLayer(w, req)
+
+		_, links := decodeConversation(t, w.Body.String())
+		if len(links) != 1 {
+			t.Fatalf("Expected a link, got %s", w.Body.String())
+		}
+		return links[0].(map[string]interface{})
+	}
+
+	for _, v := range []string{"", version.APIVersion} {
+		if _, ok := conversation(v)["directed"]; !ok {
+			t.Errorf("Client version %q: expected the current format", v)
+		}
+	}
+
+	// the clients of the previous major get the links without direction
+	link := conversation(version.MinClientAPIVersion)
+	if _, ok := link["directed"]; ok || link["value"].(float64) != 200 {
+		t.Errorf("Expected the legacy format, got %v", link)
+	}
+}
+
+func TestFlowApi_conversationWithoutNAT(t *testing.T) {
+	ft := flow.NewTableFromFlows([]*flow.Flow{
+		newNATTestFlow("flow1", "10.0.0.1", "8.8.8.8", 100, nil),
+		newNATTestFlow("flow2", "10.0.0.1", "8.8.8.8", 100, nil),
+		newNATTestFlow("flow3", "10.0.0.2", "8.8.8.8", 100, nil),
+	})
+
+	fa := &FlowApi{
+		FlowTable: ft,
+	}
+	expected := fa.jsonFlowConversationEthernetPath(flow.FlowEndpointType_IPV4)
+
+	fa.NATCollapse = true
+	nodes, links := decodeConversation(t, fa.jsonFlowConversationEthernetPath(flow.FlowEndpointType_IPV4))
+	expectedNodes, expectedLinks := decodeConversation(t, expected)
+
+	if len(nodes) != len(expectedNodes) || len(links) != len(expectedLinks) || len(links) != 3 {
+		t.Errorf("Conversation shouldn't change without NAT information, got nodes %v links %v", nodes, links)
+	}
+}
+
+func orderingTestFlows() []*flow.Flow {
+	flows := []*flow.Flow{
+		newNATTestFlow("flow-c", "10.0.0.3", "8.8.8.8", 300, nil),
+		newNATTestFlow("flow-a", "10.0.0.1", "8.8.4.4", 100, map[string]string{flow.FlowAttributeNATA: "203.0.113.1"}),
+		newNATTestFlow("flow-b", "203.0.113.1", "8.8.4.4", 200, nil),
+		newDiscoveryTestFlow("flow-d", "probe1", "10.0.0.2", "10.0.0.10", "80", 50),
+		newDiscoveryTestFlow("flow-e", "probe2", "10.0.0.2", "10.0.0.11", "443", 70),
+	}
+	flows[0].A_Role, flows[0].B_Role = flow.FlowRoleServer, flow.FlowRoleClient
+	flows[0].LayersPath = "Ethernet/IPv4/UDP"
+	return flows
+}
+
+func TestFlowApi_deterministicOutput(t *testing.T) {
+	flows := orderingTestFlows()
+	reversed := make([]*flow.Flow, len(flows))
+	for i, f := range flows {
+		reversed[len(flows)-1-i] = f
+	}
+
+	fa1 := &FlowApi{FlowTable: flow.NewTableFromFlows(flows), NATCollapse: true}
+	fa2 := &FlowApi{FlowTable: flow.NewTableFromFlows(reversed), NATCollapse: true}
+
+	for i := 0; i < 10; i++ {
+		for _, et := range []flow.FlowEndpointType{flow.FlowEndpointType_ETHERNET, flow.FlowEndpointType_IPV4, flow.FlowEndpointType_TCPPORT} {
+			c1, c2 := fa1.jsonFlowConversationEthernetPath(et), fa2.jsonFlowConversationEthernetPath(et)
+			if c1 != c2 {
+				t.Fatalf("Conversations %s differ:\n%s\n%s", et, c1, c2)
+			}
+		}
+
+		for _, fields := range [][]string{nil, {"ProbeNodeUUID", "IPV4.B"}} {
+			d1, d2 := fa1.jsonFlowDiscovery(bytes, fields), fa2.jsonFlowDiscovery(bytes, fields)
+			if d1 != d2 {
+				t.Fatalf("Discoveries differ:\n%s\n%s", d1, d2)
+			}
+		}
+	}
+}
+
+func TestFlowApi_conversationOrdering(t *testing.T) {
+	fa := &FlowApi{FlowTable: flow.NewTableFromFlows(orderingTestFlows())}
+
+	expected := `{"nodes":[` +
+		`{"name":"10.0.0.1","group":0},{"name":"10.0.0.10","group":0},{"name":"10.0.0.11","group":0},` +
+		`{"name":"10.0.0.2","group":0},{"name":"10.0.0.3","group":1},{"name":"203.0.113.1","group":0},` +
+		`{"name":"8.8.4.4","group":0},{"name":"8.8.8.8","group":1}],` +
+		`"links":[` +
+		`{"source":0,"target":6,"value":200,"directed":false},{"source":3,"target":1,"value":0,"directed":false},` +
+		`{"source":3,"target":2,"value":0,"directed":false},{"source":5,"target":6,"value":400,"directed":false},` +
+		`{"source":7,"target":4,"value":600,"directed":true}]}`
+
+	if conversation := fa.jsonFlowConversationEthernetPath(flow.FlowEndpointType_IPV4); conversation != expected {
+		t.Errorf("Unexpected conversation:\n%s\nexpected:\n%s", conversation, expected)
+	}
+
+	disco := fa.jsonFlowDiscovery(bytes, []string{"IPV4.B"})
+	if disco != `{"name":"root","children":[{"name":"10.0.0.10","size":100,"children":[]},{"name":"10.0.0.11","size":140,"children":[]}]}` {
+		t.Errorf("Unexpected discovery: %s", disco)
+	}
+}
+
+func TestFlowApi_emptyOutput(t *testing.T) {
+	fa := &FlowApi{FlowTable: flow.NewTable()}
+
+	if conversation := fa.jsonFlowConversationEthernetPath(flow.FlowEndpointType_IPV4); conversation != `{"nodes":[],"links":[]}` {
+		t.Errorf("Unexpected empty conversation: %s", conversation)
+	}
+
+	if disco := fa.jsonFlowDiscovery(packets, nil); disco != `{"name":"root","children":[]}` {
+		t.Errorf("Unexpected empty discovery: %s", disco)
+	}
+}
+
+func TestFlowApi_discoveryETag(t *testing.T) {
+	fa := &FlowApi{
+		FlowTable: flow.NewTable(),
+	}
+	fa.FlowTable.Update([]*flow.Flow{
+		newDiscoveryTestFlow("flow1", "probe1", "10.0.0.1", "10.0.0.2", "80", 100),
+	})
+
+	w := httptest.NewRecorder()
+	fa.discoveryType(w, newDiscoveryRequest(t, "/api/flow/discovery/bytes"))
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag == "" {
+		t.Fatalf("Expected status 200 with an ETag, got %d %q", w.Code, etag)
+	}
+
+	req := newDiscoveryRequest(t, "/api/flow/discovery/bytes")
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	fa.discoveryType(w, req)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("Expected status 304, got %d", w.Code)
+	}
+
+	fa.FlowTable.Update([]*flow.Flow{
+		newDiscoveryTestFlow("flow2", "probe1", "10.0.0.1", "10.0.0.3", "443", 200),
+	})
+	w = httptest.NewRecorder()
+	fa.discoveryType(w, req)
+	if w.Code != http.StatusOK || w.Header().Get("ETag") == etag {
+		t.Errorf("Expected status 200 with a new ETag, got %d %q", w.Code, w.Header().Get("ETag"))
+	}
+}
+
+func newPortTestFlow(uuid string, a string, b string, ports [2]string, roles [2]string, bytes uint64) *flow.Flow {
+	return &flow.Flow{
+		UUID:       uuid,
+		LayersPath: "Ethernet/IPv4/TCP",
+		A_Role:     roles[0],
+		B_Role:     roles[1],
+		Statistics: &flow.FlowStatistics{
+			Endpoints: []*flow.FlowEndpointsStatistics{
+				{
+					Type: flow.FlowEndpointType_IPV4,
+					AB:   &flow.FlowEndpointStatistics{Value: a, Bytes: bytes, Packets: 1},
+					BA:   &flow.FlowEndpointStatistics{Value: b, Bytes: 2 * bytes, Packets: 2},
+				},
+				{
+					Type: flow.FlowEndpointType_TCPPORT,
+					AB:   &flow.FlowEndpointStatistics{Value: ports[0]},
+					BA:   &flow.FlowEndpointStatistics{Value: ports[1]},
+				},
+			},
+		},
+	}
+}
+
+func TestFlowApi_conversationPorts(t *testing.T) {
+	client := [2]string{flow.FlowRoleClient, flow.FlowRoleServer}
+	server := [2]string{flow.FlowRoleServer, flow.FlowRoleClient}
+
+	fa := &FlowApi{
+		FlowTable: flow.NewTableFromFlows([]*flow.Flow{
+			newPortTestFlow("http1", "10.0.0.1", "10.0.0.2", [2]string{"34567", "80"}, client, 100),
+			newPortTestFlow("http2", "10.0.0.1", "10.0.0.2", [2]string{"34568", "80"}, client, 50),
+			// reverse direction, the server being A
+			newPortTestFlow("https", "10.0.0.2", "10.0.0.1", [2]string{"443", "45678"}, server, 30),
+			// unknown roles, the lowest port is used
+			newPortTestFlow("ssh", "10.0.0.1", "10.0.0.2", [2]string{"50000", "22"}, [2]string{}, 10),
+			newNATTestFlow("icmp", "10.0.0.2", "10.0.0.1", 5, nil),
+			// other conversation
+			newPortTestFlow("other", "10.0.0.1", "10.0.0.3", [2]string{"34567", "80"}, client, 1000),
+		}),
+	}
+
+	conversation := fa.conversationPorts(flow.FlowEndpointType_IPV4, "10.0.0.1", "10.0.0.2", 0)
+	if conversation.Flows != 5 || conversation.Bytes != 3*190+10 {
+		t.Fatalf("Wrong conversation total: %+v", conversation)
+	}
+
+	var flows int
+	var bytes, packets uint64
+	for _, port := range conversation.Ports {
+		flows += port.Flows
+		bytes += port.Bytes
+		packets += port.Packets
+	}
+	if flows != conversation.Flows || bytes != conversation.Bytes || packets != conversation.Packets {
+		t.Errorf("The ports should sum to the conversation total: %+v", conversation)
+	}
+
+	expected := []conversationPort{
+		{Protocol: "tcp", Port: "80", Flows: 2, Bytes: 450, Packets: 6},
+		{Protocol: "tcp", Port: "443", Flows: 1, Bytes: 90, Packets: 3},
+		{Protocol: "tcp", Port: "22", Flows: 1, Bytes: 30, Packets: 3},
+		{Protocol: "", Port: "", Flows: 1, Bytes: 10},
+	}
+	if !reflect.DeepEqual(conversation.Ports, expected) {
+		t.Errorf("Expected ports %+v, got %+v", expected, conversation.Ports)
+	}
+
+	// the ports beyond the top ones are grouped, keeping the total
+	conversation = fa.conversationPorts(flow.FlowEndpointType_IPV4, "10.0.0.2", "10.0.0.1", 1)
+	if len(conversation.Ports) != 2 || conversation.Ports[1].Bytes != 130 || conversation.Ports[1].Flows != 3 {
+		t.Errorf("Wrong top ports: %+v", conversation.Ports)
+	}
+
+	w := httptest.NewRecorder()
+	fa.conversationPortsLayer(w, newFakeRequest(t, "/api/flow/conversation/ipv4/ports?a=10.0.0.1"))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+}
+
+// contextStorage records the request ID and the compact mode of the context
+// of the searches
+type contextStorage struct {
+	fakeStorage
+	sync.Mutex
+	requestID string
+	compact   bool
+}
+
+func (s *contextStorage) SearchFlowsContext(ctx context.Context, filters storage.Filters) ([]*flow.Flow, error) {
+	s.Lock()
+	s.requestID = logging.ContextField(ctx, "request_id")
+	s.compact = storage.CompactAttributes(ctx)
+	s.Unlock()
+	return s.SearchFlows(filters)
+}
+
+func (s *contextStorage) lastRequestID() string {
+	s.Lock()
+	defer s.Unlock()
+	return s.requestID
+}
+
+func TestFlowApi_requestID(t *testing.T) {
+	st := &contextStorage{}
+	server := shttp.NewServer("analyzer", "127.0.0.1", 0, shttp.NewNoAuthenticationBackend())
+	fa := &FlowApi{Storage: storage.NewAliasedStorage(st, storage.Aliases{})}
+	fa.registerEndpoints(server)
+
+	ts := httptest.NewServer(server.Router)
+	defer ts.Close()
+
+	// the paged searches are given the context as well
+	for _, path := range []string{"/api/flow/search", "/api/flow/search?limit=1"} {
+		for _, id := range []string{"client-id", ""} {
+			req, _ := http.NewRequest("GET", ts.URL+path, nil)
+			if id != "" {
+				req.Header.Set(shttp.RequestIDHeader, id)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			resp.Body.Close()
+
+			replied := resp.Header.Get(shttp.RequestIDHeader)
+			if replied == "" || (id != "" && replied != id) {
+				t.Errorf("%s: expected the request ID %q in the reply, got %q", path, id, replied)
+			}
+			if received := st.lastRequestID(); received != replied {
+				t.Errorf("%s: expected the storage to receive the request ID %q, got %q", path, replied, received)
+			}
+		}
+	}
+}
+
+func TestFlowApi_credentials(t *testing.T) {
+	server := shttp.NewServer("analyzer", "127.0.0.1", 0, shttp.NewNoAuthenticationBackend())
+	fa := &FlowApi{
+		FlowTable:   flow.NewTable(),
+		Storage:     &contextStorage{},
+		Credentials: &shttp.AuthenticationOpts{Token: "secret"},
+	}
+	fa.registerEndpoints(server)
+
+	ts := httptest.NewServer(server.Router)
+	defer ts.Close()
+
+	for _, path := range []string{"/api/flow/search", "/api/flow/conversation/ipv4", "/api/flow/discovery/bytes"} {
+		for authorization, expected := range map[string]int{"": http.StatusUnauthorized, "Bearer wrong": http.StatusUnauthorized, "Bearer secret": http.StatusOK} {
+			req, _ := http.NewRequest("GET", ts.URL+path, nil)
+			if authorization != "" {
+				req.Header.Set("Authorization", authorization)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != expected {
+				t.Errorf("%s with %q: expected status %d, got %d", path, authorization, expected, resp.StatusCode)
+			}
+		}
+	}
+}
+
+// TestFlowExposingApis_credentials checks that the routes giving the flows
+// outside of the flow API require its credentials too
+func TestFlowExposingApis_credentials(t *testing.T) {
+	credentials := &shttp.AuthenticationOpts{Token: "secret"}
+	server := shttp.NewServer("analyzer", "127.0.0.1", 0, shttp.NewNoAuthenticationBackend())
+
+	(&AlertContextApi{Credentials: credentials}).registerEndpoints(server)
+	(&FlowRollupApi{Rollups: NewFlowRollups(&contextStorage{}, time.Minute, 0, 10), Credentials: credentials}).registerEndpoints(server)
+	(&FlowTraceApi{FlowTable: flow.NewTable(), Credentials: credentials}).registerEndpoints(server)
+
+	support := newSupportTestApi(t)
+	support.Credentials = credentials
+	support.registerEndpoints(server)
+
+	scheduler := newReportTestScheduler(t)
+	scheduler.credentials = credentials
+	scheduler.registerEndpoints(server)
+
+	ts := httptest.NewServer(server.Router)
+	defer ts.Close()
+	admin := httptest.NewServer(server.AdminRouter)
+	defer admin.Close()
+
+	for _, route := range []struct {
+		method string
+		url    string
+	}{
+		{"GET", ts.URL + "/api/alert/alert1/fires/0/context"},
+		{"GET", ts.URL + "/api/alert/alert1/fires/0/flows"},
+		{"GET", ts.URL + "/api/flow/rollups"},
+		{"POST", ts.URL + "/api/report/unknown/run"},
+		{"POST", admin.URL + "/api/admin/flow/trace"},
+		{"GET", admin.URL + "/api/admin/support-bundle"},
+	} {
+		for _, authorization := range []string{"", "Bearer wrong", "Bearer secret"} {
+			req, _ := http.NewRequest(route.method, route.url, nil)
+			if authorization != "" {
+				req.Header.Set("Authorization", authorization)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			resp.Body.Close()
+
+			if unauthorized := resp.StatusCode == http.StatusUnauthorized; unauthorized != (authorization != "Bearer secret") {
+				t.Errorf("%s %s with %q: unexpected status %d", route.method, route.url, authorization, resp.StatusCode)
+			}
+		}
+	}
+}
+
+func TestFlowApi_compact(t *testing.T) {
+	st := &contextStorage{}
+	st.StoreFlows([]*flow.Flow{{UUID: "flow"}})
+
+	server := shttp.NewServer("analyzer", "127.0.0.1", 0, shttp.NewNoAuthenticationBackend())
+	fa := &FlowApi{Storage: st}
+	fa.registerEndpoints(server)
+
+	ts := httptest.NewServer(server.Router)
+	defer ts.Close()
+
+	for _, query := range []string{"?compact=true", "", "?compact=false"} {
+		resp, err := http.Get(ts.URL + "/api/flow/search" + query)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		var flows []*flow.Flow
+		json.NewDecoder(resp.Body).Decode(&flows)
+		resp.Body.Close()
+
+		// compact isn't a filter of the search
+		if len(flows) != 1 {
+			t.Errorf("%s: expected the flow to be returned, got %d flows", query, len(flows))
+		}
+
+		st.Lock()
+		compact := st.compact
+		st.Unlock()
+		if compact != (query == "?compact=true") {
+			t.Errorf("%s: wrong compact mode %v", query, compact)
+		}
+	}
+
+	// the storage doesn't resolve references
+	resp, err := http.Get(ts.URL + "/api/flow/attributes/ref")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected the references not to be resolved, got %d", resp.StatusCode)
+	}
 }
@@ -0,0 +1,159 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/abbot/go-http-auth"
+
+	"github.com/redhat-cip/skydive/config"
+	"github.com/redhat-cip/skydive/flow"
+	"github.com/redhat-cip/skydive/flow/mappings"
+	shttp "github.com/redhat-cip/skydive/http"
+	"github.com/redhat-cip/skydive/logging"
+)
+
+// statusTooManyRequests is the status of the rejected requests, net/http
+// only defining it from Go 1.6
+const statusTooManyRequests = 429
+
+// FlowTraceRequest is either a flow record or the UUID of a flow of the
+// flow table
+type FlowTraceRequest struct {
+	Flow *flow.Flow `json:",omitempty"`
+	UUID string     `json:",omitempty"`
+}
+
+// traceLimiter is a token bucket holding up to limit traces, refilled of
+// limit traces per minute
+type traceLimiter struct {
+	sync.Mutex
+	limit  float64
+	tokens float64
+	last   time.Time
+}
+
+func (l *traceLimiter) allow(now time.Time) bool {
+	l.Lock()
+	defer l.Unlock()
+
+	l.tokens += now.Sub(l.last).Minutes() * l.limit
+	if l.tokens > l.limit {
+		l.tokens = l.limit
+	}
+	l.last = now
+
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+
+	return true
+}
+
+func newTraceLimiter(limit int) *traceLimiter {
+	return &traceLimiter{limit: float64(limit), tokens: float64(limit), last: time.Now()}
+}
+
+type FlowTraceApi struct {
+	Pipeline  *mappings.FlowMappingPipeline
+	FlowTable *flow.Table
+	// credentials required by the trace endpoint, none when not set
+	Credentials *shttp.AuthenticationOpts
+	limiter     *traceLimiter
+}
+
+func (t *FlowTraceApi) trace(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
+	if t.limiter != nil && !t.limiter.allow(time.Now()) {
+		w.Header().Set("Retry-After", fmt.Sprintf("%d", int(60/t.limiter.limit)+1))
+		w.WriteHeader(statusTooManyRequests)
+		return
+	}
+
+	var request FlowTraceRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	f := request.Flow
+	if f == nil {
+		if request.UUID == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("A flow or a flow UUID is expected"))
+			return
+		}
+		if f = t.FlowTable.GetFlow(request.UUID); f == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+	}
+
+	report, err := t.Pipeline.TraceFlow(f)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		logging.GetLogger().Criticalf("Failed to send flow trace report: %s", err.Error())
+	}
+}
+
+func (t *FlowTraceApi) registerEndpoints(r *shttp.Server) {
+	routes := []shttp.Route{
+		{
+			"FlowTrace",
+			"POST",
+			"/api/admin/flow/trace",
+			t.trace,
+		},
+	}
+
+	r.RegisterAdminRoutes(shttp.RequireRoutesCredentials(t.Credentials, routes))
+}
+
+// RegisterFlowTraceApi registers the flow trace endpoint on the admin
+// listener, the traces being limited to rate_limit per minute
+func RegisterFlowTraceApi(p *mappings.FlowMappingPipeline, f *flow.Table, r *shttp.Server) {
+	t := &FlowTraceApi{
+		Pipeline:    p,
+		FlowTable:   f,
+		Credentials: shttp.AuthenticationOptsFromConfig("analyzer"),
+	}
+
+	if limit := config.GetConfig().GetInt("analyzer.flow_trace.rate_limit"); limit > 0 {
+		t.limiter = newTraceLimiter(limit)
+	}
+
+	t.registerEndpoints(r)
+}
@@ -0,0 +1,122 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abbot/go-http-auth"
+
+	"github.com/redhat-cip/skydive/flow"
+	"github.com/redhat-cip/skydive/flow/mappings"
+)
+
+type failingFlowEnhancer struct {
+}
+
+func (e *failingFlowEnhancer) Enhance(f *flow.Flow) {
+	panic("enhancer failure")
+}
+
+type nodeFlowEnhancer struct {
+}
+
+func (e *nodeFlowEnhancer) Enhance(f *flow.Flow) {
+	f.IfSrcNodeUUID = "node-src"
+}
+
+func traceFlow(t *testing.T, ta *FlowTraceApi, body string) *httptest.ResponseRecorder {
+	req, err := http.NewRequest("POST", "/api/admin/flow/trace", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	w := httptest.NewRecorder()
+	ta.trace(w, &auth.AuthenticatedRequest{Request: *req})
+	return w
+}
+
+func TestFlowTraceApi(t *testing.T) {
+	live := &flow.Flow{UUID: "live-uuid"}
+	ta := &FlowTraceApi{
+		Pipeline:  mappings.NewFlowMappingPipeline(&failingFlowEnhancer{}, &nodeFlowEnhancer{}),
+		FlowTable: flow.NewTableFromFlows([]*flow.Flow{live}),
+	}
+
+	for _, body := range []string{`{"UUID": "live-uuid"}`, `{"Flow": {"UUID": "record-uuid"}}`} {
+		w := traceFlow(t, ta, body)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+
+		var report mappings.FlowTraceReport
+		if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+			t.Fatal(err.Error())
+		}
+
+		if len(report.Stages) != 2 || report.Stages[0].Error != "enhancer failure" || report.Flow.IfSrcNodeUUID != "node-src" {
+			t.Errorf("Wrong trace report: %s", w.Body.String())
+		}
+	}
+
+	// the live flow isn't enhanced by the trace
+	if live.IfSrcNodeUUID != "" {
+		t.Errorf("The live flow shouldn't have been modified: %+v", live)
+	}
+
+	if w := traceFlow(t, ta, `{"UUID": "unknown"}`); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+	if w := traceFlow(t, ta, `{}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+}
+
+func TestFlowTraceApi_rateLimit(t *testing.T) {
+	ta := &FlowTraceApi{
+		Pipeline:  mappings.NewFlowMappingPipeline(),
+		FlowTable: flow.NewTable(),
+		limiter:   newTraceLimiter(2),
+	}
+
+	for i := 0; i < 2; i++ {
+		if w := traceFlow(t, ta, `{"Flow": {"UUID": "flow-uuid"}}`); w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", w.Code)
+		}
+	}
+
+	w := traceFlow(t, ta, `{"Flow": {"UUID": "flow-uuid"}}`)
+	if w.Code != statusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("Expected status 429 with a Retry-After, got %d", w.Code)
+	}
+
+	// a trace every 30 seconds
+	if !ta.limiter.allow(time.Now().Add(30 * time.Second)) {
+		t.Error("The limiter should have been refilled")
+	}
+}
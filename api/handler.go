@@ -50,6 +50,19 @@ type ApiHandler interface {
 	AsyncWatch(f ApiWatcherCallback) StoppableWatcher
 }
 
+// ApiResourceValidator is implemented by the resources checking their
+// fields before being created
+type ApiResourceValidator interface {
+	Validate() error
+}
+
+// ApiResourceBundled is implemented by the resources which can be declared
+// in a bundle, the ones created by a bundle being labeled with its ID
+type ApiResourceBundled interface {
+	BundleID() string
+	SetBundleID(id string)
+}
+
 type ResourceHandler interface {
 	Name() string
 	New() ApiResource
@@ -0,0 +1,170 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"time"
+
+	"github.com/nu7hatch/gouuid"
+
+	"github.com/redhat-cip/skydive/config"
+)
+
+// types of the probe jobs
+const (
+	ProbeJobPing       = "ping"
+	ProbeJobTraceroute = "traceroute"
+)
+
+const (
+	defaultProbeCount   = 3
+	defaultProbeMaxHops = 30
+	defaultProbeTimeout = 2
+)
+
+// ProbeJob is an active probe run every Interval seconds from the agents of
+// the nodes selected by the Gremlin query Source, or from the agent Agent,
+// toward the address Target or the first IPv4 address of the nodes selected
+// by TargetNode. A probe losing more than MaxLoss of its packets, a ratio,
+// or with an average round trip time above MaxRTT milliseconds fires an
+// alert, 0 disabling the check. With DiscoverHops, the hops found by a
+// traceroute are added to the graph.
+type ProbeJob struct {
+	UUID         string
+	Name         string `valid:"nonzero"`
+	Description  string `json:",omitempty"`
+	Type         string
+	Source       string `json:",omitempty"`
+	Agent        string `json:",omitempty"`
+	Target       string `json:",omitempty"`
+	TargetNode   string `json:",omitempty"`
+	Interval     int
+	Count        int     `json:",omitempty"`
+	MaxHops      int     `json:",omitempty"`
+	Timeout      int     `json:",omitempty"`
+	MaxLoss      float64 `json:",omitempty"`
+	MaxRTT       float64 `json:",omitempty"`
+	DiscoverHops bool    `json:",omitempty"`
+	CreateTime   time.Time
+}
+
+type ProbeJobHandler struct {
+}
+
+var probeHostnameRegexp = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9.-]*[a-zA-Z0-9])?$`)
+
+// ValidProbeTarget tells whether target is an IP address or a host name,
+// the targets being given as is to the probe commands of the agents
+func ValidProbeTarget(target string) bool {
+	return net.ParseIP(target) != nil || len(target) <= 253 && probeHostnameRegexp.MatchString(target)
+}
+
+func NewProbeJob() *ProbeJob {
+	id, _ := uuid.NewV4()
+
+	return &ProbeJob{
+		UUID:       id.String(),
+		Type:       ProbeJobPing,
+		Interval:   60,
+		CreateTime: time.Now(),
+	}
+}
+
+// Validate checks the source, the target and the schedule of the job
+func (p *ProbeJob) Validate() error {
+	switch p.Type {
+	case ProbeJobPing, ProbeJobTraceroute:
+	default:
+		return fmt.Errorf("Unknown type %s, expected ping or traceroute", p.Type)
+	}
+
+	if (p.Source == "") == (p.Agent == "") {
+		return errors.New("Either Source or Agent is required")
+	}
+	if (p.Target == "") == (p.TargetNode == "") {
+		return errors.New("Either Target or TargetNode is required")
+	}
+	if p.Target != "" && !ValidProbeTarget(p.Target) {
+		return fmt.Errorf("Invalid target %s, expected an IP address or a host name", p.Target)
+	}
+
+	if interval := config.GetConfig().GetInt("analyzer.synthetic_probes.min_interval"); p.Interval < interval || p.Interval <= 0 {
+		return fmt.Errorf("Interval of %ds shorter than the minimum of %ds", p.Interval, interval)
+	}
+	if p.Count < 0 || p.Count > 100 {
+		return errors.New("Count must be between 0 and 100")
+	}
+	if p.MaxHops < 0 || p.MaxHops > 64 {
+		return errors.New("MaxHops must be between 0 and 64")
+	}
+	if p.Timeout < 0 || p.Timeout > 60 {
+		return errors.New("Timeout must be between 0 and 60 seconds")
+	}
+	if p.MaxLoss < 0 || p.MaxLoss > 1 || p.MaxRTT < 0 {
+		return errors.New("MaxLoss must be between 0 and 1 and MaxRTT can't be negative")
+	}
+	if p.DiscoverHops && p.Type != ProbeJobTraceroute {
+		return errors.New("DiscoverHops requires a traceroute")
+	}
+
+	return nil
+}
+
+// ProbeCount returns the number of pings of a run
+func (p *ProbeJob) ProbeCount() int {
+	if p.Count > 0 {
+		return p.Count
+	}
+	return defaultProbeCount
+}
+
+func (p *ProbeJob) ProbeMaxHops() int {
+	if p.MaxHops > 0 {
+		return p.MaxHops
+	}
+	return defaultProbeMaxHops
+}
+
+// ProbeTimeout returns the number of seconds a reply is waited for
+func (p *ProbeJob) ProbeTimeout() int {
+	if p.Timeout > 0 {
+		return p.Timeout
+	}
+	return defaultProbeTimeout
+}
+
+func (p *ProbeJobHandler) New() ApiResource {
+	return &ProbeJob{}
+}
+
+func (p *ProbeJobHandler) Name() string {
+	return "probejob"
+}
+
+func (p *ProbeJob) ID() string {
+	return p.UUID
+}
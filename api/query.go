@@ -0,0 +1,241 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/abbot/go-http-auth"
+
+	"github.com/redhat-cip/skydive/config"
+	"github.com/redhat-cip/skydive/flow"
+	shttp "github.com/redhat-cip/skydive/http"
+	"github.com/redhat-cip/skydive/logging"
+	"github.com/redhat-cip/skydive/storage"
+	"github.com/redhat-cip/skydive/topology"
+	"github.com/redhat-cip/skydive/topology/graph"
+)
+
+type QueryApi struct {
+	Service   string
+	Graph     *graph.Graph
+	FlowTable *flow.Table
+	Storage   storage.Storage
+}
+
+// QueryEstimateRequest holds either a Gremlin query or a flow filter with an
+// optional time range, the bounds being given in seconds since epoch.
+type QueryEstimateRequest struct {
+	GremlinQuery string `json:",omitempty"`
+	FlowFilter   string `json:",omitempty"`
+	From         int64  `json:",omitempty"`
+	To           int64  `json:",omitempty"`
+}
+
+// QueryEstimate gives the approximate size of the result of a query, computed
+// without executing it.
+type QueryEstimate struct {
+	Approximate bool
+	Count       int
+	// some terms of the filter were ignored, the count is an upper bound
+	UpperBound bool
+	Indexes    []string
+	Scan       bool
+	// false when the time budget was exhausted
+	Complete     bool
+	Limit        int
+	ExceedsLimit bool
+}
+
+func (r *QueryEstimateRequest) isFlowQuery() bool {
+	return r.FlowFilter != "" || r.From != 0 || r.To != 0
+}
+
+func matchFlowRange(f *flow.Flow, from int64, to int64) bool {
+	fs := f.GetStatistics()
+	if fs == nil {
+		return from == 0 && to == 0
+	}
+	return (from == 0 || fs.Last >= from) && (to == 0 || fs.Start <= to)
+}
+
+func (q *QueryApi) estimateGremlin(query string, deadline time.Time) (*QueryEstimate, error) {
+	tr := graph.NewGremlinTraversalParser(strings.NewReader(query), q.Graph)
+	tr.AddTraversalExtension(topology.NewTopologyTraversalExtension())
+
+	ts, err := tr.Parse()
+	if err != nil {
+		return nil, err
+	}
+
+	te, err := ts.Estimate(config.GetConfig().GetInt("analyzer.query_estimate.sample_size"), deadline)
+	if err != nil {
+		return nil, err
+	}
+
+	return &QueryEstimate{
+		Count:    te.Count,
+		Indexes:  te.Indexes,
+		Scan:     te.Scan,
+		Complete: te.Complete,
+	}, nil
+}
+
+// estimateFlows counts the flows using the storage count API, only the terms
+// of the filter known by the storage being used. Without storage the live
+// flows of the table are counted.
+func (q *QueryApi) estimateFlows(filter flow.Filter, from int64, to int64, deadline time.Time) (*QueryEstimate, error) {
+	estimate := &QueryEstimate{Indexes: []string{}, Complete: true}
+
+	if q.Storage == nil {
+		estimate.Scan = true
+		for _, f := range q.FlowTable.GetFlows() {
+			if matchFlowRange(f, from, to) && filter.Match(f) {
+				estimate.Count++
+			}
+		}
+		return estimate, nil
+	}
+
+	filters := storage.Filters(filter.FieldsOnly())
+	estimate.UpperBound = len(filters) != len(filter)
+	if from != 0 {
+		filters["Statistics.Last"] = storage.Range{Gte: from}
+	}
+	if to != 0 {
+		filters["Statistics.Start"] = storage.Range{Lte: to}
+	}
+
+	for k := range filters {
+		estimate.Indexes = append(estimate.Indexes, k)
+	}
+	sort.Strings(estimate.Indexes)
+
+	type countResult struct {
+		count int
+		err   error
+	}
+
+	result := make(chan countResult, 1)
+	go func() {
+		count, err := q.Storage.CountFlows(filters)
+		result <- countResult{count: count, err: err}
+	}()
+
+	select {
+	case r := <-result:
+		if r.err != nil {
+			return nil, r.err
+		}
+		estimate.Count = r.count
+	case <-time.After(deadline.Sub(time.Now())):
+		estimate.Complete = false
+	}
+
+	return estimate, nil
+}
+
+func (q *QueryApi) Estimate(request *QueryEstimateRequest) (*QueryEstimate, error) {
+	timeout := time.Duration(config.GetConfig().GetInt("analyzer.query_estimate.timeout")) * time.Millisecond
+	deadline := time.Now().Add(timeout)
+
+	var estimate *QueryEstimate
+	var err error
+
+	switch {
+	case request.GremlinQuery != "" && request.isFlowQuery():
+		return nil, errors.New("Either a Gremlin query or a flow filter expected")
+	case request.GremlinQuery != "":
+		estimate, err = q.estimateGremlin(request.GremlinQuery, deadline)
+	case request.isFlowQuery():
+		var filter flow.Filter
+		if request.FlowFilter != "" {
+			if filter, err = flow.ParseFilter(request.FlowFilter); err != nil {
+				return nil, err
+			}
+		}
+		estimate, err = q.estimateFlows(filter, request.From, request.To, deadline)
+	default:
+		return nil, errors.New("Empty query")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	estimate.Approximate = true
+	estimate.Limit = config.GetConfig().GetInt("analyzer.query_max_results")
+	estimate.ExceedsLimit = estimate.Limit > 0 && estimate.Count > estimate.Limit
+
+	return estimate, nil
+}
+
+func (q *QueryApi) queryEstimate(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
+	var request QueryEstimateRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	estimate, err := q.Estimate(&request)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(estimate); err != nil {
+		logging.GetLogger().Criticalf("Failed to send query estimate: %s", err.Error())
+	}
+}
+
+func (q *QueryApi) registerEndpoints(r *shttp.Server) {
+	routes := []shttp.Route{
+		{
+			"QueryEstimate",
+			"POST",
+			"/api/query/estimate",
+			q.queryEstimate,
+		},
+	}
+
+	r.RegisterRoutes(routes)
+}
+
+func RegisterQueryApi(s string, g *graph.Graph, f *flow.Table, st storage.Storage, r *shttp.Server) {
+	q := &QueryApi{
+		Service:   s,
+		Graph:     g,
+		FlowTable: f,
+		Storage:   st,
+	}
+
+	q.registerEndpoints(r)
+}
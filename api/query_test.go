@@ -0,0 +1,232 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/abbot/go-http-auth"
+
+	"github.com/redhat-cip/skydive/config"
+	"github.com/redhat-cip/skydive/flow"
+	"github.com/redhat-cip/skydive/topology/graph"
+)
+
+func newEstimateTestFlows() []*flow.Flow {
+	var flows []*flow.Flow
+	for i := 0; i != 3000; i++ {
+		flows = append(flows, &flow.Flow{
+			UUID:          fmt.Sprintf("flow-%d", i),
+			LayersPath:    "Ethernet/IPv4/TCP",
+			ProbeNodeUUID: fmt.Sprintf("probe-%d", i%3),
+			Statistics: &flow.FlowStatistics{
+				Start: int64(1000 + i),
+				Last:  int64(1010 + i),
+				Endpoints: []*flow.FlowEndpointsStatistics{
+					{
+						Type: flow.FlowEndpointType_IPV4,
+						AB:   &flow.FlowEndpointStatistics{Value: fmt.Sprintf("10.0.0.%d", i%5)},
+						BA:   &flow.FlowEndpointStatistics{Value: "10.0.1.1"},
+					},
+				},
+			},
+		})
+	}
+	return flows
+}
+
+func newEstimateTestGraph(t *testing.T) *graph.Graph {
+	b, err := graph.NewMemoryBackend()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	g, err := graph.NewGraph(b)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for i := 0; i != 200; i++ {
+		host := g.NewNode(graph.GenID(), graph.Metadata{"Type": "host"})
+		for j := 0; j != 10; j++ {
+			g.Link(host, g.NewNode(graph.GenID(), graph.Metadata{"Type": "veth", "MTU": 1500 + j%2}))
+		}
+	}
+
+	return g
+}
+
+func postEstimate(t *testing.T, q *QueryApi, request *QueryEstimateRequest) (*QueryEstimate, int) {
+	data, err := json.Marshal(request)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	req, err := http.NewRequest("POST", "/api/query/estimate", strings.NewReader(string(data)))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	w := httptest.NewRecorder()
+	q.queryEstimate(w, &auth.AuthenticatedRequest{Request: *req})
+	if w.Code != http.StatusOK {
+		return nil, w.Code
+	}
+
+	var estimate QueryEstimate
+	if err := json.NewDecoder(w.Body).Decode(&estimate); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if !estimate.Approximate {
+		t.Error("Estimates should be labeled as approximate")
+	}
+
+	return &estimate, w.Code
+}
+
+func TestQueryEstimateGremlin(t *testing.T) {
+	g := newEstimateTestGraph(t)
+	q := &QueryApi{Graph: g}
+
+	for _, query := range []string{
+		"G.V()",
+		"G.V().Has('Type', 'veth', 'MTU', 1501)",
+		"G.V().Has('Type', 'host').Out().Has('MTU', 1500)",
+		"G.V().Has('Type', 'veth').In()",
+	} {
+		ts, err := graph.NewGremlinTraversalParser(strings.NewReader(query), g).Parse()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		res, err := ts.Exec()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		actual := len(res.Values())
+
+		estimate, code := postEstimate(t, q, &QueryEstimateRequest{GremlinQuery: query})
+		if code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d", query, code)
+		}
+
+		if math.Abs(float64(estimate.Count-actual)) > 0.2*float64(actual) {
+			t.Errorf("%s: estimate %d too far from the actual count %d", query, estimate.Count, actual)
+		}
+	}
+}
+
+func TestQueryEstimateFlows(t *testing.T) {
+	flows := newEstimateTestFlows()
+	table := flow.NewTableFromFlows(flows)
+	filter, _ := flow.ParseFilter("ProbeNodeUUID=probe-1,IPV4.A=10.0.0.2")
+
+	// both from the storage count API and from the table
+	for _, st := range []*fakeStorage{{flows: flows}, nil} {
+		q := &QueryApi{FlowTable: table}
+		if st != nil {
+			q.Storage = st
+		}
+
+		for _, request := range []*QueryEstimateRequest{
+			{FlowFilter: "ProbeNodeUUID=probe-1"},
+			{FlowFilter: "ProbeNodeUUID=probe-2", From: 2000, To: 2500},
+			{From: 3500},
+		} {
+			var filter flow.Filter
+			if request.FlowFilter != "" {
+				filter, _ = flow.ParseFilter(request.FlowFilter)
+			}
+
+			actual := 0
+			for _, f := range flows {
+				if filter.Match(f) && matchFlowRange(f, request.From, request.To) {
+					actual++
+				}
+			}
+
+			estimate, code := postEstimate(t, q, request)
+			if code != http.StatusOK {
+				t.Fatalf("%+v: expected status 200, got %d", request, code)
+			}
+
+			if estimate.Count != actual || estimate.UpperBound || !estimate.Complete {
+				t.Errorf("%+v: wrong estimate %+v, expected count %d", request, estimate, actual)
+			}
+		}
+
+		actual := 0
+		for _, f := range flows {
+			if filter.Match(f) {
+				actual++
+			}
+		}
+
+		// endpoints aren't known by the storage
+		estimate, _ := postEstimate(t, q, &QueryEstimateRequest{FlowFilter: "ProbeNodeUUID=probe-1,IPV4.A=10.0.0.2"})
+		if st != nil && (!estimate.UpperBound || estimate.Count < actual || len(estimate.Indexes) != 1) {
+			t.Errorf("Estimate should be an upper bound using the ProbeNodeUUID index: %+v", estimate)
+		}
+		if st == nil && (estimate.Count != actual || !estimate.Scan) {
+			t.Errorf("Flows of the table should be scanned: %+v, expected count %d", estimate, actual)
+		}
+	}
+}
+
+func TestQueryEstimateLimit(t *testing.T) {
+	q := &QueryApi{FlowTable: flow.NewTableFromFlows(newEstimateTestFlows())}
+
+	config.GetConfig().Set("analyzer.query_max_results", 1000)
+	defer config.GetConfig().Set("analyzer.query_max_results", 10000)
+
+	estimate, _ := postEstimate(t, q, &QueryEstimateRequest{FlowFilter: "LayersPath=Ethernet/IPv4/TCP"})
+	if estimate.Limit != 1000 || !estimate.ExceedsLimit {
+		t.Errorf("Estimate should exceed the limit: %+v", estimate)
+	}
+
+	estimate, _ = postEstimate(t, q, &QueryEstimateRequest{FlowFilter: "ProbeNodeUUID=probe-0"})
+	if estimate.ExceedsLimit {
+		t.Errorf("Estimate shouldn't exceed the limit: %+v", estimate)
+	}
+}
+
+func TestQueryEstimateBadRequest(t *testing.T) {
+	q := &QueryApi{Graph: newEstimateTestGraph(t), FlowTable: flow.NewTable()}
+
+	for _, request := range []*QueryEstimateRequest{
+		{},
+		{GremlinQuery: "G.V()", FlowFilter: "ProbeNodeUUID=probe-1"},
+		{GremlinQuery: "G.V().Unknown()"},
+		{FlowFilter: "Unknown=1"},
+	} {
+		if _, code := postEstimate(t, q, request); code != http.StatusBadRequest {
+			t.Errorf("%+v: expected status 400, got %d", request, code)
+		}
+	}
+}
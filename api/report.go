@@ -0,0 +1,509 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package api
+
+import (
+	// aliased, bytes being the discovery of the flow bytes
+	stdbytes "bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nu7hatch/gouuid"
+
+	"github.com/redhat-cip/skydive/flow"
+)
+
+// canned sections of the reports
+const (
+	ReportSectionSummary      = "summary"
+	ReportSectionTopTalkers   = "top_talkers"
+	ReportSectionNewHosts     = "new_hosts"
+	ReportSectionAlerts       = "alerts"
+	ReportSectionApplications = "applications"
+)
+
+var reportSections = []string{
+	ReportSectionSummary,
+	ReportSectionTopTalkers,
+	ReportSectionNewHosts,
+	ReportSectionAlerts,
+	ReportSectionApplications,
+}
+
+const defaultReportTop = 10
+
+// Report is a periodic report, due every Period seconds shifted by Offset
+// seconds from the epoch, 86400 and 3600 for a daily report at 1 AM UTC.
+// Range is the number of seconds covered by the report, the period by
+// default.
+type Report struct {
+	UUID        string
+	Name        string `valid:"nonzero"`
+	Description string `json:",omitempty"`
+	Period      int    `valid:"nonzero"`
+	Offset      int    `json:",omitempty"`
+	Range       int    `json:",omitempty"`
+	Sections    []string
+	Top         int `json:",omitempty"`
+	// json or html
+	Format string
+	// webhook, the report being posted to URL, or spool
+	Delivery   string
+	URL        string `json:",omitempty"`
+	Bundle     string `json:",omitempty"`
+	CreateTime time.Time
+}
+
+type ReportHandler struct {
+}
+
+func NewReport() *Report {
+	id, _ := uuid.NewV4()
+
+	return &Report{
+		UUID:       id.String(),
+		Sections:   []string{ReportSectionSummary, ReportSectionTopTalkers},
+		Format:     "json",
+		Delivery:   "spool",
+		CreateTime: time.Now(),
+	}
+}
+
+// Validate checks the schedule, the sections and the delivery of the report
+func (r *Report) Validate() error {
+	if r.Period <= 0 {
+		return errors.New("Period must be a positive number of seconds")
+	}
+	if r.Offset < 0 || r.Offset >= r.Period {
+		return fmt.Errorf("Offset must be between 0 and the period of %ds", r.Period)
+	}
+	if r.Range < 0 || r.Top < 0 {
+		return errors.New("Range and Top can't be negative")
+	}
+
+	if len(r.Sections) == 0 {
+		return errors.New("At least one section is required")
+	}
+	for _, section := range r.Sections {
+		if !isReportSection(section) {
+			return fmt.Errorf("Unknown section %s, expected one of %s", section, strings.Join(reportSections, ", "))
+		}
+	}
+
+	switch r.Format {
+	case "json", "html":
+	default:
+		return fmt.Errorf("Unknown format %s, expected json or html", r.Format)
+	}
+
+	switch r.Delivery {
+	case "webhook":
+		u, err := url.Parse(r.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("Invalid webhook URL: %s", r.URL)
+		}
+	case "spool":
+	default:
+		return fmt.Errorf("Unknown delivery %s, expected webhook or spool", r.Delivery)
+	}
+
+	return nil
+}
+
+func isReportSection(name string) bool {
+	for _, section := range reportSections {
+		if section == name {
+			return true
+		}
+	}
+	return false
+}
+
+// LastDue returns the last time the report was due, now included
+func (r *Report) LastDue(now time.Time) time.Time {
+	period, offset := int64(r.Period), int64(r.Offset)
+
+	due := now.Unix() - offset
+	due -= due % period
+	if due < 0 {
+		due -= period
+	}
+	return time.Unix(due+offset, 0)
+}
+
+// timeRange returns the number of seconds covered by the report
+func (r *Report) timeRange() time.Duration {
+	if r.Range > 0 {
+		return time.Duration(r.Range) * time.Second
+	}
+	return time.Duration(r.Period) * time.Second
+}
+
+func (r *Report) top() int {
+	if r.Top > 0 {
+		return r.Top
+	}
+	return defaultReportTop
+}
+
+func (r *ReportHandler) New() ApiResource {
+	return &Report{}
+}
+
+func (r *ReportHandler) Name() string {
+	return "report"
+}
+
+func (r *Report) ID() string {
+	return r.UUID
+}
+
+func (r *Report) BundleID() string {
+	return r.Bundle
+}
+
+func (r *Report) SetBundleID(id string) {
+	r.Bundle = id
+}
+
+type ReportSummary struct {
+	Flows   int
+	Bytes   uint64
+	Packets uint64
+}
+
+type ReportTalker struct {
+	Host  string
+	Bytes uint64
+}
+
+type ReportAlert struct {
+	UUID  string
+	Name  string `json:",omitempty"`
+	Count int
+	Last  time.Time
+}
+
+type ReportApplication struct {
+	Application string
+	Bytes       uint64
+}
+
+// ReportSection holds the result of a section, in the field matching its
+// name
+type ReportSection struct {
+	Name         string
+	Summary      *ReportSummary      `json:",omitempty"`
+	TopTalkers   []ReportTalker      `json:",omitempty"`
+	NewHosts     []string            `json:",omitempty"`
+	Alerts       []ReportAlert       `json:",omitempty"`
+	Applications []ReportApplication `json:",omitempty"`
+}
+
+// ReportOutput is a rendered report covering the flows active between From
+// and To
+type ReportOutput struct {
+	UUID     string
+	Name     string
+	From     time.Time
+	To       time.Time
+	Sections []ReportSection
+}
+
+type sortTalkersByBytes []ReportTalker
+
+func (s sortTalkersByBytes) Len() int {
+	return len(s)
+}
+
+func (s sortTalkersByBytes) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s sortTalkersByBytes) Less(i, j int) bool {
+	if s[i].Bytes == s[j].Bytes {
+		return s[i].Host < s[j].Host
+	}
+	return s[i].Bytes > s[j].Bytes
+}
+
+type sortApplicationsByBytes []ReportApplication
+
+func (s sortApplicationsByBytes) Len() int {
+	return len(s)
+}
+
+func (s sortApplicationsByBytes) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s sortApplicationsByBytes) Less(i, j int) bool {
+	if s[i].Bytes == s[j].Bytes {
+		return s[i].Application < s[j].Application
+	}
+	return s[i].Bytes > s[j].Bytes
+}
+
+type sortAlertsByCount []ReportAlert
+
+func (s sortAlertsByCount) Len() int {
+	return len(s)
+}
+
+func (s sortAlertsByCount) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s sortAlertsByCount) Less(i, j int) bool {
+	if s[i].Count == s[j].Count {
+		return s[i].UUID < s[j].UUID
+	}
+	return s[i].Count > s[j].Count
+}
+
+type firedAlert struct {
+	UUID      string
+	Timestamp time.Time
+}
+
+// reportInput is what the sections are computed from: the flows active
+// during the report range, the host nodes of the graph along with the ones
+// known by the previous run and the alerts fired during the range
+type reportInput struct {
+	Flows      []*flow.Flow
+	Hosts      []string
+	KnownHosts map[string]bool
+	Alerts     []firedAlert
+	AlertNames map[string]string
+}
+
+// total returns the size of the node and of all its children
+func (d *discoNode) total() uint64 {
+	size := d.size
+	for _, child := range d.children {
+		size += child.total()
+	}
+	return size
+}
+
+// leaves returns the size of the nodes having one, by path
+func (d *discoNode) leaves(prefix string, leaves map[string]uint64) {
+	for name, child := range d.children {
+		path := name
+		if prefix != "" {
+			path = prefix + "/" + name
+		}
+		if child.size > 0 {
+			leaves[path] += child.size
+		}
+		child.leaves(path, leaves)
+	}
+}
+
+func reportSummary(fa *FlowApi, flows int) *ReportSummary {
+	return &ReportSummary{
+		Flows:   flows,
+		Bytes:   fa.flowDiscovery(bytes, nil).total(),
+		Packets: fa.flowDiscovery(packets, nil).total(),
+	}
+}
+
+// reportTopTalkers returns the IPv4 hosts having exchanged the most bytes,
+// the bytes of a flow accounting for both of its endpoints
+func reportTopTalkers(fa *FlowApi, top int) []ReportTalker {
+	hosts := make(map[string]uint64)
+	for a, node := range fa.flowDiscovery(bytes, []string{"IPV4.A", "IPV4.B"}).children {
+		for b, peer := range node.children {
+			if a != "unknown" {
+				hosts[a] += peer.size
+			}
+			if b != "unknown" {
+				hosts[b] += peer.size
+			}
+		}
+	}
+
+	talkers := make([]ReportTalker, 0, len(hosts))
+	for host, size := range hosts {
+		talkers = append(talkers, ReportTalker{Host: host, Bytes: size})
+	}
+	sort.Sort(sortTalkersByBytes(talkers))
+
+	if len(talkers) > top {
+		talkers = talkers[:top]
+	}
+	return talkers
+}
+
+// reportApplications returns the layers paths having carried the most bytes
+func reportApplications(fa *FlowApi, top int) []ReportApplication {
+	leaves := make(map[string]uint64)
+	fa.flowDiscovery(bytes, nil).leaves("", leaves)
+
+	applications := make([]ReportApplication, 0, len(leaves))
+	for path, size := range leaves {
+		applications = append(applications, ReportApplication{Application: path, Bytes: size})
+	}
+	sort.Sort(sortApplicationsByBytes(applications))
+
+	if len(applications) > top {
+		applications = applications[:top]
+	}
+	return applications
+}
+
+func reportNewHosts(in *reportInput) []string {
+	var hosts []string
+	for _, host := range in.Hosts {
+		if !in.KnownHosts[host] {
+			hosts = append(hosts, host)
+		}
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+// reportAlerts counts the alerts fired between from and to, the most fired
+// first
+func reportAlerts(in *reportInput, from time.Time, to time.Time) []ReportAlert {
+	byUUID := make(map[string]*ReportAlert)
+	for _, fired := range in.Alerts {
+		if fired.Timestamp.Before(from) || fired.Timestamp.After(to) {
+			continue
+		}
+
+		alert, ok := byUUID[fired.UUID]
+		if !ok {
+			alert = &ReportAlert{UUID: fired.UUID, Name: in.AlertNames[fired.UUID]}
+			byUUID[fired.UUID] = alert
+		}
+		alert.Count++
+		if fired.Timestamp.After(alert.Last) {
+			alert.Last = fired.Timestamp
+		}
+	}
+
+	alerts := make([]ReportAlert, 0, len(byUUID))
+	for _, alert := range byUUID {
+		alerts = append(alerts, *alert)
+	}
+	sort.Sort(sortAlertsByCount(alerts))
+	return alerts
+}
+
+// renderReport computes the sections of the report with the aggregations
+// of the flow API, on a flow table holding only the flows of the range
+func renderReport(r *Report, from time.Time, to time.Time, in *reportInput) *ReportOutput {
+	fa := &FlowApi{FlowTable: flow.NewTableFromFlows(in.Flows)}
+
+	output := &ReportOutput{
+		UUID:     r.UUID,
+		Name:     r.Name,
+		From:     from.UTC(),
+		To:       to.UTC(),
+		Sections: make([]ReportSection, 0, len(r.Sections)),
+	}
+
+	for _, name := range r.Sections {
+		section := ReportSection{Name: name}
+		switch name {
+		case ReportSectionSummary:
+			section.Summary = reportSummary(fa, len(in.Flows))
+		case ReportSectionTopTalkers:
+			section.TopTalkers = reportTopTalkers(fa, r.top())
+		case ReportSectionNewHosts:
+			section.NewHosts = reportNewHosts(in)
+		case ReportSectionAlerts:
+			section.Alerts = reportAlerts(in, from, to)
+		case ReportSectionApplications:
+			section.Applications = reportApplications(fa, r.top())
+		}
+		output.Sections = append(output.Sections, section)
+	}
+
+	return output
+}
+
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Name}}</title>
+</head>
+<body>
+<h1>{{.Name}}</h1>
+<p>From {{.From.Format "2006-01-02 15:04:05 MST"}} to {{.To.Format "2006-01-02 15:04:05 MST"}}</p>
+{{range .Sections}}{{if eq .Name "summary"}}<h2>Summary</h2>
+<table>
+<tr><th>Flows</th><td>{{.Summary.Flows}}</td></tr>
+<tr><th>Bytes</th><td>{{.Summary.Bytes}}</td></tr>
+<tr><th>Packets</th><td>{{.Summary.Packets}}</td></tr>
+</table>
+{{else if eq .Name "top_talkers"}}<h2>Top talkers</h2>
+<table>
+<tr><th>Host</th><th>Bytes</th></tr>
+{{range .TopTalkers}}<tr><td>{{.Host}}</td><td>{{.Bytes}}</td></tr>
+{{end}}</table>
+{{else if eq .Name "new_hosts"}}<h2>New hosts</h2>
+<ul>
+{{range .NewHosts}}<li>{{.}}</li>
+{{else}}<li>None</li>
+{{end}}</ul>
+{{else if eq .Name "alerts"}}<h2>Alerts</h2>
+<table>
+<tr><th>Alert</th><th>Count</th><th>Last</th></tr>
+{{range .Alerts}}<tr><td>{{if .Name}}{{.Name}}{{else}}{{.UUID}}{{end}}</td><td>{{.Count}}</td><td>{{.Last.UTC.Format "2006-01-02 15:04:05 MST"}}</td></tr>
+{{end}}</table>
+{{else if eq .Name "applications"}}<h2>Applications</h2>
+<table>
+<tr><th>Application</th><th>Bytes</th></tr>
+{{range .Applications}}<tr><td>{{.Application}}</td><td>{{.Bytes}}</td></tr>
+{{end}}</table>
+{{end}}{{end}}</body>
+</html>
+`))
+
+// Marshal returns the report in the given format along with its content
+// type
+func (o *ReportOutput) Marshal(format string) ([]byte, string, error) {
+	switch format {
+	case "html":
+		var buf stdbytes.Buffer
+		if err := reportTemplate.Execute(&buf, o); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), "text/html; charset=UTF-8", nil
+	default:
+		data, err := json.MarshalIndent(o, "", "  ")
+		if err != nil {
+			return nil, "", err
+		}
+		return append(data, '\n'), "application/json; charset=UTF-8", nil
+	}
+}
@@ -0,0 +1,487 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/abbot/go-http-auth"
+	etcd "github.com/coreos/etcd/client"
+	"golang.org/x/net/context"
+
+	"github.com/redhat-cip/skydive/config"
+	"github.com/redhat-cip/skydive/flow"
+	shttp "github.com/redhat-cip/skydive/http"
+	"github.com/redhat-cip/skydive/logging"
+	"github.com/redhat-cip/skydive/storage"
+	"github.com/redhat-cip/skydive/topology/graph"
+)
+
+// ReportState is what the scheduler keeps of the last run of a report
+type ReportState struct {
+	LastRun    time.Time
+	KnownHosts []string `json:",omitempty"`
+}
+
+// ReportStateStore persists the report states so that the missed runs are
+// detected after a restart
+type ReportStateStore interface {
+	GetReportState(id string) (*ReportState, error)
+	SetReportState(id string, state *ReportState) error
+	DelReportState(id string) error
+}
+
+type etcdReportStateStore struct {
+	kapi etcd.KeysAPI
+}
+
+// GetReportState returns the state of the report, nil if it never ran
+func (s *etcdReportStateStore) GetReportState(id string) (*ReportState, error) {
+	resp, err := s.kapi.Get(context.Background(), "/reportstate/"+id, nil)
+	if err != nil {
+		if etcd.IsKeyNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var state ReportState
+	if err := json.Unmarshal([]byte(resp.Node.Value), &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+func (s *etcdReportStateStore) SetReportState(id string, state *ReportState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.kapi.Set(context.Background(), "/reportstate/"+id, string(data), nil)
+	return err
+}
+
+func (s *etcdReportStateStore) DelReportState(id string) error {
+	if _, err := s.kapi.Delete(context.Background(), "/reportstate/"+id, nil); err != nil && !etcd.IsKeyNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// ReportScheduler runs the reports when they are due and delivers them. A
+// report missed while the analyzer was down runs once at startup if it was
+// due less than the grace period ago.
+type ReportScheduler struct {
+	sync.RWMutex
+	handler   ApiHandler
+	alerts    ApiHandler
+	graph     *graph.Graph
+	flowTable *flow.Table
+	storage   storage.Storage
+	states    ReportStateStore
+	client    *http.Client
+	spoolDir  string
+	grace     time.Duration
+	interval  time.Duration
+	fired     []firedAlert
+	watcher   StoppableWatcher
+	quit      chan bool
+	wg        sync.WaitGroup
+
+	// credentials required by the routes of the reports, none if nil
+	credentials *shttp.AuthenticationOpts
+}
+
+// RecordAlert keeps a fired alert for the alert sections
+func (s *ReportScheduler) RecordAlert(id string, timestamp time.Time) {
+	s.Lock()
+	s.fired = append(s.fired, firedAlert{UUID: id, Timestamp: timestamp})
+	s.Unlock()
+}
+
+// pruneAlerts forgets the alerts older than any report range
+func (s *ReportScheduler) pruneAlerts(now time.Time, reports []*Report) {
+	var retention time.Duration
+	for _, report := range reports {
+		if r := report.timeRange() + s.grace; r > retention {
+			retention = r
+		}
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	fired := s.fired[:0]
+	for _, alert := range s.fired {
+		if now.Sub(alert.Timestamp) <= retention {
+			fired = append(fired, alert)
+		}
+	}
+	s.fired = fired
+}
+
+// flows returns the flows active between from and to, the ones of the flow
+// table taking precedence over the stored ones
+func (s *ReportScheduler) flows(from time.Time, to time.Time) []*flow.Flow {
+	byUUID := make(map[string]*flow.Flow)
+
+	if s.storage != nil {
+		stored, err := s.storage.SearchFlows(storage.Filters{"Statistics.Last": storage.Range{Gte: from.Unix()}})
+		if err != nil {
+			logging.GetLogger().Errorf("Unable to retrieve the stored flows of the reports: %s", err.Error())
+		}
+		for _, f := range stored {
+			byUUID[f.UUID] = f
+		}
+	}
+
+	if s.flowTable != nil {
+		for _, f := range s.flowTable.GetFlows() {
+			byUUID[f.UUID] = f
+		}
+	}
+
+	var flows []*flow.Flow
+	for _, f := range byUUID {
+		if matchFlowRange(f, from.Unix(), to.Unix()) {
+			flows = append(flows, f)
+		}
+	}
+	sort.Sort(sortByUUID(flows))
+
+	return flows
+}
+
+func (s *ReportScheduler) hosts() []string {
+	if s.graph == nil {
+		return nil
+	}
+
+	s.graph.RLock()
+	defer s.graph.RUnlock()
+
+	var hosts []string
+	for _, n := range s.graph.GetNodes() {
+		m := n.Metadata()
+		if m["Type"] != "host" {
+			continue
+		}
+		if name, ok := m["Name"].(string); ok {
+			hosts = append(hosts, name)
+		}
+	}
+	return hosts
+}
+
+func (s *ReportScheduler) input(from time.Time, to time.Time, state *ReportState) *reportInput {
+	in := &reportInput{
+		Flows:      s.flows(from, to),
+		Hosts:      s.hosts(),
+		KnownHosts: make(map[string]bool),
+		AlertNames: make(map[string]string),
+	}
+
+	if state != nil {
+		for _, host := range state.KnownHosts {
+			in.KnownHosts[host] = true
+		}
+	}
+
+	s.RLock()
+	in.Alerts = append(in.Alerts, s.fired...)
+	s.RUnlock()
+
+	if s.alerts != nil {
+		for id, resource := range s.alerts.Index() {
+			in.AlertNames[id] = resource.(*Alert).Name
+		}
+	}
+
+	return in
+}
+
+var spoolNameReplacer = regexp.MustCompile("[^a-zA-Z0-9_-]+")
+
+// spool writes the report in the spool directory, through a temporary file
+// so that the readers of the directory never see a partial report
+func (s *ReportScheduler) spool(r *Report, to time.Time, data []byte) error {
+	if err := os.MkdirAll(s.spoolDir, 0755); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%s-%s.%s", spoolNameReplacer.ReplaceAllString(r.Name, "_"), r.UUID,
+		to.UTC().Format("20060102T150405Z"), r.Format)
+	path := filepath.Join(s.spoolDir, name)
+
+	tmp, err := ioutil.TempFile(s.spoolDir, "."+name)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *ReportScheduler) post(r *Report, data []byte, contentType string) error {
+	resp, err := s.client.Post(r.URL, contentType, strings.NewReader(string(data)))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Webhook %s replied %s", r.URL, resp.Status)
+	}
+	return nil
+}
+
+// run renders and delivers the report covering the range ending at to, it
+// returns the rendered report along with its content type
+func (s *ReportScheduler) run(r *Report, to time.Time, state *ReportState) ([]byte, string, *reportInput, error) {
+	in := s.input(to.Add(-r.timeRange()), to, state)
+
+	output := renderReport(r, to.Add(-r.timeRange()), to, in)
+	data, contentType, err := output.Marshal(r.Format)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	switch r.Delivery {
+	case "webhook":
+		err = s.post(r, data, contentType)
+	default:
+		err = s.spool(r, to, data)
+	}
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	return data, contentType, in, nil
+}
+
+// RunNow renders and delivers the report covering the range ending now,
+// without updating the state of the scheduled runs
+func (s *ReportScheduler) RunNow(id string) ([]byte, string, error) {
+	resource, ok := s.handler.Get(id)
+	if !ok {
+		return nil, "", fmt.Errorf("Report %s not found", id)
+	}
+	report := resource.(*Report)
+
+	state, err := s.states.GetReportState(id)
+	if err != nil {
+		return nil, "", err
+	}
+
+	data, contentType, _, err := s.run(report, time.Now(), state)
+	return data, contentType, err
+}
+
+// runDue runs the reports due since their last run, the ones due for longer
+// than the grace period being skipped
+func (s *ReportScheduler) runDue(now time.Time) {
+	var reports []*Report
+	for _, resource := range s.handler.Index() {
+		reports = append(reports, resource.(*Report))
+	}
+	s.pruneAlerts(now, reports)
+
+	for _, report := range reports {
+		state, err := s.states.GetReportState(report.UUID)
+		if err != nil {
+			logging.GetLogger().Errorf("Unable to retrieve the state of the report %s: %s", report.Name, err.Error())
+			continue
+		}
+
+		lastRun := report.CreateTime
+		if state != nil {
+			lastRun = state.LastRun
+		} else {
+			state = &ReportState{}
+		}
+
+		due := report.LastDue(now)
+		if !due.After(lastRun) {
+			continue
+		}
+
+		if now.Sub(due) > s.grace {
+			logging.GetLogger().Warningf("Report %s due at %s skipped, outside the grace period", report.Name, due)
+		} else {
+			_, _, in, err := s.run(report, due, state)
+			if err != nil {
+				// retried at the next check while within the grace period
+				logging.GetLogger().Errorf("Unable to deliver the report %s: %s", report.Name, err.Error())
+				continue
+			}
+
+			state.KnownHosts = state.KnownHosts[:0]
+			for host := range in.KnownHosts {
+				state.KnownHosts = append(state.KnownHosts, host)
+			}
+			for _, host := range reportNewHosts(in) {
+				state.KnownHosts = append(state.KnownHosts, host)
+			}
+			sort.Strings(state.KnownHosts)
+		}
+
+		state.LastRun = due
+		if err := s.states.SetReportState(report.UUID, state); err != nil {
+			logging.GetLogger().Errorf("Unable to save the state of the report %s: %s", report.Name, err.Error())
+		}
+	}
+}
+
+func (s *ReportScheduler) onApiWatcherEvent(action string, id string, resource ApiResource) {
+	switch action {
+	case "expire", "delete":
+		if err := s.states.DelReportState(id); err != nil {
+			logging.GetLogger().Errorf("Unable to delete the state of the report %s: %s", id, err.Error())
+		}
+	}
+}
+
+func (s *ReportScheduler) Start() {
+	s.watcher = s.handler.AsyncWatch(s.onApiWatcherEvent)
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		s.runDue(time.Now())
+		for {
+			select {
+			case <-s.quit:
+				return
+			case now := <-ticker.C:
+				s.runDue(now)
+			}
+		}
+	}()
+}
+
+func (s *ReportScheduler) Stop() {
+	if s.watcher == nil {
+		return
+	}
+	s.watcher.Stop()
+	s.watcher = nil
+
+	s.quit <- true
+	s.wg.Wait()
+}
+
+func (s *ReportScheduler) runReport(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/report/")
+	if !strings.HasSuffix(path, "/run") {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	id := strings.TrimSuffix(path, "/run")
+	if _, ok := s.handler.Get(id); !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	data, contentType, err := s.RunNow(id)
+	if err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+func (s *ReportScheduler) registerEndpoints(r *shttp.Server) {
+	r.RegisterRoutes(shttp.RequireRoutesCredentials(s.credentials, []shttp.Route{
+		{
+			"ReportRun",
+			"POST",
+			shttp.PathPrefix("/api/report/"),
+			s.runReport,
+		},
+	}))
+}
+
+// RegisterReportApi registers the report resources along with the route
+// running a report immediately, POST /api/report/<id>/run. The reports
+// giving the flows, the routes require the credentials of the service.
+func RegisterReportApi(s string, a *ApiServer, g *graph.Graph, t *flow.Table, st storage.Storage) (*ReportScheduler, error) {
+	handler := &BasicApiHandler{
+		ResourceHandler: &ReportHandler{},
+		EtcdKeyAPI:      a.EtcdKeyAPI,
+	}
+	credentials := shttp.AuthenticationOptsFromConfig(s)
+	if err := a.RegisterApiHandlerWithCredentials(handler, credentials); err != nil {
+		return nil, err
+	}
+
+	client, err := shttp.NewClientFromConfig("webhook")
+	if err != nil {
+		return nil, err
+	}
+
+	scheduler := &ReportScheduler{
+		handler:     handler,
+		alerts:      a.GetHandler("alert"),
+		graph:       g,
+		flowTable:   t,
+		storage:     st,
+		states:      &etcdReportStateStore{kapi: a.EtcdKeyAPI},
+		client:      client,
+		spoolDir:    config.GetConfig().GetString(s + ".report.spool_dir"),
+		grace:       time.Duration(config.GetConfig().GetInt(s+".report.grace")) * time.Second,
+		interval:    time.Duration(config.GetConfig().GetInt(s+".report.check_interval")) * time.Second,
+		credentials: credentials,
+		quit:        make(chan bool),
+	}
+	scheduler.registerEndpoints(a.HTTPServer)
+
+	return scheduler, nil
+}
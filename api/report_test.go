@@ -0,0 +1,348 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package api
+
+import (
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/abbot/go-http-auth"
+
+	"github.com/redhat-cip/skydive/flow"
+	"github.com/redhat-cip/skydive/topology/graph"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden files of the reports")
+
+type memoryReportStateStore struct {
+	states map[string]*ReportState
+}
+
+func (s *memoryReportStateStore) GetReportState(id string) (*ReportState, error) {
+	if state, ok := s.states[id]; ok {
+		copied := *state
+		return &copied, nil
+	}
+	return nil, nil
+}
+
+func (s *memoryReportStateStore) SetReportState(id string, state *ReportState) error {
+	s.states[id] = state
+	return nil
+}
+
+func (s *memoryReportStateStore) DelReportState(id string) error {
+	delete(s.states, id)
+	return nil
+}
+
+var reportTestEnd = time.Date(2026, time.October, 15, 0, 0, 0, 0, time.UTC)
+
+func newReportTestFlow(uuid string, layers string, a string, b string, bytes uint64, last time.Time) *flow.Flow {
+	return &flow.Flow{
+		UUID:       uuid,
+		LayersPath: layers,
+		Statistics: &flow.FlowStatistics{
+			Start: last.Add(-time.Minute).Unix(),
+			Last:  last.Unix(),
+			Endpoints: []*flow.FlowEndpointsStatistics{
+				{
+					Type: flow.FlowEndpointType_ETHERNET,
+					AB:   &flow.FlowEndpointStatistics{Value: "00:00:00:00:00:01", Bytes: bytes, Packets: bytes / 100},
+					BA:   &flow.FlowEndpointStatistics{Value: "00:00:00:00:00:02", Bytes: bytes / 2, Packets: bytes / 200},
+				},
+				{
+					Type: flow.FlowEndpointType_IPV4,
+					AB:   &flow.FlowEndpointStatistics{Value: a},
+					BA:   &flow.FlowEndpointStatistics{Value: b},
+				},
+			},
+		},
+	}
+}
+
+// reportTestFlows is the synthetic dataset of the golden files, flow5
+// being outside of the range of the report
+func reportTestFlows() []*flow.Flow {
+	return []*flow.Flow{
+		newReportTestFlow("flow1", "Ethernet/IPv4/TCP", "10.0.0.1", "10.0.0.2", 10000, reportTestEnd.Add(-time.Hour)),
+		newReportTestFlow("flow2", "Ethernet/IPv4/UDP", "10.0.0.1", "10.0.0.3", 4000, reportTestEnd.Add(-2*time.Hour)),
+		newReportTestFlow("flow3", "Ethernet/IPv4/TCP", "10.0.0.3", "10.0.0.4", 2000, reportTestEnd.Add(-3*time.Hour)),
+		newReportTestFlow("flow4", "Ethernet/ARP", "", "", 600, reportTestEnd.Add(-4*time.Hour)),
+		newReportTestFlow("flow5", "Ethernet/IPv4/TCP", "10.0.0.9", "10.0.0.1", 99999, reportTestEnd.Add(-48*time.Hour)),
+	}
+}
+
+func newReportTestScheduler(t *testing.T) *ReportScheduler {
+	b, err := graph.NewMemoryBackend()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	g, err := graph.NewGraph(b)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	g.NewNode(graph.GenID(), graph.Metadata{"Type": "host", "Name": "host1"})
+	g.NewNode(graph.GenID(), graph.Metadata{"Type": "host", "Name": "host2"})
+	g.NewNode(graph.GenID(), graph.Metadata{"Type": "veth", "Name": "veth0"})
+
+	alerts := &memoryApiHandler{ResourceHandler: &AlertHandler{}, resources: make(map[string]ApiResource)}
+	alert := newTestAlert("high-mtu")
+	alert.UUID = "alert1"
+	alerts.Create(alert)
+
+	s := &ReportScheduler{
+		handler:   &memoryApiHandler{ResourceHandler: &ReportHandler{}, resources: make(map[string]ApiResource)},
+		alerts:    alerts,
+		graph:     g,
+		flowTable: flow.NewTableFromFlows(reportTestFlows()),
+		states:    &memoryReportStateStore{states: make(map[string]*ReportState)},
+		client:    http.DefaultClient,
+		spoolDir:  t.TempDir(),
+		grace:     time.Hour,
+		interval:  time.Second,
+		quit:      make(chan bool),
+	}
+
+	s.RecordAlert("alert1", reportTestEnd.Add(-5*time.Hour))
+	s.RecordAlert("alert1", reportTestEnd.Add(-2*time.Hour))
+	s.RecordAlert("alert2", reportTestEnd.Add(-time.Hour))
+	s.RecordAlert("alert2", reportTestEnd.Add(-30*time.Hour))
+
+	return s
+}
+
+func newTestReport(name string) *Report {
+	report := NewReport()
+	report.UUID = name
+	report.Name = name
+	report.Period = 86400
+	report.Sections = []string{
+		ReportSectionSummary,
+		ReportSectionTopTalkers,
+		ReportSectionNewHosts,
+		ReportSectionAlerts,
+		ReportSectionApplications,
+	}
+	report.Top = 3
+	report.CreateTime = reportTestEnd.Add(-7 * 24 * time.Hour)
+	return report
+}
+
+func TestReport_golden(t *testing.T) {
+	s := newReportTestScheduler(t)
+	state := &ReportState{KnownHosts: []string{"host1"}}
+
+	for _, format := range []string{"json", "html"} {
+		report := newTestReport("daily")
+		report.Format = format
+
+		in := s.input(reportTestEnd.Add(-report.timeRange()), reportTestEnd, state)
+		data, _, err := renderReport(report, reportTestEnd.Add(-report.timeRange()), reportTestEnd, in).Marshal(format)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		golden := filepath.Join("testdata", "report."+format)
+		if *updateGolden {
+			if err := ioutil.WriteFile(golden, data, 0644); err != nil {
+				t.Fatal(err.Error())
+			}
+		}
+
+		expected, err := ioutil.ReadFile(golden)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if string(data) != string(expected) {
+			t.Errorf("%s report differs from %s:\n%s", format, golden, string(data))
+		}
+	}
+}
+
+func TestReport_lastDue(t *testing.T) {
+	report := &Report{Period: 86400, Offset: 3600}
+
+	tests := []struct {
+		now time.Time
+		due time.Time
+	}{
+		{reportTestEnd.Add(2 * time.Hour), reportTestEnd.Add(time.Hour)},
+		{reportTestEnd.Add(time.Hour), reportTestEnd.Add(time.Hour)},
+		{reportTestEnd.Add(30 * time.Minute), reportTestEnd.Add(-23 * time.Hour)},
+	}
+
+	for _, test := range tests {
+		if due := report.LastDue(test.now); !due.Equal(test.due) {
+			t.Errorf("Wrong due time for %s: %s, expected %s", test.now, due.UTC(), test.due)
+		}
+	}
+}
+
+func TestReport_validate(t *testing.T) {
+	report := newTestReport("daily")
+	if err := report.Validate(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	invalid := []func(r *Report){
+		func(r *Report) { r.Period = 0 },
+		func(r *Report) { r.Offset = r.Period },
+		func(r *Report) { r.Sections = []string{"unknown"} },
+		func(r *Report) { r.Sections = nil },
+		func(r *Report) { r.Format = "pdf" },
+		func(r *Report) { r.Delivery = "webhook"; r.URL = "ftp://example.com" },
+		func(r *Report) { r.Delivery = "mail" },
+	}
+
+	for i, modify := range invalid {
+		report := newTestReport("daily")
+		modify(report)
+		if err := report.Validate(); err == nil {
+			t.Errorf("Report %d should be invalid: %+v", i, report)
+		}
+	}
+}
+
+func spooledReports(t *testing.T, dir string) []string {
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return files
+}
+
+func TestReportScheduler_missedRuns(t *testing.T) {
+	s := newReportTestScheduler(t)
+
+	// the analyzer was down for the last two runs of both reports, the
+	// second one being due outside of the grace period
+	recent := newTestReport("recent")
+	s.handler.Create(recent)
+	s.states.SetReportState("recent", &ReportState{LastRun: reportTestEnd.Add(-48 * time.Hour)})
+
+	old := newTestReport("old")
+	old.Offset = 3600
+	s.handler.Create(old)
+	s.states.SetReportState("old", &ReportState{LastRun: reportTestEnd.Add(-47 * time.Hour)})
+
+	now := reportTestEnd.Add(30 * time.Minute)
+	s.runDue(now)
+
+	files := spooledReports(t, s.spoolDir)
+	if len(files) != 1 || filepath.Base(files[0]) != "recent-recent-20261015T000000Z.json" {
+		t.Fatalf("Only the last run of the recent report expected: %v", files)
+	}
+
+	for _, id := range []string{"recent", "old"} {
+		state, _ := s.states.GetReportState(id)
+		if expected := s.handler.(*memoryApiHandler).resources[id].(*Report).LastDue(now); !state.LastRun.Equal(expected) {
+			t.Errorf("Wrong last run of %s: %s, expected %s", id, state.LastRun, expected)
+		}
+	}
+
+	state, _ := s.states.GetReportState("recent")
+	if len(state.KnownHosts) != 2 {
+		t.Errorf("The hosts of the run should be known: %v", state.KnownHosts)
+	}
+
+	s.runDue(now.Add(time.Minute))
+	if files := spooledReports(t, s.spoolDir); len(files) != 1 {
+		t.Errorf("The report shouldn't run twice: %v", files)
+	}
+}
+
+func TestReportScheduler_webhook(t *testing.T) {
+	var status int
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		received = append(received, r.Header.Get("Content-Type"))
+		if len(data) == 0 {
+			t.Error("Empty report posted")
+		}
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	s := newReportTestScheduler(t)
+	report := newTestReport("webhook")
+	report.Format = "html"
+	report.Delivery = "webhook"
+	report.URL = server.URL
+	s.handler.Create(report)
+
+	now := reportTestEnd.Add(time.Minute)
+
+	// a failed delivery is retried at the next check
+	status = http.StatusInternalServerError
+	s.runDue(now)
+	if state, _ := s.states.GetReportState("webhook"); state != nil {
+		t.Errorf("The failed run shouldn't be recorded: %+v", state)
+	}
+
+	status = http.StatusOK
+	s.runDue(now.Add(time.Minute))
+	if state, _ := s.states.GetReportState("webhook"); state == nil || !state.LastRun.Equal(reportTestEnd) {
+		t.Errorf("The run should be recorded: %+v", state)
+	}
+
+	if len(received) != 2 || received[1] != "text/html; charset=UTF-8" {
+		t.Errorf("Wrong webhook calls: %v", received)
+	}
+}
+
+func TestReportScheduler_runNow(t *testing.T) {
+	s := newReportTestScheduler(t)
+	s.handler.Create(newTestReport("adhoc"))
+
+	req, err := http.NewRequest("POST", "/api/report/adhoc/run", nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	w := httptest.NewRecorder()
+	s.runReport(w, &auth.AuthenticatedRequest{Request: *req})
+
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/json; charset=UTF-8" {
+		t.Fatalf("Wrong run reply: %d %s", w.Code, w.Body.String())
+	}
+	if files := spooledReports(t, s.spoolDir); len(files) != 1 {
+		t.Errorf("The report should be spooled: %v", files)
+	}
+	if state, _ := s.states.GetReportState("adhoc"); state != nil {
+		t.Errorf("An ad hoc run shouldn't update the schedule: %+v", state)
+	}
+
+	req, _ = http.NewRequest("POST", "/api/report/unknown/run", nil)
+	w = httptest.NewRecorder()
+	s.runReport(w, &auth.AuthenticatedRequest{Request: *req})
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Unknown report should be reported: %d", w.Code)
+	}
+}
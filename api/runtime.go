@@ -0,0 +1,88 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+
+	"github.com/abbot/go-http-auth"
+
+	shttp "github.com/redhat-cip/skydive/http"
+)
+
+type RuntimeApi struct {
+	Service string
+}
+
+// RuntimeStatus gives the state of the Go runtime, heap usage and garbage
+// collector statistics being part of the memory statistics.
+type RuntimeStatus struct {
+	Service    string
+	GoVersion  string
+	NumCPU     int
+	Goroutines int
+	Memory     runtime.MemStats
+}
+
+func (r *RuntimeApi) GetStatus() *RuntimeStatus {
+	status := &RuntimeStatus{
+		Service:    r.Service,
+		GoVersion:  runtime.Version(),
+		NumCPU:     runtime.NumCPU(),
+		Goroutines: runtime.NumGoroutine(),
+	}
+	runtime.ReadMemStats(&status.Memory)
+
+	return status
+}
+
+func (r *RuntimeApi) runtimeIndex(w http.ResponseWriter, req *auth.AuthenticatedRequest) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(r.GetStatus()); err != nil {
+		panic(err)
+	}
+}
+
+func (r *RuntimeApi) registerEndpoints(s *shttp.Server) {
+	routes := []shttp.Route{
+		{
+			"RuntimeIndex",
+			"GET",
+			"/api/status/runtime",
+			r.runtimeIndex,
+		},
+	}
+
+	s.RegisterRoutes(routes)
+}
+
+func RegisterRuntimeApi(s string, r *shttp.Server) {
+	t := &RuntimeApi{
+		Service: s,
+	}
+
+	t.registerEndpoints(r)
+}
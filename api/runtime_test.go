@@ -0,0 +1,69 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRuntimeStatus(t *testing.T) {
+	r := &RuntimeApi{Service: "analyzer"}
+
+	// some goroutines to be counted
+	quit := make(chan bool)
+	defer close(quit)
+	for i := 0; i != 10; i++ {
+		go func() { <-quit }()
+	}
+
+	w := httptest.NewRecorder()
+	r.runtimeIndex(w, newFakeRequest(t, "/api/status/runtime"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var status RuntimeStatus
+	if err := json.NewDecoder(w.Body).Decode(&status); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if status.Service != "analyzer" || status.GoVersion == "" || status.NumCPU < 1 {
+		t.Errorf("Wrong runtime description: %+v", status)
+	}
+
+	if status.Goroutines < 11 {
+		t.Errorf("At least 11 goroutines expected, got %d", status.Goroutines)
+	}
+
+	m := status.Memory
+	if m.HeapAlloc == 0 || m.HeapAlloc > m.Sys || m.TotalAlloc < m.HeapAlloc || m.HeapObjects == 0 {
+		t.Errorf("Implausible memory statistics: heap %d, sys %d, total %d, objects %d", m.HeapAlloc, m.Sys, m.TotalAlloc, m.HeapObjects)
+	}
+
+	if m.NumGC > 0 && m.PauseTotalNs == 0 {
+		t.Errorf("Garbage collections without pause: %d", m.NumGC)
+	}
+}
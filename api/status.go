@@ -0,0 +1,289 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/abbot/go-http-auth"
+	etcd "github.com/coreos/etcd/client"
+	"golang.org/x/net/context"
+
+	"github.com/redhat-cip/skydive/flow"
+	"github.com/redhat-cip/skydive/flow/ingestion"
+	"github.com/redhat-cip/skydive/flow/mappings"
+	"github.com/redhat-cip/skydive/handover"
+	shttp "github.com/redhat-cip/skydive/http"
+	"github.com/redhat-cip/skydive/storage"
+	"github.com/redhat-cip/skydive/storage/kafka"
+)
+
+type StatusApi struct {
+	Service             string
+	WSServers           []*shttp.WSServer
+	FlowMappingPipeline *mappings.FlowMappingPipeline
+	FairQueue           *ingestion.FairQueue
+	Workers             *ingestion.WorkerPool
+	Sequencer           *ingestion.Sequencer
+	Datagrams           *ingestion.DatagramReader
+	NetFlow             *ingestion.NetFlowListener
+	SFlow               *ingestion.SFlowListener
+	KafkaSink           *kafka.FlowSink
+	ClientVersions      *shttp.ClientVersions
+	Handover            *handover.Handover
+	WAL                 *storage.WAL
+	Mux                 *shttp.Mux
+	Started             time.Time
+	GraphServer         *shttp.WSServer
+	FlowTable           *flow.Table
+	PacketDecoders      *flow.DecoderChain
+	Storage             storage.Storage
+	EtcdKeyAPI          etcd.KeysAPI
+	EmbeddedEtcd        bool
+}
+
+type Status struct {
+	Service         string
+	WSServers       []shttp.WSServerStatus
+	FlowCorrelation *mappings.CorrelationStats `json:",omitempty"`
+	FlowDatagrams   *ingestion.DatagramStats   `json:",omitempty"`
+	FlowWorkers     *ingestion.WorkerPoolStats `json:",omitempty"`
+	NetFlow         *ingestion.NetFlowStats    `json:",omitempty"`
+	SFlow           *ingestion.SFlowStats      `json:",omitempty"`
+	KafkaExport     *kafka.FlowSinkStats       `json:",omitempty"`
+	ClientVersions  []shttp.ClientVersionStats `json:",omitempty"`
+	Handover        *handover.Status           `json:",omitempty"`
+	StorageWAL      *storage.WALStatus         `json:",omitempty"`
+	Mux             *shttp.MuxStats            `json:",omitempty"`
+	Health          *Health                    `json:",omitempty"`
+	// packets each packet decoder failed to decode, by decoder name
+	PacketDecoderFailures map[string]uint64 `json:",omitempty"`
+}
+
+// The states of the health of a service
+const (
+	HealthOK          = "ok"
+	HealthDegraded    = "degraded"
+	HealthUnavailable = "unavailable"
+)
+
+// healthTimeout bounds the checks of the components
+const healthTimeout = 2 * time.Second
+
+// ComponentHealth tells whether a component the service depends on is
+// reachable
+type ComponentHealth struct {
+	Up    bool
+	Error string `json:",omitempty"`
+}
+
+func newComponentHealth(err error) *ComponentHealth {
+	if err != nil {
+		return &ComponentHealth{Error: err.Error()}
+	}
+	return &ComponentHealth{Up: true}
+}
+
+// Health tells whether the service can serve the queries, degraded when
+// one of its components is down
+type Health struct {
+	State        string
+	Storage      *ComponentHealth `json:",omitempty"`
+	Etcd         *ComponentHealth `json:",omitempty"`
+	EmbeddedEtcd bool             `json:",omitempty"`
+	Agents       int
+	FlowTable    int
+	Started      time.Time
+	Uptime       string
+}
+
+// GetStatus returns the status of the service
+func (s *StatusApi) GetStatus() *Status {
+	status := &Status{
+		Service:   s.Service,
+		WSServers: []shttp.WSServerStatus{},
+	}
+	for _, server := range s.WSServers {
+		status.WSServers = append(status.WSServers, server.GetStatus())
+	}
+	if s.FlowMappingPipeline != nil {
+		stats := s.FlowMappingPipeline.CorrelationStats()
+		status.FlowCorrelation = &stats
+	}
+	if s.Datagrams != nil {
+		stats := s.Datagrams.Stats()
+		status.FlowDatagrams = &stats
+	}
+	if s.Workers != nil {
+		stats := s.Workers.Stats()
+		status.FlowWorkers = &stats
+	}
+	if s.NetFlow != nil {
+		stats := s.NetFlow.Stats()
+		status.NetFlow = &stats
+	}
+	if s.SFlow != nil {
+		stats := s.SFlow.Stats()
+		status.SFlow = &stats
+	}
+	if s.KafkaSink != nil {
+		stats := s.KafkaSink.Stats()
+		status.KafkaExport = &stats
+	}
+	if s.ClientVersions != nil {
+		status.ClientVersions = s.ClientVersions.Stats()
+	}
+	if s.Handover != nil {
+		handover := s.Handover.Status()
+		status.Handover = &handover
+	}
+	if s.WAL != nil {
+		wal := s.WAL.Status()
+		status.StorageWAL = &wal
+	}
+	if s.Mux != nil {
+		mux := s.Mux.Stats()
+		status.Mux = &mux
+	}
+	if s.PacketDecoders != nil {
+		status.PacketDecoderFailures = s.PacketDecoders.Failures()
+	}
+
+	return status
+}
+
+// GetHealth checks the components of the service. The storage being down
+// only degrades the service, the live flows and the topology being still
+// served, while an analyzer handing over to a new one can't serve anymore.
+func (s *StatusApi) GetHealth(ctx context.Context) *Health {
+	ctx, cancel := context.WithTimeout(ctx, healthTimeout)
+	defer cancel()
+
+	health := &Health{
+		State:        HealthOK,
+		EmbeddedEtcd: s.EmbeddedEtcd,
+		Started:      s.Started,
+		Uptime:       time.Since(s.Started).String(),
+	}
+	if s.Storage != nil {
+		health.Storage = newComponentHealth(storage.Ping(ctx, s.Storage))
+	}
+	if s.EtcdKeyAPI != nil {
+		_, err := s.EtcdKeyAPI.Get(ctx, "/", nil)
+		health.Etcd = newComponentHealth(err)
+	}
+	if s.GraphServer != nil {
+		for _, client := range s.GraphServer.GetStatus().Clients {
+			// the agents introduce themselves with their host
+			if client.Host != "" {
+				health.Agents++
+			}
+		}
+	}
+	if s.FlowTable != nil {
+		health.FlowTable = s.FlowTable.Len()
+	}
+
+	if (health.Storage != nil && !health.Storage.Up) || (health.Etcd != nil && !health.Etcd.Up) {
+		health.State = HealthDegraded
+	}
+	if s.Handover != nil {
+		if state := s.Handover.Status().State; state == handover.Draining || state == handover.HandedOver {
+			health.State = HealthUnavailable
+		}
+	}
+
+	return health
+}
+
+func (s *StatusApi) statusIndex(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
+	status := s.GetStatus()
+	status.Health = s.GetHealth(shttp.RequestContext(&r.Request))
+
+	code := http.StatusOK
+	if status.Health.State == HealthUnavailable {
+		code = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		panic(err)
+	}
+}
+
+// GetAgents returns the ingestion status of the agents
+func (s *StatusApi) GetAgents() []ingestion.AgentStatus {
+	agents := []ingestion.AgentStatus{}
+	if s.FairQueue != nil {
+		agents = s.FairQueue.Status()
+	}
+	if s.Sequencer != nil {
+		agents = ingestion.AddSequences(agents, s.Sequencer.Status())
+	}
+	return agents
+}
+
+func (s *StatusApi) agentsIndex(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
+	agents := s.GetAgents()
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(agents); err != nil {
+		panic(err)
+	}
+}
+
+func (s *StatusApi) registerEndpoints(r *shttp.Server) {
+	routes := []shttp.Route{
+		{
+			"StatusIndex",
+			"GET",
+			"/api/status",
+			s.statusIndex,
+		},
+		{
+			"AgentsIndex",
+			"GET",
+			"/api/status/agents",
+			s.agentsIndex,
+		},
+	}
+
+	r.RegisterRoutes(routes)
+}
+
+func RegisterStatusApi(s string, r *shttp.Server, wsServers ...*shttp.WSServer) *StatusApi {
+	t := &StatusApi{
+		Service:        s,
+		WSServers:      wsServers,
+		ClientVersions: r.ClientVersions,
+		Started:        time.Now(),
+	}
+
+	t.registerEndpoints(r)
+
+	return t
+}
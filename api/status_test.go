@@ -0,0 +1,118 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"golang.org/x/net/context"
+
+	"github.com/redhat-cip/skydive/flow"
+)
+
+// unreachableStorage fails to answer the pings
+type unreachableStorage struct {
+	fakeStorage
+}
+
+func (s *unreachableStorage) Ping(ctx context.Context) error {
+	return errors.New("connection refused")
+}
+
+func TestStatusApi_health(t *testing.T) {
+	sa := &StatusApi{
+		Service: "analyzer",
+		Started: time.Now().Add(-time.Minute),
+		FlowTable: flow.NewTableFromFlows([]*flow.Flow{
+			newDiscoveryTestFlow("flow1", "probe1", "10.0.0.1", "10.0.0.2", "80", 100),
+			newDiscoveryTestFlow("flow2", "probe1", "10.0.0.1", "10.0.0.3", "80", 100),
+		}),
+		Storage: &fakeStorage{},
+	}
+
+	status := func() *Status {
+		w := httptest.NewRecorder()
+		sa.statusIndex(w, newFakeRequest(t, "/api/status"))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", w.Code)
+		}
+
+		var status Status
+		if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+			t.Fatal(err.Error())
+		}
+		if status.Health == nil {
+			t.Fatal("Expected the health to be reported")
+		}
+		return &status
+	}
+
+	health := status().Health
+	if health.State != HealthOK || health.Storage == nil || !health.Storage.Up || health.Etcd != nil {
+		t.Errorf("Expected a healthy storage and no etcd, got %+v", health)
+	}
+	if health.FlowTable != 2 || health.Agents != 0 || health.Uptime == "" {
+		t.Errorf("Wrong counters: %+v", health)
+	}
+
+	// the service is still served without its storage
+	sa.Storage = &unreachableStorage{}
+	health = status().Health
+	if health.State != HealthDegraded || health.Storage.Up || health.Storage.Error == "" {
+		t.Errorf("Expected the storage to degrade the service, got %+v", health.Storage)
+	}
+}
+
+func TestStatusApi_packetDecoderFailures(t *testing.T) {
+	decoders := flow.NewDecoderChain()
+	decoders.Add("fabric", flow.PacketDecoderMatch{EtherType: layers.EthernetType(0x88b5)}, flow.NewSkipDecoder(6))
+
+	// vendor header truncated to 3 bytes
+	data := []byte{
+		0x00, 0x22, 0x22, 0x22, 0x22, 0x22,
+		0x00, 0x11, 0x11, 0x11, 0x11, 0x11,
+		0x88, 0xb5,
+		0xde, 0xad, 0xbe,
+	}
+	decoders.Decode(gopacket.NewPacket(data, layers.LayerTypeEthernet, gopacket.Default))
+
+	sa := &StatusApi{Service: "agent", PacketDecoders: decoders}
+
+	w := httptest.NewRecorder()
+	sa.statusIndex(w, newFakeRequest(t, "/api/status"))
+
+	var status Status
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatal(err.Error())
+	}
+	if status.PacketDecoderFailures["fabric"] != 1 {
+		t.Errorf("Expected the failure of the decoder to be reported, got %v", status.PacketDecoderFailures)
+	}
+}
@@ -0,0 +1,474 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package api
+
+import (
+	"archive/tar"
+	// aliased, bytes being the discovery of the flow bytes
+	stdbytes "bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"runtime/pprof"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/abbot/go-http-auth"
+
+	"github.com/redhat-cip/skydive/config"
+	"github.com/redhat-cip/skydive/flow"
+	shttp "github.com/redhat-cip/skydive/http"
+	"github.com/redhat-cip/skydive/logging"
+	"github.com/redhat-cip/skydive/topology/graph"
+	"github.com/redhat-cip/skydive/version"
+)
+
+const (
+	// SupportBundleVersion has to be increased each time the format changes
+	SupportBundleVersion = 1
+	// SupportManifestEntry is the entry of the manifest in the archive
+	SupportManifestEntry = "manifest.json"
+)
+
+// SupportSection describes an entry of a support bundle
+type SupportSection struct {
+	Name        string
+	Description string
+	Size        int
+	Truncated   bool   `json:",omitempty"`
+	Error       string `json:",omitempty"`
+}
+
+// SupportManifest lists the sections of a support bundle, written first in
+// the archive
+type SupportManifest struct {
+	Version    int
+	Service    string
+	Created    time.Time
+	Anonymized bool
+	Sections   []SupportSection
+}
+
+// GraphSummary counts the nodes by type and the edges by relation type
+type GraphSummary struct {
+	Nodes map[string]int
+	Edges map[string]int
+}
+
+var (
+	macPattern  = regexp.MustCompile(`\b(?:[0-9a-fA-F]{2}:){5}[0-9a-fA-F]{2}\b`)
+	ipv6Pattern = regexp.MustCompile(`(?:[0-9a-fA-F]{0,4}:){2,7}[0-9a-fA-F]{0,4}`)
+	ipv4Pattern = regexp.MustCompile(`\b(?:[0-9]{1,3}\.){3}[0-9]{1,3}\b`)
+)
+
+// Anonymizer replaces the IP and MAC addresses and the host names of the
+// support bundles by aliases (ip-1, mac-1, host-1), the same value getting
+// the same alias in all the sections of a bundle. The loopback and
+// unspecified addresses are kept.
+type Anonymizer struct {
+	aliases map[string]string
+	counts  map[string]int
+	hosts   []string
+}
+
+func (a *Anonymizer) alias(kind string, value string) string {
+	if alias, ok := a.aliases[value]; ok {
+		return alias
+	}
+
+	a.counts[kind]++
+	alias := fmt.Sprintf("%s-%d", kind, a.counts[kind])
+	a.aliases[value] = alias
+	return alias
+}
+
+func (a *Anonymizer) ip(match string) string {
+	ip := net.ParseIP(match)
+	if ip == nil || ip.IsLoopback() || ip.IsUnspecified() {
+		return match
+	}
+	return a.alias("ip", ip.String())
+}
+
+// Anonymize returns the data with the addresses and the host names replaced
+func (a *Anonymizer) Anonymize(data string) string {
+	data = macPattern.ReplaceAllStringFunc(data, func(match string) string {
+		return a.alias("mac", strings.ToLower(match))
+	})
+	data = ipv6Pattern.ReplaceAllStringFunc(data, a.ip)
+	data = ipv4Pattern.ReplaceAllStringFunc(data, a.ip)
+
+	for _, host := range a.hosts {
+		data = strings.Replace(data, host, a.alias("host", host), -1)
+	}
+
+	return data
+}
+
+type sortByLengthDesc []string
+
+func (s sortByLengthDesc) Len() int {
+	return len(s)
+}
+
+func (s sortByLengthDesc) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s sortByLengthDesc) Less(i, j int) bool {
+	return len(s[i]) > len(s[j])
+}
+
+// NewAnonymizer returns an anonymizer of the given host names, the ones too
+// short to be told apart from the rest of the data being ignored
+func NewAnonymizer(hosts ...string) *Anonymizer {
+	a := &Anonymizer{
+		aliases: make(map[string]string),
+		counts:  make(map[string]int),
+	}
+
+	seen := make(map[string]bool)
+	for _, host := range hosts {
+		if len(host) < 3 || host == "localhost" || seen[host] {
+			continue
+		}
+		seen[host] = true
+		a.hosts = append(a.hosts, host)
+	}
+	// the longest first, not to replace a part of a longer name
+	sort.Sort(sortByLengthDesc(a.hosts))
+
+	return a
+}
+
+type supportCollector struct {
+	name        string
+	description string
+	collect     func() ([]byte, error)
+}
+
+// SupportApi builds the support bundles of the analyzer, gathering its
+// sanitized configuration, its status and its journals. The flows and the
+// topology are only summed up.
+type SupportApi struct {
+	Service   string
+	Status    *StatusApi
+	FlowTable *flow.Table
+	Graph     *graph.Graph
+	// maximum size of a section, the larger ones being truncated
+	MaxSectionSize int
+	// credentials required by the bundle endpoint, none when not set
+	Credentials *shttp.AuthenticationOpts
+	// one bundle built at once
+	busy chan bool
+}
+
+// marshalSection returns the indented JSON of a section, without escaping
+// the HTML characters to keep it readable
+func marshalSection(v interface{}) ([]byte, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(unescapeHTML(data), '\n'), nil
+}
+
+// unescapeHTML reverts the escaping of <, > and & done by encoding/json,
+// the escaped backslashes being kept as is
+func unescapeHTML(data []byte) []byte {
+	var section stdbytes.Buffer
+	for i := 0; i < len(data); i++ {
+		if data[i] != '\\' || i+1 == len(data) {
+			section.WriteByte(data[i])
+			continue
+		}
+
+		if data[i+1] == 'u' && i+6 <= len(data) {
+			switch string(data[i+2 : i+6]) {
+			case "003c":
+				section.WriteByte('<')
+				i += 5
+				continue
+			case "003e":
+				section.WriteByte('>')
+				i += 5
+				continue
+			case "0026":
+				section.WriteByte('&')
+				i += 5
+				continue
+			}
+		}
+		section.Write(data[i : i+2])
+		i++
+	}
+	return section.Bytes()
+}
+
+func profileSection(name string) func() ([]byte, error) {
+	return func() ([]byte, error) {
+		var profile stdbytes.Buffer
+		if err := pprof.Lookup(name).WriteTo(&profile, 1); err != nil {
+			return nil, err
+		}
+		return profile.Bytes(), nil
+	}
+}
+
+func journalSection(name string) func() ([]byte, error) {
+	return func() ([]byte, error) {
+		return marshalSection(logging.GetJournal(name).Entries())
+	}
+}
+
+func (s *SupportApi) graphSummary() *GraphSummary {
+	s.Graph.RLock()
+	defer s.Graph.RUnlock()
+
+	summary := &GraphSummary{Nodes: make(map[string]int), Edges: make(map[string]int)}
+	for _, n := range s.Graph.GetNodes() {
+		t, _ := n.Metadata()["Type"].(string)
+		summary.Nodes[t]++
+	}
+	for _, e := range s.Graph.GetEdges() {
+		t, _ := e.Metadata()["RelationType"].(string)
+		summary.Edges[t]++
+	}
+	return summary
+}
+
+// hostNames returns the names of the analyzer and of the hosts of the
+// topology
+func (s *SupportApi) hostNames() []string {
+	var hosts []string
+	if hostname, err := os.Hostname(); err == nil {
+		hosts = append(hosts, hostname)
+	}
+	if id := config.GetConfig().GetString("host_id"); id != "" {
+		hosts = append(hosts, id)
+	}
+
+	if s.Graph != nil {
+		s.Graph.RLock()
+		for _, n := range s.Graph.GetNodes() {
+			if m := n.Metadata(); m["Type"] == "host" {
+				if name, ok := m["Name"].(string); ok {
+					hosts = append(hosts, name)
+				}
+			}
+		}
+		s.Graph.RUnlock()
+	}
+
+	return hosts
+}
+
+func (s *SupportApi) collectors() []supportCollector {
+	collectors := []supportCollector{
+		{"config.json", "running configuration, secrets redacted", func() ([]byte, error) {
+			return marshalSection(config.Sanitized())
+		}},
+		{"version.json", "version of the analyzer", func() ([]byte, error) {
+			return marshalSection(&shttp.APIVersionInfo{
+				Version:             version.Version,
+				APIVersion:          version.APIVersion,
+				MinClientAPIVersion: version.MinClientAPIVersion,
+			})
+		}},
+		{"runtime.json", "state of the Go runtime", func() ([]byte, error) {
+			return marshalSection((&RuntimeApi{Service: s.Service}).GetStatus())
+		}},
+		{"slow_queries.json", "last slow flow searches", journalSection(logging.JournalSlowQueries)},
+		{"audit.json", "last audited actions", journalSection(logging.JournalAudit)},
+		{"parse_errors.json", "last errors parsing the flows of the agents", journalSection(logging.JournalParseErrors)},
+		{"goroutine.txt", "goroutine profile", profileSection("goroutine")},
+		{"heap.txt", "heap profile", profileSection("heap")},
+	}
+
+	if s.Status != nil {
+		collectors = append(collectors,
+			supportCollector{"status.json", "status of the analyzer, as /api/status", func() ([]byte, error) {
+				return marshalSection(s.Status.GetStatus())
+			}},
+			supportCollector{"agents.json", "ingestion status of the agents, as /api/status/agents", func() ([]byte, error) {
+				return marshalSection(s.Status.GetAgents())
+			}},
+		)
+	}
+	if s.FlowTable != nil {
+		collectors = append(collectors, supportCollector{"flow_table.json", "summary of the flow table, without the flows", func() ([]byte, error) {
+			return marshalSection(s.FlowTable.Summary())
+		}})
+	}
+	if s.Graph != nil {
+		collectors = append(collectors, supportCollector{"graph.json", "number of nodes by type and of edges by relation type", func() ([]byte, error) {
+			return marshalSection(s.graphSummary())
+		}})
+	}
+
+	return collectors
+}
+
+// WriteBundle writes the support bundle, a gzipped tar archive of the
+// manifest followed by the sections, anonymized on demand
+func (s *SupportApi) WriteBundle(w io.Writer, anonymize bool) error {
+	manifest := &SupportManifest{
+		Version:    SupportBundleVersion,
+		Service:    s.Service,
+		Created:    time.Now().UTC(),
+		Anonymized: anonymize,
+	}
+
+	var anonymizer *Anonymizer
+	if anonymize {
+		anonymizer = NewAnonymizer(s.hostNames()...)
+	}
+
+	var contents [][]byte
+	for _, c := range s.collectors() {
+		section := SupportSection{Name: c.name, Description: c.description}
+
+		data, err := c.collect()
+		if err != nil {
+			// a failing section doesn't prevent the others to be collected
+			section.Error, data = err.Error(), nil
+		}
+		if anonymizer != nil {
+			data = []byte(anonymizer.Anonymize(string(data)))
+			section.Error = anonymizer.Anonymize(section.Error)
+		}
+		if s.MaxSectionSize > 0 && len(data) > s.MaxSectionSize {
+			data, section.Truncated = data[:s.MaxSectionSize], true
+		}
+
+		section.Size = len(data)
+		manifest.Sections = append(manifest.Sections, section)
+		contents = append(contents, data)
+	}
+
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+
+	write := func(name string, data []byte) error {
+		hdr := &tar.Header{
+			Name:    name,
+			Mode:    0600,
+			Size:    int64(len(data)),
+			ModTime: manifest.Created,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+
+	data, err := marshalSection(manifest)
+	if err != nil {
+		return err
+	}
+	if err := write(SupportManifestEntry, data); err != nil {
+		return err
+	}
+	for i, section := range manifest.Sections {
+		if err := write(section.Name, contents[i]); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+func (s *SupportApi) supportBundle(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
+	anonymize := false
+	if a := r.URL.Query().Get("anonymize"); a != "" {
+		var err error
+		if anonymize, err = strconv.ParseBool(a); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("Invalid anonymize value: " + a))
+			return
+		}
+	}
+
+	select {
+	case s.busy <- true:
+		defer func() { <-s.busy }()
+	default:
+		w.WriteHeader(statusTooManyRequests)
+		w.Write([]byte("A support bundle is already being built"))
+		return
+	}
+
+	logging.GetJournal(logging.JournalAudit).RecordContext(shttp.RequestContext(&r.Request), "Support bundle built for %q, anonymized: %v", r.Username, anonymize)
+
+	// the sections are collected before the archive is sent, only the
+	// sending can fail
+	w.Header().Set("Content-Type", "application/gzip")
+	w.WriteHeader(http.StatusOK)
+	if err := s.WriteBundle(w, anonymize); err != nil {
+		logging.GetLogger().Criticalf("Failed to send support bundle: %s", err.Error())
+	}
+}
+
+func (s *SupportApi) registerEndpoints(r *shttp.Server) {
+	routes := []shttp.Route{
+		{
+			"SupportBundle",
+			"GET",
+			"/api/admin/support-bundle",
+			s.supportBundle,
+		},
+	}
+
+	r.RegisterAdminRoutes(shttp.RequireRoutesCredentials(s.Credentials, routes))
+}
+
+func NewSupportApi(service string, status *StatusApi, flowtable *flow.Table, g *graph.Graph) *SupportApi {
+	return &SupportApi{
+		Service:        service,
+		Status:         status,
+		FlowTable:      flowtable,
+		Graph:          g,
+		MaxSectionSize: config.GetConfig().GetInt(service + ".support_bundle.max_section_size"),
+		Credentials:    shttp.AuthenticationOptsFromConfig(service),
+		busy:           make(chan bool, 1),
+	}
+}
+
+// RegisterSupportApi registers the support bundle endpoint on the admin
+// listener
+func RegisterSupportApi(service string, status *StatusApi, flowtable *flow.Table, g *graph.Graph, r *shttp.Server) *SupportApi {
+	s := NewSupportApi(service, status, flowtable, g)
+	s.registerEndpoints(r)
+	return s
+}
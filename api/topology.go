@@ -24,19 +24,53 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/abbot/go-http-auth"
+
+	"github.com/redhat-cip/skydive/config"
+	"github.com/redhat-cip/skydive/flow"
 	shttp "github.com/redhat-cip/skydive/http"
+	"github.com/redhat-cip/skydive/logging"
+	"github.com/redhat-cip/skydive/storage"
 	"github.com/redhat-cip/skydive/topology"
 	"github.com/redhat-cip/skydive/topology/graph"
 )
 
+// TopologyApi serves the topology and the Gremlin queries. The Flows step
+// is available once FlowTable or Storage is set, the Capture step scoping
+// the flows to the captures given by Captures. The catalog of the metadata
+// keys is served once Keys is set. The queries using the Flows step require
+// the Credentials when set.
 type TopologyApi struct {
-	Service string
-	Graph   *graph.Graph
+	Service     string
+	Graph       *graph.Graph
+	FlowTable   *flow.Table
+	Storage     storage.Storage
+	Captures    topology.CaptureLookup
+	Keys        *graph.MetadataKeyCatalog
+	Credentials *shttp.AuthenticationOpts
+}
+
+var errFlowsUnauthorized = errors.New("The Flows step requires the credentials of the flows")
+
+// unauthorizedFlowTraversalExtension rejects the queries using the Flows
+// step, for the requests not giving the credentials of the flows
+type unauthorizedFlowTraversalExtension struct {
+	*topology.FlowTraversalExtension
+}
+
+func (e unauthorizedFlowTraversalExtension) ParseStep(t graph.Token, p graph.GremlinTraversalStepParams) (graph.GremlinTraversalStep, error) {
+	step, err := e.FlowTraversalExtension.ParseStep(t, p)
+	if step != nil {
+		return nil, errFlowsUnauthorized
+	}
+	return step, err
 }
 
 type Topology struct {
@@ -59,26 +93,64 @@ func (t *TopologyApi) topologyIndex(w http.ResponseWriter, r *auth.Authenticated
 	if resource.GremlinQuery != "" {
 		tr := graph.NewGremlinTraversalParser(strings.NewReader(resource.GremlinQuery), t.Graph)
 		tr.AddTraversalExtension(topology.NewTopologyTraversalExtension())
+		if t.FlowTable != nil || t.Storage != nil {
+			if t.Credentials != nil && !shttp.HasCredentials(t.Credentials, &r.Request) {
+				tr.AddTraversalExtension(unauthorizedFlowTraversalExtension{t.flowTraversalExtension()})
+			} else {
+				tr.AddTraversalExtension(t.flowTraversalExtension())
+			}
+		}
 
 		ts, err := tr.Parse()
+		if err == errFlowsUnauthorized {
+			shttp.ReplyUnauthorized(w)
+			return
+		}
 		if err != nil {
 			w.WriteHeader(http.StatusBadRequest)
 			w.Write([]byte(err.Error()))
 			return
 		}
 
-		res, err := ts.Exec()
+		if ts.HasWriteSteps() {
+			t.execWriteQuery(w, r, ts, resource.GremlinQuery)
+			return
+		}
+
+		// the query is aborted when the client stopped waiting for it
+		res, err := ts.ExecContext(shttp.RequestContext(&r.Request))
 		if err != nil {
 			w.WriteHeader(http.StatusBadRequest)
 			w.Write([]byte(err.Error()))
 			return
 		}
 
+		// ex: a scope that couldn't be applied
+		for _, warning := range ts.Warnings() {
+			w.Header().Add("Warning", fmt.Sprintf(`199 skydive "%s"`, warning))
+		}
+
+		values := res.Values()
+		if etag, err := elementsETag(values); err == nil && notModified(w, &r.Request, etag) {
+			return
+		}
+
 		w.WriteHeader(http.StatusOK)
-		if err := json.NewEncoder(w).Encode(res.Values()); err != nil {
+		if err := json.NewEncoder(w).Encode(values); err != nil {
 			panic(err)
 		}
 	} else {
+		var elements []interface{}
+		for _, n := range t.Graph.GetNodes() {
+			elements = append(elements, n)
+		}
+		for _, e := range t.Graph.GetEdges() {
+			elements = append(elements, e)
+		}
+		if etag, err := elementsETag(elements); err == nil && notModified(w, &r.Request, etag) {
+			return
+		}
+
 		w.WriteHeader(http.StatusOK)
 		if err := json.NewEncoder(w).Encode(t.Graph); err != nil {
 			panic(err)
@@ -86,6 +158,69 @@ func (t *TopologyApi) topologyIndex(w http.ResponseWriter, r *auth.Authenticated
 	}
 }
 
+func (t *TopologyApi) flowTraversalExtension() *topology.FlowTraversalExtension {
+	e := topology.NewFlowTraversalExtension(t.FlowTable, t.Storage)
+	e.Captures = t.Captures
+	e.Window = time.Duration(config.GetConfig().GetInt(t.Service+".gremlin_flows.window")) * time.Second
+	e.MaxResults = config.GetConfig().GetInt(t.Service + ".query_max_results")
+	return e
+}
+
+func (t *TopologyApi) isWriteAdmin(username string) bool {
+	for _, admin := range config.GetConfig().GetStringSlice(t.Service + ".gremlin_write.admins") {
+		if admin == username {
+			return true
+		}
+	}
+	return false
+}
+
+// execWriteQuery applies a query using the write steps, reserved to the
+// gremlin_write admins and audit logged with the whole query
+func (t *TopologyApi) execWriteQuery(w http.ResponseWriter, r *auth.AuthenticatedRequest, ts *graph.GremlinTraversalSequence, query string) {
+	ctx := shttp.RequestContext(&r.Request)
+
+	if !t.isWriteAdmin(r.Username) {
+		logging.GetContextLogger(ctx).Warningf("Gremlin write query denied to %q: %s", r.Username, query)
+		logging.GetJournal(logging.JournalAudit).RecordContext(ctx, "Gremlin write query denied to %q: %s", r.Username, query)
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(graph.ErrWriteNotPermitted.Error()))
+		return
+	}
+
+	origin := config.GetConfig().GetString(t.Service + ".gremlin_write.origin")
+	ts.EnableWrites(origin)
+
+	res, err := ts.ExecContext(ctx)
+	if err != nil {
+		logging.GetContextLogger(ctx).Warningf("Gremlin write query of %q failed: %s: %s", r.Username, query, err.Error())
+		logging.GetJournal(logging.JournalAudit).RecordContext(ctx, "Gremlin write query of %q failed: %s: %s", r.Username, query, err.Error())
+		if _, ok := err.(*graph.OriginError); ok {
+			w.WriteHeader(http.StatusForbidden)
+		} else {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+		w.Write([]byte(err.Error()))
+		return
+	}
+	logging.GetContextLogger(ctx).Infof("Gremlin write query of %q applied: %s", r.Username, query)
+	logging.GetJournal(logging.JournalAudit).RecordContext(ctx, "Gremlin write query of %q applied: %s", r.Username, query)
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(res.Values()); err != nil {
+		panic(err)
+	}
+}
+
+// topologyKeys returns the catalog of the metadata keys of the nodes
+func (t *TopologyApi) topologyKeys(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
+	if t.Keys == nil {
+		serveKeyCatalog(w, r, nil)
+		return
+	}
+	serveKeyCatalog(w, r, t.Keys.KeyCatalog)
+}
+
 func (t *TopologyApi) registerEndpoints(r *shttp.Server) {
 	routes := []shttp.Route{
 		{
@@ -94,16 +229,25 @@ func (t *TopologyApi) registerEndpoints(r *shttp.Server) {
 			"/api/topology",
 			t.topologyIndex,
 		},
+		{
+			"TopologyKeys",
+			"GET",
+			"/api/topology/keys",
+			t.topologyKeys,
+		},
 	}
 
 	r.RegisterRoutes(routes)
 }
 
-func RegisterTopologyApi(s string, g *graph.Graph, r *shttp.Server) {
+func RegisterTopologyApi(s string, g *graph.Graph, r *shttp.Server) *TopologyApi {
 	t := &TopologyApi{
-		Service: s,
-		Graph:   g,
+		Service:     s,
+		Graph:       g,
+		Credentials: shttp.AuthenticationOptsFromConfig(s),
 	}
 
 	t.registerEndpoints(r)
+
+	return t
 }
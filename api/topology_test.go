@@ -0,0 +1,261 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/abbot/go-http-auth"
+	gologging "github.com/op/go-logging"
+
+	"github.com/redhat-cip/skydive/config"
+	"github.com/redhat-cip/skydive/flow"
+	shttp "github.com/redhat-cip/skydive/http"
+	"github.com/redhat-cip/skydive/logging"
+	"github.com/redhat-cip/skydive/topology/graph"
+)
+
+func newTopologyRequest(t *testing.T, query string, etag string) *auth.AuthenticatedRequest {
+	req, err := http.NewRequest("GET", "/api/topology", strings.NewReader(query))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	return &auth.AuthenticatedRequest{Request: *req}
+}
+
+func TestTopologyApi_etag(t *testing.T) {
+	backend, _ := graph.NewMemoryBackend()
+	g, _ := graph.NewGraph(backend)
+	ta := &TopologyApi{Graph: g}
+
+	host := g.NewNode(graph.GenID(), graph.Metadata{"Name": "host", "Type": "host"})
+	for _, name := range []string{"eth0", "eth1", "lo"} {
+		g.Link(host, g.NewNode(graph.GenID(), graph.Metadata{"Name": name, "Type": "device"}))
+	}
+
+	for _, query := range []string{"", `{"GremlinQuery": "G.V().Has('Type', 'device')"}`} {
+		w := httptest.NewRecorder()
+		ta.topologyIndex(w, newTopologyRequest(t, query, ""))
+		etag := w.Header().Get("ETag")
+		if w.Code != http.StatusOK || etag == "" {
+			t.Fatalf("Expected status 200 with an ETag, got %d %q", w.Code, etag)
+		}
+
+		w = httptest.NewRecorder()
+		ta.topologyIndex(w, newTopologyRequest(t, query, etag))
+		if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+			t.Errorf("Expected status 304 without body, got %d: %s", w.Code, w.Body.String())
+		}
+
+		g.AddMetadata(g.LookupFirstNode(graph.Metadata{"Name": "eth0"}), "MTU", int64(9000+len(query)))
+
+		w = httptest.NewRecorder()
+		ta.topologyIndex(w, newTopologyRequest(t, query, etag))
+		if w.Code != http.StatusOK || w.Header().Get("ETag") == etag {
+			t.Errorf("Expected status 200 with a new ETag, got %d %q", w.Code, w.Header().Get("ETag"))
+		}
+	}
+}
+
+func TestTopologyApi_keys(t *testing.T) {
+	backend, _ := graph.NewMemoryBackend()
+	g, _ := graph.NewGraph(backend)
+	ta := &TopologyApi{Graph: g, Keys: graph.NewMetadataKeyCatalog(g, 1, 5, 0)}
+
+	get := func(etag string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/api/topology/keys", nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		w := httptest.NewRecorder()
+		ta.topologyKeys(w, &auth.AuthenticatedRequest{Request: *req})
+		return w
+	}
+
+	n := g.NewNode(graph.GenID(), graph.Metadata{"Name": "eth0", "Type": "device"})
+
+	w := get("")
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || !strings.HasPrefix(etag, "W/") || !strings.Contains(w.Body.String(), `"Key":"Name"`) {
+		t.Fatalf("Expected the keys with a weak ETag, got %d %q: %s", w.Code, etag, w.Body.String())
+	}
+
+	// only the counts changed
+	g.AddMetadata(n, "Name", "eth0")
+	if w = get(etag); w.Code != http.StatusNotModified {
+		t.Errorf("Expected status 304, got %d", w.Code)
+	}
+
+	g.AddMetadata(n, "MTU", 1500)
+	if w = get(etag); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"Key":"MTU"`) {
+		t.Errorf("Expected the new key, got %d: %s", w.Code, w.Body.String())
+	}
+
+	ta.Keys = nil
+	if w = get(""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 without catalog, got %d", w.Code)
+	}
+}
+
+func TestETagMatch(t *testing.T) {
+	for header, match := range map[string]bool{
+		`"abc"`:             true,
+		`W/"abc"`:           true,
+		`"xyz", "abc"`:      true,
+		`*`:                 true,
+		`"xyz"`:             false,
+		`abc`:               false,
+		`"xyz",W/"abcd"`:    false,
+		`"other" , W/"abc"`: true,
+	} {
+		if etagMatch(header, `W/"abc"`) != match {
+			t.Errorf("Wrong match of %s", header)
+		}
+	}
+}
+
+func TestTopologyApi_writeSteps(t *testing.T) {
+	backend, _ := graph.NewMemoryBackend()
+	g, _ := graph.NewGraph(backend)
+	ta := &TopologyApi{Service: "analyzer", Graph: g}
+
+	config.GetConfig().Set("analyzer.gremlin_write.admins", []string{"admin"})
+	defer config.GetConfig().Set("analyzer.gremlin_write.admins", []string{})
+
+	query := `{"GremlinQuery": "G.AddV('Name', 'switch1')"}`
+
+	for _, username := range []string{"", "guest"} {
+		r := newTopologyRequest(t, query, "")
+		r.Username = username
+
+		w := httptest.NewRecorder()
+		ta.topologyIndex(w, r)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("Expected status 403 for %q, got %d: %s", username, w.Code, w.Body.String())
+		}
+	}
+	if len(g.GetNodes()) != 0 {
+		t.Fatalf("The graph shouldn't have been modified: %s", g.String())
+	}
+
+	r := newTopologyRequest(t, query, "")
+	r.Username = "admin"
+
+	w := httptest.NewRecorder()
+	ta.topologyIndex(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	n := g.LookupFirstNode(graph.Metadata{"Name": "switch1"})
+	if n == nil || n.Metadata()[graph.OriginKey] != "user" {
+		t.Fatalf("switch1 should have been created with the user origin: %s", g.String())
+	}
+}
+
+func TestTopologyApi_flowsCredentials(t *testing.T) {
+	backend, _ := graph.NewMemoryBackend()
+	g, _ := graph.NewGraph(backend)
+	ta := &TopologyApi{
+		Service:     "analyzer",
+		Graph:       g,
+		FlowTable:   flow.NewTable(),
+		Credentials: &shttp.AuthenticationOpts{Token: "secret"},
+	}
+
+	for _, test := range []struct {
+		query         string
+		authorization string
+		expected      int
+	}{
+		{`{"GremlinQuery": "G.V().Flows()"}`, "", http.StatusUnauthorized},
+		{`{"GremlinQuery": "G.V().Flows()"}`, "Bearer wrong", http.StatusUnauthorized},
+		{`{"GremlinQuery": "G.V().Flows()"}`, "Bearer secret", http.StatusOK},
+		// the queries of the topology only stay public
+		{`{"GremlinQuery": "G.V()"}`, "", http.StatusOK},
+	} {
+		r := newTopologyRequest(t, test.query, "")
+		if test.authorization != "" {
+			r.Header.Set("Authorization", test.authorization)
+		}
+
+		w := httptest.NewRecorder()
+		ta.topologyIndex(w, r)
+		if w.Code != test.expected {
+			t.Errorf("%s with %q: expected status %d, got %d: %s", test.query, test.authorization, test.expected, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestTopologyApi_writeAuditRequestID(t *testing.T) {
+	backend, _ := graph.NewMemoryBackend()
+	g, _ := graph.NewGraph(backend)
+
+	server := shttp.NewServer("analyzer", "127.0.0.1", 0, shttp.NewNoAuthenticationBackend())
+	ta := &TopologyApi{Service: "analyzer", Graph: g}
+	ta.registerEndpoints(server)
+
+	// the requests are anonymous without authentication
+	config.GetConfig().Set("analyzer.gremlin_write.admins", []string{""})
+	defer config.GetConfig().Set("analyzer.gremlin_write.admins", []string{})
+
+	logger := logging.GetLogger()
+	saved := *logger
+	defer func() { *logger = saved }()
+
+	memory := gologging.NewMemoryBackend(10)
+	logger.SetBackend(gologging.AddModuleLevel(memory))
+
+	ts := httptest.NewServer(server.Router)
+	defer ts.Close()
+
+	query := `{"GremlinQuery": "G.AddV('Name', 'switch1')"}`
+	req, _ := http.NewRequest("GET", ts.URL+"/api/topology", strings.NewReader(query))
+	req.Header.Set(shttp.RequestIDHeader, "audit-id")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || resp.Header.Get(shttp.RequestIDHeader) != "audit-id" {
+		t.Fatalf("Expected status 200 with the request ID, got %d with %q", resp.StatusCode, resp.Header.Get(shttp.RequestIDHeader))
+	}
+
+	for n := memory.Head(); n != nil; n = n.Next() {
+		if msg := n.Record.Message(); strings.Contains(msg, "Gremlin write query") {
+			if !strings.HasPrefix(msg, "[request_id=audit-id] ") {
+				t.Errorf("Expected the audit entry to hold the request ID, got %q", msg)
+			}
+			return
+		}
+	}
+	t.Error("No audit entry logged")
+}
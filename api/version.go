@@ -0,0 +1,70 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/abbot/go-http-auth"
+
+	shttp "github.com/redhat-cip/skydive/http"
+	"github.com/redhat-cip/skydive/version"
+)
+
+type VersionApi struct {
+}
+
+func (v *VersionApi) versionIndex(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
+	info := shttp.APIVersionInfo{
+		Version:             version.Version,
+		APIVersion:          version.APIVersion,
+		MinClientAPIVersion: version.MinClientAPIVersion,
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(info); err != nil {
+		panic(err)
+	}
+}
+
+func (v *VersionApi) registerEndpoints(s *shttp.Server) {
+	routes := []shttp.Route{
+		{
+			"VersionIndex",
+			"GET",
+			"/api/version",
+			v.versionIndex,
+		},
+	}
+
+	s.RegisterRoutes(routes)
+}
+
+// RegisterVersionApi registers /api/version, giving the API version and the
+// oldest client API version served, checked by the clients
+func RegisterVersionApi(r *shttp.Server) {
+	v := &VersionApi{}
+	v.registerEndpoints(r)
+}
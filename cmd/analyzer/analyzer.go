@@ -23,6 +23,7 @@
 package analyzer
 
 import (
+	"io/ioutil"
 	"os"
 	"os/signal"
 	"syscall"
@@ -34,6 +35,8 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var bootstrapConfig string
+
 var Analyzer = &cobra.Command{
 	Use:          "analyzer",
 	Short:        "Skydive analyzer",
@@ -43,17 +46,46 @@ var Analyzer = &cobra.Command{
 		logging.SetLoggingID("analyzer")
 		logging.GetLogger().Notice("Skydive Analyzer starting...")
 
+		// first run without configuration
+		if config.GetConfigPath() == "" {
+			config.GetConfig().Set("analyzer.bootstrap.enabled", true)
+		}
+
 		server, err := analyzer.NewServerFromConfig()
 		if err != nil {
 			logging.GetLogger().Fatalf("Can't start Analyzer : %v", err)
 		}
 
-		server.ListenAndServe()
+		if bootstrapConfig != "" {
+			c, err := analyzer.StarterConfig()
+			if err == nil {
+				err = ioutil.WriteFile(bootstrapConfig, c, 0600)
+			}
+			if err != nil {
+				logging.GetLogger().Fatalf("Can't write the starter configuration : %v", err)
+			}
+			logging.GetLogger().Noticef("Starter configuration written to %s", bootstrapConfig)
+		}
+
+		if err := server.Start(); err != nil {
+			logging.GetLogger().Fatalf("Can't start Analyzer : %v", err)
+		}
 
 		logging.GetLogger().Notice("Skydive Analyzer started !")
 		ch := make(chan os.Signal)
 		signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM)
-		<-ch
+
+		// exits as well once handed over to a new analyzer
+		var handedOver <-chan struct{}
+		if server.Handover != nil {
+			handedOver = server.Handover.Done()
+		}
+
+		select {
+		case <-ch:
+		case <-handedOver:
+			logging.GetLogger().Notice("Skydive Analyzer handed over, exiting")
+		}
 
 		server.Stop()
 
@@ -65,7 +97,7 @@ func init() {
 	Analyzer.Flags().String("listen", "127.0.0.1:8082", "address and port for the analyzer API")
 	config.GetConfig().BindPFlag("analyzer.listen", Analyzer.Flags().Lookup("listen"))
 
-	Analyzer.Flags().Int("flowtable-expire", 600, "expiration time for flowtable entries")
+	Analyzer.Flags().String("flowtable-expire", "600", "expiration time for flowtable entries, in second or as a duration (30s, 10m)")
 	config.GetConfig().BindPFlag("analyzer.flowtable_expire", Analyzer.Flags().Lookup("flowtable-expire"))
 
 	Analyzer.Flags().Int("flowtable-update", 60, "send updated flows to storage every time (second)")
@@ -91,4 +123,9 @@ func init() {
 
 	Analyzer.Flags().String("gremlin", "ws://127.0.0.1:8182", "gremlin server")
 	config.GetConfig().BindPFlag("graph.gremlin", Analyzer.Flags().Lookup("gremlin"))
+
+	Analyzer.Flags().Bool("bootstrap", false, "serve the checklist of the dependencies on /api/bootstrap/checklist, enabled without configuration")
+	config.GetConfig().BindPFlag("analyzer.bootstrap.enabled", Analyzer.Flags().Lookup("bootstrap"))
+
+	Analyzer.Flags().StringVar(&bootstrapConfig, "bootstrap-config", "", "write a starter configuration holding the effective settings")
 }
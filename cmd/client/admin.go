@@ -0,0 +1,324 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package client
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/redhat-cip/skydive/api"
+	shttp "github.com/redhat-cip/skydive/http"
+	"github.com/redhat-cip/skydive/logging"
+)
+
+const (
+	backupArchiveEntry = "backup.json"
+)
+
+var (
+	backupOutput      string
+	backupPassphrase  string
+	restoreInput      string
+	restorePassphrase string
+	restorePolicy     []string
+	restoreDefault    string
+	clearFlush        bool
+	supportOutput     string
+	supportAnon       bool
+)
+
+var AdminCmd = &cobra.Command{
+	Use:          "admin",
+	Short:        "Analyzer administration",
+	Long:         "Analyzer administration",
+	SilenceUsage: false,
+}
+
+func writeBackupArchive(path string, backup *api.Backup) error {
+	data, err := json.MarshalIndent(backup, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+
+	hdr := &tar.Header{
+		Name:    backupArchiveEntry,
+		Mode:    0600,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if _, err := tw.Write(data); err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+func readBackupArchive(path string) (*api.Backup, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+
+	tr := tar.NewReader(gr)
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			return nil, fmt.Errorf("No %s found in %s", backupArchiveEntry, path)
+		}
+
+		if hdr.Name == backupArchiveEntry {
+			var backup api.Backup
+			if err := json.NewDecoder(tr).Decode(&backup); err != nil {
+				return nil, err
+			}
+			return &backup, nil
+		}
+	}
+}
+
+var AdminBackup = &cobra.Command{
+	Use:   "backup",
+	Short: "Backup the analyzer state",
+	Long:  "Backup the analyzer state",
+	Run: func(cmd *cobra.Command, args []string) {
+		client := shttp.NewAdminRestClientFromConfig(&authenticationOpts)
+		if client == nil {
+			os.Exit(1)
+		}
+
+		resp, err := client.Request("GET", "api/admin/backup", nil)
+		if err != nil {
+			logging.GetLogger().Errorf(err.Error())
+			os.Exit(1)
+		}
+
+		if resp.StatusCode != 200 {
+			logging.GetLogger().Errorf("Backup failed: %s%s", resp.Status, shttp.RequestIDDetails(resp))
+			os.Exit(1)
+		}
+
+		var backup api.Backup
+		if err := json.NewDecoder(resp.Body).Decode(&backup); err != nil {
+			logging.GetLogger().Errorf("Unable to decode backup: %s", err.Error())
+			os.Exit(1)
+		}
+
+		redacted, err := backup.Seal(backupPassphrase)
+		if err != nil {
+			logging.GetLogger().Errorf("Unable to seal the backup: %s", err.Error())
+			os.Exit(1)
+		}
+		if redacted > 0 && backupPassphrase == "" {
+			logging.GetLogger().Warningf("The secrets of %d resources are left out of the backup, give a --passphrase to encrypt them", redacted)
+		}
+
+		if err := writeBackupArchive(backupOutput, &backup); err != nil {
+			logging.GetLogger().Errorf("Unable to write %s: %s", backupOutput, err.Error())
+			os.Exit(1)
+		}
+	},
+}
+
+var AdminRestore = &cobra.Command{
+	Use:   "restore",
+	Short: "Restore the analyzer state",
+	Long:  "Restore the analyzer state",
+	Run: func(cmd *cobra.Command, args []string) {
+		backup, err := readBackupArchive(restoreInput)
+		if err != nil {
+			logging.GetLogger().Errorf("Unable to read %s: %s", restoreInput, err.Error())
+			os.Exit(1)
+		}
+
+		if err := backup.Open(restorePassphrase); err != nil {
+			logging.GetLogger().Errorf(err.Error())
+			os.Exit(1)
+		}
+
+		// policies are given as type=policy, the default applies to the others
+		policies := make(map[string]string)
+		for name := range backup.Objects {
+			policies[name] = restoreDefault
+		}
+		if backup.Graph != nil {
+			policies[api.BackupGraphName] = restoreDefault
+		}
+		for _, p := range restorePolicy {
+			kv := strings.SplitN(p, "=", 2)
+			if len(kv) != 2 {
+				logging.GetLogger().Errorf("Malformed policy: %s", p)
+				os.Exit(1)
+			}
+			policies[kv[0]] = kv[1]
+		}
+
+		s, err := json.Marshal(&api.RestoreRequest{Backup: backup, Policies: policies})
+		if err != nil {
+			logging.GetLogger().Errorf(err.Error())
+			os.Exit(1)
+		}
+
+		client := shttp.NewAdminRestClientFromConfig(&authenticationOpts)
+		if client == nil {
+			os.Exit(1)
+		}
+
+		resp, err := client.Request("POST", "api/admin/restore", bytes.NewReader(s))
+		if err != nil {
+			logging.GetLogger().Errorf(err.Error())
+			os.Exit(1)
+		}
+
+		if resp.StatusCode != 200 {
+			data, _ := ioutil.ReadAll(resp.Body)
+			logging.GetLogger().Errorf("Restore failed: %s: %s%s", resp.Status, shttp.ErrorMessage(data), shttp.RequestIDDetails(resp))
+			os.Exit(1)
+		}
+
+		var report api.RestoreReport
+		if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+			logging.GetLogger().Errorf("Unable to decode restore report: %s", err.Error())
+			os.Exit(1)
+		}
+
+		if report.ConfigMismatch {
+			logging.GetLogger().Warning("The analyzer configuration differs from the one of the backup")
+		}
+		printJSON(&report)
+	},
+}
+
+var AdminClearFlows = &cobra.Command{
+	Use:   "clear-flows",
+	Short: "Clear the flow table of the analyzer",
+	Long:  "Clear the flow table of the analyzer, storing the flows first with --flush",
+	Run: func(cmd *cobra.Command, args []string) {
+		client := shttp.NewAdminRestClientFromConfig(&authenticationOpts)
+		if client == nil {
+			os.Exit(1)
+		}
+
+		resp, err := client.Request("POST", fmt.Sprintf("api/admin/flow/clear?flush=%v", clearFlush), nil)
+		if err != nil {
+			logging.GetLogger().Errorf(err.Error())
+			os.Exit(1)
+		}
+
+		if resp.StatusCode != 200 {
+			data, _ := ioutil.ReadAll(resp.Body)
+			logging.GetLogger().Errorf("Clear failed: %s: %s%s", resp.Status, shttp.ErrorMessage(data), shttp.RequestIDDetails(resp))
+			os.Exit(1)
+		}
+
+		var report api.FlowTableClearReport
+		if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+			logging.GetLogger().Errorf("Unable to decode clear report: %s", err.Error())
+			os.Exit(1)
+		}
+		printJSON(&report)
+	},
+}
+
+var AdminSupportBundle = &cobra.Command{
+	Use:   "support-bundle",
+	Short: "Collect the analyzer internals for a support request",
+	Long:  "Collect the sanitized configuration, the status, the journals, the profiles and the flow table and graph summaries of the analyzer, anonymized with --anonymize",
+	Run: func(cmd *cobra.Command, args []string) {
+		client := shttp.NewAdminRestClientFromConfig(&authenticationOpts)
+		if client == nil {
+			os.Exit(1)
+		}
+
+		resp, err := client.Request("GET", fmt.Sprintf("api/admin/support-bundle?anonymize=%v", supportAnon), nil)
+		if err != nil {
+			logging.GetLogger().Errorf(err.Error())
+			os.Exit(1)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != 200 {
+			data, _ := ioutil.ReadAll(resp.Body)
+			logging.GetLogger().Errorf("Support bundle failed: %s: %s%s", resp.Status, shttp.ErrorMessage(data), shttp.RequestIDDetails(resp))
+			os.Exit(1)
+		}
+
+		f, err := os.OpenFile(supportOutput, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err == nil {
+			_, err = io.Copy(f, resp.Body)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+		}
+		if err != nil {
+			logging.GetLogger().Errorf("Unable to write %s: %s", supportOutput, err.Error())
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	AdminCmd.AddCommand(AdminBackup)
+	AdminCmd.AddCommand(AdminRestore)
+	AdminCmd.AddCommand(AdminClearFlows)
+	AdminCmd.AddCommand(AdminSupportBundle)
+
+	AdminBackup.Flags().StringVarP(&backupOutput, "output", "", "backup.tar.gz", "backup archive")
+	AdminBackup.Flags().StringVarP(&backupPassphrase, "passphrase", "", "", "passphrase encrypting the secrets of the resources, left out without")
+	AdminRestore.Flags().StringVarP(&restoreInput, "input", "", "backup.tar.gz", "backup archive")
+	AdminRestore.Flags().StringVarP(&restorePassphrase, "passphrase", "", "", "passphrase of the secrets of the backup")
+	AdminRestore.Flags().StringSliceVarP(&restorePolicy, "policy", "", []string{}, "conflict policy by resource type, graph for the graph elements, ex: alert=overwrite")
+	AdminRestore.Flags().StringVarP(&restoreDefault, "default-policy", "", api.RestorePolicySkip, "default conflict policy: skip, overwrite or fail")
+	AdminClearFlows.Flags().BoolVarP(&clearFlush, "flush", "", false, "store and export the flows as expired before clearing them")
+	AdminSupportBundle.Flags().StringVarP(&supportOutput, "output", "", "bundle.tar.gz", "support bundle archive")
+	AdminSupportBundle.Flags().BoolVarP(&supportAnon, "anonymize", "", false, "replace the IP and MAC addresses and the host names by aliases")
+}
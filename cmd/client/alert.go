@@ -23,10 +23,16 @@
 package client
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
+	"sort"
+	"time"
 
 	"github.com/redhat-cip/skydive/api"
+	shttp "github.com/redhat-cip/skydive/http"
 	"github.com/redhat-cip/skydive/logging"
 	"github.com/redhat-cip/skydive/validator"
 
@@ -39,6 +45,9 @@ var (
 	alertSelect      string
 	alertTest        string
 	alertAction      string
+	alertType        string
+	alertFlowFilter  string
+	alertWindow      int
 )
 
 var AlertCmd = &cobra.Command{
@@ -63,11 +72,28 @@ var AlertCreate = &cobra.Command{
 		setFromFlag(cmd, "select", &alert.Select)
 		setFromFlag(cmd, "action", &alert.Action)
 		setFromFlag(cmd, "test", &alert.Test)
+		setFromFlag(cmd, "flow-filter", &alert.FlowFilter)
+		alert.Window = alertWindow
+		switch alertType {
+		case "fixed":
+			alert.Type = api.FIXED
+		case "absence":
+			alert.Type = api.ABSENCE
+		default:
+			fmt.Println("Error: unknown alert type", alertType)
+			cmd.Usage()
+			os.Exit(1)
+		}
 		if errs := validator.Validate(alert); errs != nil {
 			fmt.Println("Error: ", errs)
 			cmd.Usage()
 			os.Exit(1)
 		}
+		if err := alert.Validate(); err != nil {
+			fmt.Println("Error: ", err)
+			cmd.Usage()
+			os.Exit(1)
+		}
 		if err := client.Create("alert", &alert); err != nil {
 			logging.GetLogger().Errorf(err.Error())
 			os.Exit(1)
@@ -137,12 +163,114 @@ var AlertDelete = &cobra.Command{
 	},
 }
 
+// printAlertContext writes the summary of what triggered a fire
+func printAlertContext(w io.Writer, ctx *api.AlertContext) {
+	fire := ctx.Fire
+	fmt.Fprintf(w, "Alert %s, fire %d at %s\n", fire.Alert, fire.N, fire.Timestamp.Format(time.RFC3339))
+	fmt.Fprintf(w, "Condition: %s\n", ctx.Expression)
+	if fire.Test != "" {
+		fmt.Fprintf(w, "Test: %s\n", fire.Test)
+	}
+
+	if len(fire.Values) > 0 {
+		names := make([]string, 0, len(fire.Values))
+		for name := range fire.Values {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		fmt.Fprintln(w, "Values:")
+		for _, name := range names {
+			fmt.Fprintf(w, "  %s = %v\n", name, fire.Values[name])
+		}
+	}
+
+	if len(ctx.Nodes) > 0 || len(ctx.MissingNodes) > 0 {
+		fmt.Fprintln(w, "Nodes (current state):")
+		for _, n := range ctx.Nodes {
+			m := n.Metadata()
+			fmt.Fprintf(w, "  %s %v (%v)\n", n.ID, m["Name"], m["Type"])
+		}
+		for _, id := range ctx.MissingNodes {
+			fmt.Fprintf(w, "  %s (removed)\n", id)
+		}
+	}
+
+	from, to := time.Unix(fire.Query.From, 0), time.Unix(fire.Query.To, 0)
+	fmt.Fprintf(w, "Flows from %s to %s", from.Format(time.RFC3339), to.Format(time.RFC3339))
+	if fire.Query.FlowFilter != "" {
+		fmt.Fprintf(w, " matching %s", fire.Query.FlowFilter)
+	}
+	fmt.Fprintln(w, ":")
+	if len(ctx.Flows) == 0 {
+		fmt.Fprintln(w, "  none")
+	}
+	for _, f := range ctx.Flows {
+		fmt.Fprintf(w, "  %s %s", f.UUID, f.LayersPath)
+		if f.TrackingID != "" {
+			fmt.Fprintf(w, " tracking %s", f.TrackingID)
+		}
+		fmt.Fprintln(w)
+	}
+	if ctx.MoreFlows > 0 {
+		fmt.Fprintf(w, "  and %d more: %s\n", ctx.MoreFlows, ctx.Next)
+	}
+}
+
+var AlertContext = &cobra.Command{
+	Use:   "context [fire]",
+	Short: "Display what triggered a fire of an alert",
+	Long:  "Display the nodes, the values and the flows that triggered a fire of an alert, given as <alert>/<n>",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			cmd.Usage()
+			os.Exit(1)
+		}
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		id, n, err := api.ParseAlertFireID(args[0])
+		if err != nil {
+			fmt.Println("Error: ", err)
+			cmd.Usage()
+			os.Exit(1)
+		}
+
+		client := api.NewRestClientFromConfig(&authenticationOpts)
+		if client == nil {
+			os.Exit(1)
+		}
+
+		resp, err := client.Request("GET", fmt.Sprintf("api/alert/%s/fires/%d/context", id, n), nil)
+		if err != nil {
+			logging.GetLogger().Errorf(err.Error())
+			os.Exit(1)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != 200 {
+			data, _ := ioutil.ReadAll(resp.Body)
+			logging.GetLogger().Errorf("Context failed: %s: %s%s", resp.Status, shttp.ErrorMessage(data), shttp.RequestIDDetails(resp))
+			os.Exit(1)
+		}
+
+		var ctx api.AlertContext
+		if err := json.NewDecoder(resp.Body).Decode(&ctx); err != nil {
+			logging.GetLogger().Errorf("Unable to decode the alert context: %s", err.Error())
+			os.Exit(1)
+		}
+		printAlertContext(os.Stdout, &ctx)
+	},
+}
+
 func addAlertFlags(cmd *cobra.Command) {
 	cmd.Flags().StringVarP(&alertName, "name", "", "", "alert name")
 	cmd.Flags().StringVarP(&alertDescription, "description", "", "", "alert description")
 	cmd.Flags().StringVarP(&alertSelect, "select", "", "", "alert select criteria")
 	cmd.Flags().StringVarP(&alertTest, "test", "", "", "alert test")
 	cmd.Flags().StringVarP(&alertAction, "action", "", "", "alert action")
+	cmd.Flags().StringVarP(&alertType, "type", "", "fixed", "alert type: fixed or absence")
+	cmd.Flags().StringVarP(&alertFlowFilter, "flow-filter", "", "", "absence alert flow filter, ex: IPV4.A=10.0.0.1,IPV4.B=10.0.0.2")
+	cmd.Flags().IntVarP(&alertWindow, "window", "", 0, "absence alert window in second")
 }
 
 func init() {
@@ -150,6 +278,7 @@ func init() {
 	AlertCmd.AddCommand(AlertGet)
 	AlertCmd.AddCommand(AlertCreate)
 	AlertCmd.AddCommand(AlertDelete)
+	AlertCmd.AddCommand(AlertContext)
 
 	addAlertFlags(AlertCreate)
 }
@@ -0,0 +1,206 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/redhat-cip/skydive/api"
+	shttp "github.com/redhat-cip/skydive/http"
+	"github.com/redhat-cip/skydive/logging"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+var bundleFile string
+
+var BundleCmd = &cobra.Command{
+	Use:          "bundle",
+	Short:        "Manage bundles",
+	Long:         "Manage bundles of captures, alerts and reports applied as a whole",
+	SilenceUsage: false,
+}
+
+// yamlToJSON converts the maps decoded by yaml, keyed by interface{}, to
+// maps keyed by string
+func yamlToJSON(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{})
+		for key, child := range v {
+			m[fmt.Sprintf("%v", key)] = yamlToJSON(child)
+		}
+		return m
+	case []interface{}:
+		for i, child := range v {
+			v[i] = yamlToJSON(child)
+		}
+	}
+	return value
+}
+
+// readBundle reads a bundle written in YAML or JSON
+func readBundle(path string) (*api.Bundle, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	if data, err = json.Marshal(yamlToJSON(doc)); err != nil {
+		return nil, err
+	}
+
+	var bundle api.Bundle
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		return nil, err
+	}
+	return &bundle, nil
+}
+
+var BundleApply = &cobra.Command{
+	Use:   "apply",
+	Short: "Apply bundle",
+	Long:  "Create or update the bundle and its resources, all of them or none",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		if bundleFile == "" {
+			cmd.Usage()
+			os.Exit(1)
+		}
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		client := api.NewCrudClientFromConfig(&authenticationOpts)
+		if client == nil {
+			os.Exit(1)
+		}
+		bundle, err := readBundle(bundleFile)
+		if err != nil {
+			logging.GetLogger().Errorf("Unable to read %s: %s", bundleFile, err.Error())
+			os.Exit(1)
+		}
+		if err := bundle.Validate(); err != nil {
+			fmt.Println("Error: ", err)
+			os.Exit(1)
+		}
+		if err := client.Create("bundle", bundle); err != nil {
+			logging.GetLogger().Errorf(err.Error())
+			os.Exit(1)
+		}
+		printJSON(bundle.Resources)
+	},
+}
+
+var BundleList = &cobra.Command{
+	Use:   "list",
+	Short: "List bundles",
+	Long:  "List bundles",
+	Run: func(cmd *cobra.Command, args []string) {
+		var bundles map[string]api.Bundle
+		client := api.NewCrudClientFromConfig(&authenticationOpts)
+		if client == nil {
+			os.Exit(1)
+		}
+		if err := client.List("bundle", &bundles); err != nil {
+			logging.GetLogger().Errorf(err.Error())
+			os.Exit(1)
+		}
+		printJSON(bundles)
+	},
+}
+
+var BundleStatus = &cobra.Command{
+	Use:   "status [bundle]",
+	Short: "Display the health of the bundle resources",
+	Long:  "Display whether each resource of the bundle exists and matches its declaration",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			cmd.Usage()
+			os.Exit(1)
+		}
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		client := api.NewRestClientFromConfig(&authenticationOpts)
+		if client == nil {
+			os.Exit(1)
+		}
+
+		resp, err := client.Request("GET", "api/bundle/"+args[0]+"/status", nil)
+		if err != nil {
+			logging.GetLogger().Errorf(err.Error())
+			os.Exit(1)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != 200 {
+			data, _ := ioutil.ReadAll(resp.Body)
+			logging.GetLogger().Errorf("Status failed: %s: %s%s", resp.Status, shttp.ErrorMessage(data), shttp.RequestIDDetails(resp))
+			os.Exit(1)
+		}
+
+		var status []api.BundleResourceStatus
+		if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+			logging.GetLogger().Errorf("Unable to decode the bundle status: %s", err.Error())
+			os.Exit(1)
+		}
+		printJSON(status)
+	},
+}
+
+var BundleDelete = &cobra.Command{
+	Use:   "delete [bundle]",
+	Short: "Delete bundle",
+	Long:  "Delete the bundle along with its resources",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			cmd.Usage()
+			os.Exit(1)
+		}
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		client := api.NewCrudClientFromConfig(&authenticationOpts)
+		if client == nil {
+			os.Exit(1)
+		}
+		if err := client.Delete("bundle", args[0]); err != nil {
+			logging.GetLogger().Errorf(err.Error())
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	BundleCmd.AddCommand(BundleApply)
+	BundleCmd.AddCommand(BundleList)
+	BundleCmd.AddCommand(BundleStatus)
+	BundleCmd.AddCommand(BundleDelete)
+
+	BundleApply.Flags().StringVarP(&bundleFile, "file", "f", "", "bundle file, in YAML or JSON")
+}
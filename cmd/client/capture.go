@@ -25,6 +25,7 @@ package client
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/redhat-cip/skydive/api"
 	"github.com/redhat-cip/skydive/logging"
@@ -34,8 +35,9 @@ import (
 )
 
 var (
-	probePath string
-	bpfFilter string
+	probePath   string
+	bpfFilter   string
+	captureTags []string
 )
 
 var CaptureCmd = &cobra.Command{
@@ -60,6 +62,17 @@ var CaptureCreate = &cobra.Command{
 			cmd.Usage()
 			os.Exit(1)
 		}
+		for _, tag := range captureTags {
+			kv := strings.SplitN(tag, "=", 2)
+			if len(kv) != 2 {
+				fmt.Printf("Invalid tag %s, expected Tag.<name>=<value>\n", tag)
+				os.Exit(1)
+			}
+			if capture.Tags == nil {
+				capture.Tags = make(map[string]string)
+			}
+			capture.Tags[kv[0]] = kv[1]
+		}
 		if err := client.Create("capture", &capture); err != nil {
 			logging.GetLogger().Errorf(err.Error())
 			os.Exit(1)
@@ -132,6 +145,7 @@ var CaptureDelete = &cobra.Command{
 func addCaptureFlags(cmd *cobra.Command) {
 	cmd.Flags().StringVarP(&probePath, "probepath", "", "", "probe path")
 	cmd.Flags().StringVarP(&bpfFilter, "bpf", "", "", "BPF filter")
+	cmd.Flags().StringSliceVarP(&captureTags, "tag", "", []string{}, "tags of the flows, as Tag.<name>=<value>")
 }
 
 func init() {
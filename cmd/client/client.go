@@ -27,6 +27,7 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/redhat-cip/skydive/config"
 	shttp "github.com/redhat-cip/skydive/http"
 	"github.com/redhat-cip/skydive/logging"
 	"github.com/spf13/cobra"
@@ -61,8 +62,15 @@ func setFromFlag(cmd *cobra.Command, flag string, value *string) {
 func init() {
 	Client.PersistentFlags().StringVarP(&authenticationOpts.Username, "username", "", os.Getenv("SKYDIVE_USERNAME"), "username auth parameter")
 	Client.PersistentFlags().StringVarP(&authenticationOpts.Password, "password", "", os.Getenv("SKYDIVE_PASSWORD"), "password auth parameter")
+	Client.PersistentFlags().StringVarP(&authenticationOpts.Token, "token", "", os.Getenv("SKYDIVE_TOKEN"), "bearer token auth parameter, given instead of the username and password")
+	Client.PersistentFlags().Int("timeout", 0, "time in second waited for the replies, the analyzer aborting the requests once passed")
+	config.GetConfig().BindPFlag("client_timeout", Client.PersistentFlags().Lookup("timeout"))
 
+	Client.AddCommand(AdminCmd)
 	Client.AddCommand(AlertCmd)
+	Client.AddCommand(BundleCmd)
 	Client.AddCommand(CaptureCmd)
+	Client.AddCommand(FlowCmd)
+	Client.AddCommand(ReportCmd)
 	Client.AddCommand(TopologyCmd)
 }
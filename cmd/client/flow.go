@@ -0,0 +1,316 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package client
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/redhat-cip/skydive/api"
+	"github.com/redhat-cip/skydive/flow"
+	shttp "github.com/redhat-cip/skydive/http"
+	"github.com/redhat-cip/skydive/logging"
+)
+
+var (
+	flowFilters  []string
+	flowLimit    int
+	flowOffset   int
+	flowSort     string
+	flowOutput   string
+	flowSrcIP    string
+	flowDstIP    string
+	flowProtocol string
+)
+
+// protocolEndpoints are the port endpoints of the transport protocols, the
+// flows of a protocol being the ones having such an endpoint
+var protocolEndpoints = map[string]flow.FlowEndpointType{
+	"tcp":  flow.FlowEndpointType_TCPPORT,
+	"udp":  flow.FlowEndpointType_UDPPORT,
+	"sctp": flow.FlowEndpointType_SCTPPORT,
+}
+
+// csvFlowColumns are the columns of the flows written in CSV, followed by
+// the endpoints of the types found in the flows
+var csvFlowColumns = []string{"UUID", "LayersPath", "TrackingID", "ProbeNodeUUID", "Statistics.Start", "Statistics.Last"}
+
+type sortEndpointTypes []flow.FlowEndpointType
+
+func (s sortEndpointTypes) Len() int {
+	return len(s)
+}
+
+func (s sortEndpointTypes) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s sortEndpointTypes) Less(i, j int) bool {
+	return s[i] < s[j]
+}
+
+// writeFlowsCSV writes the flows in CSV with a header row, the statistics
+// of the endpoints being flattened into Statistics.Endpoints.<type>.<AB|BA>
+// columns
+func writeFlowsCSV(out io.Writer, flows []*flow.Flow) error {
+	found := make(map[flow.FlowEndpointType]bool)
+	for _, f := range flows {
+		for _, e := range f.GetStatistics().GetEndpoints() {
+			found[e.Type] = true
+		}
+	}
+	var types []flow.FlowEndpointType
+	for t := range found {
+		types = append(types, t)
+	}
+	sort.Sort(sortEndpointTypes(types))
+
+	header := append([]string{}, csvFlowColumns...)
+	for _, t := range types {
+		for _, side := range []string{"AB", "BA"} {
+			prefix := "Statistics.Endpoints." + t.String() + "." + side + "."
+			header = append(header, prefix+"Value", prefix+"Packets", prefix+"Bytes")
+		}
+	}
+
+	w := csv.NewWriter(out)
+	if err := w.Write(header); err != nil {
+		return err
+	}
+
+	for _, f := range flows {
+		fs := f.GetStatistics()
+		if fs == nil {
+			fs = &flow.FlowStatistics{}
+		}
+		row := []string{
+			f.UUID, f.LayersPath, f.TrackingID, f.ProbeNodeUUID,
+			strconv.FormatInt(fs.Start, 10), strconv.FormatInt(fs.Last, 10),
+		}
+		for _, t := range types {
+			e := fs.GetEndpointsType(t)
+			for _, side := range []*flow.FlowEndpointStatistics{e.GetAB(), e.GetBA()} {
+				if side == nil {
+					row = append(row, "", "", "")
+					continue
+				}
+				row = append(row, side.Value, strconv.FormatUint(side.Packets, 10), strconv.FormatUint(side.Bytes, 10))
+			}
+		}
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+
+	w.Flush()
+	return w.Error()
+}
+
+// flowEndpoint returns the address of a side of the flow, along with its port
+// if any
+func flowEndpoint(fs *flow.FlowStatistics, side func(*flow.FlowEndpointsStatistics) *flow.FlowEndpointStatistics) string {
+	value := func(t flow.FlowEndpointType) string {
+		if e := side(fs.GetEndpointsType(t)); e != nil {
+			return e.Value
+		}
+		return ""
+	}
+
+	addr := value(flow.FlowEndpointType_IPV4)
+	if addr == "" {
+		return value(flow.FlowEndpointType_ETHERNET)
+	}
+
+	for _, t := range []flow.FlowEndpointType{flow.FlowEndpointType_TCPPORT, flow.FlowEndpointType_UDPPORT, flow.FlowEndpointType_SCTPPORT} {
+		if port := value(t); port != "" {
+			return addr + ":" + port
+		}
+	}
+	return addr
+}
+
+// writeFlowsTable writes a line per flow with its endpoints and the
+// counters of its ethernet layer, in aligned columns
+func writeFlowsTable(out io.Writer, flows []*flow.Flow) error {
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "UUID\tLAYERS\tA\tB\tPACKETS\tBYTES\tSTART\tLAST")
+
+	for _, f := range flows {
+		fs := f.GetStatistics()
+		if fs == nil {
+			fs = &flow.FlowStatistics{}
+		}
+
+		var packets, bytes uint64
+		if e := fs.GetEndpointsType(flow.FlowEndpointType_ETHERNET); e != nil {
+			for _, side := range []*flow.FlowEndpointStatistics{e.GetAB(), e.GetBA()} {
+				if side != nil {
+					packets, bytes = packets+side.Packets, bytes+side.Bytes
+				}
+			}
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%d\t%s\t%s\n", f.UUID, f.LayersPath,
+			flowEndpoint(fs, (*flow.FlowEndpointsStatistics).GetAB), flowEndpoint(fs, (*flow.FlowEndpointsStatistics).GetBA),
+			packets, bytes,
+			time.Unix(fs.Start, 0).Format(time.RFC3339), time.Unix(fs.Last, 0).Format(time.RFC3339))
+	}
+
+	return w.Flush()
+}
+
+var FlowCmd = &cobra.Command{
+	Use:          "flow",
+	Short:        "Search flows",
+	Long:         "Search flows",
+	SilenceUsage: false,
+}
+
+var FlowSearch = &cobra.Command{
+	Use:     "search",
+	Aliases: []string{"query"},
+	Short:   "Search a page of the stored flows",
+	Long:    "Search a page of the stored flows, the next pages being returned with --offset. The filter keys are listed by topology keys --flows, an unknown key being rejected with the valid ones.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if flowOutput != "json" && flowOutput != "csv" && flowOutput != "table" {
+			fmt.Printf("Unknown output %s, expected json, csv or table\n", flowOutput)
+			cmd.Usage()
+			os.Exit(1)
+		}
+		if flowLimit <= 0 || flowOffset < 0 {
+			fmt.Println("The limit must be positive and the offset not negative")
+			cmd.Usage()
+			os.Exit(1)
+		}
+
+		query := url.Values{}
+		for _, filter := range flowFilters {
+			kv := strings.SplitN(filter, "=", 2)
+			if len(kv) != 2 {
+				fmt.Printf("Invalid filter %s, expected <key>=<value>\n", filter)
+				os.Exit(1)
+			}
+			query.Add(kv[0], kv[1])
+		}
+		for key, ip := range map[string]string{"IPV4.A": flowSrcIP, "IPV4.B": flowDstIP} {
+			if ip == "" {
+				continue
+			}
+			if parsed := net.ParseIP(ip); parsed == nil || parsed.To4() == nil {
+				fmt.Printf("Invalid IPv4 address %s\n", ip)
+				os.Exit(1)
+			}
+			query.Add(key, ip)
+		}
+		if flowProtocol != "" {
+			t, ok := protocolEndpoints[strings.ToLower(flowProtocol)]
+			if !ok {
+				fmt.Printf("Unknown protocol %s, expected tcp, udp or sctp\n", flowProtocol)
+				os.Exit(1)
+			}
+			query.Add(t.String()+".A__exists", "true")
+		}
+		query.Set("limit", strconv.Itoa(flowLimit))
+		query.Set("offset", strconv.Itoa(flowOffset))
+		if flowSort != "" {
+			query.Set("sort", flowSort)
+		}
+
+		client := shttp.NewRestClientFromConfig(&authenticationOpts)
+		if client == nil {
+			os.Exit(1)
+		}
+
+		resp, err := client.Request("GET", "api/flow/search?"+query.Encode(), nil)
+		if err != nil {
+			logging.GetLogger().Errorf(err.Error())
+			os.Exit(1)
+		}
+
+		if resp.StatusCode != 200 {
+			data, _ := ioutil.ReadAll(resp.Body)
+			logging.GetLogger().Errorf("Search failed: %s: %s%s", resp.Status, shttp.ErrorMessage(data), shttp.RequestIDDetails(resp))
+			os.Exit(1)
+		}
+
+		if flowOutput == "json" {
+			var page api.FlowPage
+			if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+				logging.GetLogger().Errorf("Unable to decode flows: %s", err.Error())
+				os.Exit(1)
+			}
+			printJSON(&page)
+			return
+		}
+
+		// the total and the next offset of the page are given on the error
+		// output, the standard one being the CSV or the table only
+		var page struct {
+			Flows      []*flow.Flow `json:"flows"`
+			Total      int          `json:"total"`
+			NextOffset int          `json:"next_offset"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+			logging.GetLogger().Errorf("Unable to decode flows: %s", err.Error())
+			os.Exit(1)
+		}
+		write := writeFlowsCSV
+		if flowOutput == "table" {
+			write = writeFlowsTable
+		}
+		if err := write(os.Stdout, page.Flows); err != nil {
+			logging.GetLogger().Errorf("Unable to write flows: %s", err.Error())
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "%d of %d flows, next offset: %d\n", len(page.Flows), page.Total, page.NextOffset)
+	},
+}
+
+func init() {
+	FlowCmd.AddCommand(FlowSearch)
+
+	FlowSearch.Flags().StringSliceVarP(&flowFilters, "filter", "", []string{}, "flow filter, ex: LayersPath=Ethernet/IPv4/TCP")
+	FlowSearch.Flags().IntVarP(&flowLimit, "limit", "", 1000, "maximum number of flows returned")
+	FlowSearch.Flags().IntVarP(&flowOffset, "offset", "", 0, "number of flows skipped, the next_offset of the previous page")
+	FlowSearch.Flags().StringVarP(&flowOutput, "output", "o", "json", "output format: json, csv or table")
+	// --format is kept as an alias of --output
+	FlowSearch.Flags().StringVarP(&flowOutput, "format", "", "json", "output format: json, csv or table")
+	FlowSearch.Flags().MarkHidden("format")
+	FlowSearch.Flags().StringVarP(&flowSort, "sort", "", "", "order of the flows, ex: Duration:desc, the most recent first by default")
+	FlowSearch.Flags().StringVarP(&flowSrcIP, "src-ip", "", "", "IPv4 address of the A endpoint, the one sending the first packet, same as --filter IPV4.A=<ip>")
+	FlowSearch.Flags().StringVarP(&flowDstIP, "dst-ip", "", "", "IPv4 address of the B endpoint, same as --filter IPV4.B=<ip>")
+	FlowSearch.Flags().StringVarP(&flowProtocol, "protocol", "", "", "transport protocol of the flows, tcp, udp or sctp, same as --filter TCPPORT.A__exists=true for tcp")
+}
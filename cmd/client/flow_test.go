@@ -0,0 +1,129 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package client
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/redhat-cip/skydive/flow"
+)
+
+func newOutputTestFlows() []*flow.Flow {
+	return []*flow.Flow{
+		{
+			UUID:          "flow1",
+			LayersPath:    "Ethernet/IPv4/TCP",
+			TrackingID:    "tracking1",
+			ProbeNodeUUID: "probe1",
+			Statistics: &flow.FlowStatistics{
+				Start: 1451606400,
+				Last:  1451606460,
+				Endpoints: []*flow.FlowEndpointsStatistics{
+					{
+						Type: flow.FlowEndpointType_ETHERNET,
+						AB:   &flow.FlowEndpointStatistics{Value: "00:00:00:00:00:01", Packets: 2, Bytes: 120},
+						BA:   &flow.FlowEndpointStatistics{Value: "00:00:00:00:00:02", Packets: 1, Bytes: 60},
+					},
+					{
+						Type: flow.FlowEndpointType_IPV4,
+						AB:   &flow.FlowEndpointStatistics{Value: "10.0.0.1", Packets: 2, Bytes: 100},
+						BA:   &flow.FlowEndpointStatistics{Value: "10.0.0.2", Packets: 1, Bytes: 40},
+					},
+					{
+						Type: flow.FlowEndpointType_TCPPORT,
+						AB:   &flow.FlowEndpointStatistics{Value: "47838", Packets: 2, Bytes: 80},
+						BA:   &flow.FlowEndpointStatistics{Value: "80", Packets: 1, Bytes: 20},
+					},
+				},
+			},
+		},
+		// a flow seen in a single direction, without ethernet layer
+		{
+			UUID:       "flow2",
+			LayersPath: "IPv4/UDP",
+			Statistics: &flow.FlowStatistics{
+				Start: 1451606410,
+				Last:  1451606470,
+				Endpoints: []*flow.FlowEndpointsStatistics{
+					{
+						Type: flow.FlowEndpointType_IPV4,
+						AB:   &flow.FlowEndpointStatistics{Value: "10.0.0.3", Packets: 3, Bytes: 300},
+					},
+					{
+						Type: flow.FlowEndpointType_UDPPORT,
+						AB:   &flow.FlowEndpointStatistics{Value: "53", Packets: 3, Bytes: 200},
+					},
+				},
+			},
+		},
+		// a flow without statistics
+		{UUID: "flow3"},
+	}
+}
+
+func TestWriteFlowsCSV(t *testing.T) {
+	var out bytes.Buffer
+	if err := writeFlowsCSV(&out, newOutputTestFlows()); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expected := `UUID,LayersPath,TrackingID,ProbeNodeUUID,Statistics.Start,Statistics.Last,` +
+		`Statistics.Endpoints.ETHERNET.AB.Value,Statistics.Endpoints.ETHERNET.AB.Packets,Statistics.Endpoints.ETHERNET.AB.Bytes,` +
+		`Statistics.Endpoints.ETHERNET.BA.Value,Statistics.Endpoints.ETHERNET.BA.Packets,Statistics.Endpoints.ETHERNET.BA.Bytes,` +
+		`Statistics.Endpoints.IPV4.AB.Value,Statistics.Endpoints.IPV4.AB.Packets,Statistics.Endpoints.IPV4.AB.Bytes,` +
+		`Statistics.Endpoints.IPV4.BA.Value,Statistics.Endpoints.IPV4.BA.Packets,Statistics.Endpoints.IPV4.BA.Bytes,` +
+		`Statistics.Endpoints.TCPPORT.AB.Value,Statistics.Endpoints.TCPPORT.AB.Packets,Statistics.Endpoints.TCPPORT.AB.Bytes,` +
+		`Statistics.Endpoints.TCPPORT.BA.Value,Statistics.Endpoints.TCPPORT.BA.Packets,Statistics.Endpoints.TCPPORT.BA.Bytes,` +
+		`Statistics.Endpoints.UDPPORT.AB.Value,Statistics.Endpoints.UDPPORT.AB.Packets,Statistics.Endpoints.UDPPORT.AB.Bytes,` +
+		`Statistics.Endpoints.UDPPORT.BA.Value,Statistics.Endpoints.UDPPORT.BA.Packets,Statistics.Endpoints.UDPPORT.BA.Bytes
+flow1,Ethernet/IPv4/TCP,tracking1,probe1,1451606400,1451606460,00:00:00:00:00:01,2,120,00:00:00:00:00:02,1,60,10.0.0.1,2,100,10.0.0.2,1,40,47838,2,80,80,1,20,,,,,,
+flow2,IPv4/UDP,,,1451606410,1451606470,,,,,,,10.0.0.3,3,300,,,,,,,,,,53,3,200,,,
+flow3,,,,0,0,,,,,,,,,,,,,,,,,,,,,,,,
+`
+	if out.String() != expected {
+		t.Errorf("Expected the CSV output:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
+
+func TestWriteFlowsTable(t *testing.T) {
+	// the times being written in the local time zone
+	local := time.Local
+	time.Local = time.UTC
+	defer func() { time.Local = local }()
+
+	var out bytes.Buffer
+	if err := writeFlowsTable(&out, newOutputTestFlows()); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expected := `UUID   LAYERS             A               B            PACKETS  BYTES  START                 LAST
+flow1  Ethernet/IPv4/TCP  10.0.0.1:47838  10.0.0.2:80  3        180    2016-01-01T00:00:00Z  2016-01-01T00:01:00Z
+flow2  IPv4/UDP           10.0.0.3:53                  0        0      2016-01-01T00:00:10Z  2016-01-01T00:01:10Z
+flow3                                                  0        0      1970-01-01T00:00:00Z  1970-01-01T00:00:00Z
+`
+	if out.String() != expected {
+		t.Errorf("Expected the table output:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
@@ -0,0 +1,214 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package client
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/redhat-cip/skydive/api"
+	shttp "github.com/redhat-cip/skydive/http"
+	"github.com/redhat-cip/skydive/logging"
+	"github.com/redhat-cip/skydive/validator"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	reportName        string
+	reportDescription string
+	reportPeriod      int
+	reportOffset      int
+	reportRange       int
+	reportSections    []string
+	reportTop         int
+	reportFormat      string
+	reportDelivery    string
+	reportURL         string
+	reportNow         bool
+)
+
+var ReportCmd = &cobra.Command{
+	Use:          "report",
+	Short:        "Manage reports",
+	Long:         "Manage reports",
+	SilenceUsage: false,
+}
+
+var ReportCreate = &cobra.Command{
+	Use:   "create",
+	Short: "Create report",
+	Long:  "Create report",
+	Run: func(cmd *cobra.Command, args []string) {
+		client := api.NewCrudClientFromConfig(&authenticationOpts)
+		if client == nil {
+			os.Exit(1)
+		}
+		report := api.NewReport()
+		setFromFlag(cmd, "name", &report.Name)
+		setFromFlag(cmd, "description", &report.Description)
+		setFromFlag(cmd, "format", &report.Format)
+		setFromFlag(cmd, "delivery", &report.Delivery)
+		setFromFlag(cmd, "url", &report.URL)
+		report.Period = reportPeriod
+		report.Offset = reportOffset
+		report.Range = reportRange
+		report.Top = reportTop
+		if cmd.Flags().Lookup("sections").Changed {
+			report.Sections = reportSections
+		}
+		if errs := validator.Validate(report); errs != nil {
+			fmt.Println("Error: ", errs)
+			cmd.Usage()
+			os.Exit(1)
+		}
+		if err := report.Validate(); err != nil {
+			fmt.Println("Error: ", err)
+			cmd.Usage()
+			os.Exit(1)
+		}
+		if err := client.Create("report", &report); err != nil {
+			logging.GetLogger().Errorf(err.Error())
+			os.Exit(1)
+		}
+		printJSON(&report)
+	},
+}
+
+var ReportList = &cobra.Command{
+	Use:   "list",
+	Short: "List reports",
+	Long:  "List reports",
+	Run: func(cmd *cobra.Command, args []string) {
+		var reports map[string]api.Report
+		client := api.NewCrudClientFromConfig(&authenticationOpts)
+		if client == nil {
+			os.Exit(1)
+		}
+		if err := client.List("report", &reports); err != nil {
+			logging.GetLogger().Errorf(err.Error())
+			os.Exit(1)
+		}
+		printJSON(reports)
+	},
+}
+
+var ReportGet = &cobra.Command{
+	Use:   "get [report]",
+	Short: "Display report",
+	Long:  "Display report",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			cmd.Usage()
+			os.Exit(1)
+		}
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		var report api.Report
+		client := api.NewCrudClientFromConfig(&authenticationOpts)
+		if err := client.Get("report", args[0], &report); err != nil {
+			logging.GetLogger().Errorf(err.Error())
+			os.Exit(1)
+		}
+		printJSON(&report)
+	},
+}
+
+var ReportDelete = &cobra.Command{
+	Use:   "delete [report]",
+	Short: "Delete report",
+	Long:  "Delete report",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			cmd.Usage()
+			os.Exit(1)
+		}
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		client := api.NewCrudClientFromConfig(&authenticationOpts)
+		if client == nil {
+			os.Exit(1)
+		}
+		if err := client.Delete("report", args[0]); err != nil {
+			logging.GetLogger().Errorf(err.Error())
+			os.Exit(1)
+		}
+	},
+}
+
+var ReportRun = &cobra.Command{
+	Use:   "run --now [report]",
+	Short: "Run report",
+	Long:  "Render and deliver a report covering its range up to now, its schedule being unchanged",
+	PreRun: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 || !reportNow {
+			cmd.Usage()
+			os.Exit(1)
+		}
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		client := api.NewRestClientFromConfig(&authenticationOpts)
+		if client == nil {
+			os.Exit(1)
+		}
+
+		resp, err := client.Request("POST", "api/report/"+args[0]+"/run", nil)
+		if err != nil {
+			logging.GetLogger().Errorf(err.Error())
+			os.Exit(1)
+		}
+		defer resp.Body.Close()
+
+		data, _ := ioutil.ReadAll(resp.Body)
+		if resp.StatusCode != 200 {
+			logging.GetLogger().Errorf("Report failed: %s: %s%s", resp.Status, shttp.ErrorMessage(data), shttp.RequestIDDetails(resp))
+			os.Exit(1)
+		}
+		fmt.Print(string(data))
+	},
+}
+
+func addReportFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&reportName, "name", "", "", "report name")
+	cmd.Flags().StringVarP(&reportDescription, "description", "", "", "report description")
+	cmd.Flags().IntVarP(&reportPeriod, "period", "", 86400, "report period in second")
+	cmd.Flags().IntVarP(&reportOffset, "offset", "", 0, "offset in second of the schedule, ex: 3600 for a daily report at 1 AM UTC")
+	cmd.Flags().IntVarP(&reportRange, "range", "", 0, "range in second covered by the report, the period by default")
+	cmd.Flags().StringSliceVarP(&reportSections, "sections", "", nil, "report sections: summary, top_talkers, new_hosts, alerts, applications")
+	cmd.Flags().IntVarP(&reportTop, "top", "", 0, "number of entries of the top sections, 10 by default")
+	cmd.Flags().StringVarP(&reportFormat, "format", "", "json", "report format: json or html")
+	cmd.Flags().StringVarP(&reportDelivery, "delivery", "", "spool", "report delivery: spool or webhook")
+	cmd.Flags().StringVarP(&reportURL, "url", "", "", "webhook URL")
+}
+
+func init() {
+	ReportCmd.AddCommand(ReportList)
+	ReportCmd.AddCommand(ReportGet)
+	ReportCmd.AddCommand(ReportCreate)
+	ReportCmd.AddCommand(ReportDelete)
+	ReportCmd.AddCommand(ReportRun)
+
+	addReportFlags(ReportCreate)
+	ReportRun.Flags().BoolVarP(&reportNow, "now", "", false, "run the report immediately")
+}
@@ -26,18 +26,28 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"math"
 	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
 
 	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
 
 	"github.com/redhat-cip/skydive/api"
+	"github.com/redhat-cip/skydive/common"
 	shttp "github.com/redhat-cip/skydive/http"
 	"github.com/redhat-cip/skydive/logging"
 )
 
 var (
 	gremlinQuery string
+	estimateOnly bool
+	flowKeys     bool
+	queryOutput  string
 )
 
 var TopologyCmd = &cobra.Command{
@@ -47,6 +57,143 @@ var TopologyCmd = &cobra.Command{
 	SilenceUsage: false,
 }
 
+// topologyFormatters write the results of the gremlin queries, by value of
+// the --output flag
+var topologyFormatters = map[string]func(out io.Writer, values interface{}) error{
+	"json": func(out io.Writer, values interface{}) error {
+		return writeJSON(out, values, true)
+	},
+	"json-compact": func(out io.Writer, values interface{}) error {
+		return writeJSON(out, values, false)
+	},
+	"yaml":  writeYAML,
+	"table": writeNodesTable,
+}
+
+func writeJSON(out io.Writer, values interface{}, indent bool) error {
+	var data []byte
+	var err error
+	if indent {
+		data, err = json.MarshalIndent(values, "", "  ")
+	} else {
+		data, err = json.Marshal(values)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(out, string(data))
+	return err
+}
+
+// integralNumbers converts the numbers decoded from JSON, all float64, to
+// int64 when integral so that yaml doesn't write them in exponent notation
+func integralNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return int64(v)
+		}
+	case map[string]interface{}:
+		for key, child := range v {
+			v[key] = integralNumbers(child)
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = integralNumbers(child)
+		}
+	}
+	return value
+}
+
+func writeYAML(out io.Writer, values interface{}) error {
+	// round-trip the structs through JSON so that the yaml keys are the
+	// JSON ones
+	data, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+
+	if data, err = yaml.Marshal(integralNumbers(decoded)); err != nil {
+		return err
+	}
+	_, err = out.Write(data)
+	return err
+}
+
+// flattenMetadata adds the metadata to the columns, the keys of the nested
+// maps being joined with a dot as in the gremlin filters
+func flattenMetadata(prefix string, metadata map[string]interface{}, columns map[string]string) {
+	for key, value := range metadata {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			flattenMetadata(prefix+key+".", v, columns)
+		case string:
+			columns[prefix+key] = v
+		case nil:
+		default:
+			data, _ := json.Marshal(integralNumbers(v))
+			columns[prefix+key] = string(data)
+		}
+	}
+}
+
+// writeNodesTable writes a line per node with its ID, its host and a column
+// per metadata key of all the nodes, the result of the query having to be
+// a list of nodes
+func writeNodesTable(out io.Writer, values interface{}) error {
+	list, ok := values.([]interface{})
+	if !ok {
+		return fmt.Errorf("The table output needs a list of nodes")
+	}
+
+	type row struct {
+		id, host string
+		columns  map[string]string
+	}
+
+	rows := make([]row, 0, len(list))
+	keys := make(map[string]bool)
+	for _, value := range list {
+		node, ok := value.(map[string]interface{})
+		if !ok || node["ID"] == nil {
+			return fmt.Errorf("The table output needs a list of nodes")
+		}
+
+		r := row{id: fmt.Sprintf("%v", node["ID"]), columns: make(map[string]string)}
+		if host, ok := node["Host"].(string); ok {
+			r.host = host
+		}
+		if metadata, ok := node["Metadata"].(map[string]interface{}); ok {
+			flattenMetadata("", metadata, r.columns)
+		}
+		for key := range r.columns {
+			keys[key] = true
+		}
+		rows = append(rows, r)
+	}
+
+	header := make([]string, 0, len(keys))
+	for key := range keys {
+		header = append(header, key)
+	}
+	sort.Strings(header)
+
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(append([]string{"ID", "HOST"}, header...), "\t"))
+	for _, r := range rows {
+		line := []string{r.id, r.host}
+		for _, key := range header {
+			line = append(line, r.columns[key])
+		}
+		fmt.Fprintln(w, strings.Join(line, "\t"))
+	}
+	return w.Flush()
+}
+
 func SendGremlinQuery(auth *shttp.AuthenticationOpts, query string) (interface{}, error) {
 	client := shttp.NewRestClientFromConfig(auth)
 
@@ -65,7 +212,7 @@ func SendGremlinQuery(auth *shttp.AuthenticationOpts, query string) (interface{}
 
 	if resp.StatusCode != 200 {
 		data, _ := ioutil.ReadAll(resp.Body)
-		return nil, fmt.Errorf("%s: %s", resp.Status, string(data))
+		return nil, fmt.Errorf("%s: %s%s", resp.Status, shttp.ErrorMessage(data), shttp.RequestIDDetails(resp))
 	}
 
 	var values interface{}
@@ -77,26 +224,108 @@ func SendGremlinQuery(auth *shttp.AuthenticationOpts, query string) (interface{}
 	return values, nil
 }
 
+func EstimateGremlinQuery(auth *shttp.AuthenticationOpts, query string) (*api.QueryEstimate, error) {
+	client := shttp.NewRestClientFromConfig(auth)
+
+	s, err := json.Marshal(&api.QueryEstimateRequest{GremlinQuery: query})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Request("POST", "api/query/estimate", bytes.NewReader(s))
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != 200 {
+		data, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("%s: %s%s", resp.Status, shttp.ErrorMessage(data), shttp.RequestIDDetails(resp))
+	}
+
+	var estimate api.QueryEstimate
+	if err := json.NewDecoder(resp.Body).Decode(&estimate); err != nil {
+		return nil, fmt.Errorf("Unable to decode response: %s", err.Error())
+	}
+
+	return &estimate, nil
+}
+
 var TopologyRequest = &cobra.Command{
 	Use:   "query",
 	Short: "query topology",
 	Long:  "query topology",
 	Run: func(cmd *cobra.Command, args []string) {
-		values, err := SendGremlinQuery(&authenticationOpts, gremlinQuery)
+		write, ok := topologyFormatters[queryOutput]
+		if !ok {
+			fmt.Printf("Unknown output %s, expected json, json-compact, yaml or table\n", queryOutput)
+			cmd.Usage()
+			os.Exit(1)
+		}
+
+		var values interface{}
+		var err error
+		if estimateOnly {
+			values, err = EstimateGremlinQuery(&authenticationOpts, gremlinQuery)
+		} else {
+			values, err = SendGremlinQuery(&authenticationOpts, gremlinQuery)
+		}
+		if err != nil {
+			logging.GetLogger().Errorf(err.Error())
+			os.Exit(1)
+		}
+
+		if err := write(os.Stdout, values); err != nil {
+			logging.GetLogger().Errorf("Unable to write the result: %s", err.Error())
+			os.Exit(1)
+		}
+	},
+}
+
+var TopologyKeys = &cobra.Command{
+	Use:   "keys [prefix]",
+	Short: "list the metadata keys of the nodes",
+	Long:  "list the metadata keys of the nodes, or of the flows with --flows, starting with the given prefix",
+	Run: func(cmd *cobra.Command, args []string) {
+		client := shttp.NewRestClientFromConfig(&authenticationOpts)
+		if client == nil {
+			os.Exit(1)
+		}
+
+		var keys []common.KeyInfo
+		var err error
+		if flowKeys {
+			keys, err = client.FlowKeys()
+		} else {
+			keys, err = client.TopologyKeys()
+		}
 		if err != nil {
 			logging.GetLogger().Errorf(err.Error())
 			os.Exit(1)
 		}
-		printJSON(values)
+
+		if len(args) > 0 {
+			var matching []common.KeyInfo
+			for _, info := range keys {
+				if strings.HasPrefix(info.Key, args[0]) {
+					matching = append(matching, info)
+				}
+			}
+			keys = matching
+		}
+		printJSON(keys)
 	},
 }
 
 func addTopologyFlags(cmd *cobra.Command) {
 	cmd.Flags().StringVarP(&gremlinQuery, "gremlin", "", "", "Gremlin Query")
+	cmd.Flags().BoolVarP(&estimateOnly, "estimate", "", false, "approximate result size, the query is not executed")
+	cmd.Flags().StringVarP(&queryOutput, "output", "o", "json", "output format: json, json-compact, yaml or table, the table one flattening the metadata of the nodes into columns")
 }
 
 func init() {
 	TopologyCmd.AddCommand(TopologyRequest)
+	TopologyCmd.AddCommand(TopologyKeys)
 
 	addTopologyFlags(TopologyRequest)
+	TopologyKeys.Flags().BoolVarP(&flowKeys, "flows", "", false, "list the fields and attributes of the flows")
 }
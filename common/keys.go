@@ -0,0 +1,266 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package common
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maximum length of the string values kept as examples
+const maxExampleLength = 128
+
+// KeyInfo describes a key of a catalog, the types of its values, the number
+// of times it was observed and a few of its values. The declared keys are
+// known to exist without having been observed yet.
+type KeyInfo struct {
+	Key      string
+	Types    []string
+	Count    uint64
+	Examples []interface{} `json:",omitempty"`
+	Declared bool          `json:",omitempty"`
+}
+
+// KeyCatalog keeps the keys observed in a stream of documents, nested maps
+// giving dotted keys. Only one document every sampling is observed, keeping
+// the cost of the writes low, the counts being then approximate. The
+// version changes when a key, a type or an example is added but not with
+// the counts, changing at each write.
+type KeyCatalog struct {
+	sync.RWMutex
+	keys        map[string]*KeyInfo
+	sampling    uint64
+	maxExamples int
+	maxKeys     int
+	writes      uint64
+	epoch       int64
+	version     uint64
+}
+
+// KeyType returns the JSON type of a value
+func KeyType(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case int, uint, int8, uint8, int16, uint16, int32, uint32, int64, uint64, float32, float64:
+		return "number"
+	}
+
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct, reflect.Ptr:
+		return "object"
+	case reflect.String:
+		return "string"
+	}
+	return "unknown"
+}
+
+// Sample returns whether the current write has to be observed
+func (c *KeyCatalog) Sample() bool {
+	return atomic.AddUint64(&c.writes, 1)%c.sampling == 0
+}
+
+func (c *KeyCatalog) info(key string) *KeyInfo {
+	info, ok := c.keys[key]
+	if !ok {
+		if c.maxKeys > 0 && len(c.keys) >= c.maxKeys {
+			return nil
+		}
+		info = &KeyInfo{Key: key}
+		c.keys[key] = info
+		c.version++
+	}
+	return info
+}
+
+func (c *KeyCatalog) addType(info *KeyInfo, t string) {
+	for _, known := range info.Types {
+		if known == t {
+			return
+		}
+	}
+	info.Types = append(info.Types, t)
+	sort.Strings(info.Types)
+	c.version++
+}
+
+func (c *KeyCatalog) addExample(info *KeyInfo, value interface{}) {
+	if len(info.Examples) >= c.maxExamples {
+		return
+	}
+
+	switch v := value.(type) {
+	case string:
+		if len(v) > maxExampleLength {
+			return
+		}
+	case bool:
+	default:
+		if KeyType(value) != "number" {
+			return
+		}
+	}
+
+	s := fmt.Sprintf("%v", value)
+	for _, example := range info.Examples {
+		if fmt.Sprintf("%v", example) == s {
+			return
+		}
+	}
+	info.Examples = append(info.Examples, value)
+	c.version++
+}
+
+func (c *KeyCatalog) observe(key string, value interface{}) {
+	if v := reflect.ValueOf(value); v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String && v.Len() > 0 {
+		for _, k := range v.MapKeys() {
+			c.observe(key+"."+k.String(), v.MapIndex(k).Interface())
+		}
+		return
+	}
+
+	info := c.info(key)
+	if info == nil {
+		return
+	}
+	info.Count++
+	c.addType(info, KeyType(value))
+	c.addExample(info, value)
+}
+
+// Observe records the keys of a document
+func (c *KeyCatalog) Observe(doc map[string]interface{}) {
+	c.Lock()
+	for k, v := range doc {
+		c.observe(k, v)
+	}
+	c.Unlock()
+}
+
+// ObserveValue records a key of a document
+func (c *KeyCatalog) ObserveValue(key string, value interface{}) {
+	c.Lock()
+	c.observe(key, value)
+	c.Unlock()
+}
+
+// Declare adds a key known to exist with values of the given type
+func (c *KeyCatalog) Declare(key string, t string) {
+	c.Lock()
+	defer c.Unlock()
+
+	if info := c.info(key); info != nil {
+		info.Declared = true
+		c.addType(info, t)
+	}
+}
+
+type sortByKey []KeyInfo
+
+func (s sortByKey) Len() int {
+	return len(s)
+}
+
+func (s sortByKey) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s sortByKey) Less(i, j int) bool {
+	return s[i].Key < s[j].Key
+}
+
+// Keys returns the keys sorted along with the version of the catalog
+func (c *KeyCatalog) Keys() ([]KeyInfo, string) {
+	c.RLock()
+	defer c.RUnlock()
+
+	keys := make([]KeyInfo, 0, len(c.keys))
+	for _, info := range c.keys {
+		k := *info
+		k.Types = append([]string{}, info.Types...)
+		k.Examples = append([]interface{}{}, info.Examples...)
+		keys = append(keys, k)
+	}
+	sort.Sort(sortByKey(keys))
+
+	return keys, fmt.Sprintf("%d-%d", c.epoch, c.version)
+}
+
+// CompleteKey returns the keys starting with prefix, sorted
+func CompleteKey(keys []KeyInfo, prefix string) []string {
+	var completions []string
+	for _, info := range keys {
+		if strings.HasPrefix(info.Key, prefix) {
+			completions = append(completions, info.Key)
+		}
+	}
+	sort.Strings(completions)
+	return completions
+}
+
+// UnknownKeys returns the names missing from the keys, a name being known
+// as well when it is the prefix of nested keys
+func UnknownKeys(keys []KeyInfo, names []string) []string {
+	var unknown []string
+	for _, name := range names {
+		known := false
+		for _, info := range keys {
+			if info.Key == name || strings.HasPrefix(info.Key, name+".") {
+				known = true
+				break
+			}
+		}
+		if !known {
+			unknown = append(unknown, name)
+		}
+	}
+	return unknown
+}
+
+// NewKeyCatalog returns a catalog observing one write every sampling,
+// keeping maxExamples values per key and up to maxKeys keys, none when
+// zero
+func NewKeyCatalog(sampling int, maxExamples int, maxKeys int) *KeyCatalog {
+	if sampling <= 0 {
+		sampling = 1
+	}
+
+	return &KeyCatalog{
+		keys:        make(map[string]*KeyInfo),
+		sampling:    uint64(sampling),
+		maxExamples: maxExamples,
+		maxKeys:     maxKeys,
+		epoch:       time.Now().UnixNano(),
+	}
+}
@@ -23,6 +23,8 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"net/url"
@@ -36,23 +38,185 @@ import (
 	_ "github.com/spf13/viper/remote"
 )
 
-var cfg *viper.Viper
+var (
+	cfg        *viper.Viper
+	configPath string
+)
 
 func init() {
 	cfg = viper.New()
 	cfg.SetDefault("agent.analyzers", "127.0.0.1:8082")
 	cfg.SetDefault("agent.listen", "127.0.0.1:8081")
+	cfg.SetDefault("agent.admin_listen", "127.0.0.1:8084")
+	cfg.SetDefault("agent.debug.pprof", false)
+	cfg.SetDefault("agent.flow.late_binding_delay", 0)
+	cfg.SetDefault("agent.flow.transport", "udp")
+	cfg.SetDefault("agent.flow.udp_batch_size", 0)
+	cfg.SetDefault("agent.synthetic_probes.enabled", true)
+	cfg.SetDefault("agent.synthetic_probes.rate_limit", 60)
+	cfg.SetDefault("agent.synthetic_probes.max_concurrent", 4)
 	cfg.SetDefault("ovs.ovsdb", "unix:///var/run/openvswitch/db.sock")
 	cfg.SetDefault("graph.backend", "memory")
 	cfg.SetDefault("graph.gremlin", "ws://127.0.0.1:8182")
+	cfg.SetDefault("graph.merge_rules", []string{})
+	cfg.SetDefault("graph.id_strategy", "stable")
+	cfg.SetDefault("graph.recreated_policy", "new")
 	cfg.SetDefault("sflow.port_min", 6345)
 	cfg.SetDefault("sflow.port_max", 6355)
 	cfg.SetDefault("analyzer.listen", "127.0.0.1:8082")
+	cfg.SetDefault("analyzer.admin_listen", "127.0.0.1:8083")
 	cfg.SetDefault("analyzer.flowtable_expire", 600)
 	cfg.SetDefault("analyzer.flowtable_update", 60)
+	cfg.SetDefault("analyzer.flowtable_expire_batch", 0)
+	cfg.SetDefault("analyzer.flowtable_skew_tolerance", 300)
 	cfg.SetDefault("analyzer.flowtable_agent_ratio", 0.5)
+	cfg.SetDefault("analyzer.conversation_symmetry", false)
+	cfg.SetDefault("analyzer.flow_search.unknown_filters", "strict")
+	cfg.SetDefault("analyzer.flow_search.default_limit", 1000)
+	cfg.SetDefault("analyzer.flow_aggregates.max_staleness", 0)
+	cfg.SetDefault("analyzer.flow_aggregates.precompute_interval", 0)
+	cfg.SetDefault("analyzer.flow_aggregates.max_entries", 256)
+	cfg.SetDefault("analyzer.flow_significance.enabled", false)
+	cfg.SetDefault("analyzer.flow_significance.packets", 0)
+	cfg.SetDefault("analyzer.flow_significance.bytes", 0)
+	cfg.SetDefault("analyzer.flow_significance.duration", 0)
+	cfg.SetDefault("analyzer.flow_significance.interval", 60)
+	cfg.SetDefault("analyzer.flow_rollups.interval", 0)
+	cfg.SetDefault("analyzer.flow_rollups.delay", 120)
+	cfg.SetDefault("analyzer.flow_rollups.retention", 1440)
+	cfg.SetDefault("analyzer.debug.flow_stream", false)
+	cfg.SetDefault("analyzer.debug.pprof", false)
+	cfg.SetDefault("analyzer.live_flows.enabled", true)
+	cfg.SetDefault("analyzer.live_flows.refresh", 20)
+	cfg.SetDefault("analyzer.flow_events.update_interval", 60)
+	cfg.SetDefault("analyzer.flow_events.update_ratio", 0)
+	cfg.SetDefault("analyzer.flow_events.storage_upsert", false)
+	cfg.SetDefault("analyzer.startup_timeout", 10)
+	cfg.SetDefault("analyzer.handover.socket", "")
+	cfg.SetDefault("analyzer.handover.timeout", 30)
+	cfg.SetDefault("analyzer.bootstrap.enabled", false)
+	cfg.SetDefault("analyzer.bootstrap.log_interval", 10)
+	cfg.SetDefault("analyzer.alert_absence_interval", 10)
+	cfg.SetDefault("analyzer.alert_sandbox.max_length", 1024)
+	cfg.SetDefault("analyzer.alert_sandbox.max_nodes", 200)
+	cfg.SetDefault("analyzer.alert_sandbox.max_depth", 32)
+	cfg.SetDefault("analyzer.alert_sandbox.functions", []string{"len", "int", "int64", "uint64", "float64", "string"})
+	cfg.SetDefault("analyzer.alert_sandbox.max_selected_nodes", 10000)
+	cfg.SetDefault("analyzer.alert_sandbox.max_memory", 1048576)
+	cfg.SetDefault("analyzer.alert_sandbox.timeout", 100)
+	cfg.SetDefault("analyzer.alert_context.max_fires", 100)
+	cfg.SetDefault("analyzer.alert_context.max_flows", 20)
+	cfg.SetDefault("analyzer.alert_context.window", 60)
+	cfg.SetDefault("analyzer.flap_detection.threshold", 5)
+	cfg.SetDefault("analyzer.flap_detection.clear_threshold", 1)
+	cfg.SetDefault("analyzer.flap_detection.window", 60)
+	cfg.SetDefault("analyzer.flap_detection.history", 10)
+	cfg.SetDefault("analyzer.query_max_results", 10000)
+	cfg.SetDefault("analyzer.flow_correlation.warning_ratio", 0.5)
+	cfg.SetDefault("analyzer.flow_correlation.warning_batches", 100)
+	cfg.SetDefault("analyzer.ingestion.fair_queuing", false)
+	cfg.SetDefault("analyzer.ingestion.workers", 0)
+	cfg.SetDefault("analyzer.ingestion.queue_size", 1024)
+	cfg.SetDefault("analyzer.ingestion.quantum", 1500)
+	cfg.SetDefault("analyzer.ingestion.credits.bytes", 4194304)
+	cfg.SetDefault("analyzer.ingestion.credits.packets", 4096)
+	cfg.SetDefault("analyzer.ingestion.starvation_delay", 100)
+	cfg.SetDefault("analyzer.ingestion.sequence.window", 1024)
+	cfg.SetDefault("analyzer.ingestion.sequence.checkpoint", "")
+	cfg.SetDefault("analyzer.ingestion.sequence.checkpoint_interval", 10)
+	cfg.SetDefault("analyzer.query_estimate.sample_size", 1000)
+	cfg.SetDefault("analyzer.query_estimate.timeout", 200)
+	cfg.SetDefault("analyzer.gremlin_write.admins", []string{})
+	cfg.SetDefault("analyzer.gremlin_write.origin", "user")
+	cfg.SetDefault("analyzer.gremlin_flows.window", 60)
+	cfg.SetDefault("analyzer.key_catalog.sampling", 10)
+	cfg.SetDefault("analyzer.key_catalog.max_examples", 5)
+	cfg.SetDefault("analyzer.key_catalog.max_keys", 1000)
+	cfg.SetDefault("analyzer.flow_max_packet_size", 4096)
+	cfg.SetDefault("analyzer.netflow.listen", "")
+	cfg.SetDefault("analyzer.sflow.listen", "")
+	cfg.SetDefault("analyzer.auth.username", "")
+	cfg.SetDefault("analyzer.auth.password", "")
+	cfg.SetDefault("analyzer.auth.token", "")
+	cfg.SetDefault("analyzer.listen_mode", "separate")
+	cfg.SetDefault("analyzer.mux.peek_timeout", 1000)
+	cfg.SetDefault("analyzer.flow_trace.rate_limit", 10)
+	cfg.SetDefault("analyzer.flow_explain.cache_size", 10000)
+	cfg.SetDefault("analyzer.flow_schema.strict", false)
+	cfg.SetDefault("analyzer.flow_schema.aliases", map[string]string{})
+	cfg.SetDefault("analyzer.flow_edges.enabled", false)
+	cfg.SetDefault("analyzer.flow_edges.metadata", []string{"bytes", "packets", "rate", "last_seen"})
+	cfg.SetDefault("analyzer.flow_edges.rate_window", 60)
+	cfg.SetDefault("analyzer.flow_edges.expire", 300)
+	cfg.SetDefault("analyzer.flow_backfill.enabled", false)
+	cfg.SetDefault("analyzer.flow_backfill.window", 3600)
+	cfg.SetDefault("analyzer.flow_backfill.events", []string{"NodeAdded", "NodeUpdated"})
+	cfg.SetDefault("analyzer.flow_backfill.max_flows", 10000)
+	cfg.SetDefault("analyzer.flow_backfill.rate_limit", 500)
+	cfg.SetDefault("analyzer.asn_database", "")
+	cfg.SetDefault("analyzer.ja3.enabled", true)
+	cfg.SetDefault("analyzer.ja3.keep_client_hello", false)
+	cfg.SetDefault("analyzer.support_bundle.max_section_size", 1048576)
+	cfg.SetDefault("analyzer.report.spool_dir", "/tmp/skydive-reports")
+	cfg.SetDefault("analyzer.report.grace", 3600)
+	cfg.SetDefault("analyzer.report.check_interval", 30)
+	cfg.SetDefault("analyzer.synthetic_probes.min_interval", 10)
+	cfg.SetDefault("analyzer.synthetic_probes.agent_rate_limit", 30)
+	cfg.SetDefault("analyzer.synthetic_probes.check_interval", 1)
+	cfg.SetDefault("flow_tcp.port", 8085)
+	cfg.SetDefault("flow_tcp.ack", "none")
+	cfg.SetDefault("flow_tcp.ack_frames", 10)
 	cfg.SetDefault("storage.elasticsearch", "127.0.0.1:9200")
+	cfg.SetDefault("storage.feed_delay", 10)
+	cfg.SetDefault("storage.max_bulk_size", 1000)
+	cfg.SetDefault("storage.routing.field", "")
+	cfg.SetDefault("storage.interning.enabled", false)
+	cfg.SetDefault("storage.interning.cache_expire", 600)
+	cfg.SetDefault("storage.search.partition_period", 86400)
+	cfg.SetDefault("storage.search.concurrency", 4)
+	cfg.SetDefault("storage.search.slow_threshold", 1000)
+	cfg.SetDefault("storage.wal.dir", "")
+	cfg.SetDefault("storage.wal.max_size", 256)
+	cfg.SetDefault("storage.wal.max_entries", 10000)
+	cfg.SetDefault("storage.wal.retry_interval", 10)
+	cfg.SetDefault("storage.kafka.brokers", []string{"127.0.0.1:9092"})
+	cfg.SetDefault("storage.kafka.topic", "skydive-flows")
+	cfg.SetDefault("storage.kafka.encoding", "json")
+	cfg.SetDefault("storage.kafka.required_acks", 1)
+	cfg.SetDefault("storage.kafka.buffer_size", 10000)
+	cfg.SetDefault("storage.kafka.batch_size", 100)
+	cfg.SetDefault("storage.kafka.flush_interval", 1000)
+	cfg.SetDefault("storage.kafka.retry_backoff", 1000)
+	cfg.SetDefault("storage.kafka.timeout", 10000)
+	cfg.SetDefault("storage.kafka.events", []string{})
+	cfg.SetDefault("storage.aliases", map[string]string{
+		"probe":       "ProbeNodeUUID",
+		"layers":      "LayersPath",
+		"tracking_id": "TrackingID",
+		"duration":    "Duration",
+		"start":       "Statistics.Start",
+		"last":        "Statistics.Last",
+		"asn_a":       "Attributes.ASN_A",
+		"asn_b":       "Attributes.ASN_B",
+		"ja3":         "Attributes.JA3",
+		"noise":       "Attributes.Noise",
+	})
 	cfg.SetDefault("ws_pong_timeout", 5)
+	cfg.SetDefault("ws_max_clients", 0)
+	cfg.SetDefault("ws_queue_size", 1000)
+	cfg.SetDefault("ws_slow_consumer_timeout", 10)
+	cfg.SetDefault("client_versions_window", 86400)
+	cfg.SetDefault("client_timeout", 0)
+	cfg.SetDefault("client_keys_ttl", 60)
+	cfg.SetDefault("log_sampling.analyzer_flows.every", 1)
+	cfg.SetDefault("log_sampling.analyzer_flows.rate", 10)
+	cfg.SetDefault("log_sampling.analyzer_datagrams.every", 1)
+	cfg.SetDefault("log_sampling.analyzer_datagrams.rate", 10)
+	cfg.SetDefault("log_sampling.analyzer_truncated_datagrams.every", 1)
+	cfg.SetDefault("log_sampling.analyzer_truncated_datagrams.rate", 1)
+	cfg.SetDefault("log_sampling.analyzer_dropped_datagrams.every", 1)
+	cfg.SetDefault("log_sampling.analyzer_dropped_datagrams.rate", 1)
+	cfg.SetDefault("log_journals.size", 100)
 	cfg.SetDefault("docker.url", "unix:///var/run/docker.sock")
 	cfg.SetDefault("netns.run_path", "/var/run/netns")
 	cfg.SetDefault("etcd.data_dir", "/tmp/skydive-etcd")
@@ -71,6 +235,28 @@ func checkStrictPositiveInt(key string) error {
 	return nil
 }
 
+// getDuration returns a duration given either in second or as a duration
+// string (30s, 10m)
+func getDuration(key string) (time.Duration, error) {
+	value := cfg.GetString(key)
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	return time.ParseDuration(value)
+}
+
+func checkStrictPositiveDuration(key string) error {
+	value, err := getDuration(key)
+	if err != nil {
+		return fmt.Errorf("invalid value for %s (%s): %s", key, cfg.GetString(key), err.Error())
+	}
+	if value <= 0 {
+		return fmt.Errorf("invalid value for %s (%s)", key, value)
+	}
+
+	return nil
+}
+
 func checkStrictRangeFloat(key string, min, max float64) error {
 	if value := cfg.GetFloat64(key); value <= min || value > max {
 		return fmt.Errorf("invalid value for %s (%f)", key, value)
@@ -86,7 +272,7 @@ func checkConfig() error {
 		}
 	}
 
-	if err := checkStrictPositiveInt("analyzer.flowtable_expire"); err != nil {
+	if err := checkStrictPositiveDuration("analyzer.flowtable_expire"); err != nil {
 		return err
 	}
 
@@ -140,14 +326,29 @@ func InitConfig(backend string, path string) error {
 	default:
 		return fmt.Errorf("Invalid backend: %s", backend)
 	}
+	configPath = path
 
 	return checkConfig()
 }
 
+// GetConfigPath returns the location of the configuration, empty when
+// running with the defaults
+func GetConfigPath() string {
+	return configPath
+}
+
 func GetConfig() *viper.Viper {
 	return cfg
 }
 
+// Fingerprint returns a hash of the running configuration, used to check
+// that two analyzers share the same configuration
+func Fingerprint() string {
+	hasher := sha256.New()
+	hasher.Write([]byte(fmt.Sprintf("%v", cfg.AllSettings())))
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
 func SetDefault(key string, value interface{}) {
 	cfg.SetDefault(key, value)
 }
@@ -193,8 +394,23 @@ func GetAnalyzerClientAddr() (string, int, error) {
 	return "", 0, nil
 }
 
+// GetAnalyerExpire returns analyzer.flowtable_expire, given in second or as
+// a duration string, checked when the configuration is loaded
 func GetAnalyerExpire() time.Duration {
-	return time.Duration(GetConfig().GetInt("analyzer.flowtable_expire")) * time.Second
+	expire, _ := getDuration("analyzer.flowtable_expire")
+	return expire
+}
+
+// GetAnalyzerFlowTransport returns analyzer.flow_transport, falling back on
+// analyzer.flow_listen_protocol, its former name, and on both by default
+func GetAnalyzerFlowTransport() string {
+	if transport := GetConfig().GetString("analyzer.flow_transport"); transport != "" {
+		return transport
+	}
+	if protocol := GetConfig().GetString("analyzer.flow_listen_protocol"); protocol != "" {
+		return protocol
+	}
+	return "both"
 }
 
 func GetAnalyerUpdate() time.Duration {
@@ -210,9 +426,7 @@ func GetAgentRatio() float64 {
 }
 
 func GetAgentExpire() time.Duration {
-	analyzerExpire := GetConfig().GetInt("analyzer.flowtable_expire")
-	agentExpire := int(float64(analyzerExpire) * GetAgentRatio())
-	return time.Duration(agentExpire) * time.Second
+	return time.Duration(float64(GetAnalyerExpire()) * GetAgentRatio())
 }
 
 func GetAgentUpdate() time.Duration {
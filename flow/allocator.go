@@ -26,7 +26,8 @@ import "sync"
 
 type TableAllocator struct {
 	sync.RWMutex
-	tables map[*Table]bool
+	tables   map[*Table]bool
+	decoders *DecoderChain
 }
 
 func (a *TableAllocator) Flush() {
@@ -81,11 +82,19 @@ func (a *TableAllocator) Alloc() *Table {
 	defer a.Unlock()
 
 	t := NewTable()
+	t.decoders = a.decoders
 	a.tables[t] = true
 
 	return t
 }
 
+// SetDecoderChain sets the packet decoders used by the tables allocated
+func (a *TableAllocator) SetDecoderChain(decoders *DecoderChain) {
+	a.Lock()
+	a.decoders = decoders
+	a.Unlock()
+}
+
 func (a *TableAllocator) Release(t *Table) {
 	a.Lock()
 	delete(a.tables, t)
@@ -0,0 +1,134 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package flow
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// A batch datagram starts with a zero byte, which can't start an encoded
+// flow, followed by the version of the format and the number of records,
+// each record being an encoded flow prefixed by its length. The datagrams
+// not starting with a zero byte carry a single encoded flow.
+const (
+	batchMarker       = 0x00
+	batchVersion      = 1
+	batchHeaderSize   = 4
+	batchRecordHeader = 2
+	maxBatchRecords   = 0xffff
+)
+
+var ErrBatchTruncated = errors.New("batch truncated")
+
+// EncodeBatches encodes the flows in batch datagrams of at most maxSize
+// bytes, a flow too large to fit in a batch being encoded alone
+func EncodeBatches(flows []*Flow, maxSize int) ([][]byte, error) {
+	var datagrams [][]byte
+	var batch []byte
+	count := 0
+
+	flush := func() {
+		if count > 0 {
+			binary.BigEndian.PutUint16(batch[2:], uint16(count))
+			datagrams = append(datagrams, batch)
+		}
+		batch, count = nil, 0
+	}
+
+	for _, f := range flows {
+		data, err := f.GetData()
+		if err != nil {
+			return nil, err
+		}
+
+		if batchHeaderSize+batchRecordHeader+len(data) > maxSize || len(data) > 0xffff {
+			// keeping the order of the flows
+			flush()
+			datagrams = append(datagrams, data)
+			continue
+		}
+
+		if count == maxBatchRecords || len(batch)+batchRecordHeader+len(data) > maxSize {
+			flush()
+		}
+		if batch == nil {
+			batch = make([]byte, batchHeaderSize, maxSize)
+			batch[0], batch[1] = batchMarker, batchVersion
+		}
+
+		var length [batchRecordHeader]byte
+		binary.BigEndian.PutUint16(length[:], uint16(len(data)))
+		batch = append(append(batch, length[:]...), data...)
+		count++
+	}
+	flush()
+
+	return datagrams, nil
+}
+
+// DecodeBatch decodes the flows of a datagram, either a batch or a single
+// flow. The malformed records of a batch are skipped, their errors being
+// returned along with the other flows.
+func DecodeBatch(data []byte) ([]*Flow, []error) {
+	if len(data) == 0 || data[0] != batchMarker {
+		f, err := FromData(data)
+		if err != nil {
+			return nil, []error{err}
+		}
+		return []*Flow{f}, nil
+	}
+
+	if len(data) < batchHeaderSize {
+		return nil, []error{ErrBatchTruncated}
+	}
+	if data[1] != batchVersion {
+		return nil, []error{fmt.Errorf("unsupported batch version %d", data[1])}
+	}
+
+	count := int(binary.BigEndian.Uint16(data[2:]))
+	flows := make([]*Flow, 0, count)
+	var errs []error
+
+	data = data[batchHeaderSize:]
+	for i := 0; i < count; i++ {
+		if len(data) < batchRecordHeader {
+			return flows, append(errs, fmt.Errorf("record %d: %s", i, ErrBatchTruncated.Error()))
+		}
+		length := int(binary.BigEndian.Uint16(data))
+		if len(data) < batchRecordHeader+length {
+			return flows, append(errs, fmt.Errorf("record %d: %s", i, ErrBatchTruncated.Error()))
+		}
+
+		f, err := FromData(data[batchRecordHeader : batchRecordHeader+length])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("record %d: %s", i, err.Error()))
+		} else {
+			flows = append(flows, f)
+		}
+		data = data[batchRecordHeader+length:]
+	}
+
+	return flows, errs
+}
@@ -0,0 +1,114 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package flow
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func batchFlows(n int) []*Flow {
+	flows := make([]*Flow, n)
+	for i := range flows {
+		flows[i] = &Flow{UUID: "flow" + strconv.Itoa(i), LayersPath: "Ethernet/IPv4/TCP"}
+	}
+	return flows
+}
+
+func TestBatchRoundTrip(t *testing.T) {
+	flows := batchFlows(100)
+	flows[50].Attributes = map[string]string{"Large": strings.Repeat("x", 2000)}
+
+	datagrams, err := EncodeBatches(flows, 1400)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(datagrams) < 3 {
+		t.Fatalf("Expected the flows to be split in several datagrams, got %d", len(datagrams))
+	}
+
+	var decoded []*Flow
+	for _, data := range datagrams {
+		// the flow too large for a batch is sent alone
+		if len(data) > 1400 && data[0] == batchMarker {
+			t.Errorf("Batch of %d bytes larger than the maximum", len(data))
+		}
+
+		f, errs := DecodeBatch(data)
+		if len(errs) != 0 {
+			t.Fatalf("Unexpected errors %v", errs)
+		}
+		decoded = append(decoded, f...)
+	}
+
+	if len(decoded) != len(flows) {
+		t.Fatalf("Expected %d flows, got %d", len(flows), len(decoded))
+	}
+	for i, f := range decoded {
+		if f.UUID != flows[i].UUID {
+			t.Errorf("Expected the flow %s at %d, got %s", flows[i].UUID, i, f.UUID)
+		}
+	}
+}
+
+func TestBatchLegacyDatagram(t *testing.T) {
+	data, _ := (&Flow{UUID: "legacy"}).GetData()
+
+	flows, errs := DecodeBatch(data)
+	if len(errs) != 0 || len(flows) != 1 || flows[0].UUID != "legacy" {
+		t.Errorf("Expected the single flow datagram to be decoded, got %v %v", flows, errs)
+	}
+
+	if flows, errs = DecodeBatch([]byte{0xff, 0xff}); len(flows) != 0 || len(errs) != 1 {
+		t.Errorf("Expected a malformed flow error, got %v %v", flows, errs)
+	}
+}
+
+func TestBatchMalformedRecords(t *testing.T) {
+	datagrams, _ := EncodeBatches(batchFlows(3), 1400)
+	if len(datagrams) != 1 {
+		t.Fatalf("Expected a single batch, got %d", len(datagrams))
+	}
+	data := datagrams[0]
+
+	// the first record is corrupted, the others being decoded
+	corrupted := append([]byte(nil), data...)
+	corrupted[batchHeaderSize+batchRecordHeader] = 0xff
+	flows, errs := DecodeBatch(corrupted)
+	if len(flows) != 2 || len(errs) != 1 || !strings.HasPrefix(errs[0].Error(), "record 0:") {
+		t.Errorf("Expected the first record to be skipped, got %v %v", flows, errs)
+	}
+
+	// the records before the truncation are decoded
+	flows, errs = DecodeBatch(data[:len(data)-5])
+	if len(flows) != 2 || len(errs) != 1 || errs[0].Error() != "record 2: batch truncated" {
+		t.Errorf("Expected the truncated record to be reported, got %v %v", flows, errs)
+	}
+
+	unsupported := append([]byte(nil), data...)
+	unsupported[1] = 2
+	if flows, errs = DecodeBatch(unsupported); len(flows) != 0 || len(errs) != 1 {
+		t.Errorf("Expected the version to be rejected, got %v %v", flows, errs)
+	}
+}
@@ -0,0 +1,216 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package flow
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+
+	"github.com/redhat-cip/skydive/config"
+	"github.com/redhat-cip/skydive/logging"
+)
+
+const (
+	// maximum number of encapsulation headers stripped for a single packet
+	maxDecoderDepth = 8
+)
+
+// PacketDecoder strips a vendor specific encapsulation header. The payload
+// given is the data following the ethernet header or the UDP header the
+// decoder has been registered for. It returns the inner ethernet frame and
+// the attributes to attach to the flow.
+type PacketDecoder interface {
+	Decode(payload []byte) ([]byte, map[string]string, error)
+}
+
+// PacketDecoderCtor creates a decoder from its configuration section
+type PacketDecoderCtor func(cfg map[string]interface{}) (PacketDecoder, error)
+
+// PacketDecoderMatch specifies which packets are handed to a decoder, either
+// by ethertype or by UDP port. Zero values are ignored.
+type PacketDecoderMatch struct {
+	EtherType layers.EthernetType
+	UDPPort   layers.UDPPort
+}
+
+type packetDecoderEntry struct {
+	name     string
+	match    PacketDecoderMatch
+	decoder  PacketDecoder
+	failures uint64
+}
+
+// DecoderChain applies the configured decoders in order until no decoder
+// matches the packet anymore.
+type DecoderChain struct {
+	entries []*packetDecoderEntry
+}
+
+var (
+	packetDecodersLock sync.RWMutex
+	packetDecoders     = make(map[string]PacketDecoderCtor)
+)
+
+// RegisterPacketDecoder makes a decoder type available to the configuration
+func RegisterPacketDecoder(t string, ctor PacketDecoderCtor) {
+	packetDecodersLock.Lock()
+	packetDecoders[t] = ctor
+	packetDecodersLock.Unlock()
+}
+
+func (c *DecoderChain) Add(name string, match PacketDecoderMatch, decoder PacketDecoder) {
+	c.entries = append(c.entries, &packetDecoderEntry{
+		name:    name,
+		match:   match,
+		decoder: decoder,
+	})
+}
+
+func (c *DecoderChain) lookup(packet gopacket.Packet) (*packetDecoderEntry, []byte) {
+	var ethernet *layers.Ethernet
+	if layer := packet.Layer(layers.LayerTypeEthernet); layer != nil {
+		ethernet = layer.(*layers.Ethernet)
+	}
+
+	var udp *layers.UDP
+	if layer := packet.Layer(layers.LayerTypeUDP); layer != nil {
+		udp = layer.(*layers.UDP)
+	}
+
+	for _, entry := range c.entries {
+		if entry.match.EtherType != 0 && ethernet != nil && ethernet.EthernetType == entry.match.EtherType {
+			return entry, ethernet.LayerPayload()
+		}
+		if entry.match.UDPPort != 0 && udp != nil && (udp.DstPort == entry.match.UDPPort || udp.SrcPort == entry.match.UDPPort) {
+			return entry, udp.LayerPayload()
+		}
+	}
+
+	return nil, nil
+}
+
+// Decode returns the innermost packet that could be decoded along with the
+// attributes returned by all the decoders applied. If a decoder fails, the
+// packet decoded so far is returned and its payload is treated as opaque.
+func (c *DecoderChain) Decode(packet gopacket.Packet) (gopacket.Packet, map[string]string) {
+	var attributes map[string]string
+
+	for i := 0; i < maxDecoderDepth; i++ {
+		entry, payload := c.lookup(packet)
+		if entry == nil {
+			break
+		}
+
+		inner, attrs, err := entry.decoder.Decode(payload)
+		if err != nil {
+			atomic.AddUint64(&entry.failures, 1)
+			logging.GetLogger().Debugf("Decoder %s failed, payload kept opaque: %s", entry.name, err.Error())
+			break
+		}
+
+		if len(attrs) > 0 && attributes == nil {
+			attributes = make(map[string]string)
+		}
+		for k, v := range attrs {
+			attributes[k] = v
+		}
+
+		packet = gopacket.NewPacket(inner, layers.LayerTypeEthernet, gopacket.Default)
+	}
+
+	return packet, attributes
+}
+
+// Failures returns the number of packets each decoder failed to decode
+func (c *DecoderChain) Failures() map[string]uint64 {
+	failures := make(map[string]uint64)
+	for _, entry := range c.entries {
+		failures[entry.name] = atomic.LoadUint64(&entry.failures)
+	}
+	return failures
+}
+
+func NewDecoderChain() *DecoderChain {
+	return &DecoderChain{}
+}
+
+func NewDecoderChainFromConfig() (*DecoderChain, error) {
+	chain := NewDecoderChain()
+
+	list, ok := config.GetConfig().Get("agent.flow.decoders").([]interface{})
+	if !ok {
+		return chain, nil
+	}
+
+	for i, item := range list {
+		cfg := make(map[string]interface{})
+		switch item.(type) {
+		case map[string]interface{}:
+			cfg = item.(map[string]interface{})
+		case map[interface{}]interface{}:
+			for k, v := range item.(map[interface{}]interface{}) {
+				cfg[fmt.Sprintf("%v", k)] = v
+			}
+		default:
+			return nil, fmt.Errorf("Malformed decoder definition at index %d", i)
+		}
+
+		t, _ := cfg["type"].(string)
+
+		packetDecodersLock.RLock()
+		ctor, ok := packetDecoders[t]
+		packetDecodersLock.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("Unknown decoder type: %s", t)
+		}
+
+		decoder, err := ctor(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to create decoder %s: %s", t, err.Error())
+		}
+
+		name, _ := cfg["name"].(string)
+		if name == "" {
+			name = fmt.Sprintf("%s-%d", t, i)
+		}
+
+		var match PacketDecoderMatch
+		if v, ok := cfg["ethertype"].(int); ok {
+			match.EtherType = layers.EthernetType(v)
+		}
+		if v, ok := cfg["udp_port"].(int); ok {
+			match.UDPPort = layers.UDPPort(v)
+		}
+		if match.EtherType == 0 && match.UDPPort == 0 {
+			return nil, fmt.Errorf("Decoder %s needs an ethertype or an udp_port", name)
+		}
+
+		chain.Add(name, match, decoder)
+	}
+
+	return chain, nil
+}
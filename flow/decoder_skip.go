@@ -0,0 +1,121 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package flow
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type skipField struct {
+	offset int
+	length int
+}
+
+// SkipDecoder strips a fixed size header, optionally exporting some of its
+// bytes as hex encoded flow attributes.
+type SkipDecoder struct {
+	Length int
+	fields map[string]skipField
+}
+
+func (d *SkipDecoder) Decode(payload []byte) ([]byte, map[string]string, error) {
+	if len(payload) < d.Length {
+		return nil, nil, fmt.Errorf("payload too short: %d < %d", len(payload), d.Length)
+	}
+
+	var attrs map[string]string
+	if len(d.fields) > 0 {
+		attrs = make(map[string]string)
+		for name, field := range d.fields {
+			attrs[name] = hex.EncodeToString(payload[field.offset : field.offset+field.length])
+		}
+	}
+
+	return payload[d.Length:], attrs, nil
+}
+
+// AddField exports length bytes of the header starting at offset as the
+// attribute name
+func (d *SkipDecoder) AddField(name string, offset int, length int) error {
+	if offset < 0 || length <= 0 || offset+length > d.Length {
+		return fmt.Errorf("field %s out of the header bounds", name)
+	}
+	d.fields[name] = skipField{offset: offset, length: length}
+	return nil
+}
+
+func NewSkipDecoder(length int) *SkipDecoder {
+	return &SkipDecoder{
+		Length: length,
+		fields: make(map[string]skipField),
+	}
+}
+
+func newSkipDecoderFromConfig(cfg map[string]interface{}) (PacketDecoder, error) {
+	length, ok := cfg["length"].(int)
+	if !ok || length < 0 {
+		return nil, fmt.Errorf("invalid length: %v", cfg["length"])
+	}
+
+	d := NewSkipDecoder(length)
+
+	fields := make(map[string]string)
+	switch cfg["attributes"].(type) {
+	case map[string]interface{}:
+		for k, v := range cfg["attributes"].(map[string]interface{}) {
+			fields[k] = fmt.Sprintf("%v", v)
+		}
+	case map[interface{}]interface{}:
+		for k, v := range cfg["attributes"].(map[interface{}]interface{}) {
+			fields[fmt.Sprintf("%v", k)] = fmt.Sprintf("%v", v)
+		}
+	}
+
+	// attributes are defined as "offset:length" of the header
+	for name, def := range fields {
+		f := strings.Split(def, ":")
+		if len(f) != 2 {
+			return nil, fmt.Errorf("malformed attribute %s: %s", name, def)
+		}
+		offset, err := strconv.Atoi(f[0])
+		if err != nil {
+			return nil, fmt.Errorf("malformed attribute %s: %s", name, def)
+		}
+		length, err := strconv.Atoi(f[1])
+		if err != nil {
+			return nil, fmt.Errorf("malformed attribute %s: %s", name, def)
+		}
+		if err := d.AddField(name, offset, length); err != nil {
+			return nil, err
+		}
+	}
+
+	return d, nil
+}
+
+func init() {
+	RegisterPacketDecoder("skip", newSkipDecoderFromConfig)
+}
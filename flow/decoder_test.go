@@ -0,0 +1,220 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package flow
+
+import (
+	"net"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+const (
+	testVendorEtherType = layers.EthernetType(0x88b5)
+	testVendorUDPPort   = layers.UDPPort(6633)
+)
+
+func serializeTestLayers(t *testing.T, l ...gopacket.SerializableLayer) []byte {
+	buffer := gopacket.NewSerializeBuffer()
+	options := gopacket.SerializeOptions{FixLengths: true}
+	if err := gopacket.SerializeLayers(buffer, options, l...); err != nil {
+		t.Fatal(err.Error())
+	}
+	return buffer.Bytes()
+}
+
+func forgeInnerFrame(t *testing.T) []byte {
+	return serializeTestLayers(t,
+		&layers.Ethernet{
+			SrcMAC:       net.HardwareAddr{0x00, 0x0F, 0xAA, 0xFA, 0xAA, 0x01},
+			DstMAC:       net.HardwareAddr{0x00, 0x0D, 0xBD, 0xBD, 0x02, 0xBD},
+			EthernetType: layers.EthernetTypeIPv4,
+		},
+		&layers.IPv4{
+			SrcIP:    net.IP{192, 168, 0, 1},
+			DstIP:    net.IP{192, 168, 0, 2},
+			Protocol: layers.IPProtocolTCP,
+		},
+		&layers.TCP{
+			SrcPort: 34567,
+			DstPort: 80,
+		},
+		gopacket.Payload([]byte{10, 20, 30}),
+	)
+}
+
+// forge an outer frame using the vendor ethertype followed by header then inner
+func forgeVendorFrame(t *testing.T, header []byte, inner []byte) []byte {
+	return serializeTestLayers(t,
+		&layers.Ethernet{
+			SrcMAC:       net.HardwareAddr{0x00, 0x11, 0x11, 0x11, 0x11, 0x11},
+			DstMAC:       net.HardwareAddr{0x00, 0x22, 0x22, 0x22, 0x22, 0x22},
+			EthernetType: testVendorEtherType,
+		},
+		gopacket.Payload(append(header, inner...)),
+	)
+}
+
+// forge an outer UDP datagram sent to the vendor port followed by header then inner
+func forgeVendorDatagram(t *testing.T, header []byte, inner []byte) []byte {
+	ip := &layers.IPv4{
+		SrcIP:    net.IP{10, 0, 0, 1},
+		DstIP:    net.IP{10, 0, 0, 2},
+		Protocol: layers.IPProtocolUDP,
+	}
+	udp := &layers.UDP{
+		SrcPort: 45678,
+		DstPort: testVendorUDPPort,
+	}
+	return serializeTestLayers(t,
+		&layers.Ethernet{
+			SrcMAC:       net.HardwareAddr{0x00, 0x33, 0x33, 0x33, 0x33, 0x33},
+			DstMAC:       net.HardwareAddr{0x00, 0x44, 0x44, 0x44, 0x44, 0x44},
+			EthernetType: layers.EthernetTypeIPv4,
+		},
+		ip, udp,
+		gopacket.Payload(append(header, inner...)),
+	)
+}
+
+func newTestDecoderChain(t *testing.T) *DecoderChain {
+	chain := NewDecoderChain()
+
+	fabric := NewSkipDecoder(6)
+	if err := fabric.AddField("FabricPathID", 0, 4); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := fabric.AddField("ServiceTag", 4, 2); err != nil {
+		t.Fatal(err.Error())
+	}
+	chain.Add("fabric", PacketDecoderMatch{EtherType: testVendorEtherType}, fabric)
+
+	tunnel := NewSkipDecoder(8)
+	if err := tunnel.AddField("TunnelID", 4, 4); err != nil {
+		t.Fatal(err.Error())
+	}
+	chain.Add("tunnel", PacketDecoderMatch{UDPPort: testVendorUDPPort}, tunnel)
+
+	return chain
+}
+
+func TestDecoderChain(t *testing.T) {
+	inner := forgeInnerFrame(t)
+	tunnel := forgeVendorDatagram(t, []byte{0, 0, 0, 0, 0, 0, 0, 42}, inner)
+	data := forgeVendorFrame(t, []byte{0xde, 0xad, 0xbe, 0xef, 0x00, 0x07}, tunnel)
+
+	chain := newTestDecoderChain(t)
+
+	packet, attrs := chain.Decode(gopacket.NewPacket(data, layers.LayerTypeEthernet, gopacket.Default))
+
+	ipv4, ok := packet.Layer(layers.LayerTypeIPv4).(*layers.IPv4)
+	if !ok || !ipv4.SrcIP.Equal(net.IP{192, 168, 0, 1}) {
+		t.Fatalf("Inner packet not decoded: %v", packet)
+	}
+
+	expected := map[string]string{
+		"FabricPathID": "deadbeef",
+		"ServiceTag":   "0007",
+		"TunnelID":     "0000002a",
+	}
+	for k, v := range expected {
+		if attrs[k] != v {
+			t.Errorf("Expected attribute %s=%s, got %s", k, v, attrs[k])
+		}
+	}
+
+	for name, count := range chain.Failures() {
+		if count != 0 {
+			t.Errorf("Decoder %s should not have failed", name)
+		}
+	}
+}
+
+func TestDecoderChainMalformed(t *testing.T) {
+	// vendor header truncated, only 3 bytes instead of 6, forged by hand to
+	// avoid the ethernet padding
+	data := []byte{
+		0x00, 0x22, 0x22, 0x22, 0x22, 0x22,
+		0x00, 0x11, 0x11, 0x11, 0x11, 0x11,
+		0x88, 0xb5,
+		0xde, 0xad, 0xbe,
+	}
+
+	chain := newTestDecoderChain(t)
+
+	packet, attrs := chain.Decode(gopacket.NewPacket(data, layers.LayerTypeEthernet, gopacket.Default))
+
+	ethernet, ok := packet.Layer(layers.LayerTypeEthernet).(*layers.Ethernet)
+	if !ok || ethernet.EthernetType != testVendorEtherType {
+		t.Fatalf("Outer packet should have been kept opaque: %v", packet)
+	}
+
+	if len(attrs) != 0 {
+		t.Errorf("No attribute expected, got %v", attrs)
+	}
+
+	if chain.Failures()["fabric"] != 1 {
+		t.Errorf("Failure counter not incremented: %v", chain.Failures())
+	}
+}
+
+func TestFlowFromDecodedPacket(t *testing.T) {
+	inner := forgeInnerFrame(t)
+	data := forgeVendorFrame(t, []byte{0xde, 0xad, 0xbe, 0xef, 0x00, 0x07}, inner)
+	packet := gopacket.NewPacket(data, layers.LayerTypeEthernet, gopacket.Default)
+
+	ft := NewTable()
+	ft.SetDecoderChain(newTestDecoderChain(t))
+
+	flow := FlowFromGoPacket(ft, &packet, nil)
+	if flow == nil {
+		t.Fatal("Flow not created")
+	}
+
+	if flow.LayersPath != "Ethernet/IPv4/TCP/Payload" {
+		t.Errorf("Flow should be built from the inner packet, got %s", flow.LayersPath)
+	}
+
+	if flow.Attributes["FabricPathID"] != "deadbeef" {
+		t.Errorf("Attributes not attached to the flow: %v", flow.Attributes)
+	}
+}
+
+func TestSkipDecoderFromConfig(t *testing.T) {
+	cfg := map[string]interface{}{
+		"length": 4,
+		"attributes": map[interface{}]interface{}{
+			"Tag": "0:8",
+		},
+	}
+
+	if _, err := newSkipDecoderFromConfig(cfg); err == nil {
+		t.Error("Attribute out of the header bounds should be rejected")
+	}
+
+	cfg["attributes"] = map[interface{}]interface{}{"Tag": "2:2"}
+	if _, err := newSkipDecoderFromConfig(cfg); err != nil {
+		t.Error(err.Error())
+	}
+}
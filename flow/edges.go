@@ -0,0 +1,262 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package flow
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/redhat-cip/skydive/config"
+	"github.com/redhat-cip/skydive/topology/graph"
+)
+
+// FlowEdgeOrigin is the Origin of the edges projected from the flows
+const FlowEdgeOrigin = "flow"
+
+// The statistics of the flows the projected edges can carry
+const (
+	FlowEdgeBytes    = "bytes"
+	FlowEdgePackets  = "packets"
+	FlowEdgeRate     = "rate"
+	FlowEdgeLastSeen = "last_seen"
+)
+
+var flowEdgeKeys = map[string]string{
+	FlowEdgeBytes:    "Flow.Bytes",
+	FlowEdgePackets:  "Flow.Packets",
+	FlowEdgeRate:     "Flow.Rate",
+	FlowEdgeLastSeen: "Flow.LastSeen",
+}
+
+type flowEdgeKey struct {
+	src graph.Identifier
+	dst graph.Identifier
+}
+
+type flowCounters struct {
+	bytes   uint64
+	packets uint64
+}
+
+// projectedEdge sums the counters of the flows between two interfaces,
+// the counters of the flows of the table being kept to add the increments
+type projectedEdge struct {
+	id       graph.Identifier
+	flows    map[string]flowCounters
+	bytes    uint64
+	packets  uint64
+	last     int64
+	rate     float64
+	rated    bool
+	batch    uint64
+	updated  time.Time
+	activity time.Time
+}
+
+// EdgeProjector links the interfaces exchanging flows with edges carrying
+// the configured statistics of these flows. The rate, in bytes per second,
+// is the one of the last batch of flows or, with a rate window, its moving
+// average over the window. The edges without traffic for the expire
+// duration are removed.
+type EdgeProjector struct {
+	sync.Mutex
+	Graph      *graph.Graph
+	fields     []string
+	rateWindow time.Duration
+	expire     time.Duration
+	edges      map[flowEdgeKey]*projectedEdge
+}
+
+func (e *projectedEdge) add(f *Flow) {
+	counters := flowCounters{bytes: flowBytes(f), packets: flowPackets(f)}
+
+	// the counters of a flow only grow, unless it started over
+	previous := e.flows[f.UUID]
+	if counters.bytes < previous.bytes || counters.packets < previous.packets {
+		previous = flowCounters{}
+	}
+	e.bytes += counters.bytes - previous.bytes
+	e.packets += counters.packets - previous.packets
+	e.batch += counters.bytes - previous.bytes
+	e.flows[f.UUID] = counters
+
+	if fs := f.GetStatistics(); fs != nil && fs.Last > e.last {
+		e.last = fs.Last
+	}
+}
+
+// updateRate folds the bytes of the batch into the rate, the first batch
+// only giving the starting point
+func (e *projectedEdge) updateRate(now time.Time, window time.Duration) {
+	defer func() {
+		e.batch = 0
+		e.updated = now
+	}()
+
+	if e.updated.IsZero() {
+		return
+	}
+	elapsed := now.Sub(e.updated)
+	if elapsed <= 0 {
+		return
+	}
+
+	rate := float64(e.batch) / elapsed.Seconds()
+	if window <= 0 || !e.rated {
+		e.rate, e.rated = rate, true
+		return
+	}
+
+	alpha := 1 - math.Exp(-elapsed.Seconds()/window.Seconds())
+	e.rate = alpha*rate + (1-alpha)*e.rate
+}
+
+func (p *EdgeProjector) metadata(e *projectedEdge) graph.Metadata {
+	m := graph.Metadata{}
+	for _, field := range p.fields {
+		switch field {
+		case FlowEdgeBytes:
+			m[flowEdgeKeys[field]] = int64(e.bytes)
+		case FlowEdgePackets:
+			m[flowEdgeKeys[field]] = int64(e.packets)
+		case FlowEdgeRate:
+			m[flowEdgeKeys[field]] = e.rate
+		case FlowEdgeLastSeen:
+			m[flowEdgeKeys[field]] = e.last
+		}
+	}
+	return m
+}
+
+// sync creates or updates the edge in the graph, the graph lock being held
+func (p *EdgeProjector) sync(key flowEdgeKey, e *projectedEdge) {
+	edge := p.Graph.GetEdge(e.id)
+	if edge == nil {
+		src, dst := p.Graph.GetNode(key.src), p.Graph.GetNode(key.dst)
+		if src == nil || dst == nil {
+			return
+		}
+
+		m := p.metadata(e)
+		m["RelationType"] = "flow"
+		m[graph.OriginKey] = FlowEdgeOrigin
+		if edge = p.Graph.NewEdge(graph.GenID(), src, dst, m); edge != nil {
+			e.id = edge.ID
+		}
+		return
+	}
+
+	tr := p.Graph.StartMetadataTransaction(edge)
+	for k, v := range p.metadata(e) {
+		tr.AddMetadata(k, v)
+	}
+	tr.Commit()
+}
+
+func (p *EdgeProjector) project(flows []*Flow, now time.Time) {
+	p.Lock()
+	defer p.Unlock()
+
+	for _, f := range flows {
+		if f.IfSrcNodeUUID == "" || f.IfDstNodeUUID == "" || f.IfSrcNodeUUID == f.IfDstNodeUUID {
+			continue
+		}
+
+		key := flowEdgeKey{src: graph.Identifier(f.IfSrcNodeUUID), dst: graph.Identifier(f.IfDstNodeUUID)}
+		e, ok := p.edges[key]
+		if !ok {
+			e = &projectedEdge{flows: make(map[string]flowCounters)}
+			p.edges[key] = e
+		}
+		e.add(f)
+		e.activity = now
+	}
+
+	p.Graph.Lock()
+	defer p.Graph.Unlock()
+
+	for key, e := range p.edges {
+		if p.expire > 0 && now.Sub(e.activity) >= p.expire {
+			if edge := p.Graph.GetEdge(e.id); edge != nil {
+				p.Graph.DelEdge(edge)
+			}
+			delete(p.edges, key)
+			continue
+		}
+
+		// the edges without traffic in the batch see their rate decrease
+		e.updateRate(now, p.rateWindow)
+		p.sync(key, e)
+	}
+}
+
+// OnFlowsUpdated projects a batch of updated flows
+func (p *EdgeProjector) OnFlowsUpdated(flows []*Flow) {
+	p.project(flows, time.Now())
+}
+
+// OnFlowsExpired forgets the expired flows, their counters staying in the
+// totals of the edges
+func (p *EdgeProjector) OnFlowsExpired(flows []*Flow) {
+	p.Lock()
+	defer p.Unlock()
+
+	for _, f := range flows {
+		key := flowEdgeKey{src: graph.Identifier(f.IfSrcNodeUUID), dst: graph.Identifier(f.IfDstNodeUUID)}
+		if e, ok := p.edges[key]; ok {
+			delete(e.flows, f.UUID)
+		}
+	}
+}
+
+func NewEdgeProjector(g *graph.Graph, fields []string, rateWindow time.Duration, expire time.Duration) (*EdgeProjector, error) {
+	for _, field := range fields {
+		if _, ok := flowEdgeKeys[field]; !ok {
+			return nil, fmt.Errorf("Unknown flow edge metadata %s", field)
+		}
+	}
+
+	return &EdgeProjector{
+		Graph:      g,
+		fields:     fields,
+		rateWindow: rateWindow,
+		expire:     expire,
+		edges:      make(map[flowEdgeKey]*projectedEdge),
+	}, nil
+}
+
+// NewEdgeProjectorFromConfig returns the projector configured by
+// analyzer.flow_edges, nil when disabled
+func NewEdgeProjectorFromConfig(g *graph.Graph) (*EdgeProjector, error) {
+	cfg := config.GetConfig()
+	if !cfg.GetBool("analyzer.flow_edges.enabled") {
+		return nil, nil
+	}
+
+	return NewEdgeProjector(g,
+		cfg.GetStringSlice("analyzer.flow_edges.metadata"),
+		time.Duration(cfg.GetInt("analyzer.flow_edges.rate_window"))*time.Second,
+		time.Duration(cfg.GetInt("analyzer.flow_edges.expire"))*time.Second)
+}
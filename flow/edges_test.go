@@ -0,0 +1,156 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package flow
+
+import (
+	"testing"
+	"time"
+
+	"github.com/redhat-cip/skydive/topology/graph"
+)
+
+func newEdgeFlow(uuid string, src *graph.Node, dst *graph.Node, bytes uint64, packets uint64, last int64) *Flow {
+	return &Flow{
+		UUID:          uuid,
+		IfSrcNodeUUID: string(src.ID),
+		IfDstNodeUUID: string(dst.ID),
+		Statistics: &FlowStatistics{
+			Last: last,
+			Endpoints: []*FlowEndpointsStatistics{
+				{AB: &FlowEndpointStatistics{Bytes: bytes, Packets: packets}},
+			},
+		},
+	}
+}
+
+func newEdgeProjectorTest(t *testing.T, fields []string, rateWindow time.Duration) (*EdgeProjector, *graph.Node, *graph.Node) {
+	b, err := graph.NewMemoryBackend()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	g, err := graph.NewGraph(b)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	n1 := g.NewNode(graph.GenID(), graph.Metadata{"Name": "eth0"})
+	n2 := g.NewNode(graph.GenID(), graph.Metadata{"Name": "eth1"})
+
+	p, err := NewEdgeProjector(g, fields, rateWindow, time.Minute)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return p, n1, n2
+}
+
+func flowEdges(p *EdgeProjector) []*graph.Edge {
+	var edges []*graph.Edge
+	for _, e := range p.Graph.GetEdges() {
+		if e.Metadata()["RelationType"] == "flow" {
+			edges = append(edges, e)
+		}
+	}
+	return edges
+}
+
+func TestEdgeProjectorMetadata(t *testing.T) {
+	p, n1, n2 := newEdgeProjectorTest(t, []string{FlowEdgeBytes, FlowEdgeLastSeen}, 0)
+
+	now := time.Now()
+	p.project([]*Flow{
+		newEdgeFlow("f1", n1, n2, 1000, 10, 100),
+		newEdgeFlow("f2", n1, n2, 500, 5, 200),
+	}, now)
+
+	edges := flowEdges(p)
+	if len(edges) != 1 {
+		t.Fatalf("Expected 1 flow edge, got: %v", edges)
+	}
+
+	m := edges[0].Metadata()
+	if m["Flow.Bytes"] != int64(1500) || m["Flow.LastSeen"] != int64(200) || m[graph.OriginKey] != FlowEdgeOrigin {
+		t.Errorf("Unexpected edge metadata: %v", m)
+	}
+	if _, ok := m["Flow.Packets"]; ok {
+		t.Errorf("Packets not configured but found: %v", m)
+	}
+	if _, ok := m["Flow.Rate"]; ok {
+		t.Errorf("Rate not configured but found: %v", m)
+	}
+
+	if _, err := NewEdgeProjector(p.Graph, []string{"jitter"}, 0, 0); err == nil {
+		t.Error("Expected an error for an unknown metadata")
+	}
+}
+
+func TestEdgeProjectorRate(t *testing.T) {
+	p, n1, n2 := newEdgeProjectorTest(t, []string{FlowEdgeBytes, FlowEdgePackets, FlowEdgeRate}, 0)
+
+	now := time.Now()
+	p.project([]*Flow{newEdgeFlow("f1", n1, n2, 1000, 10, 100)}, now)
+	p.project([]*Flow{newEdgeFlow("f1", n1, n2, 3000, 30, 110)}, now.Add(10*time.Second))
+
+	m := flowEdges(p)[0].Metadata()
+	if m["Flow.Bytes"] != int64(3000) || m["Flow.Packets"] != int64(30) {
+		t.Errorf("Expected the counters of the last update, got: %v", m)
+	}
+	if m["Flow.Rate"] != float64(200) {
+		t.Errorf("Expected a rate of 200 bytes/s, got: %v", m["Flow.Rate"])
+	}
+
+	// no traffic in the batch
+	p.project(nil, now.Add(20*time.Second))
+	if m = flowEdges(p)[0].Metadata(); m["Flow.Rate"] != float64(0) {
+		t.Errorf("Expected a null rate, got: %v", m["Flow.Rate"])
+	}
+
+	// expired, the edge is removed
+	p.project(nil, now.Add(2*time.Minute))
+	if edges := flowEdges(p); len(edges) != 0 {
+		t.Errorf("Expected the edge to expire, got: %v", edges)
+	}
+}
+
+func TestEdgeProjectorRollingRate(t *testing.T) {
+	p, n1, n2 := newEdgeProjectorTest(t, []string{FlowEdgeRate}, 10*time.Second)
+
+	now := time.Now()
+	p.project([]*Flow{newEdgeFlow("f1", n1, n2, 0, 0, 100)}, now)
+	p.project([]*Flow{newEdgeFlow("f1", n1, n2, 2000, 20, 110)}, now.Add(10*time.Second))
+
+	if rate := flowEdges(p)[0].Metadata()["Flow.Rate"]; rate != float64(200) {
+		t.Fatalf("Expected a starting rate of 200 bytes/s, got: %v", rate)
+	}
+
+	// a batch without traffic lowers the average without zeroing it
+	p.project(nil, now.Add(20*time.Second))
+	rate := flowEdges(p)[0].Metadata()["Flow.Rate"].(float64)
+	if rate <= 0 || rate >= 200 {
+		t.Errorf("Expected a decreasing rolling rate, got: %v", rate)
+	}
+
+	p.project([]*Flow{newEdgeFlow("f1", n1, n2, 6000, 60, 130)}, now.Add(30*time.Second))
+	if next := flowEdges(p)[0].Metadata()["Flow.Rate"].(float64); next <= rate {
+		t.Errorf("Expected the rolling rate to increase from %v, got: %v", rate, next)
+	}
+}
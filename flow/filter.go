@@ -0,0 +1,278 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package flow
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Filter matches flows having the given values, the keys being either a
+// flow field (ex: LayersPath, ProbeNodeUUID), an attribute (Attributes.NAT_A)
+// or an endpoint value (IPV4.A, TCPPORT.B). The numeric fields (Duration)
+// can be compared with gt:, gte:, lt: or lte: prefixed values.
+type Filter map[string]string
+
+// NumericFilter compares a numeric field of the flows to a value
+type NumericFilter struct {
+	Op    string
+	Value int64
+}
+
+// ParseNumericFilter parses values of the form op:value, op being one of
+// gt, gte, lt or lte, a plain value meaning equal
+func ParseNumericFilter(s string) (NumericFilter, error) {
+	nf := NumericFilter{Op: "eq"}
+
+	if i := strings.Index(s, ":"); i != -1 {
+		nf.Op = s[:i]
+		switch nf.Op {
+		case "gt", "gte", "lt", "lte":
+		default:
+			return nf, fmt.Errorf("Unknown comparison operator: %s", nf.Op)
+		}
+		s = s[i+1:]
+	}
+
+	value, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return nf, fmt.Errorf("Malformed numeric value: %s", s)
+	}
+	nf.Value = value
+
+	return nf, nil
+}
+
+func (nf NumericFilter) Match(value int64) bool {
+	switch nf.Op {
+	case "gt":
+		return value > nf.Value
+	case "gte":
+		return value >= nf.Value
+	case "lt":
+		return value < nf.Value
+	case "lte":
+		return value <= nf.Value
+	}
+	return value == nf.Value
+}
+
+// ParseFilter parses filters of the form Key=Value,Key=Value
+func ParseFilter(s string) (Filter, error) {
+	filter := make(Filter)
+	for _, term := range strings.Split(s, ",") {
+		if term = strings.TrimSpace(term); term == "" {
+			continue
+		}
+
+		kv := strings.SplitN(term, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("Malformed filter term: %s", term)
+		}
+
+		key, value := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+		if !IsFilterKey(key) {
+			return nil, fmt.Errorf("Unknown filter key: %s", key)
+		}
+		if IsNumericField(key) {
+			if _, err := ParseNumericFilter(value); err != nil {
+				return nil, err
+			}
+		}
+		filter[key] = value
+	}
+
+	if len(filter) == 0 {
+		return nil, errors.New("Empty filter")
+	}
+
+	return filter, nil
+}
+
+// IsFilterKey returns whether the key designates a field, an attribute or
+// an endpoint value of the flows
+func IsFilterKey(key string) bool {
+	if IsNumericField(key) || strings.HasPrefix(key, "Attributes.") {
+		return true
+	}
+
+	if strings.Contains(key, ".") {
+		_, _, ok := EndpointFilterKey(key)
+		return ok
+	}
+
+	_, ok := (&Flow{}).GetFieldString(key)
+	return ok
+}
+
+// EndpointFilterKey returns the endpoint type of an endpoint filter key,
+// ex: IPV4.A, and whether it designates the A side, the value of the AB
+// statistics, or the B side, the value of the BA ones
+func EndpointFilterKey(key string) (t FlowEndpointType, sideA bool, ok bool) {
+	i := strings.LastIndex(key, ".")
+	if i == -1 || (key[i+1:] != "A" && key[i+1:] != "B") {
+		return 0, false, false
+	}
+
+	value, ok := FlowEndpointType_value[key[:i]]
+	return FlowEndpointType(value), key[i+1:] == "A", ok
+}
+
+// IsNumericField returns whether the key designates a numeric field
+func IsNumericField(key string) bool {
+	_, ok := (&Flow{}).GetFieldInt64(key)
+	return ok
+}
+
+// filterFields are the flow fields accepted by the filters, numeric ones
+// included
+var filterFields = []string{
+	"UUID", "LayersPath", "TrackingID", "ProbeNodeUUID", "IfSrcNodeUUID", "IfDstNodeUUID",
+	"A_Role", "B_Role", "Duration", "Statistics.Start", "Statistics.Last",
+}
+
+// FilterKeys returns the keys accepted by the filters, sorted, the
+// attributes being given as Attributes.<name>
+func FilterKeys() []string {
+	keys := append([]string{"Attributes.<name>"}, filterFields...)
+	for _, t := range FlowEndpointType_name {
+		keys = append(keys, t+".A", t+".B")
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ComputeDuration returns the seconds elapsed between the first and the
+// last packet of the flow
+func (flow *Flow) ComputeDuration() int64 {
+	fs := flow.GetStatistics()
+	if fs == nil {
+		return 0
+	}
+	return fs.Last - fs.Start
+}
+
+// GetFieldInt64 returns the value of a numeric field of the flow, the
+// duration being computed from the statistics
+func (flow *Flow) GetFieldInt64(field string) (int64, bool) {
+	fs := flow.GetStatistics()
+	if fs == nil {
+		fs = &FlowStatistics{}
+	}
+
+	switch field {
+	case "Duration":
+		return fs.Last - fs.Start, true
+	case "Statistics.Start":
+		return fs.Start, true
+	case "Statistics.Last":
+		return fs.Last, true
+	}
+	return 0, false
+}
+
+// GetFieldString returns the value of a string field of the flow
+func (flow *Flow) GetFieldString(field string) (string, bool) {
+	switch field {
+	case "UUID":
+		return flow.UUID, true
+	case "LayersPath":
+		return flow.LayersPath, true
+	case "TrackingID":
+		return flow.TrackingID, true
+	case "ProbeNodeUUID":
+		return flow.ProbeNodeUUID, true
+	case "IfSrcNodeUUID":
+		return flow.IfSrcNodeUUID, true
+	case "IfDstNodeUUID":
+		return flow.IfDstNodeUUID, true
+	case "A_Role":
+		return flow.A_Role, true
+	case "B_Role":
+		return flow.B_Role, true
+	}
+	return "", false
+}
+
+// GetFilterValue returns the value designated by a filter key, empty if the
+// flow doesn't have it
+func (flow *Flow) GetFilterValue(key string) string {
+	if value, ok := flow.GetFieldInt64(key); ok {
+		return strconv.FormatInt(value, 10)
+	}
+
+	if strings.HasPrefix(key, "Attributes.") {
+		return flow.GetAttributes()[strings.TrimPrefix(key, "Attributes.")]
+	}
+
+	if t, sideA, ok := EndpointFilterKey(key); ok {
+		fs := flow.GetStatistics()
+		if fs == nil {
+			return ""
+		}
+
+		ep := fs.GetEndpointsType(t)
+		side := ep.GetBA()
+		if sideA {
+			side = ep.GetAB()
+		}
+		if side == nil {
+			return ""
+		}
+		return side.Value
+	}
+
+	value, _ := flow.GetFieldString(key)
+	return value
+}
+
+func (f Filter) Match(flow *Flow) bool {
+	for key, value := range f {
+		if v, ok := flow.GetFieldInt64(key); ok {
+			if nf, err := ParseNumericFilter(value); err != nil || !nf.Match(v) {
+				return false
+			}
+			continue
+		}
+
+		if flow.GetFilterValue(key) != value {
+			return false
+		}
+	}
+	return true
+}
+
+// FieldsOnly returns the part of the filter applying to the flow fields
+// only, this part can be used to query the storages.
+func (f Filter) FieldsOnly() map[string]interface{} {
+	fields := make(map[string]interface{})
+	for key, value := range f {
+		if _, ok := (&Flow{}).GetFieldString(key); ok {
+			fields[key] = value
+		}
+	}
+	return fields
+}
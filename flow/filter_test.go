@@ -0,0 +1,128 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package flow
+
+import (
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+	flow := &Flow{
+		LayersPath:    "Ethernet/IPv4/TCP",
+		ProbeNodeUUID: "probe",
+		Attributes:    map[string]string{FlowAttributeNATA: "10.0.0.1"},
+		Statistics: &FlowStatistics{
+			Start: 1000,
+			Last:  1060,
+			Endpoints: []*FlowEndpointsStatistics{
+				{
+					Type: FlowEndpointType_IPV4,
+					AB:   &FlowEndpointStatistics{Value: "192.168.0.1"},
+					BA:   &FlowEndpointStatistics{Value: "192.168.0.2"},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		filter string
+		match  bool
+	}{
+		{"ProbeNodeUUID=probe", true},
+		{"ProbeNodeUUID=probe, LayersPath=Ethernet/IPv4/TCP", true},
+		{"ProbeNodeUUID=other", false},
+		{"IPV4.A=192.168.0.1,IPV4.B=192.168.0.2", true},
+		{"IPV4.B=192.168.0.1", false},
+		{"TCPPORT.A=80", false},
+		{"Attributes.NAT_A=10.0.0.1", true},
+		{"Duration=60", true},
+		{"Duration=gt:59", true},
+		{"Duration=gt:60", false},
+		{"Duration=gte:60,ProbeNodeUUID=probe", true},
+		{"Duration=lt:60", false},
+		{"Duration=lte:60", true},
+	}
+
+	for _, test := range tests {
+		filter, err := ParseFilter(test.filter)
+		if err != nil {
+			t.Fatalf("%s: %s", test.filter, err.Error())
+		}
+
+		if filter.Match(flow) != test.match {
+			t.Errorf("%s: expected match %v", test.filter, test.match)
+		}
+	}
+
+	fields := (Filter{"ProbeNodeUUID": "probe", "IPV4.A": "192.168.0.1"}).FieldsOnly()
+	if len(fields) != 1 || fields["ProbeNodeUUID"] != "probe" {
+		t.Errorf("Wrong storage fields: %v", fields)
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	for _, s := range []string{"", "ProbeNodeUUID", "Unknown=1", "IPV4.C=192.168.0.1", "IPV5.A=192.168.0.1", "Duration=ge:1", "Duration=long"} {
+		if _, err := ParseFilter(s); err == nil {
+			t.Errorf("%s: error expected", s)
+		}
+	}
+}
+
+func TestFilterKeys(t *testing.T) {
+	keys := FilterKeys()
+	for _, key := range keys {
+		if !IsFilterKey(key) {
+			t.Errorf("%s listed but not accepted", key)
+		}
+	}
+	if len(keys) != 1+11+2*len(FlowEndpointType_name) {
+		t.Errorf("Unexpected number of keys: %v", keys)
+	}
+}
+
+func TestEndpointFilterKey(t *testing.T) {
+	for _, test := range []struct {
+		key   string
+		t     FlowEndpointType
+		sideA bool
+		ok    bool
+	}{
+		{"IPV4.A", FlowEndpointType_IPV4, true, true},
+		{"TCPPORT.B", FlowEndpointType_TCPPORT, false, true},
+		{"IPV4.C", 0, false, false},
+		{"Attributes.NAT_A", 0, false, false},
+		{"LayersPath", 0, false, false},
+	} {
+		if et, sideA, ok := EndpointFilterKey(test.key); ok != test.ok || (ok && (et != test.t || sideA != test.sideA)) {
+			t.Errorf("%s: expected %v %v %v, got %v %v %v", test.key, test.t, test.sideA, test.ok, et, sideA, ok)
+		}
+	}
+
+	// a flow seen in a single direction
+	f := &Flow{Statistics: &FlowStatistics{Endpoints: []*FlowEndpointsStatistics{
+		{Type: FlowEndpointType_IPV4, AB: &FlowEndpointStatistics{Value: "10.0.0.1"}},
+	}}}
+	if f.GetFilterValue("IPV4.A") != "10.0.0.1" || f.GetFilterValue("IPV4.B") != "" || (&Flow{}).GetFilterValue("IPV4.A") != "" {
+		t.Errorf("Wrong endpoint values of the flows missing a side or statistics")
+	}
+}
@@ -39,6 +39,29 @@ import (
 	"github.com/redhat-cip/skydive/logging"
 )
 
+const (
+	// translated network addresses of the endpoints A and B, set by the
+	// agents when NAT information is available
+	FlowAttributeNATA = "NAT_A"
+	FlowAttributeNATB = "NAT_B"
+	// autonomous system numbers of the public IPv4 endpoints A and B
+	FlowAttributeASNA = "ASN_A"
+	FlowAttributeASNB = "ASN_B"
+	// fields of the TLS ClientHello of the client A, set by the agents, the
+	// lists being dash separated decimal values as in the JA3 strings
+	FlowAttributeTLSVersion      = "TLS_VERSION"
+	FlowAttributeTLSCiphers      = "TLS_CIPHERS"
+	FlowAttributeTLSExtensions   = "TLS_EXTENSIONS"
+	FlowAttributeTLSCurves       = "TLS_CURVES"
+	FlowAttributeTLSPointFormats = "TLS_POINT_FORMATS"
+	// JA3 fingerprint of the TLS client, set by the agents or computed from
+	// the ClientHello fields
+	FlowAttributeJA3 = "JA3"
+	// unix time the stored flow was attributed to its interfaces by a
+	// backfill of the analyzer
+	FlowAttributeBackfilledAt = "BackfilledAt"
+)
+
 type FlowProbeNodeSetter interface {
 	SetProbeNode(flow *Flow) bool
 }
@@ -159,7 +182,7 @@ func (key FlowKey) String() string {
 	return fmt.Sprintf("%x-%x", key.net, key.transport)
 }
 
-func (flow *Flow) fillFromGoPacket(packet *gopacket.Packet) error {
+func (flow *Flow) fillFromGoPacket(packet *gopacket.Packet, weight uint64, truncated uint64) error {
 	/* Continue if no ethernet layer */
 	ethernetLayer := (*packet).Layer(layers.LayerTypeEthernet)
 	_, ok := ethernetLayer.(*layers.Ethernet)
@@ -177,7 +200,8 @@ func (flow *Flow) fillFromGoPacket(packet *gopacket.Packet) error {
 		flow.Statistics = fs
 	}
 	fs.Last = now
-	fs.Update(packet)
+	fs.update(packet, weight, truncated)
+	flow.Duration = fs.Last - fs.Start
 
 	if newFlow {
 		hasher := sha1.New()
@@ -191,6 +215,8 @@ func (flow *Flow) fillFromGoPacket(packet *gopacket.Packet) error {
 		flow.LayersPath = path
 		hasher.Write([]byte(flow.LayersPath))
 
+		flow.detectRoles(packet)
+
 		/* Generate an flow UUID */
 		for _, ep := range fs.GetEndpoints() {
 			hasher.Write(ep.Hash)
@@ -206,12 +232,22 @@ func (flow *Flow) fillFromGoPacket(packet *gopacket.Packet) error {
 	return nil
 }
 
+// maxDumpedBytes is the number of the first bytes of the malformed flows
+// given by the errors
+const maxDumpedBytes = 16
+
+// FromData decodes a flow, the errors giving the size and the first bytes of
+// the malformed ones
 func FromData(data []byte) (*Flow, error) {
 	flow := new(Flow)
 
 	err := proto.Unmarshal(data, flow)
 	if err != nil {
-		return nil, err
+		dump := data
+		if len(dump) > maxDumpedBytes {
+			dump = dump[:maxDumpedBytes]
+		}
+		return nil, fmt.Errorf("malformed flow of %d bytes starting with %s: %s", len(data), hex.EncodeToString(dump), err.Error())
 	}
 
 	return flow, nil
@@ -227,18 +263,58 @@ func (flow *Flow) GetData() ([]byte, error) {
 }
 
 func FlowFromGoPacket(ft *Table, packet *gopacket.Packet, setter FlowProbeNodeSetter) *Flow {
+	return flowFromGoPacket(ft, packet, setter, 1, 0)
+}
+
+// FlowFromSampledGoPacket updates the flow of a packet sampled at the given
+// rate, the packet standing for rate packets in the statistics of the flow.
+// The packet being the header of a frame of frameLength bytes, the bytes
+// of the frame are counted, not only the ones of its header.
+func FlowFromSampledGoPacket(ft *Table, packet *gopacket.Packet, setter FlowProbeNodeSetter, rate uint32, frameLength uint32) *Flow {
+	if rate == 0 {
+		rate = 1
+	}
+	var truncated uint64
+	if captured := len((*packet).Data()); int(frameLength) > captured {
+		truncated = uint64(int(frameLength) - captured)
+	}
+	return flowFromGoPacket(ft, packet, setter, uint64(rate), truncated)
+}
+
+func flowFromGoPacket(ft *Table, packet *gopacket.Packet, setter FlowProbeNodeSetter, weight uint64, truncated uint64) *Flow {
+	var attributes map[string]string
+	if ft.decoders != nil {
+		var inner gopacket.Packet
+		inner, attributes = ft.decoders.Decode(*packet)
+		packet = &inner
+	}
+
 	key := NewFlowKeyFromGoPacket(packet)
-	flow, _ := ft.GetOrCreateFlow(key.String())
+	flow, created := ft.GetOrCreateFlow(key.String())
 	if setter != nil {
 		setter.SetProbeNode(flow)
+		// the tags are stamped once, a flow keeping the tags it was created
+		// with
+		if tagger, ok := setter.(FlowTagger); ok && created {
+			flow.SetTags(tagger.FlowTags())
+		}
 	}
 
-	err := flow.fillFromGoPacket(packet)
+	err := flow.fillFromGoPacket(packet, weight, truncated)
 	if err != nil {
 		logging.GetLogger().Error(err.Error())
 		return nil
 	}
 
+	if len(attributes) > 0 {
+		if flow.Attributes == nil {
+			flow.Attributes = make(map[string]string)
+		}
+		for k, v := range attributes {
+			flow.Attributes[k] = v
+		}
+	}
+
 	return flow
 }
 
@@ -159,6 +159,13 @@ type Flow struct {
 	ProbeNodeUUID string `protobuf:"bytes,11,opt,name=ProbeNodeUUID" json:"ProbeNodeUUID,omitempty"`
 	IfSrcNodeUUID string `protobuf:"bytes,14,opt,name=IfSrcNodeUUID" json:"IfSrcNodeUUID,omitempty"`
 	IfDstNodeUUID string `protobuf:"bytes,19,opt,name=IfDstNodeUUID" json:"IfDstNodeUUID,omitempty"`
+	// Attributes attached while decoding or enhancing the flow
+	Attributes map[string]string `protobuf:"bytes,20,rep,name=Attributes" json:"Attributes,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// Endpoint roles, client, server or unknown
+	A_Role string `protobuf:"bytes,21,opt,name=A_Role" json:"A_Role,omitempty"`
+	B_Role string `protobuf:"bytes,22,opt,name=B_Role" json:"B_Role,omitempty"`
+	// Seconds between the first and the last packet of the flow
+	Duration int64 `protobuf:"varint,23,opt,name=Duration" json:"Duration,omitempty"`
 }
 
 func (m *Flow) Reset()                    { *m = Flow{} }
@@ -173,6 +180,13 @@ func (m *Flow) GetStatistics() *FlowStatistics {
 	return nil
 }
 
+func (m *Flow) GetAttributes() map[string]string {
+	if m != nil {
+		return m.Attributes
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*FlowEndpointStatistics)(nil), "flow.FlowEndpointStatistics")
 	proto.RegisterType((*FlowEndpointsStatistics)(nil), "flow.FlowEndpointsStatistics")
@@ -25,6 +25,7 @@ package flow
 import (
 	"encoding/json"
 	"reflect"
+	"strings"
 	"testing"
 
 	v "github.com/gima/govalid/v1"
@@ -106,3 +107,10 @@ func TestFlowJSON(t *testing.T) {
 		t.Fatal("Unmarshalled flow not equal to the original")
 	}
 }
+
+func TestFromDataError(t *testing.T) {
+	data := append([]byte{0xff, 0xff, 0xff}, []byte(strings.Repeat("x", 40))...)
+	if _, err := FromData(data); err == nil || !strings.HasPrefix(err.Error(), "malformed flow of 43 bytes starting with ffffff78787878787878787878787878: ") {
+		t.Errorf("Expected the size and the first bytes of the flow, got %v", err)
+	}
+}
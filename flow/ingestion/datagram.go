@@ -0,0 +1,120 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package ingestion
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/redhat-cip/skydive/config"
+)
+
+// maximum payload of a UDP datagram
+const maxUDPPayload = 65535
+
+// DatagramStats counts the flow datagrams received over UDP, the ones
+// filling the read buffer of MaxSize bytes being dropped as truncated, and
+// the flows of the datagrams failing to parse
+type DatagramStats struct {
+	MaxSize   int
+	Received  uint64
+	Truncated uint64
+	Malformed uint64
+	Agents    map[string]uint64 `json:",omitempty"`
+}
+
+// DatagramReader sizes the read buffer of the flow datagrams and counts the
+// truncated ones, per agent
+type DatagramReader struct {
+	// first for the alignment of the atomic operations
+	received  uint64
+	truncated uint64
+	malformed uint64
+	sync.Mutex
+	MaxSize int
+	agents  map[string]uint64
+}
+
+// Buffer returns a read buffer one byte larger than the maximum size, a
+// datagram filling it being truncated
+func (r *DatagramReader) Buffer() []byte {
+	return make([]byte, r.MaxSize+1)
+}
+
+// Check counts a datagram of n bytes read in the buffer, returning false if
+// it was truncated
+func (r *DatagramReader) Check(agent string, n int) bool {
+	atomic.AddUint64(&r.received, 1)
+	if n <= r.MaxSize {
+		return true
+	}
+
+	atomic.AddUint64(&r.truncated, 1)
+	r.Lock()
+	r.agents[agent]++
+	r.Unlock()
+	return false
+}
+
+// Malformed counts n flows of the datagrams failing to parse
+func (r *DatagramReader) Malformed(n int) {
+	atomic.AddUint64(&r.malformed, uint64(n))
+}
+
+// Stats returns the counts of the datagrams
+func (r *DatagramReader) Stats() DatagramStats {
+	r.Lock()
+	defer r.Unlock()
+
+	stats := DatagramStats{
+		MaxSize:   r.MaxSize,
+		Received:  atomic.LoadUint64(&r.received),
+		Truncated: atomic.LoadUint64(&r.truncated),
+		Malformed: atomic.LoadUint64(&r.malformed),
+	}
+	if len(r.agents) > 0 {
+		stats.Agents = make(map[string]uint64)
+		for agent, n := range r.agents {
+			stats.Agents[agent] = n
+		}
+	}
+	return stats
+}
+
+func NewDatagramReader(maxSize int) (*DatagramReader, error) {
+	if maxSize <= 0 || maxSize > maxUDPPayload {
+		return nil, fmt.Errorf("Invalid flow datagram size %d, expected between 1 and %d", maxSize, maxUDPPayload)
+	}
+
+	return &DatagramReader{
+		MaxSize: maxSize,
+		agents:  make(map[string]uint64),
+	}, nil
+}
+
+// NewDatagramReaderFromConfig returns the datagram reader sized by
+// analyzer.flow_max_packet_size
+func NewDatagramReaderFromConfig() (*DatagramReader, error) {
+	return NewDatagramReader(config.GetConfig().GetInt("analyzer.flow_max_packet_size"))
+}
@@ -0,0 +1,56 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package ingestion
+
+import (
+	"testing"
+)
+
+func TestDatagramReader(t *testing.T) {
+	for _, size := range []int{0, -1, 65536} {
+		if _, err := NewDatagramReader(size); err == nil {
+			t.Errorf("Expected the size %d to be rejected", size)
+		}
+	}
+
+	r, err := NewDatagramReader(4096)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// a datagram of the maximum size fits, a larger one fills the buffer
+	if n := len(r.Buffer()); n != 4097 {
+		t.Errorf("Expected a buffer of 4097 bytes, got %d", n)
+	}
+	if !r.Check("10.0.0.1", 4096) {
+		t.Error("Expected a datagram of the maximum size to be accepted")
+	}
+	if r.Check("10.0.0.1", 4097) || r.Check("10.0.0.2", 4097) || r.Check("10.0.0.2", 4097) {
+		t.Error("Expected the datagrams filling the buffer to be truncated")
+	}
+
+	stats := r.Stats()
+	if stats.MaxSize != 4096 || stats.Received != 4 || stats.Truncated != 3 || stats.Agents["10.0.0.1"] != 1 || stats.Agents["10.0.0.2"] != 2 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
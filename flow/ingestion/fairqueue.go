@@ -0,0 +1,391 @@
+/*
+ * Copyright (C) 2015 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package ingestion
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/redhat-cip/skydive/config"
+)
+
+// Credits bounds the datagrams queued for an agent, the ones beyond being
+// dropped
+type Credits struct {
+	Bytes   int
+	Packets int
+}
+
+// AgentStatus reports the ingestion of the datagrams of an agent, Share
+// being its part of the bytes processed recently, along with the sequence of
+// its frames
+type AgentStatus struct {
+	Agent      string
+	Received   uint64
+	Processed  uint64
+	OverCredit uint64
+	Starved    uint64
+	Queued     int
+	Share      float64
+	Sequence   *SequenceStatus `json:",omitempty"`
+}
+
+type datagram struct {
+	data     []byte
+	received time.Time
+}
+
+type agentQueue struct {
+	agent    string
+	credits  Credits
+	items    []datagram
+	bytes    int
+	deficit  int
+	active   bool
+	status   AgentStatus
+	windowed [2]uint64
+}
+
+// FairQueue queues the datagrams per agent and hands them to the workers
+// with a deficit round-robin, so that an agent flooding the analyzer
+// doesn't delay the datagrams of the other ones.
+type FairQueue struct {
+	sync.Mutex
+	cond         *sync.Cond
+	handler      func(agent string, data []byte)
+	workers      int
+	quantum      int
+	credits      Credits
+	agentCredits map[string]Credits
+	starvation   time.Duration
+	queues       map[string]*agentQueue
+	active       []*agentQueue
+	window       time.Duration
+	windowStart  time.Time
+	running      bool
+	draining     bool
+	wg           sync.WaitGroup
+}
+
+// queue returns the queue of the agent, created with its credits the first
+// time. Must be called under q.Lock()
+func (q *FairQueue) queue(agent string) *agentQueue {
+	aq, ok := q.queues[agent]
+	if !ok {
+		credits, ok := q.agentCredits[agent]
+		if !ok {
+			credits = q.credits
+		}
+		aq = &agentQueue{agent: agent, credits: credits, status: AgentStatus{Agent: agent}}
+		q.queues[agent] = aq
+	}
+	return aq
+}
+
+// Enqueue queues a datagram of the agent, returning false if the agent
+// exceeded its credits.
+func (q *FairQueue) Enqueue(agent string, data []byte) bool {
+	q.Lock()
+	defer q.Unlock()
+
+	aq := q.queue(agent)
+	aq.status.Received++
+
+	if (aq.credits.Packets > 0 && len(aq.items) >= aq.credits.Packets) ||
+		(aq.credits.Bytes > 0 && aq.bytes+len(data) > aq.credits.Bytes) {
+		aq.status.OverCredit++
+		return false
+	}
+
+	aq.items = append(aq.items, datagram{data: data, received: time.Now()})
+	aq.bytes += len(data)
+
+	if !aq.active {
+		aq.active = true
+		q.active = append(q.active, aq)
+	}
+	q.cond.Signal()
+
+	return true
+}
+
+// next returns the next datagram to process, the queues being served in
+// turn for up to a quantum of bytes
+func (q *FairQueue) next() (*agentQueue, datagram, bool) {
+	for len(q.active) > 0 {
+		aq := q.active[0]
+		if len(aq.items) == 0 {
+			aq.active, aq.deficit = false, 0
+			q.active = q.active[1:]
+			continue
+		}
+
+		d := aq.items[0]
+		if aq.deficit < len(d.data) {
+			aq.deficit += q.quantum
+			q.active = append(q.active[1:], aq)
+			continue
+		}
+
+		aq.deficit -= len(d.data)
+		aq.items[0] = datagram{}
+		aq.items = aq.items[1:]
+		aq.bytes -= len(d.data)
+
+		return aq, d, true
+	}
+
+	return nil, datagram{}, false
+}
+
+func (q *FairQueue) rotateWindow(now time.Time) {
+	if now.Sub(q.windowStart) < q.window {
+		return
+	}
+
+	for _, aq := range q.queues {
+		aq.windowed[1], aq.windowed[0] = aq.windowed[0], 0
+	}
+	q.windowStart = now
+}
+
+func (q *FairQueue) worker() {
+	defer q.wg.Done()
+
+	q.Lock()
+	for {
+		for q.running && !q.draining && len(q.active) == 0 {
+			q.cond.Wait()
+		}
+		if !q.running || (q.draining && len(q.active) == 0) {
+			q.Unlock()
+			return
+		}
+
+		aq, d, ok := q.next()
+		if !ok {
+			continue
+		}
+		q.Unlock()
+
+		q.handler(aq.agent, d.data)
+
+		q.Lock()
+		now := time.Now()
+		aq.status.Processed++
+		if q.starvation > 0 && now.Sub(d.received) > q.starvation {
+			aq.status.Starved++
+		}
+		q.rotateWindow(now)
+		aq.windowed[0] += uint64(len(d.data))
+	}
+}
+
+type sortByAgent []AgentStatus
+
+func (s sortByAgent) Len() int {
+	return len(s)
+}
+
+func (s sortByAgent) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s sortByAgent) Less(i, j int) bool {
+	return s[i].Agent < s[j].Agent
+}
+
+// Status returns the status of the agents sorted by name
+func (q *FairQueue) Status() []AgentStatus {
+	q.Lock()
+	defer q.Unlock()
+
+	q.rotateWindow(time.Now())
+
+	var total uint64
+	for _, aq := range q.queues {
+		total += aq.windowed[0] + aq.windowed[1]
+	}
+
+	status := make([]AgentStatus, 0, len(q.queues))
+	for _, aq := range q.queues {
+		s := aq.status
+		s.Queued = len(aq.items)
+		if total > 0 {
+			s.Share = float64(aq.windowed[0]+aq.windowed[1]) / float64(total)
+		}
+		status = append(status, s)
+	}
+	sort.Sort(sortByAgent(status))
+
+	return status
+}
+
+// Len returns the number of datagrams queued for all the agents
+func (q *FairQueue) Len() int {
+	q.Lock()
+	defer q.Unlock()
+
+	n := 0
+	for _, aq := range q.queues {
+		n += len(aq.items)
+	}
+	return n
+}
+
+func (q *FairQueue) Start() {
+	q.Lock()
+	q.running = true
+	q.windowStart = time.Now()
+	q.Unlock()
+
+	for i := 0; i < q.workers; i++ {
+		q.wg.Add(1)
+		go q.worker()
+	}
+}
+
+// Stop stops the workers, the datagrams still queued being dropped
+func (q *FairQueue) Stop() {
+	q.Lock()
+	if !q.running {
+		q.Unlock()
+		return
+	}
+	q.running = false
+	q.cond.Broadcast()
+	q.Unlock()
+
+	q.wg.Wait()
+}
+
+// Drain stops the workers once the datagrams still queued are processed,
+// nothing being enqueued anymore
+func (q *FairQueue) Drain() {
+	q.Lock()
+	if !q.running {
+		q.Unlock()
+		return
+	}
+	q.draining = true
+	q.cond.Broadcast()
+	q.Unlock()
+
+	q.wg.Wait()
+
+	q.Lock()
+	q.running, q.draining = false, false
+	q.Unlock()
+}
+
+// Restore sets the counters of the agents to the ones of the status of
+// another queue, the one this queue takes over from
+func (q *FairQueue) Restore(status []AgentStatus) {
+	q.Lock()
+	defer q.Unlock()
+
+	for _, s := range status {
+		aq := q.queue(s.Agent)
+		aq.status.Received += s.Received
+		aq.status.Processed += s.Processed
+		aq.status.OverCredit += s.OverCredit
+		aq.status.Starved += s.Starved
+	}
+}
+
+func NewFairQueue(handler func(agent string, data []byte), workers int, quantum int, credits Credits, agentCredits map[string]Credits) *FairQueue {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if quantum <= 0 {
+		quantum = 1500
+	}
+
+	q := &FairQueue{
+		handler:      handler,
+		workers:      workers,
+		quantum:      quantum,
+		credits:      credits,
+		agentCredits: agentCredits,
+		starvation:   100 * time.Millisecond,
+		queues:       make(map[string]*agentQueue),
+		window:       10 * time.Second,
+	}
+	q.cond = sync.NewCond(q)
+
+	return q
+}
+
+// NewFairQueueFromConfig returns the fair queue of the analyzer.ingestion
+// section, nil if disabled
+func NewFairQueueFromConfig(handler func(agent string, data []byte)) (*FairQueue, error) {
+	cfg := config.GetConfig()
+	if !cfg.GetBool("analyzer.ingestion.fair_queuing") {
+		return nil, nil
+	}
+
+	credits := Credits{
+		Bytes:   cfg.GetInt("analyzer.ingestion.credits.bytes"),
+		Packets: cfg.GetInt("analyzer.ingestion.credits.packets"),
+	}
+
+	agentCredits := make(map[string]Credits)
+	list, _ := cfg.Get("analyzer.ingestion.agents").([]interface{})
+	for i, item := range list {
+		entry := make(map[string]interface{})
+		switch item.(type) {
+		case map[string]interface{}:
+			entry = item.(map[string]interface{})
+		case map[interface{}]interface{}:
+			for k, v := range item.(map[interface{}]interface{}) {
+				entry[fmt.Sprintf("%v", k)] = v
+			}
+		default:
+			return nil, fmt.Errorf("Malformed agent credits at index %d", i)
+		}
+
+		agent, _ := entry["agent"].(string)
+		if agent == "" {
+			return nil, fmt.Errorf("Agent credits at index %d need an agent address", i)
+		}
+
+		c := credits
+		if v, ok := entry["bytes"].(int); ok {
+			c.Bytes = v
+		}
+		if v, ok := entry["packets"].(int); ok {
+			c.Packets = v
+		}
+		agentCredits[agent] = c
+	}
+
+	q := NewFairQueue(handler, cfg.GetInt("analyzer.ingestion.workers"), cfg.GetInt("analyzer.ingestion.quantum"), credits, agentCredits)
+	if ms := cfg.GetInt("analyzer.ingestion.starvation_delay"); ms > 0 {
+		q.starvation = time.Duration(ms) * time.Millisecond
+	}
+
+	return q, nil
+}
@@ -0,0 +1,148 @@
+/*
+ * Copyright (C) 2015 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package ingestion
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFairQueueNoisyAgent(t *testing.T) {
+	var lock sync.Mutex
+	var maxLatency time.Duration
+	sent := make(map[string]time.Time)
+	done := make(chan struct{}, 1000)
+
+	handler := func(agent string, data []byte) {
+		// slow analysis so that the noisy agent backlogs
+		time.Sleep(100 * time.Microsecond)
+
+		if agent == "quiet" {
+			lock.Lock()
+			if latency := time.Now().Sub(sent[string(data)]); latency > maxLatency {
+				maxLatency = latency
+			}
+			lock.Unlock()
+			done <- struct{}{}
+		}
+	}
+
+	q := NewFairQueue(handler, 1, 1500, Credits{}, nil)
+	q.Start()
+	defer q.Stop()
+
+	for i := 0; i < 1000; i++ {
+		q.Enqueue("noisy", make([]byte, 1000))
+	}
+
+	for i := 0; i < 10; i++ {
+		id := string([]byte{byte('a' + i)})
+		lock.Lock()
+		sent[id] = time.Now()
+		lock.Unlock()
+		q.Enqueue("quiet", []byte(id))
+
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Datagram of the quiet agent not processed")
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	// the noisy backlog takes at least 100ms to drain
+	if maxLatency > 20*time.Millisecond {
+		t.Errorf("Quiet agent starved by the noisy one: %s", maxLatency)
+	}
+
+	for _, s := range q.Status() {
+		if s.Agent == "quiet" && (s.Received != 10 || s.Processed != 10 || s.Starved != 0) {
+			t.Errorf("Wrong quiet agent status: %+v", s)
+		}
+	}
+}
+
+func TestFairQueueCredits(t *testing.T) {
+	q := NewFairQueue(func(agent string, data []byte) {}, 1, 1500, Credits{Packets: 10}, map[string]Credits{"big": {Packets: 20}})
+
+	for i := 0; i < 30; i++ {
+		q.Enqueue("small", []byte{0})
+		q.Enqueue("big", []byte{0})
+	}
+
+	status := q.Status()
+	if len(status) != 2 || status[0].Agent != "big" || status[1].Agent != "small" {
+		t.Fatalf("Wrong agents status: %+v", status)
+	}
+	if status[0].OverCredit != 10 || status[0].Queued != 20 {
+		t.Errorf("Wrong big agent status: %+v", status[0])
+	}
+	if status[1].OverCredit != 20 || status[1].Queued != 10 {
+		t.Errorf("Wrong small agent status: %+v", status[1])
+	}
+
+	q.Start()
+	defer q.Stop()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		status = q.Status()
+		if status[0].Processed == 20 && status[1].Processed == 10 {
+			if status[0].Share != float64(2)/3 {
+				t.Errorf("Wrong big agent share: %+v", status[0])
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("Queued datagrams not processed: %+v", status)
+}
+
+func TestFairQueueDrain(t *testing.T) {
+	var processed int32
+	q := NewFairQueue(func(agent string, data []byte) {
+		time.Sleep(time.Millisecond)
+		atomic.AddInt32(&processed, 1)
+	}, 2, 1500, Credits{}, nil)
+
+	q.Start()
+	for i := 0; i < 50; i++ {
+		q.Enqueue("agent", []byte{0})
+	}
+	q.Drain()
+
+	if n := atomic.LoadInt32(&processed); n != 50 {
+		t.Errorf("Expected the queued datagrams to be processed before stopping, got %d", n)
+	}
+
+	// the counters are taken over by another queue
+	other := NewFairQueue(func(agent string, data []byte) {}, 1, 1500, Credits{}, nil)
+	other.Enqueue("agent", []byte{0})
+	other.Restore(q.Status())
+
+	if status := other.Status(); len(status) != 1 || status[0].Received != 51 || status[0].Processed != 50 || status[0].Queued != 1 {
+		t.Errorf("Wrong restored status: %+v", status)
+	}
+}
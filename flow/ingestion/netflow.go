@@ -0,0 +1,183 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package ingestion
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/redhat-cip/skydive/config"
+	"github.com/redhat-cip/skydive/flow"
+	"github.com/redhat-cip/skydive/logging"
+	"github.com/redhat-cip/skydive/metrics"
+)
+
+// NetFlowStats counts the NetFlow datagrams received, the malformed ones
+// being skipped, and the flows of their records
+type NetFlowStats struct {
+	Listen    string
+	Received  uint64
+	Malformed uint64
+	Flows     uint64
+}
+
+// NetFlowListener receives the NetFlow v5 datagrams exported by the
+// routers, handing the flows of their records to the analyzer
+type NetFlowListener struct {
+	// first for the alignment of the atomic operations
+	received  uint64
+	malformed uint64
+	flows     uint64
+	sync.Mutex
+	Addr    string
+	analyze func(flows []*flow.Flow)
+	conn    *net.UDPConn
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// read reads the datagrams until the context is cancelled, a malformed
+// datagram being counted and skipped
+func (l *NetFlowListener) read(ctx context.Context, conn *net.UDPConn) {
+	data := make([]byte, maxUDPPayload)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+		n, addr, err := conn.ReadFromUDP(data)
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				continue
+			}
+			if ctx.Err() == nil {
+				metrics.UDPReadErrors.Inc()
+				logging.GetLogger().Errorf("Error while reading NetFlow datagrams: %s", err.Error())
+			}
+			return
+		}
+		atomic.AddUint64(&l.received, 1)
+
+		flows, err := flow.FlowsFromNetFlowV5(data[0:n], addr.IP.String())
+		if err != nil {
+			atomic.AddUint64(&l.malformed, 1)
+			metrics.FlowParseErrors.Inc()
+			logging.GetLogger().Errorf("Error while parsing NetFlow datagram from %s: %s", addr.IP.String(), err.Error())
+			logging.GetJournal(logging.JournalParseErrors).Record("NetFlow from %s: %s", addr.IP.String(), err.Error())
+			continue
+		}
+		atomic.AddUint64(&l.flows, uint64(len(flows)))
+
+		l.analyze(flows)
+	}
+}
+
+// Start binds the listen address and reads the datagrams
+func (l *NetFlowListener) Start() error {
+	addr, err := net.ResolveUDPAddr("udp", l.Addr)
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	l.Lock()
+	l.conn = conn
+	l.cancel = cancel
+	l.Unlock()
+
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		defer conn.Close()
+
+		l.read(ctx, conn)
+	}()
+
+	logging.GetLogger().Infof("Receiving the NetFlow datagrams on %s", conn.LocalAddr().String())
+	return nil
+}
+
+// Stop stops reading the datagrams, returning once the reader exited
+func (l *NetFlowListener) Stop() {
+	l.Lock()
+	if l.cancel != nil {
+		l.cancel()
+		// interrupts the read in progress
+		l.conn.SetReadDeadline(time.Now())
+		l.cancel = nil
+	}
+	l.Unlock()
+
+	l.wg.Wait()
+}
+
+// LocalAddr returns the address bound, nil if not started
+func (l *NetFlowListener) LocalAddr() net.Addr {
+	l.Lock()
+	defer l.Unlock()
+
+	if l.conn == nil {
+		return nil
+	}
+	return l.conn.LocalAddr()
+}
+
+// Stats returns the counts of the datagrams
+func (l *NetFlowListener) Stats() NetFlowStats {
+	return NetFlowStats{
+		Listen:    l.Addr,
+		Received:  atomic.LoadUint64(&l.received),
+		Malformed: atomic.LoadUint64(&l.malformed),
+		Flows:     atomic.LoadUint64(&l.flows),
+	}
+}
+
+func NewNetFlowListener(addr string, analyze func(flows []*flow.Flow)) *NetFlowListener {
+	return &NetFlowListener{
+		Addr:    addr,
+		analyze: analyze,
+	}
+}
+
+// NewNetFlowListenerFromConfig returns the listener of the NetFlow
+// datagrams bound to analyzer.netflow.listen, nil if not set
+func NewNetFlowListenerFromConfig(analyze func(flows []*flow.Flow)) *NetFlowListener {
+	addr := config.GetConfig().GetString("analyzer.netflow.listen")
+	if addr == "" {
+		return nil
+	}
+	return NewNetFlowListener(addr, analyze)
+}
@@ -0,0 +1,91 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package ingestion
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redhat-cip/skydive/flow"
+)
+
+func TestNetFlowListener(t *testing.T) {
+	var lock sync.Mutex
+	var received []*flow.Flow
+
+	l := NewNetFlowListener("127.0.0.1:0", func(flows []*flow.Flow) {
+		lock.Lock()
+		received = append(received, flows...)
+		lock.Unlock()
+	})
+	if err := l.Start(); err != nil {
+		t.Fatal(err.Error())
+	}
+	defer l.Stop()
+
+	conn, err := net.Dial("udp", l.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer conn.Close()
+
+	record := &flow.NetFlowV5Record{
+		SrcAddr:  net.ParseIP("192.168.0.1"),
+		DstAddr:  net.ParseIP("192.168.0.2"),
+		Packets:  3,
+		Octets:   180,
+		Protocol: 17,
+	}
+	// the malformed datagram is skipped, the next ones being still read
+	conn.Write([]byte{0, 5, 0, 1})
+	conn.Write(flow.ForgeTestNetFlowV5(t, 1500000000, record, record))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		lock.Lock()
+		n := len(received)
+		lock.Unlock()
+		if n == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected 2 flows, got %d", n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	stats := l.Stats()
+	if stats.Received != 2 || stats.Malformed != 1 || stats.Flows != 2 {
+		t.Errorf("Wrong stats: %+v", stats)
+	}
+	if received[0].Attributes[flow.FlowAttributeSource] != "netflow" || received[0].Attributes[flow.FlowAttributeNetFlowExporter] != "127.0.0.1" {
+		t.Errorf("Wrong attributes: %+v", received[0].Attributes)
+	}
+
+	l.Stop()
+	if l.LocalAddr() == nil {
+		t.Error("Expected the listener to keep its address once stopped")
+	}
+}
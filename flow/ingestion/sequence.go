@@ -0,0 +1,438 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package ingestion
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/redhat-cip/skydive/config"
+	"github.com/redhat-cip/skydive/logging"
+)
+
+// SequenceStatus reports the frames received from an agent. Epoch
+// identifies the run of the agent, its frames being numbered from 1. The
+// frames older than the receive window, or than the run of the agent, can't
+// be told apart from duplicates and are discarded as Late. The frames
+// missing from the window are Missing until they arrive, Lost once out of
+// it.
+type SequenceStatus struct {
+	Agent      string
+	Epoch      int64
+	Last       uint64
+	Received   uint64
+	Duplicates uint64
+	Reordered  uint64
+	Late       uint64
+	Lost       uint64
+	Missing    int
+}
+
+// SequenceState is the receive window of an agent, checkpointed and handed
+// over to be restored after a restart of the analyzer
+type SequenceState struct {
+	SequenceStatus
+	Floor uint64
+	Gaps  []uint64 `json:",omitempty"`
+}
+
+type agentSequence struct {
+	status SequenceStatus
+	// the frames up to floor are older than the run of the agent or than
+	// the first frame received
+	floor   uint64
+	missing map[uint64]bool
+}
+
+// Sequencer tracks the sequence numbers of the frames of each agent in a
+// window of Window frames, so that the frames retransmitted by an agent are
+// analyzed once. When set, the windows are saved to Checkpoint every
+// Interval and when stopped, then restored on creation.
+type Sequencer struct {
+	sync.Mutex
+	Window     uint64
+	Checkpoint string
+	Interval   time.Duration
+	agents     map[string]*agentSequence
+	running    bool
+	quit       chan bool
+	wg         sync.WaitGroup
+}
+
+func newAgentSequence(agent string, epoch int64, floor uint64) *agentSequence {
+	return &agentSequence{
+		status:  SequenceStatus{Agent: agent, Epoch: epoch, Last: floor},
+		floor:   floor,
+		missing: make(map[uint64]bool),
+	}
+}
+
+// advance moves the window up to seq, the frames missing below the window
+// being lost
+func (as *agentSequence) advance(seq uint64, window uint64) {
+	start := as.status.Last + 1
+	if seq > window && seq-window >= start {
+		// skipped beyond the window at once
+		as.status.Lost += seq - window + 1 - start
+		start = seq - window + 1
+	}
+	for s := start; s < seq; s++ {
+		as.missing[s] = true
+	}
+	as.status.Last = seq
+
+	if seq <= window {
+		return
+	}
+	for s := range as.missing {
+		if s <= seq-window {
+			delete(as.missing, s)
+			as.status.Lost++
+		}
+	}
+}
+
+// accept tells whether the frame seq is received for the first time
+func (as *agentSequence) accept(seq uint64, window uint64) bool {
+	switch {
+	case seq > as.status.Last:
+		as.advance(seq, window)
+	case as.missing[seq]:
+		delete(as.missing, seq)
+		as.status.Reordered++
+	case seq <= as.floor || as.status.Last-seq >= window:
+		as.status.Late++
+		return false
+	default:
+		as.status.Duplicates++
+		return false
+	}
+
+	as.status.Received++
+	return true
+}
+
+// Accept tells whether the frame seq of the run epoch of the agent is to be
+// analyzed, false for the duplicates and the late frames. A new run of the
+// agent restarts its window, the frames missing from the previous one being
+// lost.
+func (s *Sequencer) Accept(agent string, epoch int64, seq uint64) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	as, ok := s.agents[agent]
+	switch {
+	case !ok:
+		// the frames sent before aren't known
+		var floor uint64
+		if seq > 0 {
+			floor = seq - 1
+		}
+		as = newAgentSequence(agent, epoch, floor)
+		s.agents[agent] = as
+	case epoch > as.status.Epoch:
+		logging.GetLogger().Infof("Agent %s restarted, %d frames of its previous run missing", agent, len(as.missing))
+
+		status := as.status
+		status.Epoch, status.Last = epoch, 0
+		status.Lost += uint64(len(as.missing))
+		as = &agentSequence{status: status, missing: make(map[uint64]bool)}
+		s.agents[agent] = as
+	case epoch < as.status.Epoch:
+		as.status.Late++
+		return false
+	}
+
+	return as.accept(seq, s.Window)
+}
+
+type sortSequencesByAgent []SequenceStatus
+
+func (s sortSequencesByAgent) Len() int {
+	return len(s)
+}
+
+func (s sortSequencesByAgent) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s sortSequencesByAgent) Less(i, j int) bool {
+	return s[i].Agent < s[j].Agent
+}
+
+type sortStatesByAgent []SequenceState
+
+func (s sortStatesByAgent) Len() int {
+	return len(s)
+}
+
+func (s sortStatesByAgent) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s sortStatesByAgent) Less(i, j int) bool {
+	return s[i].Agent < s[j].Agent
+}
+
+type sortGaps []uint64
+
+func (s sortGaps) Len() int {
+	return len(s)
+}
+
+func (s sortGaps) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s sortGaps) Less(i, j int) bool {
+	return s[i] < s[j]
+}
+
+// Status returns the status of the agents, sorted by agent
+func (s *Sequencer) Status() []SequenceStatus {
+	s.Lock()
+	defer s.Unlock()
+
+	status := make([]SequenceStatus, 0, len(s.agents))
+	for _, as := range s.agents {
+		st := as.status
+		st.Missing = len(as.missing)
+		status = append(status, st)
+	}
+	sort.Sort(sortSequencesByAgent(status))
+
+	return status
+}
+
+// AddSequences adds the sequences of the frames to the status of the
+// agents, the agents sending only frames being added
+func AddSequences(agents []AgentStatus, sequences []SequenceStatus) []AgentStatus {
+	index := make(map[string]int)
+	for i, a := range agents {
+		index[a.Agent] = i
+	}
+
+	for i := range sequences {
+		seq := &sequences[i]
+		if j, ok := index[seq.Agent]; ok {
+			agents[j].Sequence = seq
+		} else {
+			agents = append(agents, AgentStatus{Agent: seq.Agent, Sequence: seq})
+		}
+	}
+	sort.Sort(sortByAgent(agents))
+
+	return agents
+}
+
+// State returns the receive windows of the agents
+func (s *Sequencer) State() []SequenceState {
+	s.Lock()
+	defer s.Unlock()
+
+	state := make([]SequenceState, 0, len(s.agents))
+	for _, as := range s.agents {
+		st := SequenceState{SequenceStatus: as.status, Floor: as.floor}
+		for seq := range as.missing {
+			st.Gaps = append(st.Gaps, seq)
+		}
+		sort.Sort(sortGaps(st.Gaps))
+		st.Missing = len(st.Gaps)
+		state = append(state, st)
+	}
+	sort.Sort(sortStatesByAgent(state))
+
+	return state
+}
+
+// Restore takes over the receive windows of another sequencer, the ones
+// behind the current ones being ignored
+func (s *Sequencer) Restore(state []SequenceState) {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, st := range state {
+		if as, ok := s.agents[st.Agent]; ok && (as.status.Epoch > st.Epoch || as.status.Epoch == st.Epoch && as.status.Last >= st.Last) {
+			continue
+		}
+
+		as := &agentSequence{status: st.SequenceStatus, floor: st.Floor, missing: make(map[uint64]bool)}
+		for _, seq := range st.Gaps {
+			as.missing[seq] = true
+		}
+		as.status.Missing = 0
+		s.agents[st.Agent] = as
+	}
+}
+
+// Save writes the receive windows to the checkpoint file, replaced at once
+func (s *Sequencer) Save() error {
+	if s.Checkpoint == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(s.State())
+	if err != nil {
+		return err
+	}
+
+	dir, name := filepath.Split(s.Checkpoint)
+	if dir == "" {
+		dir = "."
+	}
+	tmp, err := ioutil.TempFile(dir, "."+name)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.Checkpoint)
+}
+
+// Load restores the receive windows of the checkpoint file, if any
+func (s *Sequencer) Load() error {
+	if s.Checkpoint == "" {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(s.Checkpoint)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	var state []SequenceState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	s.Restore(state)
+
+	return nil
+}
+
+func (s *Sequencer) save() {
+	if err := s.Save(); err != nil {
+		logging.GetLogger().Errorf("Unable to checkpoint the frame sequences to %s: %s", s.Checkpoint, err.Error())
+	}
+}
+
+func (s *Sequencer) run(quit chan bool) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.save()
+		case <-quit:
+			return
+		}
+	}
+}
+
+// Start checkpoints the receive windows every Interval
+func (s *Sequencer) Start() {
+	if s.Checkpoint == "" || s.Interval <= 0 {
+		return
+	}
+
+	s.Lock()
+	s.running = true
+	s.quit = make(chan bool)
+	s.Unlock()
+
+	s.wg.Add(1)
+	go s.run(s.quit)
+}
+
+// Stop stops the checkpoints, the receive windows being checkpointed a
+// last time
+func (s *Sequencer) Stop() {
+	s.Lock()
+	running := s.running
+	if running {
+		close(s.quit)
+		s.running = false
+	}
+	s.Unlock()
+
+	s.wg.Wait()
+	if running {
+		s.save()
+	}
+}
+
+// NewSequencer returns a sequencer restoring the receive windows of the
+// checkpoint file, if set
+func NewSequencer(window uint64, checkpoint string, interval time.Duration) (*Sequencer, error) {
+	if window == 0 {
+		window = 1
+	}
+
+	s := &Sequencer{
+		Window:     window,
+		Checkpoint: checkpoint,
+		Interval:   interval,
+		agents:     make(map[string]*agentSequence),
+	}
+	if err := s.Load(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// NewSequencerFromConfig returns the sequencer of the analyzer.ingestion.sequence
+// section
+func NewSequencerFromConfig() (*Sequencer, error) {
+	cfg := config.GetConfig()
+	window := cfg.GetInt("analyzer.ingestion.sequence.window")
+	checkpoint := cfg.GetString("analyzer.ingestion.sequence.checkpoint")
+	interval := time.Duration(cfg.GetInt("analyzer.ingestion.sequence.checkpoint_interval")) * time.Second
+
+	if window < 0 {
+		window = 0
+	}
+	return NewSequencer(uint64(window), checkpoint, interval)
+}
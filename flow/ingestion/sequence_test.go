@@ -0,0 +1,185 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package ingestion
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/redhat-cip/skydive/flow"
+)
+
+// recordedFrames returns the frames of a run of an agent, each one updating
+// the cumulative counters of a few flows
+func recordedFrames(n int) map[uint64][]*flow.Flow {
+	frames := make(map[uint64][]*flow.Flow)
+	for seq := 1; seq <= n; seq++ {
+		var flows []*flow.Flow
+		for i := 0; i < 3; i++ {
+			bytes := uint64(seq * (i + 1) * 100)
+			flows = append(flows, &flow.Flow{
+				UUID: fmt.Sprintf("flow-%d", (seq+i)%5),
+				Statistics: &flow.FlowStatistics{
+					Start: 1000,
+					Last:  int64(1000 + seq),
+					Endpoints: []*flow.FlowEndpointsStatistics{
+						{
+							AB: &flow.FlowEndpointStatistics{Packets: bytes / 100, Bytes: bytes},
+							BA: &flow.FlowEndpointStatistics{Packets: bytes / 200, Bytes: bytes / 2},
+						},
+					},
+				},
+			})
+		}
+		frames[uint64(seq)] = flows
+	}
+	return frames
+}
+
+// copyFrame returns a copy of the flows of a frame, as decoded from each
+// delivery, the table keeping the flows it is given
+func copyFrame(flows []*flow.Flow) []*flow.Flow {
+	frame := make([]*flow.Flow, len(flows))
+	for i, f := range flows {
+		data, _ := f.GetData()
+		frame[i], _ = flow.FromData(data)
+	}
+	return frame
+}
+
+func tableCounters(ft *flow.Table) map[string]string {
+	counters := make(map[string]string)
+	for _, f := range ft.GetFlows() {
+		e := f.Statistics.Endpoints[0]
+		counters[f.UUID] = fmt.Sprintf("%d/%d %d/%d last %d", e.AB.Packets, e.AB.Bytes, e.BA.Packets, e.BA.Bytes, f.Statistics.Last)
+	}
+	return counters
+}
+
+func TestSequencerReplay(t *testing.T) {
+	frames := recordedFrames(14)
+
+	canonical := flow.NewTable()
+	for seq := uint64(1); seq <= 14; seq++ {
+		canonical.Update(copyFrame(frames[seq]))
+	}
+
+	// retransmitted frames, frames overtaking others and frame 9 never
+	// delivered, its flows being updated by the next frames
+	delivery := []uint64{1, 2, 4, 3, 2, 5, 6, 5, 8, 7, 10, 1, 11, 12, 12, 13, 14, 7}
+
+	s, err := NewSequencer(4, "", 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	ft := flow.NewTable()
+	for _, seq := range delivery {
+		if s.Accept("agent", 1, seq) {
+			ft.Update(copyFrame(frames[seq]))
+		}
+	}
+
+	if expected, got := tableCounters(canonical), tableCounters(ft); fmt.Sprint(expected) != fmt.Sprint(got) {
+		t.Errorf("Expected the counters of the single delivery %v, got %v", expected, got)
+	}
+
+	status := s.Status()
+	expected := SequenceStatus{Agent: "agent", Epoch: 1, Last: 14, Received: 13, Duplicates: 3, Reordered: 2, Late: 2, Lost: 1}
+	if len(status) != 1 || status[0] != expected {
+		t.Errorf("Expected the status %+v, got %+v", expected, status)
+	}
+
+	// the table alone leaves the counters as they are on a duplicate or a
+	// late update
+	ft = flow.NewTable()
+	for _, seq := range delivery {
+		ft.Update(copyFrame(frames[seq]))
+	}
+	if expected, got := tableCounters(canonical), tableCounters(ft); fmt.Sprint(expected) != fmt.Sprint(got) {
+		t.Errorf("Expected the counters of the single delivery without sequencer %v, got %v", expected, got)
+	}
+}
+
+func TestSequencerAgentRestart(t *testing.T) {
+	s, _ := NewSequencer(16, "", 0)
+
+	for _, seq := range []uint64{1, 2, 4} {
+		s.Accept("agent", 1, seq)
+	}
+
+	// the frames of the new run are numbered from 1, frame 3 of the
+	// previous run being lost
+	if !s.Accept("agent", 2, 1) || s.Accept("agent", 2, 1) {
+		t.Error("Expected the first frame of the new run to be accepted once")
+	}
+	if s.Accept("agent", 1, 3) {
+		t.Error("Expected the frame of the previous run to be discarded")
+	}
+
+	expected := SequenceStatus{Agent: "agent", Epoch: 2, Last: 1, Received: 4, Duplicates: 1, Late: 1, Lost: 1}
+	if status := s.Status(); len(status) != 1 || status[0] != expected {
+		t.Errorf("Expected the status %+v, got %+v", expected, status)
+	}
+}
+
+func TestSequencerCheckpoint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "skydive-sequence")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+	checkpoint := filepath.Join(dir, "sequences.json")
+
+	s, err := NewSequencer(16, checkpoint, 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for _, seq := range []uint64{5, 6, 8} {
+		s.Accept("agent", 1, seq)
+	}
+	if err := s.Save(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// the restarted analyzer discards the frames analyzed before, the
+	// missing one being still expected
+	if s, err = NewSequencer(16, checkpoint, 0); err != nil {
+		t.Fatal(err.Error())
+	}
+	for _, seq := range []uint64{5, 6, 8} {
+		if s.Accept("agent", 1, seq) {
+			t.Errorf("Frame %d analyzed again after the restart", seq)
+		}
+	}
+	if !s.Accept("agent", 1, 7) {
+		t.Error("Expected the missing frame to be accepted")
+	}
+
+	expected := SequenceStatus{Agent: "agent", Epoch: 1, Last: 8, Received: 4, Duplicates: 3, Reordered: 1}
+	if status := s.Status(); len(status) != 1 || status[0] != expected {
+		t.Errorf("Expected the status %+v, got %+v", expected, status)
+	}
+}
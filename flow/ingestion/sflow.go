@@ -0,0 +1,255 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package ingestion
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+
+	"github.com/redhat-cip/skydive/config"
+	"github.com/redhat-cip/skydive/flow"
+	"github.com/redhat-cip/skydive/logging"
+	"github.com/redhat-cip/skydive/metrics"
+)
+
+// SFlowStats counts the sFlow datagrams received, the malformed ones being
+// skipped, the packet samples of the other ones and the agents sending them
+type SFlowStats struct {
+	Listen    string
+	Received  uint64
+	Malformed uint64
+	Samples   uint64
+	Agents    int
+}
+
+// SFlowListener receives the sFlow v5 datagrams of the switches. The
+// sampled packets are aggregated into flows per sFlow agent, as a skydive
+// agent does, the flows being handed to the analyzer once updated or
+// expired.
+type SFlowListener struct {
+	// first for the alignment of the atomic operations
+	received  uint64
+	malformed uint64
+	samples   uint64
+	agents    int32
+	sync.Mutex
+	Addr    string
+	Expire  time.Duration
+	Update  time.Duration
+	analyze func(flows []*flow.Flow)
+	conn    *net.UDPConn
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// handOver gives copies of the flows of an agent to the analyzer, the flows
+// of the table being updated by the next samples
+func (l *SFlowListener) handOver(agent string, flows []*flow.Flow) {
+	if len(flows) == 0 {
+		return
+	}
+
+	copies := make([]*flow.Flow, len(flows))
+	for i, f := range flows {
+		c := proto.Clone(f).(*flow.Flow)
+		if c.Attributes == nil {
+			c.Attributes = make(map[string]string)
+		}
+		c.Attributes[flow.FlowAttributeSource] = "sflow"
+		c.Attributes[flow.FlowAttributeSFlowAgent] = agent
+		copies[i] = c
+	}
+	l.analyze(copies)
+}
+
+func (l *SFlowListener) newTable(agent string) *flow.Table {
+	table := flow.NewTable()
+	handOver := func(flows []*flow.Flow) {
+		l.handOver(agent, flows)
+	}
+	table.RegisterExpire(handOver, l.Expire, l.Expire)
+	table.RegisterUpdated(handOver, l.Update, l.Update)
+	return table
+}
+
+// read reads the datagrams until the context is cancelled, a malformed
+// datagram being counted and skipped. The tables of the agents are only
+// accessed by the reader, which expires them, the remaining flows being
+// handed over once stopped.
+func (l *SFlowListener) read(ctx context.Context, conn *net.UDPConn) {
+	data := make([]byte, maxUDPPayload)
+	tables := make(map[string]*flow.Table)
+
+	expireTicker := time.NewTicker(l.Expire)
+	defer expireTicker.Stop()
+	updateTicker := time.NewTicker(l.Update)
+	defer updateTicker.Stop()
+
+	defer func() {
+		for _, table := range tables {
+			table.Clear(true)
+			table.UnregisterAll()
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-expireTicker.C:
+			for _, table := range tables {
+				table.Expire(now)
+			}
+		case now := <-updateTicker.C:
+			for _, table := range tables {
+				table.Updated(now)
+			}
+		default:
+		}
+
+		conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+		n, addr, err := conn.ReadFromUDP(data)
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				continue
+			}
+			if ctx.Err() == nil {
+				metrics.UDPReadErrors.Inc()
+				logging.GetLogger().Errorf("Error while reading sFlow datagrams: %s", err.Error())
+			}
+			return
+		}
+		atomic.AddUint64(&l.received, 1)
+
+		d, err := flow.DecodeSFlowDatagram(data[0:n])
+		if err != nil {
+			atomic.AddUint64(&l.malformed, 1)
+			metrics.FlowParseErrors.Inc()
+			logging.GetLogger().Errorf("Error while parsing sFlow datagram from %s: %s", addr.IP.String(), err.Error())
+			logging.GetJournal(logging.JournalParseErrors).Record("sFlow from %s: %s", addr.IP.String(), err.Error())
+			continue
+		}
+		atomic.AddUint64(&l.samples, uint64(len(d.Samples)))
+
+		agent := d.AgentAddress.String()
+		table, ok := tables[agent]
+		if !ok {
+			table = l.newTable(agent)
+			tables[agent] = table
+			atomic.StoreInt32(&l.agents, int32(len(tables)))
+		}
+		flow.FlowsFromSFlowDatagram(table, d, nil)
+	}
+}
+
+// Start binds the listen address and reads the datagrams
+func (l *SFlowListener) Start() error {
+	addr, err := net.ResolveUDPAddr("udp", l.Addr)
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	l.Lock()
+	l.conn = conn
+	l.cancel = cancel
+	l.Unlock()
+
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		defer conn.Close()
+
+		l.read(ctx, conn)
+	}()
+
+	logging.GetLogger().Infof("Receiving the sFlow datagrams on %s", conn.LocalAddr().String())
+	return nil
+}
+
+// Stop stops reading the datagrams, returning once the reader handed the
+// remaining flows over
+func (l *SFlowListener) Stop() {
+	l.Lock()
+	if l.cancel != nil {
+		l.cancel()
+		// interrupts the read in progress
+		l.conn.SetReadDeadline(time.Now())
+		l.cancel = nil
+	}
+	l.Unlock()
+
+	l.wg.Wait()
+}
+
+// LocalAddr returns the address bound, nil if not started
+func (l *SFlowListener) LocalAddr() net.Addr {
+	l.Lock()
+	defer l.Unlock()
+
+	if l.conn == nil {
+		return nil
+	}
+	return l.conn.LocalAddr()
+}
+
+// Stats returns the counts of the datagrams
+func (l *SFlowListener) Stats() SFlowStats {
+	return SFlowStats{
+		Listen:    l.Addr,
+		Received:  atomic.LoadUint64(&l.received),
+		Malformed: atomic.LoadUint64(&l.malformed),
+		Samples:   atomic.LoadUint64(&l.samples),
+		Agents:    int(atomic.LoadInt32(&l.agents)),
+	}
+}
+
+func NewSFlowListener(addr string, expire, update time.Duration, analyze func(flows []*flow.Flow)) *SFlowListener {
+	return &SFlowListener{
+		Addr:    addr,
+		Expire:  expire,
+		Update:  update,
+		analyze: analyze,
+	}
+}
+
+// NewSFlowListenerFromConfig returns the listener of the sFlow datagrams
+// bound to analyzer.sflow.listen, nil if not set. The flows are expired and
+// updated as the ones of the agents.
+func NewSFlowListenerFromConfig(analyze func(flows []*flow.Flow)) *SFlowListener {
+	addr := config.GetConfig().GetString("analyzer.sflow.listen")
+	if addr == "" {
+		return nil
+	}
+	return NewSFlowListener(addr, config.GetAgentExpire(), config.GetAgentUpdate(), analyze)
+}
@@ -0,0 +1,95 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package ingestion
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redhat-cip/skydive/flow"
+)
+
+func TestSFlowListener(t *testing.T) {
+	var lock sync.Mutex
+	var received []*flow.Flow
+
+	l := NewSFlowListener("127.0.0.1:0", time.Minute, time.Minute, func(flows []*flow.Flow) {
+		lock.Lock()
+		received = append(received, flows...)
+		lock.Unlock()
+	})
+	if err := l.Start(); err != nil {
+		t.Fatal(err.Error())
+	}
+	defer l.Stop()
+
+	conn, err := net.Dial("udp", l.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer conn.Close()
+
+	// the malformed datagram is skipped, the next ones being still read
+	conn.Write([]byte{0, 0, 0, 5, 0, 0})
+	conn.Write(flow.ForgeTestSFlowDatagram(t, net.ParseIP("10.0.0.1"), 10, 1))
+	conn.Write(flow.ForgeTestSFlowDatagram(t, net.ParseIP("10.0.0.2"), 10, 1))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for l.Stats().Samples != 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected 2 samples: %+v", l.Stats())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	stats := l.Stats()
+	if stats.Received != 3 || stats.Malformed != 1 || stats.Agents != 2 {
+		t.Errorf("Wrong stats: %+v", stats)
+	}
+
+	// the flows in progress are handed over once stopped
+	l.Stop()
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	if len(received) != 2 {
+		t.Fatalf("Expected a flow per agent, got %d", len(received))
+	}
+	agents := make(map[string]bool)
+	for _, f := range received {
+		if f.Attributes[flow.FlowAttributeSource] != "sflow" {
+			t.Errorf("Wrong attributes: %+v", f.Attributes)
+		}
+		agents[f.Attributes[flow.FlowAttributeSFlowAgent]] = true
+
+		if ipv4 := f.Statistics.GetEndpointsType(flow.FlowEndpointType_IPV4); ipv4.AB.Packets != 10 {
+			t.Errorf("Expected the statistics to be scaled by the sampling rate: %+v", ipv4.AB)
+		}
+	}
+	if !agents["10.0.0.1"] || !agents["10.0.0.2"] {
+		t.Errorf("Expected the flows of both agents: %v", agents)
+	}
+}
@@ -0,0 +1,182 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package ingestion
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"github.com/redhat-cip/skydive/config"
+)
+
+// WorkerPoolStats reports the datagrams handed to the workers, Dropped
+// counting the ones received while the queue was full
+type WorkerPoolStats struct {
+	Workers   int
+	QueueSize int
+	Queued    int
+	Processed uint64
+	Dropped   uint64
+}
+
+type agentDatagram struct {
+	agent string
+	data  []byte
+}
+
+// WorkerPool hands the datagrams to workers through a bounded queue, so
+// that the socket is read while the flows are analyzed. Unlike the fair
+// queue, the datagrams are served in the order they are received.
+type WorkerPool struct {
+	processed uint64
+	dropped   uint64
+	lock      sync.Mutex
+	handler   func(agent string, data []byte)
+	workers   int
+	queue     chan agentDatagram
+	quit      chan struct{}
+	drain     chan struct{}
+	running   bool
+	wg        sync.WaitGroup
+}
+
+// Enqueue queues a datagram of the agent, returning false if the queue is
+// full, the datagram being dropped
+func (p *WorkerPool) Enqueue(agent string, data []byte) bool {
+	select {
+	case p.queue <- agentDatagram{agent: agent, data: data}:
+		return true
+	default:
+		atomic.AddUint64(&p.dropped, 1)
+		return false
+	}
+}
+
+func (p *WorkerPool) handle(d agentDatagram) {
+	p.handler(d.agent, d.data)
+	atomic.AddUint64(&p.processed, 1)
+}
+
+func (p *WorkerPool) worker(quit, drain chan struct{}) {
+	defer p.wg.Done()
+
+	for {
+		select {
+		case d := <-p.queue:
+			p.handle(d)
+		case <-quit:
+			return
+		case <-drain:
+			for {
+				select {
+				case d := <-p.queue:
+					p.handle(d)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// Len returns the number of datagrams queued
+func (p *WorkerPool) Len() int {
+	return len(p.queue)
+}
+
+// Stats returns the counters of the pool
+func (p *WorkerPool) Stats() WorkerPoolStats {
+	return WorkerPoolStats{
+		Workers:   p.workers,
+		QueueSize: cap(p.queue),
+		Queued:    len(p.queue),
+		Processed: atomic.LoadUint64(&p.processed),
+		Dropped:   atomic.LoadUint64(&p.dropped),
+	}
+}
+
+func (p *WorkerPool) Start() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.running {
+		return
+	}
+	p.running = true
+	p.quit, p.drain = make(chan struct{}), make(chan struct{})
+
+	for i := 0; i < p.workers; i++ {
+		p.wg.Add(1)
+		go p.worker(p.quit, p.drain)
+	}
+}
+
+// stop signals the workers through the given channel and waits for them
+func (p *WorkerPool) stop(signal func() chan struct{}) {
+	p.lock.Lock()
+	if !p.running {
+		p.lock.Unlock()
+		return
+	}
+	p.running = false
+	close(signal())
+	p.lock.Unlock()
+
+	p.wg.Wait()
+}
+
+// Stop stops the workers, the datagrams still queued being kept until the
+// pool is started again
+func (p *WorkerPool) Stop() {
+	p.stop(func() chan struct{} { return p.quit })
+}
+
+// Drain stops the workers once the datagrams still queued are processed
+func (p *WorkerPool) Drain() {
+	p.stop(func() chan struct{} { return p.drain })
+}
+
+// NewWorkerPool returns a pool of workers calling the handler, one per CPU
+// if workers is not positive
+func NewWorkerPool(handler func(agent string, data []byte), workers int, queueSize int) *WorkerPool {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if queueSize <= 0 {
+		queueSize = 1024
+	}
+
+	return &WorkerPool{
+		handler: handler,
+		workers: workers,
+		queue:   make(chan agentDatagram, queueSize),
+	}
+}
+
+// NewWorkerPoolFromConfig returns the worker pool of the analyzer.ingestion
+// section
+func NewWorkerPoolFromConfig(handler func(agent string, data []byte)) *WorkerPool {
+	cfg := config.GetConfig()
+	return NewWorkerPool(handler, cfg.GetInt("analyzer.ingestion.workers"), cfg.GetInt("analyzer.ingestion.queue_size"))
+}
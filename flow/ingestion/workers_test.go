@@ -0,0 +1,117 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package ingestion
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWorkerPoolConcurrency(t *testing.T) {
+	var running, maxRunning int32
+	var wg sync.WaitGroup
+
+	handler := func(agent string, data []byte) {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			max := atomic.LoadInt32(&maxRunning)
+			if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		wg.Done()
+	}
+
+	p := NewWorkerPool(handler, 4, 100)
+	p.Start()
+	defer p.Stop()
+
+	wg.Add(20)
+	for i := 0; i < 20; i++ {
+		if !p.Enqueue("agent", []byte{byte(i)}) {
+			t.Fatal("Datagram should be queued")
+		}
+	}
+	wg.Wait()
+
+	if maxRunning < 2 || maxRunning > 4 {
+		t.Errorf("Expected up to 4 datagrams analyzed concurrently, got %d", maxRunning)
+	}
+	if stats := p.Stats(); stats.Processed != 20 || stats.Dropped != 0 || stats.Workers != 4 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestWorkerPoolQueueFull(t *testing.T) {
+	p := NewWorkerPool(func(agent string, data []byte) {}, 1, 2)
+
+	// not started, nothing is dequeued
+	for i := 0; i < 3; i++ {
+		p.Enqueue("agent", []byte{byte(i)})
+	}
+
+	stats := p.Stats()
+	if stats.Queued != 2 || stats.QueueSize != 2 || stats.Dropped != 1 {
+		t.Errorf("Expected 2 datagrams queued and 1 dropped, got %+v", stats)
+	}
+	if p.Len() != 2 {
+		t.Errorf("Expected a queue length of 2, got %d", p.Len())
+	}
+}
+
+func TestWorkerPoolDrain(t *testing.T) {
+	var processed int32
+	p := NewWorkerPool(func(agent string, data []byte) {
+		time.Sleep(time.Millisecond)
+		atomic.AddInt32(&processed, 1)
+	}, 2, 100)
+
+	for i := 0; i < 50; i++ {
+		p.Enqueue("agent", []byte{byte(i)})
+	}
+	p.Start()
+	p.Drain()
+
+	if processed != 50 || p.Len() != 0 {
+		t.Errorf("Expected the 50 datagrams processed, got %d, %d left", processed, p.Len())
+	}
+
+	// restarted after the drain
+	done := make(chan struct{})
+	p = NewWorkerPool(func(agent string, data []byte) { close(done) }, 1, 1)
+	p.Start()
+	p.Drain()
+	p.Start()
+	defer p.Stop()
+	p.Enqueue("agent", nil)
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Datagram not processed once the pool restarted")
+	}
+}
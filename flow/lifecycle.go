@@ -0,0 +1,197 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package flow
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// FlowEventType is the step of the lifecycle of a flow an event reports
+type FlowEventType string
+
+const (
+	// FlowCreated is emitted when a flow enters the table
+	FlowCreated FlowEventType = "FLOW_CREATED"
+	// FlowUpdated is emitted at the cadence of the update policy
+	FlowUpdated FlowEventType = "FLOW_UPDATED"
+	// FlowExpired is emitted with the final counters of the flow leaving
+	// the table
+	FlowExpired FlowEventType = "FLOW_EXPIRED"
+)
+
+// FlowEvent is a step of the lifecycle of a flow, along with a copy of the
+// flow at this step
+type FlowEvent struct {
+	Type FlowEventType
+	Flow *Flow
+}
+
+// FlowEventListener receives the lifecycle events of the flows, in the
+// order of the steps for a given flow. Called under the lock of the flow
+// table, it must not block.
+type FlowEventListener interface {
+	OnFlowEvents(events []*FlowEvent)
+}
+
+// FlowEventTypes is the set of event types a listener subscribes to
+type FlowEventTypes map[FlowEventType]bool
+
+// ParseFlowEventTypes returns the set of the event types, given by their
+// name (FLOW_CREATED) or their short name (created)
+func ParseFlowEventTypes(names []string) (FlowEventTypes, error) {
+	types := make(FlowEventTypes)
+	for _, name := range names {
+		t := FlowEventType(strings.ToUpper(name))
+		if !strings.HasPrefix(string(t), "FLOW_") {
+			t = "FLOW_" + t
+		}
+
+		switch t {
+		case FlowCreated, FlowUpdated, FlowExpired:
+			types[t] = true
+		default:
+			return nil, fmt.Errorf("Unknown flow event type: %s", name)
+		}
+	}
+	return types, nil
+}
+
+// Filter returns the events of the set types
+func (t FlowEventTypes) Filter(events []*FlowEvent) []*FlowEvent {
+	var filtered []*FlowEvent
+	for _, e := range events {
+		if t[e.Type] {
+			filtered = append(filtered, e)
+		}
+	}
+	return filtered
+}
+
+// FlowUpdatePolicy sets when a FlowUpdated event is emitted: once Interval
+// seconds elapsed since the last event of the flow, or once its bytes grew
+// by BytesRatio. An update emits an event when neither is set.
+type FlowUpdatePolicy struct {
+	Interval   int64
+	BytesRatio float64
+}
+
+type flowEventSubscription struct {
+	listener FlowEventListener
+	types    FlowEventTypes
+}
+
+// FlowEventDispatcher forwards the lifecycle events of a flow table to its
+// subscribers, each one receiving the event types it chose
+type FlowEventDispatcher struct {
+	sync.RWMutex
+	subscriptions []flowEventSubscription
+}
+
+// Subscribe adds a listener of the events of the given types
+func (d *FlowEventDispatcher) Subscribe(listener FlowEventListener, types FlowEventTypes) {
+	d.Lock()
+	d.subscriptions = append(d.subscriptions, flowEventSubscription{listener: listener, types: types})
+	d.Unlock()
+}
+
+// Subscribed returns whether the dispatcher has listeners
+func (d *FlowEventDispatcher) Subscribed() bool {
+	d.RLock()
+	defer d.RUnlock()
+	return len(d.subscriptions) > 0
+}
+
+func (d *FlowEventDispatcher) OnFlowEvents(events []*FlowEvent) {
+	d.RLock()
+	defer d.RUnlock()
+
+	for _, sub := range d.subscriptions {
+		if filtered := sub.types.Filter(events); len(filtered) > 0 {
+			sub.listener.OnFlowEvents(filtered)
+		}
+	}
+}
+
+func NewFlowEventDispatcher() *FlowEventDispatcher {
+	return &FlowEventDispatcher{}
+}
+
+// emittedFlow is the state of a flow at its last event
+type emittedFlow struct {
+	time  int64
+	bytes uint64
+}
+
+// flowBytes returns the bytes of the outermost layer of the flow
+func flowBytes(f *Flow) uint64 {
+	if f.Statistics == nil || len(f.Statistics.Endpoints) == 0 {
+		return 0
+	}
+	var bytes uint64
+	if e := f.Statistics.Endpoints[0]; e != nil {
+		if e.AB != nil {
+			bytes += e.AB.Bytes
+		}
+		if e.BA != nil {
+			bytes += e.BA.Bytes
+		}
+	}
+	return bytes
+}
+
+func flowPackets(f *Flow) uint64 {
+	if f.Statistics == nil || len(f.Statistics.Endpoints) == 0 {
+		return 0
+	}
+	var packets uint64
+	if e := f.Statistics.Endpoints[0]; e != nil {
+		if e.AB != nil {
+			packets += e.AB.Packets
+		}
+		if e.BA != nil {
+			packets += e.BA.Packets
+		}
+	}
+	return packets
+}
+
+// newFlowEvent returns an event with a copy of the flow, the flows of the
+// table being updated in place
+func newFlowEvent(t FlowEventType, f *Flow) *FlowEvent {
+	cp := *f
+	return &FlowEvent{Type: t, Flow: &cp}
+}
+
+// updateDue returns whether the update of a flow emits a FlowUpdated event
+func (p FlowUpdatePolicy) updateDue(last emittedFlow, f *Flow, now int64) bool {
+	if p.Interval <= 0 && p.BytesRatio <= 0 {
+		return true
+	}
+	if p.Interval > 0 && now-last.time >= p.Interval {
+		return true
+	}
+	bytes := flowBytes(f)
+	return p.BytesRatio > 0 && bytes > last.bytes && float64(bytes) >= float64(last.bytes)*(1+p.BytesRatio)
+}
@@ -0,0 +1,155 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package live
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/redhat-cip/skydive/flow"
+	shttp "github.com/redhat-cip/skydive/http"
+	"github.com/redhat-cip/skydive/logging"
+)
+
+type liveFlow struct {
+	flow *flow.Flow
+	seq  uint64
+	// a delta has been lost, the flow may be incomplete until the next
+	// full refresh
+	stale bool
+}
+
+// LiveFlowClient is a reference client of the live flow stream, it
+// reconstructs the full flows from the delta events
+type LiveFlowClient struct {
+	sync.RWMutex
+	shttp.DefaultWSClientEventHandler
+	wsClient *shttp.WSAsyncClient
+	delta    bool
+	flows    map[string]*liveFlow
+	// only the flows matching the filter are streamed when set
+	Filter string
+}
+
+func (c *LiveFlowClient) OnConnected() {
+	c.Lock()
+	c.flows = make(map[string]*liveFlow)
+	c.Unlock()
+
+	msg, err := newMessage("Subscribe", "", &Subscription{Delta: c.delta, Filter: c.Filter})
+	if err != nil {
+		return
+	}
+	c.wsClient.SendWSMessage(msg)
+}
+
+func (c *LiveFlowClient) OnMessage(m shttp.WSMessage) {
+	if m.Namespace != Namespace {
+		return
+	}
+
+	if err := c.Apply(m); err != nil {
+		logging.GetLogger().Errorf("Unable to apply live flow event: %s", err.Error())
+	}
+}
+
+// Apply updates the flows with an event of the live flow stream
+func (c *LiveFlowClient) Apply(m shttp.WSMessage) error {
+	if m.Obj == nil {
+		return fmt.Errorf("%s event without object", m.Type)
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	switch m.Type {
+	case "FlowFull", "FlowCreated", "FlowUpdated":
+		var u FlowUpdate
+		if err := json.Unmarshal([]byte(*m.Obj), &u); err != nil {
+			return err
+		}
+		if u.Flow == nil {
+			return fmt.Errorf("%s event without flow", m.Type)
+		}
+		c.flows[u.Flow.UUID] = &liveFlow{flow: u.Flow, seq: u.Seq}
+	case "FlowDelta":
+		var d FlowDelta
+		if err := json.Unmarshal([]byte(*m.Obj), &d); err != nil {
+			return err
+		}
+		// flow not received in full yet, waiting for the next refresh
+		lf, ok := c.flows[d.UUID]
+		if !ok {
+			return nil
+		}
+		if d.Seq != lf.seq+1 {
+			lf.stale = true
+		}
+		d.Apply(lf.flow)
+		lf.seq = d.Seq
+	case "FlowExpired":
+		delete(c.flows, m.UUID)
+	default:
+		return fmt.Errorf("unknown event type %s", m.Type)
+	}
+
+	return nil
+}
+
+// GetFlow returns the reconstructed flow and whether updates were lost since
+// its last full refresh, the flow must not be modified
+func (c *LiveFlowClient) GetFlow(uuid string) (f *flow.Flow, stale bool) {
+	c.RLock()
+	defer c.RUnlock()
+
+	if lf, ok := c.flows[uuid]; ok {
+		return lf.flow, lf.stale
+	}
+	return nil, false
+}
+
+// GetFlows returns the reconstructed flows, they must not be modified
+func (c *LiveFlowClient) GetFlows() []*flow.Flow {
+	c.RLock()
+	defer c.RUnlock()
+
+	flows := make([]*flow.Flow, 0, len(c.flows))
+	for _, lf := range c.flows {
+		flows = append(flows, lf.flow)
+	}
+	return flows
+}
+
+func NewLiveFlowClient(wsClient *shttp.WSAsyncClient, delta bool) *LiveFlowClient {
+	c := &LiveFlowClient{
+		wsClient: wsClient,
+		delta:    delta,
+		flows:    make(map[string]*liveFlow),
+	}
+	if wsClient != nil {
+		wsClient.AddEventHandler(c)
+	}
+
+	return c
+}
@@ -0,0 +1,205 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package live
+
+import (
+	"github.com/redhat-cip/skydive/flow"
+)
+
+// fields of a flow that can change once it has been sent
+const (
+	dirtyLast uint32 = 1 << iota
+	dirtyDuration
+	dirtyEndpoints
+	dirtyRoles
+	dirtyNodes
+	dirtyAttributes
+)
+
+// FlowDelta holds the fields of a flow changed since the previous update,
+// unchanged fields are omitted. Seq is the number of updates of the flow
+// sent so far, a client seeing a gap has to wait for the next full refresh.
+type FlowDelta struct {
+	UUID          string
+	Seq           uint64
+	Last          *int64                          `json:",omitempty"`
+	Duration      *int64                          `json:",omitempty"`
+	Endpoints     []*flow.FlowEndpointsStatistics `json:",omitempty"`
+	ARole         *string                         `json:",omitempty"`
+	BRole         *string                         `json:",omitempty"`
+	ProbeNodeUUID *string                         `json:",omitempty"`
+	IfSrcNodeUUID *string                         `json:",omitempty"`
+	IfDstNodeUUID *string                         `json:",omitempty"`
+	Attributes    map[string]string               `json:",omitempty"`
+}
+
+// FlowUpdate is a full flow along with its update sequence number
+type FlowUpdate struct {
+	Seq  uint64
+	Flow *flow.Flow
+}
+
+type endpointCounters struct {
+	abPackets, abBytes, baPackets, baBytes uint64
+}
+
+// flowState is what has been sent of a flow, compared field by field with
+// the next update to flag the dirty fields
+type flowState struct {
+	seq        uint64
+	last       int64
+	duration   int64
+	endpoints  []endpointCounters
+	aRole      string
+	bRole      string
+	probe      string
+	ifSrc      string
+	ifDst      string
+	attributes map[string]string
+	// attributes added or changed by the last update
+	changed map[string]string
+}
+
+func counters(e *flow.FlowEndpointsStatistics) (c endpointCounters) {
+	if e.AB != nil {
+		c.abPackets, c.abBytes = e.AB.Packets, e.AB.Bytes
+	}
+	if e.BA != nil {
+		c.baPackets, c.baBytes = e.BA.Packets, e.BA.Bytes
+	}
+	return
+}
+
+func newFlowState(f *flow.Flow) *flowState {
+	st := &flowState{seq: 1, attributes: make(map[string]string)}
+	st.update(f)
+	return st
+}
+
+// update records the fields of the flow and returns the dirty ones
+func (st *flowState) update(f *flow.Flow) (dirty uint32) {
+	if s := f.GetStatistics(); s != nil {
+		if s.Last != st.last {
+			st.last = s.Last
+			dirty |= dirtyLast
+		}
+
+		if len(s.Endpoints) != len(st.endpoints) {
+			st.endpoints = make([]endpointCounters, len(s.Endpoints))
+			dirty |= dirtyEndpoints
+		}
+		for i, e := range s.Endpoints {
+			if c := counters(e); c != st.endpoints[i] {
+				st.endpoints[i] = c
+				dirty |= dirtyEndpoints
+			}
+		}
+	}
+
+	if f.Duration != st.duration {
+		st.duration = f.Duration
+		dirty |= dirtyDuration
+	}
+
+	if f.A_Role != st.aRole || f.B_Role != st.bRole {
+		st.aRole, st.bRole = f.A_Role, f.B_Role
+		dirty |= dirtyRoles
+	}
+
+	if f.ProbeNodeUUID != st.probe || f.IfSrcNodeUUID != st.ifSrc || f.IfDstNodeUUID != st.ifDst {
+		st.probe, st.ifSrc, st.ifDst = f.ProbeNodeUUID, f.IfSrcNodeUUID, f.IfDstNodeUUID
+		dirty |= dirtyNodes
+	}
+
+	st.changed = nil
+	for k, v := range f.Attributes {
+		if old, ok := st.attributes[k]; !ok || old != v {
+			st.attributes[k] = v
+			if st.changed == nil {
+				st.changed = make(map[string]string)
+			}
+			st.changed[k] = v
+			dirty |= dirtyAttributes
+		}
+	}
+
+	return
+}
+
+// delta builds the delta of the dirty fields of the flow
+func (st *flowState) delta(f *flow.Flow, dirty uint32) *FlowDelta {
+	d := &FlowDelta{UUID: f.UUID, Seq: st.seq}
+
+	if dirty&dirtyLast != 0 {
+		d.Last = &st.last
+	}
+	if dirty&dirtyDuration != 0 {
+		d.Duration = &st.duration
+	}
+	if dirty&dirtyEndpoints != 0 {
+		d.Endpoints = f.Statistics.Endpoints
+	}
+	if dirty&dirtyRoles != 0 {
+		d.ARole, d.BRole = &st.aRole, &st.bRole
+	}
+	if dirty&dirtyNodes != 0 {
+		d.ProbeNodeUUID, d.IfSrcNodeUUID, d.IfDstNodeUUID = &st.probe, &st.ifSrc, &st.ifDst
+	}
+	if dirty&dirtyAttributes != 0 {
+		d.Attributes = st.changed
+	}
+
+	return d
+}
+
+// Apply updates the flow with the fields of the delta
+func (d *FlowDelta) Apply(f *flow.Flow) {
+	if d.Last != nil || d.Endpoints != nil {
+		if f.Statistics == nil {
+			f.Statistics = &flow.FlowStatistics{}
+		}
+		if d.Last != nil {
+			f.Statistics.Last = *d.Last
+		}
+		if d.Endpoints != nil {
+			f.Statistics.Endpoints = d.Endpoints
+		}
+	}
+	if d.Duration != nil {
+		f.Duration = *d.Duration
+	}
+	if d.ARole != nil {
+		f.A_Role, f.B_Role = *d.ARole, *d.BRole
+	}
+	if d.ProbeNodeUUID != nil {
+		f.ProbeNodeUUID, f.IfSrcNodeUUID, f.IfDstNodeUUID = *d.ProbeNodeUUID, *d.IfSrcNodeUUID, *d.IfDstNodeUUID
+	}
+	if len(d.Attributes) > 0 {
+		if f.Attributes == nil {
+			f.Attributes = make(map[string]string)
+		}
+		for k, v := range d.Attributes {
+			f.Attributes[k] = v
+		}
+	}
+}
@@ -0,0 +1,298 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package live
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/redhat-cip/skydive/config"
+	"github.com/redhat-cip/skydive/flow"
+	shttp "github.com/redhat-cip/skydive/http"
+	"github.com/redhat-cip/skydive/logging"
+)
+
+const (
+	Namespace = "LiveFlow"
+)
+
+// Subscription is sent by the clients to negotiate the delta events, clients
+// not subscribing receive the full flows. Clients giving lifecycle event
+// types (created, updated, expired) receive only these events instead, as
+// FlowCreated, FlowUpdated and FlowExpired messages holding the flow.
+// Clients giving a filter, of the form Key=Value,Key=Value as the alerts
+// ones, receive only the flows matching it.
+type Subscription struct {
+	Delta  bool
+	Events []string `json:",omitempty"`
+	Filter string   `json:",omitempty"`
+}
+
+type sender interface {
+	SendWSMessage(msg shttp.WSMessage)
+}
+
+type subscriber struct {
+	delta  bool
+	events flow.FlowEventTypes
+	filter flow.Filter
+	// flows sent in full to the subscriber, the following updates being
+	// sent as deltas
+	seen map[string]bool
+}
+
+func (sub *subscriber) match(f *flow.Flow) bool {
+	return sub.filter == nil || sub.filter.Match(f)
+}
+
+// LiveFlowServer streams the updates of the flows of the analyzer flow
+// table. The subscribers negotiating the deltas receive a flow in full
+// first then only the changed fields, with a full refresh every refresh
+// updates. All the subscribers receive a FlowExpired event once a flow
+// leaves the table.
+type LiveFlowServer struct {
+	sync.RWMutex
+	WSServer    *shttp.WSServer
+	refresh     uint64
+	subscribers map[sender]*subscriber
+	states      map[string]*flowState
+}
+
+func newMessage(t string, uuid string, obj interface{}) (shttp.WSMessage, error) {
+	b, err := json.Marshal(obj)
+	if err != nil {
+		return shttp.WSMessage{}, err
+	}
+	raw := json.RawMessage(b)
+
+	return shttp.WSMessage{
+		Namespace: Namespace,
+		Type:      t,
+		UUID:      uuid,
+		Obj:       &raw,
+	}, nil
+}
+
+// OnFlowsUpdated sends the updated flows to the subscribers
+func (s *LiveFlowServer) OnFlowsUpdated(flows []*flow.Flow) {
+	s.Lock()
+	defer s.Unlock()
+
+	if len(s.subscribers) == 0 {
+		return
+	}
+
+	for _, f := range flows {
+		var dirty uint32
+		refresh := false
+
+		st, ok := s.states[f.UUID]
+		if !ok {
+			st = newFlowState(f)
+			s.states[f.UUID] = st
+			refresh = true
+		} else if dirty = st.update(f); dirty != 0 {
+			st.seq++
+			refresh = st.seq%s.refresh == 0
+		}
+
+		var full, delta *shttp.WSMessage
+		for c, sub := range s.subscribers {
+			if sub.events != nil || !sub.match(f) {
+				continue
+			}
+
+			var msg *shttp.WSMessage
+			switch {
+			case !sub.delta || refresh || !sub.seen[f.UUID]:
+				if full == nil {
+					m, err := newMessage("FlowFull", f.UUID, &FlowUpdate{Seq: st.seq, Flow: f})
+					if err != nil {
+						logging.GetLogger().Errorf("Unable to encode flow %s: %s", f.UUID, err.Error())
+						break
+					}
+					full = &m
+				}
+				if sub.delta {
+					sub.seen[f.UUID] = true
+				}
+				msg = full
+			case dirty != 0:
+				if delta == nil {
+					m, err := newMessage("FlowDelta", f.UUID, st.delta(f, dirty))
+					if err != nil {
+						logging.GetLogger().Errorf("Unable to encode flow delta %s: %s", f.UUID, err.Error())
+						break
+					}
+					delta = &m
+				}
+				msg = delta
+			}
+
+			if msg != nil {
+				c.SendWSMessage(*msg)
+			}
+		}
+	}
+}
+
+// OnFlowsExpired notifies the subscribers of the flows leaving the table
+func (s *LiveFlowServer) OnFlowsExpired(flows []*flow.Flow) {
+	s.Lock()
+	defer s.Unlock()
+
+	if len(s.subscribers) == 0 {
+		return
+	}
+
+	for _, f := range flows {
+		delete(s.states, f.UUID)
+
+		msg, _ := newMessage("FlowExpired", f.UUID, f.UUID)
+		for c, sub := range s.subscribers {
+			// the flows sent to the subscriber before no longer matching
+			// are expired as well
+			if sub.events != nil || !(sub.match(f) || sub.seen[f.UUID]) {
+				continue
+			}
+			delete(sub.seen, f.UUID)
+			c.SendWSMessage(msg)
+		}
+	}
+}
+
+var lifecycleMessages = map[flow.FlowEventType]string{
+	flow.FlowCreated: "FlowCreated",
+	flow.FlowUpdated: "FlowUpdated",
+	flow.FlowExpired: "FlowExpired",
+}
+
+// OnFlowEvents sends the lifecycle events to the subscribers of their type
+func (s *LiveFlowServer) OnFlowEvents(events []*flow.FlowEvent) {
+	s.RLock()
+	defer s.RUnlock()
+
+	for _, e := range events {
+		var msg *shttp.WSMessage
+		for c, sub := range s.subscribers {
+			if !sub.events[e.Type] || !sub.match(e.Flow) {
+				continue
+			}
+
+			if msg == nil {
+				m, err := newMessage(lifecycleMessages[e.Type], e.Flow.UUID, &FlowUpdate{Flow: e.Flow})
+				if err != nil {
+					logging.GetLogger().Errorf("Unable to encode the event of flow %s: %s", e.Flow.UUID, err.Error())
+					break
+				}
+				msg = &m
+			}
+			c.SendWSMessage(*msg)
+		}
+	}
+}
+
+func (s *LiveFlowServer) subscribe(c sender, delta bool, filter flow.Filter) {
+	s.Lock()
+	s.subscribers[c] = &subscriber{delta: delta, filter: filter, seen: make(map[string]bool)}
+	s.Unlock()
+}
+
+func (s *LiveFlowServer) subscribeEvents(c sender, events flow.FlowEventTypes, filter flow.Filter) {
+	s.Lock()
+	s.subscribers[c] = &subscriber{events: events, filter: filter, seen: make(map[string]bool)}
+	s.Unlock()
+}
+
+func (s *LiveFlowServer) unsubscribe(c sender) {
+	s.Lock()
+	delete(s.subscribers, c)
+	if len(s.subscribers) == 0 {
+		s.states = make(map[string]*flowState)
+	}
+	s.Unlock()
+}
+
+func (s *LiveFlowServer) OnMessage(c *shttp.WSClient, m shttp.WSMessage) {
+	if m.Namespace != Namespace || m.Type != "Subscribe" || m.Obj == nil {
+		return
+	}
+
+	var sub Subscription
+	if err := json.Unmarshal([]byte(*m.Obj), &sub); err != nil {
+		logging.GetLogger().Errorf("Unable to decode live flow subscription: %s", err.Error())
+		return
+	}
+
+	var filter flow.Filter
+	if sub.Filter != "" {
+		var err error
+		if filter, err = flow.ParseFilter(sub.Filter); err != nil {
+			logging.GetLogger().Errorf("Invalid live flow subscription filter: %s", err.Error())
+			return
+		}
+	}
+
+	if len(sub.Events) > 0 {
+		events, err := flow.ParseFlowEventTypes(sub.Events)
+		if err != nil {
+			logging.GetLogger().Errorf("Invalid live flow subscription: %s", err.Error())
+			return
+		}
+		s.subscribeEvents(c, events, filter)
+		return
+	}
+
+	// the flows are sent again in full after a subscription change
+	s.subscribe(c, sub.Delta, filter)
+}
+
+func (s *LiveFlowServer) OnRegisterClient(c *shttp.WSClient) {
+	s.subscribe(c, false, nil)
+}
+
+func (s *LiveFlowServer) OnUnregisterClient(c *shttp.WSClient) {
+	s.unsubscribe(c)
+}
+
+func NewLiveFlowServer(server *shttp.WSServer, refresh int) *LiveFlowServer {
+	if refresh <= 0 {
+		refresh = 1
+	}
+
+	s := &LiveFlowServer{
+		WSServer:    server,
+		refresh:     uint64(refresh),
+		subscribers: make(map[sender]*subscriber),
+		states:      make(map[string]*flowState),
+	}
+	if server != nil {
+		server.AddEventHandler(s)
+	}
+
+	return s
+}
+
+func NewLiveFlowServerFromConfig(server *shttp.WSServer) *LiveFlowServer {
+	return NewLiveFlowServer(server, config.GetConfig().GetInt("analyzer.live_flows.refresh"))
+}
@@ -0,0 +1,290 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package live
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	"github.com/redhat-cip/skydive/flow"
+	shttp "github.com/redhat-cip/skydive/http"
+)
+
+// wsPipe forwards the messages sent by the server to a client, through
+// their JSON encoding as over the websocket
+type wsPipe struct {
+	t      *testing.T
+	client *LiveFlowClient
+	types  map[string]int
+	drop   bool
+}
+
+func (p *wsPipe) SendWSMessage(msg shttp.WSMessage) {
+	p.types[msg.Type]++
+	if p.drop {
+		return
+	}
+
+	m, err := shttp.UnmarshalWSMessage(msg.Marshal())
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	if err := p.client.Apply(m); err != nil {
+		p.t.Fatal(err)
+	}
+}
+
+func newPipe(t *testing.T, s *LiveFlowServer, delta bool) *wsPipe {
+	p := &wsPipe{t: t, client: NewLiveFlowClient(nil, delta), types: make(map[string]int)}
+	s.subscribe(p, delta, nil)
+	return p
+}
+
+func newLiveFlow(i int) *flow.Flow {
+	return &flow.Flow{
+		UUID:       "flow" + strconv.Itoa(i),
+		LayersPath: "Ethernet/IPv4/TCP",
+		Statistics: &flow.FlowStatistics{
+			Start: 100,
+			Last:  100,
+			Endpoints: []*flow.FlowEndpointsStatistics{
+				{
+					Type: flow.FlowEndpointType_IPV4,
+					AB:   &flow.FlowEndpointStatistics{Value: "10.0.0.1", Packets: 1, Bytes: 64},
+					BA:   &flow.FlowEndpointStatistics{Value: "10.0.0.2"},
+				},
+			},
+		},
+	}
+}
+
+func tick(f *flow.Flow) {
+	f.Statistics.Last++
+	f.Duration = f.Statistics.Last - f.Statistics.Start
+	f.Statistics.Endpoints[0].AB.Packets++
+	f.Statistics.Endpoints[0].AB.Bytes += 64
+}
+
+func assertReconstructed(t *testing.T, p *wsPipe, flows ...*flow.Flow) {
+	for _, f := range flows {
+		r, _ := p.client.GetFlow(f.UUID)
+		if r == nil {
+			t.Fatalf("flow %s not reconstructed", f.UUID)
+		}
+
+		expected, _ := json.Marshal(f)
+		got, _ := json.Marshal(r)
+		if string(expected) != string(got) {
+			t.Fatalf("flow %s badly reconstructed, expected %s, got %s", f.UUID, expected, got)
+		}
+	}
+}
+
+func TestLiveFlowServer_reconstruct(t *testing.T) {
+	s := NewLiveFlowServer(nil, 100)
+	deltaPipe := newPipe(t, s, true)
+	fullPipe := newPipe(t, s, false)
+
+	f1, f2 := newLiveFlow(1), newLiveFlow(2)
+	s.OnFlowsUpdated([]*flow.Flow{f1, f2})
+
+	for i := 0; i < 10; i++ {
+		tick(f1)
+		switch i {
+		case 3:
+			f1.A_Role, f1.B_Role = "client", "server"
+		case 5:
+			f1.Attributes = map[string]string{"app": "http"}
+			f2.IfSrcNodeUUID = "node1"
+		case 7:
+			f1.Attributes["tenant"] = "demo"
+			f1.Attributes["app"] = "https"
+		}
+		s.OnFlowsUpdated([]*flow.Flow{f1, f2})
+
+		assertReconstructed(t, deltaPipe, f1, f2)
+		assertReconstructed(t, fullPipe, f1, f2)
+	}
+
+	// initial full objects then only deltas, f2 being changed once
+	if deltaPipe.types["FlowFull"] != 2 || deltaPipe.types["FlowDelta"] != 11 {
+		t.Fatalf("unexpected events for the delta subscriber: %v", deltaPipe.types)
+	}
+	if fullPipe.types["FlowFull"] != 22 || fullPipe.types["FlowDelta"] != 0 {
+		t.Fatalf("unexpected events for the full subscriber: %v", fullPipe.types)
+	}
+}
+
+func TestLiveFlowServer_delta(t *testing.T) {
+	st := newFlowState(newLiveFlow(1))
+
+	f := newLiveFlow(1)
+	f.Statistics.Last = 101
+	f.Attributes = map[string]string{"app": "http"}
+	dirty := st.update(f)
+
+	b, _ := json.Marshal(st.delta(f, dirty))
+	var d map[string]interface{}
+	json.Unmarshal(b, &d)
+
+	for _, field := range []string{"UUID", "Seq", "Last", "Attributes"} {
+		if _, ok := d[field]; !ok {
+			t.Errorf("%s missing from the delta %s", field, b)
+		}
+	}
+	for _, field := range []string{"Duration", "Endpoints", "ARole", "IfSrcNodeUUID"} {
+		if _, ok := d[field]; ok {
+			t.Errorf("unchanged %s sent in the delta %s", field, b)
+		}
+	}
+
+	if dirty = st.update(f); dirty != 0 {
+		t.Errorf("no field should be dirty, got %b", dirty)
+	}
+}
+
+func TestLiveFlowServer_refresh(t *testing.T) {
+	s := NewLiveFlowServer(nil, 4)
+	p := newPipe(t, s, true)
+
+	f := newLiveFlow(1)
+	s.OnFlowsUpdated([]*flow.Flow{f})
+
+	// a lost delta makes the flow stale until the next full refresh
+	tick(f)
+	p.drop = true
+	s.OnFlowsUpdated([]*flow.Flow{f})
+	p.drop = false
+
+	f.Attributes = map[string]string{"app": "http"}
+	s.OnFlowsUpdated([]*flow.Flow{f})
+	if _, stale := p.client.GetFlow(f.UUID); !stale {
+		t.Fatal("flow should be stale after a lost delta")
+	}
+
+	tick(f)
+	s.OnFlowsUpdated([]*flow.Flow{f})
+	if _, stale := p.client.GetFlow(f.UUID); stale {
+		t.Fatal("flow should have been refreshed")
+	}
+	assertReconstructed(t, p, f)
+
+	if p.types["FlowFull"] != 2 || p.types["FlowDelta"] != 2 {
+		t.Fatalf("unexpected events: %v", p.types)
+	}
+}
+
+func TestLiveFlowServer_subscription(t *testing.T) {
+	s := NewLiveFlowServer(nil, 100)
+	first := newPipe(t, s, true)
+
+	f := newLiveFlow(1)
+	s.OnFlowsUpdated([]*flow.Flow{f})
+	tick(f)
+	s.OnFlowsUpdated([]*flow.Flow{f})
+
+	// a late subscriber gets the flow in full first
+	late := newPipe(t, s, true)
+	tick(f)
+	s.OnFlowsUpdated([]*flow.Flow{f})
+
+	assertReconstructed(t, first, f)
+	assertReconstructed(t, late, f)
+	if late.types["FlowFull"] != 1 || late.types["FlowDelta"] != 0 {
+		t.Fatalf("unexpected events for the late subscriber: %v", late.types)
+	}
+
+	s.OnFlowsExpired([]*flow.Flow{f})
+	for _, p := range []*wsPipe{first, late} {
+		if len(p.client.GetFlows()) != 0 {
+			t.Fatal("expired flow still present")
+		}
+	}
+
+	s.unsubscribe(first)
+	s.unsubscribe(late)
+	if len(s.states) != 0 {
+		t.Fatal("flow states should be released without subscribers")
+	}
+}
+
+func TestLiveFlowServer_lifecycleEvents(t *testing.T) {
+	s := NewLiveFlowServer(nil, 100)
+	p := &wsPipe{t: t, client: NewLiveFlowClient(nil, false), types: make(map[string]int)}
+	s.subscribeEvents(p, flow.FlowEventTypes{flow.FlowCreated: true, flow.FlowExpired: true}, nil)
+
+	f := newLiveFlow(1)
+	s.OnFlowEvents([]*flow.FlowEvent{{Type: flow.FlowCreated, Flow: f}, {Type: flow.FlowUpdated, Flow: f}})
+	assertReconstructed(t, p, f)
+
+	// the lifecycle subscribers don't receive the stream of the updates
+	s.OnFlowsUpdated([]*flow.Flow{f})
+	s.OnFlowsExpired([]*flow.Flow{f})
+
+	s.OnFlowEvents([]*flow.FlowEvent{{Type: flow.FlowExpired, Flow: f}})
+	if r, _ := p.client.GetFlow(f.UUID); r != nil {
+		t.Error("expired flow should be removed")
+	}
+
+	if p.types["FlowCreated"] != 1 || p.types["FlowExpired"] != 1 || len(p.types) != 2 {
+		t.Errorf("Wrong messages sent: %v", p.types)
+	}
+}
+
+func TestLiveFlowServer_filter(t *testing.T) {
+	s := NewLiveFlowServer(nil, 100)
+	all := newPipe(t, s, true)
+	filtered := &wsPipe{t: t, client: NewLiveFlowClient(nil, true), types: make(map[string]int)}
+	filter, err := flow.ParseFilter("IPV4.A=10.0.0.3")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	s.subscribe(filtered, true, filter)
+
+	f1, f2 := newLiveFlow(1), newLiveFlow(2)
+	f2.Statistics.Endpoints[0].AB.Value = "10.0.0.3"
+	s.OnFlowsUpdated([]*flow.Flow{f1, f2})
+	tick(f2)
+	s.OnFlowsUpdated([]*flow.Flow{f1, f2})
+
+	assertReconstructed(t, all, f1, f2)
+	assertReconstructed(t, filtered, f2)
+	if len(filtered.client.GetFlows()) != 1 {
+		t.Fatalf("Expected only the matching flow, got %d flows", len(filtered.client.GetFlows()))
+	}
+
+	// the expirations of the flows not sent aren't either
+	s.OnFlowsExpired([]*flow.Flow{f1, f2})
+	if filtered.types["FlowExpired"] != 1 || all.types["FlowExpired"] != 2 {
+		t.Errorf("Expected only the expiration of the matching flow, got %v", filtered.types)
+	}
+
+	// the lifecycle events are filtered too
+	events := &wsPipe{t: t, client: NewLiveFlowClient(nil, false), types: make(map[string]int)}
+	s.subscribeEvents(events, flow.FlowEventTypes{flow.FlowCreated: true}, filter)
+	s.OnFlowEvents([]*flow.FlowEvent{{Type: flow.FlowCreated, Flow: f1}, {Type: flow.FlowCreated, Flow: f2}})
+	if events.types["FlowCreated"] != 1 {
+		t.Errorf("Expected the creation of the matching flow only, got %v", events.types)
+	}
+}
@@ -0,0 +1,229 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package mappings
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/redhat-cip/skydive/config"
+	"github.com/redhat-cip/skydive/flow"
+)
+
+// private, shared, loopback, link local and multicast ranges, their
+// addresses are left untagged
+var nonPublicNetworks = []string{
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"224.0.0.0/3",
+}
+
+// ASNDatabase maps IPv4 prefixes to the autonomous systems announcing them,
+// the lookups returning the longest matching prefix
+type ASNDatabase struct {
+	// prefixes by length, the keys being the masked networks
+	prefixes [33]map[uint32]uint32
+	private  []*net.IPNet
+}
+
+func ipv4ToUint32(ip net.IP) (uint32, bool) {
+	if ip = ip.To4(); ip == nil {
+		return 0, false
+	}
+	return binary.BigEndian.Uint32(ip), true
+}
+
+func (db *ASNDatabase) add(prefix string, asn uint32) error {
+	_, network, err := net.ParseCIDR(prefix)
+	if err != nil {
+		return err
+	}
+
+	addr, ok := ipv4ToUint32(network.IP)
+	if !ok {
+		// IPv6 prefixes are ignored, the flows having IPv4 endpoints only
+		return nil
+	}
+
+	length, _ := network.Mask.Size()
+	if db.prefixes[length] == nil {
+		db.prefixes[length] = make(map[uint32]uint32)
+	}
+	db.prefixes[length][addr] = asn
+
+	return nil
+}
+
+// Lookup returns the ASN of a public IPv4 address
+func (db *ASNDatabase) Lookup(address string) (uint32, bool) {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return 0, false
+	}
+
+	for _, network := range db.private {
+		if network.Contains(ip) {
+			return 0, false
+		}
+	}
+
+	addr, ok := ipv4ToUint32(ip)
+	if !ok {
+		return 0, false
+	}
+
+	for length := 32; length >= 0; length-- {
+		prefixes := db.prefixes[length]
+		if prefixes == nil {
+			continue
+		}
+
+		mask := uint32(0)
+		if length > 0 {
+			mask = ^uint32(0) << uint(32-length)
+		}
+		if asn, ok := prefixes[addr&mask]; ok {
+			return asn, true
+		}
+	}
+
+	return 0, false
+}
+
+// LoadASNDatabase reads a prefix to ASN table, one "prefix asn" pair per
+// line as in the ipasn files built from the BGP RIB dumps, the lines
+// starting with ; or # being comments
+func LoadASNDatabase(path string) (*ASNDatabase, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	db := &ASNDatabase{}
+	for _, cidr := range nonPublicNetworks {
+		_, network, _ := net.ParseCIDR(cidr)
+		db.private = append(db.private, network)
+	}
+
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, ";") || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected a prefix and an ASN", path, line)
+		}
+
+		asn, err := strconv.ParseUint(strings.TrimPrefix(strings.ToUpper(fields[1]), "AS"), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid ASN %s", path, line, fields[1])
+		}
+
+		if err := db.add(fields[0], uint32(asn)); err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", path, line, err.Error())
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// ASNFlowEnhancer tags the flows with the ASN of their public IPv4
+// endpoints
+type ASNFlowEnhancer struct {
+	Database *ASNDatabase
+}
+
+func (e *ASNFlowEnhancer) tag(f *flow.Flow, attribute string, address string) {
+	if _, ok := f.Attributes[attribute]; ok {
+		return
+	}
+
+	if asn, ok := e.Database.Lookup(address); ok {
+		if f.Attributes == nil {
+			f.Attributes = make(map[string]string)
+		}
+		f.Attributes[attribute] = strconv.FormatUint(uint64(asn), 10)
+	}
+}
+
+func (e *ASNFlowEnhancer) Enhance(f *flow.Flow) {
+	if f.Statistics == nil {
+		return
+	}
+
+	ipv4 := f.Statistics.GetEndpointsType(flow.FlowEndpointType_IPV4)
+	if ipv4 == nil {
+		return
+	}
+
+	if ipv4.AB != nil {
+		e.tag(f, flow.FlowAttributeASNA, ipv4.AB.Value)
+	}
+	if ipv4.BA != nil {
+		e.tag(f, flow.FlowAttributeASNB, ipv4.BA.Value)
+	}
+}
+
+func (e *ASNFlowEnhancer) DeclaredAttributes() map[string]string {
+	return map[string]string{flow.FlowAttributeASNA: AttributeNumber, flow.FlowAttributeASNB: AttributeNumber}
+}
+
+func NewASNFlowEnhancer(db *ASNDatabase) *ASNFlowEnhancer {
+	return &ASNFlowEnhancer{
+		Database: db,
+	}
+}
+
+// NewASNFlowEnhancerFromConfig returns the enhancer of the configured
+// database, nil if none is configured
+func NewASNFlowEnhancerFromConfig() (*ASNFlowEnhancer, error) {
+	path := config.GetConfig().GetString("analyzer.asn_database")
+	if path == "" {
+		return nil, nil
+	}
+
+	db, err := LoadASNDatabase(path)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to load the ASN database: %s", err.Error())
+	}
+
+	return NewASNFlowEnhancer(db), nil
+}
@@ -0,0 +1,117 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package mappings
+
+import (
+	"testing"
+
+	"github.com/redhat-cip/skydive/flow"
+)
+
+func newIPv4Flow(a string, b string) *flow.Flow {
+	return &flow.Flow{
+		UUID:       a + "-" + b,
+		LayersPath: "Ethernet/IPv4/TCP",
+		Statistics: &flow.FlowStatistics{
+			Endpoints: []*flow.FlowEndpointsStatistics{
+				{
+					Type: flow.FlowEndpointType_IPV4,
+					AB:   &flow.FlowEndpointStatistics{Value: a},
+					BA:   &flow.FlowEndpointStatistics{Value: b},
+				},
+			},
+		},
+	}
+}
+
+func TestASNDatabaseLookup(t *testing.T) {
+	db, err := LoadASNDatabase("testdata/ipasn.dat")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expected := map[string]uint32{
+		"8.8.8.8":        15169,
+		"8.1.2.3":        3356,
+		"1.1.1.1":        13335,
+		"93.184.216.34":  15133,
+		"9.9.9.9":        0,
+		"10.0.0.1":       0,
+		"192.168.1.1":    0,
+		"172.16.0.1":     0,
+		"127.0.0.1":      0,
+		"2001:db8::1":    0,
+		"not an address": 0,
+	}
+
+	for address, asn := range expected {
+		got, found := db.Lookup(address)
+		if found != (asn != 0) || got != asn {
+			t.Errorf("%s: expected ASN %d, got %d (found: %v)", address, asn, got, found)
+		}
+	}
+}
+
+func TestASNDatabaseInvalid(t *testing.T) {
+	_, err := LoadASNDatabase("testdata/ipasn_invalid.dat")
+	if err == nil || err.Error() != "testdata/ipasn_invalid.dat:2: invalid ASN cloudflare" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestASNFlowEnhancer(t *testing.T) {
+	db, err := LoadASNDatabase("testdata/ipasn.dat")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	e := NewASNFlowEnhancer(db)
+
+	f := newIPv4Flow("192.168.0.10", "8.8.8.8")
+	e.Enhance(f)
+	if _, ok := f.Attributes[flow.FlowAttributeASNA]; ok {
+		t.Errorf("private endpoint should be left untagged: %v", f.Attributes)
+	}
+	if f.Attributes[flow.FlowAttributeASNB] != "15169" {
+		t.Errorf("expected ASN 15169 for 8.8.8.8: %v", f.Attributes)
+	}
+
+	f = newIPv4Flow("1.1.1.1", "93.184.216.34")
+	e.Enhance(f)
+	if f.Attributes[flow.FlowAttributeASNA] != "13335" || f.Attributes[flow.FlowAttributeASNB] != "15133" {
+		t.Errorf("both endpoints should be tagged: %v", f.Attributes)
+	}
+
+	filter, err := flow.ParseFilter("Attributes.ASN_B=15133")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !filter.Match(f) {
+		t.Error("tagged flow should match the ASN filter")
+	}
+
+	f = newIPv4Flow("10.0.0.1", "10.0.0.2")
+	e.Enhance(f)
+	if len(f.Attributes) != 0 {
+		t.Errorf("private flow should be left untagged: %v", f.Attributes)
+	}
+}
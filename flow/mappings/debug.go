@@ -0,0 +1,219 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package mappings
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/redhat-cip/skydive/flow"
+	shttp "github.com/redhat-cip/skydive/http"
+	"github.com/redhat-cip/skydive/logging"
+)
+
+const (
+	DebugNamespace = "FlowDebug"
+)
+
+// FlowFieldChange is a field modified by an enhancer, nested fields are
+// dot separated, ex: Statistics.Start
+type FlowFieldChange struct {
+	Field string
+	Old   interface{} `json:",omitempty"`
+	New   interface{} `json:",omitempty"`
+}
+
+// FlowStageRecord holds the changes done by one stage of the pipeline
+type FlowStageRecord struct {
+	Stage   string
+	Changes []FlowFieldChange
+}
+
+// FlowDebugRecord is a flow after enhancement along with the changes done
+// by each stage
+type FlowDebugRecord struct {
+	Flow   *flow.Flow
+	Stages []FlowStageRecord
+}
+
+type FlowDebugListener interface {
+	// Tracing returns whether the enhancements are to be traced, the
+	// tracing being skipped when nobody listens
+	Tracing() bool
+	OnFlowEnhanced(record *FlowDebugRecord)
+}
+
+type FlowDebugServer struct {
+	sync.RWMutex
+	shttp.DefaultWSServerEventHandler
+	WSServer *shttp.WSServer
+	clients  map[*shttp.WSClient]bool
+}
+
+func stageName(enhancer FlowEnhancer) string {
+	t := reflect.TypeOf(enhancer)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Name()
+}
+
+func flattenFlowFields(prefix string, value interface{}, fields map[string]interface{}) {
+	if m, ok := value.(map[string]interface{}); ok {
+		for k, v := range m {
+			name := k
+			if prefix != "" {
+				name = prefix + "." + k
+			}
+			flattenFlowFields(name, v, fields)
+		}
+		return
+	}
+	fields[prefix] = value
+}
+
+// snapshotFlow returns the flow fields as they are serialized
+func snapshotFlow(f *flow.Flow) (map[string]interface{}, error) {
+	data, err := json.Marshal(f)
+	if err != nil {
+		return nil, err
+	}
+
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]interface{})
+	flattenFlowFields("", m, fields)
+	return fields, nil
+}
+
+func diffFlowFields(before, after map[string]interface{}) []FlowFieldChange {
+	var names []string
+	for name := range before {
+		names = append(names, name)
+	}
+	for name := range after {
+		if _, ok := before[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	changes := []FlowFieldChange{}
+	for _, name := range names {
+		if o, n := before[name], after[name]; !reflect.DeepEqual(o, n) {
+			changes = append(changes, FlowFieldChange{Field: name, Old: o, New: n})
+		}
+	}
+	return changes
+}
+
+// traceEnhanceFlow applies the enhancers recording the changes of each one.
+// The flow is fully enhanced even if the changes can't be recorded.
+func (fe *FlowMappingPipeline) traceEnhanceFlow(f *flow.Flow) (*FlowDebugRecord, error) {
+	record := &FlowDebugRecord{Flow: f}
+
+	before, err := snapshotFlow(f)
+	for _, enhancer := range fe.Enhancers {
+		fe.enhance(enhancer, f)
+		if err != nil {
+			continue
+		}
+
+		var after map[string]interface{}
+		if after, err = snapshotFlow(f); err != nil {
+			continue
+		}
+
+		record.Stages = append(record.Stages, FlowStageRecord{
+			Stage:   stageName(enhancer),
+			Changes: diffFlowFields(before, after),
+		})
+		before = after
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// Tracing returns whether websocket clients are registered
+func (s *FlowDebugServer) Tracing() bool {
+	s.RLock()
+	defer s.RUnlock()
+	return len(s.clients) > 0
+}
+
+func (s *FlowDebugServer) OnFlowEnhanced(record *FlowDebugRecord) {
+	s.RLock()
+	defer s.RUnlock()
+
+	if len(s.clients) == 0 {
+		return
+	}
+
+	b, err := json.Marshal(record)
+	if err != nil {
+		logging.GetLogger().Errorf("Unable to encode flow debug record: %s", err.Error())
+		return
+	}
+	raw := json.RawMessage(b)
+
+	msg := shttp.WSMessage{
+		Namespace: DebugNamespace,
+		Type:      "FlowEnhanced",
+		UUID:      record.Flow.UUID,
+		Obj:       &raw,
+	}
+
+	for c := range s.clients {
+		c.SendWSMessage(msg)
+	}
+}
+
+func (s *FlowDebugServer) OnRegisterClient(c *shttp.WSClient) {
+	s.Lock()
+	s.clients[c] = true
+	s.Unlock()
+}
+
+func (s *FlowDebugServer) OnUnregisterClient(c *shttp.WSClient) {
+	s.Lock()
+	delete(s.clients, c)
+	s.Unlock()
+}
+
+func NewFlowDebugServer(server *shttp.WSServer) *FlowDebugServer {
+	s := &FlowDebugServer{
+		WSServer: server,
+		clients:  make(map[*shttp.WSClient]bool),
+	}
+	server.AddEventHandler(s)
+
+	return s
+}
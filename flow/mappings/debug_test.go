@@ -0,0 +1,106 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package mappings
+
+import (
+	"testing"
+
+	"github.com/redhat-cip/skydive/flow"
+)
+
+type testNodeEnhancer struct {
+}
+
+func (e *testNodeEnhancer) Enhance(f *flow.Flow) {
+	if f.IfSrcNodeUUID == "" {
+		f.IfSrcNodeUUID = "node-src"
+	}
+}
+
+type testDebugListener struct {
+	records []*FlowDebugRecord
+	idle    bool
+}
+
+func (l *testDebugListener) Tracing() bool {
+	return !l.idle
+}
+
+func (l *testDebugListener) OnFlowEnhanced(record *FlowDebugRecord) {
+	l.records = append(l.records, record)
+}
+
+func TestFlowDebugNotTracing(t *testing.T) {
+	pipeline := NewFlowMappingPipeline(&testNodeEnhancer{})
+
+	listener := &testDebugListener{idle: true}
+	pipeline.SetDebugListener(listener)
+
+	f := &flow.Flow{UUID: "flow-uuid", LayersPath: "Ethernet/IPv4"}
+	pipeline.Enhance([]*flow.Flow{f})
+
+	if f.IfSrcNodeUUID != "node-src" {
+		t.Fatalf("Flow not enhanced: %+v", f)
+	}
+	if len(listener.records) != 0 {
+		t.Errorf("Expected no debug record without tracing, got %d", len(listener.records))
+	}
+}
+
+func TestFlowDebugRecord(t *testing.T) {
+	pipeline := NewFlowMappingPipeline(&testNodeEnhancer{}, &testNodeEnhancer{})
+
+	listener := &testDebugListener{}
+	pipeline.SetDebugListener(listener)
+
+	f := &flow.Flow{UUID: "flow-uuid", LayersPath: "Ethernet/IPv4"}
+	pipeline.Enhance([]*flow.Flow{f})
+
+	if f.IfSrcNodeUUID != "node-src" {
+		t.Fatalf("Flow not enhanced: %+v", f)
+	}
+
+	if len(listener.records) != 1 {
+		t.Fatalf("One debug record expected, got %d", len(listener.records))
+	}
+
+	record := listener.records[0]
+	if record.Flow != f || len(record.Stages) != 2 {
+		t.Fatalf("Wrong debug record: %+v", record)
+	}
+
+	first := record.Stages[0]
+	if first.Stage != "testNodeEnhancer" || len(first.Changes) != 1 {
+		t.Fatalf("Wrong first stage record: %+v", first)
+	}
+
+	change := first.Changes[0]
+	if change.Field != "IfSrcNodeUUID" || change.Old != nil || change.New != "node-src" {
+		t.Errorf("Wrong change record: %+v", change)
+	}
+
+	// the second stage has nothing left to do
+	if len(record.Stages[1].Changes) != 0 {
+		t.Errorf("No change expected for the second stage: %+v", record.Stages[1])
+	}
+}
@@ -0,0 +1,148 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package mappings
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/redhat-cip/skydive/config"
+	"github.com/redhat-cip/skydive/flow"
+)
+
+// the ClientHello fields making up the JA3 string, in order
+var ja3Fields = []string{
+	flow.FlowAttributeTLSVersion,
+	flow.FlowAttributeTLSCiphers,
+	flow.FlowAttributeTLSExtensions,
+	flow.FlowAttributeTLSCurves,
+	flow.FlowAttributeTLSPointFormats,
+}
+
+// isGREASE returns whether the value is one of the reserved GREASE values
+// (0x0a0a, 0x1a1a, ... 0xfafa) sent by the clients to exercise the
+// extensibility of the servers, ignored by JA3
+func isGREASE(value uint64) bool {
+	return value&0x0f0f == 0x0a0a && value>>8 == value&0xff
+}
+
+// ja3List returns the dash separated list of values without the GREASE ones
+func ja3List(list string) (string, error) {
+	if list == "" {
+		return "", nil
+	}
+
+	var values []string
+	for _, v := range strings.Split(list, "-") {
+		value, err := strconv.ParseUint(v, 10, 16)
+		if err != nil {
+			return "", fmt.Errorf("invalid value %q", v)
+		}
+		if !isGREASE(value) {
+			values = append(values, v)
+		}
+	}
+
+	return strings.Join(values, "-"), nil
+}
+
+// JA3String returns the JA3 string of the ClientHello fields, the TLS
+// version followed by the lists of the ciphers, extensions, elliptic curves
+// and point formats
+func JA3String(version string, ciphers string, extensions string, curves string, pointFormats string) (string, error) {
+	if _, err := strconv.ParseUint(version, 10, 16); err != nil {
+		return "", fmt.Errorf("invalid TLS version %q", version)
+	}
+
+	fields := []string{version}
+	for _, list := range []string{ciphers, extensions, curves, pointFormats} {
+		l, err := ja3List(list)
+		if err != nil {
+			return "", err
+		}
+		fields = append(fields, l)
+	}
+
+	return strings.Join(fields, ","), nil
+}
+
+// JA3Hash returns the JA3 fingerprint of a JA3 string, its MD5 hash
+func JA3Hash(ja3 string) string {
+	sum := md5.Sum([]byte(ja3))
+	return hex.EncodeToString(sum[:])
+}
+
+// JA3FlowEnhancer fingerprints the TLS clients of the flows with JA3, from
+// the ClientHello fields given by the agents. The fingerprints given by the
+// agents are kept, the flows without ClientHello are left untouched.
+type JA3FlowEnhancer struct {
+	// keep the ClientHello fields once fingerprinted
+	KeepClientHello bool
+}
+
+func (e *JA3FlowEnhancer) Enhance(f *flow.Flow) {
+	version, ok := f.Attributes[flow.FlowAttributeTLSVersion]
+	if !ok {
+		return
+	}
+
+	if _, ok := f.Attributes[flow.FlowAttributeJA3]; !ok {
+		a := f.Attributes
+		ja3, err := JA3String(version, a[flow.FlowAttributeTLSCiphers], a[flow.FlowAttributeTLSExtensions], a[flow.FlowAttributeTLSCurves], a[flow.FlowAttributeTLSPointFormats])
+		if err != nil {
+			// a partial fingerprint would match other clients
+			return
+		}
+		f.Attributes[flow.FlowAttributeJA3] = JA3Hash(ja3)
+	}
+
+	if !e.KeepClientHello {
+		for _, field := range ja3Fields {
+			delete(f.Attributes, field)
+		}
+	}
+}
+
+func (e *JA3FlowEnhancer) DeclaredAttributes() map[string]string {
+	return map[string]string{flow.FlowAttributeJA3: AttributeString}
+}
+
+func NewJA3FlowEnhancer(keepClientHello bool) *JA3FlowEnhancer {
+	return &JA3FlowEnhancer{
+		KeepClientHello: keepClientHello,
+	}
+}
+
+// NewJA3FlowEnhancerFromConfig returns the enhancer configured by
+// analyzer.ja3, nil when disabled
+func NewJA3FlowEnhancerFromConfig() *JA3FlowEnhancer {
+	cfg := config.GetConfig()
+	if !cfg.GetBool("analyzer.ja3.enabled") {
+		return nil
+	}
+
+	return NewJA3FlowEnhancer(cfg.GetBool("analyzer.ja3.keep_client_hello"))
+}
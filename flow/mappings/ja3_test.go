@@ -0,0 +1,101 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package mappings
+
+import (
+	"testing"
+
+	"github.com/redhat-cip/skydive/flow"
+)
+
+func newClientHelloFlow(attributes map[string]string) *flow.Flow {
+	f := newIPv4Flow("192.168.0.10", "93.184.216.34")
+	f.Attributes = attributes
+	return f
+}
+
+func TestJA3String(t *testing.T) {
+	ja3, err := JA3String("769", "47-53-5-10-49161-49162-49171-49172-50-56-19-4", "0-10-11", "23-24-25", "0")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if ja3 != "769,47-53-5-10-49161-49162-49171-49172-50-56-19-4,0-10-11,23-24-25,0" {
+		t.Errorf("unexpected JA3 string: %s", ja3)
+	}
+	if hash := JA3Hash(ja3); hash != "ada70206e40642a3e4461f35503241d5" {
+		t.Errorf("unexpected JA3 hash: %s", hash)
+	}
+
+	// the GREASE values are ignored
+	ja3, err = JA3String("771", "2570-4865-4866", "6682-0-23-64250", "10794-29-23", "")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if ja3 != "771,4865-4866,0-23,29-23," {
+		t.Errorf("GREASE values should be ignored: %s", ja3)
+	}
+
+	if _, err = JA3String("771", "4865-tls", "", "", ""); err == nil {
+		t.Error("invalid cipher should be rejected")
+	}
+}
+
+func TestJA3FlowEnhancer(t *testing.T) {
+	e := NewJA3FlowEnhancer(false)
+
+	f := newClientHelloFlow(map[string]string{
+		flow.FlowAttributeTLSVersion:      "769",
+		flow.FlowAttributeTLSCiphers:      "47-53-5-10-49161-49162-49171-49172-50-56-19-4",
+		flow.FlowAttributeTLSExtensions:   "0-10-11",
+		flow.FlowAttributeTLSCurves:       "23-24-25",
+		flow.FlowAttributeTLSPointFormats: "0",
+	})
+	e.Enhance(f)
+	if len(f.Attributes) != 1 || f.Attributes[flow.FlowAttributeJA3] != "ada70206e40642a3e4461f35503241d5" {
+		t.Errorf("expected the JA3 fingerprint only: %v", f.Attributes)
+	}
+
+	filter, err := flow.ParseFilter("Attributes.JA3=ada70206e40642a3e4461f35503241d5")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !filter.Match(f) {
+		t.Error("fingerprinted flow should match the JA3 filter")
+	}
+
+	// the fingerprint of the agent is kept
+	f = newClientHelloFlow(map[string]string{
+		flow.FlowAttributeTLSVersion: "771",
+		flow.FlowAttributeJA3:        "e7d705a3286e19ea42f587b344ee6865",
+	})
+	NewJA3FlowEnhancer(true).Enhance(f)
+	if f.Attributes[flow.FlowAttributeJA3] != "e7d705a3286e19ea42f587b344ee6865" || f.Attributes[flow.FlowAttributeTLSVersion] != "771" {
+		t.Errorf("agent fingerprint and ClientHello should be kept: %v", f.Attributes)
+	}
+
+	f = newIPv4Flow("192.168.0.10", "93.184.216.34")
+	e.Enhance(f)
+	if _, ok := f.Attributes[flow.FlowAttributeJA3]; ok {
+		t.Errorf("flow without ClientHello should be left untouched: %v", f.Attributes)
+	}
+}
@@ -0,0 +1,78 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package mappings
+
+import (
+	"github.com/redhat-cip/skydive/common"
+	"github.com/redhat-cip/skydive/config"
+	"github.com/redhat-cip/skydive/flow"
+)
+
+// SetKeyCatalog enables the catalog of the flow keys, declaring the fields
+// of the flows and the attributes of the schema, the attributes set on a
+// sample of the flows being then observed
+func (fe *FlowMappingPipeline) SetKeyCatalog(c *common.KeyCatalog) {
+	for _, key := range flow.FilterKeys() {
+		if key == "Attributes.<name>" {
+			continue
+		}
+		if _, ok := (&flow.Flow{}).GetFieldInt64(key); ok {
+			c.Declare(key, "number")
+		} else {
+			c.Declare(key, "string")
+		}
+	}
+
+	// the attributes being strings whatever the format of their values
+	if fe.schema != nil {
+		for _, decl := range fe.schema.Declarations() {
+			c.Declare("Attributes."+decl.Name, "string")
+		}
+	}
+
+	fe.keys = c
+}
+
+// KeyCatalog returns the catalog of the flow keys, nil when not enabled
+func (fe *FlowMappingPipeline) KeyCatalog() *common.KeyCatalog {
+	return fe.keys
+}
+
+func (fe *FlowMappingPipeline) observeKeys(flows []*flow.Flow) {
+	for _, f := range flows {
+		if !fe.keys.Sample() {
+			continue
+		}
+		for k, v := range f.Attributes {
+			fe.keys.ObserveValue("Attributes."+k, v)
+		}
+	}
+}
+
+// NewFlowKeyCatalogFromConfig returns the catalog of the flow keys
+// configured by analyzer.key_catalog
+func NewFlowKeyCatalogFromConfig() *common.KeyCatalog {
+	cfg := config.GetConfig()
+	return common.NewKeyCatalog(cfg.GetInt("analyzer.key_catalog.sampling"),
+		cfg.GetInt("analyzer.key_catalog.max_examples"), cfg.GetInt("analyzer.key_catalog.max_keys"))
+}
@@ -0,0 +1,74 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package mappings
+
+import (
+	"testing"
+
+	"github.com/redhat-cip/skydive/common"
+	"github.com/redhat-cip/skydive/flow"
+)
+
+func TestFlowKeyCatalog(t *testing.T) {
+	ja3 := NewJA3FlowEnhancer(false)
+	schema, err := NewAttributeSchema(ja3)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	pipeline := NewFlowMappingPipeline(ja3)
+	pipeline.SetAttributeSchema(schema, false)
+	pipeline.SetKeyCatalog(common.NewKeyCatalog(3, 5, 0))
+
+	keys, _ := pipeline.KeyCatalog().Keys()
+	declared := make(map[string]common.KeyInfo)
+	for _, info := range keys {
+		declared[info.Key] = info
+	}
+	for key, typ := range map[string]string{"Attributes.JA3": "string", "Statistics.Last": "number", "IPV4.A": "string"} {
+		if info, ok := declared[key]; !ok || !info.Declared || info.Count != 0 || info.Types[0] != typ {
+			t.Errorf("Expected %s to be declared as a %s, got %+v", key, typ, info)
+		}
+	}
+
+	// the attributes set on the flows are observed within sampling flows
+	flows := make([]*flow.Flow, 3)
+	for i := range flows {
+		flows[i] = newClientHelloFlow(map[string]string{flow.FlowAttributeNATA: "10.0.0.1"})
+	}
+	pipeline.Enhance(flows)
+
+	if unknown := common.UnknownKeys(mustKeys(pipeline), []string{"Attributes.NAT_A", "IPV4"}); len(unknown) != 0 {
+		t.Errorf("Expected the NAT attribute to be observed, unknown %v", unknown)
+	}
+	if unknown := common.UnknownKeys(mustKeys(pipeline), []string{"Attributes.NAT_B", "Attributes.DSCP"}); len(unknown) != 1 || unknown[0] != "Attributes.DSCP" {
+		t.Errorf("Expected only Attributes.DSCP to be unknown, got %v", unknown)
+	}
+	if completions := common.CompleteKey(mustKeys(pipeline), "Attributes."); len(completions) != len(builtinAttributes) {
+		t.Errorf("Expected the builtin attributes, got %v", completions)
+	}
+}
+
+func mustKeys(p *FlowMappingPipeline) []common.KeyInfo {
+	keys, _ := p.KeyCatalog().Keys()
+	return keys
+}
@@ -0,0 +1,124 @@
+/*
+ * Copyright (C) 2015 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package mappings
+
+import (
+	"time"
+
+	"github.com/redhat-cip/skydive/flow"
+)
+
+// FlowLateBinder holds back the flows whose interfaces are not resolved yet,
+// the interfaces created lately being possibly not yet in the graph. These
+// flows are enhanced again on each call and when notified, being released
+// once resolved or after the maximum delay. The flows are expected to be
+// given by the goroutine of their table.
+type FlowLateBinder struct {
+	Pipeline *FlowMappingPipeline
+	MaxDelay time.Duration
+	pending  map[string]*pendingFlow
+	notify   chan bool
+}
+
+type pendingFlow struct {
+	flow  *flow.Flow
+	since time.Time
+}
+
+func isResolved(f *flow.Flow) bool {
+	return f.IfSrcNodeUUID != "" && f.IfDstNodeUUID != ""
+}
+
+// Notify signals that interfaces showed up, it doesn't block.
+func (b *FlowLateBinder) Notify() {
+	select {
+	case b.notify <- true:
+	default:
+	}
+}
+
+// Notified returns the channel the notifications are sent on, Retry is
+// expected to be called then.
+func (b *FlowLateBinder) Notified() <-chan bool {
+	return b.notify
+}
+
+func (b *FlowLateBinder) retry(now time.Time) []*flow.Flow {
+	var ready []*flow.Flow
+	for uuid, p := range b.pending {
+		b.Pipeline.EnhanceFlow(p.flow)
+		if isResolved(p.flow) || now.Sub(p.since) >= b.MaxDelay {
+			ready = append(ready, p.flow)
+			delete(b.pending, uuid)
+		}
+	}
+	return ready
+}
+
+// Enhance enhances the flows, returning the ones ready to be sent, the held
+// back ones included.
+func (b *FlowLateBinder) Enhance(flows []*flow.Flow) []*flow.Flow {
+	now := time.Now()
+	for _, f := range flows {
+		if _, ok := b.pending[f.UUID]; !ok {
+			b.pending[f.UUID] = &pendingFlow{flow: f, since: now}
+		}
+	}
+
+	return b.retry(now)
+}
+
+// Retry returns the held back flows now resolved or held for too long.
+func (b *FlowLateBinder) Retry() []*flow.Flow {
+	return b.retry(time.Now())
+}
+
+// Release enhances and returns the flows, resolved or not, these flows not
+// being held back anymore. Used for the expired flows.
+func (b *FlowLateBinder) Release(flows []*flow.Flow) []*flow.Flow {
+	for _, f := range flows {
+		delete(b.pending, f.UUID)
+	}
+	b.Pipeline.Enhance(flows)
+
+	return flows
+}
+
+// Flush returns all the held back flows
+func (b *FlowLateBinder) Flush() []*flow.Flow {
+	var flows []*flow.Flow
+	for uuid, p := range b.pending {
+		flows = append(flows, p.flow)
+		delete(b.pending, uuid)
+	}
+	return flows
+}
+
+func NewFlowLateBinder(pipeline *FlowMappingPipeline, maxDelay time.Duration) *FlowLateBinder {
+	return &FlowLateBinder{
+		Pipeline: pipeline,
+		MaxDelay: maxDelay,
+		pending:  make(map[string]*pendingFlow),
+		notify:   make(chan bool, 1),
+	}
+}
@@ -0,0 +1,126 @@
+/*
+ * Copyright (C) 2015 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package mappings
+
+import (
+	"testing"
+	"time"
+
+	"github.com/redhat-cip/skydive/flow"
+	"github.com/redhat-cip/skydive/topology/graph"
+)
+
+func newLateBindingTestFlow(uuid string, src string, dst string) *flow.Flow {
+	return &flow.Flow{
+		UUID: uuid,
+		Statistics: &flow.FlowStatistics{
+			Endpoints: []*flow.FlowEndpointsStatistics{
+				{
+					Type: flow.FlowEndpointType_ETHERNET,
+					AB:   &flow.FlowEndpointStatistics{Value: src},
+					BA:   &flow.FlowEndpointStatistics{Value: dst},
+				},
+			},
+		},
+	}
+}
+
+func newLateBindingTestGraph(t *testing.T) *graph.Graph {
+	b, err := graph.NewMemoryBackend()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	g, err := graph.NewGraph(b)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return g
+}
+
+func TestFlowLateBinder(t *testing.T) {
+	g := newLateBindingTestGraph(t)
+	g.NewNode(graph.GenID(), graph.Metadata{"MAC": "00:00:00:00:00:01"})
+
+	b := NewFlowLateBinder(NewFlowMappingPipeline(NewGraphFlowEnhancer(g)), time.Hour)
+
+	known := newLateBindingTestFlow("known", "00:00:00:00:00:01", "ff:ff:ff:ff:ff:ff")
+	late := newLateBindingTestFlow("late", "00:00:00:00:00:01", "00:00:00:00:00:02")
+
+	ready := b.Enhance([]*flow.Flow{known, late})
+	if len(ready) != 1 || ready[0] != known {
+		t.Fatalf("Only the resolved flow should be ready: %v", ready)
+	}
+
+	// updated again before the interface shows up
+	if ready = b.Enhance([]*flow.Flow{late}); len(ready) != 0 {
+		t.Fatalf("Unresolved flow shouldn't be ready: %v", ready)
+	}
+
+	// the interface shows up in the graph
+	node := g.NewNode(graph.GenID(), graph.Metadata{"MAC": "00:00:00:00:00:02"})
+	b.Notify()
+	b.Notify()
+
+	select {
+	case <-b.Notified():
+	default:
+		t.Fatal("Notification expected")
+	}
+
+	if ready = b.Retry(); len(ready) != 1 || ready[0] != late {
+		t.Fatalf("Late flow should be ready once resolved: %v", ready)
+	}
+	if late.IfDstNodeUUID != string(node.ID) {
+		t.Errorf("Late flow should be bound to the new interface: %s", late.IfDstNodeUUID)
+	}
+
+	if ready = b.Retry(); len(ready) != 0 {
+		t.Errorf("Flows shouldn't be released twice: %v", ready)
+	}
+}
+
+func TestFlowLateBinderMaxDelay(t *testing.T) {
+	g := newLateBindingTestGraph(t)
+	b := NewFlowLateBinder(NewFlowMappingPipeline(NewGraphFlowEnhancer(g)), 100*time.Millisecond)
+
+	unknown := newLateBindingTestFlow("unknown", "00:00:00:00:00:01", "00:00:00:00:00:02")
+	if ready := b.Enhance([]*flow.Flow{unknown}); len(ready) != 0 {
+		t.Fatalf("Unresolved flow shouldn't be ready: %v", ready)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+
+	if ready := b.Retry(); len(ready) != 1 || ready[0] != unknown {
+		t.Errorf("Unresolved flow should be released after the maximum delay: %v", ready)
+	}
+
+	expired := newLateBindingTestFlow("expired", "00:00:00:00:00:01", "00:00:00:00:00:02")
+	b.Enhance([]*flow.Flow{expired, newLateBindingTestFlow("other", "00:00:00:00:00:03", "00:00:00:00:00:04")})
+	if ready := b.Release([]*flow.Flow{expired}); len(ready) != 1 {
+		t.Errorf("Expired flows should be released: %v", ready)
+	}
+	if ready := b.Flush(); len(ready) != 1 || ready[0].UUID != "other" {
+		t.Errorf("Flush should return the held back flows only: %v", ready)
+	}
+}
@@ -24,7 +24,6 @@ package mappings
 
 import (
 	"github.com/redhat-cip/skydive/flow"
-	"github.com/redhat-cip/skydive/flow/packet"
 	"github.com/redhat-cip/skydive/logging"
 	"github.com/redhat-cip/skydive/topology/graph"
 )
@@ -33,37 +32,23 @@ type OvsFlowEnhancer struct {
 	Graph *graph.Graph
 }
 
-func (gfe *OvsFlowEnhancer) getNodeUUID(mac string) string {
-	if packet.IsBroadcastMac(mac) || packet.IsMulticastMac(mac) {
-		return "*"
-	}
-
-	gfe.Graph.Lock()
-	defer gfe.Graph.Unlock()
-
-	intfs := gfe.Graph.LookupNodes(graph.Metadata{"ExtID.attached-mac": mac})
-	if len(intfs) > 1 {
-		logging.GetLogger().Infof("OvsFlowEnhancer found more than one interface for the mac: %s", mac)
-	} else if len(intfs) == 1 {
-		return string(intfs[0].ID)
+func (gfe *OvsFlowEnhancer) enhance(f *flow.Flow) []FlowLookup {
+	lookups := lookupInterfaces(gfe.Graph, f, "ExtID.attached-mac")
+	for _, lookup := range lookups {
+		if len(lookup.Candidates) > 1 {
+			logging.GetLogger().Infof("OvsFlowEnhancer found more than one interface for the mac: %s", lookup.Filter["ExtID.attached-mac"])
+		}
 	}
-	return ""
+	return lookups
 }
 
 func (gfe *OvsFlowEnhancer) Enhance(f *flow.Flow) {
-	var eth *flow.FlowEndpointsStatistics
-	if f.IfSrcNodeUUID == "" || f.IfDstNodeUUID == "" {
-		eth = f.GetStatistics().GetEndpointsType(flow.FlowEndpointType_ETHERNET)
-		if eth == nil {
-			return
-		}
-	}
-	if f.IfSrcNodeUUID == "" {
-		f.IfSrcNodeUUID = gfe.getNodeUUID(eth.AB.Value)
-	}
-	if f.IfDstNodeUUID == "" {
-		f.IfDstNodeUUID = gfe.getNodeUUID(eth.BA.Value)
-	}
+	gfe.enhance(f)
+}
+
+// TraceEnhance enhances the flow, reporting the graph lookups
+func (gfe *OvsFlowEnhancer) TraceEnhance(f *flow.Flow) []FlowLookup {
+	return gfe.enhance(f)
 }
 
 func NewOvsFlowEnhancer(g *graph.Graph) *OvsFlowEnhancer {
@@ -23,20 +23,68 @@
 package mappings
 
 import (
+	"sync"
+
+	"github.com/redhat-cip/skydive/common"
 	"github.com/redhat-cip/skydive/flow"
+	"github.com/redhat-cip/skydive/logging"
 )
 
 type FlowEnhancer interface {
 	Enhance(flow *flow.Flow)
 }
 
+// CorrelationStats reports how many flow endpoints the pipeline resolved to
+// a graph node, a low ratio indicating gaps in the topology.
+type CorrelationStats struct {
+	Resolved   uint64
+	Unresolved uint64
+	// ratio of the last batch of flows and of all the batches
+	Ratio      float64
+	TotalRatio float64
+	// number of consecutive batches below the warning ratio
+	LowBatches int
+}
+
 type FlowMappingPipeline struct {
-	Enhancers []FlowEnhancer
+	Enhancers  []FlowEnhancer
+	debug      FlowDebugListener
+	provenance *provenanceCache
+	keys       *common.KeyCatalog
+
+	schema       *AttributeSchema
+	strictSchema bool
+
+	statsLock      sync.Mutex
+	stats          CorrelationStats
+	warningRatio   float64
+	warningBatches int
+}
+
+// SetDebugListener enables the recording of the changes done by each
+// enhancer, this has a cost so it should be used only for debugging. The
+// changes are only recorded while the listener is tracing.
+func (fe *FlowMappingPipeline) SetDebugListener(l FlowDebugListener) {
+	fe.debug = l
 }
 
 func (fe *FlowMappingPipeline) EnhanceFlow(flow *flow.Flow) {
+	if fe.schema != nil && len(flow.Attributes) > 0 {
+		fe.schema.Canonicalize(flow)
+	}
+
+	if fe.debug != nil && fe.debug.Tracing() {
+		record, err := fe.traceEnhanceFlow(flow)
+		if err != nil {
+			logging.GetLogger().Errorf("Unable to trace flow enhancement: %s", err.Error())
+			return
+		}
+		fe.debug.OnFlowEnhanced(record)
+		return
+	}
+
 	for _, enhancer := range fe.Enhancers {
-		enhancer.Enhance(flow)
+		fe.enhance(enhancer, flow)
 	}
 }
 
@@ -44,6 +92,65 @@ func (fe *FlowMappingPipeline) Enhance(flows []*flow.Flow) {
 	for _, flow := range flows {
 		fe.EnhanceFlow(flow)
 	}
+
+	fe.updateCorrelation(flows)
+	if fe.keys != nil {
+		fe.observeKeys(flows)
+	}
+}
+
+// SetCorrelationWarning enables the logging of a warning once the
+// correlation ratio stays below ratio for the given number of batches.
+func (fe *FlowMappingPipeline) SetCorrelationWarning(ratio float64, batches int) {
+	fe.statsLock.Lock()
+	fe.warningRatio = ratio
+	fe.warningBatches = batches
+	fe.statsLock.Unlock()
+}
+
+// CorrelationStats returns the correlation of the flow endpoints with the
+// graph nodes
+func (fe *FlowMappingPipeline) CorrelationStats() CorrelationStats {
+	fe.statsLock.Lock()
+	defer fe.statsLock.Unlock()
+
+	return fe.stats
+}
+
+func (fe *FlowMappingPipeline) updateCorrelation(flows []*flow.Flow) {
+	var resolved, unresolved uint64
+	for _, f := range flows {
+		for _, uuid := range []string{f.IfSrcNodeUUID, f.IfDstNodeUUID} {
+			if uuid != "" {
+				resolved++
+			} else {
+				unresolved++
+			}
+		}
+	}
+
+	if resolved+unresolved == 0 {
+		return
+	}
+
+	fe.statsLock.Lock()
+	defer fe.statsLock.Unlock()
+
+	fe.stats.Resolved += resolved
+	fe.stats.Unresolved += unresolved
+	fe.stats.Ratio = float64(resolved) / float64(resolved+unresolved)
+	fe.stats.TotalRatio = float64(fe.stats.Resolved) / float64(fe.stats.Resolved+fe.stats.Unresolved)
+
+	if fe.warningBatches == 0 || fe.stats.Ratio >= fe.warningRatio {
+		fe.stats.LowBatches = 0
+		return
+	}
+
+	fe.stats.LowBatches++
+	if fe.stats.LowBatches == fe.warningBatches {
+		logging.GetLogger().Warningf("Only %.0f%% of the flow endpoints resolved to a graph node for %d batches, the topology may be incomplete",
+			fe.stats.Ratio*100, fe.stats.LowBatches)
+	}
 }
 
 func NewFlowMappingPipeline(enhancers ...FlowEnhancer) *FlowMappingPipeline {
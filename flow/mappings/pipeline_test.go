@@ -0,0 +1,76 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package mappings
+
+import (
+	"testing"
+
+	"github.com/redhat-cip/skydive/flow"
+)
+
+func TestCorrelationStats(t *testing.T) {
+	// the test enhancer resolves the source endpoints only
+	pipeline := NewFlowMappingPipeline(&testNodeEnhancer{})
+
+	pipeline.Enhance([]*flow.Flow{
+		{UUID: "flow1"},
+		{UUID: "flow2"},
+		{UUID: "flow3", IfDstNodeUUID: "node-dst"},
+		{UUID: "flow4", IfDstNodeUUID: "node-dst"},
+	})
+
+	stats := pipeline.CorrelationStats()
+	if stats.Resolved != 6 || stats.Unresolved != 2 || stats.Ratio != 0.75 || stats.TotalRatio != 0.75 {
+		t.Fatalf("Wrong correlation stats: %+v", stats)
+	}
+
+	pipeline.Enhance([]*flow.Flow{{UUID: "flow5"}, {UUID: "flow6"}})
+
+	stats = pipeline.CorrelationStats()
+	if stats.Ratio != 0.5 || stats.TotalRatio != float64(8)/12 {
+		t.Errorf("Wrong correlation stats: %+v", stats)
+	}
+
+	// batches without flow don't change the ratio
+	pipeline.Enhance(nil)
+	if pipeline.CorrelationStats() != stats {
+		t.Errorf("Empty batch changed the stats: %+v", pipeline.CorrelationStats())
+	}
+}
+
+func TestCorrelationWarning(t *testing.T) {
+	pipeline := NewFlowMappingPipeline()
+	pipeline.SetCorrelationWarning(0.5, 3)
+
+	for i := 0; i < 4; i++ {
+		pipeline.Enhance([]*flow.Flow{{UUID: "unresolved"}})
+	}
+	if stats := pipeline.CorrelationStats(); stats.Ratio != 0 || stats.LowBatches != 4 {
+		t.Errorf("Expected 4 low batches, got %+v", stats)
+	}
+
+	pipeline.Enhance([]*flow.Flow{{UUID: "resolved", IfSrcNodeUUID: "src", IfDstNodeUUID: "dst"}})
+	if stats := pipeline.CorrelationStats(); stats.Ratio != 1 || stats.LowBatches != 0 {
+		t.Errorf("The low batches should be reset, got %+v", stats)
+	}
+}
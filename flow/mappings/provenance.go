@@ -0,0 +1,153 @@
+/*
+ * Copyright (C) 2015 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package mappings
+
+import (
+	"sync"
+	"time"
+
+	"github.com/redhat-cip/skydive/flow"
+	"github.com/redhat-cip/skydive/topology/graph"
+)
+
+// FlowFieldProvenance tells which stage of the pipeline set an enriched
+// field of a flow, and from what. Source is the kind of source, the graph
+// for the interface lookups, Filter being the lookup that gave the value.
+type FlowFieldProvenance struct {
+	Field  string
+	Value  string
+	Stage  string
+	Source string         `json:",omitempty"`
+	Filter graph.Metadata `json:",omitempty"`
+	Time   int64
+}
+
+// provenanceCache holds the provenance of the fields of the last size
+// flows enhanced
+type provenanceCache struct {
+	sync.RWMutex
+	size  int
+	flows map[string][]FlowFieldProvenance
+	order []string
+}
+
+func (c *provenanceCache) add(uuid string, records []FlowFieldProvenance) {
+	c.Lock()
+	defer c.Unlock()
+
+	if _, ok := c.flows[uuid]; !ok {
+		if len(c.order) >= c.size {
+			delete(c.flows, c.order[0])
+			c.order = c.order[1:]
+		}
+		c.order = append(c.order, uuid)
+	}
+	c.flows[uuid] = append(c.flows[uuid], records...)
+}
+
+func (c *provenanceCache) get(uuid string) []FlowFieldProvenance {
+	c.RLock()
+	defer c.RUnlock()
+
+	return c.flows[uuid]
+}
+
+// enhanceStage runs an enhancer on the flow, returning the provenance of the
+// fields it set. The interface fields being only set when empty, the ones
+// set by the enhancers not reporting their lookups are found by comparing
+// them.
+func enhanceStage(enhancer FlowEnhancer, f *flow.Flow, now int64) []FlowFieldProvenance {
+	var records []FlowFieldProvenance
+
+	stage := stageName(enhancer)
+	if tracer, ok := enhancer.(FlowLookupTracer); ok {
+		for _, lookup := range tracer.TraceEnhance(f) {
+			if lookup.Result != "" {
+				records = append(records, FlowFieldProvenance{
+					Field:  lookup.Field,
+					Value:  lookup.Result,
+					Stage:  stage,
+					Source: "graph",
+					Filter: lookup.Filter,
+					Time:   now,
+				})
+			}
+		}
+		return records
+	}
+
+	src, dst := f.IfSrcNodeUUID, f.IfDstNodeUUID
+	enhancer.Enhance(f)
+
+	if f.IfSrcNodeUUID != src {
+		records = append(records, FlowFieldProvenance{Field: "IfSrcNodeUUID", Value: f.IfSrcNodeUUID, Stage: stage, Time: now})
+	}
+	if f.IfDstNodeUUID != dst {
+		records = append(records, FlowFieldProvenance{Field: "IfDstNodeUUID", Value: f.IfDstNodeUUID, Stage: stage, Time: now})
+	}
+	return records
+}
+
+// SetProvenance enables the recording of the provenance of the fields set
+// by the enhancers for the last size flows, 0 disabling it
+func (fe *FlowMappingPipeline) SetProvenance(size int) {
+	if size <= 0 {
+		fe.provenance = nil
+		return
+	}
+
+	fe.provenance = &provenanceCache{
+		size:  size,
+		flows: make(map[string][]FlowFieldProvenance),
+	}
+}
+
+// Provenance returns the provenance of the fields set by the enhancers on
+// the flow, nil if not recorded
+func (fe *FlowMappingPipeline) Provenance(uuid string) []FlowFieldProvenance {
+	if fe.provenance == nil {
+		return nil
+	}
+	return fe.provenance.get(uuid)
+}
+
+// enhance runs an enhancer on the flow, recording the provenance of the
+// fields it set when enabled and validating the attributes it set in strict
+// mode
+func (fe *FlowMappingPipeline) enhance(enhancer FlowEnhancer, f *flow.Flow) {
+	if fe.strictSchema && fe.schema != nil {
+		before := copyAttributes(f.Attributes)
+		defer func() {
+			fe.schema.Validate(stageName(enhancer), before, f.Attributes)
+		}()
+	}
+
+	if fe.provenance == nil {
+		enhancer.Enhance(f)
+		return
+	}
+
+	if records := enhanceStage(enhancer, f, time.Now().Unix()); len(records) > 0 {
+		fe.provenance.add(f.UUID, records)
+	}
+}
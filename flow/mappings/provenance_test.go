@@ -0,0 +1,100 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package mappings
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/redhat-cip/skydive/flow"
+	"github.com/redhat-cip/skydive/topology/graph"
+)
+
+func TestProvenance(t *testing.T) {
+	backend, _ := graph.NewMemoryBackend()
+	g, _ := graph.NewGraph(backend)
+
+	src := g.NewNode(graph.GenID(), graph.Metadata{"MAC": "00:00:00:00:00:01"})
+	dst := g.NewNode(graph.GenID(), graph.Metadata{"ExtID.attached-mac": "00:00:00:00:00:02"})
+
+	pipeline := NewFlowMappingPipeline(NewGraphFlowEnhancer(g), NewOvsFlowEnhancer(g), &testNodeEnhancer{})
+	pipeline.SetProvenance(10)
+
+	f := newEthernetFlow("00:00:00:00:00:01", "00:00:00:00:00:02")
+	pipeline.Enhance([]*flow.Flow{f})
+
+	provenance := pipeline.Provenance(f.UUID)
+	if len(provenance) != 2 {
+		t.Fatalf("Expected the provenance of 2 fields, got %+v", provenance)
+	}
+
+	for _, p := range provenance {
+		p.Time = 0
+		switch p.Field {
+		case "IfSrcNodeUUID":
+			expected := FlowFieldProvenance{Field: "IfSrcNodeUUID", Value: string(src.ID), Stage: "GraphFlowEnhancer", Source: "graph", Filter: graph.Metadata{"MAC": "00:00:00:00:00:01"}}
+			if !reflect.DeepEqual(p, expected) {
+				t.Errorf("Expected %+v, got %+v", expected, p)
+			}
+		case "IfDstNodeUUID":
+			// not resolved by the graph enhancer, set by the ovs one
+			expected := FlowFieldProvenance{Field: "IfDstNodeUUID", Value: string(dst.ID), Stage: "OvsFlowEnhancer", Source: "graph", Filter: graph.Metadata{"ExtID.attached-mac": "00:00:00:00:00:02"}}
+			if !reflect.DeepEqual(p, expected) {
+				t.Errorf("Expected %+v, got %+v", expected, p)
+			}
+		default:
+			t.Errorf("Unexpected field: %+v", p)
+		}
+	}
+
+	// the fields being set, enhancing the flow again doesn't change them
+	pipeline.Enhance([]*flow.Flow{f})
+	if len(pipeline.Provenance(f.UUID)) != 2 {
+		t.Errorf("Expected no more provenance, got %+v", pipeline.Provenance(f.UUID))
+	}
+}
+
+func TestProvenanceChangedFields(t *testing.T) {
+	pipeline := NewFlowMappingPipeline(&testNodeEnhancer{})
+	pipeline.SetProvenance(1)
+
+	f1 := &flow.Flow{UUID: "flow-1"}
+	pipeline.Enhance([]*flow.Flow{f1})
+
+	provenance := pipeline.Provenance("flow-1")
+	if len(provenance) != 1 || provenance[0].Field != "IfSrcNodeUUID" || provenance[0].Value != "node-src" || provenance[0].Stage != "testNodeEnhancer" {
+		t.Fatalf("Wrong provenance of the enhancer not reporting its lookups: %+v", provenance)
+	}
+
+	// only the provenance of the last flow is kept
+	pipeline.Enhance([]*flow.Flow{{UUID: "flow-2"}})
+	if pipeline.Provenance("flow-1") != nil || pipeline.Provenance("flow-2") == nil {
+		t.Error("Expected the provenance of the oldest flow to be dropped")
+	}
+
+	pipeline.SetProvenance(0)
+	pipeline.Enhance([]*flow.Flow{{UUID: "flow-3"}})
+	if pipeline.Provenance("flow-3") != nil {
+		t.Error("Expected no provenance once disabled")
+	}
+}
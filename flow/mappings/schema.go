@@ -0,0 +1,367 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package mappings
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/redhat-cip/skydive/config"
+	"github.com/redhat-cip/skydive/flow"
+	"github.com/redhat-cip/skydive/logging"
+)
+
+const (
+	AttributeString = "string"
+	AttributeNumber = "number"
+
+	// owner of the attributes set outside the enhancers, by the agents or
+	// the analyzer
+	builtinAttributeOwner = "flow"
+)
+
+// attributes set outside the enhancers
+var builtinAttributes = map[string]string{
+	flow.FlowAttributeNATA:            AttributeString,
+	flow.FlowAttributeNATB:            AttributeString,
+	flow.FlowAttributeTLSVersion:      AttributeNumber,
+	flow.FlowAttributeTLSCiphers:      AttributeString,
+	flow.FlowAttributeTLSExtensions:   AttributeString,
+	flow.FlowAttributeTLSCurves:       AttributeString,
+	flow.FlowAttributeTLSPointFormats: AttributeString,
+	flow.FlowAttributeJA3:             AttributeString,
+	flow.FlowAttributeBackfilledAt:    AttributeNumber,
+}
+
+// FlowAttributesDeclarer is implemented by the enhancers declaring the
+// attributes they set, with their type
+type FlowAttributesDeclarer interface {
+	DeclaredAttributes() map[string]string
+}
+
+// AttributeDeclaration is an attribute of the flows, with its type and the
+// enhancers setting it
+type AttributeDeclaration struct {
+	Name      string
+	Type      string
+	Enhancers []string
+}
+
+// AttributeViolation is an attribute set by an enhancer not matching its
+// declarations
+type AttributeViolation struct {
+	Enhancer  string
+	Attribute string
+	Value     string
+	Reason    string
+}
+
+// AttributeSchemaStats reports the violations of the declarations by the
+// enhancers
+type AttributeSchemaStats struct {
+	Violations uint64
+	Enhancers  map[string]uint64 `json:",omitempty"`
+}
+
+// AttributeSchema is the registry of the attributes set on the flows. Two
+// declarations of an attribute must agree on its type and an attribute
+// can't differ from another one only by its case, the aliases mapping the
+// legacy spellings of the attributes to the canonical ones.
+type AttributeSchema struct {
+	sync.RWMutex
+	attributes map[string]*AttributeDeclaration
+	folded     map[string]string
+	aliases    map[string]string
+	stats      AttributeSchemaStats
+	logged     map[string]bool
+}
+
+func validAttributeType(t string) bool {
+	return t == AttributeString || t == AttributeNumber
+}
+
+// Register declares the attributes set by an enhancer
+func (s *AttributeSchema) Register(enhancer string, attributes map[string]string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	var names []string
+	for name := range attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		t := attributes[name]
+		if !validAttributeType(t) {
+			return fmt.Errorf("attribute %s declared by %s has an invalid type %s", name, enhancer, t)
+		}
+
+		if canonical, ok := s.folded[strings.ToLower(name)]; ok && canonical != name {
+			decl := s.attributes[canonical]
+			return fmt.Errorf("attribute %s declared by %s conflicts with %s declared by %s", name, enhancer, canonical, strings.Join(decl.Enhancers, ", "))
+		}
+
+		decl, ok := s.attributes[name]
+		if !ok {
+			s.attributes[name] = &AttributeDeclaration{Name: name, Type: t, Enhancers: []string{enhancer}}
+			s.folded[strings.ToLower(name)] = name
+			continue
+		}
+
+		if decl.Type != t {
+			return fmt.Errorf("attribute %s declared as a %s by %s and as a %s by %s", name, t, enhancer, decl.Type, strings.Join(decl.Enhancers, ", "))
+		}
+		decl.Enhancers = append(decl.Enhancers, enhancer)
+	}
+
+	return nil
+}
+
+// RegisterEnhancer declares the attributes of an enhancer if it declares
+// some
+func (s *AttributeSchema) RegisterEnhancer(enhancer FlowEnhancer) error {
+	if declarer, ok := enhancer.(FlowAttributesDeclarer); ok {
+		return s.Register(stageName(enhancer), declarer.DeclaredAttributes())
+	}
+	return nil
+}
+
+// Alias maps a legacy spelling of an attribute to the canonical one, the
+// aliases being case insensitive as the declared attributes
+func (s *AttributeSchema) Alias(legacy string, canonical string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	if _, ok := s.attributes[canonical]; !ok {
+		return fmt.Errorf("alias %s of the undeclared attribute %s", legacy, canonical)
+	}
+	if _, ok := s.folded[strings.ToLower(legacy)]; ok {
+		return fmt.Errorf("alias %s is a declared attribute", legacy)
+	}
+	s.aliases[strings.ToLower(legacy)] = canonical
+	return nil
+}
+
+type sortByName []AttributeDeclaration
+
+func (s sortByName) Len() int {
+	return len(s)
+}
+
+func (s sortByName) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s sortByName) Less(i, j int) bool {
+	return s[i].Name < s[j].Name
+}
+
+// Declarations returns the declared attributes sorted by name
+func (s *AttributeSchema) Declarations() []AttributeDeclaration {
+	s.RLock()
+	defer s.RUnlock()
+
+	declarations := make([]AttributeDeclaration, 0, len(s.attributes))
+	for _, decl := range s.attributes {
+		d := *decl
+		d.Enhancers = append([]string(nil), decl.Enhancers...)
+		declarations = append(declarations, d)
+	}
+	sort.Sort(sortByName(declarations))
+	return declarations
+}
+
+// canonicalName returns the canonical name of an attribute, either an
+// alias or differing from a declared attribute only by its case
+func (s *AttributeSchema) canonicalName(name string) (string, bool) {
+	folded := strings.ToLower(name)
+	if canonical, ok := s.aliases[folded]; ok {
+		return canonical, true
+	}
+	if canonical, ok := s.folded[folded]; ok && canonical != name {
+		return canonical, true
+	}
+	return "", false
+}
+
+// Canonicalize renames the attributes of the flow spelled the legacy way,
+// the value of the canonical attribute being kept if both are set
+func (s *AttributeSchema) Canonicalize(f *flow.Flow) {
+	s.RLock()
+	defer s.RUnlock()
+
+	for name, value := range f.Attributes {
+		if canonical, ok := s.canonicalName(name); ok {
+			if _, ok := f.Attributes[canonical]; !ok {
+				f.Attributes[canonical] = value
+			}
+			delete(f.Attributes, name)
+		}
+	}
+}
+
+func (s *AttributeSchema) violation(enhancer string, attribute string, value string) *AttributeViolation {
+	decl, ok := s.attributes[attribute]
+	if !ok {
+		return &AttributeViolation{Enhancer: enhancer, Attribute: attribute, Value: value, Reason: "undeclared attribute"}
+	}
+
+	declared := false
+	for _, e := range decl.Enhancers {
+		if e == enhancer {
+			declared = true
+			break
+		}
+	}
+	if !declared {
+		return &AttributeViolation{Enhancer: enhancer, Attribute: attribute, Value: value, Reason: "attribute declared by " + strings.Join(decl.Enhancers, ", ")}
+	}
+
+	if decl.Type == AttributeNumber {
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return &AttributeViolation{Enhancer: enhancer, Attribute: attribute, Value: value, Reason: "not a number"}
+		}
+	}
+	return nil
+}
+
+// Validate checks the attributes set by an enhancer, added or changed
+// between before and after, against its declarations. The violations are
+// counted and logged once per enhancer, attribute and reason.
+func (s *AttributeSchema) Validate(enhancer string, before map[string]string, after map[string]string) []AttributeViolation {
+	var violations []AttributeViolation
+
+	s.RLock()
+	for name, value := range after {
+		if old, ok := before[name]; ok && old == value {
+			continue
+		}
+		if v := s.violation(enhancer, name, value); v != nil {
+			violations = append(violations, *v)
+		}
+	}
+	s.RUnlock()
+
+	if len(violations) == 0 {
+		return nil
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	for _, v := range violations {
+		s.stats.Violations++
+		s.stats.Enhancers[enhancer]++
+
+		key := v.Enhancer + "/" + v.Attribute + "/" + v.Reason
+		if !s.logged[key] {
+			s.logged[key] = true
+			logging.GetLogger().Errorf("Enhancer %s set the attribute %s to %q: %s", v.Enhancer, v.Attribute, v.Value, v.Reason)
+		}
+	}
+	return violations
+}
+
+// Stats returns the violations of the declarations
+func (s *AttributeSchema) Stats() AttributeSchemaStats {
+	s.RLock()
+	defer s.RUnlock()
+
+	stats := AttributeSchemaStats{Violations: s.stats.Violations, Enhancers: make(map[string]uint64)}
+	for enhancer, count := range s.stats.Enhancers {
+		stats.Enhancers[enhancer] = count
+	}
+	return stats
+}
+
+// SetAttributeSchema sets the registry of the attributes, legacy spellings
+// being canonicalized before the enhancement. In strict mode the attributes
+// set by each enhancer are validated against its declarations, which has a
+// cost on each flow.
+func (fe *FlowMappingPipeline) SetAttributeSchema(s *AttributeSchema, strict bool) {
+	fe.schema = s
+	fe.strictSchema = strict
+}
+
+// AttributeSchema returns the registry of the attributes, nil when not set
+func (fe *FlowMappingPipeline) AttributeSchema() *AttributeSchema {
+	return fe.schema
+}
+
+// StrictAttributeSchema returns whether the attributes set by the enhancers
+// are validated
+func (fe *FlowMappingPipeline) StrictAttributeSchema() bool {
+	return fe.strictSchema
+}
+
+func copyAttributes(attributes map[string]string) map[string]string {
+	c := make(map[string]string, len(attributes))
+	for k, v := range attributes {
+		c[k] = v
+	}
+	return c
+}
+
+// NewAttributeSchema returns the registry of the builtin attributes and of
+// the ones declared by the enhancers, failing on conflicting declarations
+func NewAttributeSchema(enhancers ...FlowEnhancer) (*AttributeSchema, error) {
+	s := &AttributeSchema{
+		attributes: make(map[string]*AttributeDeclaration),
+		folded:     make(map[string]string),
+		aliases:    make(map[string]string),
+		stats:      AttributeSchemaStats{Enhancers: make(map[string]uint64)},
+		logged:     make(map[string]bool),
+	}
+
+	if err := s.Register(builtinAttributeOwner, builtinAttributes); err != nil {
+		return nil, err
+	}
+	for _, enhancer := range enhancers {
+		if err := s.RegisterEnhancer(enhancer); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// NewAttributeSchemaFromConfig returns the registry of the attributes of
+// the enhancers with the aliases of analyzer.flow_schema.aliases
+func NewAttributeSchemaFromConfig(enhancers ...FlowEnhancer) (*AttributeSchema, error) {
+	s, err := NewAttributeSchema(enhancers...)
+	if err != nil {
+		return nil, err
+	}
+
+	for legacy, canonical := range config.GetConfig().GetStringMapString("analyzer.flow_schema.aliases") {
+		if err := s.Alias(legacy, canonical); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
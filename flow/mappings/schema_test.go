@@ -0,0 +1,183 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package mappings
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/redhat-cip/skydive/flow"
+)
+
+// labelEnhancer declares attributes and sets values, declared or not
+type labelEnhancer struct {
+	attributes map[string]string
+	values     map[string]string
+}
+
+func (e *labelEnhancer) Enhance(f *flow.Flow) {
+	if f.Attributes == nil {
+		f.Attributes = make(map[string]string)
+	}
+	for k, v := range e.values {
+		f.Attributes[k] = v
+	}
+}
+
+func (e *labelEnhancer) DeclaredAttributes() map[string]string {
+	return e.attributes
+}
+
+type classifierEnhancer struct {
+	labelEnhancer
+}
+
+func TestAttributeSchemaConflicts(t *testing.T) {
+	label := &labelEnhancer{attributes: map[string]string{"Application": AttributeString}}
+
+	for _, test := range []struct {
+		attributes map[string]string
+		err        string
+	}{
+		{map[string]string{"Application": AttributeNumber}, "attribute Application declared as a number by classifierEnhancer and as a string by labelEnhancer"},
+		{map[string]string{"application": AttributeString}, "attribute application declared by classifierEnhancer conflicts with Application declared by labelEnhancer"},
+		{map[string]string{"ja3": AttributeString}, "attribute ja3 declared by classifierEnhancer conflicts with JA3 declared by flow"},
+		{map[string]string{"Score": "float"}, "attribute Score declared by classifierEnhancer has an invalid type float"},
+	} {
+		classifier := &classifierEnhancer{labelEnhancer{attributes: test.attributes}}
+		if _, err := NewAttributeSchema(label, classifier); err == nil || err.Error() != test.err {
+			t.Errorf("Expected the error %q, got %v", test.err, err)
+		}
+	}
+
+	// the enhancers agreeing on the type share the attribute
+	classifier := &classifierEnhancer{labelEnhancer{attributes: map[string]string{"Application": AttributeString}}}
+	schema, err := NewAttributeSchema(label, classifier)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for _, decl := range schema.Declarations() {
+		if decl.Name == "Application" && strings.Join(decl.Enhancers, ",") != "labelEnhancer,classifierEnhancer" {
+			t.Errorf("Expected the attribute to be declared by both enhancers, got %+v", decl)
+		}
+	}
+}
+
+func TestAttributeSchemaStrict(t *testing.T) {
+	misbehaving := &labelEnhancer{
+		attributes: map[string]string{"Score": AttributeNumber},
+		values:     map[string]string{"Score": "high", "Application": "http", flow.FlowAttributeJA3: "abc"},
+	}
+	classifier := &classifierEnhancer{labelEnhancer{
+		attributes: map[string]string{"Application": AttributeString},
+		values:     map[string]string{"Application": "https"},
+	}}
+
+	schema, err := NewAttributeSchema(misbehaving, classifier)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	pipeline := NewFlowMappingPipeline(misbehaving, classifier)
+	pipeline.SetAttributeSchema(schema, true)
+
+	flows := []*flow.Flow{{UUID: "1"}, {UUID: "2"}}
+	pipeline.Enhance(flows)
+
+	// the violations are flagged without stopping the pipeline
+	for _, f := range flows {
+		if f.Attributes["Application"] != "https" || f.Attributes["Score"] != "high" {
+			t.Errorf("Expected the flow to be fully enhanced, got %v", f.Attributes)
+		}
+	}
+
+	stats := schema.Stats()
+	if stats.Violations != 6 || stats.Enhancers["labelEnhancer"] != 6 || stats.Enhancers["classifierEnhancer"] != 0 {
+		t.Errorf("Expected 3 violations of labelEnhancer per flow, got %+v", stats)
+	}
+
+	violations := schema.Validate("labelEnhancer", nil, map[string]string{"Score": "high", "Application": "http", flow.FlowAttributeJA3: "abc"})
+	reasons := make(map[string]string)
+	for _, v := range violations {
+		reasons[v.Attribute] = v.Reason
+	}
+	expected := map[string]string{
+		"Score":               "not a number",
+		"Application":         "attribute declared by classifierEnhancer",
+		flow.FlowAttributeJA3: "attribute declared by flow",
+	}
+	for attribute, reason := range expected {
+		if reasons[attribute] != reason {
+			t.Errorf("Expected %s to be flagged with %q, got %q", attribute, reason, reasons[attribute])
+		}
+	}
+
+	// the unchanged attributes aren't validated
+	if violations := schema.Validate("labelEnhancer", map[string]string{"Score": "high"}, map[string]string{"Score": "high"}); len(violations) != 0 {
+		t.Errorf("Expected no violation, got %+v", violations)
+	}
+
+	// without strict mode nothing is validated
+	pipeline.SetAttributeSchema(schema, false)
+	pipeline.Enhance([]*flow.Flow{{UUID: "3"}})
+	if stats := schema.Stats(); stats.Violations != 9 {
+		t.Errorf("Expected no violation counted without strict mode, got %+v", stats)
+	}
+}
+
+func TestAttributeSchemaAliases(t *testing.T) {
+	schema, err := NewAttributeSchema()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := schema.Alias("JA3_HASH", flow.FlowAttributeJA3); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := schema.Alias("FINGERPRINT", "Fingerprint"); err == nil {
+		t.Error("Expected an alias of an undeclared attribute to fail")
+	}
+	if err := schema.Alias("nat_a", flow.FlowAttributeJA3); err == nil {
+		t.Error("Expected an alias of a declared attribute to fail")
+	}
+
+	pipeline := NewFlowMappingPipeline()
+	pipeline.SetAttributeSchema(schema, false)
+
+	f1 := &flow.Flow{Attributes: map[string]string{"ja3_hash": "abc", "nat_a": "10.0.0.1", "DSCP": "46"}}
+	f2 := &flow.Flow{Attributes: map[string]string{"Ja3": "legacy", flow.FlowAttributeJA3: "canonical"}}
+	pipeline.Enhance([]*flow.Flow{f1, f2})
+
+	expected := map[string]string{flow.FlowAttributeJA3: "abc", flow.FlowAttributeNATA: "10.0.0.1", "DSCP": "46"}
+	if len(f1.Attributes) != len(expected) {
+		t.Errorf("Expected the attributes %v, got %v", expected, f1.Attributes)
+	}
+	for k, v := range expected {
+		if f1.Attributes[k] != v {
+			t.Errorf("Expected the attributes %v, got %v", expected, f1.Attributes)
+		}
+	}
+
+	// the canonical attribute wins over the legacy one
+	if len(f2.Attributes) != 1 || f2.Attributes[flow.FlowAttributeJA3] != "canonical" {
+		t.Errorf("Expected the canonical attribute to be kept, got %v", f2.Attributes)
+	}
+}
@@ -0,0 +1,172 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package mappings
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/redhat-cip/skydive/flow"
+	"github.com/redhat-cip/skydive/flow/packet"
+	"github.com/redhat-cip/skydive/topology/graph"
+)
+
+// FlowLookup is a graph lookup done by an enhancer, Reason explaining why
+// it didn't resolve the field
+type FlowLookup struct {
+	Field      string
+	Filter     graph.Metadata `json:",omitempty"`
+	Result     string         `json:",omitempty"`
+	Candidates []string       `json:",omitempty"`
+	Reason     string         `json:",omitempty"`
+}
+
+// FlowLookupTracer is implemented by the enhancers able to report the graph
+// lookups they do when enhancing a traced flow
+type FlowLookupTracer interface {
+	TraceEnhance(f *flow.Flow) []FlowLookup
+}
+
+// FlowTraceStage reports what an enhancer saw, the flow fields, and what it
+// changed
+type FlowTraceStage struct {
+	Stage    string
+	Input    map[string]interface{}
+	Changes  []FlowFieldChange
+	Lookups  []FlowLookup `json:",omitempty"`
+	Duration time.Duration
+	Error    string `json:",omitempty"`
+}
+
+// FlowTraceReport is the flow as enhanced by the pipeline along with the
+// report of each stage
+type FlowTraceReport struct {
+	Flow   *flow.Flow
+	Stages []FlowTraceStage
+}
+
+// lookupInterface looks up the interface having the given MAC address
+func lookupInterface(g *graph.Graph, field string, key string, mac string) FlowLookup {
+	lookup := FlowLookup{Field: field, Filter: graph.Metadata{key: mac}}
+
+	if packet.IsBroadcastMac(mac) || packet.IsMulticastMac(mac) {
+		lookup.Result = "*"
+		return lookup
+	}
+
+	g.Lock()
+	defer g.Unlock()
+
+	intfs := g.LookupNodes(lookup.Filter)
+	switch len(intfs) {
+	case 0:
+		lookup.Reason = fmt.Sprintf("no node with %s %s", key, mac)
+	case 1:
+		lookup.Result = string(intfs[0].ID)
+	default:
+		for _, intf := range intfs {
+			lookup.Candidates = append(lookup.Candidates, string(intf.ID))
+		}
+		lookup.Reason = fmt.Sprintf("%d nodes with %s %s: %s", len(intfs), key, mac, strings.Join(lookup.Candidates, ", "))
+	}
+
+	return lookup
+}
+
+// lookupInterfaces resolves the interfaces of the flow not resolved yet
+// using the Ethernet endpoints
+func lookupInterfaces(g *graph.Graph, f *flow.Flow, key string) []FlowLookup {
+	var lookups []FlowLookup
+
+	var eth *flow.FlowEndpointsStatistics
+	if f.IfSrcNodeUUID == "" || f.IfDstNodeUUID == "" {
+		if f.Statistics != nil {
+			eth = f.Statistics.GetEndpointsType(flow.FlowEndpointType_ETHERNET)
+		}
+		if eth == nil {
+			return []FlowLookup{{Field: "IfSrcNodeUUID", Reason: "no Ethernet endpoints"}, {Field: "IfDstNodeUUID", Reason: "no Ethernet endpoints"}}
+		}
+	}
+	if f.IfSrcNodeUUID == "" {
+		lookup := lookupInterface(g, "IfSrcNodeUUID", key, eth.AB.Value)
+		f.IfSrcNodeUUID = lookup.Result
+		lookups = append(lookups, lookup)
+	}
+	if f.IfDstNodeUUID == "" {
+		lookup := lookupInterface(g, "IfDstNodeUUID", key, eth.BA.Value)
+		f.IfDstNodeUUID = lookup.Result
+		lookups = append(lookups, lookup)
+	}
+
+	return lookups
+}
+
+// traceStage runs an enhancer on the flow, a panic being reported as the
+// error of the stage
+func traceStage(enhancer FlowEnhancer, f *flow.Flow, stage *FlowTraceStage) {
+	start := time.Now()
+	defer func() {
+		stage.Duration = time.Since(start)
+		if r := recover(); r != nil {
+			stage.Error = fmt.Sprintf("%v", r)
+		}
+	}()
+
+	if tracer, ok := enhancer.(FlowLookupTracer); ok {
+		stage.Lookups = tracer.TraceEnhance(f)
+	} else {
+		enhancer.Enhance(f)
+	}
+}
+
+// TraceFlow runs a copy of the flow through the enhancers and reports what
+// each of them did. The given flow isn't modified, a failing enhancer doesn't
+// prevent the next ones from being run.
+func (fe *FlowMappingPipeline) TraceFlow(f *flow.Flow) (*FlowTraceReport, error) {
+	f = proto.Clone(f).(*flow.Flow)
+	report := &FlowTraceReport{Flow: f, Stages: []FlowTraceStage{}}
+
+	before, err := snapshotFlow(f)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, enhancer := range fe.Enhancers {
+		stage := FlowTraceStage{Stage: stageName(enhancer), Input: before}
+		traceStage(enhancer, f, &stage)
+
+		after, err := snapshotFlow(f)
+		if err != nil {
+			return nil, err
+		}
+		stage.Changes = diffFlowFields(before, after)
+
+		report.Stages = append(report.Stages, stage)
+		before = after
+	}
+
+	return report, nil
+}
@@ -0,0 +1,136 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package mappings
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/redhat-cip/skydive/flow"
+	"github.com/redhat-cip/skydive/topology/graph"
+)
+
+type failingEnhancer struct {
+}
+
+func (e *failingEnhancer) Enhance(f *flow.Flow) {
+	f.IfDstNodeUUID = "partial"
+	panic("enhancer failure")
+}
+
+func newEthernetFlow(src, dst string) *flow.Flow {
+	return &flow.Flow{
+		UUID: "flow-uuid",
+		Statistics: &flow.FlowStatistics{
+			Endpoints: []*flow.FlowEndpointsStatistics{
+				{
+					Type: flow.FlowEndpointType_ETHERNET,
+					AB:   &flow.FlowEndpointStatistics{Value: src},
+					BA:   &flow.FlowEndpointStatistics{Value: dst},
+				},
+			},
+		},
+	}
+}
+
+func TestTraceFlow(t *testing.T) {
+	backend, _ := graph.NewMemoryBackend()
+	g, _ := graph.NewGraph(backend)
+
+	g.NewNode(graph.GenID(), graph.Metadata{"MAC": "00:00:00:00:00:01"})
+	g.NewNode(graph.GenID(), graph.Metadata{"MAC": "00:00:00:00:00:02"})
+	g.NewNode(graph.GenID(), graph.Metadata{"MAC": "00:00:00:00:00:02"})
+
+	pipeline := NewFlowMappingPipeline(&failingEnhancer{}, NewGraphFlowEnhancer(g), &testNodeEnhancer{})
+
+	f := newEthernetFlow("00:00:00:00:00:01", "00:00:00:00:00:02")
+	report, err := pipeline.TraceFlow(f)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// the traced flow is a copy
+	if f.IfSrcNodeUUID != "" || f.IfDstNodeUUID != "" || report.Flow == f {
+		t.Fatalf("The given flow shouldn't have been modified: %+v", f)
+	}
+
+	if len(report.Stages) != 3 {
+		t.Fatalf("Expected 3 stages, got: %+v", report.Stages)
+	}
+
+	failed := report.Stages[0]
+	if failed.Stage != "failingEnhancer" || failed.Error != "enhancer failure" || len(failed.Changes) != 1 {
+		t.Errorf("The failure should be reported along with the changes: %+v", failed)
+	}
+
+	// the next stages are still run, on the flow left by the failing one
+	stage := report.Stages[1]
+	if stage.Stage != "GraphFlowEnhancer" || stage.Error != "" || stage.Input["IfDstNodeUUID"] != "partial" {
+		t.Fatalf("Wrong graph stage: %+v", stage)
+	}
+	if len(stage.Lookups) != 1 || stage.Lookups[0].Field != "IfSrcNodeUUID" || stage.Lookups[0].Result == "" {
+		t.Errorf("Expected the source lookup only: %+v", stage.Lookups)
+	}
+	if len(stage.Changes) != 1 || stage.Changes[0].Field != "IfSrcNodeUUID" {
+		t.Errorf("Wrong graph stage changes: %+v", stage.Changes)
+	}
+
+	if report.Flow.IfSrcNodeUUID != stage.Lookups[0].Result {
+		t.Errorf("The report should give the enhanced flow: %+v", report.Flow)
+	}
+}
+
+func TestTraceFlowLookups(t *testing.T) {
+	backend, _ := graph.NewMemoryBackend()
+	g, _ := graph.NewGraph(backend)
+
+	g.NewNode(graph.GenID(), graph.Metadata{"MAC": "00:00:00:00:00:02"})
+	g.NewNode(graph.GenID(), graph.Metadata{"MAC": "00:00:00:00:00:02"})
+
+	pipeline := NewFlowMappingPipeline(NewGraphFlowEnhancer(g))
+
+	report, err := pipeline.TraceFlow(newEthernetFlow("00:00:00:00:00:01", "00:00:00:00:00:02"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	lookups := report.Stages[0].Lookups
+	if len(lookups) != 2 {
+		t.Fatalf("Expected 2 lookups, got: %+v", lookups)
+	}
+
+	if src := lookups[0]; src.Result != "" || !strings.Contains(src.Reason, "no node with MAC 00:00:00:00:00:01") {
+		t.Errorf("Wrong source lookup: %+v", src)
+	}
+	if dst := lookups[1]; dst.Result != "" || len(dst.Candidates) != 2 || !strings.HasPrefix(dst.Reason, "2 nodes with MAC") {
+		t.Errorf("Wrong destination lookup: %+v", dst)
+	}
+
+	report, err = pipeline.TraceFlow(&flow.Flow{UUID: "flow-uuid"})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if lookups := report.Stages[0].Lookups; len(lookups) != 2 || lookups[0].Reason != "no Ethernet endpoints" {
+		t.Errorf("The missing endpoints should be reported: %+v", lookups)
+	}
+}
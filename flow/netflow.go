@@ -0,0 +1,228 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package flow
+
+import (
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/google/gopacket/layers"
+)
+
+const (
+	// source of the flows not captured by the probes of the agents
+	FlowAttributeSource = "Source"
+	// address of the router exporting the NetFlow records of a flow
+	FlowAttributeNetFlowExporter = "NetFlowExporter"
+)
+
+// A NetFlow v5 datagram is a header of 24 bytes followed by at most 30
+// records of 48 bytes, the times of the records being given in milliseconds
+// since the boot of the exporter.
+const (
+	netFlowV5Version    = 5
+	netFlowV5HeaderSize = 24
+	netFlowV5RecordSize = 48
+	netFlowV5MaxRecords = 30
+)
+
+// NetFlowV5Header is the header of a NetFlow v5 datagram
+type NetFlowV5Header struct {
+	Count            uint16
+	SysUptime        uint32
+	UnixSecs         uint32
+	UnixNsecs        uint32
+	FlowSequence     uint32
+	EngineType       uint8
+	EngineID         uint8
+	SamplingInterval uint16
+}
+
+// NetFlowV5Record is a unidirectional flow record of a NetFlow v5 datagram
+type NetFlowV5Record struct {
+	SrcAddr  net.IP
+	DstAddr  net.IP
+	NextHop  net.IP
+	Input    uint16
+	Output   uint16
+	Packets  uint32
+	Octets   uint32
+	First    uint32
+	Last     uint32
+	SrcPort  uint16
+	DstPort  uint16
+	TCPFlags uint8
+	Protocol uint8
+	ToS      uint8
+	SrcAS    uint16
+	DstAS    uint16
+	SrcMask  uint8
+	DstMask  uint8
+}
+
+// DecodeNetFlowV5 decodes the header and the records of a NetFlow v5
+// datagram
+func DecodeNetFlowV5(data []byte) (*NetFlowV5Header, []*NetFlowV5Record, error) {
+	if len(data) < netFlowV5HeaderSize {
+		return nil, nil, fmt.Errorf("NetFlow datagram of %d bytes shorter than its header", len(data))
+	}
+	if version := binary.BigEndian.Uint16(data); version != netFlowV5Version {
+		return nil, nil, fmt.Errorf("unsupported NetFlow version %d", version)
+	}
+
+	header := &NetFlowV5Header{
+		Count:            binary.BigEndian.Uint16(data[2:]),
+		SysUptime:        binary.BigEndian.Uint32(data[4:]),
+		UnixSecs:         binary.BigEndian.Uint32(data[8:]),
+		UnixNsecs:        binary.BigEndian.Uint32(data[12:]),
+		FlowSequence:     binary.BigEndian.Uint32(data[16:]),
+		EngineType:       data[20],
+		EngineID:         data[21],
+		SamplingInterval: binary.BigEndian.Uint16(data[22:]),
+	}
+	if header.Count == 0 || header.Count > netFlowV5MaxRecords {
+		return nil, nil, fmt.Errorf("invalid NetFlow record count %d", header.Count)
+	}
+	if size := netFlowV5HeaderSize + int(header.Count)*netFlowV5RecordSize; len(data) < size {
+		return nil, nil, fmt.Errorf("NetFlow datagram of %d bytes truncated, %d records needing %d bytes", len(data), header.Count, size)
+	}
+
+	records := make([]*NetFlowV5Record, header.Count)
+	for i := range records {
+		r := data[netFlowV5HeaderSize+i*netFlowV5RecordSize:]
+		records[i] = &NetFlowV5Record{
+			SrcAddr:  net.IP(append([]byte{}, r[0:4]...)),
+			DstAddr:  net.IP(append([]byte{}, r[4:8]...)),
+			NextHop:  net.IP(append([]byte{}, r[8:12]...)),
+			Input:    binary.BigEndian.Uint16(r[12:]),
+			Output:   binary.BigEndian.Uint16(r[14:]),
+			Packets:  binary.BigEndian.Uint32(r[16:]),
+			Octets:   binary.BigEndian.Uint32(r[20:]),
+			First:    binary.BigEndian.Uint32(r[24:]),
+			Last:     binary.BigEndian.Uint32(r[28:]),
+			SrcPort:  binary.BigEndian.Uint16(r[32:]),
+			DstPort:  binary.BigEndian.Uint16(r[34:]),
+			TCPFlags: r[37],
+			Protocol: r[38],
+			ToS:      r[39],
+			SrcAS:    binary.BigEndian.Uint16(r[40:]),
+			DstAS:    binary.BigEndian.Uint16(r[42:]),
+			SrcMask:  r[44],
+			DstMask:  r[45],
+		}
+	}
+
+	return header, records, nil
+}
+
+// unixTime returns the time, in seconds, of an uptime of the exporter
+func (h *NetFlowV5Header) unixTime(uptime uint32) int64 {
+	exported := int64(h.UnixSecs)*1000 + int64(h.UnixNsecs)/1000000
+	// the uptimes wrap around after 49 days
+	return (exported - int64(h.SysUptime-uptime)) / 1000
+}
+
+// FlowsFromNetFlowV5 converts the records of a NetFlow v5 datagram into
+// flows, the statistics of a record being the ones of the endpoint A, its
+// source. The flows are tagged with the netflow source and the address of
+// the exporter.
+func FlowsFromNetFlowV5(data []byte, exporter string) ([]*Flow, error) {
+	header, records, err := DecodeNetFlowV5(data)
+	if err != nil {
+		return nil, err
+	}
+
+	flows := make([]*Flow, len(records))
+	for i, r := range records {
+		flows[i] = flowFromNetFlowV5Record(header, r, exporter)
+	}
+	return flows, nil
+}
+
+func flowFromNetFlowV5Record(h *NetFlowV5Header, r *NetFlowV5Record, exporter string) *Flow {
+	fs := &FlowStatistics{
+		Start: h.unixTime(r.First),
+		Last:  h.unixTime(r.Last),
+	}
+
+	network := &FlowEndpointsStatistics{
+		Type: FlowEndpointType_IPV4,
+		AB:   &FlowEndpointStatistics{Value: r.SrcAddr.String(), Packets: uint64(r.Packets), Bytes: uint64(r.Octets)},
+		BA:   &FlowEndpointStatistics{Value: r.DstAddr.String()},
+	}
+	network.hash(r.SrcAddr, r.DstAddr)
+	fs.Endpoints = append(fs.Endpoints, network)
+
+	path := "IPv4"
+	var transport *FlowEndpointsStatistics
+	switch layers.IPProtocol(r.Protocol) {
+	case layers.IPProtocolTCP:
+		path += "/TCP"
+		transport = &FlowEndpointsStatistics{Type: FlowEndpointType_TCPPORT}
+		transport.hash(layers.TCPPort(r.SrcPort), layers.TCPPort(r.DstPort))
+	case layers.IPProtocolUDP:
+		path += "/UDP"
+		transport = &FlowEndpointsStatistics{Type: FlowEndpointType_UDPPORT}
+		transport.hash(layers.UDPPort(r.SrcPort), layers.UDPPort(r.DstPort))
+	case layers.IPProtocolSCTP:
+		path += "/SCTP"
+		transport = &FlowEndpointsStatistics{Type: FlowEndpointType_SCTPPORT}
+		transport.hash(layers.SCTPPort(r.SrcPort), layers.SCTPPort(r.DstPort))
+	}
+	if transport != nil {
+		transport.AB = &FlowEndpointStatistics{Value: strconv.Itoa(int(r.SrcPort)), Packets: uint64(r.Packets), Bytes: uint64(r.Octets)}
+		transport.BA = &FlowEndpointStatistics{Value: strconv.Itoa(int(r.DstPort))}
+		fs.Endpoints = append(fs.Endpoints, transport)
+	}
+
+	flow := &Flow{
+		LayersPath: path,
+		Statistics: fs,
+		Duration:   fs.Last - fs.Start,
+		Attributes: map[string]string{
+			FlowAttributeSource:          "netflow",
+			FlowAttributeNetFlowExporter: exporter,
+		},
+	}
+
+	// identified as the flows of the probes, the exporter standing for the
+	// probe node
+	hasher := sha1.New()
+	hasher.Write([]byte(flow.LayersPath))
+	for _, ep := range fs.Endpoints {
+		hasher.Write(ep.Hash)
+	}
+	flow.TrackingID = hex.EncodeToString(hasher.Sum(nil))
+
+	bfStart := make([]byte, 8)
+	binary.BigEndian.PutUint64(bfStart, uint64(fs.Start))
+	hasher.Write(bfStart)
+	hasher.Write([]byte(exporter))
+	flow.UUID = hex.EncodeToString(hasher.Sum(nil))
+
+	return flow
+}
@@ -0,0 +1,115 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package flow
+
+import (
+	"net"
+	"testing"
+)
+
+func TestFlowsFromNetFlowV5(t *testing.T) {
+	data := ForgeTestNetFlowV5(t, 1500000000,
+		&NetFlowV5Record{
+			SrcAddr:  net.ParseIP("192.168.0.1"),
+			DstAddr:  net.ParseIP("192.168.0.2"),
+			Packets:  10,
+			Octets:   1500,
+			First:    3540000,
+			Last:     3590000,
+			SrcPort:  34567,
+			DstPort:  80,
+			Protocol: 6,
+		},
+		&NetFlowV5Record{
+			SrcAddr:  net.ParseIP("192.168.0.2"),
+			DstAddr:  net.ParseIP("192.168.0.1"),
+			Packets:  1,
+			Octets:   84,
+			First:    3600000,
+			Last:     3600000,
+			Protocol: 1,
+		},
+	)
+
+	flows, err := FlowsFromNetFlowV5(data, "10.0.0.1")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(flows) != 2 {
+		t.Fatalf("Expected 2 flows, got %d", len(flows))
+	}
+
+	f := flows[0]
+	if f.LayersPath != "IPv4/TCP" || f.Attributes[FlowAttributeSource] != "netflow" || f.Attributes[FlowAttributeNetFlowExporter] != "10.0.0.1" {
+		t.Errorf("Wrong flow: %+v", f)
+	}
+	if f.Statistics.Start != 1500000000-60 || f.Statistics.Last != 1500000000-10 || f.Duration != 50 {
+		t.Errorf("Wrong times: %+v", f.Statistics)
+	}
+
+	ipv4 := f.Statistics.GetEndpointsType(FlowEndpointType_IPV4)
+	if ipv4.AB.Value != "192.168.0.1" || ipv4.BA.Value != "192.168.0.2" || ipv4.AB.Packets != 10 || ipv4.AB.Bytes != 1500 {
+		t.Errorf("Wrong network endpoints: %+v", ipv4)
+	}
+	tcp := f.Statistics.GetEndpointsType(FlowEndpointType_TCPPORT)
+	if tcp == nil || tcp.AB.Value != "34567" || tcp.BA.Value != "80" {
+		t.Errorf("Wrong transport endpoints: %+v", tcp)
+	}
+
+	// the same endpoints give the same hash whatever their direction
+	if string(flows[1].Statistics.GetEndpointsType(FlowEndpointType_IPV4).Hash) != string(ipv4.Hash) {
+		t.Error("Expected the network endpoints to have a symmetric hash")
+	}
+	if flows[1].LayersPath != "IPv4" || len(flows[1].Statistics.Endpoints) != 1 {
+		t.Errorf("Wrong flow without transport: %+v", flows[1])
+	}
+	if flows[0].UUID == flows[1].UUID {
+		t.Error("Expected the flows to have different UUIDs")
+	}
+
+	again, _ := FlowsFromNetFlowV5(data, "10.0.0.1")
+	if again[0].UUID != f.UUID {
+		t.Error("Expected the UUID of the flow of a record to be stable")
+	}
+}
+
+func TestFlowsFromNetFlowV5Malformed(t *testing.T) {
+	data := ForgeTestNetFlowV5(t, 1500000000, &NetFlowV5Record{SrcAddr: net.ParseIP("192.168.0.1"), DstAddr: net.ParseIP("192.168.0.2")})
+
+	version9 := append([]byte{}, data...)
+	version9[1] = 9
+
+	empty := append([]byte{}, data[:netFlowV5HeaderSize]...)
+	empty[3] = 0
+
+	for name, malformed := range map[string][]byte{
+		"short header":     data[:10],
+		"version 9":        version9,
+		"no record":        empty,
+		"truncated record": data[:len(data)-1],
+	} {
+		if _, err := FlowsFromNetFlowV5(malformed, "10.0.0.1"); err == nil {
+			t.Errorf("Expected an error for the %s datagram", name)
+		}
+	}
+}
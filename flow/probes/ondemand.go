@@ -64,6 +64,10 @@ func (o *OnDemandProbeListener) probeFromType(n *graph.Node) FlowProbe {
 	return probe.(FlowProbe)
 }
 
+func isCapturing(n *graph.Node) bool {
+	return n.Metadata()["State.FlowCapture"] == "ON"
+}
+
 func (o *OnDemandProbeListener) registerProbe(n *graph.Node, capture *api.Capture) {
 	if !IsCaptureAllowed(n) {
 		logging.GetLogger().Errorf("Failed to register flow probe, type not supported %v", n)
@@ -78,6 +82,7 @@ func (o *OnDemandProbeListener) registerProbe(n *graph.Node, capture *api.Captur
 
 	if err := fprobe.RegisterProbe(n, capture); err != nil {
 		logging.GetLogger().Debugf("Failed to register flow probe: %s", err.Error())
+		return
 	}
 
 	o.Graph.AddMetadata(n, "State.FlowCapture", "ON")
@@ -97,6 +102,10 @@ func (o *OnDemandProbeListener) unregisterProbe(n *graph.Node) {
 }
 
 func (o *OnDemandProbeListener) OnNodeAdded(n *graph.Node) {
+	if isCapturing(n) {
+		return
+	}
+
 	nodes := o.Graph.LookupShortestPath(n, graph.Metadata{"Type": "host"}, graph.Metadata{"RelationType": "ownership"})
 	if len(nodes) == 0 {
 		return
@@ -127,6 +136,13 @@ func (o *OnDemandProbeListener) OnEdgeAdded(e *graph.Edge) {
 		return
 	}
 
+	// the interfaces are linked to their host or namespace once created, the
+	// probe path being known only then
+	if e.Metadata()["RelationType"] == "ownership" {
+		o.OnNodeAdded(child)
+		return
+	}
+
 	if parent.Metadata()["Type"] == "ovsbridge" {
 		o.OnNodeAdded(parent)
 		return
@@ -139,7 +155,9 @@ func (o *OnDemandProbeListener) OnEdgeAdded(e *graph.Edge) {
 }
 
 func (o *OnDemandProbeListener) OnNodeDeleted(n *graph.Node) {
-	o.unregisterProbe(n)
+	if isCapturing(n) {
+		o.unregisterProbe(n)
+	}
 }
 
 func (o *OnDemandProbeListener) onCaptureAdded(probePath string, capture *api.Capture) {
@@ -47,6 +47,7 @@ type OvsSFlowProbe struct {
 	Sampling      uint32
 	Polling       uint32
 	ProbeNodeUUID string
+	Tags          map[string]string
 }
 
 type OvsSFlowProbesHandler struct {
@@ -54,6 +55,8 @@ type OvsSFlowProbesHandler struct {
 	AnalyzerClient *analyzer.Client
 	ovsClient      *ovsdb.OvsClient
 	allocator      *sflow.SFlowAgentAllocator
+	// tags of the agent, merged with the ones of the captures
+	tags map[string]string
 }
 
 func probeID(i string) string {
@@ -65,6 +68,11 @@ func (p *OvsSFlowProbe) SetProbeNode(flow *flow.Flow) bool {
 	return true
 }
 
+// FlowTags returns the tags stamped on the flows created by the sFlow agent
+func (p *OvsSFlowProbe) FlowTags() map[string]string {
+	return p.Tags
+}
+
 func newInsertSFlowProbeOP(probe OvsSFlowProbe) (*libovsdb.Operation, error) {
 	sFlowRow := make(map[string]interface{})
 	sFlowRow["agent"] = probe.Interface
@@ -222,7 +230,7 @@ func (o *OvsSFlowProbesHandler) UnregisterSFlowProbeFromBridge(bridgeUUID string
 	return nil
 }
 
-func (o *OvsSFlowProbesHandler) RegisterProbeOnBridge(bridgeUUID string, uuid string) error {
+func (o *OvsSFlowProbesHandler) RegisterProbeOnBridge(bridgeUUID string, uuid string, tags map[string]string) error {
 	probe := OvsSFlowProbe{
 		ID:            probeID(bridgeUUID),
 		Interface:     "lo",
@@ -230,10 +238,14 @@ func (o *OvsSFlowProbesHandler) RegisterProbeOnBridge(bridgeUUID string, uuid st
 		Sampling:      1,
 		Polling:       0,
 		ProbeNodeUUID: uuid,
+		Tags:          tags,
 	}
 
 	agent, err := o.allocator.Alloc(bridgeUUID, &probe)
-	if err != nil && err != sflow.AgentAlreadyAllocated {
+	if err == sflow.AgentAlreadyAllocated {
+		// the capture was updated, its tags applying to the new flows
+		agent.SetFlowProbeNodeSetter(&probe)
+	} else if err != nil {
 		return err
 	}
 
@@ -252,7 +264,12 @@ func isOvsBridge(n *graph.Node) bool {
 
 func (o *OvsSFlowProbesHandler) RegisterProbe(n *graph.Node, capture *api.Capture) error {
 	if isOvsBridge(n) {
-		err := o.RegisterProbeOnBridge(n.Metadata()["UUID"].(string), string(n.ID))
+		if err := flow.ValidateTags(capture.Tags); err != nil {
+			return err
+		}
+		tags := flow.MergeTags(o.tags, capture.Tags)
+
+		err := o.RegisterProbeOnBridge(n.Metadata()["UUID"].(string), string(n.ID), tags)
 		if err != nil {
 			return err
 		}
@@ -286,7 +303,7 @@ func (o *OvsSFlowProbesHandler) Stop() {
 }
 
 func NewOvsSFlowProbesHandler(tb *probes.TopologyProbeBundle, g *graph.Graph,
-	m *mappings.FlowMappingPipeline, a *analyzer.Client, fta *flow.TableAllocator) *OvsSFlowProbesHandler {
+	m *mappings.FlowMappingPipeline, a *analyzer.Client, fta *flow.TableAllocator, tags map[string]string) *OvsSFlowProbesHandler {
 	probe := tb.GetProbe("ovsdb")
 	if probe == nil {
 		logging.GetLogger().Error("Agent.ovssflow probe depends on agent.ovsdb topology probe: agent.ovssflow probe can't start properly")
@@ -298,6 +315,7 @@ func NewOvsSFlowProbesHandler(tb *probes.TopologyProbeBundle, g *graph.Graph,
 		Graph:     g,
 		ovsClient: p.OvsMon.OvsClient,
 		allocator: sflow.NewSFlowAgentAllocator(a, m, fta),
+		tags:      tags,
 	}
 
 	return o
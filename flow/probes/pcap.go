@@ -50,16 +50,26 @@ type PcapProbe struct {
 	flowTable           *flow.Table
 	flowMappingPipeline *mappings.FlowMappingPipeline
 	flowTableAllocator  *flow.TableAllocator
+	lateBinder          *mappings.FlowLateBinder
+	quit                chan bool
+	tags                map[string]string
+	tagsLock            sync.RWMutex
 }
 
 type PcapProbesHandler struct {
+	graph.DefaultGraphListener
 	graph               *graph.Graph
 	analyzerClient      *analyzer.Client
 	flowMappingPipeline *mappings.FlowMappingPipeline
 	flowTableAllocator  *flow.TableAllocator
-	wg                  sync.WaitGroup
-	probes              map[string]*PcapProbe
-	probesLock          sync.RWMutex
+	lateBindingDelay    time.Duration
+	// tags of the agent, merged with the ones of the captures
+	tags map[string]string
+	wg   sync.WaitGroup
+	// probes by node ID, the interface names being unique only within a
+	// namespace
+	probes     map[graph.Identifier]*PcapProbe
+	probesLock sync.RWMutex
 }
 
 const (
@@ -71,42 +81,89 @@ func (p *PcapProbe) SetProbeNode(flow *flow.Flow) bool {
 	return true
 }
 
+// FlowTags returns the tags stamped on the flows created from now on
+func (p *PcapProbe) FlowTags() map[string]string {
+	p.tagsLock.RLock()
+	defer p.tagsLock.RUnlock()
+	return p.tags
+}
+
+func (p *PcapProbe) setTags(tags map[string]string) {
+	p.tagsLock.Lock()
+	p.tags = tags
+	p.tagsLock.Unlock()
+}
+
+func (p *PcapProbe) sendFlows(flows []*flow.Flow) {
+	if p.analyzerClient != nil && len(flows) > 0 {
+		p.analyzerClient.SendFlows(flows)
+	}
+}
+
 func (p *PcapProbe) asyncFlowPipeline(flows []*flow.Flow) {
+	if p.lateBinder != nil {
+		p.sendFlows(p.lateBinder.Enhance(flows))
+		return
+	}
+
 	if p.flowMappingPipeline != nil {
 		p.flowMappingPipeline.Enhance(flows)
 	}
-	if p.analyzerClient != nil {
-		p.analyzerClient.SendFlows(flows)
+	p.sendFlows(flows)
+}
+
+func (p *PcapProbe) expireFlowPipeline(flows []*flow.Flow) {
+	if p.lateBinder != nil {
+		p.sendFlows(p.lateBinder.Release(flows))
+		return
 	}
+
+	p.asyncFlowPipeline(flows)
 }
 
 func (p *PcapProbe) start() {
-	p.flowTable = p.flowTableAllocator.Alloc()
-	defer p.flowTable.UnregisterAll()
 	defer p.flowTableAllocator.Release(p.flowTable)
 
 	agentExpire := config.GetAgentExpire()
-	p.flowTable.RegisterExpire(p.asyncFlowPipeline, agentExpire, agentExpire)
+	p.flowTable.RegisterExpire(p.expireFlowPipeline, agentExpire, agentExpire)
 
 	agentUpdate := config.GetAgentUpdate()
 	p.flowTable.RegisterUpdated(p.asyncFlowPipeline, agentUpdate, agentUpdate)
 
+	// a nil channel never notifies when the late binding is disabled
+	var notified <-chan bool
+	if p.lateBinder != nil {
+		notified = p.lateBinder.Notified()
+	}
+
 	feedFlowTable := func() {
 		select {
 		case packet, ok := <-p.channel:
 			if ok {
 				flow.FlowFromGoPacket(p.flowTable, &packet, p)
 			}
+		case <-notified:
+			p.sendFlows(p.lateBinder.Retry())
+		case <-p.quit:
 		}
 	}
 	p.flowTable.RegisterDefault(feedFlowTable)
 
 	p.flowTable.Start()
+
+	p.flowTable.UnregisterAll()
+	if p.lateBinder != nil {
+		p.sendFlows(p.lateBinder.Flush())
+	}
 }
 
+// stop releases the capture handle at once, the flow table being stopped
+// asynchronously as expiring the flows may require the graph lock, held by
+// the callers of UnregisterProbe.
 func (p *PcapProbe) stop() {
 	p.handle.Close()
-	p.flowTable.Stop()
+	close(p.quit)
+	go p.flowTable.Stop()
 }
 
 func (p *PcapProbesHandler) RegisterProbe(n *graph.Node, capture *api.Capture) error {
@@ -115,8 +172,19 @@ func (p *PcapProbesHandler) RegisterProbe(n *graph.Node, capture *api.Capture) e
 	if name, ok := n.Metadata()["Name"]; ok && name != "" {
 		ifName := name.(string)
 
-		if _, ok := p.probes[ifName]; ok {
-			return errors.New(fmt.Sprintf("A pcap probe already exists for %s", ifName))
+		if err := flow.ValidateTags(capture.Tags); err != nil {
+			return err
+		}
+		tags := flow.MergeTags(p.tags, capture.Tags)
+
+		p.probesLock.RLock()
+		probe, found := p.probes[n.ID]
+		p.probesLock.RUnlock()
+
+		// the capture was updated, its tags applying to the new flows
+		if found {
+			probe.setTags(tags)
+			return nil
 		}
 
 		nodes := p.graph.LookupShortestPath(n, graph.Metadata{"Type": "host"}, graph.Metadata{"RelationType": "ownership"})
@@ -160,16 +228,23 @@ func (p *PcapProbesHandler) RegisterProbe(n *graph.Node, capture *api.Capture) e
 		packetSource := gopacket.NewPacketSource(handle, handle.LinkType())
 		packetChannel := packetSource.Packets()
 
-		probe := &PcapProbe{
+		probe = &PcapProbe{
 			handle:              handle,
 			channel:             packetChannel,
 			probeNodeUUID:       string(n.ID),
+			flowTable:           p.flowTableAllocator.Alloc(),
 			flowMappingPipeline: p.flowMappingPipeline,
 			flowTableAllocator:  p.flowTableAllocator,
 			analyzerClient:      p.analyzerClient,
+			quit:                make(chan bool),
+			tags:                tags,
 		}
+		if p.lateBindingDelay > 0 && p.flowMappingPipeline != nil {
+			probe.lateBinder = mappings.NewFlowLateBinder(p.flowMappingPipeline, p.lateBindingDelay)
+		}
+
 		p.probesLock.Lock()
-		p.probes[ifName] = probe
+		p.probes[n.ID] = probe
 		p.probesLock.Unlock()
 		p.wg.Add(1)
 
@@ -182,11 +257,11 @@ func (p *PcapProbesHandler) RegisterProbe(n *graph.Node, capture *api.Capture) e
 	return nil
 }
 
-func (p *PcapProbesHandler) unregisterProbe(ifName string) error {
-	if probe, ok := p.probes[ifName]; ok {
-		logging.GetLogger().Debugf("Terminating pcap capture on %s", ifName)
+func (p *PcapProbesHandler) unregisterProbe(id graph.Identifier) error {
+	if probe, ok := p.probes[id]; ok {
+		logging.GetLogger().Debugf("Terminating pcap capture on %s", id)
 		probe.stop()
-		delete(p.probes, ifName)
+		delete(p.probes, id)
 	}
 
 	return nil
@@ -196,37 +271,70 @@ func (p *PcapProbesHandler) UnregisterProbe(n *graph.Node) error {
 	p.probesLock.Lock()
 	defer p.probesLock.Unlock()
 
-	if name, ok := n.Metadata()["Name"]; ok && name != "" {
-		ifName := name.(string)
-		err := p.unregisterProbe(ifName)
-		if err != nil {
-			return err
+	return p.unregisterProbe(n.ID)
+}
+
+// ActiveProbes returns the number of running captures, each one holding a
+// pcap handle
+func (p *PcapProbesHandler) ActiveProbes() int {
+	p.probesLock.RLock()
+	defer p.probesLock.RUnlock()
+
+	return len(p.probes)
+}
+
+func (p *PcapProbesHandler) notifyLateBinders(n *graph.Node) {
+	if _, ok := n.Metadata()["MAC"]; !ok {
+		return
+	}
+
+	p.probesLock.RLock()
+	for _, probe := range p.probes {
+		if probe.lateBinder != nil {
+			probe.lateBinder.Notify()
 		}
 	}
-	return nil
+	p.probesLock.RUnlock()
+}
+
+func (p *PcapProbesHandler) OnNodeAdded(n *graph.Node) {
+	p.notifyLateBinders(n)
+}
+
+func (p *PcapProbesHandler) OnNodeUpdated(n *graph.Node) {
+	p.notifyLateBinders(n)
 }
 
 func (p *PcapProbesHandler) Start() {
+	if p.lateBindingDelay > 0 {
+		p.graph.AddEventListener(p)
+	}
 }
 
 func (p *PcapProbesHandler) Stop() {
+	if p.lateBindingDelay > 0 {
+		p.graph.RemoveEventListener(p)
+	}
+
 	p.probesLock.Lock()
 	defer p.probesLock.Unlock()
 
-	for name := range p.probes {
-		p.unregisterProbe(name)
+	for id := range p.probes {
+		p.unregisterProbe(id)
 	}
 	p.wg.Wait()
 }
 
 func NewPcapProbesHandler(tb *probes.TopologyProbeBundle, g *graph.Graph,
-	p *mappings.FlowMappingPipeline, a *analyzer.Client, fta *flow.TableAllocator) *PcapProbesHandler {
+	p *mappings.FlowMappingPipeline, a *analyzer.Client, fta *flow.TableAllocator, tags map[string]string) *PcapProbesHandler {
 	handler := &PcapProbesHandler{
 		graph:               g,
 		analyzerClient:      a,
 		flowMappingPipeline: p,
 		flowTableAllocator:  fta,
-		probes:              make(map[string]*PcapProbe),
+		lateBindingDelay:    time.Duration(config.GetConfig().GetInt("agent.flow.late_binding_delay")) * time.Second,
+		tags:                tags,
+		probes:              make(map[graph.Identifier]*PcapProbe),
 	}
 	return handler
 }
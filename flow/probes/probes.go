@@ -57,6 +57,12 @@ func NewFlowProbeBundleFromConfig(tb *probes.TopologyProbeBundle, g *graph.Graph
 
 	gfe := mappings.NewGraphFlowEnhancer(g)
 
+	tags := config.GetConfig().GetStringMapString("agent.flow.tags")
+	if err := flow.ValidateTags(tags); err != nil {
+		logging.GetLogger().Errorf("Invalid agent flow tags: %s", err.Error())
+		return nil
+	}
+
 	var aclient *analyzer.Client
 
 	addr, port, err := config.GetAnalyzerClientAddr()
@@ -66,7 +72,7 @@ func NewFlowProbeBundleFromConfig(tb *probes.TopologyProbeBundle, g *graph.Graph
 	}
 
 	if addr != "" {
-		aclient, err = analyzer.NewClient(addr, port)
+		aclient, err = analyzer.NewClientFromConfig(addr, port)
 		if err != nil {
 			logging.GetLogger().Errorf("Analyzer client error %s:%d : %s", addr, port, err.Error())
 			return nil
@@ -84,14 +90,14 @@ func NewFlowProbeBundleFromConfig(tb *probes.TopologyProbeBundle, g *graph.Graph
 			ofe := mappings.NewOvsFlowEnhancer(g)
 			pipeline := mappings.NewFlowMappingPipeline(gfe, ofe)
 
-			o := NewOvsSFlowProbesHandler(tb, g, pipeline, aclient, fta)
+			o := NewOvsSFlowProbesHandler(tb, g, pipeline, aclient, fta, tags)
 			if o != nil {
 				probes[t] = o
 			}
 		case "pcap":
 			pipeline := mappings.NewFlowMappingPipeline(gfe)
 
-			o := NewPcapProbesHandler(tb, g, pipeline, aclient, fta)
+			o := NewPcapProbesHandler(tb, g, pipeline, aclient, fta, tags)
 			if o != nil {
 				probes[t] = o
 			}
@@ -0,0 +1,103 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package flow
+
+import (
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+const (
+	FlowRoleClient  = "client"
+	FlowRoleServer  = "server"
+	FlowRoleUnknown = "unknown"
+)
+
+const (
+	// ports below are considered as service ports
+	wellKnownPortMax = 1024
+)
+
+// rolesFromPorts returns the roles of A and B if one port only is a well
+// known port, the heuristic is not conclusive otherwise
+func rolesFromPorts(a, b int) (string, string, bool) {
+	switch {
+	case a < wellKnownPortMax && b >= wellKnownPortMax:
+		return FlowRoleServer, FlowRoleClient, true
+	case b < wellKnownPortMax && a >= wellKnownPortMax:
+		return FlowRoleClient, FlowRoleServer, true
+	}
+	return FlowRoleUnknown, FlowRoleUnknown, false
+}
+
+// detectRoles guesses the endpoint roles from the first packet of the flow,
+// A being the source of this packet.
+func (flow *Flow) detectRoles(packet *gopacket.Packet) {
+	flow.A_Role, flow.B_Role = FlowRoleUnknown, FlowRoleUnknown
+
+	if layer := (*packet).Layer(layers.LayerTypeTCP); layer != nil {
+		tcp := layer.(*layers.TCP)
+		switch {
+		case tcp.SYN && !tcp.ACK:
+			flow.A_Role, flow.B_Role = FlowRoleClient, FlowRoleServer
+		case tcp.SYN && tcp.ACK:
+			flow.A_Role, flow.B_Role = FlowRoleServer, FlowRoleClient
+		default:
+			// handshake not observed, only trust the ports
+			flow.A_Role, flow.B_Role, _ = rolesFromPorts(int(tcp.SrcPort), int(tcp.DstPort))
+		}
+		return
+	}
+
+	if layer := (*packet).Layer(layers.LayerTypeUDP); layer != nil {
+		udp := layer.(*layers.UDP)
+
+		var ok bool
+		if flow.A_Role, flow.B_Role, ok = rolesFromPorts(int(udp.SrcPort), int(udp.DstPort)); !ok {
+			// the sender of the first packet is considered as the client
+			flow.A_Role, flow.B_Role = FlowRoleClient, FlowRoleServer
+		}
+	}
+}
+
+// GetServerEndpoint returns the endpoint of the given type playing the server
+// role, nil if roles are unknown
+func (flow *Flow) GetServerEndpoint(eptype FlowEndpointType) *FlowEndpointStatistics {
+	fs := flow.GetStatistics()
+	if fs == nil {
+		return nil
+	}
+
+	ep := fs.GetEndpointsType(eptype)
+	if ep == nil {
+		return nil
+	}
+
+	switch {
+	case flow.A_Role == FlowRoleServer:
+		return ep.AB
+	case flow.B_Role == FlowRoleServer:
+		return ep.BA
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package flow
+
+import (
+	"net"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+func forgeRoleTestPacket(t *testing.T, transport gopacket.SerializableLayer) *gopacket.Packet {
+	ip := &layers.IPv4{
+		SrcIP: net.IP{192, 168, 0, 1},
+		DstIP: net.IP{192, 168, 0, 2},
+	}
+
+	switch transport.(type) {
+	case *layers.TCP:
+		ip.Protocol = layers.IPProtocolTCP
+	case *layers.UDP:
+		ip.Protocol = layers.IPProtocolUDP
+	}
+
+	data := serializeTestLayers(t,
+		&layers.Ethernet{
+			SrcMAC:       net.HardwareAddr{0x00, 0x0F, 0xAA, 0xFA, 0xAA, 0x01},
+			DstMAC:       net.HardwareAddr{0x00, 0x0D, 0xBD, 0xBD, 0x02, 0xBD},
+			EthernetType: layers.EthernetTypeIPv4,
+		},
+		ip, transport,
+		gopacket.Payload([]byte{10, 20, 30}),
+	)
+
+	packet := gopacket.NewPacket(data, layers.LayerTypeEthernet, gopacket.Default)
+	return &packet
+}
+
+func TestFlowRoles(t *testing.T) {
+	tests := []struct {
+		name      string
+		transport gopacket.SerializableLayer
+		aRole     string
+		bRole     string
+	}{
+		{"syn", &layers.TCP{SrcPort: 34567, DstPort: 8080, SYN: true}, FlowRoleClient, FlowRoleServer},
+		{"syn-ack", &layers.TCP{SrcPort: 8080, DstPort: 34567, SYN: true, ACK: true}, FlowRoleServer, FlowRoleClient},
+		{"mid-life", &layers.TCP{SrcPort: 34567, DstPort: 8080, ACK: true}, FlowRoleUnknown, FlowRoleUnknown},
+		{"mid-life well known port", &layers.TCP{SrcPort: 80, DstPort: 34567, ACK: true}, FlowRoleServer, FlowRoleClient},
+		{"udp first packet", &layers.UDP{SrcPort: 34567, DstPort: 5000}, FlowRoleClient, FlowRoleServer},
+		{"udp well known port", &layers.UDP{SrcPort: 53, DstPort: 34567}, FlowRoleServer, FlowRoleClient},
+	}
+
+	for _, test := range tests {
+		ft := NewTable()
+
+		flow := FlowFromGoPacket(ft, forgeRoleTestPacket(t, test.transport), nil)
+		if flow == nil {
+			t.Fatalf("%s: flow not created", test.name)
+		}
+
+		if flow.A_Role != test.aRole || flow.B_Role != test.bRole {
+			t.Errorf("%s: expected roles %s/%s, got %s/%s", test.name, test.aRole, test.bRole, flow.A_Role, flow.B_Role)
+		}
+	}
+}
+
+func TestFlowServerEndpoint(t *testing.T) {
+	ft := NewTable()
+
+	flow := FlowFromGoPacket(ft, forgeRoleTestPacket(t, &layers.TCP{SrcPort: 8080, DstPort: 34567, SYN: true, ACK: true}), nil)
+
+	ep := flow.GetServerEndpoint(FlowEndpointType_IPV4)
+	if ep == nil || ep.Value != "192.168.0.1" {
+		t.Errorf("Wrong server endpoint: %v", ep)
+	}
+
+	ft = NewTable()
+	flow = FlowFromGoPacket(ft, forgeRoleTestPacket(t, &layers.TCP{SrcPort: 34567, DstPort: 8080, ACK: true}), nil)
+	if ep := flow.GetServerEndpoint(FlowEndpointType_IPV4); ep != nil {
+		t.Errorf("No server endpoint expected for unknown roles, got: %v", ep)
+	}
+}
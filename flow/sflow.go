@@ -0,0 +1,205 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package flow
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// An sFlow v5 datagram is XDR encoded: a header giving the address of the
+// agent and the number of samples, each sample and each record of a flow
+// sample being prefixed by its format and its length, so that the ones not
+// decoded are skipped.
+const (
+	sFlowVersion = 5
+
+	sFlowFlowSample            = 1
+	sFlowExpandedFlowSample    = 3
+	sFlowRawPacketHeaderRecord = 1
+	sFlowEthernetHeader        = 1
+)
+
+// address of the sFlow agent having sampled the packets of a flow
+const FlowAttributeSFlowAgent = "SFlowAgent"
+
+var errSFlowTruncated = errors.New("truncated")
+
+// SFlowPacketSample is the header of a packet sampled by an sFlow agent one
+// out of SamplingRate packets, FrameLength being the length of the whole
+// frame, without the bytes stripped by the agent such as the FCS
+type SFlowPacketSample struct {
+	SamplingRate uint32
+	FrameLength  uint32
+	Header       []byte
+}
+
+// SFlowDatagram is an sFlow v5 datagram, only the ethernet packet headers
+// of its flow samples being decoded
+type SFlowDatagram struct {
+	AgentAddress   net.IP
+	SequenceNumber uint32
+	Samples        []SFlowPacketSample
+}
+
+// xdrReader reads the big endian fields of a datagram, the first read past
+// its end making the reader fail
+type xdrReader struct {
+	data []byte
+	err  error
+}
+
+func (r *xdrReader) uint32() uint32 {
+	b := r.bytes(4)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+func (r *xdrReader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(r.data) {
+		r.err = errSFlowTruncated
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+// opaque reads a field of n bytes padded to a multiple of 4 bytes
+func (r *xdrReader) opaque(n int) []byte {
+	b := r.bytes(n)
+	r.bytes((4 - n%4) % 4)
+	return b
+}
+
+// DecodeSFlowDatagram decodes an sFlow v5 datagram, the counter samples and
+// the records other than the ethernet packet headers being skipped
+func DecodeSFlowDatagram(data []byte) (*SFlowDatagram, error) {
+	r := &xdrReader{data: data}
+
+	if version := r.uint32(); r.err == nil && version != sFlowVersion {
+		return nil, fmt.Errorf("unsupported sFlow version %d", version)
+	}
+
+	d := &SFlowDatagram{}
+	switch addrType := r.uint32(); addrType {
+	case 1:
+		d.AgentAddress = net.IP(r.bytes(4))
+	case 2:
+		d.AgentAddress = net.IP(r.bytes(16))
+	default:
+		if r.err == nil {
+			return nil, fmt.Errorf("invalid sFlow agent address type %d", addrType)
+		}
+	}
+	r.uint32() // sub agent
+	d.SequenceNumber = r.uint32()
+	r.uint32() // uptime
+
+	count := r.uint32()
+	for i := uint32(0); i < count && r.err == nil; i++ {
+		format := r.uint32()
+		sample := &xdrReader{data: r.opaque(int(r.uint32()))}
+		if r.err != nil {
+			break
+		}
+
+		// the counter samples and the samples of the vendors are skipped
+		switch format {
+		case sFlowFlowSample:
+			sample.bytes(8) // sequence and source
+		case sFlowExpandedFlowSample:
+			sample.bytes(12)
+		default:
+			continue
+		}
+		rate := sample.uint32()
+		if format == sFlowFlowSample {
+			sample.bytes(16) // pool, drops, input and output
+		} else {
+			sample.bytes(24)
+		}
+
+		records := sample.uint32()
+		for j := uint32(0); j < records && sample.err == nil; j++ {
+			recordFormat := sample.uint32()
+			record := &xdrReader{data: sample.opaque(int(sample.uint32()))}
+			if sample.err != nil || recordFormat != sFlowRawPacketHeaderRecord {
+				continue
+			}
+
+			protocol := record.uint32()
+			frameLength := record.uint32()
+			stripped := record.uint32()
+			header := record.opaque(int(record.uint32()))
+			if record.err != nil {
+				return nil, fmt.Errorf("sFlow sample %d, record %d: %s", i, j, record.err.Error())
+			}
+			if protocol != sFlowEthernetHeader {
+				continue
+			}
+			if stripped < frameLength {
+				frameLength -= stripped
+			}
+
+			d.Samples = append(d.Samples, SFlowPacketSample{
+				SamplingRate: rate,
+				FrameLength:  frameLength,
+				Header:       header,
+			})
+		}
+		if sample.err != nil {
+			return nil, fmt.Errorf("sFlow sample %d: %s", i, sample.err.Error())
+		}
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("sFlow datagram of %d bytes: %s", len(data), r.err.Error())
+	}
+
+	return d, nil
+}
+
+// FlowsFromSFlowDatagram updates the flows of the packets sampled in an
+// sFlow v5 datagram, their statistics being scaled by the sampling rate
+func FlowsFromSFlowDatagram(ft *Table, d *SFlowDatagram, setter FlowProbeNodeSetter) []*Flow {
+	flows := []*Flow{}
+
+	for _, sample := range d.Samples {
+		packet := gopacket.NewPacket(sample.Header, layers.LayerTypeEthernet, gopacket.Default)
+		if flow := FlowFromSampledGoPacket(ft, &packet, setter, sample.SamplingRate, sample.FrameLength); flow != nil {
+			flows = append(flows, flow)
+		}
+	}
+
+	return flows
+}
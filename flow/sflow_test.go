@@ -0,0 +1,101 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package flow
+
+import (
+	"net"
+	"testing"
+)
+
+func TestFlowsFromSFlowDatagram(t *testing.T) {
+	data := ForgeTestSFlowDatagram(t, net.ParseIP("10.0.0.1"), 100, 1, 2)
+
+	d, err := DecodeSFlowDatagram(data)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if d.AgentAddress.String() != "10.0.0.1" || len(d.Samples) != 2 || d.Samples[0].SamplingRate != 100 {
+		t.Fatalf("Wrong datagram: %+v", d)
+	}
+
+	ft := NewTable()
+	flows := FlowsFromSFlowDatagram(ft, d, nil)
+	if len(flows) != 2 {
+		t.Fatalf("Expected 2 flows, got %d", len(flows))
+	}
+
+	// the same packets sampled again
+	FlowsFromSFlowDatagram(ft, d, nil)
+
+	for _, f := range flows {
+		if f.LayersPath != "Ethernet/IPv4/TCP/Payload" {
+			t.Errorf("Wrong layers: %s", f.LayersPath)
+		}
+		ipv4 := f.Statistics.GetEndpointsType(FlowEndpointType_IPV4)
+		if ipv4.AB.Packets != 200 || ipv4.AB.Bytes != 200*43 {
+			t.Errorf("Expected the statistics to be scaled by the sampling rate: %+v", ipv4.AB)
+		}
+	}
+}
+
+func TestDecodeSFlowDatagramMalformed(t *testing.T) {
+	data := ForgeTestSFlowDatagram(t, net.ParseIP("10.0.0.1"), 100, 1)
+
+	version4 := append([]byte{}, data...)
+	version4[3] = 4
+	if _, err := DecodeSFlowDatagram(version4); err == nil {
+		t.Error("Expected the version 4 to be rejected")
+	}
+
+	// truncated anywhere, the datagram fails to decode without panicking
+	for n := 0; n < len(data); n++ {
+		if _, err := DecodeSFlowDatagram(data[:n]); err == nil {
+			t.Errorf("Expected the datagram truncated at %d bytes to fail", n)
+		}
+	}
+}
+
+func TestFlowsFromSFlowTruncatedHeader(t *testing.T) {
+	frame := (*forgeTestPacket(t, 1, false, ETH, IPv4, TCP)).Data()
+
+	whole := &SFlowDatagram{Samples: []SFlowPacketSample{{SamplingRate: 100, FrameLength: uint32(len(frame)), Header: frame}}}
+	expected := FlowsFromSFlowDatagram(NewTable(), whole, nil)[0]
+
+	// the agent sampled the header only, the padding and a part of the
+	// payload being cut off
+	truncated := &SFlowDatagram{Samples: []SFlowPacketSample{{SamplingRate: 100, FrameLength: uint32(len(frame)), Header: frame[:len(frame)-5]}}}
+	flows := FlowsFromSFlowDatagram(NewTable(), truncated, nil)
+	if len(flows) != 1 {
+		t.Fatalf("Expected a flow, got %d", len(flows))
+	}
+
+	for _, ep := range expected.Statistics.Endpoints {
+		got := flows[0].Statistics.GetEndpointsType(ep.Type)
+		if got == nil || got.AB.Bytes != ep.AB.Bytes || got.AB.Packets != ep.AB.Packets {
+			t.Errorf("Expected the %s statistics of the whole frame %+v, got %+v", ep.Type, ep.AB, got)
+		}
+	}
+	if eth := flows[0].Statistics.GetEndpointsType(FlowEndpointType_ETHERNET); eth.AB.Bytes != 100*uint64(len(frame)) {
+		t.Errorf("Expected the ethernet bytes of the frame, got %d", eth.AB.Bytes)
+	}
+}
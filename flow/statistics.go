@@ -55,15 +55,22 @@ func NewFlowStatistics(packet *gopacket.Packet) *FlowStatistics {
 }
 
 func (fs *FlowStatistics) Update(packet *gopacket.Packet) {
-	err := fs.updateLinkLayerStatistics(packet)
+	fs.update(packet, 1, 0)
+}
+
+// update counts a packet standing for weight packets, as the packets
+// sampled at a rate, the truncated bytes cut off from the end of the packet
+// being counted in the bytes of the layers
+func (fs *FlowStatistics) update(packet *gopacket.Packet, weight uint64, truncated uint64) {
+	err := fs.updateLinkLayerStatistics(packet, weight, truncated)
 	if err != nil {
 		return
 	}
-	err = fs.updateNetworkLayerStatistics(packet)
+	err = fs.updateNetworkLayerStatistics(packet, weight)
 	if err != nil {
 		return
 	}
-	err = fs.updateTransportLayerStatistics(packet)
+	err = fs.updateTransportLayerStatistics(packet, weight, truncated)
 	if err != nil {
 		return
 	}
@@ -112,7 +119,7 @@ func (fs *FlowStatistics) newLinkLayerEndpointStatistics(packet *gopacket.Packet
 	return nil
 }
 
-func (fs *FlowStatistics) updateLinkLayerStatistics(packet *gopacket.Packet) error {
+func (fs *FlowStatistics) updateLinkLayerStatistics(packet *gopacket.Packet, weight uint64, truncated uint64) error {
 	ep := fs.Endpoints[FlowEndpointLayer_LINK]
 	ethernetLayer := (*packet).Layer(layers.LayerTypeEthernet)
 	ethernetPacket, ok := ethernetLayer.(*layers.Ethernet)
@@ -126,11 +133,11 @@ func (fs *FlowStatistics) updateLinkLayerStatistics(packet *gopacket.Packet) err
 	} else {
 		e = ep.BA
 	}
-	e.Packets += uint64(1)
+	e.Packets += weight
 	if ethernetPacket.Length > 0 { // LLC
-		e.Bytes += uint64(ethernetPacket.Length)
+		e.Bytes += weight * uint64(ethernetPacket.Length)
 	} else {
-		e.Bytes += uint64(len(ethernetPacket.Contents) + len(ethernetPacket.Payload))
+		e.Bytes += weight * (uint64(len(ethernetPacket.Contents)+len(ethernetPacket.Payload)) + truncated)
 	}
 	return nil
 }
@@ -154,7 +161,7 @@ func (fs *FlowStatistics) newNetworkLayerEndpointStatistics(packet *gopacket.Pac
 	return nil
 }
 
-func (fs *FlowStatistics) updateNetworkLayerStatistics(packet *gopacket.Packet) error {
+func (fs *FlowStatistics) updateNetworkLayerStatistics(packet *gopacket.Packet, weight uint64) error {
 	ipv4Layer := (*packet).Layer(layers.LayerTypeIPv4)
 	ipv4Packet, ok := ipv4Layer.(*layers.IPv4)
 	if !ok {
@@ -169,8 +176,8 @@ func (fs *FlowStatistics) updateNetworkLayerStatistics(packet *gopacket.Packet)
 	} else {
 		e = ep.BA
 	}
-	e.Packets += uint64(1)
-	e.Bytes += uint64(ipv4Packet.Length)
+	e.Packets += weight
+	e.Bytes += weight * uint64(ipv4Packet.Length)
 	return nil
 }
 
@@ -220,7 +227,7 @@ func (fs *FlowStatistics) newTransportLayerEndpointStatistics(packet *gopacket.P
 	return nil
 }
 
-func (fs *FlowStatistics) updateTransportLayerStatistics(packet *gopacket.Packet) error {
+func (fs *FlowStatistics) updateTransportLayerStatistics(packet *gopacket.Packet, weight uint64, truncated uint64) error {
 	if len(fs.Endpoints) <= int(FlowEndpointLayer_TRANSPORT) {
 		return errors.New("Unable to decode the transport layer")
 	}
@@ -248,7 +255,23 @@ func (fs *FlowStatistics) updateTransportLayerStatistics(packet *gopacket.Packet
 	} else {
 		e = ep.BA
 	}
-	e.Packets += uint64(1)
-	e.Bytes += uint64(len(transportLayer.LayerContents()) + len(transportLayer.LayerPayload()))
+	e.Packets += weight
+	e.Bytes += weight * transportLength(packet, transportLayer, truncated)
 	return nil
 }
+
+// transportLength returns the length of the transport layer of a packet,
+// given by the IPv4 header when the end of the packet was truncated, the
+// truncated bytes possibly being the padding of the frame
+func transportLength(packet *gopacket.Packet, transportLayer gopacket.Layer, truncated uint64) uint64 {
+	length := uint64(len(transportLayer.LayerContents()) + len(transportLayer.LayerPayload()))
+	if truncated == 0 {
+		return length
+	}
+
+	ipv4Packet, ok := (*packet).Layer(layers.LayerTypeIPv4).(*layers.IPv4)
+	if !ok || int(ipv4Packet.Length) < len(ipv4Packet.Contents) {
+		return length + truncated
+	}
+	return uint64(int(ipv4Packet.Length) - len(ipv4Packet.Contents))
+}
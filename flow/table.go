@@ -68,11 +68,29 @@ type Table struct {
 	reply       chan *TableReply
 	running     atomic.Value
 	wg          sync.WaitGroup
+	decoders    *DecoderChain
+	expireBatch int
+	// receive times of the updated flows, used in place of the flow
+	// timestamps when these ones are too far from the local clock
+	skewTolerance int64
+	received      map[string]receivedFlow
+	// lifecycle events of the flows, along with the state of the flows at
+	// their last event
+	eventListener FlowEventListener
+	updatePolicy  FlowUpdatePolicy
+	emitted       map[string]emittedFlow
+}
+
+type receivedFlow struct {
+	time   int64
+	skewed bool
 }
 
 func NewTable() *Table {
 	return &Table{
 		table:     make(map[string]*Flow),
+		received:  make(map[string]receivedFlow),
+		emitted:   make(map[string]emittedFlow),
 		flush:     make(chan bool),
 		flushDone: make(chan bool),
 		query:     make(chan *TableQuery),
@@ -86,6 +104,131 @@ func NewTableFromFlows(flows []*Flow) *Table {
 	return nft
 }
 
+func (ft *Table) SetDecoderChain(decoders *DecoderChain) {
+	ft.lock.Lock()
+	ft.decoders = decoders
+	ft.lock.Unlock()
+}
+
+// SetExpireBatchSize bounds the number of flows given to the expire callback
+// per call, 0 meaning all the expired flows in one call
+func (ft *Table) SetExpireBatchSize(size int) {
+	ft.lock.Lock()
+	ft.expireBatch = size
+	ft.lock.Unlock()
+}
+
+// SetSkewTolerance sets how far from the local clock the last seen time of
+// an updated flow can be. Beyond, the flow is expired according to the time
+// it was received. 0 disables the check.
+func (ft *Table) SetSkewTolerance(tolerance time.Duration) {
+	ft.lock.Lock()
+	ft.skewTolerance = int64(tolerance.Seconds())
+	ft.lock.Unlock()
+}
+
+// SetEventListener sets the listener of the lifecycle events of the flows
+// updated by Update, the FlowUpdated events being emitted following the
+// policy
+func (ft *Table) SetEventListener(listener FlowEventListener, policy FlowUpdatePolicy) {
+	ft.lock.Lock()
+	ft.eventListener = listener
+	ft.updatePolicy = policy
+	ft.lock.Unlock()
+}
+
+// flowEvent returns the event of a flow entering or updated in the table,
+// nil if no event is due. Must be called under ft.lock.Lock()
+func (ft *Table) flowEvent(f *Flow, created bool, now int64) *FlowEvent {
+	t := FlowCreated
+	if !created {
+		if !ft.updatePolicy.updateDue(ft.emitted[f.UUID], f, now) {
+			return nil
+		}
+		t = FlowUpdated
+	}
+
+	ft.emitted[f.UUID] = emittedFlow{time: now, bytes: flowBytes(f)}
+	return newFlowEvent(t, f)
+}
+
+// receive records the receive time of an updated flow, logging once the
+// flows whose timestamp is skewed. Must be called under ft.lock.Lock()
+func (ft *Table) receive(f *Flow, now int64) {
+	if ft.skewTolerance <= 0 || f.Statistics == nil {
+		return
+	}
+
+	skew := f.Statistics.Last - now
+	skewed := skew > ft.skewTolerance || -skew > ft.skewTolerance
+
+	if skewed && !ft.received[f.UUID].skewed {
+		logging.GetLogger().Warningf("Flow %s of %s has a clock skew of %ds, its receive time being used for its expiry",
+			f.UUID, f.ProbeNodeUUID, skew)
+	}
+	ft.received[f.UUID] = receivedFlow{time: now, skewed: skewed}
+}
+
+// lastSeen returns the last seen time of the flow, its receive time if it
+// is skewed. Must be called under ft.lock
+func (ft *Table) lastSeen(f *Flow) int64 {
+	if r, ok := ft.received[f.UUID]; ok && r.skewed {
+		return r.time
+	}
+	return f.GetStatistics().Last
+}
+
+// TableSummary sums up the content of the table without the flows
+type TableSummary struct {
+	Flows int
+	// flows whose receive time is used for their expiry
+	Skewed int
+	Probes int
+	// number of flows by layers path
+	Layers      map[string]int
+	OldestStart int64
+	NewestLast  int64
+}
+
+// Summary returns the summary of the table, only counting the flows under
+// the lock
+func (ft *Table) Summary() TableSummary {
+	ft.lock.RLock()
+	defer ft.lock.RUnlock()
+
+	summary := TableSummary{Flows: len(ft.table), Layers: make(map[string]int)}
+	probes := make(map[string]bool)
+	for _, f := range ft.table {
+		summary.Layers[f.LayersPath]++
+		probes[f.ProbeNodeUUID] = true
+
+		if f.Statistics == nil {
+			continue
+		}
+		if summary.OldestStart == 0 || f.Statistics.Start < summary.OldestStart {
+			summary.OldestStart = f.Statistics.Start
+		}
+		if f.Statistics.Last > summary.NewestLast {
+			summary.NewestLast = f.Statistics.Last
+		}
+	}
+	for _, r := range ft.received {
+		if r.skewed {
+			summary.Skewed++
+		}
+	}
+	summary.Probes = len(probes)
+
+	return summary
+}
+
+// Len returns the number of flows in the table
+func (ft *Table) Len() int {
+	ft.lock.RLock()
+	defer ft.lock.RUnlock()
+	return len(ft.table)
+}
+
 func (ft *Table) String() string {
 	ft.lock.RLock()
 	defer ft.lock.RUnlock()
@@ -93,15 +236,65 @@ func (ft *Table) String() string {
 }
 
 func (ft *Table) Update(flows []*Flow) {
+	now := time.Now().Unix()
+
 	ft.lock.Lock()
+	defer ft.lock.Unlock()
+
+	var events []*FlowEvent
 	for _, f := range flows {
-		if _, ok := ft.table[f.UUID]; !ok {
+		current, exists := ft.table[f.UUID]
+		// delivered again or out of order, an update leaves the counters
+		// as they are
+		if exists && staleUpdate(current, f) {
+			continue
+		}
+
+		ft.receive(f, now)
+
+		if !exists {
 			ft.table[f.UUID] = f
 		} else {
+			// keep the first seen time of the flow, the duration being
+			// computed from it
+			if fs := ft.table[f.UUID].Statistics; fs != nil && f.Statistics != nil && fs.Start != 0 && fs.Start < f.Statistics.Start {
+				f.Statistics.Start = fs.Start
+			}
 			ft.table[f.UUID].Statistics = f.Statistics
+			ft.table[f.UUID].Duration = f.ComputeDuration()
+			if f.A_Role != "" {
+				ft.table[f.UUID].A_Role = f.A_Role
+				ft.table[f.UUID].B_Role = f.B_Role
+			}
+		}
+
+		if ft.eventListener != nil {
+			if e := ft.flowEvent(ft.table[f.UUID], !exists, now); e != nil {
+				events = append(events, e)
+			}
 		}
 	}
-	ft.lock.Unlock()
+
+	// sent under the lock so that the events of a flow keep their order
+	if len(events) > 0 {
+		ft.eventListener.OnFlowEvents(events)
+	}
+}
+
+// staleUpdate tells whether an update of a flow is a duplicate of the flow
+// in the table or older than it, the counters of a flow only growing
+func staleUpdate(current *Flow, update *Flow) bool {
+	cs, us := current.Statistics, update.Statistics
+	if cs == nil || us == nil {
+		return false
+	}
+	if us.Last != cs.Last {
+		return us.Last < cs.Last
+	}
+	if ub, cb := flowBytes(update), flowBytes(current); ub != cb {
+		return ub < cb
+	}
+	return flowPackets(update) <= flowPackets(current)
 }
 
 func matchQueryFilter(f *Flow, filter *FlowQueryFilter) bool {
@@ -125,6 +318,21 @@ func (ft *Table) GetFlows(filters ...FlowQueryFilter) []*Flow {
 	return flows
 }
 
+// Snapshot returns copies of the flows, taken at once under the lock. The
+// updates replacing the statistics of the flows instead of modifying them,
+// the copies aren't affected by the later updates.
+func (ft *Table) Snapshot() []*Flow {
+	ft.lock.RLock()
+	defer ft.lock.RUnlock()
+
+	flows := make([]*Flow, 0, len(ft.table))
+	for _, f := range ft.table {
+		c := *f
+		flows = append(flows, &c)
+	}
+	return flows
+}
+
 func (ft *Table) GetFlow(key string) *Flow {
 	ft.lock.RLock()
 	defer ft.lock.RUnlock()
@@ -207,16 +415,35 @@ func (ft *Table) expire(fn ExpireUpdateFunc, expireBefore int64) {
 	flowTableSzBefore := len(ft.table)
 	for _, f := range ft.table {
 		fs := f.GetStatistics()
-		if fs.Last < expireBefore {
+		if ft.lastSeen(f) < expireBefore {
 			duration := time.Duration(fs.Last - fs.Start)
 			logging.GetLogger().Debugf("Expire flow %s Duration %v", f.UUID, duration)
 			expiredFlows = append(expiredFlows, f)
 		}
 	}
 	/* Advise Clients */
-	fn(expiredFlows)
+	if ft.expireBatch <= 0 || len(expiredFlows) == 0 {
+		fn(expiredFlows)
+	} else {
+		for i := 0; i < len(expiredFlows); i += ft.expireBatch {
+			end := i + ft.expireBatch
+			if end > len(expiredFlows) {
+				end = len(expiredFlows)
+			}
+			fn(expiredFlows[i:end])
+		}
+	}
+	var events []*FlowEvent
 	for _, f := range expiredFlows {
+		if ft.eventListener != nil {
+			events = append(events, newFlowEvent(FlowExpired, f))
+		}
 		delete(ft.table, f.UUID)
+		delete(ft.received, f.UUID)
+		delete(ft.emitted, f.UUID)
+	}
+	if len(events) > 0 {
+		ft.eventListener.OnFlowEvents(events)
 	}
 	flowTableSz := len(ft.table)
 	logging.GetLogger().Debugf("Expire Flow : removed %v ; new size %v", flowTableSzBefore-flowTableSz, flowTableSz)
@@ -233,8 +460,7 @@ func (ft *Table) Updated(now time.Time) {
 func (ft *Table) updated(fn ExpireUpdateFunc, updateFrom int64) {
 	var updatedFlows []*Flow
 	for _, f := range ft.table {
-		fs := f.GetStatistics()
-		if fs.Last > updateFrom {
+		if ft.lastSeen(f) > updateFrom {
 			updatedFlows = append(updatedFlows, f)
 		}
 	}
@@ -250,6 +476,75 @@ func (ft *Table) expireNow() {
 	ft.lock.Unlock()
 }
 
+// Clear removes all the flows of the table at once, returning their number.
+// When flush is set, the flows are given to the expire callback first and
+// notified as expired, as when expiring.
+func (ft *Table) Clear(flush bool) int {
+	ft.lock.Lock()
+	defer ft.lock.Unlock()
+
+	cleared := len(ft.table)
+	if flush && ft.manager.expire.callback != nil {
+		const Now = int64(^uint64(0) >> 1)
+		ft.expire(ft.manager.expire.callback, Now)
+	}
+
+	ft.table = make(map[string]*Flow)
+	ft.received = make(map[string]receivedFlow)
+	ft.emitted = make(map[string]emittedFlow)
+
+	return cleared
+}
+
+// Extract removes all the flows of the table at once and returns them,
+// without giving them to the expire callback nor notifying them, to hand
+// them over to another table
+func (ft *Table) Extract() []*Flow {
+	ft.lock.Lock()
+	defer ft.lock.Unlock()
+
+	flows := make([]*Flow, 0, len(ft.table))
+	for _, f := range ft.table {
+		flows = append(flows, f)
+	}
+
+	ft.table = make(map[string]*Flow)
+	ft.received = make(map[string]receivedFlow)
+	ft.emitted = make(map[string]emittedFlow)
+
+	return flows
+}
+
+// Merge adds the flows extracted from another table, a flow already in the
+// table keeping its first seen time and the statistics of the most recent
+// one. No lifecycle event is emitted, the flows being already known.
+func (ft *Table) Merge(flows []*Flow) {
+	ft.lock.Lock()
+	defer ft.lock.Unlock()
+
+	for _, f := range flows {
+		current, exists := ft.table[f.UUID]
+		if !exists {
+			ft.table[f.UUID] = f
+			continue
+		}
+
+		cs, fs := current.Statistics, f.Statistics
+		if fs == nil {
+			continue
+		}
+		if cs == nil || fs.Last > cs.Last {
+			if cs != nil && cs.Start != 0 && cs.Start < fs.Start {
+				fs.Start = cs.Start
+			}
+			current.Statistics = fs
+		} else if fs.Start != 0 && fs.Start < cs.Start {
+			cs.Start = fs.Start
+		}
+		current.Duration = current.ComputeDuration()
+	}
+}
+
 /* Asynchrnously Register an expire callback fn with last updated flow 'since', each 'since' tick  */
 func (ft *Table) RegisterExpire(fn ExpireUpdateFunc, every time.Duration, windowSize time.Duration) {
 	ft.lock.Lock()
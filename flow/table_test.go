@@ -67,6 +67,27 @@ func TestTable_Update(t *testing.T) {
 	}
 }
 
+func TestTable_UpdateDuration(t *testing.T) {
+	ft := NewTable()
+	ft.Update([]*Flow{{UUID: "long", Statistics: &FlowStatistics{Start: 1000, Last: 1010}}})
+
+	// an update with a later start, the first seen time being preserved
+	ft.Update([]*Flow{{UUID: "long", Statistics: &FlowStatistics{Start: 1500, Last: 4700}}})
+
+	f := ft.GetFlow("long")
+	if f.Statistics.Start != 1000 || f.Statistics.Last != 4700 {
+		t.Errorf("Wrong statistics: %v", f.Statistics)
+	}
+	if f.Duration != 3700 || f.ComputeDuration() != 3700 {
+		t.Errorf("Expected a duration of 3700, got %d", f.Duration)
+	}
+
+	filter, _ := ParseFilter("Duration=gt:3600")
+	if !filter.Match(f) {
+		t.Error("Flow should match the duration filter")
+	}
+}
+
 type MyTestFlowCounter struct {
 	NbFlow int
 }
@@ -96,6 +117,19 @@ func TestTable_expire(t *testing.T) {
 	}
 }
 
+func TestTable_expireBatch(t *testing.T) {
+	const MaxInt64 = int64(^uint64(0) >> 1)
+	ft := NewTestFlowTableComplex(t)
+	ft.SetExpireBatchSize(3)
+
+	var chunks []int
+	ft.expire(func(flows []*Flow) { chunks = append(chunks, len(flows)) }, MaxInt64)
+
+	if len(chunks) != 4 || chunks[0] != 3 || chunks[3] != 1 {
+		t.Errorf("10 flows should be expired in 4 chunks of at most 3 flows, got %v", chunks)
+	}
+}
+
 func TestTable_updated(t *testing.T) {
 	const MaxInt64 = int64(^uint64(0) >> 1)
 	ft := NewTestFlowTableComplex(t)
@@ -265,3 +299,166 @@ func TestTable_SymmeticsHash(t *testing.T) {
 		}
 	}
 }
+
+func TestTable_expireSkewed(t *testing.T) {
+	ft := NewTable()
+	ft.SetSkewTolerance(5 * time.Minute)
+
+	now := time.Now()
+	ft.Update([]*Flow{
+		{UUID: "ahead", Statistics: &FlowStatistics{Start: now.Add(time.Hour).Unix(), Last: now.Add(time.Hour).Unix()}},
+		{UUID: "behind", Statistics: &FlowStatistics{Start: now.Add(-time.Hour).Unix(), Last: now.Add(-time.Hour).Unix()}},
+		{UUID: "tolerated", Statistics: &FlowStatistics{Start: now.Add(time.Minute).Unix(), Last: now.Add(time.Minute).Unix()}},
+	})
+
+	var expired []string
+	expire := func(flows []*Flow) {
+		for _, f := range flows {
+			expired = append(expired, f.UUID)
+		}
+	}
+
+	// a flow behind the analyzer clock isn't expired as soon as received
+	ft.expire(expire, now.Add(-10*time.Minute).Unix())
+	if len(expired) != 0 {
+		t.Fatalf("No flow should be expired yet: %v", expired)
+	}
+
+	// the skewed flows expire according to their receive time, a flow ahead
+	// of the analyzer clock being expired instead of never, the tolerated
+	// one according to its own timestamp
+	ft.expire(expire, now.Add(30*time.Second).Unix())
+	if len(expired) != 2 || ft.GetFlow("tolerated") == nil {
+		t.Fatalf("Only the skewed flows should be expired: %v", expired)
+	}
+
+	ft.expire(expire, now.Add(2*time.Minute).Unix())
+	if len(expired) != 3 {
+		t.Errorf("All the flows should be expired: %v", expired)
+	}
+
+	if len(ft.received) != 0 {
+		t.Errorf("The receive times of the expired flows should be forgotten: %v", ft.received)
+	}
+}
+
+func TestTable_expireSkewDisabled(t *testing.T) {
+	ft := NewTable()
+
+	ahead := time.Now().Add(time.Hour).Unix()
+	ft.Update([]*Flow{{UUID: "ahead", Statistics: &FlowStatistics{Start: ahead, Last: ahead}}})
+
+	var expired int
+	ft.expire(func(flows []*Flow) { expired += len(flows) }, time.Now().Add(30*time.Minute).Unix())
+	if expired != 0 {
+		t.Error("The flow timestamp should be used without skew tolerance")
+	}
+}
+
+type recordingEventListener struct {
+	events []*FlowEvent
+}
+
+func (l *recordingEventListener) OnFlowEvents(events []*FlowEvent) {
+	l.events = append(l.events, events...)
+}
+
+func TestTable_eventsUpdatePolicy(t *testing.T) {
+	withBytes := func(bytes uint64) *Flow {
+		return &Flow{
+			UUID: "flow",
+			Statistics: &FlowStatistics{
+				Endpoints: []*FlowEndpointsStatistics{
+					{AB: &FlowEndpointStatistics{Bytes: bytes}, BA: &FlowEndpointStatistics{}},
+				},
+			},
+		}
+	}
+
+	listener := &recordingEventListener{}
+	ft := NewTable()
+	ft.SetEventListener(listener, FlowUpdatePolicy{Interval: 3600, BytesRatio: 0.5})
+
+	// an update is emitted once the bytes grew by half since the last event
+	for _, bytes := range []uint64{100, 120, 149, 150, 200, 225} {
+		ft.Update([]*Flow{withBytes(bytes)})
+	}
+
+	var types []FlowEventType
+	var bytes []uint64
+	for _, e := range listener.events {
+		types = append(types, e.Type)
+		bytes = append(bytes, flowBytes(e.Flow))
+	}
+	if fmt.Sprint(types) != "[FLOW_CREATED FLOW_UPDATED FLOW_UPDATED]" || fmt.Sprint(bytes) != "[100 150 225]" {
+		t.Errorf("Wrong events %v with the bytes %v", types, bytes)
+	}
+}
+
+func TestTable_ExtractMerge(t *testing.T) {
+	old := NewTable()
+	old.Update([]*Flow{
+		{UUID: "both", Statistics: &FlowStatistics{Start: 1000, Last: 1200}},
+		{UUID: "old", Statistics: &FlowStatistics{Start: 1000, Last: 1100}},
+	})
+
+	var expired int
+	old.RegisterExpire(func(flows []*Flow) { expired += len(flows) }, time.Hour, time.Hour)
+	defer old.UnregisterAll()
+
+	flows := old.Extract()
+	if len(flows) != 2 || old.String() != "0 flows" || expired != 0 {
+		t.Fatalf("Expected the 2 flows to be extracted without expiring them, got %d, %s, %d expired", len(flows), old, expired)
+	}
+
+	// the new table got an update of one of the flows in the meantime
+	ft := NewTable()
+	ft.Update([]*Flow{{UUID: "both", Statistics: &FlowStatistics{Start: 1100, Last: 1300}}})
+
+	listener := &recordingEventListener{}
+	ft.SetEventListener(listener, FlowUpdatePolicy{})
+	ft.Merge(flows)
+
+	f := ft.GetFlow("both")
+	if f.Statistics.Start != 1000 || f.Statistics.Last != 1300 || f.Duration != 300 {
+		t.Errorf("Expected the first seen time and the last statistics, got %v, duration %d", f.Statistics, f.Duration)
+	}
+	if ft.GetFlow("old") == nil || len(listener.events) != 0 {
+		t.Errorf("Expected the flow to be merged without event, got %d events", len(listener.events))
+	}
+}
+
+func TestTable_UpdateStale(t *testing.T) {
+	withBytes := func(last int64, bytes uint64) *Flow {
+		return &Flow{
+			UUID: "flow",
+			Statistics: &FlowStatistics{
+				Start: 1000,
+				Last:  last,
+				Endpoints: []*FlowEndpointsStatistics{
+					{AB: &FlowEndpointStatistics{Bytes: bytes}, BA: &FlowEndpointStatistics{}},
+				},
+			},
+		}
+	}
+
+	listener := &recordingEventListener{}
+	ft := NewTable()
+	ft.SetEventListener(listener, FlowUpdatePolicy{})
+
+	// the duplicates and the updates older than the flow are ignored
+	for _, u := range []struct {
+		last  int64
+		bytes uint64
+	}{{1010, 100}, {1020, 300}, {1020, 300}, {1010, 100}, {1020, 200}, {1030, 400}, {1020, 300}} {
+		ft.Update([]*Flow{withBytes(u.last, u.bytes)})
+	}
+
+	f := ft.GetFlow("flow")
+	if f.Statistics.Last != 1030 || flowBytes(f) != 400 {
+		t.Errorf("Wrong statistics: %v", f.Statistics)
+	}
+	if len(listener.events) != 3 {
+		t.Errorf("Expected 3 events, got %d", len(listener.events))
+	}
+}
@@ -0,0 +1,98 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package flow
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TagPrefix is the namespace of the static tags among the flow attributes,
+// so that they can't collide with the attributes set by the probes
+const TagPrefix = "Tag."
+
+// FlowTagger is implemented by the probes stamping the flows they create
+// with static tags
+type FlowTagger interface {
+	FlowTags() map[string]string
+}
+
+func isTagChar(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-'
+}
+
+// ValidateTags checks that the keys are made of the tag prefix followed by
+// a name of letters, digits, '_' or '-'
+func ValidateTags(tags map[string]string) error {
+	for key := range tags {
+		if !strings.HasPrefix(key, TagPrefix) {
+			return fmt.Errorf("Tag %s not in the %s namespace", key, TagPrefix)
+		}
+		name := strings.TrimPrefix(key, TagPrefix)
+		if name == "" || strings.IndexFunc(name, func(r rune) bool { return !isTagChar(r) }) != -1 {
+			return fmt.Errorf("Invalid tag name: %s", key)
+		}
+	}
+	return nil
+}
+
+// MergeTags merges the tag sets, the later ones winning on conflicts
+func MergeTags(sets ...map[string]string) map[string]string {
+	var tags map[string]string
+	for _, set := range sets {
+		for k, v := range set {
+			if tags == nil {
+				tags = make(map[string]string)
+			}
+			tags[k] = v
+		}
+	}
+	return tags
+}
+
+// SetTags adds the tags to the attributes of the flow
+func (flow *Flow) SetTags(tags map[string]string) {
+	if len(tags) == 0 {
+		return
+	}
+	if flow.Attributes == nil {
+		flow.Attributes = make(map[string]string)
+	}
+	for k, v := range tags {
+		flow.Attributes[k] = v
+	}
+}
+
+// GetTags returns the tags of the flow
+func (flow *Flow) GetTags() map[string]string {
+	var tags map[string]string
+	for k, v := range flow.GetAttributes() {
+		if strings.HasPrefix(k, TagPrefix) {
+			if tags == nil {
+				tags = make(map[string]string)
+			}
+			tags[k] = v
+		}
+	}
+	return tags
+}
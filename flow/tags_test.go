@@ -0,0 +1,94 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package flow
+
+import (
+	"testing"
+
+	"github.com/google/gopacket/layers"
+)
+
+func TestValidateTags(t *testing.T) {
+	valid := map[string]string{"Tag.segment": "pci", "Tag.change_ticket-2": "CHG-1234"}
+	if err := ValidateTags(valid); err != nil {
+		t.Errorf("Unexpected error: %s", err.Error())
+	}
+
+	for _, key := range []string{"segment", "NAT_A", "Tag.", "Tag.a.b", "Tag.a b", "tag.segment"} {
+		if err := ValidateTags(map[string]string{key: "value"}); err == nil {
+			t.Errorf("Tag %s should be rejected", key)
+		}
+	}
+}
+
+func TestMergeTags(t *testing.T) {
+	agent := map[string]string{"Tag.segment": "dmz", "Tag.site": "paris"}
+	capture := map[string]string{"Tag.segment": "pci"}
+
+	tags := MergeTags(agent, capture)
+	if len(tags) != 2 || tags["Tag.segment"] != "pci" || tags["Tag.site"] != "paris" {
+		t.Errorf("Capture tags should win, got %v", tags)
+	}
+	if agent["Tag.segment"] != "dmz" {
+		t.Error("The merged sets shouldn't be modified")
+	}
+
+	if tags := MergeTags(nil, nil); tags != nil {
+		t.Errorf("Expected no tags, got %v", tags)
+	}
+}
+
+type tagger struct {
+	tags map[string]string
+}
+
+func (p *tagger) SetProbeNode(f *Flow) bool {
+	f.ProbeNodeUUID = "probe"
+	return true
+}
+
+func (p *tagger) FlowTags() map[string]string {
+	return p.tags
+}
+
+func TestFlowTags(t *testing.T) {
+	ft := NewTable()
+	probe := &tagger{tags: map[string]string{"Tag.segment": "pci"}}
+
+	flow := FlowFromGoPacket(ft, forgeRoleTestPacket(t, &layers.TCP{SrcPort: 34567, DstPort: 8080, SYN: true}), probe)
+	if tags := flow.GetTags(); len(tags) != 1 || tags["Tag.segment"] != "pci" {
+		t.Errorf("Expected the flow to be tagged, got %v", flow.Attributes)
+	}
+
+	// the flows keep the tags they were created with
+	probe.tags = map[string]string{"Tag.segment": "dmz"}
+	flow = FlowFromGoPacket(ft, forgeRoleTestPacket(t, &layers.TCP{SrcPort: 34567, DstPort: 8080, ACK: true}), probe)
+	if flow.Attributes["Tag.segment"] != "pci" {
+		t.Errorf("Tags of an existing flow shouldn't change, got %v", flow.Attributes)
+	}
+
+	flow = FlowFromGoPacket(ft, forgeRoleTestPacket(t, &layers.UDP{SrcPort: 34567, DstPort: 5000}), probe)
+	if flow.Attributes["Tag.segment"] != "dmz" {
+		t.Errorf("New flows should get the new tags, got %v", flow.Attributes)
+	}
+}
@@ -25,6 +25,8 @@
 package flow
 
 import (
+	"bytes"
+	"encoding/binary"
 	"math/rand"
 	"net"
 	"testing"
@@ -174,3 +176,62 @@ func NewTestFlowTableComplex(t *testing.T) *Table {
 	GenerateTestFlows(t, ft, 0xca55e77e, "probe-uuid")
 	return ft
 }
+
+// ForgeTestNetFlowV5 encodes a NetFlow v5 datagram exported at the given
+// time by a router up for an hour
+func ForgeTestNetFlowV5(t *testing.T, unixSecs uint32, records ...*NetFlowV5Record) []byte {
+	data := make([]byte, netFlowV5HeaderSize+len(records)*netFlowV5RecordSize)
+	binary.BigEndian.PutUint16(data, netFlowV5Version)
+	binary.BigEndian.PutUint16(data[2:], uint16(len(records)))
+	binary.BigEndian.PutUint32(data[4:], 3600000)
+	binary.BigEndian.PutUint32(data[8:], unixSecs)
+
+	for i, r := range records {
+		b := data[netFlowV5HeaderSize+i*netFlowV5RecordSize:]
+		copy(b[0:4], r.SrcAddr.To4())
+		copy(b[4:8], r.DstAddr.To4())
+		binary.BigEndian.PutUint32(b[16:], r.Packets)
+		binary.BigEndian.PutUint32(b[20:], r.Octets)
+		binary.BigEndian.PutUint32(b[24:], r.First)
+		binary.BigEndian.PutUint32(b[28:], r.Last)
+		binary.BigEndian.PutUint16(b[32:], r.SrcPort)
+		binary.BigEndian.PutUint16(b[34:], r.DstPort)
+		b[38] = r.Protocol
+	}
+	return data
+}
+
+// ForgeTestSFlowDatagram encodes an sFlow v5 datagram of an agent, made of
+// a counter sample followed by a flow sample sampled at the given rate for
+// each of the TCP packets forged from the seeds
+func ForgeTestSFlowDatagram(t *testing.T, agent net.IP, rate uint32, seeds ...int64) []byte {
+	var buf bytes.Buffer
+	write := func(values ...uint32) {
+		for _, v := range values {
+			binary.Write(&buf, binary.BigEndian, v)
+		}
+	}
+
+	write(5, 1)
+	buf.Write(agent.To4())
+	write(0, 1, 3600000, uint32(1+len(seeds)))
+
+	// VLAN counters, skipped
+	write(2, 12+36, 1, 1, 1, 5, 28)
+	buf.Write(make([]byte, 28))
+
+	for i, seed := range seeds {
+		header := (*forgeTestPacket(t, seed, false, ETH, IPv4, TCP)).Data()
+		padded := (len(header) + 3) / 4 * 4
+
+		// sequence, source, rate, pool, drops, input, output and the
+		// extended switch and raw packet header records
+		write(1, uint32(32+8+16+8+16+padded))
+		write(uint32(i), 1, rate, rate*uint32(i+1), 0, 1, 2, 2)
+		write(1001, 16, 0, 0, 0, 0)
+		write(1, uint32(16+padded), 1, uint32(len(header)), 0, uint32(len(header)))
+		buf.Write(header)
+		buf.Write(make([]byte, padded-len(header)))
+	}
+	return buf.Bytes()
+}
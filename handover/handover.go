@@ -0,0 +1,368 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package handover
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/redhat-cip/skydive/config"
+	"github.com/redhat-cip/skydive/flow"
+	"github.com/redhat-cip/skydive/flow/ingestion"
+	"github.com/redhat-cip/skydive/logging"
+)
+
+// A handover passes the listening sockets, the flow table and the agent
+// state of a running analyzer to a new one over a unix socket:
+//
+//	new: request         -> old: sockets (passed as SCM_RIGHTS)
+//	new: ready or abort  -> old: state, once drained
+//	new: done
+//
+// Until ready, the handover can be aborted by the new analyzer, the old one
+// going on serving. Once ready, the old analyzer stops reading the flows,
+// drains its in-flight requests, sends its state and exits.
+const (
+	Idle       = "idle"
+	Receiving  = "receiving"
+	Received   = "received"
+	Sending    = "sending"
+	Draining   = "draining"
+	HandedOver = "handed over"
+	Aborted    = "aborted"
+	Failed     = "failed"
+)
+
+// State is the state of the analyzer handed over along with the sockets
+type State struct {
+	Flows     []*flow.Flow
+	Agents    []ingestion.AgentStatus
+	Sequences []ingestion.SequenceState
+}
+
+// Peer is the analyzer handing its sockets and state over
+type Peer interface {
+	// HandoverFiles returns copies of the listening sockets by name
+	HandoverFiles() (map[string]*os.File, error)
+	// HandoverDrain stops reading the flows and serving the requests,
+	// waiting for the in-flight ones, and returns the state to hand over
+	HandoverDrain() *State
+	// HandoverRestore takes the state back when it couldn't be handed over
+	HandoverRestore(state *State)
+}
+
+// Status reports the last handover, sent or received
+type Status struct {
+	State  string
+	Socket string
+	Peer   int    `json:",omitempty"`
+	Flows  int    `json:",omitempty"`
+	Agents int    `json:",omitempty"`
+	Error  string `json:",omitempty"`
+	Time   time.Time
+}
+
+// Handover listens on Path for a new analyzer to hand over to, each step of
+// the exchange having to complete within Timeout
+type Handover struct {
+	sync.RWMutex
+	Path     string
+	Timeout  time.Duration
+	peer     Peer
+	listener *net.UnixListener
+	status   Status
+	done     chan struct{}
+	wg       sync.WaitGroup
+}
+
+var ErrAborted = errors.New("Handover aborted")
+
+func (h *Handover) setStatus(state string, peer int, err error) {
+	h.Lock()
+	h.status.State = state
+	h.status.Peer = peer
+	h.status.Time = time.Now().UTC()
+	h.status.Error = ""
+	if err != nil {
+		h.status.Error = err.Error()
+	}
+	h.Unlock()
+}
+
+func (h *Handover) setState(state *State) {
+	h.Lock()
+	h.status.Flows = len(state.Flows)
+	h.status.Agents = len(state.Agents)
+	h.Unlock()
+}
+
+// Status returns the status of the last handover
+func (h *Handover) Status() Status {
+	h.RLock()
+	defer h.RUnlock()
+	return h.status
+}
+
+// Done is closed once the sockets and the state were handed over, the
+// analyzer having then to exit
+func (h *Handover) Done() <-chan struct{} {
+	return h.done
+}
+
+// Listen binds the handover socket, the one of a previous analyzer being
+// replaced
+func (h *Handover) Listen() error {
+	if err := os.Remove(h.Path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: h.Path, Net: "unix"})
+	if err != nil {
+		return fmt.Errorf("Failed to listen on the handover socket %s: %s", h.Path, err.Error())
+	}
+	os.Chmod(h.Path, 0600)
+
+	h.Lock()
+	h.listener = listener
+	h.Unlock()
+
+	return nil
+}
+
+// Serve hands over to the analyzers connecting one at a time, until one
+// succeeds or Stop is called
+func (h *Handover) Serve() {
+	h.wg.Add(1)
+	defer h.wg.Done()
+
+	for {
+		conn, err := h.listener.AcceptUnix()
+		if err != nil {
+			return
+		}
+
+		handedOver := h.handover(conn)
+		conn.Close()
+
+		if handedOver {
+			// the socket now belongs to the new analyzer
+			h.listener.SetUnlinkOnClose(false)
+			h.listener.Close()
+			close(h.done)
+			return
+		}
+	}
+}
+
+// handover runs the exchange with a new analyzer, returning whether the
+// new one took over
+func (h *Handover) handover(conn *net.UnixConn) bool {
+	conn.SetDeadline(time.Now().Add(h.Timeout))
+	msg, _, err := readMessage(conn)
+	if err != nil || msg.Type != msgRequest {
+		logging.GetLogger().Errorf("Invalid handover request: %v", err)
+		return false
+	}
+	pid := msg.PID
+
+	logging.GetLogger().Noticef("Handing over to the analyzer %d", pid)
+	h.setStatus(Sending, pid, nil)
+
+	files, err := h.peer.HandoverFiles()
+	if err == nil {
+		err = sendFiles(conn, files)
+	}
+	if err != nil {
+		logging.GetLogger().Errorf("Unable to hand the sockets over to the analyzer %d: %s", pid, err.Error())
+		h.setStatus(Failed, pid, err)
+		return false
+	}
+
+	// the new analyzer validates its configuration and starts
+	conn.SetDeadline(time.Now().Add(h.Timeout))
+	if msg, _, err = readMessage(conn); err == nil && msg.Type != msgReady {
+		err = fmt.Errorf("%s: %s", ErrAborted.Error(), msg.Reason)
+	}
+	if err != nil {
+		logging.GetLogger().Errorf("Handover to the analyzer %d aborted, still serving: %s", pid, err.Error())
+		h.setStatus(Aborted, pid, err)
+		return false
+	}
+
+	h.setStatus(Draining, pid, nil)
+	state := h.peer.HandoverDrain()
+	h.setState(state)
+
+	conn.SetDeadline(time.Now().Add(h.Timeout))
+	err = writeMessage(conn, stateMessage(state), nil)
+	if err == nil {
+		if msg, _, err = readMessage(conn); err == nil && msg.Type != msgDone {
+			err = fmt.Errorf("Unexpected handover message: %s", msg.Type)
+		}
+	}
+	if err != nil {
+		// the new analyzer serves the sockets already, the flows being
+		// stored on exit
+		logging.GetLogger().Errorf("Unable to hand the state over to the analyzer %d: %s", pid, err.Error())
+		h.peer.HandoverRestore(state)
+		h.setStatus(Failed, pid, err)
+		return true
+	}
+
+	logging.GetLogger().Noticef("Handed %d flows over to the analyzer %d", len(state.Flows), pid)
+	h.setStatus(HandedOver, pid, nil)
+	return true
+}
+
+// Stop stops listening for a new analyzer
+func (h *Handover) Stop() {
+	h.Lock()
+	if h.listener != nil {
+		h.listener.Close()
+	}
+	h.Unlock()
+
+	h.wg.Wait()
+}
+
+// Receiver is the side of the new analyzer, holding the sockets handed over
+// until the handover is committed or aborted
+type Receiver struct {
+	handover *Handover
+	conn     *net.UnixConn
+	peer     int
+	Sockets  map[string]*os.File
+}
+
+// Receive asks the analyzer listening on the handover socket for its
+// sockets, returning nil if none is listening
+func (h *Handover) Receive() (*Receiver, error) {
+	conn, err := net.DialUnix("unix", nil, &net.UnixAddr{Name: h.Path, Net: "unix"})
+	if err != nil {
+		// no analyzer running
+		return nil, nil
+	}
+
+	h.setStatus(Receiving, 0, nil)
+
+	conn.SetDeadline(time.Now().Add(h.Timeout))
+	err = writeMessage(conn, &message{Type: msgRequest, PID: os.Getpid()}, nil)
+
+	var msg *message
+	var files map[string]*os.File
+	if err == nil {
+		msg, files, err = readMessage(conn)
+	}
+	if err == nil && msg.Type != msgSockets {
+		err = fmt.Errorf("Unexpected handover message: %s", msg.Type)
+	}
+	if err != nil {
+		conn.Close()
+		h.setStatus(Failed, 0, err)
+		return nil, err
+	}
+
+	h.setStatus(Receiving, msg.PID, nil)
+	logging.GetLogger().Noticef("Sockets handed over by the analyzer %d", msg.PID)
+
+	return &Receiver{handover: h, conn: conn, peer: msg.PID, Sockets: files}, nil
+}
+
+func (r *Receiver) closeSockets() {
+	for _, f := range r.Sockets {
+		f.Close()
+	}
+}
+
+// Abort aborts the handover, the old analyzer going on serving
+func (r *Receiver) Abort(reason string) {
+	r.conn.SetDeadline(time.Now().Add(r.handover.Timeout))
+	writeMessage(r.conn, &message{Type: msgAbort, Reason: reason}, nil)
+	r.conn.Close()
+	r.closeSockets()
+
+	r.handover.setStatus(Aborted, r.peer, errors.New(reason))
+}
+
+// Commit tells the old analyzer to drain and returns its state. The sockets
+// are served by the new analyzer from then on, even if the state can't be
+// received.
+func (r *Receiver) Commit() (*State, error) {
+	defer r.conn.Close()
+	defer r.closeSockets()
+
+	// the old analyzer waits for its in-flight requests
+	r.conn.SetDeadline(time.Now().Add(2 * r.handover.Timeout))
+	err := writeMessage(r.conn, &message{Type: msgReady, PID: os.Getpid()}, nil)
+
+	var msg *message
+	if err == nil {
+		msg, _, err = readMessage(r.conn)
+	}
+	if err == nil && msg.Type != msgState {
+		err = fmt.Errorf("Unexpected handover message: %s", msg.Type)
+	}
+
+	var state *State
+	if err == nil {
+		if state, err = msg.state(); err == nil {
+			err = writeMessage(r.conn, &message{Type: msgDone}, nil)
+		}
+	}
+	if err != nil {
+		r.handover.setStatus(Failed, r.peer, err)
+		return nil, err
+	}
+
+	r.handover.setState(state)
+	r.handover.setStatus(Received, r.peer, nil)
+	logging.GetLogger().Noticef("%d flows handed over by the analyzer %d", len(state.Flows), r.peer)
+
+	return state, nil
+}
+
+func New(path string, timeout time.Duration, peer Peer) *Handover {
+	return &Handover{
+		Path:    path,
+		Timeout: timeout,
+		peer:    peer,
+		status:  Status{State: Idle, Socket: path},
+		done:    make(chan struct{}),
+	}
+}
+
+// NewFromConfig returns the handover of the analyzer.handover.socket, nil
+// if not set
+func NewFromConfig(peer Peer) *Handover {
+	path := config.GetConfig().GetString("analyzer.handover.socket")
+	if path == "" {
+		return nil
+	}
+
+	timeout := time.Duration(config.GetConfig().GetInt("analyzer.handover.timeout")) * time.Second
+	return New(path, timeout, peer)
+}
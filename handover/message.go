@@ -0,0 +1,216 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package handover
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"syscall"
+
+	"github.com/redhat-cip/skydive/flow"
+	"github.com/redhat-cip/skydive/flow/ingestion"
+)
+
+// The messages are length prefixed JSON documents, the sockets being passed
+// as the ancillary data of the sockets message
+const (
+	msgRequest = "request"
+	msgSockets = "sockets"
+	msgReady   = "ready"
+	msgAbort   = "abort"
+	msgState   = "state"
+	msgDone    = "done"
+
+	maxSockets     = 16
+	maxMessageSize = 1024 * 1024 * 1024
+)
+
+var ErrMessageTooLarge = errors.New("Handover message too large")
+
+type message struct {
+	Type      string
+	PID       int                       `json:",omitempty"`
+	Reason    string                    `json:",omitempty"`
+	Sockets   []string                  `json:",omitempty"`
+	Flows     [][]byte                  `json:",omitempty"`
+	Agents    []ingestion.AgentStatus   `json:",omitempty"`
+	Sequences []ingestion.SequenceState `json:",omitempty"`
+}
+
+func stateMessage(state *State) *message {
+	msg := &message{Type: msgState, Agents: state.Agents, Sequences: state.Sequences}
+	for _, f := range state.Flows {
+		data, err := f.GetData()
+		if err != nil {
+			continue
+		}
+		msg.Flows = append(msg.Flows, data)
+	}
+	return msg
+}
+
+func (m *message) state() (*State, error) {
+	state := &State{Agents: m.Agents, Sequences: m.Sequences}
+	for _, data := range m.Flows {
+		f, err := flow.FromData(data)
+		if err != nil {
+			return nil, err
+		}
+		state.Flows = append(state.Flows, f)
+	}
+	return state, nil
+}
+
+func writeMessage(conn *net.UnixConn, msg *message, files []*os.File) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	frame := make([]byte, 4, 4+len(data))
+	binary.BigEndian.PutUint32(frame, uint32(len(data)))
+	frame = append(frame, data...)
+
+	if len(files) == 0 {
+		_, err = conn.Write(frame)
+		return err
+	}
+
+	// Fd() would put the sockets, shared with the copies, in blocking mode
+	fds := make([]int, len(files))
+	for i, f := range files {
+		rc, err := f.SyscallConn()
+		if err != nil {
+			return err
+		}
+		rc.Control(func(fd uintptr) {
+			fds[i] = int(fd)
+		})
+	}
+
+	n, _, err := conn.WriteMsgUnix(frame, syscall.UnixRights(fds...), nil)
+	if err == nil && n < len(frame) {
+		_, err = conn.Write(frame[n:])
+	}
+	return err
+}
+
+// readMessage reads a message and the sockets passed along, named after the
+// Sockets of the message
+func readMessage(conn *net.UnixConn) (*message, map[string]*os.File, error) {
+	header := make([]byte, 4)
+	oob := make([]byte, syscall.CmsgSpace(maxSockets*4))
+
+	n, oobn, _, _, err := conn.ReadMsgUnix(header, oob)
+	if err != nil {
+		return nil, nil, err
+	}
+	if n < len(header) {
+		if _, err := io.ReadFull(conn, header[n:]); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var fds []int
+	if oobn > 0 {
+		scms, err := syscall.ParseSocketControlMessage(oob[:oobn])
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, scm := range scms {
+			rights, err := syscall.ParseUnixRights(&scm)
+			if err != nil {
+				return nil, nil, err
+			}
+			fds = append(fds, rights...)
+		}
+	}
+
+	size := binary.BigEndian.Uint32(header)
+	if size > maxMessageSize {
+		closeFds(fds)
+		return nil, nil, ErrMessageTooLarge
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(conn, data); err != nil {
+		closeFds(fds)
+		return nil, nil, err
+	}
+
+	var msg message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		closeFds(fds)
+		return nil, nil, err
+	}
+
+	if len(fds) != len(msg.Sockets) {
+		closeFds(fds)
+		return nil, nil, fmt.Errorf("%d sockets received, %d expected", len(fds), len(msg.Sockets))
+	}
+
+	files := make(map[string]*os.File)
+	for i, name := range msg.Sockets {
+		files[name] = os.NewFile(uintptr(fds[i]), name)
+	}
+
+	return &msg, files, nil
+}
+
+// sendFiles sends the sockets, closing the copies given by the peer
+func sendFiles(conn *net.UnixConn, files map[string]*os.File) error {
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+
+	if len(files) > maxSockets {
+		return fmt.Errorf("Too many sockets to hand over: %d", len(files))
+	}
+
+	msg := &message{Type: msgSockets, PID: os.Getpid()}
+	for name := range files {
+		msg.Sockets = append(msg.Sockets, name)
+	}
+	sort.Strings(msg.Sockets)
+
+	list := make([]*os.File, len(msg.Sockets))
+	for i, name := range msg.Sockets {
+		list[i] = files[name]
+	}
+
+	return writeMessage(conn, msg, list)
+}
+
+func closeFds(fds []int) {
+	for _, fd := range fds {
+		syscall.Close(fd)
+	}
+}
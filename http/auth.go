@@ -23,6 +23,8 @@
 package http
 
 import (
+	"crypto/subtle"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"net/http"
@@ -30,7 +32,9 @@ import (
 	"strings"
 
 	"github.com/abbot/go-http-auth"
+	gcontext "github.com/gorilla/context"
 	"github.com/redhat-cip/skydive/config"
+	"github.com/redhat-cip/skydive/logging"
 )
 
 var (
@@ -40,6 +44,97 @@ var (
 type AuthenticationOpts struct {
 	Username string
 	Password string
+	// bearer token given instead of the username and password
+	Token string
+}
+
+// Authorization returns the value of the Authorization header giving the
+// token, or the username and password, of the options, empty if none
+func (o *AuthenticationOpts) Authorization() string {
+	switch {
+	case o.Token != "":
+		return "Bearer " + o.Token
+	case o.Username != "":
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(o.Username+":"+o.Password))
+	}
+	return ""
+}
+
+// AuthenticationOptsFromConfig returns the credentials required by the
+// service, configured in its auth section, nil if none
+func AuthenticationOptsFromConfig(service string) *AuthenticationOpts {
+	opts := &AuthenticationOpts{
+		Username: config.GetConfig().GetString(service + ".auth.username"),
+		Password: config.GetConfig().GetString(service + ".auth.password"),
+		Token:    config.GetConfig().GetString(service + ".auth.token"),
+	}
+	if opts.Authorization() == "" {
+		return nil
+	}
+	return opts
+}
+
+type authorizationKey struct{}
+
+// withAuthorization keeps the Authorization header given by the client, the
+// authentication backends overwriting it with the one of the authtok cookie
+func withAuthorization(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if authorization := r.Header.Get("Authorization"); authorization != "" {
+			gcontext.Set(r, authorizationKey{}, authorization)
+			defer gcontext.Delete(r, authorizationKey{})
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// HasCredentials returns whether the request gives the token, or the
+// username and password, of the options
+func HasCredentials(opts *AuthenticationOpts, r *http.Request) bool {
+	authorization, ok := gcontext.Get(r, authorizationKey{}).(string)
+	if !ok {
+		authorization = r.Header.Get("Authorization")
+	}
+
+	given := []byte(authorization)
+	for _, expected := range []AuthenticationOpts{{Token: opts.Token}, {Username: opts.Username, Password: opts.Password}} {
+		if authorization := expected.Authorization(); authorization != "" && subtle.ConstantTimeCompare(given, []byte(authorization)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// ReplyUnauthorized replies 401 to a request not giving the credentials
+// required
+func ReplyUnauthorized(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Basic realm="`+basicAuthRealm+`"`)
+	w.WriteHeader(http.StatusUnauthorized)
+	w.Write([]byte("Unauthorized"))
+}
+
+// RequireCredentials wraps the handler so that it serves only the requests
+// giving the token, or the username and password, of the options, the
+// others being replied 401
+func RequireCredentials(opts *AuthenticationOpts, handler auth.AuthenticatedHandlerFunc) auth.AuthenticatedHandlerFunc {
+	return func(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
+		if !HasCredentials(opts, &r.Request) {
+			ReplyUnauthorized(w)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// RequireRoutesCredentials wraps the handlers of the routes with
+// RequireCredentials, the routes being left untouched without options
+func RequireRoutesCredentials(opts *AuthenticationOpts, routes []Route) []Route {
+	if opts != nil {
+		for i := range routes {
+			routes[i].HandlerFunc = RequireCredentials(opts, routes[i].HandlerFunc)
+		}
+	}
+	return routes
 }
 
 type AuthenticationClient struct {
@@ -48,6 +143,7 @@ type AuthenticationClient struct {
 	Addr          string
 	Port          int
 	AuthToken     string
+	transport     http.RoundTripper
 }
 
 func (c *AuthenticationClient) getPrefix() string {
@@ -62,6 +158,9 @@ func (c *AuthenticationClient) SetHeaders(headers http.Header) {
 	if c.authenticated && c.AuthToken != "" {
 		headers.Set("Cookie", c.Cookie().String())
 	}
+	if authorization := c.authOptions.Authorization(); authorization != "" {
+		headers.Set("Authorization", authorization)
+	}
 }
 
 func (c *AuthenticationClient) Cookie() *http.Cookie {
@@ -77,7 +176,7 @@ func (c *AuthenticationClient) Authenticate() error {
 	}
 
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	resp, err := http.DefaultTransport.RoundTrip(req)
+	resp, err := c.transport.RoundTrip(req)
 	if err != nil {
 		return fmt.Errorf("Authentication failed: %s", err.Error())
 	}
@@ -99,10 +198,18 @@ func (c *AuthenticationClient) Authenticate() error {
 }
 
 func NewAuthenticationClient(addr string, port int, authOptions *AuthenticationOpts) *AuthenticationClient {
+	var transport http.RoundTripper = http.DefaultTransport
+	if t, err := NewTransportFromConfig("analyzer"); err == nil {
+		transport = t
+	} else {
+		logging.GetLogger().Errorf("Unable to set up the analyzer transport, using the default one: %s", err.Error())
+	}
+
 	return &AuthenticationClient{
 		Addr:        addr,
 		Port:        port,
 		authOptions: authOptions,
+		transport:   transport,
 	}
 }
 
@@ -118,7 +225,7 @@ func NewAuthenticationBackendFromConfig() (AuthenticationBackend, error) {
 	case "basic":
 		return NewBasicAuthenticationBackendFromConfig()
 	case "keystone":
-		return NewKeystoneAuthenticationBackendFromConfig(), nil
+		return NewKeystoneAuthenticationBackendFromConfig()
 	default:
 		return NewNoAuthenticationBackend(), nil
 	}
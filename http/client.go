@@ -28,15 +28,22 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/redhat-cip/skydive/config"
 	"github.com/redhat-cip/skydive/logging"
+	"github.com/redhat-cip/skydive/version"
 )
 
 type RestClient struct {
 	authClient *AuthenticationClient
 	client     *http.Client
+	// time waited for the replies, sent to the analyzer to abort the
+	// requests the client gave up on, none when zero
+	timeout time.Duration
 }
 
 type CrudClient struct {
@@ -45,11 +52,20 @@ type CrudClient struct {
 }
 
 func NewRestClient(addr string, port int, authOptions *AuthenticationOpts) *RestClient {
-	client := &http.Client{}
+	client, err := NewClientFromConfig("analyzer")
+	if err != nil {
+		logging.GetLogger().Errorf("Unable to set up the analyzer transport: %s", err.Error())
+		return nil
+	}
+
+	timeout := time.Duration(config.GetConfig().GetInt("client_timeout")) * time.Second
+	client.Timeout = timeout
+
 	authClient := NewAuthenticationClient(addr, port, authOptions)
 	return &RestClient{
 		client:     client,
 		authClient: authClient,
+		timeout:    timeout,
 	}
 }
 
@@ -63,26 +79,103 @@ func NewRestClientFromConfig(authOptions *AuthenticationOpts) *RestClient {
 	return NewRestClient(addr, port, authOptions)
 }
 
-func (c *RestClient) Request(method, path string, body io.Reader) (*http.Response, error) {
-	if !c.authClient.Authenticated() {
-		if err := c.authClient.Authenticate(); err != nil {
-			return nil, err
-		}
+// NewAdminRestClientFromConfig returns a client of the admin address of the
+// analyzer, the administrative routes being served only there
+func NewAdminRestClientFromConfig(authOptions *AuthenticationOpts) *RestClient {
+	addr, port, err := config.GetHostPortAttributes("analyzer", "admin_listen")
+	if err != nil {
+		logging.GetLogger().Errorf("Unable to parse analyzer admin address %s", err.Error())
+		return nil
 	}
 
+	return NewRestClient(addr, port, authOptions)
+}
+
+// versionChecks caches the result of the API version check of each
+// analyzer, done once per invocation
+var versionChecks = struct {
+	sync.Mutex
+	results map[string]error
+}{results: make(map[string]error)}
+
+func (c *RestClient) do(method, path string, body io.Reader) (*http.Response, error) {
 	url := fmt.Sprintf("%s/%s", c.authClient.getPrefix(), path)
 	req, err := http.NewRequest(method, url, body)
 	if err != nil {
 		return nil, err
 	}
 
+	return c.doRequest(req)
+}
+
+func (c *RestClient) doRequest(req *http.Request) (*http.Response, error) {
 	cookie := http.Cookie{Name: "authtok", Value: c.authClient.AuthToken}
 	req.Header.Set("Cookie", cookie.String())
+	if authorization := c.authClient.authOptions.Authorization(); authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(ClientVersionHeader, version.APIVersion)
+	req.Header.Set(RequestIDHeader, NewRequestID())
+	SetRequestTimeout(req, c.timeout)
 
 	return c.client.Do(req)
 }
 
+// checkVersion checks that the analyzer serves the API version of the
+// client, warning on a skew. The analyzers not giving their version are
+// assumed to serve it.
+func (c *RestClient) checkVersion() error {
+	prefix := c.authClient.getPrefix()
+
+	versionChecks.Lock()
+	defer versionChecks.Unlock()
+
+	if err, ok := versionChecks.results[prefix]; ok {
+		return err
+	}
+
+	resp, err := c.do("GET", "api/version", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		var info APIVersionInfo
+		if err = json.NewDecoder(resp.Body).Decode(&info); err != nil {
+			return fmt.Errorf("Unable to decode the analyzer version: %s", err.Error())
+		}
+
+		var warning string
+		if warning, err = checkAPIVersion(info, version.APIVersion); warning != "" {
+			logging.GetLogger().Warning(warning)
+		}
+	case http.StatusNotFound:
+		logging.GetLogger().Warningf("The analyzer doesn't report its API version, it may not serve the client API version %s", version.APIVersion)
+	default:
+		return fmt.Errorf("Unable to get the analyzer version: %s", resp.Status)
+	}
+
+	versionChecks.results[prefix] = err
+	return err
+}
+
+func (c *RestClient) Request(method, path string, body io.Reader) (*http.Response, error) {
+	if !c.authClient.Authenticated() {
+		if err := c.authClient.Authenticate(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := c.checkVersion(); err != nil {
+		return nil, err
+	}
+
+	return c.do(method, path, body)
+}
+
 func NewCrudClient(addr string, port int, authOpts *AuthenticationOpts, root string) *CrudClient {
 	restClient := NewRestClient(addr, port, authOpts)
 	if restClient == nil {
@@ -115,7 +208,7 @@ func (c *CrudClient) List(resource string, values interface{}) error {
 	}
 
 	if resp.StatusCode != 200 {
-		return errors.New(fmt.Sprintf("Failed to retrieve list of %s: %s", resource, resp.Status))
+		return errors.New(fmt.Sprintf("Failed to retrieve list of %s: %s%s", resource, resp.Status, RequestIDDetails(resp)))
 	}
 
 	return json.NewDecoder(resp.Body).Decode(values)
@@ -129,12 +222,30 @@ func (c *CrudClient) Get(resource string, id string, value interface{}) error {
 	}
 
 	if resp.StatusCode != 200 {
-		return errors.New(fmt.Sprintf("Failed to retrieve %s: %s", resource, resp.Status))
+		return errors.New(fmt.Sprintf("Failed to retrieve %s: %s%s", resource, resp.Status, RequestIDDetails(resp)))
 	}
 
 	return json.NewDecoder(resp.Body).Decode(value)
 }
 
+// errorDetails returns the reason given by the body of an error reply
+func errorDetails(resp *http.Response) string {
+	data, _ := ioutil.ReadAll(resp.Body)
+	if len(data) == 0 {
+		return ""
+	}
+	return ": " + ErrorMessage(data)
+}
+
+// RequestIDDetails returns the ID of the request of a failed reply, to be
+// quoted when reporting the failure
+func RequestIDDetails(resp *http.Response) string {
+	if id := RequestID(resp); id != "" {
+		return " (request ID " + id + ")"
+	}
+	return ""
+}
+
 func (c *CrudClient) Create(resource string, value interface{}) error {
 	s, err := json.Marshal(value)
 	if err != nil {
@@ -151,7 +262,7 @@ func (c *CrudClient) Create(resource string, value interface{}) error {
 	}
 
 	if resp.StatusCode != 200 {
-		return errors.New(fmt.Sprintf("Failed to create %s: %s", resource, resp.Status))
+		return errors.New(fmt.Sprintf("Failed to create %s: %s%s%s", resource, resp.Status, errorDetails(resp), RequestIDDetails(resp)))
 	}
 
 	return json.NewDecoder(resp.Body).Decode(value)
@@ -172,7 +283,7 @@ func (c *CrudClient) Update(resource string, id string, value interface{}) error
 	}
 
 	if resp.StatusCode != 200 {
-		return errors.New(fmt.Sprintf("Failed to update %s: %s", resource, resp.Status))
+		return errors.New(fmt.Sprintf("Failed to update %s: %s%s", resource, resp.Status, RequestIDDetails(resp)))
 	}
 
 	return json.NewDecoder(resp.Body).Decode(value)
@@ -187,7 +298,7 @@ func (c *CrudClient) Delete(resource string, id string) error {
 	}
 
 	if resp.StatusCode != 200 {
-		return errors.New(fmt.Sprintf("Failed to delete %s: %s", resource, resp.Status))
+		return errors.New(fmt.Sprintf("Failed to delete %s: %s%s%s", resource, resp.Status, errorDetails(resp), RequestIDDetails(resp)))
 	}
 
 	return nil
@@ -0,0 +1,70 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// RequestTimeoutHeader carries the time in milliseconds the client waits
+// for the reply. Relative to the reception of the request, it doesn't depend
+// on the clocks of the client and of the server being in sync.
+const RequestTimeoutHeader = "X-Request-Timeout"
+
+// SetRequestTimeout sets the timeout of the request, none when not positive
+func SetRequestTimeout(req *http.Request, timeout time.Duration) {
+	if timeout > 0 {
+		req.Header.Set(RequestTimeoutHeader, strconv.FormatInt(int64(timeout/time.Millisecond), 10))
+	}
+}
+
+// withRequestTimeout cancels the context of the requests once the client
+// stopped waiting for the reply, aborting the searches and the queries
+// still running
+func withRequestTimeout(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		value := r.Header.Get(RequestTimeoutHeader)
+		if value == "" {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		ms, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || ms <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(fmt.Sprintf("Invalid %s header: %s", RequestTimeoutHeader, value)))
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(RequestContext(r), time.Duration(ms)*time.Millisecond)
+		defer cancel()
+
+		SetRequestContext(r, ctx)
+		handler.ServeHTTP(w, r)
+	})
+}
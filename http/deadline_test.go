@@ -0,0 +1,89 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/abbot/go-http-auth"
+)
+
+func TestRequestTimeout(t *testing.T) {
+	aborted := make(chan error, 1)
+	handler := func(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
+		select {
+		case <-RequestContext(&r.Request).Done():
+			aborted <- RequestContext(&r.Request).Err()
+		case <-time.After(5 * time.Second):
+			aborted <- nil
+		}
+	}
+
+	server := NewServer("analyzer", "127.0.0.1", freePort(t), NewNoAuthenticationBackend())
+	server.RegisterRoutes([]Route{{"Slow", "GET", "/api/slow", handler}})
+
+	if err := server.Listen(); err != nil {
+		t.Fatal(err.Error())
+	}
+	go server.Serve()
+	defer server.Stop()
+
+	timeout := 100 * time.Millisecond
+	req, err := http.NewRequest("GET", fmt.Sprintf("http://127.0.0.1:%d/api/slow", server.Port), nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	SetRequestTimeout(req, timeout)
+
+	start := time.Now()
+	client := &http.Client{Timeout: timeout}
+	if resp, err := client.Do(req); err == nil {
+		resp.Body.Close()
+		t.Error("Expected the client to give up")
+	}
+
+	select {
+	case err := <-aborted:
+		if err == nil {
+			t.Fatal("Expected the request to be aborted")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Request still running after the client gave up")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Request aborted after %s", elapsed)
+	}
+
+	req.Header.Set(RequestTimeoutHeader, "soon")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected an invalid timeout to be rejected, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,95 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package http
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// drainPollInterval is the interval at which the connections of a draining
+// server are checked
+const drainPollInterval = 50 * time.Millisecond
+
+// connTracker tracks the connections of a server by their state, for the
+// server to be drained without http.Server.Shutdown, only available from
+// Go 1.8. The hijacked connections aren't tracked anymore.
+type connTracker struct {
+	sync.Mutex
+	conns    map[net.Conn]http.ConnState
+	draining bool
+}
+
+// track is the ConnState hook of the server, the connections becoming idle
+// while draining being closed
+func (t *connTracker) track(c net.Conn, state http.ConnState) {
+	t.Lock()
+	defer t.Unlock()
+
+	switch state {
+	case http.StateHijacked, http.StateClosed:
+		delete(t.conns, c)
+	default:
+		t.conns[c] = state
+		if t.draining && state == http.StateIdle {
+			c.Close()
+		}
+	}
+}
+
+// drain disables the keep-alives of the server, closes its idle connections
+// and waits up to the deadline for the others to be closed once their
+// request completed
+func (t *connTracker) drain(srv *http.Server, deadline time.Time) error {
+	srv.SetKeepAlivesEnabled(false)
+
+	t.Lock()
+	t.draining = true
+	for c, state := range t.conns {
+		if state == http.StateIdle {
+			c.Close()
+		}
+	}
+	t.Unlock()
+
+	for {
+		t.Lock()
+		active := len(t.conns)
+		t.Unlock()
+
+		if active == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return errors.New("Timeout while waiting for the requests in progress")
+		}
+		time.Sleep(drainPollInterval)
+	}
+}
+
+func newConnTracker() *connTracker {
+	return &connTracker{conns: make(map[net.Conn]http.ConnState)}
+}
@@ -0,0 +1,132 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/redhat-cip/skydive/common"
+	"github.com/redhat-cip/skydive/config"
+)
+
+type cachedResponse struct {
+	etag    string
+	data    []byte
+	fetched time.Time
+}
+
+// cachedResponses caches the responses of the analyzers by address and
+// path, for the lifetime of the client
+var cachedResponses = struct {
+	sync.Mutex
+	entries map[string]*cachedResponse
+}{entries: make(map[string]*cachedResponse)}
+
+// CachedGet decodes the response of a GET of path into value, the response
+// being cached for ttl and then revalidated with its ETag
+func (c *RestClient) CachedGet(path string, ttl time.Duration, value interface{}) error {
+	key := c.authClient.getPrefix() + "/" + path
+
+	cachedResponses.Lock()
+	defer cachedResponses.Unlock()
+
+	cached := cachedResponses.entries[key]
+	if cached == nil || time.Now().Sub(cached.fetched) >= ttl {
+		if err := c.revalidate(path, key, cached); err != nil {
+			return err
+		}
+		cached = cachedResponses.entries[key]
+	}
+
+	return json.Unmarshal(cached.data, value)
+}
+
+func (c *RestClient) revalidate(path string, key string, cached *cachedResponse) error {
+	if !c.authClient.Authenticated() {
+		if err := c.authClient.Authenticate(); err != nil {
+			return err
+		}
+	}
+	if err := c.checkVersion(); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/%s", c.authClient.getPrefix(), path), nil)
+	if err != nil {
+		return err
+	}
+	if cached != nil && cached.etag != "" {
+		req.Header.Set("If-None-Match", cached.etag)
+	}
+
+	resp, err := c.doRequest(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		if cached != nil {
+			cached.fetched = time.Now()
+			return nil
+		}
+	case http.StatusOK:
+		data, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		cachedResponses.entries[key] = &cachedResponse{etag: resp.Header.Get("ETag"), data: data, fetched: time.Now()}
+		return nil
+	}
+
+	data, _ := ioutil.ReadAll(resp.Body)
+	return fmt.Errorf("Failed to get %s, %s: %s%s", path, resp.Status, ErrorMessage(data), RequestIDDetails(resp))
+}
+
+func (c *RestClient) keys(path string) ([]common.KeyInfo, error) {
+	ttl := time.Duration(config.GetConfig().GetInt("client_keys_ttl")) * time.Second
+
+	var keys []common.KeyInfo
+	if err := c.CachedGet(path, ttl, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// TopologyKeys returns the catalog of the metadata keys of the nodes, cached
+// for client_keys_ttl
+func (c *RestClient) TopologyKeys() ([]common.KeyInfo, error) {
+	return c.keys("api/topology/keys")
+}
+
+// FlowKeys returns the catalog of the flow fields and attributes, cached for
+// client_keys_ttl
+func (c *RestClient) FlowKeys() ([]common.KeyInfo, error) {
+	return c.keys("api/flow/keys")
+}
@@ -0,0 +1,96 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/abbot/go-http-auth"
+
+	"github.com/redhat-cip/skydive/common"
+	"github.com/redhat-cip/skydive/version"
+)
+
+func TestCachedGet(t *testing.T) {
+	var requests, notModified int
+	etag := `W/"1"`
+	keys := []common.KeyInfo{{Key: "Name", Types: []string{"string"}, Count: 1}}
+
+	handler := func(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
+		requests++
+		if r.Header.Get("If-None-Match") == etag {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		json.NewEncoder(w).Encode(keys)
+	}
+	versionHandler := func(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
+		json.NewEncoder(w).Encode(APIVersionInfo{Version: version.Version, APIVersion: version.APIVersion})
+	}
+
+	server := NewServer("analyzer", "127.0.0.1", freePort(t), NewNoAuthenticationBackend())
+	server.RegisterRoutes([]Route{
+		{"TopologyKeys", "GET", "/api/topology/keys", handler},
+		{"Version", "GET", "/api/version", versionHandler},
+	})
+	if err := server.Listen(); err != nil {
+		t.Fatal(err.Error())
+	}
+	go server.Serve()
+	defer server.Stop()
+
+	client := NewRestClient("127.0.0.1", server.Port, &AuthenticationOpts{})
+
+	get := func(ttl time.Duration) []common.KeyInfo {
+		var keys []common.KeyInfo
+		if err := client.CachedGet("api/topology/keys", ttl, &keys); err != nil {
+			t.Fatal(err.Error())
+		}
+		return keys
+	}
+
+	if k := get(time.Hour); len(k) != 1 || k[0].Key != "Name" || requests != 1 {
+		t.Fatalf("Expected the keys to be fetched, got %v after %d requests", k, requests)
+	}
+
+	// served from the cache until the ttl expires
+	if k := get(time.Hour); len(k) != 1 || requests != 1 {
+		t.Errorf("Expected the keys to be cached, got %v after %d requests", k, requests)
+	}
+
+	// then revalidated
+	if k := get(0); len(k) != 1 || requests != 2 || notModified != 1 {
+		t.Errorf("Expected the keys to be revalidated, got %v after %d requests", k, requests)
+	}
+
+	etag = `W/"2"`
+	keys = append(keys, common.KeyInfo{Key: "MTU", Types: []string{"number"}, Count: 1})
+	if k := get(0); len(k) != 2 || requests != 3 {
+		t.Errorf("Expected the new keys, got %v after %d requests", k, requests)
+	}
+}
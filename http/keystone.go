@@ -37,6 +37,20 @@ import (
 type KeystoneAuthenticationBackend struct {
 	AuthURL string
 	Tenant  string
+	client  *http.Client
+}
+
+func (b *KeystoneAuthenticationBackend) newProvider() (*gophercloud.ProviderClient, error) {
+	provider, err := openstack.NewClient(b.AuthURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if b.client != nil {
+		provider.HTTPClient = *b.client
+	}
+
+	return provider, nil
 }
 
 func (b *KeystoneAuthenticationBackend) CheckUser(r *http.Request) (string, error) {
@@ -50,7 +64,7 @@ func (b *KeystoneAuthenticationBackend) CheckUser(r *http.Request) (string, erro
 		return "", WrongCredentials
 	}
 
-	provider, err := openstack.NewClient(b.AuthURL)
+	provider, err := b.newProvider()
 	if err != nil {
 		return "", err
 	}
@@ -96,7 +110,7 @@ func (b *KeystoneAuthenticationBackend) Authenticate(username string, password s
 		TenantName:       b.Tenant,
 	}
 
-	provider, err := openstack.NewClient(b.AuthURL)
+	provider, err := b.newProvider()
 	if err != nil {
 		return "", err
 	}
@@ -133,9 +147,17 @@ func NewKeystoneBackend(authURL string, tenant string) *KeystoneAuthenticationBa
 	}
 }
 
-func NewKeystoneAuthenticationBackendFromConfig() *KeystoneAuthenticationBackend {
+func NewKeystoneAuthenticationBackendFromConfig() (*KeystoneAuthenticationBackend, error) {
 	authURL := config.GetConfig().GetString("openstack.auth_url")
 	tenant := config.GetConfig().GetString("openstack.tenant_name")
 
-	return NewKeystoneBackend(authURL, tenant)
+	client, err := NewClientFromConfig("keystone")
+	if err != nil {
+		return nil, err
+	}
+
+	backend := NewKeystoneBackend(authURL, tenant)
+	backend.client = client
+
+	return backend, nil
 }
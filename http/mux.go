@@ -0,0 +1,314 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redhat-cip/skydive/logging"
+)
+
+// ErrMuxClosed is returned by the listeners of a closed Mux
+var ErrMuxClosed = errors.New("Multiplexer closed")
+
+// The results of a MuxMatcher
+const (
+	MuxNoMatch = iota
+	MuxMatched
+	MuxNeedMore
+)
+
+// MuxMatcher tells from the first bytes of a connection whether it speaks a
+// protocol, MuxNeedMore asking for more bytes when it can't tell yet
+type MuxMatcher func(peek []byte) int
+
+// matchPrefix matches the connections starting with one of the prefixes
+func matchPrefix(peek []byte, prefixes ...[]byte) int {
+	result := MuxNoMatch
+	for _, prefix := range prefixes {
+		if len(peek) >= len(prefix) {
+			if bytes.HasPrefix(peek, prefix) {
+				return MuxMatched
+			}
+		} else if bytes.HasPrefix(prefix, peek) {
+			result = MuxNeedMore
+		}
+	}
+	return result
+}
+
+var http1Methods = [][]byte{
+	[]byte("GET "), []byte("HEAD "), []byte("POST "), []byte("PUT "), []byte("DELETE "),
+	[]byte("OPTIONS "), []byte("PATCH "), []byte("CONNECT "), []byte("TRACE "),
+}
+
+// MatchHTTP1 matches the HTTP/1.x requests, the websockets included
+func MatchHTTP1(peek []byte) int {
+	return matchPrefix(peek, http1Methods...)
+}
+
+var http2Preface = []byte("PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n")
+
+// MatchHTTP2 matches the HTTP/2 connections without TLS, as the ones of
+// gRPC, starting with the connection preface
+func MatchHTTP2(peek []byte) int {
+	return matchPrefix(peek, http2Preface)
+}
+
+// MatchTLS matches the TLS connections, starting with a handshake record
+func MatchTLS(peek []byte) int {
+	return matchPrefix(peek, []byte{0x16, 0x03})
+}
+
+// MuxStats counts the connections routed to each protocol and the ones
+// rejected, of an unknown protocol or not telling it in time
+type MuxStats struct {
+	Accepted map[string]uint64
+	Rejected uint64
+}
+
+// MuxListener is the listener of the connections of a protocol
+type MuxListener struct {
+	mux      *Mux
+	name     string
+	match    MuxMatcher
+	conns    chan net.Conn
+	closed   chan bool
+	once     sync.Once
+	accepted uint64
+}
+
+func (l *MuxListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closed:
+		return nil, ErrMuxClosed
+	}
+}
+
+func (l *MuxListener) Close() error {
+	l.once.Do(func() { close(l.closed) })
+	return nil
+}
+
+// Stop closes the listener, as Close
+func (l *MuxListener) Stop() {
+	l.Close()
+}
+
+func (l *MuxListener) Addr() net.Addr {
+	return l.mux.Listener.Addr()
+}
+
+// muxConn replays the bytes read to detect the protocol
+type muxConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *muxConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+// Mux shares a listener between protocols, told apart by the first bytes
+// of the connections. The protocols are matched in the order they are
+// registered with Match. The connections of an unknown protocol, or not
+// sending PeekSize bytes needed to tell it within PeekTimeout, are closed.
+type Mux struct {
+	sync.Mutex
+	Listener    net.Listener
+	PeekTimeout time.Duration
+	PeekSize    int
+	listeners   []*MuxListener
+	rejected    uint64
+	pending     map[net.Conn]bool
+	closed      bool
+	wg          sync.WaitGroup
+}
+
+// Match returns the listener of the connections matched by match
+func (m *Mux) Match(name string, match MuxMatcher) *MuxListener {
+	l := &MuxListener{
+		mux:    m,
+		name:   name,
+		match:  match,
+		conns:  make(chan net.Conn),
+		closed: make(chan bool),
+	}
+
+	m.Lock()
+	m.listeners = append(m.listeners, l)
+	m.Unlock()
+
+	return l
+}
+
+func (m *Mux) reject(conn net.Conn, reason string) {
+	atomic.AddUint64(&m.rejected, 1)
+	logging.GetLogger().Debugf("Connection from %s rejected: %s", conn.RemoteAddr(), reason)
+	conn.Close()
+}
+
+// detect returns the listener of the protocol of the connection, nil if
+// none matches
+func (m *Mux) detect(r *bufio.Reader, listeners []*MuxListener) (*MuxListener, string) {
+	for size := 1; size <= m.PeekSize; size++ {
+		peek, err := r.Peek(size)
+		if err != nil {
+			return nil, err.Error()
+		}
+
+		more := false
+		for _, l := range listeners {
+			switch l.match(peek) {
+			case MuxMatched:
+				return l, ""
+			case MuxNeedMore:
+				more = true
+			}
+		}
+		if !more {
+			return nil, "unknown protocol"
+		}
+	}
+	return nil, "protocol not detected"
+}
+
+func (m *Mux) route(conn net.Conn) {
+	defer m.wg.Done()
+
+	m.Lock()
+	listeners := m.listeners
+	m.Unlock()
+
+	conn.SetReadDeadline(time.Now().Add(m.PeekTimeout))
+	r := bufio.NewReaderSize(conn, m.PeekSize)
+	l, reason := m.detect(r, listeners)
+
+	m.Lock()
+	delete(m.pending, conn)
+	closed := m.closed
+	m.Unlock()
+
+	if closed {
+		conn.Close()
+		return
+	}
+	if l == nil {
+		m.reject(conn, reason)
+		return
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	select {
+	case l.conns <- &muxConn{Conn: conn, r: r}:
+		atomic.AddUint64(&l.accepted, 1)
+	case <-l.closed:
+		conn.Close()
+	}
+}
+
+// Serve accepts the connections and routes them to the listeners of their
+// protocol until the Mux is closed
+func (m *Mux) Serve() error {
+	for {
+		conn, err := m.Listener.Accept()
+		if err != nil {
+			m.Lock()
+			closed := m.closed
+			m.Unlock()
+
+			if closed {
+				return nil
+			}
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				time.Sleep(10 * time.Millisecond)
+				continue
+			}
+			return err
+		}
+
+		m.Lock()
+		if m.closed {
+			m.Unlock()
+			conn.Close()
+			return nil
+		}
+		m.pending[conn] = true
+		m.wg.Add(1)
+		m.Unlock()
+
+		go m.route(conn)
+	}
+}
+
+// Close closes the listener, the listeners of the protocols and the
+// connections whose protocol isn't detected yet
+func (m *Mux) Close() error {
+	m.Lock()
+	if m.closed {
+		m.Unlock()
+		return nil
+	}
+	m.closed = true
+	err := m.Listener.Close()
+	for _, l := range m.listeners {
+		l.Close()
+	}
+	for conn := range m.pending {
+		conn.Close()
+	}
+	m.Unlock()
+
+	m.wg.Wait()
+	return err
+}
+
+// Stats returns the number of connections routed to each protocol and of
+// the rejected ones
+func (m *Mux) Stats() MuxStats {
+	m.Lock()
+	defer m.Unlock()
+
+	stats := MuxStats{Accepted: make(map[string]uint64), Rejected: atomic.LoadUint64(&m.rejected)}
+	for _, l := range m.listeners {
+		stats.Accepted[l.name] = atomic.LoadUint64(&l.accepted)
+	}
+	return stats
+}
+
+func NewMux(l net.Listener, peekTimeout time.Duration, peekSize int) *Mux {
+	return &Mux{
+		Listener:    l,
+		PeekTimeout: peekTimeout,
+		PeekSize:    peekSize,
+		pending:     make(map[net.Conn]bool),
+	}
+}
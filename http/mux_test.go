@@ -0,0 +1,202 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package http
+
+import (
+	"crypto/tls"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func newTestMux(t *testing.T) *Mux {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return NewMux(l, 200*time.Millisecond, 64)
+}
+
+// acceptPeek accepts a connection on the listener and reads its first bytes
+func acceptPeek(t *testing.T, l net.Listener, size int) []byte {
+	conns := make(chan net.Conn, 1)
+	go func() {
+		if conn, err := l.Accept(); err == nil {
+			conns <- conn
+		}
+	}()
+
+	select {
+	case conn := <-conns:
+		defer conn.Close()
+		data := make([]byte, size)
+		if _, err := io.ReadFull(conn, data); err != nil {
+			t.Fatal(err.Error())
+		}
+		return data
+	case <-time.After(5 * time.Second):
+		t.Fatal("No connection routed")
+	}
+	return nil
+}
+
+func expectClosed(t *testing.T, conn net.Conn) {
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("Expected the connection to be closed, got %v", err)
+	}
+}
+
+func TestMux(t *testing.T) {
+	mux := newTestMux(t)
+	tlsL := mux.Match("tls", MatchTLS)
+	h2L := mux.Match("http2", MatchHTTP2)
+	httpL := mux.Match("http", MatchHTTP1)
+	go mux.Serve()
+	defer mux.Close()
+
+	addr := mux.Listener.Addr().String()
+
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})}
+	go srv.Serve(httpL)
+
+	resp, err := http.Get("http://" + addr + "/")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "ok" {
+		t.Errorf("Expected the HTTP request to be served, got %q", string(body))
+	}
+
+	// the bytes read to detect the protocol are replayed
+	go func() {
+		if conn, err := net.Dial("tcp", addr); err == nil {
+			conn.Write(http2Preface)
+			time.Sleep(time.Second)
+			conn.Close()
+		}
+	}()
+	if data := acceptPeek(t, h2L, len(http2Preface)); string(data) != string(http2Preface) {
+		t.Errorf("Expected the HTTP/2 preface, got %q", data)
+	}
+
+	go func() {
+		if conn, err := net.Dial("tcp", addr); err == nil {
+			tls.Client(conn, &tls.Config{InsecureSkipVerify: true}).Handshake()
+			conn.Close()
+		}
+	}()
+	if data := acceptPeek(t, tlsL, 2); data[0] != 0x16 || data[1] != 0x03 {
+		t.Errorf("Expected a TLS handshake, got %v", data)
+	}
+
+	// an unknown protocol is rejected at once, a silent client after the
+	// peek timeout
+	garbage, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	garbage.Write([]byte("garbage\r\n"))
+	expectClosed(t, garbage)
+
+	silent, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	start := time.Now()
+	expectClosed(t, silent)
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("Expected the silent connection to be closed after the peek timeout, got %s", elapsed)
+	}
+
+	stats := mux.Stats()
+	if stats.Rejected != 2 || stats.Accepted["http"] != 1 || stats.Accepted["http2"] != 1 || stats.Accepted["tls"] != 1 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
+
+func TestMuxClose(t *testing.T) {
+	mux := newTestMux(t)
+	httpL := mux.Match("http", MatchHTTP1)
+
+	served := make(chan error, 1)
+	go func() { served <- mux.Serve() }()
+
+	// a connection whose protocol isn't detected yet
+	conn, err := net.Dial("tcp", mux.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	conn.Write([]byte("GE"))
+	time.Sleep(50 * time.Millisecond)
+
+	if err := mux.Close(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("Expected Serve to return without error, got %s", err.Error())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve didn't return")
+	}
+
+	if _, err := httpL.Accept(); err != ErrMuxClosed {
+		t.Errorf("Expected the listener to be closed, got %v", err)
+	}
+	expectClosed(t, conn)
+	if _, err := net.Dial("tcp", mux.Listener.Addr().String()); err == nil {
+		t.Error("Expected the port to be closed")
+	}
+}
+
+func TestMuxMatchers(t *testing.T) {
+	for _, test := range []struct {
+		match    MuxMatcher
+		peek     string
+		expected int
+	}{
+		{MatchHTTP1, "GET / HTTP/1.1", MuxMatched},
+		{MatchHTTP1, "DEL", MuxNeedMore},
+		{MatchHTTP1, "GETX", MuxNoMatch},
+		{MatchHTTP1, "PRI * HTTP/2.0", MuxNoMatch},
+		{MatchHTTP2, "PRI * HTTP/2.0\r\n", MuxNeedMore},
+		{MatchHTTP2, string(http2Preface), MuxMatched},
+		{MatchTLS, "\x16", MuxNeedMore},
+		{MatchTLS, "\x16\x03\x01", MuxMatched},
+		{MatchTLS, "\x17\x03", MuxNoMatch},
+	} {
+		if result := test.match([]byte(test.peek)); result != test.expected {
+			t.Errorf("%q: expected %d, got %d", test.peek, test.expected, result)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+/*
+ * Copyright (C) 2015 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/redhat-cip/skydive/config"
+)
+
+func servePath(router http.Handler, path string) int {
+	req, _ := http.NewRequest("GET", path, nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestPprofServer(t *testing.T) {
+	server := NewServer("analyzer", "127.0.0.1", 8082, NewNoAuthenticationBackend())
+	RegisterPprofFromConfig(server)
+	if code := servePath(server.AdminRouter, "/debug/pprof/"); code != http.StatusNotFound {
+		t.Fatalf("pprof should be disabled by default, got %d", code)
+	}
+
+	config.GetConfig().Set("analyzer.debug.pprof", true)
+	defer config.GetConfig().Set("analyzer.debug.pprof", false)
+
+	server = NewServer("analyzer", "127.0.0.1", 8082, NewNoAuthenticationBackend())
+	RegisterPprofFromConfig(server)
+
+	for _, path := range []string{"/debug/pprof/", "/debug/pprof/goroutine", "/debug/pprof/cmdline"} {
+		if code := servePath(server.AdminRouter, path); code != http.StatusOK {
+			t.Errorf("%s: expected status 200, got %d", path, code)
+		}
+		if code := servePath(server.Router, path); code == http.StatusOK {
+			t.Errorf("%s: should not be served on the public router", path)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+
+	gcontext "github.com/gorilla/context"
+	"github.com/nu7hatch/gouuid"
+	"golang.org/x/net/context"
+
+	"github.com/redhat-cip/skydive/logging"
+)
+
+// RequestIDHeader carries the ID of a request, generated by the client or
+// by the server, and returned in the reply so that the failures can be
+// correlated with the logs of the analyzer
+const RequestIDHeader = "X-Request-ID"
+
+// NewRequestID returns a new request ID
+func NewRequestID() string {
+	u, _ := uuid.NewV4()
+	return u.String()
+}
+
+// RequestID returns the ID of the request the response replies to, empty if
+// none
+func RequestID(resp *http.Response) string {
+	if id := resp.Header.Get(RequestIDHeader); id != "" {
+		return id
+	}
+	if resp.Request != nil {
+		return resp.Request.Header.Get(RequestIDHeader)
+	}
+	return ""
+}
+
+type requestIDKey struct{}
+
+type requestContextKey struct{}
+
+// GetRequestID returns the ID given to the request by the server, empty if
+// none
+func GetRequestID(r *http.Request) string {
+	id, _ := gcontext.Get(r, requestIDKey{}).(string)
+	return id
+}
+
+// RequestContext returns the context of the request, holding its logging
+// fields and cancelled once the client stopped waiting for the reply
+func RequestContext(r *http.Request) context.Context {
+	if ctx, ok := gcontext.Get(r, requestContextKey{}).(context.Context); ok {
+		return ctx
+	}
+	return context.Background()
+}
+
+// SetRequestContext replaces the context of the request
+func SetRequestContext(r *http.Request, ctx context.Context) {
+	gcontext.Set(r, requestContextKey{}, ctx)
+}
+
+// ErrorReply is the body of the error replies, giving the ID of the request
+// so that it can be quoted when reporting the failure
+type ErrorReply struct {
+	Error     string `json:"error"`
+	RequestID string `json:"request_id"`
+}
+
+// ErrorMessage returns the reason given by the body of an error reply
+func ErrorMessage(data []byte) string {
+	var reply ErrorReply
+	if err := json.Unmarshal(data, &reply); err == nil && reply.Error != "" {
+		return reply.Error
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// errorReplyWriter turns the error replies of the handlers, written as
+// plain text, into JSON error replies. The status and the message of the
+// error replies are kept until the handler returns.
+type errorReplyWriter struct {
+	http.ResponseWriter
+	id     string
+	wrote  bool
+	status int
+	body   bytes.Buffer
+}
+
+func (w *errorReplyWriter) WriteHeader(status int) {
+	if w.wrote {
+		return
+	}
+	w.wrote = true
+
+	if status >= 400 && !strings.Contains(w.Header().Get("Content-Type"), "json") {
+		w.status = status
+		return
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *errorReplyWriter) Write(b []byte) (int, error) {
+	if !w.wrote {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.status != 0 {
+		return w.body.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Hijack hands the connection over to the websocket upgrades
+func (w *errorReplyWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("Connection can't be hijacked")
+	}
+	return hijacker.Hijack()
+}
+
+// flush writes the error reply kept
+func (w *errorReplyWriter) flush() {
+	if w.status == 0 {
+		return
+	}
+
+	message := strings.TrimSpace(w.body.String())
+	if message == "" {
+		message = http.StatusText(w.status)
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.Header().Del("Content-Length")
+	w.ResponseWriter.WriteHeader(w.status)
+	json.NewEncoder(w.ResponseWriter).Encode(&ErrorReply{Error: message, RequestID: w.id})
+}
+
+// withRequestID gives an ID to the requests not having one, returns it in
+// the reply, along with the error message for the error replies, and adds
+// it to the logging fields of the request context
+func withRequestID(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if id == "" {
+			id = NewRequestID()
+			r.Header.Set(RequestIDHeader, id)
+		}
+		w.Header().Set(RequestIDHeader, id)
+
+		gcontext.Set(r, requestIDKey{}, id)
+		SetRequestContext(r, logging.NewContext(RequestContext(r), "request_id", id))
+		defer gcontext.Delete(r, requestIDKey{})
+		defer gcontext.Delete(r, requestContextKey{})
+
+		ew := &errorReplyWriter{ResponseWriter: w, id: id}
+		defer ew.flush()
+
+		handler.ServeHTTP(ew, r)
+	})
+}
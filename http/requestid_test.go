@@ -0,0 +1,105 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+package http
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/abbot/go-http-auth"
+)
+
+func TestRequestIDErrorReply(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
+		switch r.URL.Query().Get("reply") {
+		case "text":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("Invalid limit: x\n"))
+		case "json":
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"Health": "unhealthy"}`))
+		case "empty":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.Write([]byte("ok"))
+		}
+	}
+
+	server := NewServer("analyzer", "127.0.0.1", 0, NewNoAuthenticationBackend())
+	server.RegisterRoutes([]Route{{"Reply", "GET", "/api/reply", handler}})
+
+	ts := httptest.NewServer(server.Router)
+	defer ts.Close()
+
+	for _, test := range []struct {
+		reply    string
+		status   int
+		expected string
+	}{
+		{"text", http.StatusBadRequest, "Invalid limit: x"},
+		{"empty", http.StatusNotFound, "Not Found"},
+	} {
+		req, _ := http.NewRequest("GET", ts.URL+"/api/reply?reply="+test.reply, nil)
+		req.Header.Set(RequestIDHeader, "reply-"+test.reply)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		var reply ErrorReply
+		err = json.NewDecoder(resp.Body).Decode(&reply)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("Expected a JSON error reply: %s", err.Error())
+		}
+
+		if resp.StatusCode != test.status || reply.Error != test.expected || reply.RequestID != "reply-"+test.reply {
+			t.Errorf("Expected %d %q with the request ID, got %d %+v", test.status, test.expected, resp.StatusCode, reply)
+		}
+	}
+
+	// the JSON replies of the handlers and the successful ones are left as is
+	for reply, expected := range map[string]string{"json": `{"Health": "unhealthy"}`, "ok": "ok"} {
+		resp, err := http.Get(ts.URL + "/api/reply?reply=" + reply)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != expected {
+			t.Errorf("Expected the reply %s to be left as is, got %s", expected, body)
+		}
+	}
+
+	if message := ErrorMessage([]byte(`{"error": "Invalid limit", "request_id": "id"}`)); message != "Invalid limit" {
+		t.Errorf("Expected the message of the JSON error reply, got %q", message)
+	}
+	if message := ErrorMessage([]byte("Invalid limit\n")); message != "Invalid limit" {
+		t.Errorf("Expected the message of the plain text reply, got %q", message)
+	}
+}
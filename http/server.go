@@ -29,9 +29,11 @@ import (
 	"mime"
 	"net"
 	"net/http"
+	"os"
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/abbot/go-http-auth"
 	"github.com/gorilla/mux"
@@ -51,23 +53,36 @@ type Route struct {
 	HandlerFunc auth.AuthenticatedHandlerFunc
 }
 
+// Server serves the public routes on Addr:Port and the administrative ones,
+// registered with RegisterAdminRoutes, on AdminAddr:AdminPort. The
+// administrative routes are not served if no admin port is set.
 type Server struct {
-	Service string
-	Router  *mux.Router
-	Addr    string
-	Port    int
-	Auth    AuthenticationBackend
-	lock    sync.Mutex
-	sl      *stoppableListener.StoppableListener
-	wg      sync.WaitGroup
+	Service     string
+	Router      *mux.Router
+	AdminRouter *mux.Router
+	Addr        string
+	Port        int
+	AdminAddr   string
+	AdminPort   int
+	Auth        AuthenticationBackend
+	// API versions of the clients seen recently
+	ClientVersions *ClientVersions
+	lock           sync.Mutex
+	sl             serverListener
+	adminSl        serverListener
+	srv            *http.Server
+	adminSrv       *http.Server
+	conns          *connTracker
+	adminConns     *connTracker
+	wg             sync.WaitGroup
 }
 
-func (s *Server) RegisterRoutes(routes []Route) {
+func (s *Server) registerRoutes(router *mux.Router, routes []Route) {
 	for _, route := range routes {
-		r := s.Router.
+		r := router.
 			Methods(route.Method).
 			Name(route.Name).
-			Handler(s.Auth.Wrap(route.HandlerFunc))
+			Handler(withRequestID(withRequestTimeout(withAuthorization(wrapAuthentication(s.Auth, s.checkClientVersion(route.HandlerFunc))))))
 		switch p := route.Path.(type) {
 		case string:
 			r.Path(p)
@@ -77,32 +92,240 @@ func (s *Server) RegisterRoutes(routes []Route) {
 	}
 }
 
-func (s *Server) ListenAndServe() {
-	defer s.wg.Done()
-	s.wg.Add(1)
+func (s *Server) RegisterRoutes(routes []Route) {
+	s.registerRoutes(s.Router, routes)
+}
+
+// RegisterAdminRoutes registers privileged routes, served only on the admin
+// address
+func (s *Server) RegisterAdminRoutes(routes []Route) {
+	s.registerRoutes(s.AdminRouter, routes)
+}
+
+// serverListener is the listener of an address, or of the HTTP connections
+// of a Mux
+type serverListener interface {
+	net.Listener
+	Stop()
+}
+
+func listen(addr string, port int) (*stoppableListener.StoppableListener, error) {
+	listener, err := net.Listen("tcp", fmt.Sprintf("%s:%d", addr, port))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to listen on %s:%d: %s", addr, port, err.Error())
+	}
 
-	listener, err := net.Listen("tcp", fmt.Sprintf("%s:%d", s.Addr, s.Port))
+	sl, err := stoppableListener.New(listener)
 	if err != nil {
-		logging.GetLogger().Fatalf("Failed to listen on %s:%d: %s", s.Addr, s.Port, err.Error())
+		listener.Close()
+		return nil, fmt.Errorf("Failed to create stoppable listener: %s", err.Error())
+	}
+
+	return sl, nil
+}
+
+func listenFile(f *os.File) (*stoppableListener.StoppableListener, error) {
+	listener, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to listen on the socket %s: %s", f.Name(), err.Error())
+	}
+
+	sl, err := stoppableListener.New(listener)
+	if err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("Failed to create stoppable listener: %s", err.Error())
+	}
+
+	return sl, nil
+}
+
+func (s *Server) setListeners(sl serverListener, adminSl *stoppableListener.StoppableListener) {
+	s.lock.Lock()
+	s.sl = sl
+	s.conns = newConnTracker()
+	s.srv = &http.Server{Handler: s.Router, ConnState: s.conns.track}
+	s.adminSl = nil
+	if adminSl != nil {
+		s.adminSl = adminSl
+		s.adminConns = newConnTracker()
+		s.adminSrv = &http.Server{Handler: s.AdminRouter, ConnState: s.adminConns.track}
+	}
+	s.lock.Unlock()
+}
+
+// Listen binds the server and admin addresses, the requests are served only
+// once Serve is called.
+func (s *Server) Listen() error {
+	sl, err := listen(s.Addr, s.Port)
+	if err != nil {
+		return err
+	}
+
+	var adminSl *stoppableListener.StoppableListener
+	if s.AdminPort != 0 {
+		if adminSl, err = listen(s.AdminAddr, s.AdminPort); err != nil {
+			sl.Stop()
+			return err
+		}
+	}
+
+	s.setListeners(sl, adminSl)
+
+	return nil
+}
+
+// ListenOn serves the connections of the HTTP listener of a Mux instead
+// of binding the address, the admin address being still bound
+func (s *Server) ListenOn(l *MuxListener) error {
+	var adminSl *stoppableListener.StoppableListener
+	if s.AdminPort != 0 {
+		var err error
+		if adminSl, err = listen(s.AdminAddr, s.AdminPort); err != nil {
+			return err
+		}
+	}
+
+	s.setListeners(l, adminSl)
+
+	return nil
+}
+
+// ListenFiles listens on the sockets of another server, given by Files,
+// instead of binding the addresses
+func (s *Server) ListenFiles(files map[string]*os.File) error {
+	f, ok := files["http"]
+	if !ok {
+		return errors.New("No http socket to listen on")
+	}
+
+	sl, err := listenFile(f)
+	if err != nil {
+		return err
+	}
+
+	var adminSl *stoppableListener.StoppableListener
+	if f, ok := files["admin"]; ok {
+		if adminSl, err = listenFile(f); err != nil {
+			sl.Close()
+			return err
+		}
 	}
 
+	s.setListeners(sl, adminSl)
+
+	return nil
+}
+
+// Files returns copies of the listening sockets, named http and admin
+func (s *Server) Files() (map[string]*os.File, error) {
 	s.lock.Lock()
-	s.sl, err = stoppableListener.New(listener)
+	defer s.lock.Unlock()
+
+	if s.sl == nil {
+		return nil, errors.New("Server not listening")
+	}
+
+	sl, ok := s.sl.(*stoppableListener.StoppableListener)
+	if !ok {
+		return nil, errors.New("Server listening on a multiplexed port")
+	}
+
+	files := make(map[string]*os.File)
+	f, err := sl.TCPListener.File()
 	if err != nil {
-		s.lock.Unlock()
-		logging.GetLogger().Fatalf("Failed to create stoppable listener: %s", err.Error())
+		return nil, err
 	}
+	files["http"] = f
+
+	if s.adminSl != nil {
+		if f, err = s.adminSl.(*stoppableListener.StoppableListener).TCPListener.File(); err != nil {
+			files["http"].Close()
+			return nil, err
+		}
+		files["admin"] = f
+	}
+
+	return files, nil
+}
+
+// Listening returns whether the server listens, with Listen or ListenFiles
+func (s *Server) Listening() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.sl != nil
+}
+
+func (s *Server) Serve() {
+	defer s.wg.Done()
+	s.wg.Add(1)
+
+	s.lock.Lock()
+	sl, adminSl := s.sl, s.adminSl
+	srv, adminSrv := s.srv, s.adminSrv
 	s.lock.Unlock()
 
-	http.Serve(s.sl, s.Router)
+	if adminSl != nil {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			adminSrv.Serve(adminSl)
+		}()
+	}
+
+	srv.Serve(sl)
+}
+
+func (s *Server) ListenAndServe() {
+	if err := s.Listen(); err != nil {
+		logging.GetLogger().Fatal(err.Error())
+	}
+
+	s.Serve()
 }
 
 func (s *Server) Stop() {
 	s.lock.Lock()
-	s.sl.Stop()
+	if s.sl != nil {
+		s.sl.Stop()
+	}
+	if s.adminSl != nil {
+		s.adminSl.Stop()
+	}
+	s.sl, s.adminSl = nil, nil
+	s.lock.Unlock()
+
+	s.wg.Wait()
+}
+
+// Drain stops accepting connections and waits up to timeout for the
+// requests in progress to complete, the idle connections being closed. The
+// websockets, hijacked, are left open.
+func (s *Server) Drain(timeout time.Duration) error {
+	s.lock.Lock()
+	servers := []*http.Server{}
+	trackers := []*connTracker{}
+	if s.sl != nil {
+		s.sl.Stop()
+		servers, trackers = append(servers, s.srv), append(trackers, s.conns)
+	}
+	if s.adminSl != nil {
+		s.adminSl.Stop()
+		servers, trackers = append(servers, s.adminSrv), append(trackers, s.adminConns)
+	}
+	s.sl, s.adminSl = nil, nil
 	s.lock.Unlock()
 
+	deadline := time.Now().Add(timeout)
+
+	var err error
+	for i, srv := range servers {
+		if e := trackers[i].drain(srv, deadline); e != nil {
+			err = e
+		}
+	}
 	s.wg.Wait()
+
+	return err
 }
 
 func serveStatics(w http.ResponseWriter, r *http.Request) {
@@ -196,7 +419,7 @@ func unauthorized(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) HandleFunc(path string, f auth.AuthenticatedHandlerFunc) {
-	s.Router.HandleFunc(path, s.Auth.Wrap(f))
+	s.Router.Handle(path, withRequestID(withRequestTimeout(withAuthorization(wrapAuthentication(s.Auth, f)))))
 }
 
 func NewServer(s string, a string, p int, auth AuthenticationBackend) *Server {
@@ -205,15 +428,19 @@ func NewServer(s string, a string, p int, auth AuthenticationBackend) *Server {
 	router.PathPrefix("/statics").HandlerFunc(serveStatics)
 
 	server := &Server{
-		Service: s,
-		Router:  router,
-		Addr:    a,
-		Port:    p,
-		Auth:    auth,
+		Service:     s,
+		Router:      router,
+		AdminRouter: mux.NewRouter().StrictSlash(true),
+		Addr:        a,
+		Port:        p,
+		Auth:        auth,
+
+		ClientVersions: NewClientVersions(24 * time.Hour),
 	}
 
 	router.HandleFunc("/login", server.serveLogin)
 	router.HandleFunc("/", auth.Wrap(server.serveIndex))
+	server.AdminRouter.HandleFunc("/login", server.serveLogin)
 
 	return server
 }
@@ -229,5 +456,14 @@ func NewServerFromConfig(s string) (*Server, error) {
 		return nil, errors.New("Configuration error: " + err.Error())
 	}
 
-	return NewServer(s, addr, port, auth), nil
+	server := NewServer(s, addr, port, auth)
+	server.ClientVersions = NewClientVersions(time.Duration(config.GetConfig().GetInt("client_versions_window")) * time.Second)
+
+	if config.GetConfig().GetString(s+".admin_listen") != "" {
+		if server.AdminAddr, server.AdminPort, err = config.GetHostPortAttributes(s, "admin_listen"); err != nil {
+			return nil, errors.New("Configuration error: " + err.Error())
+		}
+	}
+
+	return server, nil
 }
@@ -0,0 +1,287 @@
+/*
+ * Copyright (C) 2015 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package http
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/abbot/go-http-auth"
+)
+
+func freePort(t *testing.T) int {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer l.Close()
+
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func getStatus(t *testing.T, port int, path string) int {
+	resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d%s", port, path))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	resp.Body.Close()
+
+	return resp.StatusCode
+}
+
+func TestServerAdminRoutes(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	server := NewServer("analyzer", "127.0.0.1", freePort(t), NewNoAuthenticationBackend())
+	server.AdminAddr, server.AdminPort = "127.0.0.1", freePort(t)
+	server.RegisterRoutes([]Route{{"Public", "GET", "/api/public", handler}})
+	server.RegisterAdminRoutes([]Route{{"Admin", "GET", "/api/admin/backup", handler}})
+
+	if err := server.Listen(); err != nil {
+		t.Fatal(err.Error())
+	}
+	go server.Serve()
+	defer server.Stop()
+
+	if code := getStatus(t, server.Port, "/api/public"); code != http.StatusOK {
+		t.Errorf("Public route not served on the listen address: %d", code)
+	}
+	if code := getStatus(t, server.Port, "/api/admin/backup"); code != http.StatusNotFound {
+		t.Errorf("Admin route served on the listen address: %d", code)
+	}
+	if code := getStatus(t, server.AdminPort, "/api/admin/backup"); code != http.StatusOK {
+		t.Errorf("Admin route not served on the admin address: %d", code)
+	}
+	if code := getStatus(t, server.AdminPort, "/api/public"); code != http.StatusNotFound {
+		t.Errorf("Public route served on the admin address: %d", code)
+	}
+}
+
+func TestServerRequireCredentials(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	required := &AuthenticationOpts{Username: "flows", Password: "password", Token: "secret"}
+	server := NewServer("analyzer", "127.0.0.1", freePort(t), NewNoAuthenticationBackend())
+	server.RegisterRoutes([]Route{{"Flows", "GET", "/api/flows", RequireCredentials(required, handler)}})
+
+	if err := server.Listen(); err != nil {
+		t.Fatal(err.Error())
+	}
+	go server.Serve()
+	defer server.Stop()
+
+	for _, test := range []struct {
+		opts     AuthenticationOpts
+		expected int
+	}{
+		{AuthenticationOpts{Token: "secret"}, http.StatusOK},
+		{AuthenticationOpts{Username: "flows", Password: "password"}, http.StatusOK},
+		{AuthenticationOpts{Token: "wrong"}, http.StatusUnauthorized},
+		{AuthenticationOpts{Username: "flows", Password: "wrong"}, http.StatusUnauthorized},
+		{AuthenticationOpts{}, http.StatusUnauthorized},
+	} {
+		client := NewRestClient("127.0.0.1", server.Port, &test.opts)
+		resp, err := client.Request("GET", "api/flows", nil)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != test.expected {
+			t.Errorf("Expected status %d for %+v, got %d", test.expected, test.opts, resp.StatusCode)
+		}
+	}
+}
+
+func TestServerRequireCredentialsBasicAuth(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	f, err := ioutil.TempFile("", "skydive-htpasswd")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.WriteString("admin:$apr1$/tk0tCNm$fBaXEudF9OTyFUhuqoIwp/"); err != nil {
+		t.Fatal(err.Error())
+	}
+	f.Close()
+
+	backend, err := NewBasicAuthenticationBackend(f.Name())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	required := &AuthenticationOpts{Token: "secret"}
+	server := NewServer("analyzer", "127.0.0.1", freePort(t), backend)
+	server.RegisterRoutes([]Route{{"Flows", "GET", "/api/flows", RequireCredentials(required, handler)}})
+
+	if err := server.Listen(); err != nil {
+		t.Fatal(err.Error())
+	}
+	go server.Serve()
+	defer server.Stop()
+
+	// the authtok cookie authenticates the user, the Authorization header
+	// giving the token required by the route
+	authtok := base64.StdEncoding.EncodeToString([]byte("admin:password"))
+	for _, test := range []struct {
+		token    string
+		expected int
+	}{
+		{"secret", http.StatusOK},
+		{"wrong", http.StatusUnauthorized},
+	} {
+		req, err := http.NewRequest("GET", fmt.Sprintf("http://127.0.0.1:%d/api/flows", server.Port), nil)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		req.AddCookie(&http.Cookie{Name: "authtok", Value: authtok})
+		req.Header.Set("Authorization", "Bearer "+test.token)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != test.expected {
+			t.Errorf("Expected status %d for the token %s, got %d", test.expected, test.token, resp.StatusCode)
+		}
+	}
+}
+
+// newDrainTestServer returns a server whose /api/slow requests are answered
+// once released, started being notified of each of them
+func newDrainTestServer(t *testing.T) (server *Server, started chan bool, release chan bool) {
+	started, release = make(chan bool, 1), make(chan bool)
+	handler := func(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
+		if r.URL.Path == "/api/slow" {
+			started <- true
+			<-release
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+
+	server = NewServer("analyzer", "127.0.0.1", freePort(t), NewNoAuthenticationBackend())
+	server.RegisterRoutes([]Route{
+		{"Slow", "GET", "/api/slow", handler},
+		{"Fast", "GET", "/api/fast", handler},
+	})
+
+	if err := server.Listen(); err != nil {
+		t.Fatal(err.Error())
+	}
+	go server.Serve()
+
+	return server, started, release
+}
+
+func getSlow(port int) chan int {
+	codes := make(chan int, 1)
+	go func() {
+		resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/api/slow", port))
+		if err != nil {
+			codes <- 0
+			return
+		}
+		resp.Body.Close()
+		codes <- resp.StatusCode
+	}()
+	return codes
+}
+
+func TestServerDrain(t *testing.T) {
+	server, started, release := newDrainTestServer(t)
+
+	// a keep-alive connection left idle after its request
+	idle, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", server.Port))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer idle.Close()
+
+	idle.Write([]byte("GET /api/fast HTTP/1.1\r\nHost: 127.0.0.1\r\n\r\n"))
+	resp, err := http.ReadResponse(bufio.NewReader(idle), nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	resp.Body.Close()
+
+	codes := getSlow(server.Port)
+	<-started
+
+	drained := make(chan error, 1)
+	go func() {
+		drained <- server.Drain(5 * time.Second)
+	}()
+
+	idle.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := idle.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("Expected the idle connection to be closed, got %v", err)
+	}
+
+	select {
+	case err := <-drained:
+		t.Fatalf("Expected the drain to wait for the request in progress, got %v", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	close(release)
+	if code := <-codes; code != http.StatusOK {
+		t.Errorf("Expected the request in progress to complete, got %d", code)
+	}
+	if err := <-drained; err != nil {
+		t.Errorf("Expected the server to be drained, got %s", err.Error())
+	}
+
+	if _, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/api/fast", server.Port)); err == nil {
+		t.Error("Expected a drained server not to accept connections")
+	}
+}
+
+func TestServerDrainTimeout(t *testing.T) {
+	server, started, release := newDrainTestServer(t)
+	defer close(release)
+
+	getSlow(server.Port)
+	<-started
+
+	if err := server.Drain(200 * time.Millisecond); err == nil {
+		t.Error("Expected the drain to time out with a request in progress")
+	}
+}
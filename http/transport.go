@@ -0,0 +1,217 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package http
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/redhat-cip/skydive/config"
+)
+
+// TransportOpts describes how to reach a class of destinations (etcd,
+// elasticsearch, keystone...). The proxy applies to all the requests but
+// the ones to the hosts matching NoProxy, the CA bundle being used in
+// addition to the system roots.
+type TransportOpts struct {
+	Proxy    string
+	NoProxy  string
+	CAFile   string
+	CertFile string
+	KeyFile  string
+}
+
+// Transport is the transport used by the outbound clients, naming the proxy
+// in the errors of the requests that went through one.
+type Transport struct {
+	*http.Transport
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.Transport.RoundTrip(req)
+	if err != nil && t.Proxy != nil {
+		if proxy, _ := t.Proxy(req); proxy != nil {
+			return nil, fmt.Errorf("%s (through proxy %s)", err.Error(), proxy.Host)
+		}
+	}
+	return resp, err
+}
+
+func matchNoProxy(host string, noProxy string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	ip := net.ParseIP(host)
+
+	for _, entry := range strings.Split(noProxy, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		switch {
+		case entry == "":
+		case entry == "*":
+			return true
+		case strings.Contains(entry, "/"):
+			if _, cidr, err := net.ParseCIDR(entry); err == nil && ip != nil && cidr.Contains(ip) {
+				return true
+			}
+		default:
+			if h, _, err := net.SplitHostPort(entry); err == nil {
+				entry = h
+			}
+			entry = strings.TrimPrefix(entry, ".")
+			if host == entry || strings.HasSuffix(host, "."+entry) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func proxyFunc(opts TransportOpts) (func(*http.Request) (*url.URL, error), error) {
+	// without explicit proxy the standard environment variables apply
+	if opts.Proxy == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+
+	proxy, err := url.Parse(opts.Proxy)
+	if err != nil || proxy.Host == "" {
+		// proxy given as host:port
+		if proxy, err = url.Parse("http://" + opts.Proxy); err != nil {
+			return nil, fmt.Errorf("invalid proxy %s: %s", opts.Proxy, err.Error())
+		}
+	}
+
+	noProxy := opts.NoProxy
+	if noProxy == "" {
+		noProxy = os.Getenv("NO_PROXY")
+		if noProxy == "" {
+			noProxy = os.Getenv("no_proxy")
+		}
+	}
+
+	return func(req *http.Request) (*url.URL, error) {
+		if matchNoProxy(req.URL.Host, noProxy) {
+			return nil, nil
+		}
+		return proxy, nil
+	}, nil
+}
+
+func tlsConfig(opts TransportOpts) (*tls.Config, error) {
+	if opts.CAFile == "" && opts.CertFile == "" {
+		return nil, nil
+	}
+
+	cfg := &tls.Config{}
+
+	if opts.CAFile != "" {
+		pem, err := ioutil.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the CA bundle %s: %s", opts.CAFile, err.Error())
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in the CA bundle %s", opts.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if opts.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load the client certificate %s: %s", opts.CertFile, err.Error())
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+func NewTransport(opts TransportOpts) (*Transport, error) {
+	proxy, err := proxyFunc(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig, err := tlsConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Transport{
+		Transport: &http.Transport{
+			Proxy: proxy,
+			Dial: (&net.Dialer{
+				Timeout:   30 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}).Dial,
+			TLSClientConfig:     tlsConfig,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+	}, nil
+}
+
+// TransportOptsFromConfig returns the options of a destination class, the
+// http.destinations.<class> keys overriding the global http ones.
+func TransportOptsFromConfig(class string) TransportOpts {
+	get := func(key string) string {
+		if v := config.GetConfig().GetString("http.destinations." + class + "." + key); v != "" {
+			return v
+		}
+		return config.GetConfig().GetString("http." + key)
+	}
+
+	return TransportOpts{
+		Proxy:    get("proxy"),
+		NoProxy:  get("no_proxy"),
+		CAFile:   get("ca_file"),
+		CertFile: get("cert_file"),
+		KeyFile:  get("key_file"),
+	}
+}
+
+func NewTransportFromConfig(class string) (*Transport, error) {
+	return NewTransport(TransportOptsFromConfig(class))
+}
+
+func NewClientFromConfig(class string) (*http.Client, error) {
+	transport, err := NewTransportFromConfig(class)
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Client{Transport: transport}, nil
+}
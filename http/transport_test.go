@@ -0,0 +1,255 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package http
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redhat-cip/skydive/config"
+)
+
+// newProxy returns a proxy answering itself the requests it receives
+func newProxy() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("proxied " + r.URL.String()))
+	}))
+}
+
+func get(t *testing.T, opts TransportOpts, url string) (string, error) {
+	transport, err := NewTransport(opts)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer transport.CloseIdleConnections()
+
+	resp, err := (&http.Client{Transport: transport}).Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	return string(body), nil
+}
+
+func TestTransportProxy(t *testing.T) {
+	proxy := newProxy()
+	defer proxy.Close()
+
+	body, err := get(t, TransportOpts{Proxy: proxy.URL}, "http://elasticsearch.example.com:9200/skydive")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if body != "proxied http://elasticsearch.example.com:9200/skydive" {
+		t.Errorf("Request not sent through the proxy: %s", body)
+	}
+}
+
+func TestTransportNoProxy(t *testing.T) {
+	proxy := newProxy()
+	defer proxy.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("direct"))
+	}))
+	defer server.Close()
+
+	body, err := get(t, TransportOpts{Proxy: proxy.URL, NoProxy: "example.com, 127.0.0.0/8"}, server.URL)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if body != "direct" {
+		t.Errorf("Request unexpectedly sent through the proxy: %s", body)
+	}
+}
+
+func TestTransportProxyError(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	_, err = get(t, TransportOpts{Proxy: "http://" + addr}, "http://etcd.example.com:2379/v2/keys")
+	if err == nil || !strings.Contains(err.Error(), "through proxy "+addr) {
+		t.Errorf("Expected an error naming the proxy %s, got %v", addr, err)
+	}
+}
+
+func TestMatchNoProxy(t *testing.T) {
+	for _, test := range []struct {
+		host    string
+		noProxy string
+		match   bool
+	}{
+		{"es.example.com:9200", "example.com", true},
+		{"es.example.com", ".example.com", true},
+		{"example.com", "example.com", true},
+		{"badexample.com", "example.com", false},
+		{"10.0.0.1:2379", "10.0.0.0/8", true},
+		{"192.168.0.1", "10.0.0.0/8", false},
+		{"keystone", "keystone:5000", true},
+		{"keystone", "*", true},
+		{"keystone", "", false},
+	} {
+		if m := matchNoProxy(test.host, test.noProxy); m != test.match {
+			t.Errorf("%s with no_proxy %q: expected %v, got %v", test.host, test.noProxy, test.match, m)
+		}
+	}
+}
+
+// writeCertificate writes a self-signed certificate for 127.0.0.1, used as
+// CA, server and client certificate
+func writeCertificate(t *testing.T, dir string) (string, string, tls.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "skydive test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	if err := ioutil.WriteFile(certFile, certPem, 0600); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := ioutil.WriteFile(keyFile, keyPem, 0600); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	cert, err := tls.X509KeyPair(certPem, keyPem)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	return certFile, keyFile, cert
+}
+
+func TestTransportPrivateCA(t *testing.T) {
+	dir, err := ioutil.TempDir("", "skydive-transport")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	certFile, keyFile, cert := writeCertificate(t, dir)
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("secured"))
+	}))
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	if _, err := get(t, TransportOpts{}, server.URL); err == nil {
+		t.Error("The private CA shouldn't be trusted by default")
+	}
+
+	if _, err := get(t, TransportOpts{CAFile: certFile}, server.URL); err == nil {
+		t.Error("The server requires a client certificate")
+	}
+
+	body, err := get(t, TransportOpts{CAFile: certFile, CertFile: certFile, KeyFile: keyFile}, server.URL)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if body != "secured" {
+		t.Errorf("Unexpected response: %s", body)
+	}
+
+	if _, err := NewTransport(TransportOpts{CAFile: filepath.Join(dir, "missing.pem")}); err == nil {
+		t.Error("Expected an error for a missing CA bundle")
+	}
+}
+
+func TestTransportOptsFromConfig(t *testing.T) {
+	config.GetConfig().Set("http.proxy", "proxy.example.com:3128")
+	config.GetConfig().Set("http.ca_file", "/etc/skydive/ca.pem")
+	config.GetConfig().Set("http.destinations.elasticsearch.proxy", "es-proxy.example.com:3128")
+	defer func() {
+		config.GetConfig().Set("http.proxy", "")
+		config.GetConfig().Set("http.ca_file", "")
+		config.GetConfig().Set("http.destinations.elasticsearch.proxy", "")
+	}()
+
+	if opts := TransportOptsFromConfig("etcd"); opts.Proxy != "proxy.example.com:3128" || opts.CAFile != "/etc/skydive/ca.pem" {
+		t.Errorf("Expected the global options, got %+v", opts)
+	}
+
+	if opts := TransportOptsFromConfig("elasticsearch"); opts.Proxy != "es-proxy.example.com:3128" || opts.CAFile != "/etc/skydive/ca.pem" {
+		t.Errorf("Expected the elasticsearch proxy override, got %+v", opts)
+	}
+
+	transport, err := NewTransportFromConfig("etcd")
+	if err == nil {
+		t.Errorf("Expected an error for the missing CA bundle, got %+v", transport)
+	}
+}
@@ -0,0 +1,48 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package http
+
+import (
+	"net/http"
+
+	"github.com/abbot/go-http-auth"
+	gcontext "github.com/gorilla/context"
+)
+
+// wrapAuthentication wraps the handler with the authentication backend. The
+// values attached to the request with gorilla/context, as the variables of
+// the path set by the router, are indexed by the request pointer, so they
+// are attached back to the copy of the request the backend hands to the
+// handler.
+func wrapAuthentication(backend AuthenticationBackend, handler auth.AuthenticatedHandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		values := gcontext.GetAll(r)
+		backend.Wrap(func(w http.ResponseWriter, ar *auth.AuthenticatedRequest) {
+			for k, v := range values {
+				gcontext.Set(&ar.Request, k, v)
+			}
+			defer gcontext.Clear(&ar.Request)
+			handler(w, ar)
+		}).ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,59 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/abbot/go-http-auth"
+	"github.com/gorilla/mux"
+)
+
+func TestServerRouteVars(t *testing.T) {
+	var layer string
+	handler := func(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
+		layer = mux.Vars(&r.Request)["layer"]
+		w.WriteHeader(http.StatusOK)
+	}
+
+	server := NewServer("analyzer", "127.0.0.1", 0, NewNoAuthenticationBackend())
+	server.RegisterRoutes([]Route{{"Layer", "GET", "/api/layer/{layer}", handler}})
+
+	ts := httptest.NewServer(server.Router)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/api/layer/ipv4")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Route not served: %d", resp.StatusCode)
+	}
+	if layer != "ipv4" {
+		t.Errorf("Expected the path variable to reach the handler, got %q", layer)
+	}
+}
@@ -0,0 +1,222 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/abbot/go-http-auth"
+
+	"github.com/redhat-cip/skydive/logging"
+	"github.com/redhat-cip/skydive/version"
+)
+
+// ClientVersionHeader carries the API version of the client of a request
+const ClientVersionHeader = "X-Skydive-Api-Version"
+
+// APIVersionInfo is returned by /api/version
+type APIVersionInfo struct {
+	Version             string
+	APIVersion          string
+	MinClientAPIVersion string
+}
+
+// ParseAPIVersion returns the major and minor of a major.minor version
+func ParseAPIVersion(v string) (int, int, error) {
+	parts := strings.SplitN(v, ".", 2)
+
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("Invalid API version %s", v)
+	}
+
+	minor := 0
+	if len(parts) == 2 {
+		if minor, err = strconv.Atoi(parts[1]); err != nil {
+			return 0, 0, fmt.Errorf("Invalid API version %s", v)
+		}
+	}
+
+	return major, minor, nil
+}
+
+func olderAPIVersion(v1 string, v2 string) bool {
+	major1, minor1, _ := ParseAPIVersion(v1)
+	major2, minor2, _ := ParseAPIVersion(v2)
+	return major1 < major2 || (major1 == major2 && minor1 < minor2)
+}
+
+// APIMajor returns the major of the API version served
+func APIMajor() int {
+	major, _, _ := ParseAPIVersion(version.APIVersion)
+	return major
+}
+
+// ClientAPIMajor returns the API major of the client of the request, the
+// current one for the clients not giving their version such as the WebUI
+func ClientAPIMajor(r *http.Request) int {
+	major, _, err := ParseAPIVersion(r.Header.Get(ClientVersionHeader))
+	if err != nil {
+		return APIMajor()
+	}
+	return major
+}
+
+// ClientVersionStats reports the requests of the clients of an API version
+type ClientVersionStats struct {
+	Version  string
+	Requests int64
+	LastSeen int64
+}
+
+// ClientVersions records the API versions of the clients, the versions not
+// seen for window being forgotten
+type ClientVersions struct {
+	sync.Mutex
+	window   time.Duration
+	versions map[string]*ClientVersionStats
+}
+
+func (c *ClientVersions) record(v string, now time.Time) {
+	c.Lock()
+	defer c.Unlock()
+
+	stats, ok := c.versions[v]
+	if !ok {
+		logging.GetLogger().Infof("Client of API version %s seen", v)
+
+		stats = &ClientVersionStats{Version: v}
+		c.versions[v] = stats
+	}
+	stats.Requests++
+	stats.LastSeen = now.Unix()
+}
+
+type sortByVersion []ClientVersionStats
+
+func (s sortByVersion) Len() int {
+	return len(s)
+}
+
+func (s sortByVersion) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s sortByVersion) Less(i, j int) bool {
+	return s[i].Version < s[j].Version
+}
+
+// Stats returns the versions seen during the window, sorted by version
+func (c *ClientVersions) Stats() []ClientVersionStats {
+	c.Lock()
+	defer c.Unlock()
+
+	stats := []ClientVersionStats{}
+	for v, s := range c.versions {
+		if time.Since(time.Unix(s.LastSeen, 0)) > c.window {
+			delete(c.versions, v)
+			continue
+		}
+		stats = append(stats, *s)
+	}
+
+	sort.Sort(sortByVersion(stats))
+
+	return stats
+}
+
+func NewClientVersions(window time.Duration) *ClientVersions {
+	return &ClientVersions{
+		window:   window,
+		versions: make(map[string]*ClientVersionStats),
+	}
+}
+
+// checkClientVersion records the API version of the clients, the ones
+// older than version.MinClientAPIVersion being refused. The requests not
+// giving a version are accounted as unknown.
+func (s *Server) checkClientVersion(handler auth.AuthenticatedHandlerFunc) auth.AuthenticatedHandlerFunc {
+	return func(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
+		v := r.Header.Get(ClientVersionHeader)
+		if v == "" {
+			s.ClientVersions.record("unknown", time.Now())
+			handler(w, r)
+			return
+		}
+
+		s.ClientVersions.record(v, time.Now())
+
+		if _, _, err := ParseAPIVersion(v); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		// the old clients can still learn they have to be upgraded
+		if olderAPIVersion(v, version.MinClientAPIVersion) && r.URL.Path != "/api/version" {
+			w.WriteHeader(http.StatusUpgradeRequired)
+			fmt.Fprintf(w, "Client API version %s not supported by the analyzer API version %s, clients from API version %s are supported",
+				v, version.APIVersion, version.MinClientAPIVersion)
+			return
+		}
+
+		handler(w, r)
+	}
+}
+
+// checkAPIVersion compares the API version of the client to the one of the
+// analyzer, returning an error when the analyzer can't serve the client and
+// a warning on a skew
+func checkAPIVersion(info APIVersionInfo, client string) (string, error) {
+	serverMajor, serverMinor, err := ParseAPIVersion(info.APIVersion)
+	if err != nil {
+		return "", err
+	}
+	clientMajor, clientMinor, err := ParseAPIVersion(client)
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case info.MinClientAPIVersion != "" && olderAPIVersion(client, info.MinClientAPIVersion):
+		return "", fmt.Errorf("The client API version %s is too old for the analyzer %s (API version %s, clients from %s), please upgrade the client",
+			client, info.Version, info.APIVersion, info.MinClientAPIVersion)
+	case clientMajor > serverMajor:
+		return "", fmt.Errorf("The client API version %s is too recent for the analyzer %s (API version %s), please upgrade the analyzer",
+			client, info.Version, info.APIVersion)
+	case clientMajor < serverMajor:
+		return fmt.Sprintf("The analyzer %s (API version %s) serves the client API version %s in compatibility mode, please upgrade the client",
+			info.Version, info.APIVersion, client), nil
+	case clientMinor != serverMinor:
+		return fmt.Sprintf("The client API version %s differs from the analyzer %s one (API version %s)",
+			client, info.Version, info.APIVersion), nil
+	}
+
+	return "", nil
+}
@@ -0,0 +1,162 @@
+/*
+ * Copyright (C) 2015 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/abbot/go-http-auth"
+
+	"github.com/redhat-cip/skydive/version"
+)
+
+func TestCheckAPIVersion(t *testing.T) {
+	info := APIVersionInfo{Version: "v0.4.0", APIVersion: "2.1", MinClientAPIVersion: "1.2"}
+
+	tests := []struct {
+		client  string
+		warning bool
+		err     bool
+	}{
+		{"2.1", false, false},
+		{"2.0", true, false},
+		{"2.3", true, false},
+		{"1.2", true, false},
+		{"1.1", false, true},
+		{"0.9", false, true},
+		{"3.0", false, true},
+	}
+
+	for _, test := range tests {
+		warning, err := checkAPIVersion(info, test.client)
+		if (warning != "") != test.warning || (err != nil) != test.err {
+			t.Errorf("%s: unexpected warning %q, error %v", test.client, warning, err)
+		}
+	}
+}
+
+func getWithVersion(t *testing.T, port int, path string, v string) *http.Response {
+	req, _ := http.NewRequest("GET", fmt.Sprintf("http://127.0.0.1:%d%s", port, path), nil)
+	if v != "" {
+		req.Header.Set(ClientVersionHeader, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return resp
+}
+
+func newVersionTestServer(t *testing.T, info APIVersionInfo) *Server {
+	handler := func(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
+		w.Write([]byte("[]"))
+	}
+	versionHandler := func(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
+		json.NewEncoder(w).Encode(info)
+	}
+
+	server := NewServer("analyzer", "127.0.0.1", freePort(t), NewNoAuthenticationBackend())
+	server.RegisterRoutes([]Route{
+		{"Capture", "GET", "/api/capture", handler},
+		{"Version", "GET", "/api/version", versionHandler},
+	})
+
+	if err := server.Listen(); err != nil {
+		t.Fatal(err.Error())
+	}
+	go server.Serve()
+
+	return server
+}
+
+func TestServerClientVersion(t *testing.T) {
+	server := newVersionTestServer(t, APIVersionInfo{})
+	defer server.Stop()
+
+	// a client of the previous major is served, an older one refused but
+	// still told its version isn't supported
+	for _, test := range []struct {
+		path    string
+		version string
+		code    int
+	}{
+		{"/api/capture", version.APIVersion, http.StatusOK},
+		{"/api/capture", version.MinClientAPIVersion, http.StatusOK},
+		{"/api/capture", "", http.StatusOK},
+		{"/api/capture", "0.9", http.StatusUpgradeRequired},
+		{"/api/version", "0.9", http.StatusOK},
+		{"/api/capture", "latest", http.StatusBadRequest},
+	} {
+		resp := getWithVersion(t, server.Port, test.path, test.version)
+		resp.Body.Close()
+		if resp.StatusCode != test.code {
+			t.Errorf("%s with client version %q: expected %d, got %d", test.path, test.version, test.code, resp.StatusCode)
+		}
+	}
+
+	requests := make(map[string]int64)
+	for _, stats := range server.ClientVersions.Stats() {
+		requests[stats.Version] = stats.Requests
+	}
+	if requests["0.9"] != 2 || requests[version.MinClientAPIVersion] != 1 || requests["unknown"] != 1 {
+		t.Errorf("Wrong client versions: %v", requests)
+	}
+}
+
+func TestClientVersionCheck(t *testing.T) {
+	server := newVersionTestServer(t, APIVersionInfo{Version: "v9.0.0", APIVersion: "9.0", MinClientAPIVersion: "9.0"})
+
+	client := NewCrudClient("127.0.0.1", server.Port, &AuthenticationOpts{}, "api")
+
+	var captures []interface{}
+	err := client.List("capture", &captures)
+	if err == nil || !strings.Contains(err.Error(), "please upgrade the client") {
+		t.Fatalf("Expected the client to be refused, got %v", err)
+	}
+
+	// the check is done once per analyzer
+	server.Stop()
+	if err := client.List("capture", &captures); err == nil || !strings.Contains(err.Error(), "please upgrade the client") {
+		t.Errorf("Expected the result of the check to be cached, got %v", err)
+	}
+
+	current := newVersionTestServer(t, APIVersionInfo{Version: version.Version, APIVersion: version.APIVersion})
+	defer current.Stop()
+
+	client = NewCrudClient("127.0.0.1", current.Port, &AuthenticationOpts{}, "api")
+	if err := client.List("capture", &captures); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	requests := make(map[string]int64)
+	for _, stats := range current.ClientVersions.Stats() {
+		requests[stats.Version] = stats.Requests
+	}
+	if requests[version.APIVersion] != 2 {
+		t.Errorf("Expected the version check and the request to carry the client version: %v", requests)
+	}
+}
@@ -39,6 +39,11 @@ import (
 	"github.com/redhat-cip/skydive/logging"
 )
 
+const (
+	reconnectDelay    = 1 * time.Second
+	maxReconnectDelay = 32 * time.Second
+)
+
 type WSClientEventHandler interface {
 	OnMessage(m WSMessage)
 	OnConnected()
@@ -116,13 +121,14 @@ func (c *WSAsyncClient) sendHello() {
 	c.sendMessage(m.String())
 }
 
-func (c *WSAsyncClient) connect() {
+// connect returns the close code sent by the server if any
+func (c *WSAsyncClient) connect() int {
 	host := c.Addr + ":" + strconv.FormatInt(int64(c.Port), 10)
 
 	conn, err := net.Dial("tcp", host)
 	if err != nil {
 		logging.GetLogger().Errorf("Connection to the WebSocket server failed: %s", err.Error())
-		return
+		return 0
 	}
 
 	endpoint := "ws://" + host + c.Path
@@ -130,7 +136,7 @@ func (c *WSAsyncClient) connect() {
 	if err != nil {
 		logging.GetLogger().Errorf("Unable to parse the WebSocket Endpoint %s: %s", endpoint, err.Error())
 		conn.Close()
-		return
+		return 0
 	}
 
 	headers := http.Header{"Origin": {endpoint}}
@@ -138,7 +144,7 @@ func (c *WSAsyncClient) connect() {
 		if err := c.AuthClient.Authenticate(); err != nil {
 			logging.GetLogger().Errorf("Unable to create a WebSocket connection %s : %s", endpoint, err.Error())
 			conn.Close()
-			return
+			return 0
 		}
 		c.AuthClient.SetHeaders(headers)
 	}
@@ -147,7 +153,7 @@ func (c *WSAsyncClient) connect() {
 	if err != nil {
 		logging.GetLogger().Errorf("Unable to create a WebSocket connection %s : %s", endpoint, err.Error())
 		conn.Close()
-		return
+		return 0
 	}
 	defer c.wsConn.Close()
 	c.wsConn.SetPingHandler(nil)
@@ -165,10 +171,14 @@ func (c *WSAsyncClient) connect() {
 		l.OnConnected()
 	}
 
+	var closeCode int32
 	go func() {
 		for c.running.Load() == true {
 			_, m, err := c.wsConn.ReadMessage()
 			if err != nil {
+				if e, ok := err.(*websocket.CloseError); ok {
+					atomic.StoreInt32(&closeCode, int32(e.Code))
+				}
 				break
 			}
 
@@ -194,15 +204,18 @@ func (c *WSAsyncClient) connect() {
 				}
 			}
 		case <-c.quit:
-			return
+			return int(atomic.LoadInt32(&closeCode))
 		}
 	}
+
+	return 0
 }
 
 func (c *WSAsyncClient) Connect() {
 	go func() {
+		delay := reconnectDelay
 		for c.running.Load() == true {
-			c.connect()
+			closeCode := c.connect()
 
 			wasConnected := c.connected.Load()
 			c.connected.Store(false)
@@ -213,8 +226,19 @@ func (c *WSAsyncClient) Connect() {
 				}
 			}
 
+			switch closeCode {
+			case WSCloseTooManyClients, WSCloseSlowConsumer, WSCloseIdle:
+				// the server is overloaded or considers us as too slow
+				if delay *= 2; delay > maxReconnectDelay {
+					delay = maxReconnectDelay
+				}
+				logging.GetLogger().Warningf("Disconnected by the WebSocket server (code %d), reconnecting in %s", closeCode, delay)
+			default:
+				delay = reconnectDelay
+			}
+
 			if c.running.Load() == true {
-				time.Sleep(1 * time.Second)
+				time.Sleep(delay)
 			}
 		}
 	}()
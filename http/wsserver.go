@@ -24,6 +24,7 @@ package http
 
 import (
 	"encoding/json"
+	"net"
 	"net/http"
 	"sync"
 	"sync/atomic"
@@ -40,14 +41,61 @@ const (
 	Namespace      = "WSServer"
 	writeWait      = 10 * time.Second
 	maxMessageSize = 1024 * 1024
+	maxEvictions   = 100
+	// time given to a client being evicted to receive the close message
+	closeWait = 1 * time.Second
+)
+
+// Close codes sent before closing a connection, clients receiving one of them
+// have to back off before reconnecting.
+const (
+	WSCloseTooManyClients = 4001
+	WSCloseSlowConsumer   = 4002
+	WSCloseIdle           = 4003
 )
 
 type WSClient struct {
-	conn   *websocket.Conn
-	read   chan []byte
-	send   chan []byte
-	server *WSServer
-	host   string
+	// first for the alignment of the atomic operations, time since when the
+	// send queue is full, 0 if not full
+	saturatedSince int64
+	// messages dropped as the send queue was full
+	dropped    int64
+	conn       *websocket.Conn
+	read       chan []byte
+	send       chan []byte
+	server     *WSServer
+	host       atomic.Value
+	remoteAddr string
+	since      time.Time
+	evicted    int32
+}
+
+type WSClientStatus struct {
+	Host        string
+	RemoteAddr  string
+	Since       time.Time
+	QueueLength int
+	Dropped     int64
+}
+
+// WSEviction records a closed or rejected connection
+type WSEviction struct {
+	Host       string `json:",omitempty"`
+	RemoteAddr string
+	Reason     string
+	Time       time.Time
+}
+
+type WSServerStatus struct {
+	Endpoint              string
+	MaxClients            int
+	Clients               []WSClientStatus
+	Rejected              int64
+	SlowConsumerEvictions int64
+	IdleEvictions         int64
+	// messages dropped as the send queue of their client was full
+	Dropped       int64
+	LastEvictions []WSEviction
 }
 
 type WSMessage struct {
@@ -67,18 +115,33 @@ type DefaultWSServerEventHandler struct {
 }
 
 type WSServer struct {
+	// first for the alignment of the atomic operations
+	rejected      int64
+	slowConsumers int64
+	idles         int64
+	dropped       int64
+	sync.RWMutex
 	DefaultWSServerEventHandler
-	Server        *Server
-	eventHandlers []WSServerEventHandler
-	clients       map[*WSClient]bool
-	broadcast     chan string
-	quit          chan bool
-	register      chan *WSClient
-	unregister    chan *WSClient
-	pongWait      time.Duration
-	pingPeriod    time.Duration
-	wg            sync.WaitGroup
-	listening     atomic.Value
+	Server              *Server
+	endpoint            string
+	eventHandlers       []WSServerEventHandler
+	clients             map[*WSClient]bool
+	broadcast           chan string
+	quit                chan bool
+	register            chan *WSClient
+	unregister          chan *WSClient
+	pongWait            time.Duration
+	pingPeriod          time.Duration
+	maxClients          int
+	queueSize           int
+	slowConsumerTimeout time.Duration
+	nbClients           int32
+	evictionsLock       sync.Mutex
+	evictions           []WSEviction
+	wg                  sync.WaitGroup
+	listening           atomic.Value
+	// credentials required from the clients, none if nil
+	Credentials *AuthenticationOpts
 }
 
 func (g WSMessage) Marshal() []byte {
@@ -108,8 +171,39 @@ func (d *DefaultWSServerEventHandler) OnRegisterClient(c *WSClient) {
 func (d *DefaultWSServerEventHandler) OnUnregisterClient(c *WSClient) {
 }
 
+func (c *WSClient) Host() string {
+	if host, ok := c.host.Load().(string); ok {
+		return host
+	}
+	return ""
+}
+
 func (c *WSClient) SendWSMessage(msg WSMessage) {
-	c.send <- []byte(msg.String())
+	c.queue([]byte(msg.String()))
+}
+
+// queue never blocks, the message is dropped and counted if the send queue
+// is full and the client is evicted if it stays saturated too long.
+func (c *WSClient) queue(m []byte) {
+	if atomic.LoadInt32(&c.evicted) == 1 {
+		return
+	}
+
+	select {
+	case c.send <- m:
+		atomic.StoreInt64(&c.saturatedSince, 0)
+	default:
+		atomic.AddInt64(&c.dropped, 1)
+		atomic.AddInt64(&c.server.dropped, 1)
+
+		now := time.Now().UnixNano()
+		since := atomic.LoadInt64(&c.saturatedSince)
+		if since == 0 {
+			atomic.CompareAndSwapInt64(&c.saturatedSince, 0, now)
+		} else if time.Duration(now-since) > c.server.slowConsumerTimeout {
+			c.server.evict(c, WSCloseSlowConsumer, "slow consumer")
+		}
+	}
 }
 
 func (c *WSClient) processMessage(m []byte) {
@@ -128,9 +222,9 @@ func (c *WSClient) processMessage(m []byte) {
 				logging.GetLogger().Errorf("WSServer: Unable to parse the event %s: %s", msg, err.Error())
 				return
 			}
-			c.host = host
+			c.host.Store(host)
 
-			logging.GetLogger().Infof("Hello received from WSClient: %s", c.host)
+			logging.GetLogger().Infof("Hello received from WSClient: %s", host)
 		}
 	} else {
 		for _, e := range c.server.eventHandlers {
@@ -171,6 +265,9 @@ func (c *WSClient) readPump() {
 	for {
 		_, m, err := c.conn.ReadMessage()
 		if err != nil {
+			if e, ok := err.(net.Error); ok && e.Timeout() {
+				c.server.evict(c, WSCloseIdle, "idle")
+			}
 			break
 		}
 
@@ -217,8 +314,11 @@ func (c *WSClient) write(mt int, message []byte) error {
 }
 
 func (s *WSServer) SendWSMessageTo(msg WSMessage, host string) bool {
+	s.RLock()
+	defer s.RUnlock()
+
 	for c := range s.clients {
-		if c.host == host {
+		if c.Host() == host {
 			c.SendWSMessage(msg)
 			return true
 		}
@@ -227,6 +327,83 @@ func (s *WSServer) SendWSMessageTo(msg WSMessage, host string) bool {
 	return false
 }
 
+func (s *WSServer) addEviction(c *WSClient, reason string) {
+	s.evictionsLock.Lock()
+	defer s.evictionsLock.Unlock()
+
+	s.evictions = append(s.evictions, WSEviction{
+		Host:       c.Host(),
+		RemoteAddr: c.remoteAddr,
+		Reason:     reason,
+		Time:       time.Now(),
+	})
+	if len(s.evictions) > maxEvictions {
+		s.evictions = s.evictions[len(s.evictions)-maxEvictions:]
+	}
+}
+
+// evict closes the connection with the given close code, the client will be
+// unregistered once its read pump notices the closed connection.
+func (s *WSServer) evict(c *WSClient, code int, reason string) {
+	if !atomic.CompareAndSwapInt32(&c.evicted, 0, 1) {
+		return
+	}
+
+	switch code {
+	case WSCloseSlowConsumer:
+		atomic.AddInt64(&s.slowConsumers, 1)
+	case WSCloseIdle:
+		atomic.AddInt64(&s.idles, 1)
+	}
+	s.addEviction(c, reason)
+
+	logging.GetLogger().Warningf("WebSocket client %s (%s) evicted: %s", c.Host(), c.remoteAddr, reason)
+
+	// the close message can take up to closeWait to be sent to a stalled
+	// client, so don't block the caller
+	go func() {
+		c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(closeWait))
+		c.conn.Close()
+	}()
+}
+
+// ClientCount returns the number of clients connected
+func (s *WSServer) ClientCount() int {
+	s.RLock()
+	defer s.RUnlock()
+	return len(s.clients)
+}
+
+func (s *WSServer) GetStatus() WSServerStatus {
+	status := WSServerStatus{
+		Endpoint:              s.endpoint,
+		MaxClients:            s.maxClients,
+		Clients:               []WSClientStatus{},
+		Rejected:              atomic.LoadInt64(&s.rejected),
+		SlowConsumerEvictions: atomic.LoadInt64(&s.slowConsumers),
+		IdleEvictions:         atomic.LoadInt64(&s.idles),
+		Dropped:               atomic.LoadInt64(&s.dropped),
+	}
+
+	s.RLock()
+	for c := range s.clients {
+		status.Clients = append(status.Clients, WSClientStatus{
+			Host:        c.Host(),
+			RemoteAddr:  c.remoteAddr,
+			Since:       c.since,
+			QueueLength: len(c.send),
+			Dropped:     atomic.LoadInt64(&c.dropped),
+		})
+	}
+	s.RUnlock()
+
+	s.evictionsLock.Lock()
+	status.LastEvictions = append([]WSEviction{}, s.evictions...)
+	s.evictionsLock.Unlock()
+
+	return status
+}
+
 func (s *WSServer) listenAndServe() {
 	quit := false
 
@@ -238,13 +415,17 @@ func (s *WSServer) listenAndServe() {
 			}
 
 			// close all the client so that they will call unregister
+			s.RLock()
 			for c := range s.clients {
 				c.conn.Close()
 			}
+			s.RUnlock()
 
 			quit = true
 		case c := <-s.register:
+			s.Lock()
 			s.clients[c] = true
+			s.Unlock()
 			for _, e := range s.eventHandlers {
 				e.OnRegisterClient(c)
 			}
@@ -252,7 +433,9 @@ func (s *WSServer) listenAndServe() {
 			for _, e := range s.eventHandlers {
 				e.OnUnregisterClient(c)
 			}
+			s.Lock()
 			delete(s.clients, c)
+			s.Unlock()
 
 			// if quit has been requested and there is no more clients then leave
 			if quit && len(s.clients) == 0 {
@@ -265,16 +448,20 @@ func (s *WSServer) listenAndServe() {
 }
 
 func (s *WSServer) broadcastMessage(m string) {
+	s.RLock()
+	defer s.RUnlock()
+
 	for c := range s.clients {
-		select {
-		case c.send <- []byte(m):
-		default:
-			delete(s.clients, c)
-		}
+		c.queue([]byte(m))
 	}
 }
 
 func (s *WSServer) serveMessages(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
+	if s.Credentials != nil && !HasCredentials(s.Credentials, &r.Request) {
+		ReplyUnauthorized(w)
+		return
+	}
+
 	var upgrader = websocket.Upgrader{
 		ReadBufferSize:  1024,
 		WriteBufferSize: 1024,
@@ -286,12 +473,28 @@ func (s *WSServer) serveMessages(w http.ResponseWriter, r *auth.AuthenticatedReq
 	}
 
 	c := &WSClient{
-		read:   make(chan []byte, maxMessageSize),
-		send:   make(chan []byte, maxMessageSize),
-		conn:   conn,
-		server: s,
+		read:       make(chan []byte, maxMessageSize),
+		send:       make(chan []byte, s.queueSize),
+		conn:       conn,
+		server:     s,
+		remoteAddr: conn.RemoteAddr().String(),
+		since:      time.Now(),
 	}
-	logging.GetLogger().Infof("New WebSocket Connection from %s : URI path %s", conn.RemoteAddr().String(), r.URL.Path)
+
+	if n := atomic.AddInt32(&s.nbClients, 1); s.maxClients > 0 && int(n) > s.maxClients {
+		atomic.AddInt32(&s.nbClients, -1)
+		atomic.AddInt64(&s.rejected, 1)
+		s.addEviction(c, "too many clients")
+
+		logging.GetLogger().Warningf("WebSocket connection from %s rejected, %d clients max", c.remoteAddr, s.maxClients)
+
+		conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(WSCloseTooManyClients, "too many clients"), time.Now().Add(closeWait))
+		conn.Close()
+		return
+	}
+	defer atomic.AddInt32(&s.nbClients, -1)
+
+	logging.GetLogger().Infof("New WebSocket Connection from %s : URI path %s", c.remoteAddr, r.URL.Path)
 
 	s.register <- c
 
@@ -308,8 +511,9 @@ func (s *WSServer) serveMessages(w http.ResponseWriter, r *auth.AuthenticatedReq
 	quit <- struct{}{}
 	quit <- struct{}{}
 
+	// the send queue is not closed as messages can still be queued from
+	// other goroutines, it will be garbage collected
 	close(c.read)
-	close(c.send)
 
 	wg.Wait()
 }
@@ -338,16 +542,23 @@ func (s *WSServer) AddEventHandler(h WSServerEventHandler) {
 	s.eventHandlers = append(s.eventHandlers, h)
 }
 
-func NewWSServer(server *Server, pongWait time.Duration, endpoint string) *WSServer {
+// NewWSServer creates a server accepting at most maxClients connections,
+// 0 meaning no limit. Clients keeping their queue of queueSize messages full
+// longer than slowConsumerTimeout are evicted.
+func NewWSServer(server *Server, pongWait time.Duration, maxClients int, queueSize int, slowConsumerTimeout time.Duration, endpoint string) *WSServer {
 	s := &WSServer{
-		Server:     server,
-		broadcast:  make(chan string, 500),
-		quit:       make(chan bool, 1),
-		register:   make(chan *WSClient),
-		unregister: make(chan *WSClient),
-		clients:    make(map[*WSClient]bool),
-		pongWait:   pongWait,
-		pingPeriod: (pongWait * 8) / 10,
+		Server:              server,
+		endpoint:            endpoint,
+		broadcast:           make(chan string, 500),
+		quit:                make(chan bool, 1),
+		register:            make(chan *WSClient),
+		unregister:          make(chan *WSClient),
+		clients:             make(map[*WSClient]bool),
+		pongWait:            pongWait,
+		pingPeriod:          (pongWait * 8) / 10,
+		maxClients:          maxClients,
+		queueSize:           queueSize,
+		slowConsumerTimeout: slowConsumerTimeout,
 	}
 
 	server.HandleFunc(endpoint, s.serveMessages)
@@ -357,6 +568,9 @@ func NewWSServer(server *Server, pongWait time.Duration, endpoint string) *WSSer
 
 func NewWSServerFromConfig(server *Server, endpoint string) *WSServer {
 	w := config.GetConfig().GetInt("ws_pong_timeout")
+	m := config.GetConfig().GetInt("ws_max_clients")
+	q := config.GetConfig().GetInt("ws_queue_size")
+	t := config.GetConfig().GetInt("ws_slow_consumer_timeout")
 
-	return NewWSServer(server, time.Duration(w)*time.Second, endpoint)
+	return NewWSServer(server, time.Duration(w)*time.Second, m, q, time.Duration(t)*time.Second, endpoint)
 }
@@ -0,0 +1,193 @@
+/*
+ * Copyright (C) 2015 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func newTestWSServer(t *testing.T, maxClients int, queueSize int, slowConsumerTimeout time.Duration) (*WSServer, *httptest.Server) {
+	server := NewServer("test", "127.0.0.1", 0, NewNoAuthenticationBackend())
+	ws := NewWSServer(server, time.Minute, maxClients, queueSize, slowConsumerTimeout, "/ws")
+	go ws.ListenAndServe()
+
+	return ws, httptest.NewServer(server.Router)
+}
+
+func dialTestWSServer(t *testing.T, ts *httptest.Server) *websocket.Conn {
+	conn, _, err := websocket.DefaultDialer.Dial(strings.Replace(ts.URL, "http", "ws", 1)+"/ws", nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return conn
+}
+
+func waitForWSClients(t *testing.T, ws *WSServer, n int) {
+	for i := 0; i < 100; i++ {
+		if len(ws.GetStatus().Clients) == n {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("Expected %d clients, got %d", n, len(ws.GetStatus().Clients))
+}
+
+func TestWSServerMaxClients(t *testing.T) {
+	ws, ts := newTestWSServer(t, 1, 100, time.Second)
+	defer ts.Close()
+	defer ws.Stop()
+
+	conn := dialTestWSServer(t, ts)
+	defer conn.Close()
+	waitForWSClients(t, ws, 1)
+
+	rejected := dialTestWSServer(t, ts)
+	defer rejected.Close()
+
+	_, _, err := rejected.ReadMessage()
+	if e, ok := err.(*websocket.CloseError); !ok || e.Code != WSCloseTooManyClients {
+		t.Fatalf("Expected a too many clients close error, got: %v", err)
+	}
+
+	status := ws.GetStatus()
+	if status.Rejected != 1 || len(status.Clients) != 1 || len(status.LastEvictions) != 1 {
+		t.Errorf("Wrong status: %+v", status)
+	}
+}
+
+func TestWSServerCredentials(t *testing.T) {
+	ws, ts := newTestWSServer(t, 0, 100, time.Second)
+	defer ts.Close()
+	defer ws.Stop()
+	ws.Credentials = &AuthenticationOpts{Token: "secret"}
+
+	url := strings.Replace(ts.URL, "http", "ws", 1) + "/ws"
+	_, resp, err := websocket.DefaultDialer.Dial(url, http.Header{"Authorization": {"Bearer wrong"}})
+	if err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("Expected the connection to be refused with a 401, got: %v", err)
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, http.Header{"Authorization": {"Bearer secret"}})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer conn.Close()
+	waitForWSClients(t, ws, 1)
+}
+
+// TestWSServerSlowConsumer checks that a stalled client is evicted and
+// doesn't slow down the broadcast to the other clients
+func TestWSServerSlowConsumer(t *testing.T) {
+	ws, ts := newTestWSServer(t, 0, 10, 200*time.Millisecond)
+	defer ts.Close()
+	defer ws.Stop()
+
+	// never reads anything so that the socket buffers and the queue get full
+	stalled := dialTestWSServer(t, ts)
+	defer stalled.Close()
+
+	healthy := dialTestWSServer(t, ts)
+	defer healthy.Close()
+
+	waitForWSClients(t, ws, 2)
+
+	var lock sync.Mutex
+	var latencies []time.Duration
+
+	done := make(chan bool)
+	go func() {
+		for {
+			_, m, err := healthy.ReadMessage()
+			if err != nil {
+				close(done)
+				return
+			}
+
+			msg, _ := UnmarshalWSMessage(m)
+			var sent time.Time
+			json.Unmarshal([]byte(*msg.Obj), &sent)
+
+			lock.Lock()
+			latencies = append(latencies, time.Now().Sub(sent))
+			lock.Unlock()
+		}
+	}()
+
+	payload := strings.Repeat("x", 64*1024)
+	for i := 0; i < 300; i++ {
+		b, _ := json.Marshal(time.Now())
+		raw := json.RawMessage(b)
+		ws.BroadcastWSMessage(WSMessage{Namespace: "Test", Type: payload, Obj: &raw})
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	for i := 0; i < 100; i++ {
+		if ws.GetStatus().SlowConsumerEvictions == 1 {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	status := ws.GetStatus()
+	if status.SlowConsumerEvictions != 1 {
+		t.Fatalf("The stalled client should have been evicted: %+v", status)
+	}
+	if len(status.LastEvictions) != 1 || status.LastEvictions[0].Reason != "slow consumer" {
+		t.Errorf("Wrong evictions: %+v", status.LastEvictions)
+	}
+	if status.Dropped == 0 {
+		t.Errorf("The messages dropped for the stalled client should be counted: %+v", status)
+	}
+
+	waitForWSClients(t, ws, 1)
+
+	healthy.Close()
+	<-done
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	if len(latencies) < 290 {
+		t.Fatalf("The healthy client missed messages: %d received", len(latencies))
+	}
+
+	// compare the latency while the stalled client is connected with the
+	// one once it has been evicted
+	var max time.Duration
+	for _, l := range latencies {
+		if l > max {
+			max = l
+		}
+	}
+	if max > 500*time.Millisecond {
+		t.Errorf("Broadcast latency too high for the healthy client: %s", max)
+	}
+}
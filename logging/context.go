@@ -0,0 +1,111 @@
+/*
+ * Copyright (C) 2015 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package logging
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/op/go-logging"
+	"golang.org/x/net/context"
+)
+
+type contextKey struct{}
+
+// NewContext returns a copy of the context holding the field, the messages
+// of the loggers returned by GetContextLogger being prefixed with the
+// fields of their context
+func NewContext(ctx context.Context, key string, value string) context.Context {
+	fields := make(map[string]string)
+	if parent, ok := ctx.Value(contextKey{}).(map[string]string); ok {
+		for k, v := range parent {
+			fields[k] = v
+		}
+	}
+	fields[key] = value
+
+	return context.WithValue(ctx, contextKey{}, fields)
+}
+
+// ContextField returns the value of a field of the context, empty if not set
+func ContextField(ctx context.Context, key string) string {
+	if fields, ok := ctx.Value(contextKey{}).(map[string]string); ok {
+		return fields[key]
+	}
+	return ""
+}
+
+// contextPrefix returns the fields of the context as [key=value ...]
+func contextPrefix(ctx context.Context) string {
+	fields, ok := ctx.Value(contextKey{}).(map[string]string)
+	if !ok || len(fields) == 0 {
+		return ""
+	}
+
+	var kv []string
+	for k, v := range fields {
+		kv = append(kv, k+"="+v)
+	}
+	sort.Strings(kv)
+
+	return "[" + strings.Join(kv, " ") + "] "
+}
+
+// ContextLogger prefixes the messages with the fields of a context
+type ContextLogger struct {
+	logger *logging.Logger
+	prefix string
+}
+
+func (l *ContextLogger) Debugf(format string, args ...interface{}) {
+	l.logger.Debugf(l.prefix+format, args...)
+}
+
+func (l *ContextLogger) Infof(format string, args ...interface{}) {
+	l.logger.Infof(l.prefix+format, args...)
+}
+
+func (l *ContextLogger) Noticef(format string, args ...interface{}) {
+	l.logger.Noticef(l.prefix+format, args...)
+}
+
+func (l *ContextLogger) Warningf(format string, args ...interface{}) {
+	l.logger.Warningf(l.prefix+format, args...)
+}
+
+func (l *ContextLogger) Errorf(format string, args ...interface{}) {
+	l.logger.Errorf(l.prefix+format, args...)
+}
+
+// GetContextLogger returns the logger of the caller prefixing the messages
+// with the fields of the context
+func GetContextLogger(ctx context.Context) *ContextLogger {
+	// a copy, the caller of the ContextLogger methods being the one logged
+	logger := *getLogger(3)
+	logger.ExtraCalldepth++
+
+	return &ContextLogger{
+		logger: &logger,
+		prefix: contextPrefix(ctx),
+	}
+}
@@ -0,0 +1,66 @@
+/*
+ * Copyright (C) 2015 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package logging
+
+import (
+	"testing"
+
+	"github.com/op/go-logging"
+	"golang.org/x/net/context"
+)
+
+func TestContextLogger(t *testing.T) {
+	defaultLogger := GetLogger()
+
+	memory := logging.NewMemoryBackend(10)
+	backend := logging.AddModuleLevel(memory)
+	backend.SetLevel(logging.INFO, "")
+
+	saved := *defaultLogger
+	defaultLogger.SetBackend(backend)
+	defer func() { *defaultLogger = saved }()
+
+	ctx := NewContext(context.Background(), "request_id", "abc")
+	ctx = NewContext(ctx, "user", "admin")
+
+	if id := ContextField(ctx, "request_id"); id != "abc" {
+		t.Errorf("Expected the request_id field, got %q", id)
+	}
+	if id := ContextField(context.Background(), "request_id"); id != "" {
+		t.Errorf("Expected no field, got %q", id)
+	}
+
+	GetContextLogger(ctx).Infof("query %s", "G.V()")
+	GetContextLogger(context.Background()).Infof("no field")
+
+	head := memory.Head()
+	if head == nil || head.Next() == nil {
+		t.Fatal("Expected 2 messages to be logged")
+	}
+	if msg := head.Record.Message(); msg != "[request_id=abc user=admin] query G.V()" {
+		t.Errorf("Expected the message to be prefixed with the fields, got %q", msg)
+	}
+	if msg := head.Next().Record.Message(); msg != "no field" {
+		t.Errorf("Expected the message without prefix, got %q", msg)
+	}
+}
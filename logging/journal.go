@@ -0,0 +1,121 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package logging
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/redhat-cip/skydive/config"
+)
+
+// names of the journals kept by the analyzer
+const (
+	JournalSlowQueries = "slow_queries"
+	JournalAudit       = "audit"
+	JournalParseErrors = "parse_errors"
+)
+
+// JournalEntry is a message recorded by a journal, along with the ID of the
+// request it was recorded for
+type JournalEntry struct {
+	Time      time.Time
+	Message   string
+	RequestID string `json:",omitempty"`
+}
+
+// Journal keeps the last messages of a kind, along with the messages
+// logged, so that they can be collected without going through the logs.
+// Recording takes the lock of the journal only.
+type Journal struct {
+	sync.Mutex
+	entries []JournalEntry
+	next    int
+	full    bool
+}
+
+// Record adds a message to the journal, replacing the oldest one once full
+func (j *Journal) Record(format string, args ...interface{}) {
+	j.record(JournalEntry{Time: time.Now().UTC(), Message: fmt.Sprintf(format, args...)})
+}
+
+// RecordContext adds a message to the journal recorded for the request of
+// the context
+func (j *Journal) RecordContext(ctx context.Context, format string, args ...interface{}) {
+	j.record(JournalEntry{Time: time.Now().UTC(), Message: fmt.Sprintf(format, args...), RequestID: ContextField(ctx, "request_id")})
+}
+
+func (j *Journal) record(entry JournalEntry) {
+	j.Lock()
+	defer j.Unlock()
+
+	if len(j.entries) == 0 {
+		return
+	}
+
+	j.entries[j.next] = entry
+	if j.next++; j.next == len(j.entries) {
+		j.next, j.full = 0, true
+	}
+}
+
+// Entries returns the messages of the journal, the oldest first
+func (j *Journal) Entries() []JournalEntry {
+	j.Lock()
+	defer j.Unlock()
+
+	if !j.full {
+		return append([]JournalEntry{}, j.entries[:j.next]...)
+	}
+	return append(append([]JournalEntry{}, j.entries[j.next:]...), j.entries[:j.next]...)
+}
+
+// NewJournal returns a journal of the last size messages
+func NewJournal(size int) *Journal {
+	if size < 0 {
+		size = 0
+	}
+	return &Journal{entries: make([]JournalEntry, size)}
+}
+
+var journals = struct {
+	sync.Mutex
+	byName map[string]*Journal
+}{byName: make(map[string]*Journal)}
+
+// GetJournal returns the journal of the given name, keeping the last
+// log_journals.size messages
+func GetJournal(name string) *Journal {
+	journals.Lock()
+	defer journals.Unlock()
+
+	j, ok := journals.byName[name]
+	if !ok {
+		j = NewJournal(config.GetConfig().GetInt("log_journals.size"))
+		journals.byName[name] = j
+	}
+	return j
+}
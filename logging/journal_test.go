@@ -0,0 +1,69 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package logging
+
+import (
+	"fmt"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestJournal(t *testing.T) {
+	j := NewJournal(3)
+	if entries := j.Entries(); len(entries) != 0 {
+		t.Errorf("Expected an empty journal, got %v", entries)
+	}
+
+	for i := 0; i != 5; i++ {
+		j.Record("message %d", i)
+	}
+
+	entries := j.Entries()
+	if len(entries) != 3 {
+		t.Fatalf("Expected the last 3 messages, got %v", entries)
+	}
+	for i, entry := range entries {
+		if expected := fmt.Sprintf("message %d", i+2); entry.Message != expected {
+			t.Errorf("Expected %s, got %s", expected, entry.Message)
+		}
+	}
+
+	// a journal of no size records nothing
+	j = NewJournal(0)
+	j.Record("message")
+	if entries := j.Entries(); len(entries) != 0 {
+		t.Errorf("Expected an empty journal, got %v", entries)
+	}
+}
+
+func TestJournalRequestID(t *testing.T) {
+	j := NewJournal(2)
+	j.Record("message")
+	j.RecordContext(NewContext(context.Background(), "request_id", "id"), "request message")
+
+	entries := j.Entries()
+	if entries[0].RequestID != "" || entries[1].RequestID != "id" {
+		t.Errorf("Expected the request ID of the second message only, got %v", entries)
+	}
+}
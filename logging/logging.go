@@ -35,9 +35,11 @@ import (
 	"github.com/redhat-cip/skydive/config"
 )
 
-func getPackageFunction() (pkg string, fun string) {
+// getPackageFunction returns the package and the function of the caller
+// skip frames above
+func getPackageFunction(skip int) (pkg string, fun string) {
 	pkg, fun = "???", "???"
-	if pc, _, _, ok := runtime.Caller(2); ok {
+	if pc, _, _, ok := runtime.Caller(skip); ok {
 		if fr := runtime.FuncForPC(pc); fr != nil {
 			f := fr.Name()
 			i := strings.LastIndex(f, "/")
@@ -133,10 +135,14 @@ func initLogger() (err error) {
 }
 
 func GetLogger() (log *logging.Logger) {
+	return getLogger(3)
+}
+
+func getLogger(skip int) (log *logging.Logger) {
 	skydiveLoggerLock.Lock()
 	defer skydiveLoggerLock.Unlock()
 
-	pkg, f := getPackageFunction()
+	pkg, f := getPackageFunction(skip)
 	log, found := skydiveLogger.loggers[pkg+"."+f]
 	if !found {
 		log, found = skydiveLogger.loggers[pkg]
@@ -0,0 +1,109 @@
+/*
+ * Copyright (C) 2015 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package logging
+
+import (
+	"sync"
+	"time"
+
+	"github.com/redhat-cip/skydive/config"
+)
+
+// Sampler samples the messages logged by a hot path, letting through 1 in
+// every messages and at most rate messages per second. A nil Sampler lets
+// all the messages through.
+type Sampler struct {
+	sync.Mutex
+	every   uint64
+	rate    float64
+	count   uint64
+	skipped uint64
+	tokens  float64
+	last    time.Time
+}
+
+// Allow tells whether the message has to be logged, along with the number
+// of messages skipped since the previous one logged
+func (s *Sampler) Allow() (bool, uint64) {
+	if s == nil {
+		return true, 0
+	}
+	return s.allow(time.Now())
+}
+
+func (s *Sampler) allow(now time.Time) (bool, uint64) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.count++
+	if s.every > 1 && (s.count-1)%s.every != 0 {
+		s.skipped++
+		return false, 0
+	}
+
+	if s.rate > 0 {
+		s.tokens += now.Sub(s.last).Seconds() * s.rate
+		if s.tokens > s.rate {
+			s.tokens = s.rate
+		}
+		s.last = now
+
+		if s.tokens < 1 {
+			s.skipped++
+			return false, 0
+		}
+		s.tokens--
+	}
+
+	skipped := s.skipped
+	s.skipped = 0
+
+	return true, skipped
+}
+
+// NewSampler returns a sampler letting through 1 in every messages and at
+// most rate messages per second, 0 disabling the rate limit
+func NewSampler(every int, rate float64) *Sampler {
+	if every < 1 {
+		every = 1
+	}
+
+	s := &Sampler{
+		every: uint64(every),
+		rate:  rate,
+		last:  time.Now(),
+	}
+	// let a first burst through
+	if rate > 0 {
+		s.tokens = rate
+	}
+
+	return s
+}
+
+// NewSamplerFromConfig returns the sampler configured by
+// log_sampling.<name>.every and log_sampling.<name>.rate
+func NewSamplerFromConfig(name string) *Sampler {
+	cfg := config.GetConfig()
+	return NewSampler(cfg.GetInt("log_sampling."+name+".every"), cfg.GetFloat64("log_sampling."+name+".rate"))
+}
@@ -0,0 +1,69 @@
+/*
+ * Copyright (C) 2015 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package logging
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSamplerEvery(t *testing.T) {
+	s := NewSampler(10, 0)
+	now := time.Now()
+
+	allowed, skipped := 0, uint64(0)
+	for i := 0; i != 100; i++ {
+		if ok, n := s.allow(now); ok {
+			allowed++
+			skipped += n
+		}
+	}
+
+	if allowed != 10 || skipped != 81 {
+		t.Errorf("Expected 1 in 10 messages, got %d with %d skipped", allowed, skipped)
+	}
+}
+
+func TestSamplerRate(t *testing.T) {
+	s := NewSampler(1, 5)
+	now := s.last
+
+	// a message every 10ms for 2 seconds, the first burst being let through
+	allowed := 0
+	for i := 0; i != 200; i++ {
+		if ok, _ := s.allow(now.Add(time.Duration(i) * 10 * time.Millisecond)); ok {
+			allowed++
+		}
+	}
+
+	if allowed < 14 || allowed > 15 {
+		t.Errorf("Expected 5 messages per second after a burst of 5, got %d", allowed)
+	}
+}
+
+func TestSamplerNil(t *testing.T) {
+	var s *Sampler
+	if ok, _ := s.Allow(); !ok {
+		t.Error("A nil sampler should let all the messages through")
+	}
+}
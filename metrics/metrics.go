@@ -0,0 +1,220 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package metrics
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/abbot/go-http-auth"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/redhat-cip/skydive/flow"
+	shttp "github.com/redhat-cip/skydive/http"
+)
+
+var (
+	// FlowsReceived counts the flows received from the agents
+	FlowsReceived = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "skydive",
+		Subsystem: "analyzer",
+		Name:      "flows_received_total",
+		Help:      "Number of flows received from the agents.",
+	})
+
+	// FlowsStored counts the flows written to the storage
+	FlowsStored = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "skydive",
+		Subsystem: "analyzer",
+		Name:      "flows_stored_total",
+		Help:      "Number of flows written to the storage.",
+	})
+
+	// FlowsExpired counts the flows expired from the flow table
+	FlowsExpired = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "skydive",
+		Subsystem: "analyzer",
+		Name:      "flows_expired_total",
+		Help:      "Number of flows expired from the flow table.",
+	})
+
+	// StorageErrors counts the flow batches failing to be stored
+	StorageErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "skydive",
+		Subsystem: "analyzer",
+		Name:      "storage_errors_total",
+		Help:      "Number of flow batches failing to be stored.",
+	})
+
+	// AlertEvaluations counts the evaluations of the alerts, on the nodes
+	// for the fixed ones and periodically for the absence ones
+	AlertEvaluations = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "skydive",
+		Subsystem: "analyzer",
+		Name:      "alert_evaluations_total",
+		Help:      "Number of evaluations of the alerts.",
+	})
+
+	// FlowParseErrors counts the malformed flows skipped
+	FlowParseErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "skydive",
+		Subsystem: "analyzer",
+		Name:      "flow_parse_errors_total",
+		Help:      "Number of malformed flows skipped.",
+	})
+
+	// UDPReadErrors counts the errors while reading the flow datagrams
+	UDPReadErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "skydive",
+		Subsystem: "analyzer",
+		Name:      "udp_read_errors_total",
+		Help:      "Number of errors while reading the flow datagrams.",
+	})
+
+	// UDPDatagramsDropped counts the flow datagrams dropped as the ingestion
+	// queue was full
+	UDPDatagramsDropped = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "skydive",
+		Subsystem: "analyzer",
+		Name:      "udp_datagrams_dropped_total",
+		Help:      "Number of flow datagrams dropped as the ingestion queue was full.",
+	})
+
+	// UDPDatagramsTruncated counts the flow datagrams dropped as larger than
+	// analyzer.flow_max_packet_size
+	UDPDatagramsTruncated = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "skydive",
+		Subsystem: "analyzer",
+		Name:      "udp_datagrams_truncated_total",
+		Help:      "Number of flow datagrams dropped as larger than the read buffer.",
+	})
+
+	// FlowTableExpireDuration observes the time spent handing the expired
+	// flows of the flow table over to the storage and the listeners
+	FlowTableExpireDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "skydive",
+		Subsystem: "analyzer",
+		Name:      "flowtable_expire_duration_seconds",
+		Help:      "Time spent handling the expired flows of the flow table.",
+	})
+
+	flowTableSize = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: "skydive",
+		Subsystem: "analyzer",
+		Name:      "flowtable_size",
+		Help:      "Number of flows in the flow table.",
+	}, func() float64 {
+		sourcesLock.RLock()
+		defer sourcesLock.RUnlock()
+
+		if table == nil {
+			return 0
+		}
+		return float64(table.Len())
+	})
+
+	graphClients = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: "skydive",
+		Subsystem: "analyzer",
+		Name:      "graph_clients",
+		Help:      "Number of clients connected to the graph websocket.",
+	}, func() float64 {
+		sourcesLock.RLock()
+		defer sourcesLock.RUnlock()
+
+		if wsServer == nil {
+			return 0
+		}
+		return float64(wsServer.ClientCount())
+	})
+
+	udpQueueDepth = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: "skydive",
+		Subsystem: "analyzer",
+		Name:      "udp_queue_depth",
+		Help:      "Number of flow datagrams waiting to be analyzed.",
+	}, func() float64 {
+		sourcesLock.RLock()
+		defer sourcesLock.RUnlock()
+
+		if udpQueue == nil {
+			return 0
+		}
+		return float64(udpQueue.Len())
+	})
+
+	registerOnce sync.Once
+	sourcesLock  sync.RWMutex
+	table        *flow.Table
+	wsServer     *shttp.WSServer
+	udpQueue     Queue
+)
+
+// Queue is implemented by the queues of the flow datagrams
+type Queue interface {
+	Len() int
+}
+
+// SetUDPQueue sets the queue reported by the udp_queue_depth gauge, nil if
+// the datagrams are not queued
+func SetUDPQueue(q Queue) {
+	sourcesLock.Lock()
+	udpQueue = q
+	sourcesLock.Unlock()
+}
+
+// MustRegister registers the metrics of the analyzer in the default
+// registry, the gauges reporting the size of the given flow table and the
+// clients of the graph websocket server. The metrics are registered once, a
+// later call only replaces the flow table and the websocket server.
+func MustRegister(t *flow.Table, ws *shttp.WSServer) {
+	sourcesLock.Lock()
+	table = t
+	wsServer = ws
+	sourcesLock.Unlock()
+
+	registerOnce.Do(func() {
+		prometheus.MustRegister(FlowsReceived)
+		prometheus.MustRegister(FlowsStored)
+		prometheus.MustRegister(FlowsExpired)
+		prometheus.MustRegister(StorageErrors)
+		prometheus.MustRegister(AlertEvaluations)
+		prometheus.MustRegister(FlowParseErrors)
+		prometheus.MustRegister(UDPReadErrors)
+		prometheus.MustRegister(UDPDatagramsDropped)
+		prometheus.MustRegister(UDPDatagramsTruncated)
+		prometheus.MustRegister(FlowTableExpireDuration)
+		prometheus.MustRegister(flowTableSize)
+		prometheus.MustRegister(graphClients)
+		prometheus.MustRegister(udpQueueDepth)
+	})
+}
+
+// RegisterHandler serves the registered metrics in the Prometheus
+// exposition format under /metrics, authenticated as the API
+func RegisterHandler(s *shttp.Server) {
+	handler := prometheus.Handler()
+	s.HandleFunc("/metrics", func(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
+		handler.ServeHTTP(w, &r.Request)
+	})
+}
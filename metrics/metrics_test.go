@@ -0,0 +1,111 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package metrics
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/redhat-cip/skydive/flow"
+	shttp "github.com/redhat-cip/skydive/http"
+)
+
+type queue int
+
+func (q queue) Len() int {
+	return int(q)
+}
+
+func TestMetricsHandler(t *testing.T) {
+	server := shttp.NewServer("analyzer", "127.0.0.1", 0, shttp.NewNoAuthenticationBackend())
+	ws := shttp.NewWSServer(server, time.Minute, 10, 10, time.Minute, "/ws")
+	go ws.ListenAndServe()
+	defer ws.Stop()
+
+	table := flow.NewTableFromFlows([]*flow.Flow{{UUID: "flow-1"}, {UUID: "flow-2"}})
+	MustRegister(table, ws)
+	// registered again by another analyzer
+	MustRegister(table, ws)
+
+	FlowsReceived.Add(3)
+	UDPReadErrors.Inc()
+	UDPDatagramsDropped.Add(2)
+	UDPDatagramsTruncated.Inc()
+	SetUDPQueue(queue(5))
+	defer SetUDPQueue(nil)
+
+	RegisterHandler(server)
+	ts := httptest.NewServer(server.Router)
+	defer ts.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial(strings.Replace(ts.URL, "http", "ws", 1)+"/ws", nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for ws.ClientCount() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("The websocket client should be connected")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	resp, err := http.Get(ts.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for _, line := range []string{
+		"skydive_analyzer_flows_received_total 3",
+		"skydive_analyzer_udp_read_errors_total 1",
+		"skydive_analyzer_udp_datagrams_dropped_total 2",
+		"skydive_analyzer_udp_datagrams_truncated_total 1",
+		"skydive_analyzer_udp_queue_depth 5",
+		"skydive_analyzer_flows_stored_total 0",
+		"skydive_analyzer_flows_expired_total 0",
+		"skydive_analyzer_storage_errors_total 0",
+		"skydive_analyzer_alert_evaluations_total 0",
+		"skydive_analyzer_graph_clients 1",
+		"skydive_analyzer_flow_parse_errors_total 0",
+		"skydive_analyzer_flowtable_size 2",
+		"skydive_analyzer_flowtable_expire_duration_seconds_count 0",
+	} {
+		if !strings.Contains(string(body), line+"\n") {
+			t.Errorf("Metric %s not found in:\n%s", line, body)
+		}
+	}
+}
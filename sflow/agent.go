@@ -57,6 +57,8 @@ type SFlowAgent struct {
 	FlowMappingPipeline *mappings.FlowMappingPipeline
 	FlowProbeNodeSetter flow.FlowProbeNodeSetter
 	FlowTableAllocator  *flow.TableAllocator
+	// the setter is replaced when the capture is updated
+	setterLock sync.RWMutex
 }
 
 type SFlowAgentAllocator struct {
@@ -91,9 +93,13 @@ func (sfa *SFlowAgent) feedFlowTable(conn *net.UDPConn) {
 		return
 	}
 
+	sfa.setterLock.RLock()
+	setter := sfa.FlowProbeNodeSetter
+	sfa.setterLock.RUnlock()
+
 	if sflowPacket.SampleCount > 0 {
 		for _, sample := range sflowPacket.FlowSamples {
-			flows := flow.FlowsFromSFlowSample(sfa.flowTable, &sample, sfa.FlowProbeNodeSetter)
+			flows := flow.FlowsFromSFlowSample(sfa.flowTable, &sample, setter)
 			logging.GetLogger().Debugf("%d flows captured", len(flows))
 		}
 	}
@@ -155,7 +161,9 @@ func (sfa *SFlowAgent) Flush() {
 }
 
 func (sfa *SFlowAgent) SetFlowProbeNodeSetter(p flow.FlowProbeNodeSetter) {
+	sfa.setterLock.Lock()
 	sfa.FlowProbeNodeSetter = p
+	sfa.setterLock.Unlock()
 }
 
 func NewSFlowAgent(u string, a string, p int, c *analyzer.Client,
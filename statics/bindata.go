@@ -553,7 +553,7 @@ func staticsJsJqueryJsonviewJs() (*asset, error) {
 	return a, nil
 }
 
-var _staticsJsSkydiveJs = []byte("\x1f\x8b\x08\x00\x00\x09\x6e\x88\x00\xff\xd4\x7d\x7b\x73\x1b\x37\xf2\xe0\xff\xfe\x14\xc8\x24\xb7\x1a\x26\xd4\x88\x52\xd6\xf9\x25\xd2\xf9\x97\xf2\x4a\x4e\xa2\xbb\x44\xf2\x59\xce\xa6\xb6\x54\x2a\xd7\x70\x06\x22\xc7\x26\x67\xb8\x33\x43\x89\x74\x56\xdf\xfd\xfa\x81\xe7\xbc\x28\x39\xd9\xbd\x3a\xd7\x6e\x44\x02\x0d\xa0\xd1\xdd\x68\x74\x37\x1a\xe0\xc1\x97\xcf\xc4\x97\xe2\xb4\x58\x6d\xcb\x6c\x36\xaf\x45\x78\x3a\x12\x47\x93\xc3\x6f\xc4\x1b\x99\x8a\x9f\xe2\x7a\x2c\xce\xf3\x24\x02\x18\x04\xfb\x39\x4b\x64\x5e\x41\x45\x5d\x88\x7a\x2e\xc5\xcb\x55\x9c\xc0\x9f\xab\xe2\xb6\xbe\x8f\x4b\x29\x7e\x28\xd6\x79\x1a\xd7\x59\x91\x8b\xf0\xe5\xd5\x0f\x23\x01\x5f\x65\x29\x8a\x5c\x62\xeb\xa2\x14\xcb\x02\xa0\x92\x22\xaf\xcb\x6c\xba\xae\xa1\x60\xc1\x3d\x8a\x78\x56\x4a\xb9\x94\x79\x5d\x45\x42\x5c\x49\x49\xdd\x5f\x5c\xbe\x3d\x3f\x7d\x25\x6e\xb3\x05\xb5\x4f\xb3\x8a\xdb\x01\x02\xf7\x59\x3d\x07\x98\xac\x12\xf7\x45\xf9\x41\xdc\x42\x57\x71\x9a\x66\x38\x74\xbc\x10\x59\x0e\x05\x4b\x42\x04\x1b\x96\x72\x16\x97\x69\x96\xcf\x60\x68\x3d\xcf\xe2\x3e\x97\x65\x35\xcf\x56\x30\xde\x5b\x9c\xca\xd5\x0f\x1a\x99\x8a\x3b\xd6\xc3\xc2\x5c\xb7\xc5\x5a\x4d\xc5\x99\xb5\x22\xc6\x58\xfc\x1d\x3a\xc2\x29\x1f\x45\x13\x11\x02\x00\x36\x0a\x54\x6d\x30\x3a\xa1\xd6\xcb\x78\x2b\xf2\xa2\x16\xeb\x4a\xda\xde\x85\xdc\x24\x72\x55\x03\xba\x80\xd8\x72\xb5\xc8\xe2\x3c\xa1\xd6\x6a\x76\x66\x0c\xc0\xf1\x1f\xaa\x93\x62\x5a\xc7\x00\x1f\xd3\x54\x44\x71\xeb\x82\x89\xb8\x56\x8c\x12\xf3\xba\x5e\x1d\x1f\x1c\xdc\xdf\xdf\x47\x31\xa1\x1b\x15\xe5\xec\x40\x4f\xf0\xe0\x67\xa0\xeb\xc5\xd5\xab\x7d\x40\x59\xb5\xf8\x35\x5f\xc8\xaa\x02\x52\xfd\x73\x9d\x95\x40\xe0\xe9\x56\xc4\x2b\x40\x29\x89\xa7\x80\xe8\x22\xbe\x47\xf6\x11\x97\x88\xfb\x80\xc2\x7d\x09\xe4\xce\x67\x63\x6c\x5d\x69\x09\x70\x79\x64\x29\xa6\xf1\x83\x79\xbb\x00\x40\xb3\x98\x18\x14\xbc\xbc\x12\xe7\x57\x81\xf8\xdb\xcb\xab\xf3\xab\xb1\xf8\xed\xfc\xed\x4f\x97\xbf\xbe\x15\xbf\xbd\x7c\xf3\xe6\xe5\xc5\xdb\xf3\x57\x57\xe2\xf2\x8d\x38\xbd\xbc\x38\x3b\x7f\x7b\x7e\x79\x01\xdf\x7e\x10\x2f\x2f\xfe\x81\x2d\xff\xf7\xf9\xc5\xd9\x58\x48\xa0\x17\x0c\x25\x37\xab\x12\x27\x01\x98\x66\x48\x4e\x99\x3a\xc2\xa4\x71\x40\x51\x51\x4c\xaa\x56\x32\xc9\x6e\xb3\x04\xa6\x97\xcf\xd6\xf1\x4c\x8a\x59\x71\x27\xcb\x1c\x25\x65\x25\xcb\x65\x56\x21\x5f\x2b\x40\x32\x05\xd9\x58\x66\x35\x49\x54\x85\x4d\x5b\x73\xc3\x25\x72\xf0\xec\xd9\x5d\x5c\x8a\x0a\xd8\x97\xcc\xcf\x97\x33\xf1\x42\xec\x55\xd8\x28\xa9\x0e\xb2\xe5\xec\x80\x2b\xa2\x55\x3e\xdb\x3b\x21\xc8\x55\x51\xd6\x1d\x70\x58\xec\x40\x65\x79\x7d\xdb\x01\x85\xc5\x0e\xd4\x9d\xac\xbb\xc6\xc4\x62\x07\x2a\xaf\x3a\x60\xf2\xca\x81\x98\x96\x59\x3a\x93\x1d\x50\x5c\xe1\x40\xa6\x45\xf2\x41\x96\x1d\x90\x5c\xe1\x8e\x2a\xd7\x75\x59\xe4\x1d\xa0\xc5\x0a\x88\x57\xc7\xc9\x07\x07\x7a\x99\xe5\xeb\xaa\x09\x48\x85\xfb\xc5\xba\x5e\x64\xb9\xdc\x3f\xfc\xc6\xa5\xe2\xa2\x0d\x8e\x65\x0d\xa8\xb2\x98\xca\x8b\x22\x95\xe7\x79\x0a\x52\x8d\xca\xa7\x39\x84\x4c\xb3\x78\xbf\x94\x49\x51\xa6\xaa\x21\xb5\xc4\x46\x00\x7b\xbb\xce\x13\xe4\x7f\x78\x7e\x36\x12\xbf\x3f\x13\xb4\x8e\xa3\xf3\x33\xa8\x3a\x3f\x3b\xd1\xdf\x7f\x2a\xaa\x1a\x3b\xde\x33\x25\xbf\xc8\x3a\x06\xa5\x18\x43\xe9\xef\x0f\xa6\xf4\x15\xd0\xb2\xf2\x8b\xfe\x9e\x55\x19\x2e\xb6\x17\xa2\x2e\xd7\xd2\x14\x9f\x16\x8b\x45\xbc\x42\xad\x0b\x38\xc4\x8b\x0a\x6a\x1e\x08\xaf\x78\x21\xcb\x5a\xf7\xf1\x0c\xb1\x8c\x60\x92\x75\x51\x6f\x57\x32\x7a\x0b\xff\x71\x91\x66\x94\xb3\x5b\x11\x06\x58\x15\xe0\x0a\xf6\xd0\x1b\x41\xb5\x80\xf5\x5f\xaf\xcb\x46\xcd\x35\xb7\xb8\x41\x8c\x54\x7d\x10\x20\x12\xcd\x31\x2f\xe2\x65\xdf\x98\x58\xf5\xb4\x31\xa9\xc5\xee\x31\xcf\xab\xd3\x78\x05\xf5\xf2\x32\x6f\x0f\xad\x5b\x5e\x01\x97\x65\xf4\xc3\xa2\xb8\x57\xc0\x6d\x54\xc4\x5f\xfe\xd2\xc4\xa0\xdd\xea\x46\xbc\x78\x21\x82\xcb\x8b\x61\x4c\x5e\x2e\xa0\x09\xb3\xcb\x47\x87\x99\x46\x95\x48\x51\x64\xdd\x75\x90\xca\x3b\xd0\x20\xc1\x58\x04\xb8\x54\xf1\x6f\x71\x57\xf1\x5a\xc3\x2f\xb0\xca\x41\x23\xc5\x0b\xfc\x5c\xaf\x73\xfc\xa3\x2a\x5d\xda\xb8\xbd\x46\x19\xe8\xa6\xcd\xe5\x6d\xd8\xc9\xc4\x91\xf8\xef\x17\x62\xd2\x85\xff\x4c\xd6\x28\xbc\x6f\xe4\x02\x96\xc4\x9d\x7c\x1d\xc3\x1e\xe4\x4c\x61\x05\xdf\xc7\xe2\x0e\x64\x14\x54\xb7\x9a\x0f\x7f\xb9\x56\x0b\xe1\xc6\x48\x2e\xd4\xad\xb8\x39\xfe\x89\x2a\xdc\x77\xc2\x91\x29\x8f\x56\xeb\x6a\x4e\xe8\x8d\x4e\x94\x80\x50\x17\x88\x21\x90\x0a\x69\x3c\x07\x4c\x02\x4f\x3c\xb0\x21\xf5\x40\x2a\x76\x0e\x2a\x52\xd2\x4a\xbb\x26\x3a\xa0\x56\x0f\xb1\x46\x1a\xce\xd2\x0a\x63\x44\xb9\x91\x84\x02\x44\xd1\x54\x5e\x4b\x6a\xcb\x18\x60\x6d\xc4\x93\x2f\x72\x85\xc9\x67\x80\x89\x31\x14\x14\x3a\x82\xec\x17\xd0\x46\x3c\x4f\xee\x3a\x97\x60\x54\x4c\x8b\xb2\xd1\xdd\x6b\xd8\x12\xf3\x1a\x75\xc4\x67\x2f\x8c\xba\x00\x41\xfb\xac\x59\x0d\x38\x6b\xca\xea\x51\x74\x97\x80\xb1\x03\xac\xc6\x34\x23\x9c\xce\xb3\x45\xda\x3b\x80\xa9\x7d\x44\xff\x04\xeb\x74\x8f\x32\x01\xe6\x85\x01\x43\x5a\xe0\xae\x77\x0b\xfa\x37\x0d\x34\x5d\x15\x3b\xd6\x53\xe8\x46\x83\x76\x49\x52\x43\x7c\x4e\x54\x63\x1c\x08\x1a\x47\x0b\x99\xcf\x40\x5e\xfe\x5b\x4c\x10\xfb\x50\xb3\x57\x97\x83\x44\x4c\xc4\xbf\xfe\x25\x1c\xd0\xff\x29\x1a\x40\x66\x62\xc2\x95\x0e\x68\xc1\x63\x3d\x3c\xc3\xff\xdb\x15\xa3\x61\xba\x56\xc2\x8f\xc3\x2b\x81\xe7\x9e\x43\x9b\x4a\x8b\x53\xd7\x8c\xaf\x6f\xc6\xa0\x99\x8d\x84\x13\xbc\x3b\x21\x4f\xba\x51\xb9\x29\xd9\x56\x2b\x27\x08\xb4\x58\x67\xc8\x3e\x6e\x5e\x4a\xb0\x4f\x2a\x10\x4d\x57\xae\x73\xde\x9f\x08\xe2\x3a\xbb\x71\x78\x48\x8b\xcd\xd2\x56\x53\x88\x46\xf8\x0a\x86\x38\x08\x14\xb0\x2e\xc1\x3e\x48\x8d\xc3\x24\xbf\x12\xc1\x35\xae\x83\x17\x01\x7c\xa4\x0a\xb5\x2a\xa0\xe2\x26\x38\x69\xd0\x93\x97\xe7\x03\xef\x98\xaf\x78\xa5\x7d\xea\x8e\xc9\xa2\xee\x97\x91\x78\x3e\x66\x63\x6d\xee\xa2\x80\xd2\x2b\xb2\x5b\xfe\xb3\x5b\x63\x63\x4c\x57\xb1\xf4\x8c\xed\x82\x3c\x0d\x07\xaf\x65\x07\x2e\xc8\x92\x1f\xcb\x78\x35\xef\xe5\xc9\x85\x12\xe6\x1e\x03\x05\xed\x0b\xea\xc0\xdd\xec\xe5\x7d\xdb\x30\x1a\x0b\x54\xdc\x76\xb7\xd3\xa2\x29\xef\xc9\x8a\xc2\x41\x4f\xd4\xda\x89\x34\x46\x38\x9a\x29\x54\xc2\x80\xbd\x90\x68\x5a\xec\xae\x79\x83\x41\xa8\x13\x47\xee\xf8\xfb\x43\x1b\x3f\x58\xc4\x7d\x86\x9b\x4b\x45\xd3\x77\x5f\x27\x7d\xb2\xec\x76\xc2\xbb\x49\x5f\x27\x40\xa9\x76\x27\x63\x58\x31\x28\xe5\x63\x91\xa0\x64\x37\x09\xa7\xf6\x2a\x24\x1c\xb6\xd5\x84\x73\x76\x02\xda\x5c\x79\x4b\x10\x8e\x06\x87\xe2\x84\x35\xb9\x2a\x6d\x92\x99\x0a\x3b\xc9\x6c\x66\xa1\xb6\x04\xb5\x5f\xd3\x1e\xd5\xae\x13\x3c\x4e\x77\x2b\x45\x1c\xfe\xde\x41\x92\x33\xb9\x68\x32\x07\x19\xc9\xf3\xf7\x95\x9e\xbf\x8f\x13\xa2\xd0\x9a\x88\xe2\x60\x9d\xdd\x8c\x8c\x46\x4a\xe5\x42\xd6\xd2\x65\x2f\xf5\xd3\xc7\x1e\xd5\x9b\x8b\x0b\xe2\xcd\x23\xaa\xbe\xdc\xed\x5a\x99\x0e\x58\x42\x5d\xfa\x40\xa7\x0e\x51\x3a\x60\x5c\xe3\xc3\xd4\x76\x20\x75\x9e\x67\xf5\x0f\x65\xb1\xbc\xda\xe6\xc9\x2f\xe0\xd3\xc6\x3e\x82\xcb\x6a\x66\x65\x05\x9d\x2a\x28\x88\x2e\xa7\xef\x89\xf8\xc6\x16\x22\x1a\xce\x98\x06\xde\x86\x01\x0d\x54\xb1\xdd\x2f\x9c\xe5\xca\x94\xe3\xf5\x1d\xe6\x91\x92\x3d\xa5\xa6\xb4\xda\x21\x15\x95\x1b\x6b\x02\x29\xec\x28\xe4\xfc\xda\x02\x2a\x23\xcb\x5d\xdf\x50\x8d\x9f\xb8\xea\xa1\x0b\xe9\xb6\xf5\x46\x48\x6b\x6e\x3b\x48\xaf\xf4\x72\x20\xb4\xd5\xb2\x0f\xe5\x75\xc0\xfc\x0a\x6e\x14\xf6\x08\x9b\xa8\x25\xd2\x04\x25\xae\x11\x64\xa7\xb5\xa8\x56\x70\x88\xfc\x6a\x2c\xdc\x91\xb3\xdd\xfa\xb4\x91\x9a\x36\xc4\x68\x87\x36\xb2\x4d\x1b\x77\x51\x4a\x9f\x36\x4a\x7f\x63\xd1\xcf\xf1\x16\x1c\xe0\xa6\x1e\x99\xa1\xe8\x8c\x45\x75\x37\x73\x14\xfa\x6f\x59\x4a\x56\xc4\x37\xdf\x4e\xec\x46\x2b\x29\xf2\xa5\x0a\x75\xe9\x4c\xe9\x07\xfa\x6b\x60\xe7\xeb\xc5\xe2\xf2\xf6\xb6\x92\x08\x7f\x74\x64\xca\x41\x8a\x29\x4a\xa7\xbd\x4e\xa6\xd5\x3b\x0a\x69\x69\x1d\xa3\x61\x81\xa5\x09\x92\x30\xfd\x3a\x5a\x10\xe6\x5c\x12\x22\x5d\xa2\x2a\xfb\x28\xc3\x6b\x8b\xeb\xd8\xc5\xf1\x86\x40\x92\x79\x5c\x02\xd1\xf7\xbf\x9b\x90\xe5\x12\x81\xe7\xff\xe1\x2c\x03\x8f\x3d\x87\x4e\x9e\x73\x19\x60\x7d\x97\xd5\xdb\x70\x12\x7d\xfd\x9c\x0a\x80\x28\x01\x78\xf4\x1f\xc0\x51\xb2\xcb\x59\xcb\xd1\x3b\x76\x33\xa0\x1a\xca\x88\xbc\x23\x8b\x2e\x9a\xf5\x31\x18\xb7\x68\x11\x03\x31\xa3\x78\xb5\x92\x79\x1a\x06\xf0\x99\x4c\xff\x28\xae\xeb\x32\x0c\xee\x11\xdb\x60\xec\x90\xd9\xa9\x9c\x13\xfa\x81\x37\x19\xa7\x7a\x55\x90\x3b\xb7\x0f\x56\x1c\xd0\x10\x7d\x39\x70\xdc\xdc\xce\xef\x32\x79\xff\xb7\x62\x83\x35\x13\x30\x79\xd1\xf2\x72\xd8\x09\x86\x97\x2d\x52\x9d\x77\xe0\x6f\x30\x2f\x65\x52\xff\x59\xa8\x97\x88\xd4\xe1\xc4\x29\x49\x16\x71\x45\x73\x30\xbe\x5a\x54\xd5\xdb\x85\x84\x9a\x75\x59\x15\x65\x30\x0e\x96\xc5\x9d\xe4\x9a\x04\x26\x1a\x82\x20\x4c\xe5\x1c\x18\x06\x3e\xc2\xc7\xa2\x58\x86\x23\x62\x17\x7e\x74\xd9\xe5\x73\xeb\x8d\xac\xa0\x31\xb9\x8f\xc8\xaf\xc1\x09\xd7\x72\xe3\x4d\x18\x71\x3e\x72\x71\xde\x42\x81\x12\x94\xc6\x24\x66\x65\xb1\x66\x17\x2f\xc2\x5e\x78\xc3\xd5\x23\x21\x5b\xb4\x2e\x68\x8d\xba\x37\xdb\x73\x40\xd3\x32\x9e\x69\x50\x12\x77\x20\x4a\xb1\x82\x99\x62\x45\x68\x44\x14\xbf\x81\x24\x97\xb5\x3b\xf1\xd4\x7a\x55\x40\x2a\x12\x92\xa8\x2a\xd6\xd0\xc9\x2b\xfe\x0c\x3d\xbd\x2e\x8b\x55\x3c\x8b\x99\x50\x4d\x11\xc6\x55\xfb\xa3\x1e\x1d\x91\x36\x94\x51\x22\x8c\x43\x27\x8b\xc6\xf2\xd0\xa3\x9a\x31\x57\x25\xfd\x3d\x93\xb7\xf1\x7a\x51\xb7\x87\xf1\x5c\x1f\x9e\x24\x15\x31\x24\x95\xe2\x5a\x6d\x80\x50\x91\x8a\x02\x90\x82\x05\x55\xd2\x8b\xec\x89\x3b\x16\x2a\x45\x04\x8e\x2a\xf8\x93\xd4\x2f\x41\x94\x02\xaa\x08\xfc\x01\x3b\xe1\xb0\x02\xe1\x40\x8f\x5a\x1d\xea\x59\xe6\x24\x5f\xdd\xd1\x9a\xba\x8c\xf3\x8a\x55\x18\x93\x86\x0a\xc0\xda\x26\x03\x88\x5c\x5f\xd5\xd8\x32\x0c\x0b\x7c\xd1\x51\xb2\x46\x6d\xf1\x60\x82\x62\x39\xba\xa3\x90\x56\x34\x8d\x03\xeb\x7b\x14\xd0\x2a\x67\x91\xc7\xcf\xdc\x3f\xd5\x0c\x4c\xe2\x4a\xd6\xaf\x8b\x8a\x8e\x3f\xdc\x89\x6c\xc6\x62\xeb\x6c\x0a\x8e\xe8\x9a\xe5\xb1\x19\x39\x4b\x63\x3b\x30\x04\x6e\x95\x67\xb0\x6d\x65\x8b\xaa\xdb\x6c\x43\x6a\xbc\xaf\x08\x81\xff\x75\x75\x79\x11\x61\x9c\x3f\x9f\x65\xb7\xdb\xd0\x33\x0e\x88\x65\x5f\x84\xc1\xe7\x4b\xbd\x07\x8e\x22\x84\xff\x3b\x10\x2a\xc4\xf6\x56\x42\x68\x4b\x52\xde\x37\xbb\x0c\x1d\x6e\xb6\x71\xb0\x2d\x34\x86\x2a\x4c\x84\xe2\x8b\x28\x7e\x1f\x6f\x42\xb3\xb0\x60\x44\xf4\x93\x8e\x45\x80\x83\x05\x63\x55\xbe\x2e\x17\xc7\x62\xef\x20\x5e\x65\x07\xb7\x8b\xe2\xfe\xa0\x92\x71\x99\xcc\xbf\x7f\xad\xa3\xc6\xbf\xfe\x7a\x7e\xf6\x62\x4f\x7b\xc2\xb0\xef\xaa\x76\xd5\x3a\x49\xc0\x3e\x3b\x76\x56\x31\x4e\xd2\x2c\xe4\x21\xba\x18\x72\xf0\x3f\x24\x0a\x8e\x5d\x75\x50\xc4\xc2\xec\x31\xcc\x9e\x03\xb3\x57\x17\xb3\xd9\x42\xee\x81\x6e\x33\xa0\x0f\xf4\xf7\xc1\x5a\xc5\x3a\x06\xd1\x8a\x53\x86\x2a\x72\x02\xc3\x47\x75\x56\x2f\xe4\x7e\xc2\xf5\xfb\x7c\x5e\x01\xd8\x54\xf3\xe2\x9e\x09\x2d\x17\x95\xdc\x05\x3d\xcf\x52\x1d\xed\x03\xa8\xeb\x3c\x5e\xca\x17\x7b\x3e\xd4\xde\x0d\xc0\x4d\x8b\xa2\x06\x62\xc4\xab\x2b\x2a\x03\xa5\x28\xe1\x6b\xb1\x0d\xb4\x88\x3c\xbe\x29\x53\xbb\xc8\xf9\xeb\xe9\x3c\xce\x67\xd2\x61\x09\xad\x4c\x30\x91\x30\xa0\x6b\x59\x43\xc1\x27\xbf\xa8\x25\x2e\x43\x22\xd3\x10\x1b\x85\xe6\xde\xb8\xd1\xf4\xb8\xc9\xf6\xdf\x03\x92\x2a\x14\xd5\xe0\xd8\x0a\xf9\xc3\xc8\x6d\x89\x6b\x15\x90\x56\xe3\xaa\xa3\x38\x9c\xcc\x01\xe2\x70\x22\xd0\x38\x02\xeb\xec\xc5\xba\xbe\xdd\xff\xd6\x43\x09\xd6\xd5\xbc\x48\x01\xab\xd7\x97\x57\x6f\x1d\x6c\x1e\xac\x6c\x10\x1b\x87\x27\xdd\x9e\xd8\x01\x4a\xbf\xc1\xf6\x4f\xc6\xf5\xec\xd5\xcf\xaf\xde\xbe\xea\xc6\x56\xfd\xd5\x0e\xb7\x3a\x1b\x51\x21\x3d\x96\xb3\xb6\x70\x5f\xe6\x36\x48\xf6\x24\x51\xa2\x23\x21\x5c\x4b\x38\xd0\x98\x4f\x5c\x78\x15\xb9\x54\xfb\xb4\x2e\xa9\x33\xaf\xcf\x5e\x75\xfb\x32\x4d\xfb\x3d\x64\x3b\xdd\x33\x13\x28\xd2\x96\xb9\x1b\x28\x6a\x99\xed\xc6\x13\x76\x23\x29\xa6\xb7\xa1\xf8\x7b\x63\xf7\xe7\x10\x3e\x61\x64\x6b\xde\x48\x30\x6b\x48\x51\xf4\xcd\x6a\xd0\xef\x47\x3c\x3e\xeb\x9f\x57\x0b\x1b\xdf\x65\xb4\xb8\x69\xb6\x9b\x73\x05\x1d\x19\xa5\xd0\xe3\x0b\xad\xc1\xed\xaa\x77\xa6\x55\xad\xe8\xac\x22\x03\x5b\xd7\x08\xe0\xb4\x94\xf1\x07\x37\x8a\xec\x7b\xf3\x2d\xda\x3e\x85\x20\xc0\xe6\xfe\xe0\x83\x89\xf2\x3f\x99\xcd\x3a\xb6\xe0\xc6\x64\x9a\xa7\x12\x8f\xe3\x36\x59\x6d\xcc\xed\xdf\xd9\x16\x3d\x76\xa3\x21\xb0\x4c\xd0\x49\xab\x8f\x9d\xe8\xc7\x98\x3e\x73\xc9\x83\xb5\xd0\x1e\x25\x1d\xc3\xc4\xf8\xac\x9f\x1c\x8f\x91\x0e\x9a\x4b\x4b\x3a\xa8\x14\xa4\xe3\x3a\xe0\xf9\x05\x5a\x4e\x1a\x67\x34\x7f\xf9\x8b\x2b\x2e\xb6\x15\x13\xc0\x6f\xa5\x0f\x5e\x18\x2b\x87\x90\x4d\xf9\xea\x15\x26\x1b\x1d\x7a\x3c\xfd\xd0\xb1\xf5\x88\x67\x0d\x33\x2c\x3f\x14\x5f\x0a\x19\xc5\x8b\xd5\x3c\xf6\xf9\x1b\xc9\x18\xb4\x94\xe7\x86\x88\x54\x79\x1e\xd1\x56\xec\xbf\x10\x1f\xc6\x50\xc0\x13\x85\x82\xaf\xa0\xe0\x04\x54\xaf\xe3\x68\x1d\x36\xfd\x18\xad\xae\x4d\x3f\x1b\xbf\xc5\x76\x77\x8b\x6d\x63\x8c\xa3\xfe\x16\x0a\xb5\xe6\x18\xbb\x5b\xd0\x18\xbe\x6e\xd3\x9e\xe1\xa6\xbf\x71\x63\x9c\x64\xdb\x0f\xda\x3f\x80\xeb\x0e\xb4\x5d\x40\x1d\xc1\xf7\xfd\x04\x18\x1a\x7d\x81\x31\x7f\xde\xb2\x5f\xe0\xb8\x67\xed\x60\x8c\x5a\x38\xc6\x3d\x8c\xe4\x72\x55\x6f\xb5\xcd\x67\x8b\xd1\x52\x09\x75\x58\xec\xb4\xc8\xef\xe4\xe6\x27\x28\x07\x87\x4d\x3b\x08\x69\x8f\xab\xaa\x30\x65\x6f\xfd\x0c\x44\xf4\x74\xb1\xae\x6a\x59\x02\x8c\xb1\x41\xfb\x24\xf6\x34\x2b\x93\x85\xbc\xca\x3e\x7a\x8b\x5e\x75\xce\x3b\x6a\x98\x2a\x4d\xa5\x47\x4c\x62\xd8\x85\xf1\x90\x1c\xd3\x64\x82\x63\x97\x5a\x87\xdf\x9e\xf8\x20\xea\xa8\xdc\x03\x3a\x9a\x30\x50\xca\xee\xad\xdf\xc1\x37\xc3\xbb\x32\x6e\x5e\xa7\x18\x32\xe8\x40\x17\xe9\xac\x0f\x5b\x39\x35\xc3\x3f\xe1\xa3\xb2\xe0\x99\x81\x6c\x24\x1a\xa8\xe4\x82\xb3\xcb\xdf\x2e\xfc\x83\xef\x20\x2d\xee\x73\x3e\xa8\xdb\x45\x11\x6f\xba\xb6\x03\x5b\x73\xd2\x4b\x41\x0f\x9a\xca\x5d\xd8\x69\x91\xa7\x2d\x40\x2a\xf4\xa0\xba\x87\xf7\xc6\xf6\xa8\xee\xcc\x91\x8b\x83\x61\xf2\xff\x0c\xca\xaa\x8f\xfc\x2a\x5e\x9b\x46\xed\x0d\xcf\xd9\xe9\x1a\xa4\xa5\xe0\x80\x4b\x5a\x07\xde\xa7\x2e\x25\x67\x34\xb1\xa6\xa0\x03\xd5\x0c\x4e\x4e\x8d\x32\x34\x33\x5e\x08\x97\xab\x38\xc9\xea\x6d\xaf\x70\x35\x8f\x1d\x69\x4a\xb9\xac\xf3\x2a\xc0\x73\x73\x17\xe0\x97\x38\x8f\x67\xb2\x64\x98\x1c\x56\xb2\x37\xf1\x49\x34\x71\x8e\x09\x0f\xa3\x49\xff\x12\xc5\x1d\xb9\x1f\x2f\x2f\x00\xaf\x35\xb7\x8e\x8d\xe8\x68\xbb\xd6\xb6\x86\x2b\xea\x50\x69\x68\x3a\xff\xfa\x17\x13\x9f\x36\xd2\x01\xc0\xf6\xb4\x1e\x39\x2f\x5c\xca\x8a\x48\xaf\xb3\xa4\x2e\x86\x14\x50\x07\x59\x7d\xe9\xe0\x8c\xb7\xa6\x7c\x98\x04\x39\x77\x91\xa8\x5c\xb8\x26\xac\x4d\x91\xdb\xad\x81\x14\x12\x57\x18\x6d\xfd\x37\xa0\x1d\x04\x8f\xc0\x37\x68\x65\x00\x04\x98\xdc\x31\xcd\x16\x20\x28\xc7\x62\x9e\xa5\xa9\xcc\x83\xc1\x69\xec\x24\xfb\x6e\xbd\x6f\xd3\x0f\x38\x93\x72\xb7\x36\x32\xe9\x8d\x43\x3b\x85\xcd\x12\xd1\xa9\x9c\x3e\x45\x50\xf0\x9a\xfc\xab\x1a\x50\x5d\x0a\x43\xe5\x68\xee\xd2\xac\x1d\x93\x31\xa1\xbb\x1d\x32\xd6\xad\x81\x54\x06\xe9\x6e\xc9\xa2\xc0\x04\x6d\x47\x7d\xcc\x51\xbb\x9c\xe7\x66\xbb\x4b\xb0\x9d\x66\xe9\x28\x1a\x95\x8f\xd0\x3b\xfc\x23\x46\x76\x32\xb3\x3a\x14\x80\x4a\xa3\x61\x50\x93\x38\xe9\x23\x08\xd6\x89\x83\x12\xe5\x95\xf6\x63\x05\xc6\x10\x7b\x00\xfd\x6e\xab\x55\x81\x1d\x7e\x47\xfb\x04\xdb\x3d\xdd\x37\x95\x74\xa2\xa9\x6b\x6b\xce\x11\x71\xb6\x22\x7b\xfc\xca\x75\x30\xf9\x55\x8c\xc1\x36\x4c\x8a\x32\x45\x24\x71\x3d\x49\x6a\x0d\xe7\x4f\xbb\xc1\x03\x29\x6d\x2e\x16\x9e\xc7\xd8\x81\x0c\xf9\x8f\x1e\x2e\x2e\x6f\xbc\x74\x00\x3f\x8f\xcd\x6c\x1d\x5e\x55\x33\xb3\x88\x4b\x07\x5c\xe8\xb7\xc5\x8f\x78\x7c\xd3\xe4\x0f\x9e\x8d\x42\xb1\xfa\x53\x59\x76\x51\x0a\x92\x72\xd7\x6d\xf8\x18\x7b\xc0\xb3\x67\x06\x26\xfc\xf8\xf3\x35\xfd\xb9\xf1\x32\x50\x9c\x50\xa8\x07\x84\x07\xa3\xe7\x67\xc7\x04\xf5\x30\x18\xb0\x47\x89\x22\xb4\xfd\x78\xfa\x58\x38\xa8\x57\x4e\xbc\x67\xb7\xc3\xde\x79\x1a\x6e\xc5\x37\xcc\x6d\xba\x25\x27\xf7\xad\x8c\x30\x38\xa9\x7d\xc8\xc7\x55\x87\x94\x38\x03\x39\x7b\xb8\x9b\x29\xa9\x54\x33\x9a\x22\xed\x5a\x07\x65\x42\xcd\xb2\x4d\xf1\x6a\xa5\x3d\x6d\x33\x77\xeb\x36\x79\xe4\x0a\xf5\x01\xbc\x47\xa8\xc1\x35\x4c\xb9\x0e\x8f\x24\x36\x9f\x3c\x28\x50\x75\xc8\xdd\x11\x4c\xa0\x48\x68\x47\x6e\x85\x93\x42\xe1\x80\x98\x05\xfe\xa8\x20\xd7\xd0\x82\x74\x22\x75\xaa\xf2\xe0\x40\x24\xa5\x04\xcd\x29\x62\x50\xf5\x75\x25\x17\xb7\x3c\x81\xf6\x42\xb5\x1b\xdd\xc0\x6a\xed\x66\x8f\x3e\xf7\xf0\x98\xd3\xc9\x1e\x0b\xef\x00\xfb\x6b\x9a\x8b\x07\x59\xe6\xf8\xa0\xdd\x67\x73\x73\x55\xe5\xe4\x21\x18\xb6\xb9\xf9\x1d\x8a\xef\xac\xb8\x0c\x23\x99\xf8\x2e\xdf\x9d\x2e\x28\x1d\x82\x16\xb5\xca\xde\x71\x1a\xe6\xa6\x9d\x9f\x03\xab\x18\x4f\x35\xd7\xb9\x4e\x50\x61\xfa\x67\xd5\x45\x7c\x81\x62\x5b\xc9\x1f\x16\x45\x5c\x33\xff\x37\x23\x27\x67\xb5\xc1\x70\x25\x28\x04\xa7\x32\x1a\xdb\xb0\xcf\x9c\xe1\x17\x98\xce\x85\x14\x21\x2d\x05\xcc\x0d\xed\x37\x4c\x92\x36\xd1\xcc\x05\x07\xf3\x38\x5a\xb9\x11\xfb\xcd\x7c\x0f\xc5\xe9\x6d\xbb\xe6\x13\xfa\xf8\xea\xd1\x7d\xb4\x20\x3f\x01\x8f\xfe\x3e\x7a\xf1\x30\x69\xc1\x46\xa2\x64\x47\x52\x39\x0b\x0b\x55\x6b\xa6\x2b\x58\x15\x19\x25\xae\x1f\x0b\xce\x0e\xaa\xe7\xc7\x78\x1c\x3c\x93\xc5\x92\x46\xb4\x9c\x18\x3d\xb4\x56\x82\xea\x67\x20\x28\x6a\x23\x2a\x3d\xfe\x57\xb2\x2e\xef\xd4\x11\x34\xe6\xad\xe0\x05\x19\x4e\x34\xa0\x64\x93\x55\xc1\xd1\xa3\x84\xae\xc0\xc5\x8b\xfd\x64\x51\x54\x98\xc1\xfd\x8c\x52\x1d\x72\xbc\xe2\x14\x46\xdf\x3e\x1f\xb9\x9e\x13\x75\x09\x26\x14\x4e\x66\xb7\x66\x7d\x2b\x37\x75\x07\x6e\x39\x5f\x07\x71\x54\xa1\xce\xb8\xa2\xd0\xa8\xca\x33\xd6\x5b\x12\x42\xdb\x5c\xe5\x43\x95\xac\xac\xfa\xa0\xca\x6a\x3d\xad\xea\x32\x9c\x8c\xc5\xb7\x94\x84\x1c\x05\x5e\x22\x28\x80\xf4\x63\xfa\x4b\xb1\xae\xe4\xe5\x9d\x2c\x9b\x76\x5c\xda\xc8\x82\x55\x47\xdc\x61\x3a\x30\x6d\xee\x6c\xdd\xb2\x09\xa9\xaf\x5e\x0f\x5f\x59\xa3\x17\x40\x83\xab\x81\xc4\xc7\x4f\x32\x1d\x7d\x1b\x4f\x25\x82\x0e\x9a\x78\x08\x7c\x39\x7d\x2f\x93\x3a\xfa\x20\xb7\x55\xd8\x4c\x5e\x1c\x39\xa9\xea\x87\x56\xd3\x39\x60\x36\xd1\xba\xa3\xf0\x7b\x3e\xe4\x12\xc7\xce\x79\x9d\x6a\xdd\x68\xd7\xd7\x42\x35\xa1\x89\xba\x57\xa0\x1e\x39\xd8\xc3\x70\xb8\x45\x33\xa3\x5b\x1a\xc8\x7f\xd0\x09\x1d\xca\xa5\x7a\xcd\x49\x31\xbe\x37\xb1\x3b\x2a\xe7\x3b\x8b\xde\x85\x2e\x92\x84\x30\xb5\x2a\xe1\x91\x61\x7e\x86\xe8\xde\x14\xbb\x33\xf1\x54\x72\x8c\x0d\xf8\xdb\x68\x2f\x1d\x4b\x0c\x04\xa0\x4d\x34\x1e\x74\x20\xac\xb9\x7d\x8e\x3c\xab\xe8\x39\x5b\xcf\x0f\x7e\x16\x4e\x84\x54\x2a\xc3\x11\xa8\x9f\x4a\x96\x75\x88\xe1\x2f\xba\xca\xa4\x52\x76\x9e\xd9\x44\xb1\x82\xe3\x4a\x43\x01\xf0\x77\x26\x63\x56\x05\xa1\x34\xc1\x3a\x92\xb8\x76\x74\x62\xa2\x87\xa6\x8b\x06\xde\x9b\xac\x06\xb4\x4b\x89\x69\x6b\x61\x23\x68\xaf\xc9\x47\xf2\x68\xc9\x47\xa7\x86\x83\xe4\x73\x69\xa4\xed\x84\x57\x39\x6b\x73\xdb\xa3\xa6\x59\x23\x5f\xab\x91\xa4\x66\x09\xd8\x99\xc8\xd5\x3d\x6d\x57\xd6\x3d\xe2\x35\xb3\xf5\x54\x76\xa2\x93\xb0\x67\x32\xda\xfc\x7c\xac\x61\x4a\x3d\x8d\x29\x26\xa2\xee\xa2\x66\xfb\x52\x38\xa6\x59\xb5\x5a\xc4\x83\x82\xf2\x99\xab\x0f\x80\x52\x20\x73\xa0\x10\x82\xe9\xa2\x48\x54\xf0\x75\x84\x68\x1a\xf2\x1b\x52\x27\x14\x7a\x75\xe9\x5d\xea\x2c\x48\x7b\x3c\xd1\xc5\x0d\xb7\xe1\x53\x05\xda\x8b\xf7\x7a\x5c\x41\xce\x2e\x71\x83\xc1\xab\xc8\x9d\x1d\x71\x0f\xde\x8e\xd6\xd3\xc3\xba\x3b\xbd\xd0\xed\x60\x5d\x7b\xed\x4f\xba\x69\x94\x2d\xe3\x99\x47\xa2\x0d\xae\x98\xe3\x79\x29\x6f\x1f\xc7\x62\x0a\xea\x74\x2c\x5d\x3c\x64\xdb\x3f\x6c\xa6\x68\xfa\x25\x3a\x6d\xd5\xcb\xe4\x34\xe9\xaa\xaa\xf4\xff\x0f\xb2\x19\xd9\xa1\x30\x59\x33\x55\xb5\x4d\x87\xa3\x27\xd3\x81\x4b\xad\x1c\x4e\xa2\xff\x7a\x3a\x76\x94\xaf\xd2\xc4\x6e\xbf\x95\x49\xdb\x8d\xde\xe1\x51\x17\x7a\x5e\xe9\xa3\xd0\xeb\x5e\x97\x5e\x3f\x74\x46\x7b\xf8\xd7\xe6\x51\xec\xe1\x37\x5d\x93\x5a\xaa\x18\x78\x8f\x1a\xe8\xa7\x86\x6d\xe8\xd1\xe3\xaf\x0d\x6a\x7c\xf3\xc7\x59\xf5\x5d\x1f\x2d\x9a\x89\xcd\x29\x65\x36\xbb\xa4\x48\xb7\xb4\xc5\x7e\xfd\x5c\x2e\x9d\x2c\xe6\x1d\x2b\xd3\xb1\xdf\x7d\x51\x36\xde\xd0\x19\x5f\x59\xe8\x3c\x18\x6e\xe4\x1d\xbb\x59\xb7\x74\xf1\x10\x4b\x03\x77\x97\x70\xa0\x75\x9f\x43\x2d\x71\x16\xb4\xd3\x1a\x4c\xa8\xa8\xb9\x55\x62\x8b\x4e\xbe\xd9\x5e\xb8\x22\xeb\x3e\xc1\x36\x3b\x35\x39\x71\x4d\x05\x35\xd8\xa6\xeb\xcc\xdb\xa1\xa2\xb3\x8d\xd1\x04\xc3\x3d\x64\xca\x5e\x27\x7b\x3a\x4f\xd3\xbb\xf8\xd3\xdd\x31\x49\x6f\xc4\x79\x66\xa3\x4f\xd4\xd1\x36\xfa\xbe\x6b\x1a\x3c\x1a\xe9\xb0\x4f\x1e\xad\x71\xd4\xf0\xb8\x21\xd5\x52\xfc\xe4\x41\xdd\x03\xbf\x5d\x23\xb2\xfe\x69\x0d\x49\x3b\xfd\x93\x46\xa3\x73\xba\x8e\xd1\xf4\xf5\x80\xb8\xac\x95\xb9\x8f\xcb\xae\x7d\xc9\x87\x11\x2a\x4a\xc7\x53\xd5\x97\x76\xf0\x7e\x20\x5d\x1d\x73\x97\x17\xf8\x0c\x8d\xbb\x9c\xba\x07\xbc\xd9\xa2\x3e\x9a\xba\xf5\x0a\xd6\x98\xac\xf0\x18\x49\x5f\xb9\xd5\x55\xf7\x1d\x97\x88\xe6\x9d\x97\x88\xaa\xbb\x99\x0a\x40\x30\xf1\x0c\xca\x8f\xba\x45\x73\x3f\x78\x15\x65\xde\xbc\x8a\x42\xca\xd6\x51\xa1\x7b\xea\xd6\xcc\xde\x58\xec\xe1\xad\x99\x3d\x1d\xee\xb9\x57\xb7\x66\xf6\x6c\xd1\x5c\xdf\x9a\x01\x6a\x77\x38\x56\x97\x65\x2a\xcb\x36\x07\xac\x7f\xb5\x11\xf4\x7a\xc2\x33\xc7\x59\x47\x7a\x9b\x40\x2e\x11\xdf\xbb\xac\x48\x25\xd7\xf8\xdf\x1b\x37\x4d\x1f\x73\xf3\x27\x2a\x08\xb5\xc1\x8c\xaa\x16\xb0\xbe\xf3\x73\x38\xf1\x1d\x44\xcd\x95\x8d\xa9\xd3\x2c\xe8\xa7\x6d\x07\x94\xbd\x6a\xf4\xc7\x88\xf6\x32\x4d\xd5\xc5\x35\x43\x2e\x7b\x95\xb5\x39\x29\x25\xb2\xd6\xad\x25\xd8\xb1\x23\xd4\x63\x83\xa7\x97\x58\xea\x30\x26\xf4\x22\x55\xcd\x11\xba\x91\x3c\x93\x8b\x26\x92\x36\xec\xe2\xe6\xdf\x71\x4f\x36\x93\xb3\x67\xc6\xc3\xa7\x81\x73\x33\x3d\x1d\xdc\x52\x11\xff\x9f\xda\xa2\xc2\xd1\x84\xb9\x7b\xbb\x4f\xc7\xdf\x11\xda\xb6\xf3\xd3\xef\xdb\x0d\x1c\xcc\x7f\x62\x42\x3c\x73\xc1\x34\xd6\x3a\x67\xb7\x87\x4a\x8f\xca\xce\xed\x9d\x46\x33\x79\xb4\x89\x93\x1e\x61\x08\x89\xc1\x8c\xd8\x3e\xea\xda\xfb\x93\x4f\xa3\xae\x69\xf7\x38\xea\x1a\xf0\x2e\xea\xf2\x0d\x51\x44\xb5\x97\xba\x8f\xca\x6e\x7d\x22\x75\x2d\x4e\xe6\x5e\xf4\x00\x12\x8f\xbd\x57\xec\x04\x6d\x3b\x9a\x10\x9c\xaf\x05\x9d\xc3\x2c\xf7\x64\xcc\xea\x41\x2d\x7f\xad\x93\x31\x8c\x8b\xef\xe8\x8b\x23\x92\x5e\x5f\xf6\x02\xb8\x03\xa2\xfa\xea\x9a\xf8\xe9\x42\xc6\x65\x5b\xa7\x3f\x7e\x4c\xef\xd2\x79\x7b\xcc\x27\xd1\x42\x2f\x83\x3f\x83\x16\x5c\xfa\x67\x62\x67\x7a\x1c\xc2\xb1\x7b\xff\xec\x0e\x4c\xda\xad\x72\xf7\x3e\x79\xe3\x04\x40\x55\x04\xb7\x35\x0e\x98\x8e\x78\xe7\x8a\x0c\x9f\x21\x21\x56\x81\x59\xbc\x1b\x8f\xa1\x59\x3f\x30\x8b\xe2\xfc\x46\xae\x16\xdb\x46\x70\x16\xe5\x24\x34\x27\x4c\x54\xd6\xbf\x02\x18\x48\x5d\x10\x70\x3a\x47\x4a\xfd\x4a\x86\x95\x4d\xee\xe9\x3e\x1d\xd6\x47\x22\xea\x02\xbf\xbd\x6c\xdf\xbe\x53\xaf\x41\x74\x91\x89\x91\x37\xe2\xc6\x43\x48\xc1\xca\xd9\x89\x92\xbe\xf9\x6f\x51\x1a\x9b\xb1\x8d\xae\xc4\x7f\xed\x3b\xef\x0a\xcc\x1e\x55\x3e\x6d\x0e\x8d\x3d\x6a\x68\x22\x67\xb4\x69\xff\x01\xea\xba\x6f\x1c\x51\x3b\xff\x7d\x23\x33\x03\x47\xeb\xf6\xac\x10\x0f\x5b\x6f\x7d\xef\x98\x07\xae\xd0\x2e\x29\x71\x9f\x1e\x30\xf3\xa0\xe5\xdd\x31\x8f\xe6\xeb\x02\x7f\x54\x4a\x70\x9c\xb6\x94\xf8\x69\x25\xdd\xc4\xe5\x4c\x13\xd3\x75\xeb\xbd\x85\xee\x56\xa7\xee\x2b\x0a\x7d\xf3\xd7\x0f\x30\xb8\x22\xd9\x78\x3f\xe5\x53\x25\xf4\x69\xf4\x6b\xec\xf3\x43\x44\xec\x92\xd0\x27\x71\xd6\x91\x50\x6e\xd7\x23\xa1\xee\xf0\x9d\x9b\x82\x87\xac\xb7\x99\x75\x4d\x63\x87\xd6\x7d\x89\x69\xec\xbb\x9e\x24\x69\x1d\x43\xa9\x8d\xc7\xa1\xef\x1b\x19\x57\x45\x8e\x51\x1d\x75\x44\xc2\x39\xf3\x2a\x61\xc1\xbc\x67\xd2\x92\x5b\xd4\xeb\xb2\x7e\x9b\x2d\x31\x4e\x1b\xba\x3b\x8d\x36\xe4\x6d\x47\x27\xe6\x0d\x01\xd5\x5a\x3c\x60\x94\x75\x32\xe9\xd9\xc0\xae\xd0\x17\xff\x39\xbb\x53\xab\xb2\xbd\x93\x75\x79\xcc\xe8\xd0\xfc\x26\xa7\x57\xf4\x1d\xbc\xaf\xea\xf8\xe0\x00\x4f\xc9\x16\x05\x5f\x82\xa4\x9d\x0d\xcf\xce\x0e\xf0\x66\x6f\xef\x35\x8d\x56\xd7\x51\x91\xe3\xeb\x98\x6d\x24\xb8\x3d\x50\x08\x9d\x7c\x7a\x27\xb1\x5a\xc5\x89\x0c\x8e\x45\x40\x5b\x22\xc6\xbe\x28\x4f\xfa\x58\xef\x72\xff\x5c\xcb\xaa\x0e\x1e\x98\xd1\xef\xda\x23\x55\xe8\xa2\x37\x6e\xa9\x22\x3f\x1b\x47\x93\x0d\xec\x28\x91\xa0\x0b\xbd\x3e\xfe\xf0\xc8\x0d\x22\xfb\x5c\x19\x18\x6e\xd9\x96\x39\xd9\x26\xc8\xfb\xff\xb3\x96\xe5\x36\xa2\x5c\x1b\x9c\x51\xc8\xa7\x75\x4a\xc8\x1d\x8b\xc0\xd0\xcd\x1e\x6e\xf3\xda\x65\x1a\x1e\x9b\xe5\xc5\x58\x77\xd8\x1c\x9e\x0d\xe0\x5d\x13\xd4\x5d\xd1\x5a\xe9\xeb\xca\x5d\x48\xfd\x5d\xd1\x39\xb6\x09\x0e\x9d\x65\x55\x82\x07\x1c\xdb\x47\x45\x89\x86\xe3\x37\x13\x5b\x58\xc6\x69\x46\x6f\xa8\x86\xbf\x60\xf8\x75\x99\xe5\xa1\xed\xc0\x0f\xc3\x88\x03\x71\x34\x12\xfb\xe2\xb9\x6d\x9d\x14\x0b\x0a\x2d\x61\xf8\x07\x5f\x2b\x88\x40\xee\xe5\xac\x28\xb7\x47\x93\x44\xad\xd8\x83\x03\xf1\x37\x98\x53\x9a\x94\xeb\xe5\x54\xa4\x20\x1c\x94\x77\x52\x1d\x0b\x35\x04\xf7\x3e\x16\xc8\x11\x7c\x54\x98\xcb\xe9\x7d\xe3\x6c\x75\x80\x29\x19\x91\x1e\x0e\x9f\x1a\x64\x8e\xdd\x1f\x8b\xff\x7a\x0e\x6d\x8f\xc5\xd7\x13\x68\x0a\x7f\x00\xd7\x63\x10\x25\xa6\xd9\x7f\x3a\x38\xd5\x75\x74\x4b\x31\x67\x27\x7b\xdb\xa9\x1a\x7a\x0e\xc2\x72\x0f\xc8\x6d\x6e\x7c\xb9\x0c\xfc\x52\x44\xcf\x8f\xcc\xbb\x10\x7a\xaa\x20\xf6\xb5\x7e\x05\xc2\x3e\xbb\x63\x4a\xd5\xd3\x3b\x45\x59\x87\xfa\x4a\x88\x7a\x88\xe7\x08\x3a\x24\xde\xbf\x3e\x1f\x7b\x32\xf1\xa5\xfb\x8d\xdf\xe5\xb9\x8b\x17\x6b\x19\x76\xde\x77\x3b\xf4\x6f\xbb\xc5\x65\x62\xf3\x92\xe0\x8b\x1a\x1f\x15\xc0\xcb\x7c\xb6\xe8\xe9\xc4\xb9\x5f\x07\x04\x1d\x06\xa4\x6c\x84\xd4\xc0\x67\x39\x10\xf9\x0d\xe1\xda\xdd\x84\xe6\x58\xfd\x13\x08\x90\x46\xdb\x91\x6e\x06\x44\x7a\x42\x33\x1e\x93\x5b\xf7\xaa\x73\x5e\xea\x19\x78\x0e\x59\xbc\x00\x12\x5b\xf1\x7f\x5b\x82\x34\xb3\x05\x66\x64\x92\x8e\x3e\x3e\xc7\x3d\x28\xe0\x37\x71\x12\x7a\xc2\xa0\xff\x55\x1c\x3a\x73\x98\x63\xe4\x98\xbe\x12\x4b\x4c\x80\x19\x94\x45\x43\x51\x34\xf3\xc9\xb0\xa5\xab\x3c\x6a\xe3\x1e\x51\xa6\x69\x51\xc7\x0b\x75\x29\xcf\x0f\x30\x3a\xd8\x7e\xd9\x38\xda\xe9\x22\xc2\xc1\x41\x5c\x55\xd9\x2c\x17\xd3\x2d\x28\x72\x11\x57\xfa\x7e\x02\xda\x36\x79\xc1\x09\xa8\x33\xd8\x0a\x72\x5a\xdd\x9c\xe0\xaa\xb3\x55\x5f\x08\x63\xec\x8c\xf0\x48\x9f\xfa\xc0\x33\x7d\xac\x57\xd4\xc3\xf7\x05\xc2\xc0\xbe\xda\x91\xea\x69\xd3\x0e\x8c\x80\x0e\x05\xcb\xa2\xa8\xdd\x0d\x43\x3d\xb4\xf9\xce\xcc\x0e\x36\x8a\xf5\x92\xc1\x9a\x47\x52\xc6\xdc\xe2\x13\xa9\x77\xfe\x6a\x53\xf7\xce\x35\x48\xef\x09\x15\xd5\xaa\x73\xa5\xee\x8c\x06\x2b\xda\xa9\x5c\x01\x7a\xdf\xd3\xdd\x2d\x4c\x64\xa0\x8c\x06\x14\x39\x54\xa6\xf8\xc4\x86\x20\x61\x17\xb8\x5f\x37\xba\x86\x4e\xdf\xe9\xf5\x67\x86\x55\x19\x0a\xf8\xc2\xc6\x07\x4a\xc7\xf9\xfc\xf6\xf6\x36\x68\x56\xdf\x66\x8b\x45\x1f\x4e\xef\xac\xb6\x0f\x61\x1d\x90\xdd\x0d\x26\x38\xe0\x98\x02\x82\x98\x2c\x48\xf6\x7f\x84\x99\x78\x7a\x69\x35\xfb\xde\x2f\xd7\x74\x7a\x12\x60\x32\x55\x91\x5a\x33\xb6\x75\x80\x6f\x3e\x5b\xb2\xe2\x2d\x65\x50\x1e\xd5\x5c\x6d\x95\xae\x9c\xd2\xb9\x21\xb2\x01\xe8\xfe\xee\x1d\x32\xe9\xdd\x3b\x5e\x16\x36\x4b\x1a\x8c\x77\x81\x4f\xb6\x53\xd7\xe0\xe7\xdf\x49\x01\xcb\x2c\x5d\xe0\x4b\xee\xfc\x83\x06\x53\xfc\x01\x03\xfa\xa1\x00\x3a\xfc\xa1\x86\x76\xe3\x08\x3e\x77\x14\xb9\x45\x98\x7a\xd2\x18\xd3\x17\xed\xce\x34\xd6\xf7\x92\xd2\x77\xba\xd7\xb7\xe0\x92\xd0\x4f\x2a\xa6\x09\x68\x87\x41\x7d\x41\x37\x20\x01\x3c\xeb\xc0\xd8\x0b\xdf\x7b\x6a\xc2\x55\xc6\x06\xe4\xb8\xf7\xb2\x35\xd0\xef\x44\x6d\x97\xf8\x0f\x09\x69\x5a\x75\x4b\x3b\x6f\x00\xac\x73\x7c\xd1\xc7\x83\x4d\xdc\xc9\xec\x6e\xa3\xfb\x59\x97\xfc\x66\xd5\xe1\xf3\xc9\xc4\x29\x47\x89\x7d\x7b\x2f\x65\xce\x62\x0b\x02\x4b\xdf\x74\x76\xae\x7b\x66\x06\x0c\xbc\xcc\xad\x58\x98\xf9\x40\x95\x21\xa2\xa9\xb5\xa7\x72\xb4\xd2\x65\x99\x80\x68\xb2\xf5\x18\x82\xa9\x89\xbf\x26\xa1\xe8\x79\x60\xc5\x68\x14\xd5\xc5\xeb\x52\x26\x19\xe5\xc4\x7e\x4d\x49\xa6\xe2\x7f\xa8\xdb\x70\xea\x0d\xf9\xba\x80\x05\xf0\x4e\x3f\x3b\x6b\x26\xd2\xfa\xc7\xef\x97\xe3\xb2\xc0\xe5\x30\x1e\x00\x7c\x6d\x90\x03\x70\x8b\xe9\x50\x13\x44\x96\xfa\x46\xe6\x0d\x01\xfe\x1d\xa7\x48\x90\x34\xd9\x21\xd0\x33\xd4\x37\x04\x4a\x9a\xa7\x1f\xf2\xc1\xd2\xa3\xfb\x59\x23\x8f\x4a\x8a\x93\xf8\xb6\x91\x57\xde\xf3\xc6\x11\x3d\xa9\x85\xfe\x4a\x9e\xc8\x97\x65\x19\xe3\x9d\xd3\x99\x04\x6b\x01\x2c\x66\x30\xd9\x74\x96\x98\x10\xec\x1c\xd8\x5d\xb5\x0a\xbd\x66\x63\x87\x92\xd6\xad\x70\x44\x88\x97\x7f\xaf\x0c\x51\xb5\x15\x22\x57\x07\xd4\xb8\x7f\x3b\xee\xb7\x56\x6f\xf6\xfa\x23\x25\x1f\xf0\x05\x48\xad\x09\x06\xe7\xff\xfb\x83\x87\xe2\x8f\xb8\x21\x8a\x98\xc3\x52\xf4\x5b\x1f\xd6\xac\x63\x8b\x6e\x6c\xde\x7d\x87\xff\x11\x95\xc0\x50\x8e\x61\x9f\x80\x3f\x59\x8d\x3f\x58\xc1\xe4\xe2\xfe\x54\x8e\xe2\x1c\xec\x46\x7c\x14\xfb\x36\x2b\xf1\xc8\x6d\x0a\xee\x83\xdc\x24\x8b\x35\xe9\x3b\x54\x7e\xb8\xf1\x45\x2e\x25\x3c\xc2\xdb\x93\x20\x6f\xf7\xbc\x76\x2e\xae\x25\xeb\x52\x45\x89\x10\x98\xcb\xef\xe7\xf8\x43\x27\xa1\xaa\xd2\x7b\x84\x59\x39\xa4\xb6\xd7\x79\x35\xcf\x6e\x6b\x0d\x64\x1c\x21\xdb\x9f\xdf\xdc\x7a\x46\x8d\xe7\xa7\x1d\x1a\x4a\x50\xd2\x74\x25\x46\xb0\x60\xc2\x0c\xe3\x1a\xcc\x8f\x2a\x29\xb3\x29\xfd\xc4\x8b\x14\x94\xf0\x58\x11\xed\xc8\xe5\x52\xee\xc9\xaa\x58\x6c\x67\x45\xee\x91\xc2\x56\xbf\xa6\x46\x61\x3a\x16\x99\x47\x0e\xee\xcb\x12\x84\x0b\xf8\x7e\x40\x30\x19\x4f\x82\x51\xbb\x9c\x15\xeb\x34\xba\x27\x13\x7f\x27\x88\xfe\x5c\x1b\x8f\xc0\x54\x93\xa3\x30\xda\x39\x44\xe0\xf4\x32\xef\x80\x06\x44\xbb\x40\xe8\x0e\x0b\xbd\x20\x0e\x3b\x07\x50\xf7\x67\x79\x5b\x2f\x31\xaa\x61\xc9\x72\x22\xd2\x22\xdf\xc3\x83\x2b\x14\x29\x29\xbe\x01\xe9\x00\x3d\x5c\xcb\x4d\xa4\x59\xdd\x81\xd5\xae\x99\xf8\x3c\xe6\x0e\xde\xc3\x1f\xf0\x92\x02\x77\xcd\xa8\x78\x0d\x6d\xe1\x96\x91\xb4\x52\x71\x6b\xc7\xf7\xcb\xa8\x52\x4b\x94\xd6\x15\xf4\xd3\x2e\x56\x53\x78\x2c\x6f\x6b\x18\x94\xea\x96\x76\xb9\x22\xf1\x42\x51\xd0\x66\x06\xe5\x4c\x21\x96\x27\xe2\x83\xdc\x3a\x3b\x7c\xb1\x9c\x82\xed\x50\xf1\xa5\x06\x1c\x99\x6d\xbc\x10\xac\x17\xfd\x78\x1f\x2c\x77\x8d\xdb\x28\x32\xc2\xe5\xfb\xaf\x5d\x2a\xc8\x5a\x19\x33\xb7\x9c\xb6\x6f\x07\xed\x1e\x1b\x80\x10\xfa\x4a\xab\x7e\xe3\xd7\x18\xa3\xc9\xa1\x29\xfd\x16\x4e\x3c\x95\x0b\x3a\xd5\x21\x4b\x17\x57\x17\xe9\x18\x8b\xb0\x29\xc7\x37\x7b\x9b\xe6\x30\x98\xda\xc7\x33\xa3\x19\x35\xa8\x57\xad\x96\x60\xd0\xb0\x14\xd4\x0b\xaa\x98\xdd\xd5\x5c\x90\x6d\x7d\xfc\x58\x53\x36\xb5\x06\xeb\x10\x4a\x26\x05\xcf\xc3\x07\xf3\x27\xba\xd7\x28\x47\x4a\x9a\xf0\x5b\x63\x9b\x6b\x49\x6f\x42\x70\x22\xdf\xc4\x66\xf2\x79\xb5\x88\xc5\x3e\xa8\xf7\x39\x66\x6c\x8b\x60\x09\xbb\x8c\xca\x91\x54\x60\xed\xb4\x3f\x9f\xcd\x3e\x73\xaf\x64\x6d\x65\xcf\x63\x28\xf2\x99\x56\x40\x83\xbb\xb3\xc1\xd7\x6b\x1c\xa5\xd8\xf7\x84\x4d\x06\xb6\x57\x27\xc5\x2a\xb2\xb7\x30\x2b\x26\xb0\xd6\x9f\x46\xf4\x0d\x79\x9a\x02\x13\xcf\x5b\x08\x75\x65\xa3\x53\xa3\x0b\x58\xf5\xd4\xcc\x4c\x86\x55\x84\x63\x0e\xc2\x9e\x80\x25\xc0\xe5\xa8\x6f\xa3\xb7\x05\x00\x45\xa2\xdf\x23\x06\x66\x9d\xe9\xab\x33\x5f\x89\x49\xf4\x7c\xd4\x3f\xdf\xff\x47\xd2\xd1\xd2\x5d\x96\x62\xbf\xc4\x1f\x7a\xb4\xe8\x1d\xe7\xd6\x8f\x71\x2f\xc8\xea\xbd\x4a\xbd\xf0\xd0\x4b\xb5\x1d\xe6\x91\xa7\xbd\xc5\x15\x3a\x75\x34\x6e\xb1\x48\xd9\xe9\xa9\xf8\x87\xba\x8c\x33\xe1\xa9\x66\x72\x02\x1d\xeb\x2c\xda\x4c\xe8\x99\x91\xcd\x89\xfa\x9e\xaa\x82\x74\xe3\x0e\x73\x6e\xef\xc3\xd1\x60\xe0\x6d\x54\xa8\x71\xc9\x9a\xa7\x58\xb0\x37\x8a\x76\x46\xc2\xd8\xdd\xe8\x33\xd6\xc5\xee\xdd\xba\xdf\x37\xc7\x22\x06\x1c\xc6\x22\xa5\x4f\x30\xfa\x03\xf8\x32\x6a\x0f\x53\xcb\xc0\xc6\x5a\x7c\x17\x0f\xc3\x99\x59\x68\x8d\x9e\x98\x27\x33\xd5\x93\x11\xdc\x21\x15\xa5\xa6\xcc\xd3\x63\x18\x59\x9b\xea\x1d\xd3\x4c\xd8\x0b\x78\xde\x46\xb7\x25\xac\xfe\x57\x7c\xcf\x78\xa4\x99\xd2\x15\xcc\xc4\x55\xb8\xda\x04\xbb\xe2\x48\xbd\xa1\xad\xf6\x89\x80\xe3\x7a\x63\x2c\x16\x6c\xb8\x38\x7a\x66\x1e\xd8\xa5\x16\xae\x04\xe9\x0d\x30\xf0\xb7\x0c\x1d\xa4\x75\xe4\xbd\x3b\x50\xeb\x00\x98\xf9\x3d\x9f\x34\x6a\x38\x30\xab\x84\xf5\xc4\x47\x92\xf7\x37\xab\x1a\xc6\xfa\x47\xe3\x1a\xa6\x02\xb5\xee\xdb\x24\xbc\x71\x1a\x9a\xa3\xb1\x45\xa9\x50\x8c\x89\xf2\x53\x96\x75\x59\x91\xc3\xfc\xb4\x40\xff\xa4\x2f\xd0\xaf\xc8\xbd\x8c\xcb\x59\x86\x2e\xdb\xef\x75\xb1\xc2\x48\x39\xc8\x2c\xfd\x1e\xe2\xb1\x80\x4f\xd3\xa2\xae\x8b\x25\x16\x8f\xc5\x02\x4c\x3c\x02\xf8\x73\x03\xe9\x20\x5c\x8c\x43\x84\x03\xd8\x6f\x65\x23\xc7\xb3\x47\x30\x15\x34\x20\x6f\xbf\x30\xd6\xee\x2d\x1c\xae\xd8\xc7\x11\xf0\x96\x82\x3f\xe0\xd1\x44\x0b\xf8\xb3\xbe\xa0\xfd\x40\x64\xde\xef\x8b\x4d\x57\x0f\x29\x3f\x1e\x5f\x60\xda\xe2\xe0\x63\xf9\xbe\xf2\x04\xf7\xce\xc4\x96\x76\x85\x80\xaf\xc0\xa8\x75\x25\xa5\x2b\x12\xdc\xe8\x9e\xd0\x79\x64\xf7\x94\x71\xd9\x13\x5d\x6e\x0b\xa8\xa3\x18\xa8\xa1\x2b\xb0\x34\xaa\x9b\xe6\x46\x05\x26\x15\x88\x69\xe4\xe5\xb8\x69\x52\x6d\xdc\x53\xa5\x82\xef\x2f\xe3\x4e\x8f\x98\xff\x0d\x36\xf5\x2a\xbc\x9e\xe8\x1d\x93\xc4\x4b\xe5\x3a\x6d\xa2\xb4\x58\xc6\xfa\x14\x8b\x07\xb8\xa6\x3f\x37\x36\x62\x6f\xb2\x19\x30\xf4\xeb\x46\xad\x6c\xb0\xea\xe8\x39\x1d\x4a\x22\x37\xbd\x37\xc5\xcb\xe2\x5e\x5d\x4f\x90\xc0\xb1\xb0\x69\xff\x68\xed\xbc\x09\x33\xdc\xfd\xff\xea\xbf\x16\xb8\xd3\x72\x6a\xdb\x4d\xec\x95\x51\x77\xce\x8b\x7f\xcf\x7c\xc3\x3f\x4a\xa4\xb9\x35\xd1\x40\xcb\xc3\x09\xb6\xc9\x0e\xac\x7a\x5f\x3b\xe4\x06\xf6\x5c\xc7\x1f\xb2\x58\xac\x97\xf9\x7f\x94\x16\x1e\x25\x40\xe8\xb0\x6c\xff\xbb\x89\x7d\x06\x71\x97\x7c\x3a\xb7\xbc\xfb\x53\x78\xfb\x1f\x32\xa7\x75\xe0\xbe\x5f\xfe\x0e\x74\x48\x47\x34\x6b\x17\x1a\xcd\xe5\xeb\xe2\x42\x6a\xc0\xd1\xef\x83\x47\x2f\xfd\x6a\xa5\x79\x3a\x92\x38\xc3\x71\x8a\x02\x8e\x33\xee\x7e\xba\x9c\xb7\x0b\x98\xfa\xa6\x11\xe5\x21\xdb\x1b\x17\x26\xa6\x6e\xd0\x17\xa7\x4e\xc5\x7e\xf4\x0f\x9d\x59\xcb\xf2\xb4\x58\xae\xd6\x35\xc6\xb3\x52\xb9\xc1\x7d\x94\x53\xc3\xa8\x9a\x5b\x80\x18\xbc\xf2\xde\x21\xe5\x17\x44\x1c\x87\x82\x5a\x70\x07\x60\x33\x79\x69\x72\x14\x10\xd7\xc7\x55\xf8\x8f\x51\xbf\xce\x6e\x58\x85\x90\xca\x08\xf3\x51\xb4\x8c\x57\x76\x84\xf7\x8e\x80\xa2\x11\xf7\x7e\x2c\xb6\xc7\x22\x1b\x8b\x8f\xb0\x1f\x3e\x9c\x98\x47\xa8\x5d\x4f\x84\x79\x56\x0b\xfe\x85\x83\xba\x50\x23\x9d\x08\x46\x01\xdf\xba\x8e\x13\xbc\xde\x5b\x24\x1c\x6d\x48\xb4\xa7\x42\x04\xe3\x67\x60\x5b\x73\xc5\x62\x3b\x51\x85\x3c\xdd\x4a\xe6\xeb\xd7\x37\xfc\x85\xef\x5d\xdf\x44\x1f\xf1\xc6\x00\x95\xa8\x23\x8e\x76\x3b\x05\xea\x75\xf2\x98\x76\xde\x78\x4f\x68\xe7\x8d\xb7\x03\x4f\x62\xb8\x3f\x02\x53\x6f\x17\xb4\xee\xb7\x17\xda\xe5\x14\x86\xf2\x95\xd4\x91\x6b\x41\xdb\x40\xe4\x6c\x71\x76\x77\xd6\x23\x81\x89\x7c\xec\x89\x0b\x9d\x95\x1b\x2e\xc5\x60\x23\x8d\x9c\xa8\xbf\xf6\xac\xbf\x8e\xe2\x0a\xec\x42\x8c\xa4\xf2\xef\xfc\x5d\xc7\x37\x1c\xf7\x57\xc8\x4f\x6f\x54\x90\x41\x35\xb5\x6f\xbd\xd3\x4c\x3e\x61\x4c\xd3\x2f\x93\x62\x5f\x98\x71\xa9\xa0\x3d\x90\x7a\xa2\xe4\xd3\x07\xe2\x97\x71\x9c\x81\xcc\x7d\x39\x1e\x48\xbb\x1f\x46\x19\x7c\xd2\xee\xad\x1b\x7f\x74\x1b\xe3\x0b\x02\x98\x95\xab\xb7\x75\x6c\xf7\xd7\x9b\x51\x94\x2c\xe2\xe5\x2a\xc4\x47\x1f\x9c\x96\x49\x57\x2a\xca\xe1\xc4\xb6\x36\x24\x38\x9c\x8c\x1c\x71\xc1\x5f\x01\xd7\xc7\xd3\x48\x19\x16\x18\x96\x17\x63\x50\xb8\x82\xa3\x59\xea\x48\x94\xfb\xbb\x41\xe6\xd7\x77\xda\x37\x13\xa7\x71\xf2\x01\xa9\x97\xa7\x3e\x80\xb6\x97\xdf\x35\x1d\x9a\xa6\x55\xfc\xce\x4d\x3e\x71\x48\x0e\x86\x89\x77\xa2\xdd\x65\xb4\xe8\xb0\x20\x2f\x5e\x55\xd2\xfd\xfe\x40\x1b\x73\xa7\x93\x3f\x10\x07\x6a\xd9\x33\x5a\x7c\x1a\x2f\x51\xc3\x68\xbe\xb3\x8c\x26\x8e\xe7\x82\xe0\x4c\x47\xdd\x56\x90\x9d\x29\x74\x13\x81\xab\x85\x33\xec\x89\x8c\x7d\x44\x0c\x7a\xcf\xef\x1d\x4e\x76\xbc\x02\xe0\x8d\xf7\x68\x63\xaa\xdb\x91\xdd\xd8\xd5\x11\x8e\xfa\x7c\xd9\x6e\x20\xf7\x90\xbd\xe7\x19\x02\x8d\xc5\x47\xc0\xe2\x63\xdf\x09\x7d\x5f\x23\x5e\xf6\x80\xbe\xd6\x04\x2f\x5e\x98\xb2\xad\x2e\xfb\x5e\x24\x61\x13\x70\x24\x8e\x29\x89\xc1\x1d\xaf\xf7\xb6\xfe\xca\xd5\x40\xfe\xf1\x39\x0a\xb0\x60\x07\x3b\x22\xea\x4b\x60\x0d\x6c\xb5\xd9\x9d\xec\x90\x3d\xf3\xf4\x25\xe2\xb9\xd2\xcf\x70\xf7\xf4\xcc\x66\xec\x27\x77\xbe\xf1\x3b\xb7\x17\x40\xf5\x63\x42\x4c\x12\x00\xbc\x19\x35\xf4\xe5\xc0\xbb\x03\x03\xa4\xe8\x47\xd4\xfc\xb2\x04\x8f\xd1\xd8\x14\x71\x21\x68\xa1\xa6\x57\x59\x9a\x72\x7b\xd4\x50\x3e\x1d\xed\xda\x21\x0e\x7a\x1c\xe0\x1b\xaf\x68\xdb\x14\x53\x1b\xcc\x6c\xdc\x57\x3f\x32\x71\xcc\xee\x18\xa6\xb4\x3a\xb2\x11\xda\xf6\x59\xc1\x24\xce\x6e\x9a\x41\x6e\xd2\x1a\xcc\xde\x3e\xc5\xe8\x38\x31\x7f\x48\x37\xfa\xfd\xfc\xc1\x30\x79\xaf\x8b\xe3\xb0\x93\x07\xec\xe2\xa8\xfb\x2c\xff\x7e\x07\x43\x1b\x2d\xbb\x79\xfa\xef\x62\x29\xff\xee\xd9\x27\x32\xd5\xf8\x78\x9c\xa8\xb6\x2a\x16\xc5\x6c\xab\xaf\x03\x32\xb3\x9b\x4e\x17\x97\xa7\x7e\x24\x13\x08\x61\x02\xbd\x2f\x67\xc0\xe2\x37\x32\x4e\xb7\x2a\x06\x62\x7e\x89\x69\x7f\x15\xe7\x18\xb5\x33\xbf\x69\xc4\x75\xee\x18\xad\x4a\x33\x90\x53\xf3\xe0\x8e\x06\x66\xd0\xf6\xa3\x2c\xdd\x01\xdb\x48\xab\xc4\xf2\xb6\x0b\x49\x02\x6b\x0b\xf7\xd3\xaf\x89\x94\x69\x2b\x33\x18\x9b\x37\xc2\xb7\xd0\xd6\xc0\x51\x43\xf5\x23\x4d\x7b\x9f\x6b\x4a\xee\x4f\xeb\x7c\x0f\x35\x0b\xfe\x46\x62\x2b\x32\xe4\x42\xe2\x85\xf8\x34\xe5\xa7\x7b\xf6\x58\x01\xed\x99\x64\x8f\x3d\x8f\x44\x7b\xda\x5d\xed\x85\x36\x58\xf5\x83\x6a\xd8\xc8\x41\xc0\xfe\x2e\x15\x57\x35\x06\xb5\x7c\xe1\x6a\x77\x14\x5b\xf7\xa0\xa9\xb0\xe3\x07\xad\x34\xa9\x3c\xea\x3f\x85\x5c\x3b\x68\xd0\x40\x7e\x88\xb8\x9f\x48\xae\x26\x3d\x1a\x23\x36\xa9\xd9\x45\x2e\xa5\x3d\x5a\x71\x8d\x56\x30\x32\x90\xe0\x73\x95\xb9\xac\x03\x43\xe4\x06\xee\xff\x46\xda\x3d\x41\xda\xba\xc8\xfc\x09\xb4\x1b\x12\x35\x4d\x57\x0e\x02\x34\x0e\x54\x6c\x22\xaf\xab\xdc\x8c\xb2\xf8\xb9\x98\xd1\x95\x77\xa2\xca\x7d\x96\xa7\xb0\x13\xdb\x8b\x25\xa5\xbc\x15\xf8\x33\xee\x80\x63\x96\x07\x7e\x4b\xba\x66\x71\x3a\x97\xc9\x87\x97\xaf\xcf\x5f\xd2\x8f\xd4\xa9\x6e\x2a\x59\xd3\x49\x18\xb8\xcd\x1d\x74\x7f\xea\xcf\xe5\x99\xdf\xec\x92\x65\x59\x94\xc7\xed\x70\x32\xfe\xd3\xd3\x30\x96\xc9\x33\x6b\x9f\x3c\xe0\xe9\x8c\xbe\x92\xf3\x45\x98\x16\xc9\x9a\xcf\xa8\x4a\x52\x90\x8d\xfe\x30\x82\x7c\x05\xb8\x67\x09\x5f\x88\x8a\x51\x77\x9b\x1f\xfb\x73\x35\x39\x1f\x84\x09\xf7\x57\xbb\x1a\xaa\xd7\x1c\x95\x29\x86\xe2\x8f\x98\x91\xf4\x54\xd9\xc7\x78\x0a\x06\x31\xb7\xe2\x1c\xd1\x0a\xe6\xab\x7f\xdc\x6d\x99\xe5\xf4\x3a\x03\x5e\x3c\x98\x70\x11\x35\x92\xc7\xf6\x6a\x0a\xfd\xe4\xdc\x3a\x1b\xb9\xee\xcb\xe6\xc5\x3a\xd3\x6f\xfd\x72\xd2\x39\x75\x13\x7a\x77\xe6\xb6\x2d\x20\xfe\x31\x56\x1f\x0a\x00\x1c\x38\xb7\xe6\x36\x56\x4f\x7d\x7c\xa1\xbc\x23\x4e\x9a\x02\x03\xe6\x9e\x07\xdb\xdf\x78\xe0\x47\x03\xa0\x78\xcd\x60\x72\xf4\xdd\x77\xdf\xe9\x16\x5f\xb0\x87\x06\xc3\x46\x78\x1e\x9c\xe5\x33\x90\xaa\xb1\x99\x75\x96\x6e\xc6\x59\x2d\x97\x2e\xef\x7d\xd8\x48\xfe\x13\xa1\x60\xdd\xe3\x9a\x63\x9f\x66\x6f\xbc\xfd\x6a\x6f\xb5\xd9\x73\x6c\xe8\x9e\x46\x8c\x56\xc8\x53\xdc\xbf\x3d\x1a\xf9\xed\x1e\x9c\xb4\x21\xa3\xd6\x07\xd6\xea\x80\x96\xf3\x4d\x0b\xb5\x9d\x9a\x5d\xd4\x6c\x97\xbc\x89\x36\xc1\x3b\xee\x38\x61\x9f\x9d\x4b\x12\xc4\x1e\xfe\xff\x7f\x03\x00\x00\xff\xff\x57\xb6\xef\xe6\x87\x8d\x00\x00")
+var _staticsJsSkydiveJs = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x00\xff\xcc\x7d\x7f\x73\x1b\x37\xb2\xe0\xff\xfc\x14\x9d\x49\xee\x69\x18\x53\x23\x4a\x7e\xce\x4b\xc8\xe3\xa6\x14\xc9\x49\x74\x97\x48\x3e\xcb\xd9\xd4\x2b\x95\xca\x05\xce\x80\xe4\xc4\xc3\x19\xee\x0c\x28\x92\xf6\xea\xbb\x5f\x75\xe3\xf7\xfc\x20\x25\x27\xbb\x77\xef\xed\xae\x45\xa0\xd1\xdd\xe8\x6e\x34\x80\x46\x03\x73\xf2\x75\x0f\xbe\x86\x8b\x62\xb5\x2b\xd3\xf9\x42\x40\x78\xd1\x87\xb3\xe1\xe9\x37\xf0\x96\x27\xf0\x33\x13\x03\xb8\xca\xe3\xa8\x07\x04\xf6\x4b\x1a\xf3\xbc\xe2\x09\x88\x02\xc4\x82\xc3\xf9\x8a\xc5\x0b\x0e\xb7\xc5\x4c\x6c\x58\xc9\xe1\xc7\x62\x9d\x27\x4c\xa4\x45\x0e\xe1\xf9\xed\x8f\x7d\x58\xe7\x09\x2f\xa1\xc8\x39\xb6\x2e\x4a\x58\x16\x25\x87\xb8\xc8\x45\x99\x4e\xd7\xa2\x28\x21\x93\x18\x81\xcd\x4b\xce\x97\x3c\x17\x55\x04\x70\xcb\x39\xa1\xbf\xbe\x79\x77\x75\xf1\x1a\x66\x69\x46\xed\x93\xb4\x92\xed\x78\x02\x9b\x54\x2c\x40\x2c\xd2\x0a\x36\x45\xf9\x01\x66\x45\x09\x2c\x49\x52\x24\xcd\x32\x48\xf3\x59\x51\x2e\x89\x11\x6c\x58\xf2\x39\x2b\x93\x34\x9f\x43\x6c\xfa\x59\x6c\x72\x5e\x56\x8b\x74\x15\x01\xbc\xc3\xae\xdc\xfe\xa8\x99\xa9\x24\x62\x4d\x56\x14\xb0\x2b\xd6\xaa\x2b\x4e\xaf\x95\x30\x06\xf0\x77\x5e\x56\xd8\xe5\xb3\x68\x08\xa1\x58\x10\xaf\x81\xaa\x0d\xfa\x63\x6a\xbd\x64\x3b\xc8\x0b\x01\xeb\x8a\x5b\xec\xc0\xb7\x31\x5f\x09\x48\x73\x88\x8b\xe5\x2a\x4b\x59\x1e\x53\x6b\xd5\x3b\x43\x23\x02\xf8\x6f\x85\xa4\x98\x0a\x96\xe6\xc0\xa8\x2b\x50\xcc\x5c\x30\x60\x42\x29\x0a\x16\x42\xac\x46\x27\x27\x9b\xcd\x26\x62\xa4\xa4\xa8\x28\xe7\x27\xba\x83\x27\xbf\x5c\x5d\xbc\xbe\xbe\x7d\x7d\x7c\x16\x0d\x55\x8b\xdf\xf2\x8c\x57\x15\x94\xfc\x1f\xeb\xb4\xe4\x09\x4c\x77\xc0\x56\xab\x2c\x8d\xd9\x34\xe3\x90\xb1\x0d\xa0\x88\x51\x4b\xa4\xfd\x34\x87\x4d\x99\x8a\x34\x9f\x0f\x90\xe1\x4a\x5b\x80\xab\x23\x2b\x31\xcd\x5f\x5a\x79\x00\x45\x0e\x2c\xc7\xe6\xc1\xf9\x2d\x5c\xdd\x06\xf0\xc3\xf9\xed\xd5\xed\x00\x7e\xbf\x7a\xf7\xf3\xcd\x6f\xef\xe0\xf7\xf3\xb7\x6f\xcf\xaf\xdf\x5d\xbd\xbe\x85\x9b\xb7\x70\x71\x73\x7d\x79\xf5\xee\xea\xe6\xfa\x16\x6e\x7e\x84\xf3\xeb\xff\xc6\x96\xff\xfb\xea\xfa\x72\x00\x3c\x15\x0b\x5e\x02\xdf\xae\x4a\xec\x44\x51\x42\x8a\xe2\xe4\x89\x63\x4c\x9a\x07\x34\x15\xa5\xa4\x6a\xc5\xe3\x74\x96\xc6\x90\xb1\x7c\xbe\x66\x73\x0e\xf3\xe2\x81\x97\x39\x5a\xca\x8a\x97\xcb\xb4\x42\xbd\x56\xc0\xf2\x04\xb2\x74\x99\x0a\xb2\xa8\x0a\xe9\x36\xfa\x86\x43\xe4\xa4\xd7\x7b\x60\x25\x54\x9b\x54\xc4\x8b\xab\xe5\x1c\x26\x70\x54\x61\xa3\xb8\x3a\x49\x97\xf3\x13\x59\x11\xad\xf2\xf9\xd1\x98\x20\x57\x45\x29\x5a\xe0\xb0\xd8\x81\x4a\x73\x31\x6b\x81\xc2\x62\x07\xea\x81\x8b\x36\x9a\x58\xec\x40\xe5\x55\x0b\x4c\x5e\x39\x10\xd3\x32\x4d\xe6\xbc\x05\x4a\x56\x38\x90\x49\x11\x7f\xe0\x65\x0b\xa4\xac\x70\x20\x73\xbe\x16\x65\x91\xb7\x80\x16\x2b\x9e\x57\x82\xc5\x1f\x1c\xe8\x65\x9a\xaf\xab\x3a\x20\x15\x1e\x17\x6b\x91\xa5\x39\x3f\x3e\xfd\xc6\x81\x5f\x65\x4d\x70\x2c\xab\x41\x95\xc5\x94\x5f\x17\x09\xbf\xca\x93\x34\x66\xa2\x28\x1b\x24\x78\x92\xb2\xe3\x92\xc7\x45\x99\xa8\x86\x84\x1f\x1b\xc1\x04\x66\xeb\x3c\x46\xfd\x87\x57\x97\x7d\xf8\xd4\x03\x1a\xc7\xd1\xd5\x25\x4c\xe0\xea\x72\xac\x7f\xff\x5c\x54\x02\x11\x1f\x99\x92\x5f\xb9\x60\x09\x13\x0c\x26\xf0\xe9\xd1\x94\xbe\x4e\xe6\xbc\xf2\x8b\xfe\x9e\x56\x29\x0e\xb6\x09\x88\x72\xcd\x4d\xf1\x45\x91\x65\x6c\x85\x5e\x77\x02\x33\x96\x55\x7c\xdc\x7b\x24\xbe\x58\xc6\x4b\xa1\x71\xf4\x90\xcb\x68\x55\x16\xa2\x10\xbb\x15\x8f\xde\xed\x56\x1e\xd3\x92\xe5\x74\x06\x61\x80\x55\x01\x3a\x1d\x8f\xbd\x7e\x0f\x00\xa0\xe4\x62\x5d\xd6\x6a\xee\x64\x8b\xfb\x71\xcf\xd4\x07\x01\x32\x51\xa7\x79\xcd\x96\x5d\x34\xb1\xea\x79\x34\xa9\xc5\x61\x9a\x57\xd5\x05\x5b\x89\x75\xc9\x6f\xf2\x26\x69\xdd\xf2\x56\x30\xc1\xa3\x1f\xb3\x62\xa3\x80\x9b\xac\xc0\x7f\xfc\x47\x9d\x83\x66\xab\x7b\x98\x4c\x20\xb8\xb9\xde\xcf\xc9\x79\x96\x15\x1b\x9e\x34\xd9\x91\x4a\xa3\x4a\x94\x28\xaa\xee\x2e\x48\xf8\x43\x1a\xf3\x60\x00\x01\x0e\x55\xfc\xb7\x78\xa8\xe4\x58\xc3\x1f\x69\x2e\x78\x99\xb3\x0c\xff\x16\xeb\x1c\xff\x51\x95\xae\x6c\x5c\xac\x51\x9a\x27\x7c\x7b\x33\x0b\x6b\xdd\x41\x92\xc1\x7d\x1f\xfe\x36\x81\x61\x1b\xff\x73\x2e\xd0\x78\xdf\xf2\x8c\x89\xf4\x81\xbf\x61\x62\xe1\x76\x61\xc5\xc4\x62\x00\x0f\x69\x95\x0a\x9e\xa8\xfe\xc8\x1f\x77\x6a\x20\xdc\x1b\xcb\xed\x01\x20\x38\x4c\xe8\x9f\xa8\xc2\x79\x27\xec\x9b\xf2\x68\xb5\xae\x16\xc4\x5e\x7f\xac\x0c\x04\x7f\x44\xc8\x61\xd8\x27\x19\x2f\x8a\x4a\x04\x9e\x79\x20\x26\xc2\x80\x52\xac\x16\x45\x29\x38\x8d\xb4\x3b\x92\x03\x7a\xf5\x10\x6b\xb8\xd1\x2c\x8d\x30\xc9\xa8\x14\x3d\x4f\xe6\x68\x9e\xb6\xf2\x8e\x53\x5b\xc9\x01\xd6\x46\xb2\xf3\x45\xae\x38\xf9\x62\x02\x81\x59\x28\x28\x76\x80\xd6\x2f\x69\xae\xfa\x29\x51\xe7\x3c\x9d\x2f\xa6\x45\x59\x43\xf7\x86\x95\x3c\x17\xe8\x23\xbe\x50\x74\xaf\x2e\xd1\xd0\xbe\xa8\x57\xa7\xb9\x91\xac\xa6\xa2\x51\xc2\x04\x1c\xe0\x71\xcf\xa7\x70\xb1\x48\xb3\xa4\x93\x80\xa9\x7d\x02\x7e\x82\x75\xd0\xa3\x4d\x17\x33\x0b\x86\xb2\xc0\x59\x6f\x96\xe6\x3c\x09\xb4\x5c\x95\x3a\xd6\x53\x98\x18\xd0\x36\x4b\xaa\x99\xcf\x58\x35\x46\x49\x55\xeb\x69\x94\xf1\x7c\x2e\x16\xf0\x37\x18\x22\xf7\xa1\x56\xaf\x2e\x9f\x4c\x60\x08\xff\xfc\x27\x38\xa0\xff\x13\x6a\x40\xa6\x63\xe0\x5a\x47\xb5\x9e\x4a\x5a\x8f\x3d\xfc\xaf\x1d\x31\x1a\xa6\x6d\x24\xfc\xb4\x7f\x24\xc8\xbe\xe7\x45\x42\x0e\x9c\xd4\xda\xd6\xe3\xbb\xfb\x01\x7c\x7a\x34\x16\x4e\xf0\x9a\x7b\xec\x90\x67\xdd\x41\x60\x6c\x5b\x8d\x9c\x20\xd0\x66\x9d\xa2\x49\xcb\xe6\x25\x7f\xe0\x65\xc5\xc3\xbe\x6b\xd7\x58\x85\xe2\x47\x88\xbb\xf4\xde\xd1\x21\xa2\xd2\x24\xff\xa6\x29\xaa\xb1\xf9\x62\x02\xc1\x49\xa0\x80\x75\x09\xa2\x8a\xd0\xf3\x86\x7d\x78\x01\xc1\x1d\x8e\x83\x49\x00\x2f\x08\xb9\x1e\x9f\x2f\x20\xb8\x0f\xc6\x35\x79\x22\x06\x92\x25\x72\x84\x43\xef\x4f\xcc\x98\x72\x5c\xf8\x65\x64\x9e\x7e\x91\x76\x6d\x6a\x06\xec\x98\x45\x1f\x7b\x3d\x64\xe7\xdf\x3d\x35\xd6\x68\xba\x8e\xa5\x83\xb6\x0b\xf2\x3c\x1e\xbc\x96\x2d\xbc\xa0\x4a\x7e\x2a\xd9\x6a\xd1\xa9\x93\xeb\x22\xa9\xaf\x46\xdc\x05\xca\xe3\xb8\xd7\x23\x04\x4e\x8f\xae\xf9\xa6\xb9\x30\x1a\x00\x3a\x6e\x3b\xdb\x69\xd3\xe4\x1b\x40\x60\x24\x3a\x56\x63\x27\xd2\x1c\x21\x35\x53\xa8\x8c\x01\xb1\x8c\x7b\x1e\x77\x77\x57\x97\xf7\xca\xca\xc7\x8e\xdd\xc9\xdf\x8f\x4d\xfe\x7e\xe2\xa2\x6b\xe1\xa6\x9a\xfa\xb8\xbb\x90\x74\xd9\xb2\x8b\x04\x61\xba\x91\x5c\xf3\x4d\x13\xc9\x00\x56\x64\xe5\x03\x88\xd1\xb2\xeb\x82\x53\x73\x55\xce\x37\x80\x6d\xb5\xe0\x9c\x99\x80\x26\x57\xc4\x60\xca\xf5\x10\x21\x84\xa6\xb4\x2e\x66\x2a\x6c\x15\xb3\xe9\x85\x9a\x12\xd4\x7c\x8d\x34\x5a\xea\x40\x32\xde\x52\x63\x85\x83\xb4\x5a\x45\x72\xc9\xb3\xba\x72\x50\x91\xb2\xff\xbe\xd3\xf3\xe7\x71\x62\xf4\x92\x67\x58\x18\x3a\x5c\xa7\xf7\x7d\xe3\x91\x12\x9e\x71\xc1\x5d\xd3\x21\x3c\x5d\xea\x51\xd8\x5c\x5e\x90\x6f\x49\x51\xe1\x72\xe4\xae\x08\x52\x09\xa1\xf4\x81\x2e\x1c\xa1\xb4\xc0\x38\x2c\xdb\xda\x16\xa6\xae\xf2\x54\xfc\x58\x16\xcb\xdb\x5d\x1e\xff\xca\xab\x8a\xf9\x0c\x2e\xab\xb9\xb5\x15\xdc\x54\x2d\xab\x79\x74\x33\xfd\x63\xdc\x73\xd7\x42\x34\x71\xcc\xa5\x0c\xbc\x09\x03\x26\xba\xd8\xce\x17\xce\x70\x25\x26\xd5\xf8\x0e\xf3\x48\xd9\x9e\x72\x53\xda\xed\x90\x8b\xca\xf5\x94\x82\x2d\x8d\x47\x42\xc3\xbd\xb3\x80\x6a\x91\xe5\x8e\xef\xfc\x2e\x40\x13\x94\x8e\xf3\xb1\x8d\xe9\xe6\xea\x8d\x98\xd6\xda\x76\x98\x5e\xe9\xe1\x40\x6c\xab\x61\x1f\xf2\xbb\x40\x4e\x23\xc1\xbd\xe2\x1e\x91\xc7\x6a\x88\xd4\x41\x49\x6b\x04\xd9\xba\x5a\x54\x23\x38\xe4\x51\x63\xe0\xea\x26\x4d\xd9\x70\x2d\x1b\x52\xb4\xae\x82\x09\xf0\xa6\x6c\xdc\x41\xc9\x7d\xd9\x28\xff\x8d\x45\xbf\xb0\x5d\xb1\x16\xae\x1d\x20\x3b\x73\x34\x9d\x01\x54\x0f\xca\x24\x88\xe3\xdf\xd3\x84\x56\x11\xdf\x7c\x3b\x34\x1e\xfd\x67\x5c\x9f\x09\x5d\xa8\x4b\xe7\xca\x3f\xd0\xbf\x06\x76\xb1\xce\xb2\x9b\xd9\xac\xe2\x08\x7f\x76\x66\xca\x79\x26\xa3\x74\x6a\x62\x50\x16\xf8\x1e\xeb\x94\xb0\x2c\xe6\x59\x51\xc6\x28\xc2\xe4\x65\x94\x11\xe7\xb2\x24\x44\xb9\x44\x55\xfa\x91\x87\x77\x96\xd7\x81\xcb\xe3\x3d\x81\xc4\x0b\x56\xce\x79\x78\xfc\xdd\x90\x56\x2e\x51\x96\xe6\x1f\x2e\xd3\x4a\x60\x94\x2c\x7c\x25\xcb\xe6\x25\x7b\x48\xc5\x2e\x1c\x46\x2f\x5f\x51\x41\x91\x87\x81\x48\xe3\x0f\xc1\xc0\x4a\x49\x8d\x65\x90\x7c\x46\xef\xd2\xf8\x43\xc8\xc9\x2a\x1e\xfb\x96\x5d\x5c\xd6\xb3\x34\xe7\xb8\x22\xae\x1e\xe6\x11\x5b\xad\x78\x9e\x84\x41\xf5\x30\xa7\xa5\x7f\xc4\x84\x28\xc3\x60\x83\x92\x0d\x14\xbb\xc4\xba\x53\xb9\x20\x11\xeb\x5a\xd9\x19\xa7\x7a\x55\xd0\x76\xee\x98\x3f\xa0\x0c\x71\x2f\xc7\xb2\xcc\x45\xfe\x90\xf2\xcd\x0f\xc5\x16\x6b\x86\x30\x04\x5c\x79\x59\x3a\xb8\x22\xb3\x45\x0a\x79\x0b\xff\x86\xf3\x92\xc7\xe2\xaf\x62\xbd\x44\xa6\x4e\x87\x4e\x49\x9c\xb1\xaa\x0a\x06\xce\x5e\x2d\xaa\xc4\x2e\xe3\x61\x10\xaf\xcb\xaa\x28\x83\x41\xb0\x2c\x1e\xb8\xac\x89\x59\x96\x85\xc9\xcb\x68\xca\x17\xec\x21\x2d\xca\xe8\x63\x51\x2c\xc3\x3e\xa9\x0b\xff\x74\xd5\xe5\x6b\xeb\x2d\xaf\x62\x96\xf1\x50\xe9\x6b\x6f\x87\x05\xdf\x7a\x1d\x46\x9e\xcf\x5c\x9e\x77\xc1\x00\x5e\xbe\x6a\xeb\xc4\xbc\x2c\xd6\x2b\xd9\x16\xb1\xc8\x09\x57\x53\x42\xb5\xc0\xa4\x83\xea\xd1\xfc\xc8\x01\x4d\x4a\x36\xd7\xa0\x64\xee\x51\x25\x8a\x55\xd8\xa7\x8a\xd0\x98\x28\xfe\xaa\x04\x2b\x85\xdb\x71\xb5\xad\xc6\xbe\x27\x2f\x23\x32\x92\xa8\x2a\xd6\x65\xcc\x5f\xcb\xbf\x45\xb1\x7a\x53\x16\x2b\x36\xa7\x40\xa4\x16\x89\x25\x8e\xa3\xf6\x27\x4d\x1d\x99\x36\x92\x51\x26\x8c\xa4\xe3\xac\x36\x3c\x34\x55\x43\x73\x85\xdb\x8c\x5c\x5c\xf2\x19\x5b\x67\xa2\x49\xc6\xdb\xfa\xc8\x4e\x52\x91\x84\xa4\x52\x1c\xab\x35\x10\x2a\x52\x51\x00\xf4\xc5\xe8\x4a\x3a\x99\x35\x88\xd4\x94\x44\xc0\x51\xc5\x33\x1e\x8b\xf3\x2c\x0b\x03\xaa\x70\xe0\x10\x7b\x2b\x1c\x56\x20\xdc\x63\xaf\x67\x7d\xa8\x33\xd3\x2a\xfb\x72\xbd\xaa\x9d\x5a\x45\xc9\x72\xec\x86\x11\x0d\x15\x64\x4c\xd0\x02\x08\x21\x74\x63\x03\x41\x05\x56\x56\x52\x0b\x64\x6b\xd4\x16\x0f\x26\xd0\xde\x0c\xa2\x90\x46\x34\xfe\xc2\xf1\xdd\xc7\x5f\x01\x10\x12\xaa\xa1\xbf\xb0\xac\xbf\xaf\x13\xb7\x5c\xbc\x29\x2a\x3a\xfe\x70\x3b\xb2\x1d\xc0\xce\x99\x14\x1c\xd3\x35\xc3\x63\xdb\x57\x3f\x70\x68\xec\xf6\x90\xc0\xa9\xf2\x92\x0b\x96\x66\x55\xfb\xb2\x0d\xa5\xf1\x47\x55\x60\x18\xee\x7f\xdd\xde\x5c\x47\x78\x10\x90\xcf\xd3\xd9\x2e\xf4\x16\x07\xa4\xb2\xaf\xc2\xe0\xcb\xa5\x9e\x03\xfb\x11\xc2\xff\x3d\xe5\x9b\x10\xdb\x5b\x0b\xa1\x29\x49\xed\xbe\x09\x47\xcb\xc6\x3c\x34\x1b\x6c\x0b\x8d\xa1\x0a\x13\xa1\xf8\x2a\x62\x7f\xb0\x6d\x68\x06\x16\x13\x0c\xf7\x49\x23\x08\x90\x58\x30\x50\xe5\xeb\x32\x1b\xc1\xd1\x09\x5b\xa5\x27\xb3\xac\xd8\x9c\x54\x9c\x95\xf1\xe2\xfb\x37\x3a\x6a\xfc\xdb\x6f\x57\x97\x93\x23\xbd\x13\xbe\xba\xd4\xed\xaa\x75\x1c\xf3\xaa\x1a\x59\x89\x50\x27\x15\x71\x80\x7d\x72\x31\xe2\x40\x30\x29\x14\xa4\x5d\xb5\x48\xc4\xc2\x1c\x49\x98\x23\x07\xe6\x48\x14\xf3\x79\xc6\x8f\x06\xf0\xd2\x80\x62\xbc\x43\x8e\x5a\xb5\xc0\xd2\x31\x88\x46\x9c\x32\x54\x91\x93\xaf\xc2\x20\x12\xa9\xc8\xf8\x71\x2c\xeb\x8f\xe5\x79\x45\xd0\x8f\xaa\x45\xb1\x91\x82\xe6\x59\xc5\x0f\x41\x2f\xd2\x44\x47\xfb\xbe\x0a\x83\xbb\x9c\x2d\xf9\xe4\xc8\x87\x3a\xba\x0f\xfa\xd1\xb4\x28\x44\x25\x4a\xb6\xba\xa5\x96\x61\x90\xf0\x4a\x94\xc5\x2e\xe8\x8f\x9f\xdb\x54\x4a\xbb\xc8\xe5\xcf\x8b\x05\xcb\xe7\xdc\x51\x09\xb9\xb3\x01\x60\xb0\xdf\xac\x05\x54\xf0\xc9\x2f\x6a\x98\xcb\x3e\x93\xa9\x99\x8d\x62\xf3\xc8\xad\xc6\xa6\xa3\xba\xda\x3f\x05\x64\x55\x68\xd8\xc1\xc8\x1a\xf9\x63\xdf\x6d\x89\x63\x95\xe7\x42\xd1\x55\x47\x71\xd8\x99\x13\xb4\x88\x31\xe0\xe2\xa8\xe2\x62\xb2\x16\xb3\xe3\x6f\x3d\x96\x96\x5c\x2c\x8a\x64\x04\x47\x6f\x6e\x6e\xdf\x39\xdc\x3c\x5a\xdb\x20\x35\xee\xef\x74\xb3\x63\x27\x68\xfd\x86\xdb\xbf\x98\xd7\xcb\xd7\xbf\xbc\x7e\xf7\xba\x9d\x5b\xf5\xaf\xde\x70\xab\xb3\x11\x15\xd2\xeb\x8f\xdb\x8d\xfb\x26\xb7\x41\xb2\x67\x99\x12\x1d\x4f\xe1\x58\xc2\x43\x98\x81\x3c\x71\x21\x5e\x3c\xa9\x7d\x1e\x4a\x42\xe6\xe1\xec\x74\xb7\xe7\x49\xd2\xbd\x43\xb6\xdd\xbd\x34\x81\x22\xbd\x32\x77\x03\x45\x66\x76\xd4\x95\x77\xaa\x95\x17\x49\x31\xd8\xf6\xc5\xdf\x6b\xb3\xbf\x0c\xe1\xe3\x9f\xce\xba\xe0\x2d\x4f\x4a\xb6\x09\xf7\x4c\x22\x7b\xf7\xfd\xc8\xc7\x17\xdd\xfd\x6a\x70\xe3\x6f\x19\x2d\x6f\x5a\xed\xe6\x5c\x41\x47\x46\x51\x5c\x13\x35\x95\xe8\x30\x8e\x09\x2a\x60\x69\x15\x55\x68\xbb\x3c\x4c\x07\x70\x6a\x0c\x70\x5a\x72\xf6\xc1\x8d\x22\xfb\xbb\xf9\x86\x6c\x9f\x23\x90\xf3\x24\xe9\x0e\x3e\x98\x28\xff\xb3\xd5\xac\x63\x0b\x6e\x4c\xc6\x60\x53\x71\x8c\xa7\x69\x1b\x97\x4f\x4a\xdb\x9f\xe4\x5a\x74\xe4\x46\xa1\x06\x20\x70\x93\x26\x54\x21\xed\xa3\x07\xf4\xb7\x2c\x79\xec\x8f\x9f\x2e\x8c\xbd\x91\x18\x64\xff\x8b\x6e\x71\x3c\xc5\x3a\xa8\x2f\x0d\xeb\xa0\xd2\xbb\xf4\xfe\x2e\x90\xfd\x0b\xb4\x9d\xb8\xc2\xa2\x63\x15\xd7\x5c\x6c\x2b\x29\x00\xbf\x95\x3e\x78\xe9\xdb\xa0\x15\x35\x68\xd8\x57\xa7\x31\x69\x0d\x3e\xc7\x98\x70\x63\xeb\x09\xcf\x2e\xcc\x3e\xc0\x04\x4e\xe1\x6b\xe0\x11\xcb\x56\x0b\xe6\xeb\x37\xe2\x2c\x5e\x84\xa6\x19\x6e\x43\x20\x51\x3b\x8f\x68\x07\xc7\x13\xf8\x30\x80\x24\x92\x1d\x8d\x76\x78\x50\xf0\x61\x0c\x8f\xce\x36\x6a\x7b\x5a\xdf\xc7\x28\x55\x58\x3c\x5b\xbf\xc5\xee\x70\x8b\x5d\x8d\xc6\x59\x77\x0b\xc5\x5a\x9d\xc6\xe1\x16\x44\xc3\x4a\x03\x9d\x80\x6a\x1c\x6f\xbb\x1b\xd7\xe8\xc4\xbb\x6e\x3a\xdd\x04\xdc\xed\x80\xd7\xd8\xb1\xe4\xfa\x3e\x21\x89\xb6\xb8\x17\x18\xc8\xbf\x77\xf8\x77\x3f\x70\xb6\x67\xcd\x60\x8c\x1a\x38\x66\x7b\x18\xf1\xe5\x4a\xec\xf4\x9a\xcf\x16\xe3\x4a\x25\xd4\x61\xb1\x8b\x22\x7f\xe0\xdb\x9f\xd7\x59\x56\x85\x7d\xbd\x41\x48\x5a\xf9\x34\x9c\x12\xd9\xe8\xb2\x64\x9b\x8b\x6c\x5d\x09\x5e\x86\x49\xdf\xac\x41\xbb\x2c\xf6\x22\x2d\xe3\x8c\xdf\xa6\x1f\xbd\x41\xaf\x90\xcb\x19\x35\x4c\xd4\xb9\x93\xa6\x18\xb3\x8a\xd3\x21\x39\xa6\xc9\x04\x23\x57\x5a\xa7\xdf\x8e\x7d\x10\x75\x54\xee\x01\x9d\x0d\x25\x50\x22\xb7\xb7\x3e\x82\x6f\xf6\xcf\xca\x38\x25\x5f\x60\xc8\xa0\x85\x5d\x94\xb3\x3e\x6c\x95\xa9\x19\xae\x4f\xc2\x50\x0f\x2f\x45\xa0\x3d\x71\x52\x4f\x34\x50\xc9\x05\x97\x37\xbf\x5f\x7b\xae\x18\x82\xa4\xd8\xe4\xf2\xa0\xee\x90\x44\xbc\xee\x5a\x04\xb6\x66\xdc\x29\x41\x0f\x9a\x24\xeb\xc2\x4e\x8b\x3c\x69\x00\x52\xa1\x07\xd5\x4e\xde\xa3\xed\x49\xdd\xc2\xa8\xe2\x60\xbf\xf8\x7f\x49\xf3\x0f\x5d\xe2\xe7\x72\xe6\x48\xa2\xe6\x84\xe7\xcc\x74\x35\xd1\xa2\xf7\xf3\x44\xeb\xc0\xfb\xd2\xa5\xe4\x8c\x3a\xd7\xd8\x1c\xa8\x66\x6f\xe7\x14\x95\x7d\x3d\x93\x03\xe1\x66\xc5\xe2\x54\xec\x3a\x8d\xcb\x9a\x0c\x76\x49\x59\x4c\xce\x45\x5e\x05\x78\x6e\xee\x02\xfc\xca\x72\x36\xe7\xa5\x84\xc9\xd7\x59\xe6\x75\x7c\x18\x0d\x9d\x63\xc2\x53\xfc\xd5\xc5\x19\x2e\x4f\xba\xf9\xf2\x02\xf0\xda\x73\x8f\x7b\x7e\xb4\x5d\x7b\x5b\xa3\x15\x75\xa8\xb4\xaf\x3b\xff\xfc\x27\xf1\x4b\x28\xf6\x01\x36\xbb\xf5\xc4\x7e\xe1\x50\x56\x42\x7a\x93\xc6\xa2\x68\xe9\x9c\x19\x6e\x2d\x62\xf5\xad\x43\x66\xbc\xd5\x2d\xdf\x24\xc8\xb9\x83\x44\xe5\xc2\xd5\x61\x6d\x8a\xdc\x7e\x43\x71\xd8\xbe\xc5\x68\xeb\xbf\x80\xed\x20\x78\x02\xbf\x81\x32\x68\x2b\xed\x00\x33\x58\xa6\x69\x96\x8a\xdd\x08\x16\x69\x92\xf0\x3c\xd8\xdb\x8d\x83\x62\x3f\xec\xf7\x0d\x71\x95\x49\xe9\x32\xae\xdc\x4e\x0d\xd0\xa4\x37\x8e\x9f\xe2\x3a\x4d\x2a\xa7\x0b\x2d\x0d\xaf\x06\x99\x57\x35\xa8\x36\x87\xa1\x72\x34\x0f\x79\xd6\x96\xce\x98\xd0\xdd\x01\x1b\x6b\xf7\x40\x2a\x83\xf4\xb0\x65\x51\x60\x82\xf2\xde\xba\x94\xa3\x66\x39\x6f\x9b\xed\x0e\xc1\x66\x9a\x65\x33\x1f\xa1\x93\xfc\x13\x28\x2b\x5f\xfe\x45\xbb\x03\x50\x69\x34\x92\x49\x93\x38\xe9\x81\xac\xb2\x75\xe5\xb0\x44\x79\xa5\xdd\x5c\xfd\xc4\x85\xdc\xe8\x74\x6f\x5b\xad\x0b\x6c\xd9\x77\x34\x4f\xb0\xdd\xd3\x7d\x53\x49\xc7\xb0\xba\x16\xc5\xa1\x36\x6e\x6a\x2a\xb2\xc7\xaf\xb2\x6e\x02\xc1\x8a\x61\xb0\x0d\x93\xa2\x4c\x11\x5a\x97\x12\x47\x23\x49\xad\xb6\xf9\xd3\xdb\xe0\x0e\xe8\x3a\x17\xde\x8e\xb1\x85\x19\x3a\x01\xf2\x78\x71\x75\x63\x64\xed\xe0\x52\x84\xcc\xd4\xe1\x55\xf9\x7e\x45\xcb\xb6\x4b\x45\xe7\x49\xf2\xae\xf8\xa9\x2c\xd6\xab\xba\x7e\xf0\x6c\xb4\x58\xaf\xd4\x3f\x4a\x03\xd8\x37\xdc\xdf\xe9\x30\x80\x0d\x1f\x23\x86\x34\xd7\xc0\xc4\x9f\xfc\xfb\x8e\xfe\xb9\x57\x49\x0e\xd8\xce\x0b\x85\x7a\x40\x78\x30\x7a\x75\x39\x22\xec\x8f\xdd\x4c\xdf\xca\xb3\x67\x62\xdb\x5b\xcd\xe4\x03\x70\x58\x57\x3c\x23\x7f\xf9\xe1\x0d\xbb\x37\x19\xeb\xb5\xbc\x35\xdf\x30\x37\xb1\x72\x95\xdc\xa7\x81\xbd\xd4\x3e\xd4\xe3\xaa\xc5\x4a\x1c\x42\xce\x1c\xee\x8c\x47\xed\x9a\x71\x29\xd2\xac\x75\x58\x26\xd6\xac\xda\x94\xae\x54\x13\x79\xa4\x8d\x62\x71\xb6\x4d\x9e\xb8\x14\xe9\x9a\xa0\xba\x65\xad\xce\xf9\xab\x27\x0a\x1b\xa5\x38\xd7\xa0\x9f\x1e\x5b\x06\xb5\x3d\x37\x6f\xc9\xad\x70\x52\x28\x1c\x10\x33\xc0\x9f\x14\xe4\xda\x37\x20\x9d\x48\x9d\xaa\x3c\x39\x81\xb8\xe4\x4c\x70\x60\x39\xa4\xa2\xe2\xd9\x4c\x76\xa8\x39\x50\xed\x44\xb7\x67\xb4\xb6\xab\x47\xb1\xec\xc8\xdb\xea\xd2\x57\x8f\x85\x77\x80\xfd\x31\x2d\x8b\xf7\xaa\xcc\xd9\x83\xba\x2a\xb3\x3a\x5a\xa8\x2a\x27\x0f\xc1\xa8\x4d\x1b\xbf\xa3\x77\x0c\x9c\x38\x8a\x94\xfb\x34\xc5\x9e\xa3\xbf\xb9\x72\x24\xf4\xaf\xca\xe9\x02\x70\x1a\xe6\xa6\x9d\x56\xbb\xa7\x78\x6a\x77\x97\xeb\x04\x15\xe9\x5b\xd2\xea\x9a\x5d\xa3\xd9\x56\xfc\xc7\xac\x60\x82\x2c\x3e\xda\xf6\x8d\xba\x1b\x0a\x57\x86\x42\x70\x2a\xa3\xb1\x09\xab\x41\x91\x7c\x86\xe9\x5c\xeb\x2c\x23\x96\x51\xb9\xa1\xfd\x35\x81\x3b\x9d\x04\x03\x90\xc9\x60\x9e\x8c\x56\x6e\xe1\xd8\xc6\x00\x64\xbe\x87\xd2\xf4\xae\x59\xf3\x19\x38\x5e\xd4\x6b\x3a\x71\xbc\xe8\xc4\x71\xfc\x17\xe0\x78\xd1\x85\xc3\xa4\x05\x1b\x8b\xe2\x2d\x49\xe5\xd2\x58\xa8\x5a\x2b\x5d\xc1\xaa\xc8\x28\x69\x7d\x04\xe8\xbb\x56\x4c\x2c\x46\x90\xbc\x8c\xe6\xbc\x58\x12\x45\xab\x89\xfe\x63\x63\x24\x28\x3c\xdd\x43\xc1\x89\xa8\xb4\x2c\x8a\x90\xbb\x78\x5d\x3e\xa8\x23\x68\xcc\x5b\xc1\x0b\x32\x21\x1a\x4b\x94\xe6\x82\x97\xab\x02\x8f\xab\xc3\x20\xa6\x2b\x70\x2c\x3b\x8e\xb3\xa2\xc2\x0c\x6e\x84\x10\x3c\xc7\x2b\x4e\x61\xf4\xed\xab\xbe\xbb\x73\x22\x94\x61\x12\x61\x67\x0e\x7b\xd6\x77\x7c\x2b\x5a\x78\xc3\xf3\x11\xdf\x15\x2a\x78\x0a\x93\xf4\x55\x9e\xb1\x9e\x92\x10\xda\xe6\x2a\xcb\x4c\x13\x83\x03\xff\x89\xaa\xf5\xb4\x12\x65\x38\x1c\xc0\xb7\x94\x84\x1c\x05\x2e\xcb\x08\xd2\xcd\xe9\xaf\xc5\xba\xe2\x37\x0f\xbc\xac\xaf\xe3\x14\xaf\x26\x59\x50\x1d\x71\x87\xc9\x9e\x6e\x4b\x64\xeb\xc6\x9a\x90\x70\x75\x35\xd2\xab\xd1\x6b\x2e\xae\x6f\xdb\x57\x92\x9f\xbf\x74\x34\x9e\xde\x46\x9f\x0f\x2c\xf1\x50\xe4\x37\xd3\x3f\x78\x2c\xa2\x0f\x7c\x57\xb9\xf7\x05\x08\x6d\x5f\xeb\x62\x32\x81\x53\xcd\x80\x4a\x54\x93\x60\x36\xd1\xba\xa5\xf0\x7b\x79\xc8\x05\x23\xe7\xbc\x4e\xb5\xae\xb5\xeb\x6a\xa1\x9a\x60\x17\xec\x4a\xfe\xe9\xc4\x1e\xf7\xee\x75\x8c\x32\xda\xad\x01\x85\x63\x12\x3a\xd4\x96\xea\x8d\x4c\x8a\xf1\x77\x13\x87\xa3\x72\xfe\x66\xd1\xbb\xd0\x45\x96\x10\x26\xd6\x25\x3c\x31\xcc\x2f\x0f\x02\xda\x27\xc5\xf6\x4c\x3c\x95\x1c\x63\x03\xfe\x36\xda\x8b\x55\xd5\x9e\x00\xb4\x89\xc6\x5f\x5d\xe2\x98\x3b\x96\x91\x67\x15\x3d\x97\xab\x67\xe7\x8c\x07\xb1\x45\x1c\xdd\x4e\xd8\x8f\xd2\xbc\xe2\xa5\x08\x03\x74\x48\x98\xf2\xa2\x52\x76\x9c\x44\xb1\x42\xc6\x95\xf6\x05\xc0\xdf\x9b\x8c\x59\x15\x84\xd2\x02\x6b\x49\xe2\x3a\x80\xc4\x44\x0f\x0d\x8a\x1a\xdf\xdb\x54\x84\xfd\xa8\xe4\x98\xb6\x16\xd6\x82\xf6\x5a\x7c\xf8\xb7\x23\x3e\xfc\xb9\x5f\x7c\xae\x8c\xf4\x3a\xe1\x35\x4a\xc8\xc3\xa8\x65\x56\xcb\xd7\xf2\xfb\x17\x58\x01\xb6\x26\x72\xb5\x77\xdb\xb5\x75\x4f\x78\xf5\x6c\x3d\x95\x9d\xe8\x24\xec\x99\x8c\x36\xab\x61\x64\x61\xbf\xa4\x9e\xa7\x14\x13\x51\x77\x59\xb3\xb8\x14\x8f\x49\x5a\xad\x32\xb6\xdb\x87\xee\x0b\xd7\x1f\x04\x79\x91\xf3\x00\x46\x10\x4c\xb3\x22\x56\xc1\xd7\x7e\x4f\xdd\x32\x20\xf1\x1b\x51\xc7\x14\x7a\x75\xe5\x5d\xea\x2c\x48\x7b\x3c\xd1\xa6\x0d\xb7\xe1\x73\x0d\xda\x8b\xf7\x7a\x5a\x41\xcd\x2e\x71\x82\xc1\xab\xc8\xad\x88\x24\x06\x6f\x46\xeb\xc0\xb0\x16\x07\x11\xac\x85\xd7\x7e\xdc\x2e\xa3\x74\xc9\xe6\x3c\x70\x0f\xe3\x70\xa4\x8f\x16\x25\x9f\x1d\xee\xab\x89\xf5\x79\x5c\x2a\x3c\xc1\x00\x8e\xbd\xb4\xd2\x5d\xa3\x44\xa7\xad\x9e\x0d\xdb\xd2\x55\xcf\x86\xb5\x4e\xff\xff\x2c\x36\x63\x3b\x14\x26\xf3\x04\x5a\x4f\xaf\x25\x39\x9c\x3d\x5b\x0e\xb2\xd4\xda\xe1\x30\xfa\xaf\xe7\x73\x47\xf9\x2a\x75\xee\x8e\xcf\x9e\xc6\xde\xe9\x59\x1b\x7b\xa7\x67\xcf\x65\xaf\x7d\x5c\x7a\x78\xe8\x8c\xf6\xf4\x3f\xdd\x12\xec\xf3\xe9\x37\x6d\x9d\x5a\xaa\x18\x78\xff\xb9\xd2\xb0\x0d\x4d\x1d\xd2\x75\xc9\x22\xd5\x6f\x5a\x64\x71\x36\x6c\x93\xc5\xd9\xb0\x43\x16\xdf\x75\xc9\xa2\x9e\xd8\x9c\x20\x03\x67\xae\x28\x12\x64\x21\x88\x5e\xbe\xe2\x4b\x27\x8b\xf9\xc0\xc8\x74\xd6\xef\xbe\x29\x9b\xdd\xd0\xa5\xbc\xce\xd1\x7a\x30\x6c\xdd\x3e\x82\x7a\x59\xb7\xb8\x6f\xa0\xbd\x4f\xe0\xce\x12\x0e\x34\x4c\x0e\xb7\xc4\x5e\xd0\x4c\x6b\x38\xa1\x8e\xd5\xa7\x4a\xa4\xd5\xaa\x37\x8b\x45\x56\xa4\xc9\x3e\x5f\x95\x44\xb4\x89\xab\x3b\xa8\xbd\x6d\xda\xce\xbc\x1d\x29\x3a\xd3\x18\x25\x24\x87\x47\xa8\x94\xa3\x56\xf5\xe8\x05\xf6\x41\xfd\xb4\x23\xa6\xb1\x1c\xd1\xb8\x3d\xea\x7f\xa6\x8f\xb6\xd1\xf7\x43\xdd\x90\xd4\xc8\x87\x7d\x36\xb5\xda\x51\xc3\xd3\x48\xaa\xa1\xf8\xd9\x44\xd5\x39\xd8\x93\x28\x4a\xff\xd3\x20\x49\x33\xfd\xb3\xa8\xd1\x39\x5d\x0b\x35\x7d\x3d\x80\x95\x42\x2d\xf7\x71\xd8\x35\x2f\xf9\x48\x11\x14\xa5\xb3\x53\xd5\x97\x76\xf0\x7e\x20\xdd\x67\x73\x87\x57\x51\x99\xfb\x39\xaa\x48\x63\xc0\x9b\x2d\xea\x4f\x53\xb7\x5e\x25\x4c\xf0\x0a\x4f\x02\xf5\x95\x5b\x5d\xb5\x69\xb9\x44\xb4\x68\xbd\x44\x54\x3d\xcc\x55\x00\x82\xd0\x5b\x96\x9f\x74\x8b\x66\xb3\xf7\x16\xcd\xa2\x7e\x8b\x06\x3d\xdd\x37\x8e\x0b\x3d\x52\xb7\x66\x8e\x06\x70\x84\xb7\x66\x8e\xf4\xad\x99\x8d\xba\x35\x73\x64\x8b\x14\x32\xda\xdb\xb7\x6c\xac\x6e\xca\x84\x97\x4d\x0d\xd8\xfd\xd5\x16\xe8\xf5\x04\x77\xb3\x8e\x81\x6d\x13\xc8\xc5\x1f\x66\xbf\x6e\x4b\xee\xb0\xfc\xde\x4d\xd3\x0f\xb7\x03\x18\xaa\x20\xd4\x16\x33\xaa\x1a\xc0\xfa\xce\xcf\xe9\xd0\xdf\x20\x6a\xad\x6c\x4d\x9d\x56\x41\xb7\x6c\x5b\xa0\xec\x55\xa3\x3f\x27\xb4\xf3\x24\x51\x17\xd7\x8c\xb8\xec\x55\xd6\x7a\xa7\x94\xc9\xda\x6d\x2d\xc1\x2a\x56\xd5\x45\x36\xcd\xa7\x33\x52\x3c\xc5\xa8\x89\x47\x8d\xb6\x3a\x85\x76\x26\x2f\x79\x56\x67\xd2\x86\x5d\xdc\xfc\x3b\x64\xc7\xcd\xe4\xec\xe8\x71\x3d\xf2\x63\x91\x69\x8b\x90\xdd\x1b\xf7\xbc\x88\xff\xcf\x4d\x53\x41\x33\x06\xa7\x85\x9e\x18\x95\x58\x6d\x3b\x3f\xfd\xbe\xd9\xc0\xe1\x1c\xc1\x65\x00\xda\x82\x69\xae\x75\xce\x6e\x87\x94\xba\x3b\xf6\x94\x6e\x38\x41\x91\x56\x9e\x34\x85\x7d\x4c\xec\xcd\x88\xed\x92\xae\xbd\x3f\xf9\x3c\xe9\x9a\x76\x4f\x93\xae\x01\x6f\x93\x2e\xc6\x28\x24\xab\x9d\xd2\x7d\x52\x76\xeb\x33\xa5\x6b\x79\xd2\x14\xf6\x31\xf1\xd4\x7b\xc5\x76\x40\xb6\x35\x21\x38\xdf\x0b\x5e\x5d\xb6\x9e\x8c\x59\x3f\xa8\xed\xaf\x0e\x42\x71\xf1\x03\xb8\xb0\x57\x35\x5c\xf6\x02\xb8\x03\xa2\x70\xb5\x75\xfc\x22\xe3\xac\x74\xbb\x5a\x0b\xb9\x1e\xa4\xa9\x85\xdb\x41\xf3\x59\xb2\xd0\xc3\xa0\x0e\xf2\x39\xb2\x90\xa5\x7f\x25\x77\x06\xe3\x3e\x1e\xdb\x64\xdc\x15\x98\x34\xa4\x17\x87\xe7\xc9\x7b\x27\x00\xaa\x22\xb8\x0d\x3a\x6f\xca\x02\xef\x5c\xd1\xc2\x67\x9f\x11\xab\xc0\x2c\xde\x8d\xc7\xd0\xac\x26\x27\x03\xb3\x38\x02\xde\xf2\x55\xb6\xab\x05\x67\xd1\x4e\x74\x92\x83\x2a\xeb\x1e\x01\xde\x05\x01\x07\x39\x4a\xea\x37\x5a\x58\xd9\xb4\x49\xe7\x90\xd0\x91\xa9\x5a\xe2\x87\xea\x02\xbf\xbd\x6c\xdf\xbc\x53\xaf\x41\x74\x91\x89\x91\xd7\xe2\xc6\xfb\x98\x3a\x4f\x92\x83\x2c\xe9\x9b\xff\x96\xa5\x81\xa1\x6d\x7c\xa5\x3e\x43\xb0\x57\xd9\x51\xb7\x0a\xcc\x1e\x55\x3e\xaf\x0f\xb5\x39\x6a\x5f\x47\x2e\x69\xd2\xfe\x13\xd2\x75\xd2\x20\x64\x3b\xff\x7d\x23\x05\xe5\x79\xdd\x8e\x11\xe2\x71\xeb\x8d\xef\x03\xfd\xc0\xa1\xdc\x66\x25\x3c\x99\x37\xfb\x81\xc0\x6d\xfd\xa8\xbf\x2e\xb0\x5f\xc2\x87\xad\x04\xe9\x34\xad\x44\x27\x84\xec\x13\xae\xcc\x34\x31\xa8\x1b\xef\x2d\xb4\xb7\xba\x70\x5f\x51\xe8\xea\xbf\x7e\x80\x41\x37\x6a\x79\x3f\xe5\x73\x2d\xf4\x79\xf2\xab\xcd\xf3\xfb\x84\xd8\x66\xa1\xcf\xd2\xac\x63\xa1\xb2\x5d\x87\x85\xba\xe4\xeb\x06\xda\x64\x56\x1b\xe8\x53\xba\xf1\x03\x26\x99\x9d\xe3\xdd\x43\xa7\x1f\xc6\x9b\xa7\xad\xf2\x24\xfc\x2d\x1e\x5a\xf7\x50\x3f\xd5\xe2\x50\x3c\xe0\xe7\xcf\x31\x99\x7e\x9f\x9f\x6f\x3d\xf8\x52\x53\x9d\xa3\xd1\xb7\x9c\x55\x45\x8e\x11\x2d\x75\x28\x43\x19\xf9\x3a\x45\x42\x41\x8d\x7b\x8d\x91\x82\x47\x7c\x5c\xbc\x4b\x97\x18\x19\xb6\x31\x1b\xbc\xe6\xa1\xb6\x0e\x16\xd1\x18\xde\xfb\xad\xe1\x11\xe3\xba\xc3\x61\xc7\x94\x79\x8b\x6f\x00\xfc\x92\x3e\x28\x3f\xe0\x76\xcf\x59\x88\xd5\xf6\xe8\xb8\xeb\xff\x9d\x4f\x6f\x69\xcf\x1e\x06\x9b\x6a\x74\x72\x82\xe7\x72\x59\x21\xaf\x5d\xd2\x5c\x8a\xa7\x75\x27\x9b\x2a\xe8\xbe\x18\xd2\x40\x1d\x15\x79\xb1\xe2\x2d\xef\x28\x4a\x11\x2f\x2b\xdc\xe4\x7f\x0a\xf0\xdc\xbe\x5a\xb1\x98\x07\x23\x08\x68\x12\xc6\xd0\x23\xce\xb0\x58\x80\x93\xe4\x5b\xfe\x8f\x35\xaf\x44\xf0\x38\x76\x02\xe7\x1e\xa5\x0a\x83\x02\xb5\x7b\xb1\x38\xa5\xd6\x0e\x43\x6b\xdc\x51\xea\x42\x1b\x7b\x5d\xfa\x91\xca\xa8\x09\xd9\xd7\xca\x1e\x72\xcb\xa6\xcd\xf1\xa6\x40\xfe\xf8\x3f\x6b\x5e\xee\x22\xca\xee\xc1\x1e\x85\x3c\x72\x2e\x76\x3b\x6b\x10\x23\x37\x8d\x43\x0f\x33\x29\x43\x3d\xbe\xb4\xbc\x3a\xc6\x90\x19\x3c\xce\xf0\xb1\xa8\x68\xac\x74\xa1\x72\x07\x52\x37\x2a\x3a\x39\x37\xe1\xa8\xcb\xb4\x8a\xf1\x24\x6a\x67\xf6\x43\x46\x16\x26\xc8\x03\x9f\xea\xb1\x89\xf6\x88\xd1\xd0\x16\x96\x2c\x49\xe9\xd5\xd6\xf0\x57\x0c\xf8\x2e\xd3\x3c\xb4\x08\x06\x5e\xd8\x01\x4e\xe0\xac\x0f\xc7\xf0\xca\xb6\x8e\x8b\x8c\x82\x59\x18\x70\xc2\xf7\x11\xa2\x98\x09\x3e\x2f\xca\xdd\xd9\x30\x56\x23\xf6\xe4\x04\x7e\x28\x39\x4b\xe2\x72\xbd\x9c\x42\x92\x2e\x65\xa6\x4b\x35\x02\x45\x42\xb2\x35\x00\xd4\x08\x3e\x63\x2c\xcb\xe9\x45\xe5\x74\x75\x82\x49\x20\x91\x26\x87\x8f\x1b\x62\x17\x01\x36\x23\xf8\xaf\x57\x03\x58\x8c\xe0\xe5\x70\x00\xd5\x08\x5e\x0e\x40\x8c\xe0\x74\x28\x65\xf6\xef\x0e\x87\x29\x64\x1e\x2a\x8a\x72\x3b\xf9\xe2\x4e\xd5\xbe\x07\x28\x0c\x61\x14\xb7\xb9\x63\xe6\x50\x84\xaf\x21\x7a\x45\x35\x7d\xe5\x53\xa8\x72\xc5\x4a\xa1\xdf\x9d\xb0\x0f\xfd\x98\x52\xf5\xd8\x4f\x51\x8a\x50\x5f\x42\x51\x4f\xff\x9c\xc1\xd7\x40\xba\x7f\x73\x35\xf0\x6c\xe2\x6b\xf7\x97\x7c\x09\xe8\x81\x65\x6b\x1e\xb6\xde\xb0\x3b\xf5\xef\xd7\xb1\x32\x56\x92\xc7\x40\x57\x19\x2b\xfa\xe8\x00\xce\xf3\x79\xc6\xc3\x43\x37\xfa\x78\x9e\xec\x07\xa4\xfc\x87\xc4\xc0\xa7\x79\xce\xcb\xb7\xc4\x79\x7b\x13\xea\x63\xf5\x8f\x52\x84\x49\xb4\xeb\xeb\x66\xc5\x5a\x3c\xa3\x99\xa4\x29\x5b\x77\xba\x73\xe9\x35\xd2\x3c\x15\x29\xcb\xd2\x8f\xdc\x9a\xff\xbb\x92\xa5\x19\x8e\x0b\xb0\x36\x49\x87\x2d\x5f\xe2\xf2\x22\x90\xaf\xf0\xc4\xf4\x68\x82\x1b\xd8\xd6\x6e\xca\x39\xe5\x58\x60\xac\x9a\x7e\x92\x4a\x4c\x48\xfb\xb1\xd7\xab\x39\x0a\x67\x8e\x33\x2d\x5d\xe7\x21\xcc\x86\x0c\xa7\x16\x51\x08\x96\xa9\x6b\x80\x76\x98\x63\x36\x9b\xc3\xed\xd7\xb5\xc3\xa4\x36\x21\x9c\x9c\xb0\xaa\x4a\xe7\x39\x4c\x77\x82\x57\xc0\x2a\x7d\x27\x0b\x97\x82\x79\x21\x93\x68\xe7\xe9\x03\xcf\x69\x74\xe3\xaf\x89\xc9\x8f\x9d\x80\x59\x5e\xf5\xe1\x7b\x08\x08\x07\x66\x11\x60\xbd\x92\x1e\xbe\x68\x10\x06\xf6\x9d\x90\x44\x77\x9b\x66\x60\x04\x74\x24\x58\x16\x85\x0a\x84\xea\x55\x34\xbd\x65\xf2\xde\xf4\x2e\x61\x62\xbd\x94\x60\x6e\x4f\xcd\x91\x16\x8a\x9f\x66\x93\xf0\xbd\x3f\xda\xd4\x4d\x77\x0d\xd2\x79\x26\x06\x60\x46\x7f\x47\x0e\x85\x36\xb8\x24\x4a\xf8\x4a\x2c\xe0\x7b\xc0\x81\x8a\xa9\x13\x94\x43\x81\x26\x07\x27\x27\x78\xa1\x07\x9f\xd6\xc5\xe7\xb1\xf0\x1d\x8b\x1a\xea\x60\xa0\xac\x84\x95\xb1\x21\xab\x72\x22\x2a\x51\x16\x1f\xe8\x81\xe3\x2f\x67\xb3\x59\x50\xaf\x9e\xa5\x59\xd6\xc5\xd3\x7b\xeb\xed\xc3\x30\x89\x68\xa5\x5f\xf2\x1c\xbe\x87\x04\x46\x80\xe9\x89\xb8\x05\xe8\x47\x98\xfb\xa7\x87\x56\x1d\xf7\x71\xb9\xce\x88\x3a\xa6\x6f\x15\x89\x5d\x38\x37\x52\x06\xcc\xdf\x06\x82\xee\x45\x57\x82\x55\x0b\x35\x55\xba\x76\x8a\x32\x26\x35\x84\xfd\xe8\xfd\x7b\x54\xd2\xfb\xf7\x72\x58\xa8\x35\xf4\xc9\x09\x9c\x27\x09\x3d\x3e\x4f\xa8\x33\xce\x1e\x38\x2c\x58\x9e\x64\xbc\xd4\x9f\x50\x98\xe2\x27\x13\xf0\xc1\x79\x79\xdc\xa4\x1f\x62\x52\x13\x47\xf0\xa5\xe3\xc8\x2d\xc3\x84\x49\x73\x4c\x3f\xf4\x06\xaa\x36\xbe\x97\x45\xd2\x39\xbe\xf1\x09\x91\x7c\xce\xcd\x30\x97\x26\x4a\x1d\x50\x03\x2a\x52\x3f\x30\x73\x3c\x2e\xd6\xb9\x08\x14\x20\xc0\xf7\x56\x61\x8e\xbe\xd0\x19\x1b\x90\x51\xbb\x4e\x13\xf2\xff\x63\x35\x5d\xea\xc7\x67\x4d\xab\x76\x6b\x27\x46\x42\xfa\xdf\xbe\x6f\xfa\x78\x94\x8a\x33\x99\x9d\x6d\x34\x9e\x75\x49\x8b\xe1\xf0\xf4\xd5\x50\x25\x93\x1a\x8b\x7d\xb7\xe1\x3c\x97\x66\xcb\xca\x98\x7e\x29\x05\x3f\xba\xa7\x74\x27\x27\x70\x93\x5b\xb3\x30\xfd\xe9\x81\xf9\xd3\xd6\xda\x73\x40\x14\xe3\x8a\x97\x31\xcf\x85\xdc\xb1\x84\xa7\xc3\x21\x7e\xbf\x42\xc9\xf3\xc4\x9a\x51\x3f\x12\xc5\x9b\x92\xc7\x29\xae\x4d\xc2\x97\x94\xd6\x0a\xff\x43\xdd\xbf\x53\xaf\xd6\x8b\x22\x2e\xb2\xf7\x6a\x77\xaa\x55\xd5\xf2\x7f\xb4\x2e\x0f\x70\x58\xe0\x70\x18\xf4\x3a\xc0\x00\x82\x37\x86\xb9\x60\xe4\x70\xba\xaf\x09\x32\x4b\xb8\x51\x79\xfb\x00\xff\x8e\x5d\x24\x48\xea\xec\x3e\xd0\x4b\xf4\x37\x04\x4a\x9e\xa7\x1b\x52\x6d\x23\xba\x1f\x52\xf2\xa4\xa4\x34\xf9\x55\x18\x7c\xe9\x95\x77\xbc\xaa\x84\x58\x2b\xdc\xaf\xe4\x31\x3f\x2f\x4b\x86\xb7\x5c\xe7\x5c\x9c\xe7\x31\xaf\x44\x51\x56\xea\xe0\x16\x40\x6e\x0e\xec\xac\x5a\x85\x5e\xb3\x81\x23\x49\xbb\xad\x70\x4c\x88\xc6\x69\xb7\x0d\x51\xb5\x35\x22\xd7\x07\x08\x9c\xbf\x8d\xdf\xb2\xee\xcd\x5e\xb8\xa4\x74\x07\x79\xe5\x52\x7b\x82\xbd\xfd\xff\xf4\xe8\xb1\xf8\x13\x4e\x88\xc0\x28\xe2\x88\x9b\x7a\x06\x66\xe8\x81\x7c\xba\x71\xa0\x87\x2f\xcb\x81\x91\x94\x8a\x19\xb0\x2c\xc3\xf5\x72\x2a\xf0\x13\x19\x52\x5c\x72\xd4\xa8\xac\xc8\x45\x3a\x5f\xf0\x4a\xc0\x2c\x2d\xf1\x90\x6f\xba\x16\xf8\xc5\x93\x6c\x4d\x9f\x62\x41\xb7\x88\x13\x5f\xe4\x4a\xc2\x13\xbc\x3d\x7b\x52\x63\x01\xdf\xf6\xd2\xb9\xf9\x26\xf5\x5d\xc5\xa5\xf4\xad\x2c\x80\xcd\x22\xcd\x38\x84\xaa\x4a\xcf\x11\x0a\x8f\x7a\x80\x7e\x9d\x57\x8b\x74\x26\x34\x90\xd2\x30\x38\xf8\xfc\xe6\x76\x67\x54\x7b\xf0\xda\x91\x21\xcf\x79\xc9\x30\x18\x00\xd2\x30\x41\x2c\x98\x80\x84\x57\x71\x99\x4e\xe9\xa3\x32\x1c\x28\xc5\xb2\x42\xa1\x31\x98\xda\xed\xc9\xaa\xc8\x76\xf3\x22\xf7\x44\x61\xab\xdf\x50\xa3\x30\x19\x40\xea\x89\x83\x8a\x1d\x81\x48\xe4\xf2\x46\x42\x30\x1c\x0c\x83\x7e\xb3\x5c\x3a\xd6\x69\xb4\x41\x4f\x73\x18\x44\xff\x2d\xcc\x8e\xc0\x54\xd3\x46\xa1\x7f\x90\x44\xe0\x60\x59\xb4\x40\x07\xc3\x56\x10\x8c\x7d\xa5\xf8\x1e\x3c\xce\x1c\x27\x27\xf0\x0b\x9f\x89\x25\x46\x35\xac\x58\xc6\x90\x14\xf9\x11\x9e\xf8\xc5\xd9\x3a\xe1\xf0\x8d\x58\xc0\x03\x2f\x05\xdf\x46\x5a\xd5\x2d\x5c\x1d\xea\x89\xaf\x63\x89\xe0\x8f\x22\xcd\xc3\x00\x02\x77\xcc\xa8\x78\x0d\x2a\xd5\xb2\x04\x34\x52\x71\x6a\xc7\x17\xd3\x48\xe3\xda\xa2\xb4\xaf\xa0\x8f\xc9\x58\x4f\xe1\xa9\xbc\xe9\x61\xd0\xaa\x1b\xde\xe5\x96\xcc\x0b\x4d\x41\x2f\x33\x30\xa6\x05\xc8\xe5\x18\x3e\xf0\x9d\x45\x18\x17\xcb\x69\x9a\xf3\x4a\x5e\xa3\x40\xca\xe4\x69\x21\x9c\xc0\x4a\x3f\x17\x98\xe6\x86\xb7\x7e\x64\x8c\xcb\xdf\xbf\xb6\xb9\x20\xbb\xca\x98\xbb\xe5\x38\x4f\xb9\x6c\x77\xac\x01\x88\xa1\x17\xda\xf5\x9b\x7d\x8d\x59\x34\x39\x32\x45\xb6\x33\x36\xe5\x19\x1d\xb0\xd1\x4a\x17\xfd\x07\xd2\xa8\x2c\xc3\xa6\x1c\x5f\x09\xae\x2f\x87\xab\x87\xf9\x68\x6e\x3c\xa3\x06\xf5\xaa\xd5\x10\x74\xbb\xe2\xbc\xd9\x8a\xf9\x64\x96\x25\x39\x20\x9b\xfe\xf8\xa9\x4b\xd9\xc4\x2e\x58\xf7\xb1\x64\x92\xfe\x3c\x7e\x30\x63\xa3\x7d\x8c\xf6\xc9\x8e\xeb\xf0\x3b\xb3\x36\xd7\x96\x5e\x87\x90\xa9\x83\x43\x9b\x3b\xe8\xd5\x22\x17\xc7\x2c\x8f\x17\xf8\xa2\x2b\x04\xcb\x34\x49\x32\xee\x82\x35\x13\x0d\x7d\x35\xfb\xca\xbd\xe5\xc2\xda\x9e\xa7\x50\xd4\x33\x8d\x80\x9a\x76\xe7\x2d\xd1\x0b\x4b\xce\x71\x8a\x5d\x8f\xe6\xa4\xf0\x75\xbb\xc4\x2a\x5a\x6f\x61\x1e\x8e\x5a\x71\xb9\x8c\xbe\xa5\x9d\x26\x60\xaa\x7b\x83\xa1\xb6\xfc\x77\x32\xdd\xeb\x62\x03\xd4\xcc\x74\x06\xdf\x21\xe7\xce\xe0\x05\x26\xa8\x84\xe7\x49\xd4\x35\xd1\xdb\x02\x9e\x27\x64\xfa\x4d\xb5\x90\x19\x98\x71\xa6\x2f\xeb\xbc\x80\x61\xf4\xaa\xdf\xdd\xdf\xff\x47\xd6\xd1\xf0\x5d\x56\x62\xbf\xb2\x0f\x1d\x5e\x94\x56\x37\x19\x1f\xe0\xce\x3d\x15\x47\x95\x7a\x53\xa2\x53\x6a\x8d\xe1\xe8\x2f\x8f\x3c\xef\x0d\xb7\xb8\xa9\x23\xba\x45\x96\x00\x2d\x55\x2b\xf2\x2f\x76\x33\xe1\xb9\x66\xda\x04\x3a\xab\xb3\x68\x3b\x44\x0f\x19\x6d\xd5\xb3\x0b\x51\xa2\x0a\x92\xad\x4b\xe6\xca\xde\xc0\x23\x62\xac\x8c\x2b\x5c\x60\xa1\x97\xa4\xc8\xa3\x3f\x01\xe8\xcd\x48\x68\x1e\x10\x45\xd7\x96\x22\xe2\x97\xde\x6d\xbe\x4f\xdb\x11\xb0\x68\x3b\x1c\x40\x42\x7f\x25\xdb\xe1\xe3\x00\x74\xcc\x59\x0d\x03\x8d\x36\x74\x56\x3f\x88\x0f\xc3\x99\x69\x68\x17\x3d\x88\x08\x26\x30\xd5\x9d\xc1\x92\x44\x15\x25\xdb\xb1\x3f\xb6\xcc\x36\x3f\x9c\x2a\x04\x8f\xa6\xc3\x56\x29\x78\x07\x39\x9a\x95\x6c\xc9\x5f\xcb\xf7\xea\xfa\x5a\x29\x6d\xc1\x4c\x1c\x85\xab\x6d\x70\x28\x8e\xd4\x19\xda\x72\xe3\x4a\xb2\xab\xce\xd6\x1b\x63\xb1\xac\xe4\x2c\xd2\xa1\x26\xd5\xc2\xb5\x20\x3d\x01\x06\xfe\x94\xa1\x83\xb4\x00\x07\x02\xb5\x00\xcd\x60\xed\xab\x61\xad\x46\x06\x66\x95\xb1\x8e\x7d\x26\x69\x90\x3b\xae\x61\xa0\x3f\x53\xe7\x78\x0e\xec\x00\xb5\xee\x9a\x24\x3c\x3a\x35\xcf\x51\x9b\xa2\x54\x28\xc6\x44\xf9\x29\xaf\xbb\xac\x68\xc3\xfc\xbc\x40\xbf\x13\xd3\x57\xca\x54\x85\x4a\xdc\x4b\x56\xce\x53\x0c\x0e\x7f\x12\xc5\x0a\x23\xe5\xc3\x01\xd0\x97\x26\x47\x30\x1c\xc0\xb4\x10\xa2\x58\x62\xf1\x00\x32\x3e\xa3\x50\xfa\xf0\xaf\x0d\xa4\xc3\x0b\xc5\x43\x84\x04\xec\xaf\xd2\x86\xd1\x3b\xa3\xec\x16\x5a\x14\x2b\xfb\x43\x72\xed\xde\xfb\x91\x14\x8e\x91\x02\xde\x8b\xf0\x09\x9e\x0d\xb5\x81\x77\x06\xed\xf7\x44\xe6\x7d\x5c\xc1\xc0\x29\x93\x4c\xf9\xf1\xf8\x02\x33\x58\x6b\xd7\xe2\xeb\x41\x52\xd7\xf4\x33\xb6\xe3\x65\x57\x88\xc8\xc4\x86\xd4\x39\xda\xa2\xd8\xb8\x96\xd2\x16\x09\xae\xa1\x27\x76\x9e\x88\x9e\x72\x3c\x3b\xa2\xcb\x4d\x03\x75\x1c\x03\x35\x74\x0d\x96\xa8\xba\x89\x75\x54\x60\x92\x8f\xe8\x97\x9f\x55\xa7\x45\xb5\x55\xe6\x46\xa7\x4a\x85\xbc\x31\x8d\x33\x3d\xc6\xce\x7e\x60\x79\x52\x85\x77\x43\x3d\x63\x92\xad\xa9\xec\xaa\x6d\x94\x14\x4b\xa6\x4f\xb1\x24\x81\x3b\xfa\x47\x01\x20\x72\x93\x3f\x81\xa1\x5f\x37\x6a\x65\x83\x55\x67\x18\xac\xa2\x06\x42\x09\x91\x42\xdf\x51\x59\x6c\xd4\x85\x08\x9e\xb1\x9d\xb3\xdc\x92\xeb\x1f\xed\x9d\xb7\x61\x8a\xb3\xff\x7f\xea\x63\x86\xa6\x75\xd5\x5b\xf6\xda\xd7\x4d\x72\x57\x46\xe8\x9c\x37\x06\x7b\xfe\xc2\x3f\x8a\x79\x96\xb5\xb3\xe5\xf1\x94\x44\xdb\x16\xae\x3a\xdf\x57\x94\x0d\xcc\xb2\xd1\x17\x44\x5c\x64\xeb\x65\xfe\x6f\x95\x85\x27\x89\xb2\xc0\x2b\x0b\xf8\x51\x8a\x7e\xf0\x54\xfb\xfc\x93\x4f\xa7\x37\x5e\x4c\x7f\xcf\x56\xab\x96\x68\xd6\x21\x36\xea\xc3\xd7\xe5\x85\xdc\x80\xe3\xdf\xf7\x1e\xbd\x74\xbb\x95\xfa\xe9\x48\xec\x90\xa3\x03\x12\xa2\x33\x68\x7f\x2c\x1d\x87\xc8\x92\x89\x32\xdd\xd6\xa2\x3c\xfa\x7b\x03\xb8\x6a\x92\xd1\x5f\xa7\x4e\xc5\x7e\x2a\xb5\x04\xb6\x2b\xcb\x8b\x62\xb9\x5a\x0b\x3c\xb4\x48\xf8\x16\xe7\x51\x82\x8b\xcc\xb7\x68\xe8\xbb\x04\xaf\xbd\x97\x4f\xb1\xd8\x31\x05\x95\xbe\x26\x11\x4c\x20\xd5\x4b\x21\x2a\xa5\x80\xb8\x3e\xae\xc2\xff\x97\xac\xdf\xa5\x98\x4c\x92\xbc\x94\x2e\x23\xcc\xfb\xd1\x92\xad\x2c\x85\x3f\x1c\x03\xc5\x45\xdc\x1f\x03\xd8\x8d\x20\x1d\xc0\xc7\x11\x0c\x1f\xc7\xea\x9e\xb0\xbf\x13\x91\xbe\x4f\x00\xde\x75\xa9\x30\xb8\x20\x29\x8d\x41\xb2\x10\x2f\x58\xc9\x62\xbc\x50\x5c\xc4\x32\xda\x10\xeb\x9d\x0a\x09\x8c\x9a\x35\xfb\x8a\xc5\xb6\xa3\x8a\x79\x2c\x54\x17\xbe\xef\xe5\x0f\x79\xd3\xfb\x3e\xfa\x88\x77\x14\xa8\x44\x1d\x71\x34\xdb\x29\x50\x0f\xc9\x53\xda\x79\xf4\x9e\xd1\xce\xa3\xa7\x7e\x74\xb5\x43\x95\x55\x3e\x05\x29\xbd\x43\xd0\x1a\x6f\x27\xb4\xab\x29\x0c\xe5\x2b\xab\xc3\x85\x1c\xf9\x7f\xa5\x8a\xf7\xce\xc4\xe0\xc4\xf1\x71\x83\x3c\xf2\xcc\x85\xce\xca\x8d\x96\xd8\x00\xa6\x56\x4b\xc6\x3b\x25\x2f\x23\x56\xc5\x9c\x4e\x8e\xc8\x47\x54\x77\xec\x9e\xa2\x0a\x03\xc5\xfc\xf4\x5e\x05\x19\x54\x53\xfb\xba\x3c\xf5\xe4\x33\x68\x1a\xbc\x84\x00\x8e\x15\x21\xa6\x0a\x9a\x84\xd4\xa3\x28\x9f\x4f\x88\x10\xb8\x84\xcc\x0d\x3d\x84\x56\xa7\x7d\xfa\x1c\xe9\xb3\x67\x6f\xdd\xf8\xa3\xdb\x18\xdf\x2c\xc0\x3c\x60\x3d\xad\x63\xbb\xff\xbc\xef\x47\x71\xc6\x96\xab\x10\x9f\x99\x70\x5a\xc6\x6d\xa9\x28\xa7\x43\xdb\xda\x88\xe0\x74\xa8\x3e\x60\x43\xab\x7f\xfc\xee\xb8\x3e\x9e\x46\xc9\x00\x99\x87\xb4\x17\xb3\xa0\x70\x0d\x47\xab\xd4\xb1\x28\xf7\x4b\x45\xe6\x7b\x3f\xcd\xbb\x90\x53\x16\x7f\x40\xe9\xe5\x89\x0f\xa0\xd7\xcb\x8e\x4c\xfa\xbd\xb6\xed\xcc\x7b\x67\x59\xac\x39\x40\xa9\x95\xc5\xc6\x3b\xd1\x6e\x5b\xb4\xe8\xb0\xa0\x1c\xf4\xfd\x5e\xeb\x91\xf5\x3c\xf0\x08\x1b\xce\x1d\x24\x4f\x9c\xc1\xdb\xe6\xf0\xc6\x7a\x46\x9b\x4f\xed\xed\xeb\xb2\xd8\x58\x34\xd8\x3f\x5c\xe2\x28\xf5\x52\xcf\x68\x81\xd7\x6f\x5f\x05\xd9\x9e\x96\xc5\x26\x9a\xa5\x19\x46\x21\x2d\x8b\x8e\xeb\x4f\xa2\x8f\xe8\xeb\x4d\xa3\xba\x30\x1c\x4d\x36\x25\xe2\xd3\x7b\xf2\x62\xca\x6f\xa0\x15\xbf\xb5\xa3\x23\xec\xd7\x60\x8c\xf2\xdb\x81\x9c\x2d\xe5\xb1\xbd\xc5\xdc\xca\xc5\xc7\x30\x89\x3e\x76\x9d\xd0\x77\x35\x92\xfe\x25\x89\xb6\xda\x13\xa8\x17\x6d\xb0\x6c\xa7\xcb\xbe\x87\x38\xac\x03\xf6\x61\x44\x49\x0c\x2e\xbd\xfa\x61\xbf\xa1\xe8\x3c\xe3\xe5\x6c\x5d\x8c\x01\x03\x06\xb0\x02\x1a\xf8\x55\xc5\x93\x30\x60\x31\x7e\x13\xd6\xe3\xd9\x5f\x77\xa6\x98\xa8\xbb\xd2\x2f\x8b\x77\x60\x96\xcb\xd8\xcf\x46\xbe\xf5\x91\xbf\x6f\x3c\x5f\x24\x45\xb2\x8a\xb6\x36\xd9\xb6\x4d\x16\x6b\xf1\x44\x51\x74\x33\x6a\xbe\x65\xa1\x27\x43\x6f\x52\xc4\x81\xa0\x8d\x1a\x7d\x6a\xc3\x6e\xcf\x6a\xce\xa7\xa5\x5d\x33\xc4\x81\xd6\x7e\xfc\x8d\x57\xb4\xab\x9b\xa9\x0d\x66\xd6\x6e\xc8\x9f\x99\x38\x66\x7b\x0c\x93\x5b\x1f\x59\x0b\x6d\xfb\xaa\x30\x5f\x95\xa8\x05\xb9\xc9\x6b\x48\xf5\x76\x39\x46\x67\x13\xf3\xa7\x7c\xa3\x8f\xe7\x4f\xb8\xc7\xbd\x5b\x1c\x47\x9d\x92\x60\x9b\x46\x15\x75\xfa\x10\xc0\x71\x8b\x42\x6b\x2d\xdb\x75\xfa\xaf\x52\x29\xdd\xb1\xfe\x5c\xa5\x9a\x3d\x1e\x2a\x56\x14\xab\x22\x2b\xe6\x2a\x38\x39\xa6\xe0\x99\xbb\xc9\x71\xcb\x4d\x6a\x98\x2e\xec\x69\xaa\x70\x3e\xe7\xb9\x78\xcb\x59\xb2\x53\x31\x10\xf3\xed\xa7\xe3\x15\xcb\x79\xe6\x7c\x45\x49\x9e\xe4\xbb\x34\x1a\x95\x86\x90\x53\xf3\xe8\x52\xcb\x59\xb6\xfb\xc8\x4b\x97\x60\x93\x69\x95\x58\xde\xdc\x42\x92\xbb\xb2\x85\xc7\xc9\x4b\x12\x65\xad\x7b\xaa\x79\x2d\x7c\x1b\x06\x91\x81\xa3\x86\xea\xb3\x50\x47\x5f\x6a\x49\x1e\x4f\x45\x7e\x84\x2e\x10\xbf\xca\xa8\x59\x56\x4c\xfa\x90\x78\x05\x3f\x49\x2e\xd0\x05\x85\x47\xd2\x01\x1d\x29\x7f\x83\x60\x2e\x8f\x47\x7a\xbb\xda\x09\x6d\xb8\xea\x06\xd5\xb0\x91\xc3\x80\xfd\x12\x16\xf1\xe6\x09\xe6\xc8\xd5\x8b\xac\x76\xa9\xd8\x3a\x35\x9c\x0e\x7e\x42\xab\xd7\x6b\xf6\xec\x59\xe2\x3a\x20\x83\x1a\xf3\xfb\x84\xfb\x99\xe2\xaa\xcb\xa3\x46\xb1\x2e\xcd\x36\x71\x29\xef\xd1\x88\x6b\xd4\xa3\x19\x61\xc0\xc5\x82\x97\x39\x17\xea\xa4\x47\x0d\x0f\x87\xf7\x7f\xa1\xec\x0e\x40\xbb\x1d\x6b\x13\xf3\x67\xc8\xae\x5e\xed\x92\xd0\x72\x25\xb4\xa6\x42\x09\xce\x26\xf2\x1a\x39\xb9\xce\xe2\x97\x62\x8e\xe3\x56\x4a\x65\x93\xe6\x49\xb1\x89\xec\xc5\x92\x92\xcf\xf0\xdb\xf4\x27\x59\x31\x4f\xf3\xc0\x6f\x49\xd7\x2c\x2e\x16\x3c\xfe\x70\xfe\xe6\xea\x9c\x3e\x8b\xa7\xd0\x54\x5c\xd0\x49\xd8\x03\xcb\x5a\xe4\xee\x7f\x7c\xac\xeb\x6b\x6b\xf6\x83\x64\xe6\x2b\x61\xbc\x2c\x8b\x72\x04\x0d\x8c\xf8\x1f\xdd\x0d\xb3\x32\x31\x13\x19\xe0\x95\x9c\x57\xe6\x4a\xce\x57\x61\x52\xc4\x6b\x79\x46\x85\x27\xfc\x4e\x40\xd1\x46\x90\x6f\x79\xf9\x90\xe2\xc7\x6c\x27\x10\x30\xf4\xdd\xe6\xf3\x82\xae\x27\xd7\x1f\xf4\x71\xbe\x13\x56\x73\xbd\xe6\xa8\x4c\x29\x54\xf0\x5c\x90\xf5\x54\xe9\x47\x36\xcd\xb8\x92\x82\xcc\x11\xad\x46\x70\xc4\x55\x67\x97\x69\x4e\xef\x41\xe0\xc5\x83\xe1\x40\x05\x2a\x31\x17\x6f\x64\xb8\xc5\xfc\x56\x31\x58\xa7\x7d\x2d\x04\x9c\x83\xb6\x93\x75\xaa\x5f\x17\x96\x49\xe7\x84\xc6\xca\x05\x81\x76\x0d\x20\xf9\xf9\x57\x1f\x8a\x67\xdc\x81\x73\x6b\x66\x4c\x3d\x2e\xf2\x95\xda\x1d\xc9\xa4\xa9\xb0\x2f\x4f\x60\xc2\xfe\xb1\x39\x45\x24\xf0\xb3\x3d\xa0\x78\xcd\x60\x78\xf6\xdd\x77\xdf\xe9\x16\x5f\xc9\x1d\x1a\xcf\x78\x84\xe7\xc1\x69\x3e\xaf\xc2\xfe\xc0\xf4\x3a\x4d\xb6\x83\x54\xf0\xa5\xab\x7b\x1f\x36\xe2\xff\x08\xd3\x64\xdb\x8f\x62\x1c\x73\x72\x4f\x73\x34\xd8\xbd\x38\x5a\x6d\xf5\x10\xdd\xd3\x48\xb2\x15\xca\x2e\x1e\xcf\xce\xfa\x7e\x3b\xb3\xe0\x55\x23\xa9\x77\x60\xac\xee\xf1\x72\x7a\xe8\x7b\xd3\xa9\x99\x45\x75\xad\x9a\x44\xeb\xe0\x2d\x77\x9c\xd0\x21\xb7\x0e\xc9\x71\xef\xb1\x3f\xee\xfd\xdf\x01\x00\x89\x68\x01\xf2\xf9\x8d\x00\x00")
 
 func staticsJsSkydiveJsBytes() ([]byte, error) {
 	return bindataRead(
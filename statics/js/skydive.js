@@ -975,6 +975,11 @@ Layout.prototype.ProcessGraphMessage = function(msg) {
       this.graph.DelEdge(edge);
       this.DelEdge(edge);
       break;
+
+    case "BatchApplied":
+      for (var i in msg.Obj)
+        this.ProcessGraphMessage(msg.Obj[i]);
+      break;
   }
 }
 
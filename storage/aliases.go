@@ -0,0 +1,287 @@
+/*
+ * Copyright (C) 2015 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package storage
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"github.com/redhat-cip/skydive/config"
+	"github.com/redhat-cip/skydive/flow"
+)
+
+// FilterError reports a filter that can't be translated into a storage
+// query
+type FilterError struct {
+	Key    string
+	Reason string
+}
+
+func (e *FilterError) Error() string {
+	return fmt.Sprintf("Invalid filter %s: %s", e.Key, e.Reason)
+}
+
+// Aliases maps friendly filter keys (src_ip) to the field paths of the
+// storage (Network.A)
+type Aliases map[string]string
+
+// FilterOperators are the operators suffixing the filter keys, after a
+// double underscore (last__gte, LayersPath__in) or, for the numeric fields,
+// a dot (Duration.gt). gt, gte, lt and lte compare the numeric fields, in
+// matches any field to one of the comma separated values and exists, true
+// or false, whether the field or the endpoint side is set (TCPPORT.A__exists).
+var FilterOperators = []string{"gt", "gte", "lt", "lte", "in", "exists"}
+
+// Operator splits a key suffixed with an operator into the field, the alias
+// being resolved, and the operator, checked by Translate
+func (a Aliases) Operator(key string) (field string, op string, ok bool) {
+	i, sep := strings.LastIndex(key, "__"), 2
+	if i == -1 {
+		if i, sep = strings.LastIndex(key, "."), 1; i == -1 {
+			return "", "", false
+		}
+	}
+
+	if field, ok = a[key[:i]]; !ok {
+		field = key[:i]
+	}
+	// the endpoint and attribute keys having dots, only the numeric fields
+	// are suffixed with a dot
+	if !flow.IsFilterKey(field) || (sep == 1 && !flow.IsNumericField(field)) {
+		return "", "", false
+	}
+	return field, key[i+sep:], true
+}
+
+// ParseSort parses the sort orders of the form field[:asc|desc], the field
+// being a numeric field or an alias of one, the ascending order by default
+func (a Aliases) ParseSort(s string) (Sort, error) {
+	var sort Sort
+
+	field := s
+	if i := strings.LastIndex(s, ":"); i != -1 {
+		switch s[i+1:] {
+		case "asc":
+		case "desc":
+			sort.Desc = true
+		default:
+			return sort, fmt.Errorf("Unknown sort order: %s, expected asc or desc", s[i+1:])
+		}
+		field = s[:i]
+	}
+
+	if sort.Field = a[field]; sort.Field == "" {
+		sort.Field = field
+	}
+	if !flow.IsNumericField(sort.Field) {
+		return sort, fmt.Errorf("Unknown sort field: %s, expected one of the numeric fields Duration, Statistics.Start or Statistics.Last", field)
+	}
+	return sort, nil
+}
+
+// filterValue returns the value of a filter of the field with the operator,
+// the comparisons of the numeric fields being turned into ranges
+func filterValue(field string, op string, value interface{}) (interface{}, error) {
+	numeric := flow.IsNumericField(field)
+
+	switch op {
+	case "":
+	case "in":
+		var values In
+		for _, v := range strings.Split(fmt.Sprint(value), ",") {
+			if !numeric {
+				values = append(values, v)
+				continue
+			}
+			i, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("Malformed numeric value: %s", v)
+			}
+			values = append(values, i)
+		}
+		return values, nil
+	case "exists":
+		exists, err := strconv.ParseBool(fmt.Sprint(value))
+		if err != nil {
+			return nil, fmt.Errorf("Malformed boolean value: %v", value)
+		}
+		return Exists(exists), nil
+	case "gt", "gte", "lt", "lte":
+		if !numeric {
+			return nil, fmt.Errorf("The %s operator only compares the numeric fields", op)
+		}
+		value = fmt.Sprintf("%s:%v", op, value)
+	default:
+		return nil, fmt.Errorf("Unknown operator: %s, expected one of %s", op, strings.Join(FilterOperators, ", "))
+	}
+
+	if s, ok := value.(string); ok && numeric {
+		nf, err := flow.ParseNumericFilter(s)
+		if err != nil {
+			return nil, err
+		}
+		return NumericFilter(nf), nil
+	}
+	return value, nil
+}
+
+// Translate returns the filters with the aliases replaced by the field paths
+// they designate and the operators turned into typed values: the
+// comparisons of numeric fields (Duration=gt:3600 or Duration__gt=3600) into
+// ranges, intersected when a field is compared several times, and in into
+// In. Keys being neither an alias nor a flow field, and unknown operators,
+// are reported with a FilterError.
+func (a Aliases) Translate(filters Filters) (Filters, error) {
+	translated := make(Filters)
+	for key, value := range filters {
+		field, ok := a[key]
+		var op string
+		if !ok {
+			if flow.IsFilterKey(key) && !strings.Contains(key, "__") {
+				field = key
+			} else if field, op, ok = a.Operator(key); !ok {
+				return nil, &FilterError{Key: key, Reason: "unknown field or alias"}
+			}
+		}
+
+		value, err := filterValue(field, op, value)
+		if err != nil {
+			return nil, &FilterError{Key: key, Reason: err.Error()}
+		}
+
+		if previous, ok := translated[field]; ok {
+			pr, ok1 := previous.(Range)
+			vr, ok2 := value.(Range)
+			if !ok1 || !ok2 {
+				return nil, &FilterError{Key: key, Reason: "conflicts with another filter of " + field}
+			}
+			value = pr.Intersect(vr)
+		}
+
+		translated[field] = value
+	}
+
+	return translated, nil
+}
+
+// AliasedStorage translates the filters given to the storage it wraps, the
+// searches of a partitioned storage being split by the planner if any
+type AliasedStorage struct {
+	Storage
+	Aliases Aliases
+	Planner *Planner
+}
+
+func (s *AliasedStorage) SearchFlows(filters Filters) ([]*flow.Flow, error) {
+	return s.SearchFlowsContext(context.Background(), filters)
+}
+
+func (s *AliasedStorage) SearchFlowsContext(ctx context.Context, filters Filters) ([]*flow.Flow, error) {
+	translated, err := s.Aliases.Translate(filters)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.Planner != nil {
+		return s.Planner.SearchFlows(ctx, s.Storage, translated)
+	}
+	return searchFlowsContext(ctx, s.Storage, translated)
+}
+
+// SearchFlowsPaged searches a page of the flows, split by the planner if
+// any and limited by the aliased storage if it can
+func (s *AliasedStorage) SearchFlowsPaged(ctx context.Context, q SearchQuery) ([]*flow.Flow, int, error) {
+	translated, err := s.Aliases.Translate(q.Filters)
+	if err != nil {
+		return nil, 0, err
+	}
+	q.Filters = translated
+
+	if s.Planner != nil {
+		return s.Planner.SearchFlowsPaged(ctx, s.Storage, q)
+	}
+	return SearchFlowsPaged(ctx, s.Storage, q)
+}
+
+func (s *AliasedStorage) CountFlows(filters Filters) (int, error) {
+	translated, err := s.Aliases.Translate(filters)
+	if err != nil {
+		return 0, err
+	}
+	return s.Storage.CountFlows(translated)
+}
+
+// MaxBulkSize returns the bulk size of the aliased storage, 0 when unbounded
+func (s *AliasedStorage) MaxBulkSize() int {
+	if limiter, ok := s.Storage.(BulkLimiter); ok {
+		return limiter.MaxBulkSize()
+	}
+	return 0
+}
+
+func (s *AliasedStorage) StoreFlowsAcked(flows []*flow.Flow) error {
+	return StoreFlowsAcked(s.Storage, flows)
+}
+
+func (s *AliasedStorage) ResolveAttributes(hashes []string) (map[string]map[string]string, error) {
+	resolver, ok := s.Storage.(AttributeResolver)
+	if !ok {
+		return nil, ErrInterningNotEnabled
+	}
+	return resolver.ResolveAttributes(hashes)
+}
+
+func (s *AliasedStorage) SearchFlowsSince(filters Filters, cursor int64, limit int) ([]*flow.Flow, int64, error) {
+	feed, ok := s.Storage.(FlowFeed)
+	if !ok {
+		return nil, cursor, ErrFlowFeedNotSupported
+	}
+
+	translated, err := s.Aliases.Translate(filters)
+	if err != nil {
+		return nil, cursor, err
+	}
+	return feed.SearchFlowsSince(translated, cursor, limit)
+}
+
+func (s *AliasedStorage) Ping(ctx context.Context) error {
+	return Ping(ctx, s.Storage)
+}
+
+func NewAliasedStorage(s Storage, aliases Aliases) *AliasedStorage {
+	return &AliasedStorage{
+		Storage: s,
+		Aliases: aliases,
+	}
+}
+
+// NewAliasedStorageFromConfig wraps the storage with the storage.aliases and
+// a planner configured by storage.search
+func NewAliasedStorageFromConfig(s Storage) *AliasedStorage {
+	as := NewAliasedStorage(s, Aliases(config.GetConfig().GetStringMapString("storage.aliases")))
+	as.Planner = NewPlannerFromConfig()
+	return as
+}
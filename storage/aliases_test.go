@@ -0,0 +1,196 @@
+/*
+ * Copyright (C) 2015 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package storage
+
+import (
+	"reflect"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/redhat-cip/skydive/config"
+	"github.com/redhat-cip/skydive/flow"
+)
+
+type recordingStorage struct {
+	filters Filters
+	flows   []*flow.Flow
+}
+
+func (s *recordingStorage) Start() {
+}
+
+func (s *recordingStorage) Stop() {
+}
+
+func (s *recordingStorage) StoreFlows(flows []*flow.Flow) error {
+	return nil
+}
+
+func (s *recordingStorage) SearchFlows(filters Filters) ([]*flow.Flow, error) {
+	s.filters = filters
+	return s.flows, nil
+}
+
+func (s *recordingStorage) CountFlows(filters Filters) (int, error) {
+	s.filters = filters
+	return 0, nil
+}
+
+func TestAliasesTranslate(t *testing.T) {
+	aliases := Aliases{
+		"src_ip":   "Network.A",
+		"probe":    "ProbeNodeUUID",
+		"duration": "Duration",
+	}
+
+	tests := []struct {
+		filters    Filters
+		translated Filters
+	}{
+		{Filters{"src_ip": "10.0.0.1"}, Filters{"Network.A": "10.0.0.1"}},
+		{Filters{"probe": "node-1", "LayersPath": "Ethernet/IPv4"}, Filters{"ProbeNodeUUID": "node-1", "LayersPath": "Ethernet/IPv4"}},
+		{Filters{"duration": "gt:3600"}, Filters{"Duration": Range{Gte: 3601}}},
+		{Filters{"Duration": "lte:0"}, Filters{"Duration": Range{Lt: 1}}},
+		{Filters{"Duration": "30"}, Filters{"Duration": int64(30)}},
+		{Filters{"Statistics.Last": Range{Gte: 100}}, Filters{"Statistics.Last": Range{Gte: 100}}},
+		{Filters{"Statistics.Last.gte": "100"}, Filters{"Statistics.Last": Range{Gte: 100}}},
+		{Filters{"duration.gt": "3600", "Duration.lte": "7200"}, Filters{"Duration": Range{Gte: 3601, Lt: 7201}}},
+		{Filters{"Statistics.Last": Range{Gte: 100}, "Statistics.Last.gt": "200"}, Filters{"Statistics.Last": Range{Gte: 201}}},
+		{Filters{"Duration": "gte:10", "Duration.lt": "20"}, Filters{"Duration": Range{Gte: 10, Lt: 20}}},
+		{Filters{"duration__gt": "10", "Statistics.Last__lte": "100"}, Filters{"Duration": Range{Gte: 11}, "Statistics.Last": Range{Lt: 101}}},
+		{Filters{"probe__in": "node-1,node-2"}, Filters{"ProbeNodeUUID": In{"node-1", "node-2"}}},
+		{Filters{"Duration__in": "0,30"}, Filters{"Duration": In{int64(0), int64(30)}}},
+		{Filters{"Attributes.JA3__in": "a,b"}, Filters{"Attributes.JA3": In{"a", "b"}}},
+		{Filters{"TCPPORT.A__exists": "true", "Attributes.JA3__exists": "false"}, Filters{"TCPPORT.A": Exists(true), "Attributes.JA3": Exists(false)}},
+	}
+
+	for _, test := range tests {
+		translated, err := aliases.Translate(test.filters)
+		if err != nil {
+			t.Fatalf("%v: %s", test.filters, err.Error())
+		}
+		if !reflect.DeepEqual(translated, test.translated) {
+			t.Errorf("%v: expected %v, got %v", test.filters, test.translated, translated)
+		}
+	}
+}
+
+func TestAliasesUnknown(t *testing.T) {
+	for _, filters := range []Filters{
+		{"dst_ip": "10.0.0.1"},
+		{"duration": "longer:10"},
+		{"Duration.longer": "10"},
+		{"Duration.gt": "gt:10"},
+		{"LayersPath.gt": "10"},
+		{"LayersPath__gt": "10"},
+		{"Duration__longer": "10"},
+		{"Duration__in": "0,long"},
+		{"unknown__in": "a,b"},
+		{"TCPPORT.A__exists": "maybe"},
+		{"Duration": "30", "Duration.gt": "10"},
+	} {
+		_, err := Aliases{"duration": "Duration"}.Translate(filters)
+		if _, ok := err.(*FilterError); !ok {
+			t.Errorf("%v: expected a filter error, got %v", filters, err)
+		}
+	}
+}
+
+func TestAliasedStorage(t *testing.T) {
+	rs := &recordingStorage{}
+	s := NewAliasedStorageFromConfig(rs)
+
+	if _, err := s.SearchFlows(Filters{"probe": "node-1", "last": "gte:1000"}); err != nil {
+		t.Fatal(err.Error())
+	}
+	expected := Filters{"ProbeNodeUUID": "node-1", "Statistics.Last": Range{Gte: 1000}}
+	if !reflect.DeepEqual(rs.filters, expected) {
+		t.Errorf("Expected the default aliases to be translated, got %v", rs.filters)
+	}
+
+	defaults := config.GetConfig().GetStringMapString("storage.aliases")
+	config.GetConfig().Set("storage.aliases", map[string]string{"src_ip": "Network.A"})
+	defer config.GetConfig().Set("storage.aliases", defaults)
+
+	s = NewAliasedStorageFromConfig(rs)
+	if _, err := s.CountFlows(Filters{"src_ip": "10.0.0.1"}); err != nil {
+		t.Fatal(err.Error())
+	}
+	if !reflect.DeepEqual(rs.filters, Filters{"Network.A": "10.0.0.1"}) {
+		t.Errorf("Expected the configured alias to be translated, got %v", rs.filters)
+	}
+
+	if _, err := s.CountFlows(Filters{"probe": "node-1"}); err == nil {
+		t.Error("The configured aliases should replace the default ones")
+	}
+}
+
+func TestAliasedStorageSearchFlowsPaged(t *testing.T) {
+	st := &recordingStorage{}
+	for _, last := range []int64{20, 40, 10, 30} {
+		st.flows = append(st.flows, &flow.Flow{Statistics: &flow.FlowStatistics{Last: last}})
+	}
+	as := NewAliasedStorage(st, Aliases{"probe": "ProbeNodeUUID"})
+
+	// the storage not paging, all the flows are searched then paged
+	flows, total, err := as.SearchFlowsPaged(context.Background(), SearchQuery{Filters: Filters{"probe": "node-1"}, Limit: 2, Offset: 1})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if total != 4 || len(flows) != 2 || flows[0].Statistics.Last != 30 || flows[1].Statistics.Last != 20 {
+		t.Errorf("Expected the 2nd and 3rd most recent flows of 4, got %d flows of %d", len(flows), total)
+	}
+	if !reflect.DeepEqual(st.filters, Filters{"ProbeNodeUUID": "node-1"}) {
+		t.Errorf("Expected the filters to be translated, got %v", st.filters)
+	}
+
+	// sorted in the ascending order of Statistics.Last
+	flows, _, err = as.SearchFlowsPaged(context.Background(), SearchQuery{Limit: 2, Sort: Sort{Field: "Statistics.Last"}})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(flows) != 2 || flows[0].Statistics.Last != 10 || flows[1].Statistics.Last != 20 {
+		t.Errorf("Expected the 2 oldest flows, got %d flows", len(flows))
+	}
+}
+
+func TestAliasesParseSort(t *testing.T) {
+	aliases := Aliases{"duration": "Duration", "probe": "ProbeNodeUUID"}
+
+	for s, expected := range map[string]Sort{
+		"Statistics.Last:desc": {Field: "Statistics.Last", Desc: true},
+		"Statistics.Start":     {Field: "Statistics.Start"},
+		"duration:asc":         {Field: "Duration"},
+	} {
+		if sort, err := aliases.ParseSort(s); err != nil || sort != expected {
+			t.Errorf("%s: expected %+v, got %+v: %v", s, expected, sort, err)
+		}
+	}
+
+	for _, s := range []string{"Bytes:desc", "probe", "LayersPath", "Duration:up", ""} {
+		if _, err := aliases.ParseSort(s); err == nil {
+			t.Errorf("%s: expected an error", s)
+		}
+	}
+}
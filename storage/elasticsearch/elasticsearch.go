@@ -23,37 +23,79 @@
 package elasticsearch
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
 	"strconv"
 	"strings"
 	"sync/atomic"
 	"time"
 
 	elastigo "github.com/mattbaird/elastigo/lib"
+	"golang.org/x/net/context"
 
 	"github.com/redhat-cip/skydive/config"
 	"github.com/redhat-cip/skydive/flow"
+	shttp "github.com/redhat-cip/skydive/http"
 	"github.com/redhat-cip/skydive/logging"
 	"github.com/redhat-cip/skydive/storage"
 )
 
-const indexVersion = 2
+const indexVersion = 4
 
+// attributesIndex is the side index of the interned attribute sets
+const attributesIndex = "skydive_attributes"
+
+// the endpoints are nested for their type and values to be matched together
 const mapping = `
 {"mappings":{"flow":{"dynamic_templates":[
 	{"notanalyzed_graph":{"match":"*NodeUUID","mapping":{"type":"string","index":"not_analyzed"}}},
 	{"notanalyzed_layers":{"match":"LayersPath","mapping":{"type":"string","index":"not_analyzed"}}},
+	{"notanalyzed_roles":{"match":"*_Role","mapping":{"type":"string","index":"not_analyzed"}}},
+	{"notanalyzed_endpoints":{"path_match":"Statistics.Endpoints.*","match_mapping_type":"string","mapping":{"type":"string","index":"not_analyzed"}}},
 	{"start_epoch":{"match":"Start","mapping":{"type":"date", "format": "epoch_second"}}},
 	{"last_epoch":{"match":"Last","mapping":{"type":"date", "format": "epoch_second"}}}
-]}}}
+],"properties":{"Statistics":{"properties":{"Endpoints":{"type":"nested"}}}}}}}
 `
 
 type ElasticSearchStorage struct {
+	// alias of the versioned index of the flows
+	index      string
 	connection *elastigo.Conn
 	indexer    *elastigo.BulkIndexer
+	client     *http.Client
 	started    atomic.Value
+	sequence   int64
+	feedDelay  time.Duration
+	period     int64
+	limit      int
+	maxBulk    int
+	oversized  int64
+	attributes *storage.AttributeDictionary
+}
+
+// storedFlow is the document of a flow, numbered in the order the flows are
+// stored for the flow feed
+type storedFlow struct {
+	*flow.Flow
+	Sequence int64
+}
+
+// nextSequence returns a number greater than the previous one, based on the
+// time so that the sequence keeps increasing across restarts
+func (c *ElasticSearchStorage) nextSequence() int64 {
+	for {
+		last := atomic.LoadInt64(&c.sequence)
+		next := time.Now().UnixNano()
+		if next <= last {
+			next = last + 1
+		}
+		if atomic.CompareAndSwapInt64(&c.sequence, last, next) {
+			return next
+		}
+	}
 }
 
 func (c *ElasticSearchStorage) StoreFlows(flows []*flow.Flow) error {
@@ -61,8 +103,23 @@ func (c *ElasticSearchStorage) StoreFlows(flows []*flow.Flow) error {
 		return errors.New("ElasticSearchStorage is not yet started")
 	}
 
+	if c.attributes != nil {
+		interned, err := c.attributes.Intern(flows)
+		if err != nil {
+			return err
+		}
+		flows = interned
+	}
+
+	// the indexer sends a bulk each maxBulk flows, a larger batch being
+	// stored in several bulks
+	if bulks := storage.SplitBulks(flows, c.maxBulk); len(bulks) > 1 {
+		atomic.AddInt64(&c.oversized, 1)
+		logging.GetLogger().Warningf("%d flows stored at once, above the bulk size of %d, split into %d bulks", len(flows), c.maxBulk, len(bulks))
+	}
+
 	for _, flow := range flows {
-		err := c.indexer.Index("skydive", "flow", flow.UUID, "", "", nil, flow)
+		err := c.indexer.Index(c.index, "flow", flow.UUID, "", "", nil, &storedFlow{Flow: flow, Sequence: c.nextSequence()})
 		if err != nil {
 			logging.GetLogger().Errorf("Error while indexing: %s", err.Error())
 			continue
@@ -72,43 +129,222 @@ func (c *ElasticSearchStorage) StoreFlows(flows []*flow.Flow) error {
 	return nil
 }
 
-func (c *ElasticSearchStorage) SearchFlows(filters storage.Filters) ([]*flow.Flow, error) {
+// StoreFlowsAcked sends the bulk requests of the flows at once instead of
+// queuing them in the indexer, returning once Elasticsearch indexed them
+func (c *ElasticSearchStorage) StoreFlowsAcked(flows []*flow.Flow) error {
 	if c.started.Load() != true {
-		return nil, errors.New("ElasticSearchStorage is not yet started")
+		return errors.New("ElasticSearchStorage is not yet started")
 	}
 
-	query := map[string]interface{}{
-		"sort": map[string]interface{}{
-			"Statistics.Last": map[string]string{
-				"order": "desc",
-			},
-		},
-		"from": 0,
-		"size": 5,
+	if c.attributes != nil {
+		interned, err := c.attributes.Intern(flows)
+		if err != nil {
+			return err
+		}
+		flows = interned
 	}
-	if len(filters) > 0 {
-		query = map[string]interface{}{
+
+	for _, bulk := range storage.SplitBulks(flows, c.maxBulk) {
+		var buf bytes.Buffer
+		for _, flow := range bulk {
+			data, err := elastigo.WriteBulkBytes("index", c.index, "flow", flow.UUID, "", "", nil, &storedFlow{Flow: flow, Sequence: c.nextSequence()})
+			if err != nil {
+				return err
+			}
+			buf.Write(data)
+		}
+		if err := c.sendBulk(&buf); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// MaxBulkSize returns the maximum number of flows of a bulk request
+func (c *ElasticSearchStorage) MaxBulkSize() int {
+	return c.maxBulk
+}
+
+// OversizedBatches returns the number of batches given to StoreFlows above
+// the bulk size
+func (c *ElasticSearchStorage) OversizedBatches() int64 {
+	return atomic.LoadInt64(&c.oversized)
+}
+
+func fieldQuery(field string, value interface{}) map[string]interface{} {
+	switch value := value.(type) {
+	case storage.Range:
+		return map[string]interface{}{"range": map[string]interface{}{field: value}}
+	case storage.In:
+		return map[string]interface{}{"terms": map[string]interface{}{field: value}}
+	case storage.Exists:
+		exists := map[string]interface{}{"exists": map[string]interface{}{"field": field}}
+		if !value {
+			return mustNot(exists)
+		}
+		return exists
+	default:
+		return map[string]interface{}{"term": map[string]interface{}{field: value}}
+	}
+}
+
+func mustNot(query map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"bool": map[string]interface{}{"must_not": []interface{}{query}}}
+}
+
+// endpointQuery matches the flows having an endpoint of the type whose
+// value of the given side matches, or, with Exists, the flows having or not
+// such an endpoint side
+func endpointQuery(t flow.FlowEndpointType, sideA bool, value interface{}) map[string]interface{} {
+	side := "BA"
+	if sideA {
+		side = "AB"
+	}
+
+	exists, isExists := value.(storage.Exists)
+	if isExists {
+		value = storage.Exists(true)
+	}
+
+	query := map[string]interface{}{
+		"nested": map[string]interface{}{
+			"path": "Statistics.Endpoints",
 			"query": map[string]interface{}{
-				"term": filters,
-			},
-			"sort": map[string]interface{}{
-				"Statistics.Last": map[string]string{
-					"order": "desc",
+				"bool": map[string]interface{}{
+					"must": []interface{}{
+						fieldQuery("Statistics.Endpoints.Type", t.String()),
+						fieldQuery("Statistics.Endpoints."+side+".Value", value),
+					},
 				},
 			},
-			"from": 0,
-			"size": 5,
+		},
+	}
+	if isExists && !bool(exists) {
+		return mustNot(query)
+	}
+	return query
+}
+
+// filtersQuery returns the query of the filters, the endpoint keys, ex:
+// IPV4.A, being translated into queries of the nested endpoints
+func filtersQuery(filters storage.Filters) map[string]interface{} {
+	must := []interface{}{}
+	for k, v := range filters {
+		if t, sideA, ok := flow.EndpointFilterKey(k); ok {
+			must = append(must, endpointQuery(t, sideA, v))
+		} else {
+			must = append(must, fieldQuery(k, v))
 		}
 	}
 
-	q, err := json.Marshal(query)
+	return map[string]interface{}{
+		"bool": map[string]interface{}{
+			"must": must,
+		},
+	}
+}
+
+func (c *ElasticSearchStorage) SearchFlows(filters storage.Filters) ([]*flow.Flow, error) {
+	return c.search(context.Background(), filters, c.limit)
+}
+
+func (c *ElasticSearchStorage) SearchFlowsContext(ctx context.Context, filters storage.Filters) ([]*flow.Flow, error) {
+	return c.search(ctx, filters, c.limit)
+}
+
+// Partitions splits the range into periods of storage.search.partition_period
+// seconds of Statistics.Last, aligned on the epoch
+func (c *ElasticSearchStorage) Partitions(r storage.Range) []storage.Range {
+	if c.period <= 0 {
+		return []storage.Range{r}
+	}
+
+	end := r.Lt
+	if end == 0 {
+		end = time.Now().Unix() + 1
+	}
+
+	var partitions []storage.Range
+	for end > r.Gte {
+		start := (end - 1) / c.period * c.period
+		if start < r.Gte {
+			start = r.Gte
+		}
+		partitions = append(partitions, storage.Range{Gte: start, Lt: end})
+		end = start
+	}
+
+	if len(partitions) > 0 && r.Lt == 0 {
+		partitions[0].Lt = 0
+	}
+
+	return partitions
+}
+
+func (c *ElasticSearchStorage) SearchPartition(ctx context.Context, r storage.Range, filters storage.Filters, limit int) ([]*flow.Flow, error) {
+	return c.search(ctx, filters, limit)
+}
+
+// SearchFlowsPaged returns a page of the flows, the limit, the offset and
+// the order being given to ElasticSearch. The queries without limit return
+// at most analyzer.query_max_results flows.
+func (c *ElasticSearchStorage) SearchFlowsPaged(ctx context.Context, q storage.SearchQuery) ([]*flow.Flow, int, error) {
+	if q.Limit == 0 {
+		q.Limit = c.limit
+	}
+	return c.searchPage(ctx, q)
+}
+
+func (c *ElasticSearchStorage) search(ctx context.Context, filters storage.Filters, limit int) ([]*flow.Flow, error) {
+	flows, _, err := c.searchPage(ctx, storage.SearchQuery{Filters: filters, Limit: limit})
+	return flows, err
+}
+
+// searchQuery returns the ElasticSearch query of the search
+func searchQuery(q storage.SearchQuery) map[string]interface{} {
+	order := "asc"
+	if q.SortOrder().Desc {
+		order = "desc"
+	}
+
+	query := map[string]interface{}{
+		"sort": map[string]interface{}{
+			q.SortOrder().Field: map[string]string{
+				"order": order,
+			},
+		},
+		"from": q.Offset,
+	}
+	if q.Limit > 0 {
+		query["size"] = q.Limit
+	}
+	if len(q.Filters) > 0 {
+		query["query"] = filtersQuery(q.Filters)
+	}
+	return query
+}
+
+// searchPage returns the page of the flows of the query, along with the
+// number of flows matching the filters
+func (c *ElasticSearchStorage) searchPage(ctx context.Context, sq storage.SearchQuery) ([]*flow.Flow, int, error) {
+	if c.started.Load() != true {
+		return nil, 0, errors.New("ElasticSearchStorage is not yet started")
+	}
+
+	q, err := json.Marshal(searchQuery(sq))
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
-	out, err := c.connection.Search("skydive", "flow", nil, string(q))
+	data, err := c.query(ctx, "/"+c.index+"/flow/_search", string(q))
 	if err != nil {
-		return nil, err
+		return nil, 0, err
+	}
+
+	var out elastigo.SearchResult
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, 0, err
 	}
 
 	flows := []*flow.Flow{}
@@ -118,21 +354,140 @@ func (c *ElasticSearchStorage) SearchFlows(filters storage.Filters) ([]*flow.Flo
 			f := new(flow.Flow)
 			err := json.Unmarshal([]byte(*d.Source), f)
 			if err != nil {
-				return nil, err
+				return nil, 0, err
 			}
 
 			flows = append(flows, f)
 		}
 	}
 
-	return flows, nil
+	if c.attributes != nil && !storage.CompactAttributes(ctx) {
+		if err := c.attributes.Expand(flows); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return flows, out.Hits.Total, nil
+}
+
+// SearchFlowsSince returns the flows stored after the cursor. The flows
+// being searchable only once the bulk indexer flushed them, the ones stored
+// during the last storage.feed_delay seconds are left for the next call.
+func (c *ElasticSearchStorage) SearchFlowsSince(filters storage.Filters, cursor int64, limit int) ([]*flow.Flow, int64, error) {
+	if c.started.Load() != true {
+		return nil, cursor, errors.New("ElasticSearchStorage is not yet started")
+	}
+
+	since := make(storage.Filters)
+	for k, v := range filters {
+		since[k] = v
+	}
+	since["Sequence"] = storage.Range{Gte: cursor + 1, Lte: time.Now().Add(-c.feedDelay).UnixNano()}
+
+	query := map[string]interface{}{
+		"sort": map[string]interface{}{
+			"Sequence": map[string]string{
+				"order": "asc",
+			},
+		},
+		"from":  0,
+		"size":  limit,
+		"query": filtersQuery(since),
+	}
+
+	q, err := json.Marshal(query)
+	if err != nil {
+		return nil, cursor, err
+	}
+
+	data, err := c.query(context.Background(), "/"+c.index+"/flow/_search", string(q))
+	if err != nil {
+		return nil, cursor, err
+	}
+
+	var out elastigo.SearchResult
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, cursor, err
+	}
+
+	flows := []*flow.Flow{}
+	for _, d := range out.Hits.Hits {
+		sf := storedFlow{Flow: new(flow.Flow)}
+		if err := json.Unmarshal([]byte(*d.Source), &sf); err != nil {
+			return nil, cursor, err
+		}
+
+		flows = append(flows, sf.Flow)
+		cursor = sf.Sequence
+	}
+
+	if c.attributes != nil {
+		if err := c.attributes.Expand(flows); err != nil {
+			return nil, cursor, err
+		}
+	}
+
+	return flows, cursor, nil
+}
+
+func (c *ElasticSearchStorage) CountFlows(filters storage.Filters) (int, error) {
+	if c.started.Load() != true {
+		return 0, errors.New("ElasticSearchStorage is not yet started")
+	}
+
+	query := map[string]interface{}{}
+	if len(filters) > 0 {
+		query["query"] = filtersQuery(filters)
+	}
+
+	q, err := json.Marshal(query)
+	if err != nil {
+		return 0, err
+	}
+
+	data, err := c.query(context.Background(), "/"+c.index+"/flow/_count", string(q))
+	if err != nil {
+		return 0, err
+	}
+
+	var out elastigo.CountResponse
+	if err := json.Unmarshal(data, &out); err != nil {
+		return 0, err
+	}
+
+	return out.Count, nil
+}
+
+// Ping checks that the elasticsearch server answers
+func (c *ElasticSearchStorage) Ping(ctx context.Context) error {
+	code, _, err := c.requestContext(ctx, "GET", "/", "", "")
+	if err != nil {
+		return err
+	}
+	if code != 200 {
+		return fmt.Errorf("Elasticsearch returned code %d", code)
+	}
+	return nil
 }
 
 func (c *ElasticSearchStorage) request(method string, path string, query string, body string) (int, []byte, error) {
+	return c.requestContext(context.Background(), method, path, query, body)
+}
+
+// requestContext sends a request cancelled along with the context
+func (c *ElasticSearchStorage) requestContext(ctx context.Context, method string, path string, query string, body string) (int, []byte, error) {
 	req, err := c.connection.NewRequest(method, path, query)
 	if err != nil {
 		return 503, nil, err
 	}
+	req.Client = c.client
+	req.Cancel = ctx.Done()
+
+	// the slow logs and the tasks of elasticsearch tell the request they
+	// come from
+	if id := logging.ContextField(ctx, "request_id"); id != "" {
+		req.Header.Set("X-Opaque-Id", id)
+	}
 
 	if body != "" {
 		req.SetBodyString(body)
@@ -142,8 +497,51 @@ func (c *ElasticSearchStorage) request(method string, path string, query string,
 	return req.Do(&response)
 }
 
+// query sends a search or a count request, the error replies of
+// elasticsearch, as a malformed query or a page past the result window,
+// being returned as errors
+func (c *ElasticSearchStorage) query(ctx context.Context, path string, body string) ([]byte, error) {
+	code, data, err := c.requestContext(ctx, "POST", path, "", body)
+	if err != nil {
+		return nil, err
+	}
+	if code >= 300 {
+		return nil, replyError(code, data)
+	}
+	return data, nil
+}
+
+// replyError returns the error of an error reply of elasticsearch, giving
+// the reason of its root cause
+func replyError(code int, data []byte) error {
+	reason := strings.TrimSpace(string(data))
+
+	var reply struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if err := json.Unmarshal(data, &reply); err == nil && len(reply.Error) > 0 {
+		var cause struct {
+			Reason    string `json:"reason"`
+			RootCause []struct {
+				Reason string `json:"reason"`
+			} `json:"root_cause"`
+		}
+		var message string
+		switch {
+		case json.Unmarshal(reply.Error, &message) == nil:
+			reason = message
+		case json.Unmarshal(reply.Error, &cause) == nil && len(cause.RootCause) > 0 && cause.RootCause[0].Reason != "":
+			reason = cause.RootCause[0].Reason
+		case cause.Reason != "":
+			reason = cause.Reason
+		}
+	}
+
+	return fmt.Errorf("Elasticsearch returned code %d: %s", code, reason)
+}
+
 func (c *ElasticSearchStorage) initialize() error {
-	indexPath := fmt.Sprintf("/skydive_v%d", indexVersion)
+	indexPath := fmt.Sprintf("/%s_v%d", c.index, indexVersion)
 
 	code, _, _ := c.request("GET", indexPath, "", "")
 	if code == 200 {
@@ -152,41 +550,128 @@ func (c *ElasticSearchStorage) initialize() error {
 
 	code, _, _ = c.request("PUT", indexPath, "", mapping)
 	if code != 200 {
-		return errors.New("Unable to create the skydive index: " + strconv.FormatInt(int64(code), 10))
+		return errors.New("Unable to create the " + c.index + " index: " + strconv.FormatInt(int64(code), 10))
 	}
 
 	aliases := `{"actions": [`
 
+	// the alias is moved from the indices of the previous versions
 	code, data, _ := c.request("GET", "/_aliases", "", "")
 	if code == 200 {
-		var current map[string]interface{}
+		var current map[string]struct {
+			Aliases map[string]interface{} `json:"aliases"`
+		}
 
 		err := json.Unmarshal(data, &current)
 		if err != nil {
 			return errors.New("Unable to parse aliases: " + err.Error())
 		}
 
-		for k := range current {
-			if strings.HasPrefix(k, "skydive_") {
-				remove := `{"remove":{"alias": "skydive", "index": "%s"}},`
-				aliases += fmt.Sprintf(remove, k)
+		for k, v := range current {
+			if _, ok := v.Aliases[c.index]; ok && strings.HasPrefix(k, c.index+"_") {
+				remove := `{"remove":{"alias": "%s", "index": "%s"}},`
+				aliases += fmt.Sprintf(remove, c.index, k)
 			}
 		}
 	}
 
-	add := `{"add":{"alias": "skydive", "index": "skydive_v%d"}}]}`
-	aliases += fmt.Sprintf(add, indexVersion)
+	add := `{"add":{"alias": "%s", "index": "%s_v%d"}}]}`
+	aliases += fmt.Sprintf(add, c.index, c.index, indexVersion)
 
 	code, _, _ = c.request("POST", "/_aliases", "", aliases)
 	if code != 200 {
-		return errors.New("Unable to create an alias to the skydive index: " + strconv.FormatInt(int64(code), 10))
+		return errors.New("Unable to create an alias to the " + c.index + " index: " + strconv.FormatInt(int64(code), 10))
 	}
 
-	logging.GetLogger().Infof("ElasticSearchStorage started")
+	logging.GetLogger().Infof("ElasticSearchStorage started on %s", c.index)
 
 	return nil
 }
 
+// sendBulk sends the bulk requests through the storage client instead of the
+// default one used by the indexer
+func (c *ElasticSearchStorage) sendBulk(buf *bytes.Buffer) error {
+	code, data, err := c.request("POST", "/_bulk", "refresh=false", buf.String())
+	if err != nil {
+		return err
+	}
+	if code != 200 {
+		return fmt.Errorf("Bulk insertion failed with code %d", code)
+	}
+
+	var response struct {
+		Errors bool                     `json:"errors"`
+		Items  []map[string]interface{} `json:"items"`
+	}
+	if err := json.Unmarshal(data, &response); err == nil && response.Errors {
+		return fmt.Errorf("Bulk insertion error, failed item count %d", len(response.Items))
+	}
+
+	return nil
+}
+
+// StoreAttributeSets indexes the attribute sets in the side index, keyed by
+// their hash. The request is sent at once so that the sets are stored before
+// the flows referencing them.
+func (c *ElasticSearchStorage) StoreAttributeSets(sets map[string]map[string]string) error {
+	var buf bytes.Buffer
+	for hash, attrs := range sets {
+		data, err := elastigo.WriteBulkBytes("index", attributesIndex, "attributes", hash, "", "", nil, map[string]interface{}{"Attributes": attrs})
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+	}
+	return c.sendBulk(&buf)
+}
+
+// GetAttributeSets returns the attribute sets of the hashes found in the
+// side index
+func (c *ElasticSearchStorage) GetAttributeSets(hashes []string) (map[string]map[string]string, error) {
+	q, err := json.Marshal(map[string]interface{}{"ids": hashes})
+	if err != nil {
+		return nil, err
+	}
+
+	code, data, err := c.request("POST", "/"+attributesIndex+"/attributes/_mget", "", string(q))
+	if err != nil {
+		return nil, err
+	}
+	if code != 200 {
+		return nil, fmt.Errorf("Unable to get the attribute sets: %d", code)
+	}
+
+	var out struct {
+		Docs []struct {
+			ID     string `json:"_id"`
+			Found  bool   `json:"found"`
+			Source struct {
+				Attributes map[string]string
+			} `json:"_source"`
+		} `json:"docs"`
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, err
+	}
+
+	sets := make(map[string]map[string]string)
+	for _, doc := range out.Docs {
+		if doc.Found {
+			sets[doc.ID] = doc.Source.Attributes
+		}
+	}
+	return sets, nil
+}
+
+// ResolveAttributes returns the attribute sets referenced by the flows of
+// the compact searches
+func (c *ElasticSearchStorage) ResolveAttributes(hashes []string) (map[string]map[string]string, error) {
+	if c.attributes == nil {
+		return nil, storage.ErrInterningNotEnabled
+	}
+	return c.attributes.Resolve(hashes)
+}
+
 var ErrBadConfig = errors.New("elasticsearch : Config file is misconfigured, check elasticsearch key format")
 
 func (c *ElasticSearchStorage) start() {
@@ -201,6 +686,10 @@ func (c *ElasticSearchStorage) start() {
 	}
 
 	c.indexer = c.connection.NewBulkIndexerErrors(10, 60)
+	c.indexer.Sender = c.sendBulk
+	if c.maxBulk > 0 {
+		c.indexer.BulkMaxDocs = c.maxBulk
+	}
 	c.indexer.Start()
 
 	c.started.Store(true)
@@ -218,6 +707,11 @@ func (c *ElasticSearchStorage) Stop() {
 }
 
 func New() (*ElasticSearchStorage, error) {
+	return newStorage("skydive")
+}
+
+// newStorage returns a storage of the flows in the index aliased by index
+func newStorage(index string) (*ElasticSearchStorage, error) {
 	c := elastigo.NewConn()
 
 	elasticonfig := strings.Split(config.GetConfig().GetString("storage.elasticsearch"), ":")
@@ -227,8 +721,71 @@ func New() (*ElasticSearchStorage, error) {
 	c.Domain = elasticonfig[0]
 	c.Port = elasticonfig[1]
 
-	storage := &ElasticSearchStorage{connection: c}
-	storage.started.Store(false)
+	client, err := shttp.NewClientFromConfig("elasticsearch")
+	if err != nil {
+		return nil, err
+	}
+
+	es := &ElasticSearchStorage{
+		index:      index,
+		connection: c,
+		client:     client,
+		feedDelay:  time.Duration(config.GetConfig().GetInt("storage.feed_delay")) * time.Second,
+		period:     int64(config.GetConfig().GetInt("storage.search.partition_period")),
+		limit:      config.GetConfig().GetInt("analyzer.query_max_results"),
+		maxBulk:    config.GetConfig().GetInt("storage.max_bulk_size"),
+	}
+	es.attributes = storage.NewAttributeDictionaryFromConfig(es)
+	es.started.Store(false)
+
+	return es, nil
+}
+
+// routePrefix prefixes the indices of the partitions of a routed storage
+const routePrefix = "skydive-"
+
+// Router creates the storages of the partitions of the flows, each one in
+// its own index
+type Router struct {
+	es *ElasticSearchStorage
+}
+
+func (r *Router) NewRoute(name string) (storage.Storage, error) {
+	return newStorage(routePrefix + name)
+}
+
+// Routes returns the partitions having an index
+func (r *Router) Routes() ([]string, error) {
+	code, data, err := r.es.request("GET", "/_aliases", "", "")
+	if err != nil {
+		return nil, err
+	}
+	if code != 200 {
+		return nil, fmt.Errorf("Unable to get the aliases: %d", code)
+	}
+
+	var current map[string]struct {
+		Aliases map[string]interface{} `json:"aliases"`
+	}
+	if err := json.Unmarshal(data, &current); err != nil {
+		return nil, err
+	}
 
-	return storage, nil
+	var routes []string
+	for _, v := range current {
+		for alias := range v.Aliases {
+			if strings.HasPrefix(alias, routePrefix) {
+				routes = append(routes, strings.TrimPrefix(alias, routePrefix))
+			}
+		}
+	}
+	return routes, nil
+}
+
+func NewRouter() (*Router, error) {
+	es, err := New()
+	if err != nil {
+		return nil, err
+	}
+	return &Router{es: es}, nil
 }
@@ -0,0 +1,412 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package elasticsearch
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/redhat-cip/skydive/config"
+	"github.com/redhat-cip/skydive/flow"
+	"github.com/redhat-cip/skydive/storage"
+)
+
+// fakeBulkServer acknowledges the bulk requests, recording the number of
+// flows of each of them
+type fakeBulkServer struct {
+	sync.Mutex
+	bulks []int
+}
+
+func (f *fakeBulkServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/_bulk" {
+		w.Write([]byte("{}"))
+		return
+	}
+
+	docs := 0
+	scanner := bufio.NewScanner(r.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if strings.HasPrefix(scanner.Text(), `{"index"`) {
+			docs++
+		}
+	}
+
+	f.Lock()
+	f.bulks = append(f.bulks, docs)
+	f.Unlock()
+
+	w.Write([]byte(`{"errors":false,"items":[]}`))
+}
+
+func (f *fakeBulkServer) stored() (total int, largest int) {
+	f.Lock()
+	defer f.Unlock()
+
+	for _, docs := range f.bulks {
+		total += docs
+		if docs > largest {
+			largest = docs
+		}
+	}
+	return
+}
+
+func TestStoreFlowsOversized(t *testing.T) {
+	fake := &fakeBulkServer{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	config.GetConfig().Set("storage.elasticsearch", strings.TrimPrefix(server.URL, "http://"))
+	config.GetConfig().Set("storage.max_bulk_size", 100)
+	defer config.GetConfig().Set("storage.max_bulk_size", 1000)
+
+	es, err := New()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	es.start()
+	defer es.Stop()
+
+	if es.MaxBulkSize() != 100 {
+		t.Fatalf("Expected a bulk size of 100, got %d", es.MaxBulkSize())
+	}
+
+	flows := make([]*flow.Flow, 250)
+	for i := range flows {
+		flows[i] = &flow.Flow{UUID: fmt.Sprintf("flow-%d", i)}
+	}
+
+	if err := es.StoreFlows(flows); err != nil {
+		t.Fatalf("Expected the oversized batch to be stored, got %s", err.Error())
+	}
+	if es.OversizedBatches() != 1 {
+		t.Errorf("Expected the oversized batch to be reported, got %d", es.OversizedBatches())
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	total, largest := fake.stored()
+	for total < len(flows) && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+		es.indexer.Flush()
+		total, largest = fake.stored()
+	}
+
+	if total != len(flows) {
+		t.Errorf("Expected %d flows to be stored, got %d", len(flows), total)
+	}
+	if largest > 100 {
+		t.Errorf("Expected bulks of at most 100 flows, got %d", largest)
+	}
+
+	if err := es.StoreFlows(flows[:100]); err != nil || es.OversizedBatches() != 1 {
+		t.Errorf("Expected a batch of the bulk size not to be reported: %v, %d", err, es.OversizedBatches())
+	}
+}
+
+func TestAttributeSetsIndex(t *testing.T) {
+	var lock sync.Mutex
+	docs := make(map[string]json.RawMessage)
+
+	// the side index keeps the documents of the bulk requests, returned by
+	// the multi get requests
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		switch r.URL.Path {
+		case "/_bulk":
+			decoder := json.NewDecoder(r.Body)
+			for {
+				var action struct {
+					Index struct {
+						Index string `json:"_index"`
+						ID    string `json:"_id"`
+					} `json:"index"`
+				}
+				var source json.RawMessage
+				if decoder.Decode(&action) != nil || decoder.Decode(&source) != nil {
+					break
+				}
+				if action.Index.Index == attributesIndex {
+					docs[action.Index.ID] = source
+				}
+			}
+			w.Write([]byte(`{"errors":false,"items":[]}`))
+		case "/" + attributesIndex + "/attributes/_mget":
+			var query struct{ IDs []string }
+			json.NewDecoder(r.Body).Decode(&query)
+
+			var found []map[string]interface{}
+			for _, id := range query.IDs {
+				if source, ok := docs[id]; ok {
+					found = append(found, map[string]interface{}{"_id": id, "found": true, "_source": source})
+				} else {
+					found = append(found, map[string]interface{}{"_id": id, "found": false})
+				}
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"docs": found})
+		default:
+			w.Write([]byte("{}"))
+		}
+	}))
+	defer server.Close()
+
+	config.GetConfig().Set("storage.elasticsearch", strings.TrimPrefix(server.URL, "http://"))
+
+	es, err := New()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	attrs := map[string]string{flow.FlowAttributeASNB: "64500", "TLS_SNI": "example.com"}
+	hash := storage.AttributeSetHash(attrs)
+	if err := es.StoreAttributeSets(map[string]map[string]string{hash: attrs}); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	sets, err := es.GetAttributeSets([]string{hash, "unknown"})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(sets) != 1 || !reflect.DeepEqual(sets[hash], attrs) {
+		t.Errorf("Expected the attribute set to be found, got %v", sets)
+	}
+
+	if _, err := es.ResolveAttributes([]string{hash}); err != storage.ErrInterningNotEnabled {
+		t.Errorf("Expected the interning to be disabled by default, got %v", err)
+	}
+}
+
+func TestFiltersQueryRange(t *testing.T) {
+	filters, err := storage.Aliases{}.Translate(storage.Filters{"Statistics.Last.gte": "1460000000", "Statistics.Last.lt": "1470000000"})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	data, err := json.Marshal(filtersQuery(filters))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expected := `{"bool":{"must":[{"range":{"Statistics.Last":{"gte":1460000000,"lt":1470000000}}}]}}`
+	if string(data) != expected {
+		t.Errorf("Expected the range query %s, got %s", expected, data)
+	}
+}
+
+func TestFiltersQueryIn(t *testing.T) {
+	filters, err := storage.Aliases{}.Translate(storage.Filters{"LayersPath__in": "Ethernet/IPv4/TCP,Ethernet/IPv4/UDP"})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	data, err := json.Marshal(filtersQuery(filters))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expected := `{"bool":{"must":[{"terms":{"LayersPath":["Ethernet/IPv4/TCP","Ethernet/IPv4/UDP"]}}]}}`
+	if string(data) != expected {
+		t.Errorf("Expected the terms query %s, got %s", expected, data)
+	}
+}
+
+func TestSearchQuerySort(t *testing.T) {
+	for _, test := range []struct {
+		query    storage.SearchQuery
+		expected string
+	}{
+		{storage.SearchQuery{Limit: 10}, `{"from":0,"size":10,"sort":{"Statistics.Last":{"order":"desc"}}}`},
+		{storage.SearchQuery{Offset: 5, Sort: storage.Sort{Field: "Duration"}}, `{"from":5,"sort":{"Duration":{"order":"asc"}}}`},
+	} {
+		data, err := json.Marshal(searchQuery(test.query))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if string(data) != test.expected {
+			t.Errorf("Expected the query %s, got %s", test.expected, data)
+		}
+	}
+}
+
+func TestPing(t *testing.T) {
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	config.GetConfig().Set("storage.elasticsearch", strings.TrimPrefix(server.URL, "http://"))
+
+	es, err := New()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if err := es.Ping(context.Background()); err != nil {
+		t.Errorf("Expected the server to answer, got %s", err.Error())
+	}
+
+	status = http.StatusServiceUnavailable
+	if err := es.Ping(context.Background()); err == nil {
+		t.Error("Expected an unavailable server to fail the ping")
+	}
+}
+
+func TestSearchErrorReply(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/_search") || strings.HasSuffix(r.URL.Path, "/_count") {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":{"root_cause":[{"type":"query_parsing_exception","reason":"No query registered for [foo]"}],"type":"search_phase_execution_exception","reason":"all shards failed"},"status":400}`))
+			return
+		}
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	config.GetConfig().Set("storage.elasticsearch", strings.TrimPrefix(server.URL, "http://"))
+
+	es, err := New()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	es.start()
+	defer es.Stop()
+
+	const reason = "No query registered for [foo]"
+
+	if _, err := es.SearchFlows(storage.Filters{}); err == nil || !strings.Contains(err.Error(), reason) {
+		t.Errorf("Expected the search to fail with the reason of the reply, got %v", err)
+	}
+
+	if _, _, err := es.SearchFlowsSince(storage.Filters{}, 0, 10); err == nil || !strings.Contains(err.Error(), reason) {
+		t.Errorf("Expected the incremental search to fail with the reason of the reply, got %v", err)
+	}
+
+	if _, err := es.CountFlows(storage.Filters{}); err == nil || !strings.Contains(err.Error(), reason) {
+		t.Errorf("Expected the count to fail with the reason of the reply, got %v", err)
+	}
+}
+
+func TestReplyError(t *testing.T) {
+	tests := []struct {
+		code     int
+		data     string
+		expected string
+	}{
+		{404, `{"error":"IndexMissingException[[skydive] missing]","status":404}`, "Elasticsearch returned code 404: IndexMissingException[[skydive] missing]"},
+		{400, `{"error":{"type":"illegal_argument_exception","reason":"Result window is too large"},"status":400}`, "Elasticsearch returned code 400: Result window is too large"},
+		{502, "Bad Gateway\n", "Elasticsearch returned code 502: Bad Gateway"},
+	}
+
+	for _, test := range tests {
+		if err := replyError(test.code, []byte(test.data)); err.Error() != test.expected {
+			t.Errorf("Expected the error %q, got %q", test.expected, err.Error())
+		}
+	}
+}
+
+func TestFiltersQueryEndpoint(t *testing.T) {
+	filters, err := storage.Aliases{}.Translate(storage.Filters{"IPV4.B__in": "10.0.0.1,10.0.0.2"})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	data, err := json.Marshal(filtersQuery(filters))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expected := `{"bool":{"must":[{"nested":{"path":"Statistics.Endpoints","query":{"bool":{"must":[` +
+		`{"term":{"Statistics.Endpoints.Type":"IPV4"}},{"terms":{"Statistics.Endpoints.BA.Value":["10.0.0.1","10.0.0.2"]}}]}}}}]}}`
+	if string(data) != expected {
+		t.Errorf("Expected the nested endpoint query %s, got %s", expected, data)
+	}
+
+	data, err = json.Marshal(filtersQuery(storage.Filters{"TCPPORT.A": "80"}))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expected = `{"bool":{"must":[{"nested":{"path":"Statistics.Endpoints","query":{"bool":{"must":[` +
+		`{"term":{"Statistics.Endpoints.Type":"TCPPORT"}},{"term":{"Statistics.Endpoints.AB.Value":"80"}}]}}}}]}}`
+	if string(data) != expected {
+		t.Errorf("Expected the nested endpoint query %s, got %s", expected, data)
+	}
+}
+
+func TestMapping(t *testing.T) {
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(mapping), &m); err != nil {
+		t.Errorf("Expected a valid mapping, got %s", err.Error())
+	}
+}
+
+func TestFiltersQueryExists(t *testing.T) {
+	for _, test := range []struct {
+		filters  storage.Filters
+		expected string
+	}{
+		{
+			storage.Filters{"UDPPORT.A__exists": "true"},
+			`{"bool":{"must":[{"nested":{"path":"Statistics.Endpoints","query":{"bool":{"must":[` +
+				`{"term":{"Statistics.Endpoints.Type":"UDPPORT"}},{"exists":{"field":"Statistics.Endpoints.AB.Value"}}]}}}}]}}`,
+		},
+		{
+			storage.Filters{"IPV4.B__exists": "false"},
+			`{"bool":{"must":[{"bool":{"must_not":[{"nested":{"path":"Statistics.Endpoints","query":{"bool":{"must":[` +
+				`{"term":{"Statistics.Endpoints.Type":"IPV4"}},{"exists":{"field":"Statistics.Endpoints.BA.Value"}}]}}}}]}}]}}`,
+		},
+		{
+			storage.Filters{"Attributes.JA3__exists": "false"},
+			`{"bool":{"must":[{"bool":{"must_not":[{"exists":{"field":"Attributes.JA3"}}]}}]}}`,
+		},
+	} {
+		filters, err := storage.Aliases{}.Translate(test.filters)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		data, err := json.Marshal(filtersQuery(filters))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if string(data) != test.expected {
+			t.Errorf("Expected the exists query %s, got %s", test.expected, data)
+		}
+	}
+}
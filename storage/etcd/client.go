@@ -29,15 +29,17 @@ import (
 	etcd "github.com/coreos/etcd/client"
 
 	"github.com/redhat-cip/skydive/config"
+	shttp "github.com/redhat-cip/skydive/http"
 )
 
 type EtcdClient struct {
-	Client  *etcd.Client
-	KeysApi etcd.KeysAPI
+	Client    *etcd.Client
+	KeysApi   etcd.KeysAPI
+	transport etcd.CancelableTransport
 }
 
 func (client *EtcdClient) Stop() {
-	if tr, ok := etcd.DefaultTransport.(interface {
+	if tr, ok := client.transport.(interface {
 		CloseIdleConnections()
 	}); ok {
 		tr.CloseIdleConnections()
@@ -45,9 +47,14 @@ func (client *EtcdClient) Stop() {
 }
 
 func NewEtcdClient(etcdServers []string) (*EtcdClient, error) {
+	transport, err := shttp.NewTransportFromConfig("etcd")
+	if err != nil {
+		return nil, fmt.Errorf("Failed to set up the etcd transport: %s", err)
+	}
+
 	cfg := etcd.Config{
 		Endpoints: etcdServers,
-		Transport: etcd.DefaultTransport,
+		Transport: transport,
 		// set timeout per request to fail fast when the target endpoint is unavailable
 		HeaderTimeoutPerRequest: time.Second,
 	}
@@ -60,8 +67,9 @@ func NewEtcdClient(etcdServers []string) (*EtcdClient, error) {
 	kapi := etcd.NewKeysAPI(etcdClient)
 
 	return &EtcdClient{
-		Client:  &etcdClient,
-		KeysApi: kapi,
+		Client:    &etcdClient,
+		KeysApi:   kapi,
+		transport: transport,
 	}, nil
 }
 
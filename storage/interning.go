@@ -0,0 +1,215 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package storage
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/pmylund/go-cache"
+	"golang.org/x/net/context"
+
+	"github.com/redhat-cip/skydive/config"
+	"github.com/redhat-cip/skydive/flow"
+)
+
+// AttributesRefKey is the only attribute of an interned flow, giving the
+// hash of its attribute set
+const AttributesRefKey = "@ref"
+
+// ErrInterningNotEnabled is returned when resolving attribute sets with a
+// storage not interning them
+var ErrInterningNotEnabled = errors.New("The storage doesn't intern the flow attributes")
+
+// AttributeSets is the side table of the interned attribute sets, keyed by
+// the hash of their content, implemented by each storage
+type AttributeSets interface {
+	StoreAttributeSets(sets map[string]map[string]string) error
+	GetAttributeSets(hashes []string) (map[string]map[string]string, error)
+}
+
+// AttributeResolver is implemented by the storages interning the attribute
+// sets, resolving the references returned by the compact searches
+type AttributeResolver interface {
+	ResolveAttributes(hashes []string) (map[string]map[string]string, error)
+}
+
+type compactKey struct{}
+
+// WithCompactAttributes returns a context asking the searches to return the
+// interned flows with the references of their attribute sets
+func WithCompactAttributes(ctx context.Context) context.Context {
+	return context.WithValue(ctx, compactKey{}, true)
+}
+
+// CompactAttributes returns whether the searches done with the context
+// return the references of the attribute sets
+func CompactAttributes(ctx context.Context) bool {
+	compact, _ := ctx.Value(compactKey{}).(bool)
+	return compact
+}
+
+// AttributeSetHash returns the hash of the content of an attribute set
+func AttributeSetHash(attrs map[string]string) string {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	h := sha1.New()
+	for _, k := range keys {
+		h.Write([]byte(k))
+		h.Write([]byte{0})
+		h.Write([]byte(attrs[k]))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// AttributeDictionary interns the attribute sets of the flows in a side
+// table, the flows referencing them by hash. The sets recently stored or
+// read are kept in memory, so that the repeated ones are stored once and
+// the expansion of the search results mostly doesn't query the side table.
+type AttributeDictionary struct {
+	sets AttributeSets
+	hot  *cache.Cache
+}
+
+// Intern returns copies of the flows with their attribute set replaced by
+// a reference, the sets not known yet being stored first
+func (d *AttributeDictionary) Intern(flows []*flow.Flow) ([]*flow.Flow, error) {
+	interned := make([]*flow.Flow, len(flows))
+	missing := make(map[string]map[string]string)
+
+	for i, f := range flows {
+		if len(f.Attributes) == 0 {
+			interned[i] = f
+			continue
+		}
+
+		hash := AttributeSetHash(f.Attributes)
+		if _, ok := d.hot.Get(hash); !ok {
+			missing[hash] = f.Attributes
+		}
+
+		cp := *f
+		cp.Attributes = map[string]string{AttributesRefKey: hash}
+		interned[i] = &cp
+	}
+
+	if len(missing) > 0 {
+		if err := d.sets.StoreAttributeSets(missing); err != nil {
+			return nil, err
+		}
+		for hash, attrs := range missing {
+			d.hot.Set(hash, attrs, cache.DefaultExpiration)
+		}
+	}
+
+	return interned, nil
+}
+
+// Resolve returns the attribute sets of the hashes, querying the side table
+// once for the ones not in memory
+func (d *AttributeDictionary) Resolve(hashes []string) (map[string]map[string]string, error) {
+	resolved := make(map[string]map[string]string)
+
+	var missing []string
+	for _, hash := range hashes {
+		if _, ok := resolved[hash]; ok {
+			continue
+		}
+		if attrs, ok := d.hot.Get(hash); ok {
+			resolved[hash] = attrs.(map[string]string)
+			// the hot entries stay in memory
+			d.hot.Set(hash, attrs, cache.DefaultExpiration)
+		} else {
+			missing = append(missing, hash)
+		}
+	}
+
+	if len(missing) > 0 {
+		sets, err := d.sets.GetAttributeSets(missing)
+		if err != nil {
+			return nil, err
+		}
+		for hash, attrs := range sets {
+			resolved[hash] = attrs
+			d.hot.Set(hash, attrs, cache.DefaultExpiration)
+		}
+	}
+
+	return resolved, nil
+}
+
+// Expand replaces the references of the interned flows by their attribute
+// set, the sets missing from the side table being left as references
+func (d *AttributeDictionary) Expand(flows []*flow.Flow) error {
+	var hashes []string
+	for _, f := range flows {
+		if hash, ok := f.Attributes[AttributesRefKey]; ok {
+			hashes = append(hashes, hash)
+		}
+	}
+	if len(hashes) == 0 {
+		return nil
+	}
+
+	sets, err := d.Resolve(hashes)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range flows {
+		if attrs, ok := sets[f.Attributes[AttributesRefKey]]; ok {
+			f.Attributes = make(map[string]string, len(attrs))
+			for k, v := range attrs {
+				f.Attributes[k] = v
+			}
+		}
+	}
+
+	return nil
+}
+
+// NewAttributeDictionary returns a dictionary keeping the sets used during
+// the last expire seconds in memory
+func NewAttributeDictionary(sets AttributeSets, expire int) *AttributeDictionary {
+	return &AttributeDictionary{
+		sets: sets,
+		hot:  cache.New(time.Duration(expire)*time.Second, time.Duration(expire)*time.Second),
+	}
+}
+
+// NewAttributeDictionaryFromConfig returns the dictionary of the storage
+// when storage.interning.enabled is set, nil otherwise
+func NewAttributeDictionaryFromConfig(sets AttributeSets) *AttributeDictionary {
+	if !config.GetConfig().GetBool("storage.interning.enabled") {
+		return nil
+	}
+	return NewAttributeDictionary(sets, config.GetConfig().GetInt("storage.interning.cache_expire"))
+}
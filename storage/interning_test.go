@@ -0,0 +1,175 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/redhat-cip/skydive/flow"
+)
+
+// memoryAttributeSets is a side table counting its queries
+type memoryAttributeSets struct {
+	sets    map[string]map[string]string
+	stores  int
+	queries int
+}
+
+func (m *memoryAttributeSets) StoreAttributeSets(sets map[string]map[string]string) error {
+	m.stores++
+	for hash, attrs := range sets {
+		m.sets[hash] = attrs
+	}
+	return nil
+}
+
+func (m *memoryAttributeSets) GetAttributeSets(hashes []string) (map[string]map[string]string, error) {
+	m.queries++
+	sets := make(map[string]map[string]string)
+	for _, hash := range hashes {
+		if attrs, ok := m.sets[hash]; ok {
+			sets[hash] = attrs
+		}
+	}
+	return sets, nil
+}
+
+// syntheticFlows returns flows enriched like the ones of a cluster: the
+// attributes of the capturing interface and of the remote endpoint, among
+// a few of them, are repeated over the flows
+func syntheticFlows(count int) []*flow.Flow {
+	flows := make([]*flow.Flow, count)
+	for i := range flows {
+		iface, remote := i%8, i%50
+		flows[i] = &flow.Flow{
+			UUID:       fmt.Sprintf("%040x", i),
+			LayersPath: "Ethernet/IPv4/TCP",
+			Statistics: &flow.FlowStatistics{
+				Start: 1470000000 + int64(i),
+				Last:  1470000060 + int64(i),
+				Endpoints: []*flow.FlowEndpointsStatistics{
+					{
+						Type: flow.FlowEndpointType_IPV4,
+						AB:   &flow.FlowEndpointStatistics{Value: fmt.Sprintf("10.0.%d.%d", iface, i%250), Packets: 12, Bytes: 1500},
+						BA:   &flow.FlowEndpointStatistics{Value: fmt.Sprintf("203.0.113.%d", remote), Packets: 10, Bytes: 9000},
+					},
+				},
+			},
+			ProbeNodeUUID: fmt.Sprintf("probe-%d", iface),
+			Attributes: map[string]string{
+				"IfName":               fmt.Sprintf("eth%d", iface),
+				"IfDriver":             "virtio_net",
+				"IfMTU":                "1500",
+				"IfNamespace":          fmt.Sprintf("/var/run/netns/tenant-%d", iface),
+				flow.FlowAttributeASNB: fmt.Sprintf("%d", 64500+remote),
+				"GeoIP_B_Country":      "FR",
+				"GeoIP_B_City":         fmt.Sprintf("City %d", remote),
+				"GeoIP_B_Coords":       fmt.Sprintf("48.%04d,2.%04d", remote*37, remote*53),
+				"TLS_SNI":              fmt.Sprintf("service-%d.example.com", remote),
+			},
+		}
+	}
+	return flows
+}
+
+func jsonSize(t *testing.T, value interface{}) int {
+	data, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return len(data)
+}
+
+func TestAttributeInterningSize(t *testing.T) {
+	flows := syntheticFlows(10000)
+	sets := &memoryAttributeSets{sets: make(map[string]map[string]string)}
+	dict := NewAttributeDictionary(sets, 600)
+
+	interned, err := dict.Intern(flows)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	full := jsonSize(t, flows)
+	compact := jsonSize(t, interned) + jsonSize(t, sets.sets)
+	t.Logf("%d flows, %d attribute sets: %d bytes, %d bytes interned (%.1f%%)", len(flows), len(sets.sets), full, compact, 100*float64(compact)/float64(full))
+
+	if len(sets.sets) != 200 || sets.stores != 1 {
+		t.Errorf("Expected the 200 distinct sets to be stored at once, got %d sets in %d stores", len(sets.sets), sets.stores)
+	}
+	if compact > full*3/4 {
+		t.Errorf("Expected the interning to save a quarter of the size at least: %d bytes, %d bytes interned", full, compact)
+	}
+
+	// the flows given aren't modified
+	if _, ok := flows[0].Attributes[AttributesRefKey]; ok {
+		t.Error("Expected the flows to be copied")
+	}
+
+	// the known sets aren't stored again
+	if _, err := dict.Intern(flows); err != nil || sets.stores != 1 {
+		t.Errorf("Expected the known sets not to be stored again: %v, %d stores", err, sets.stores)
+	}
+}
+
+func TestAttributeInterningExpand(t *testing.T) {
+	flows := syntheticFlows(100)
+	sets := &memoryAttributeSets{sets: make(map[string]map[string]string)}
+
+	interned, err := NewAttributeDictionary(sets, 600).Intern(flows)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// a dictionary without the sets in memory queries the side table once
+	// per expansion, then serves the hot ones from memory
+	dict := NewAttributeDictionary(sets, 600)
+	for i := 0; i < 3; i++ {
+		read := make([]*flow.Flow, len(interned))
+		for j, f := range interned {
+			cp := *f
+			read[j] = &cp
+		}
+
+		if err := dict.Expand(read); err != nil {
+			t.Fatal(err.Error())
+		}
+		for j := range read {
+			if !reflect.DeepEqual(read[j].Attributes, flows[j].Attributes) {
+				t.Fatalf("Wrong attributes of flow %d: %v", j, read[j].Attributes)
+			}
+		}
+	}
+	if sets.queries != 1 {
+		t.Errorf("Expected the side table to be queried once, got %d", sets.queries)
+	}
+
+	// an unknown reference is left as is
+	unknown := &flow.Flow{Attributes: map[string]string{AttributesRefKey: "unknown"}}
+	if err := dict.Expand([]*flow.Flow{unknown}); err != nil || unknown.Attributes[AttributesRefKey] != "unknown" {
+		t.Errorf("Expected the unknown reference to be kept: %v, %v", err, unknown.Attributes)
+	}
+}
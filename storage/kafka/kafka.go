@@ -0,0 +1,472 @@
+/*
+ * Copyright (C) 2015 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package kafka
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/redhat-cip/skydive/config"
+	"github.com/redhat-cip/skydive/flow"
+	"github.com/redhat-cip/skydive/logging"
+)
+
+var (
+	ErrBufferFull = errors.New("Kafka buffer full, flows dropped")
+	ErrNoBroker   = errors.New("No Kafka broker available")
+)
+
+// FlowSinkStats reports the publication of the flows, Failures counting the
+// failed publish attempts of a batch, the flows being published again
+// until acknowledged.
+type FlowSinkStats struct {
+	Published uint64
+	Failures  uint64
+	Dropped   uint64
+	Queued    int
+}
+
+// FlowSink publishes flows to a Kafka topic, keyed by flow UUID so that the
+// updates of a flow go to the same partition. Flows are buffered and
+// published asynchronously, in batches, with an at least once delivery: a
+// batch is published again until the brokers acknowledge it, the flows given
+// while the buffer is full being dropped.
+type FlowSink struct {
+	Brokers       []string
+	Topic         string
+	ClientID      string
+	Encoding      string
+	RequiredAcks  int16
+	Timeout       time.Duration
+	BatchSize     int
+	FlushInterval time.Duration
+	RetryBackoff  time.Duration
+	// lifecycle events published in place of the expired flows, as
+	// FlowEvent JSON documents
+	Events flow.FlowEventTypes
+
+	buffer    chan *Message
+	quit      chan bool
+	wg        sync.WaitGroup
+	published uint64
+	failures  uint64
+	dropped   uint64
+
+	// owned by the publishing goroutine
+	correlationID int32
+	brokers       map[int32]string
+	leaders       []int32
+	conns         map[int32]net.Conn
+}
+
+func (s *FlowSink) encode(f *flow.Flow) ([]byte, error) {
+	if s.Encoding == "protobuf" {
+		return proto.Marshal(f)
+	}
+	return json.Marshal(f)
+}
+
+// queue queues a message for publication without blocking, returning false
+// if the buffer is full
+func (s *FlowSink) queue(key string, value []byte) bool {
+	select {
+	case s.buffer <- &Message{Key: []byte(key), Value: value}:
+		return true
+	default:
+		return false
+	}
+}
+
+// StoreFlows queues the flows for publication without blocking
+func (s *FlowSink) StoreFlows(flows []*flow.Flow) error {
+	var dropped uint64
+	for _, f := range flows {
+		value, err := s.encode(f)
+		if err != nil {
+			return err
+		}
+
+		if !s.queue(f.UUID, value) {
+			dropped++
+		}
+	}
+
+	if dropped > 0 {
+		atomic.AddUint64(&s.dropped, dropped)
+		return ErrBufferFull
+	}
+	return nil
+}
+
+// OnFlowEvents queues the lifecycle events for publication, keyed by flow
+// UUID so that the events of a flow are consumed in order
+func (s *FlowSink) OnFlowEvents(events []*flow.FlowEvent) {
+	var dropped uint64
+	for _, e := range events {
+		value, err := json.Marshal(e)
+		if err != nil {
+			logging.GetLogger().Errorf("Unable to encode the event of flow %s: %s", e.Flow.UUID, err.Error())
+			continue
+		}
+
+		if !s.queue(e.Flow.UUID, value) {
+			dropped++
+		}
+	}
+
+	if dropped > 0 {
+		atomic.AddUint64(&s.dropped, dropped)
+		logging.GetLogger().Errorf("%d flow events not exported: %s", dropped, ErrBufferFull.Error())
+	}
+}
+
+func (s *FlowSink) Stats() FlowSinkStats {
+	return FlowSinkStats{
+		Published: atomic.LoadUint64(&s.published),
+		Failures:  atomic.LoadUint64(&s.failures),
+		Dropped:   atomic.LoadUint64(&s.dropped),
+		Queued:    len(s.buffer),
+	}
+}
+
+func (s *FlowSink) closeConns() {
+	for id, conn := range s.conns {
+		conn.Close()
+		delete(s.conns, id)
+	}
+}
+
+// request sends a request to a broker and returns its response
+func (s *FlowSink) request(conn net.Conn, apiKey int16, body []byte) (*decoder, error) {
+	s.correlationID++
+	conn.SetDeadline(time.Now().Add(s.Timeout + 5*time.Second))
+
+	if _, err := conn.Write(encodeRequest(apiKey, s.correlationID, s.ClientID, body)); err != nil {
+		return nil, err
+	}
+	return readResponse(conn, s.correlationID)
+}
+
+func (s *FlowSink) refreshMetadata() error {
+	err := ErrNoBroker
+	for _, addr := range s.Brokers {
+		var conn net.Conn
+		if conn, err = net.DialTimeout("tcp", addr, s.Timeout); err != nil {
+			continue
+		}
+
+		var d *decoder
+		var resp *metadataResponse
+		if d, err = s.request(conn, apiKeyMetadata, encodeMetadataRequest([]string{s.Topic})); err == nil {
+			resp, err = decodeMetadataResponse(d)
+		}
+		conn.Close()
+
+		if err != nil {
+			continue
+		}
+		return s.updateMetadata(resp)
+	}
+	return err
+}
+
+func (s *FlowSink) updateMetadata(resp *metadataResponse) error {
+	s.brokers = make(map[int32]string)
+	for _, b := range resp.Brokers {
+		s.brokers[b.ID] = net.JoinHostPort(b.Host, strconv.Itoa(int(b.Port)))
+	}
+
+	for _, topic := range resp.Topics {
+		if topic.Name != s.Topic {
+			continue
+		}
+		if topic.Err != 0 {
+			return topic.Err
+		}
+
+		leaders := make([]int32, len(topic.Partitions))
+		for _, p := range topic.Partitions {
+			if p.ID < 0 || int(p.ID) >= len(leaders) {
+				return ErrMalformedResponse
+			}
+			leaders[p.ID] = p.Leader
+			if p.Err != 0 && p.Err != 9 { // replica not available
+				leaders[p.ID] = -1
+			}
+		}
+		if len(leaders) == 0 {
+			return KafkaError(3)
+		}
+		s.leaders = leaders
+		return nil
+	}
+
+	return KafkaError(3)
+}
+
+func (s *FlowSink) conn(broker int32) (net.Conn, error) {
+	if conn, ok := s.conns[broker]; ok {
+		return conn, nil
+	}
+
+	addr, ok := s.brokers[broker]
+	if !ok {
+		return nil, KafkaError(5)
+	}
+
+	conn, err := net.DialTimeout("tcp", addr, s.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	s.conns[broker] = conn
+	return conn, nil
+}
+
+// produce publishes messages to a broker, returning the partitions whose
+// messages weren't acknowledged
+func (s *FlowSink) produce(broker int32, msgs map[int32][]*Message) (map[int32]bool, error) {
+	conn, err := s.conn(broker)
+	if err == nil {
+		var d *decoder
+		body := encodeProduceRequest(s.RequiredAcks, int32(s.Timeout/time.Millisecond), s.Topic, msgs)
+		if d, err = s.request(conn, apiKeyProduce, body); err == nil {
+			var errs map[int32]KafkaError
+			if errs, err = decodeProduceResponse(d); err == nil {
+				failed := make(map[int32]bool)
+				for partition := range msgs {
+					if code, ok := errs[partition]; !ok || code != 0 {
+						failed[partition] = true
+						err = code
+					}
+				}
+				return failed, err
+			}
+		}
+		conn.Close()
+		delete(s.conns, broker)
+	}
+
+	failed := make(map[int32]bool)
+	for partition := range msgs {
+		failed[partition] = true
+	}
+	return failed, err
+}
+
+// publish publishes a batch, returning the messages not acknowledged
+func (s *FlowSink) publish(msgs []*Message) ([]*Message, error) {
+	if s.leaders == nil {
+		if err := s.refreshMetadata(); err != nil {
+			return msgs, err
+		}
+	}
+
+	var lastErr error
+	var remaining []*Message
+
+	byBroker := make(map[int32]map[int32][]*Message)
+	for _, msg := range msgs {
+		partition := partitionForKey(msg.Key, len(s.leaders))
+		leader := s.leaders[partition]
+		if leader == -1 {
+			remaining = append(remaining, msg)
+			lastErr = KafkaError(5)
+			continue
+		}
+		if _, ok := byBroker[leader]; !ok {
+			byBroker[leader] = make(map[int32][]*Message)
+		}
+		byBroker[leader][partition] = append(byBroker[leader][partition], msg)
+	}
+
+	for broker, partitions := range byBroker {
+		failed, err := s.produce(broker, partitions)
+		for partition, pmsgs := range partitions {
+			if failed[partition] {
+				remaining = append(remaining, pmsgs...)
+			} else {
+				atomic.AddUint64(&s.published, uint64(len(pmsgs)))
+			}
+		}
+		if err != nil {
+			lastErr = err
+		}
+	}
+
+	// the leaders may have changed
+	if lastErr != nil {
+		s.leaders = nil
+	}
+
+	return remaining, lastErr
+}
+
+// publishUntilAcked publishes the batch until fully acknowledged, returning
+// false if stopped before
+func (s *FlowSink) publishUntilAcked(msgs []*Message) ([]*Message, bool) {
+	for {
+		var err error
+		if msgs, err = s.publish(msgs); err == nil {
+			return nil, true
+		}
+
+		atomic.AddUint64(&s.failures, 1)
+		logging.GetLogger().Warningf("Failed to publish %d flows to Kafka, retrying: %s", len(msgs), err.Error())
+
+		select {
+		case <-s.quit:
+			return msgs, false
+		case <-time.After(s.RetryBackoff):
+		}
+	}
+}
+
+// flush does a last publication attempt of the batch and of the buffered
+// messages
+func (s *FlowSink) flush(msgs []*Message) {
+	for len(s.buffer) > 0 {
+		msgs = append(msgs, <-s.buffer)
+	}
+
+	if len(msgs) == 0 {
+		return
+	}
+
+	if msgs, err := s.publish(msgs); err != nil {
+		atomic.AddUint64(&s.failures, 1)
+		logging.GetLogger().Errorf("%d flows not published to Kafka: %s", len(msgs), err.Error())
+	}
+}
+
+func (s *FlowSink) run() {
+	defer s.wg.Done()
+	defer s.closeConns()
+
+	ticker := time.NewTicker(s.FlushInterval)
+	defer ticker.Stop()
+
+	var batch []*Message
+	for {
+		select {
+		case msg := <-s.buffer:
+			if batch = append(batch, msg); len(batch) < s.BatchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		case <-s.quit:
+			s.flush(batch)
+			return
+		}
+
+		var acked bool
+		if batch, acked = s.publishUntilAcked(batch); !acked {
+			s.flush(batch)
+			return
+		}
+	}
+}
+
+func (s *FlowSink) Start() {
+	s.wg.Add(1)
+	go s.run()
+}
+
+// Stop stops the publication once the buffered flows are published, or a
+// last attempt failed
+func (s *FlowSink) Stop() {
+	close(s.quit)
+	s.wg.Wait()
+}
+
+func NewFlowSink(brokers []string, topic string, bufferSize int) *FlowSink {
+	return &FlowSink{
+		Brokers:       brokers,
+		Topic:         topic,
+		ClientID:      "skydive",
+		Encoding:      "json",
+		RequiredAcks:  1,
+		Timeout:       10 * time.Second,
+		BatchSize:     100,
+		FlushInterval: time.Second,
+		RetryBackoff:  time.Second,
+		buffer:        make(chan *Message, bufferSize),
+		quit:          make(chan bool),
+		conns:         make(map[int32]net.Conn),
+	}
+}
+
+func NewFlowSinkFromConfig() (*FlowSink, error) {
+	cfg := config.GetConfig()
+
+	brokers := cfg.GetStringSlice("storage.kafka.brokers")
+	if len(brokers) == 0 {
+		return nil, errors.New("No Kafka broker configured")
+	}
+
+	s := NewFlowSink(brokers, cfg.GetString("storage.kafka.topic"), cfg.GetInt("storage.kafka.buffer_size"))
+
+	switch s.Encoding = cfg.GetString("storage.kafka.encoding"); s.Encoding {
+	case "json", "protobuf":
+	default:
+		return nil, fmt.Errorf("Unknown Kafka encoding: %s", s.Encoding)
+	}
+
+	switch acks := cfg.GetInt("storage.kafka.required_acks"); acks {
+	case 1, -1:
+		s.RequiredAcks = int16(acks)
+	default:
+		return nil, fmt.Errorf("Kafka required_acks should be 1 or -1, got: %d", acks)
+	}
+
+	if events := cfg.GetStringSlice("storage.kafka.events"); len(events) > 0 {
+		types, err := flow.ParseFlowEventTypes(events)
+		if err != nil {
+			return nil, err
+		}
+		if s.Encoding != "json" {
+			return nil, errors.New("Kafka flow events are only published in json")
+		}
+		s.Events = types
+	}
+
+	s.BatchSize = cfg.GetInt("storage.kafka.batch_size")
+	s.FlushInterval = time.Duration(cfg.GetInt("storage.kafka.flush_interval")) * time.Millisecond
+	s.RetryBackoff = time.Duration(cfg.GetInt("storage.kafka.retry_backoff")) * time.Millisecond
+	s.Timeout = time.Duration(cfg.GetInt("storage.kafka.timeout")) * time.Millisecond
+	if s.BatchSize <= 0 || s.FlushInterval <= 0 || s.Timeout <= 0 {
+		return nil, errors.New("Kafka batch_size, flush_interval and timeout should be positive")
+	}
+
+	return s, nil
+}
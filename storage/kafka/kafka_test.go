@@ -0,0 +1,387 @@
+/*
+ * Copyright (C) 2015 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package kafka
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redhat-cip/skydive/flow"
+)
+
+type producedMessage struct {
+	partition int32
+	key       string
+	value     []byte
+}
+
+// mockBroker is a single Kafka broker leading all the partitions of a topic
+type mockBroker struct {
+	sync.Mutex
+	listener   net.Listener
+	topic      string
+	partitions int
+	// error code returned by the next produce requests
+	produceErrors []int16
+	produced      []producedMessage
+}
+
+func (b *mockBroker) messages() []producedMessage {
+	b.Lock()
+	defer b.Unlock()
+	return append([]producedMessage{}, b.produced...)
+}
+
+func (b *mockBroker) metadataResponse() []byte {
+	host, port, _ := net.SplitHostPort(b.listener.Addr().String())
+	p, _ := strconv.Atoi(port)
+
+	var e encoder
+	e.putInt32(1)
+	e.putInt32(0)
+	e.putString(host)
+	e.putInt32(int32(p))
+
+	e.putInt32(1)
+	e.putInt16(0)
+	e.putString(b.topic)
+	e.putInt32(int32(b.partitions))
+	for i := 0; i < b.partitions; i++ {
+		e.putInt16(0)
+		e.putInt32(int32(i))
+		e.putInt32(0)
+		e.putInt32(1)
+		e.putInt32(0)
+		e.putInt32(1)
+		e.putInt32(0)
+	}
+	return e.Bytes()
+}
+
+func (b *mockBroker) produceResponse(t *testing.T, d *decoder) []byte {
+	b.Lock()
+	defer b.Unlock()
+
+	var code int16
+	if len(b.produceErrors) > 0 {
+		code, b.produceErrors = b.produceErrors[0], b.produceErrors[1:]
+	}
+
+	d.int16()
+	d.int32()
+
+	var e encoder
+	e.putInt32(d.int32())
+	topic := d.string()
+	if topic != b.topic {
+		t.Errorf("Messages produced to the wrong topic: %s", topic)
+	}
+	e.putString(topic)
+
+	n := d.int32()
+	e.putInt32(n)
+	for ; n > 0; n-- {
+		partition := d.int32()
+		msgs, err := decodeMessageSet(d.bytes())
+		if err != nil {
+			t.Error(err.Error())
+		}
+		if code == 0 {
+			for _, msg := range msgs {
+				b.produced = append(b.produced, producedMessage{partition: partition, key: string(msg.Key), value: msg.Value})
+			}
+		}
+		e.putInt32(partition)
+		e.putInt16(code)
+		e.putInt64(0)
+	}
+	return e.Bytes()
+}
+
+func (b *mockBroker) serve(t *testing.T, conn net.Conn) {
+	defer conn.Close()
+
+	for {
+		var size int32
+		if err := binary.Read(conn, binary.BigEndian, &size); err != nil {
+			return
+		}
+		data := make([]byte, size)
+		if _, err := io.ReadFull(conn, data); err != nil {
+			return
+		}
+
+		d := &decoder{data: data}
+		apiKey, _ := d.int16(), d.int16()
+		correlationID := d.int32()
+		d.string()
+
+		var body []byte
+		switch apiKey {
+		case apiKeyMetadata:
+			body = b.metadataResponse()
+		case apiKeyProduce:
+			body = b.produceResponse(t, d)
+		default:
+			t.Errorf("Unexpected request %d", apiKey)
+			return
+		}
+
+		var e encoder
+		e.putInt32(int32(4 + len(body)))
+		e.putInt32(correlationID)
+		e.Write(body)
+		conn.Write(e.Bytes())
+	}
+}
+
+func newMockBroker(t *testing.T, topic string, partitions int) *mockBroker {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	b := &mockBroker{listener: listener, topic: topic, partitions: partitions}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go b.serve(t, conn)
+		}
+	}()
+
+	return b
+}
+
+func newTestSink(b *mockBroker) *FlowSink {
+	s := NewFlowSink([]string{b.listener.Addr().String()}, b.topic, 100)
+	s.BatchSize = 10
+	s.FlushInterval = 10 * time.Millisecond
+	s.RetryBackoff = 10 * time.Millisecond
+	s.Timeout = time.Second
+	return s
+}
+
+func waitPublished(t *testing.T, s *FlowSink, count uint64) {
+	for i := 0; i < 200; i++ {
+		if s.Stats().Published >= count {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Expected %d published flows, got: %+v", count, s.Stats())
+}
+
+func TestMurmur2(t *testing.T) {
+	// values of the Java client
+	for key, hash := range map[string]int32{
+		"21":     -973932308,
+		"foobar": -790332482,
+		"abc":    479470107,
+	} {
+		if h := murmur2([]byte(key)); h != hash {
+			t.Errorf("Wrong hash of %s: %d, expected %d", key, h, hash)
+		}
+	}
+}
+
+func TestFlowSinkPublish(t *testing.T) {
+	b := newMockBroker(t, "flows", 3)
+	defer b.listener.Close()
+
+	s := newTestSink(b)
+	s.Start()
+	defer s.Stop()
+
+	var flows []*flow.Flow
+	for i := 0; i < 25; i++ {
+		flows = append(flows, &flow.Flow{UUID: "flow-" + strconv.Itoa(i), LayersPath: "Ethernet/IPv4"})
+	}
+	if err := s.StoreFlows(flows); err != nil {
+		t.Fatal(err.Error())
+	}
+	waitPublished(t, s, 25)
+
+	msgs := b.messages()
+	if len(msgs) != 25 {
+		t.Fatalf("Expected 25 messages, got %d", len(msgs))
+	}
+
+	partitions := make(map[int32]bool)
+	for _, msg := range msgs {
+		var f flow.Flow
+		if err := json.Unmarshal(msg.value, &f); err != nil {
+			t.Fatal(err.Error())
+		}
+		if f.UUID != msg.key {
+			t.Errorf("Message of flow %s published with the key %s", f.UUID, msg.key)
+		}
+		if p := partitionForKey([]byte(msg.key), 3); p != msg.partition {
+			t.Errorf("Flow %s published to the partition %d instead of %d", msg.key, msg.partition, p)
+		}
+		partitions[msg.partition] = true
+	}
+	if len(partitions) != 3 {
+		t.Errorf("The flows should be spread over the partitions: %v", partitions)
+	}
+}
+
+func TestFlowSinkProtobuf(t *testing.T) {
+	b := newMockBroker(t, "flows", 1)
+	defer b.listener.Close()
+
+	s := newTestSink(b)
+	s.Encoding = "protobuf"
+	s.Start()
+	defer s.Stop()
+
+	s.StoreFlows([]*flow.Flow{{UUID: "flow-uuid", LayersPath: "Ethernet/IPv4"}})
+	waitPublished(t, s, 1)
+
+	f, err := flow.FromData(b.messages()[0].value)
+	if err != nil || f.UUID != "flow-uuid" || f.LayersPath != "Ethernet/IPv4" {
+		t.Errorf("Wrong protobuf message: %+v (%v)", f, err)
+	}
+}
+
+func TestFlowSinkRetry(t *testing.T) {
+	b := newMockBroker(t, "flows", 1)
+	defer b.listener.Close()
+
+	// the leader moved twice
+	b.produceErrors = []int16{6, 6}
+
+	s := newTestSink(b)
+	s.Start()
+	defer s.Stop()
+
+	s.StoreFlows([]*flow.Flow{{UUID: "flow1"}, {UUID: "flow2"}})
+	waitPublished(t, s, 2)
+
+	if stats := s.Stats(); stats.Failures != 2 || stats.Published != 2 {
+		t.Errorf("Expected 2 failures before the publication: %+v", stats)
+	}
+	if msgs := b.messages(); len(msgs) != 2 {
+		t.Errorf("Expected 2 messages, got: %+v", msgs)
+	}
+}
+
+func TestFlowSinkUnavailable(t *testing.T) {
+	b := newMockBroker(t, "flows", 1)
+	addr := b.listener.Addr().String()
+	b.listener.Close()
+
+	s := NewFlowSink([]string{addr}, "flows", 2)
+	s.FlushInterval = 10 * time.Millisecond
+	s.RetryBackoff = 10 * time.Millisecond
+
+	if err := s.StoreFlows([]*flow.Flow{{UUID: "flow1"}, {UUID: "flow2"}, {UUID: "flow3"}}); err != ErrBufferFull {
+		t.Errorf("Expected the buffer to be full, got: %v", err)
+	}
+
+	s.Start()
+	for i := 0; i < 100 && s.Stats().Failures < 2; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	s.Stop()
+
+	if stats := s.Stats(); stats.Dropped != 1 || stats.Failures < 2 || stats.Published != 0 {
+		t.Errorf("Wrong stats: %+v", stats)
+	}
+}
+
+func TestFlowSinkLifecycleEvents(t *testing.T) {
+	b := newMockBroker(t, "flows", 3)
+	defer b.listener.Close()
+
+	s := newTestSink(b)
+	s.Events = flow.FlowEventTypes{flow.FlowCreated: true, flow.FlowExpired: true}
+	s.Start()
+	defer s.Stop()
+
+	dispatcher := flow.NewFlowEventDispatcher()
+	dispatcher.Subscribe(s, s.Events)
+
+	table := flow.NewTable()
+	table.SetEventListener(dispatcher, flow.FlowUpdatePolicy{})
+	table.RegisterExpire(func(flows []*flow.Flow) {}, time.Hour, time.Hour)
+
+	// short flows updated a few times then expired
+	newFlow := func(i int, bytes uint64) *flow.Flow {
+		return &flow.Flow{
+			UUID: "flow-" + strconv.Itoa(i),
+			Statistics: &flow.FlowStatistics{
+				Start: 100,
+				Last:  100 + int64(bytes),
+				Endpoints: []*flow.FlowEndpointsStatistics{
+					{AB: &flow.FlowEndpointStatistics{Value: "10.0.0.1", Bytes: bytes}, BA: &flow.FlowEndpointStatistics{Value: "10.0.0.2"}},
+				},
+			},
+		}
+	}
+	for _, bytes := range []uint64{64, 128, 192} {
+		var flows []*flow.Flow
+		for i := 0; i < 10; i++ {
+			flows = append(flows, newFlow(i, bytes))
+		}
+		table.Update(flows)
+	}
+	table.UnregisterAll()
+	waitPublished(t, s, 20)
+
+	events := make(map[string][]flow.FlowEvent)
+	for _, msg := range b.messages() {
+		var e flow.FlowEvent
+		if err := json.Unmarshal(msg.value, &e); err != nil {
+			t.Fatal(err.Error())
+		}
+		if e.Flow.UUID != msg.key {
+			t.Errorf("Event of flow %s published with the key %s", e.Flow.UUID, msg.key)
+		}
+		events[msg.key] = append(events[msg.key], e)
+	}
+
+	if len(events) != 10 {
+		t.Fatalf("Expected the events of 10 flows, got %d", len(events))
+	}
+	for uuid, flowEvents := range events {
+		if len(flowEvents) != 2 || flowEvents[0].Type != flow.FlowCreated || flowEvents[1].Type != flow.FlowExpired {
+			t.Errorf("Expected the created then expired events of %s, got %+v", uuid, flowEvents)
+			continue
+		}
+		if bytes := flowEvents[1].Flow.Statistics.Endpoints[0].AB.Bytes; bytes != 192 {
+			t.Errorf("Expected the final counters of %s, got %d bytes", uuid, bytes)
+		}
+		if bytes := flowEvents[0].Flow.Statistics.Endpoints[0].AB.Bytes; bytes != 64 {
+			t.Errorf("Expected the first counters of %s in the created event, got %d bytes", uuid, bytes)
+		}
+	}
+}
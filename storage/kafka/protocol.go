@@ -0,0 +1,372 @@
+/*
+ * Copyright (C) 2015 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package kafka
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// Only the version 0 of the Metadata and Produce requests is implemented,
+// supported by all the brokers
+const (
+	apiKeyProduce  = 0
+	apiKeyMetadata = 3
+)
+
+var ErrMalformedResponse = errors.New("Malformed Kafka response")
+
+// KafkaError is an error code returned by a broker
+type KafkaError int16
+
+func (e KafkaError) Error() string {
+	switch e {
+	case 3:
+		return "Kafka error: unknown topic or partition"
+	case 5:
+		return "Kafka error: leader not available"
+	case 6:
+		return "Kafka error: not leader for partition"
+	case 7:
+		return "Kafka error: request timed out"
+	}
+	return fmt.Sprintf("Kafka error %d", int16(e))
+}
+
+type encoder struct {
+	bytes.Buffer
+}
+
+func (e *encoder) putInt8(v int8) {
+	e.WriteByte(byte(v))
+}
+
+func (e *encoder) putInt16(v int16) {
+	binary.Write(e, binary.BigEndian, v)
+}
+
+func (e *encoder) putInt32(v int32) {
+	binary.Write(e, binary.BigEndian, v)
+}
+
+func (e *encoder) putInt64(v int64) {
+	binary.Write(e, binary.BigEndian, v)
+}
+
+func (e *encoder) putString(s string) {
+	e.putInt16(int16(len(s)))
+	e.WriteString(s)
+}
+
+func (e *encoder) putBytes(b []byte) {
+	if b == nil {
+		e.putInt32(-1)
+		return
+	}
+	e.putInt32(int32(len(b)))
+	e.Write(b)
+}
+
+type decoder struct {
+	data []byte
+	err  error
+}
+
+func (d *decoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || len(d.data) < n {
+		d.err = ErrMalformedResponse
+		return nil
+	}
+	b := d.data[:n]
+	d.data = d.data[n:]
+	return b
+}
+
+func (d *decoder) int8() int8 {
+	if b := d.next(1); b != nil {
+		return int8(b[0])
+	}
+	return 0
+}
+
+func (d *decoder) int16() int16 {
+	if b := d.next(2); b != nil {
+		return int16(binary.BigEndian.Uint16(b))
+	}
+	return 0
+}
+
+func (d *decoder) int32() int32 {
+	if b := d.next(4); b != nil {
+		return int32(binary.BigEndian.Uint32(b))
+	}
+	return 0
+}
+
+func (d *decoder) int64() int64 {
+	if b := d.next(8); b != nil {
+		return int64(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
+
+func (d *decoder) string() string {
+	return string(d.next(int(d.int16())))
+}
+
+func (d *decoder) bytes() []byte {
+	n := d.int32()
+	if n == -1 {
+		return nil
+	}
+	return d.next(int(n))
+}
+
+// Message is a Kafka message of the version 0 of the message format
+type Message struct {
+	Key   []byte
+	Value []byte
+}
+
+// encodeMessageSet encodes the messages of a partition, the offsets being
+// assigned by the broker
+func encodeMessageSet(msgs []*Message) []byte {
+	var set encoder
+	for _, msg := range msgs {
+		var m encoder
+		m.putInt8(0) // magic
+		m.putInt8(0) // attributes, no compression
+		m.putBytes(msg.Key)
+		m.putBytes(msg.Value)
+
+		set.putInt64(0)
+		set.putInt32(int32(4 + m.Len()))
+		set.putInt32(int32(crc32.ChecksumIEEE(m.Bytes())))
+		set.Write(m.Bytes())
+	}
+	return set.Bytes()
+}
+
+// decodeMessageSet decodes a message set, a partial trailing message being
+// ignored as brokers do
+func decodeMessageSet(data []byte) ([]*Message, error) {
+	var msgs []*Message
+
+	d := &decoder{data: data}
+	for len(d.data) >= 12 {
+		d.int64()
+		m := &decoder{data: d.bytes()}
+		if d.err != nil {
+			break
+		}
+
+		crc := uint32(m.int32())
+		if crc != crc32.ChecksumIEEE(m.data) {
+			return nil, errors.New("Kafka message with a wrong CRC")
+		}
+		m.int8()
+		m.int8()
+		msg := &Message{Key: m.bytes(), Value: m.bytes()}
+		if m.err != nil {
+			return nil, m.err
+		}
+		msgs = append(msgs, msg)
+	}
+
+	return msgs, nil
+}
+
+// encodeRequest frames a request along with its header
+func encodeRequest(apiKey int16, correlationID int32, clientID string, body []byte) []byte {
+	var header encoder
+	header.putInt16(apiKey)
+	header.putInt16(0)
+	header.putInt32(correlationID)
+	header.putString(clientID)
+
+	var req encoder
+	req.putInt32(int32(header.Len() + len(body)))
+	req.Write(header.Bytes())
+	req.Write(body)
+	return req.Bytes()
+}
+
+// readResponse reads a response frame, checking its correlation id
+func readResponse(r io.Reader, correlationID int32) (*decoder, error) {
+	var size int32
+	if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+		return nil, err
+	}
+	if size < 4 {
+		return nil, ErrMalformedResponse
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+
+	d := &decoder{data: data}
+	if id := d.int32(); id != correlationID {
+		return nil, fmt.Errorf("Kafka response for request %d, expected %d", id, correlationID)
+	}
+	return d, nil
+}
+
+type brokerMetadata struct {
+	ID   int32
+	Host string
+	Port int32
+}
+
+type partitionMetadata struct {
+	Err    KafkaError
+	ID     int32
+	Leader int32
+}
+
+type topicMetadata struct {
+	Err        KafkaError
+	Name       string
+	Partitions []partitionMetadata
+}
+
+type metadataResponse struct {
+	Brokers []brokerMetadata
+	Topics  []topicMetadata
+}
+
+func encodeMetadataRequest(topics []string) []byte {
+	var e encoder
+	e.putInt32(int32(len(topics)))
+	for _, topic := range topics {
+		e.putString(topic)
+	}
+	return e.Bytes()
+}
+
+func decodeMetadataResponse(d *decoder) (*metadataResponse, error) {
+	resp := &metadataResponse{}
+
+	for n := d.int32(); n > 0 && d.err == nil; n-- {
+		resp.Brokers = append(resp.Brokers, brokerMetadata{ID: d.int32(), Host: d.string(), Port: d.int32()})
+	}
+
+	for n := d.int32(); n > 0 && d.err == nil; n-- {
+		topic := topicMetadata{Err: KafkaError(d.int16()), Name: d.string()}
+		for p := d.int32(); p > 0 && d.err == nil; p-- {
+			partition := partitionMetadata{Err: KafkaError(d.int16()), ID: d.int32(), Leader: d.int32()}
+			// replicas and in sync replicas
+			for i := 0; i < 2; i++ {
+				for r := d.int32(); r > 0 && d.err == nil; r-- {
+					d.int32()
+				}
+			}
+			topic.Partitions = append(topic.Partitions, partition)
+		}
+		resp.Topics = append(resp.Topics, topic)
+	}
+
+	return resp, d.err
+}
+
+// encodeProduceRequest encodes the messages of a topic, by partition
+func encodeProduceRequest(acks int16, timeout int32, topic string, msgs map[int32][]*Message) []byte {
+	var e encoder
+	e.putInt16(acks)
+	e.putInt32(timeout)
+	e.putInt32(1)
+	e.putString(topic)
+	e.putInt32(int32(len(msgs)))
+	for partition, pmsgs := range msgs {
+		e.putInt32(partition)
+		e.putBytes(encodeMessageSet(pmsgs))
+	}
+	return e.Bytes()
+}
+
+// decodeProduceResponse returns the error code of each partition
+func decodeProduceResponse(d *decoder) (map[int32]KafkaError, error) {
+	errs := make(map[int32]KafkaError)
+	for n := d.int32(); n > 0 && d.err == nil; n-- {
+		d.string()
+		for p := d.int32(); p > 0 && d.err == nil; p-- {
+			partition := d.int32()
+			errs[partition] = KafkaError(d.int16())
+			d.int64()
+		}
+	}
+	return errs, d.err
+}
+
+// murmur2 is the hash used by the default partitioner of the Java client so
+// that a key is published to the same partition by all the producers
+func murmur2(data []byte) int32 {
+	const (
+		seed uint32 = 0x9747b28c
+		m    uint32 = 0x5bd1e995
+		r           = 24
+	)
+
+	length := len(data)
+	h := seed ^ uint32(length)
+
+	for i := 0; i+4 <= length; i += 4 {
+		k := uint32(data[i]) | uint32(data[i+1])<<8 | uint32(data[i+2])<<16 | uint32(data[i+3])<<24
+		k *= m
+		k ^= k >> r
+		k *= m
+		h *= m
+		h ^= k
+	}
+
+	tail := length &^ 3
+	switch length % 4 {
+	case 3:
+		h ^= uint32(data[tail+2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint32(data[tail+1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint32(data[tail])
+		h *= m
+	}
+
+	h ^= h >> 13
+	h *= m
+	h ^= h >> 15
+
+	return int32(h)
+}
+
+func partitionForKey(key []byte, partitions int) int32 {
+	return (murmur2(key) & 0x7fffffff) % int32(partitions)
+}
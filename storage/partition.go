@@ -0,0 +1,266 @@
+/*
+ * Copyright (C) 2015 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package storage
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/redhat-cip/skydive/config"
+	"github.com/redhat-cip/skydive/flow"
+	"github.com/redhat-cip/skydive/logging"
+)
+
+// partitionKey is the field the storages are partitioned on
+const partitionKey = "Statistics.Last"
+
+// PartitionedStorage is implemented by the storages splitting the flows into
+// time partitions. Partitions returns, most recent first, the ranges of the
+// partitions covering the given range, clipped to it, the first one being
+// unbounded when the given one is. SearchPartition returns at most limit
+// flows of the range, sorted by Statistics.Last in the descending order, the
+// search being cancelled along with the context.
+type PartitionedStorage interface {
+	Partitions(r Range) []Range
+	SearchPartition(ctx context.Context, r Range, filters Filters, limit int) ([]*flow.Flow, error)
+}
+
+// ContextSearcher is implemented by the storages cancelling the searches
+// along with the context
+type ContextSearcher interface {
+	SearchFlowsContext(ctx context.Context, filters Filters) ([]*flow.Flow, error)
+}
+
+// searchFlowsContext searches the flows of the storage, the search being
+// cancelled along with the context if the storage can
+func searchFlowsContext(ctx context.Context, s Storage, filters Filters) ([]*flow.Flow, error) {
+	if cs, ok := s.(ContextSearcher); ok {
+		return cs.SearchFlowsContext(ctx, filters)
+	}
+	return s.SearchFlows(filters)
+}
+
+// searchPlan describes how a search was split into partitions
+type searchPlan struct {
+	partitions int
+	hit        int
+	skipped    int
+	flows      int
+	duration   time.Duration
+}
+
+// Planner splits the flow searches of a partitioned storage into per
+// partition searches run in parallel
+type Planner struct {
+	concurrency   int
+	limit         int
+	slowThreshold time.Duration
+}
+
+type partitionResult struct {
+	index int
+	flows []*flow.Flow
+	err   error
+}
+
+// searchRange returns the range of Statistics.Last of the filters, the
+// search being split only when it has a lower bound
+func searchRange(filters Filters) (Range, bool) {
+	r, ok := filters[partitionKey].(Range)
+	if !ok || r.Gte == 0 {
+		return Range{}, false
+	}
+	if r.Lte != 0 && (r.Lt == 0 || r.Lte < r.Lt) {
+		r.Lt = r.Lte + 1
+	}
+	r.Lte = 0
+	return r, true
+}
+
+// SearchFlows searches the flows across the partitions of the storage,
+// falling back on a single search when the filters don't bound
+// Statistics.Last
+func (p *Planner) SearchFlows(ctx context.Context, s Storage, filters Filters) ([]*flow.Flow, error) {
+	ps, ok := s.(PartitionedStorage)
+	if !ok {
+		return searchFlowsContext(ctx, s, filters)
+	}
+
+	r, ok := searchRange(filters)
+	if !ok {
+		return searchFlowsContext(ctx, s, filters)
+	}
+
+	flows, plan, err := p.search(ctx, ps, filters, r, p.limit)
+	if err != nil {
+		return nil, err
+	}
+	p.logSlowSearch(ctx, filters, plan)
+
+	return flows, nil
+}
+
+// SearchFlowsPaged searches a page of the flows across the partitions of
+// the storage. The flows being sorted by Statistics.Last in the descending
+// order within and across the partitions, only the flows up to the end of
+// the page are searched, the total being counted by the storage. The other
+// searches are paged by the storage.
+func (p *Planner) SearchFlowsPaged(ctx context.Context, s Storage, q SearchQuery) ([]*flow.Flow, int, error) {
+	ps, ok := s.(PartitionedStorage)
+	if !ok || q.SortOrder() != DefaultSort {
+		return SearchFlowsPaged(ctx, s, q)
+	}
+
+	r, ok := searchRange(q.Filters)
+	if !ok {
+		return SearchFlowsPaged(ctx, s, q)
+	}
+
+	limit := q.Limit
+	if limit == 0 {
+		limit = p.limit
+	}
+	if limit > 0 {
+		limit += q.Offset
+	}
+
+	flows, plan, err := p.search(ctx, ps, q.Filters, r, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	p.logSlowSearch(ctx, q.Filters, plan)
+
+	total, err := s.CountFlows(q.Filters)
+	if err != nil {
+		return nil, 0, err
+	}
+	return PageFlows(flows, q), total, nil
+}
+
+func (p *Planner) logSlowSearch(ctx context.Context, filters Filters, plan *searchPlan) {
+	if p.slowThreshold > 0 && plan.duration >= p.slowThreshold {
+		logging.GetContextLogger(ctx).Warningf("Slow flow search %v in %s: %d partitions, %d hit, %d skipped, %d flows",
+			filters, plan.duration, plan.partitions, plan.hit, plan.skipped, plan.flows)
+		logging.GetJournal(logging.JournalSlowQueries).RecordContext(ctx, "Flow search %v in %s: %d partitions, %d hit, %d skipped, %d flows",
+			filters, plan.duration, plan.partitions, plan.hit, plan.skipped, plan.flows)
+	}
+}
+
+// search runs the searches of the partitions, the most recent first, at
+// most concurrency partitions ahead of the ones merged. The partitions being
+// disjoint and sorted, the results are merged by concatenating them in the
+// order of the partitions, no more search being issued once the merged ones
+// reach the limit, 0 meaning no limit. The searches still running when
+// returning are cancelled and waited for.
+func (p *Planner) search(ctx context.Context, s PartitionedStorage, filters Filters, r Range, limit int) ([]*flow.Flow, *searchPlan, error) {
+	start := time.Now()
+
+	partitions := s.Partitions(r)
+	plan := &searchPlan{partitions: len(partitions)}
+
+	// the searches are cancelled before being waited for, the deferred
+	// calls running in the reverse order
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// buffered so that the searches not merged don't block
+	results := make(chan partitionResult, len(partitions))
+	done := make([][]*flow.Flow, len(partitions))
+	completed := make([]bool, len(partitions))
+
+	flows := []*flow.Flow{}
+	next, merged := 0, 0
+	for merged < len(partitions) && (limit <= 0 || len(flows) < limit) {
+		for next-merged < p.concurrency && next < len(partitions) {
+			sub := make(Filters)
+			for k, v := range filters {
+				sub[k] = v
+			}
+			sub[partitionKey] = partitions[next]
+
+			wg.Add(1)
+			go func(index int, pr Range, sub Filters) {
+				defer wg.Done()
+				f, err := s.SearchPartition(ctx, pr, sub, limit)
+				results <- partitionResult{index: index, flows: f, err: err}
+			}(next, partitions[next], sub)
+
+			next++
+		}
+
+		select {
+		case res := <-results:
+			if res.err != nil {
+				return nil, nil, res.err
+			}
+			done[res.index], completed[res.index] = res.flows, true
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		}
+
+		for merged < next && completed[merged] {
+			flows = append(flows, done[merged]...)
+			merged++
+		}
+	}
+
+	if limit > 0 && len(flows) > limit {
+		flows = flows[:limit]
+	}
+
+	plan.hit = next
+	plan.skipped = len(partitions) - next
+	plan.flows = len(flows)
+	plan.duration = time.Since(start)
+
+	return flows, plan, nil
+}
+
+func NewPlanner(concurrency int, limit int, slowThreshold time.Duration) *Planner {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	return &Planner{
+		concurrency:   concurrency,
+		limit:         limit,
+		slowThreshold: slowThreshold,
+	}
+}
+
+// NewPlannerFromConfig returns a planner running storage.search.concurrency
+// searches at once, returning at most analyzer.query_max_results flows, 0
+// meaning no limit
+func NewPlannerFromConfig() *Planner {
+	cfg := config.GetConfig()
+	return NewPlanner(
+		cfg.GetInt("storage.search.concurrency"),
+		cfg.GetInt("analyzer.query_max_results"),
+		time.Duration(cfg.GetInt("storage.search.slow_threshold"))*time.Millisecond,
+	)
+}
@@ -0,0 +1,297 @@
+/*
+ * Copyright (C) 2015 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package storage
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/redhat-cip/skydive/flow"
+)
+
+// partitionedStorage splits its flows into partitions of period seconds, the
+// searches lasting latency per flow of the partition
+type partitionedStorage struct {
+	recordingStorage
+	flows    []*flow.Flow
+	period   int64
+	latency  time.Duration
+	searches int64
+	block    bool
+	err      error
+}
+
+func (s *partitionedStorage) Partitions(r Range) []Range {
+	if s.period == 0 {
+		return []Range{r}
+	}
+
+	end := r.Lt
+	if end == 0 {
+		end = s.flows[len(s.flows)-1].Statistics.Last + 1
+	}
+
+	var partitions []Range
+	for end > r.Gte {
+		start := (end - 1) / s.period * s.period
+		if start < r.Gte {
+			start = r.Gte
+		}
+		partitions = append(partitions, Range{Gte: start, Lt: end})
+		end = start
+	}
+
+	if len(partitions) > 0 && r.Lt == 0 {
+		partitions[0].Lt = 0
+	}
+	return partitions
+}
+
+func (s *partitionedStorage) SearchPartition(ctx context.Context, r Range, filters Filters, limit int) ([]*flow.Flow, error) {
+	atomic.AddInt64(&s.searches, 1)
+
+	if s.block {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	var flows []*flow.Flow
+	for _, f := range s.flows {
+		if filters[partitionKey].(Range).Match(f.Statistics.Last) {
+			flows = append(flows, f)
+		}
+	}
+	time.Sleep(time.Duration(len(flows)) * s.latency)
+
+	sort.Sort(sortByLastDesc(flows))
+	if len(flows) > limit {
+		flows = flows[:limit]
+	}
+	return flows, nil
+}
+
+func (s *partitionedStorage) CountFlows(filters Filters) (int, error) {
+	r, _ := filters[partitionKey].(Range)
+
+	count := 0
+	for _, f := range s.flows {
+		if r.Match(f.Statistics.Last) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// newPartitionedStorage returns a storage of hours hourly partitions holding
+// perHour flows each
+func newPartitionedStorage(hours int, perHour int) *partitionedStorage {
+	s := &partitionedStorage{period: 3600}
+	for i := 0; i != hours*perHour; i++ {
+		s.flows = append(s.flows, &flow.Flow{
+			UUID:       strconv.Itoa(i),
+			Statistics: &flow.FlowStatistics{Last: int64(i * 3600 / perHour)},
+		})
+	}
+	return s
+}
+
+func TestPlannerEarlyTermination(t *testing.T) {
+	s := newPartitionedStorage(48, 10)
+	p := NewPlanner(2, 25, 0)
+
+	flows, plan, err := p.search(context.Background(), s, Filters{}, Range{Gte: 1, Lt: 48 * 3600}, p.limit)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(flows) != 25 {
+		t.Fatalf("Expected 25 flows, got %d", len(flows))
+	}
+	for i, f := range flows {
+		// the most recent flow of the range is the last one stored
+		if expected := int64((479 - i) * 360); f.Statistics.Last != expected {
+			t.Fatalf("Expected the flow %d to be the one of %d, got %d", i, expected, f.Statistics.Last)
+		}
+	}
+
+	searches := atomic.LoadInt64(&s.searches)
+	if plan.partitions != 48 || plan.skipped == 0 || plan.hit+plan.skipped != 48 || int(searches) != plan.hit {
+		t.Errorf("Expected the oldest partitions to be skipped: %+v, %d searches", plan, searches)
+	}
+}
+
+func TestPlannerAllPartitions(t *testing.T) {
+	s := newPartitionedStorage(24, 10)
+	p := NewPlanner(4, 1000, 0)
+
+	flows, plan, err := p.search(context.Background(), s, Filters{}, Range{Gte: 3600, Lt: 24 * 3600}, p.limit)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(flows) != 230 || plan.hit != 23 || plan.skipped != 0 {
+		t.Fatalf("Expected the flows of 23 partitions, got %d flows: %+v", len(flows), plan)
+	}
+	for i := 1; i < len(flows); i++ {
+		if flows[i].Statistics.Last > flows[i-1].Statistics.Last {
+			t.Fatal("Flows not merged in the descending order of Statistics.Last")
+		}
+	}
+}
+
+func TestPlannerCancellation(t *testing.T) {
+	s := newPartitionedStorage(10, 1)
+	s.block = true
+	p := NewPlanner(3, 100, 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	var err error
+	go func() {
+		defer wg.Done()
+		_, _, err = p.search(ctx, s, Filters{}, Range{Gte: 1, Lt: 10 * 3600}, p.limit)
+	}()
+
+	for atomic.LoadInt64(&s.searches) != 3 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	wg.Wait()
+
+	if err != context.Canceled {
+		t.Errorf("Expected the search to be cancelled, got %v", err)
+	}
+	if searches := atomic.LoadInt64(&s.searches); searches != 3 {
+		t.Errorf("Expected no more search once cancelled, got %d", searches)
+	}
+}
+
+func TestPlannerError(t *testing.T) {
+	s := newPartitionedStorage(10, 1)
+	s.err = errors.New("partition unavailable")
+
+	if _, err := NewPlanner(2, 100, 0).SearchFlows(context.Background(), s, Filters{partitionKey: Range{Gte: 1}}); err != s.err {
+		t.Errorf("Expected the error of the partition, got %v", err)
+	}
+}
+
+func TestPlannerPaged(t *testing.T) {
+	s := newPartitionedStorage(48, 10)
+	p := NewPlanner(2, 1000, 0)
+
+	q := SearchQuery{Filters: Filters{partitionKey: Range{Gte: 1}}, Limit: 5, Offset: 10}
+	flows, total, err := p.SearchFlowsPaged(context.Background(), s, q)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(flows) != 5 || total != 479 {
+		t.Fatalf("Expected 5 of 479 flows, got %d of %d", len(flows), total)
+	}
+	for i, f := range flows {
+		if expected := int64((469 - i) * 360); f.Statistics.Last != expected {
+			t.Fatalf("Expected the flow %d to be the one of %d, got %d", i, expected, f.Statistics.Last)
+		}
+	}
+	if searches := atomic.LoadInt64(&s.searches); searches == 0 || searches >= 48 {
+		t.Errorf("Expected the partitions past the page to be skipped, got %d searches", searches)
+	}
+
+	// the flows sorted on another field can't be merged by partition
+	atomic.StoreInt64(&s.searches, 0)
+	q.Sort = Sort{Field: "Statistics.Last"}
+	if _, _, err := p.SearchFlowsPaged(context.Background(), s, q); err != nil {
+		t.Fatal(err.Error())
+	}
+	if searches := atomic.LoadInt64(&s.searches); searches != 0 || s.filters == nil {
+		t.Errorf("Expected a single search, got %d partition searches", searches)
+	}
+}
+
+func TestPlannerPagedCancellation(t *testing.T) {
+	s := newPartitionedStorage(10, 1)
+	s.block = true
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	q := SearchQuery{Filters: Filters{partitionKey: Range{Gte: 1}}, Limit: 5}
+	if _, _, err := NewPlanner(3, 100, 0).SearchFlowsPaged(ctx, s, q); err != context.Canceled {
+		t.Errorf("Expected the search to be cancelled, got %v", err)
+	}
+}
+
+func TestPlannerUnbounded(t *testing.T) {
+	s := newPartitionedStorage(10, 1)
+	as := NewAliasedStorageFromConfig(s)
+
+	// without a lower bound the search isn't split
+	if _, err := as.SearchFlows(Filters{"last": "lte:3600"}); err != nil {
+		t.Fatal(err.Error())
+	}
+	if searches := atomic.LoadInt64(&s.searches); searches != 0 || s.filters == nil {
+		t.Errorf("Expected a single search, got %d partition searches", searches)
+	}
+
+	if _, err := as.SearchFlows(Filters{"last": "gte:1"}); err != nil {
+		t.Fatal(err.Error())
+	}
+	if atomic.LoadInt64(&s.searches) == 0 {
+		t.Error("Expected the search to be split")
+	}
+}
+
+func benchmarkPlanner(b *testing.B, period int64) {
+	s := newPartitionedStorage(7*24, 1000)
+	s.period = period
+	s.latency = 100 * time.Nanosecond
+	p := NewPlanner(4, 1000, 0)
+
+	for i := 0; i < b.N; i++ {
+		if _, _, err := p.search(context.Background(), s, Filters{}, Range{Gte: 1, Lt: 7 * 24 * 3600}, p.limit); err != nil {
+			b.Fatal(err.Error())
+		}
+	}
+}
+
+// BenchmarkPlannerMonolithic searches the 7 days of flows at once
+func BenchmarkPlannerMonolithic(b *testing.B) {
+	benchmarkPlanner(b, 0)
+}
+
+// BenchmarkPlannerPartitioned searches the 7 days of flows hour by hour
+func BenchmarkPlannerPartitioned(b *testing.B) {
+	benchmarkPlanner(b, 3600)
+}
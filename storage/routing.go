@@ -0,0 +1,355 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/redhat-cip/skydive/config"
+	"github.com/redhat-cip/skydive/flow"
+	"github.com/redhat-cip/skydive/logging"
+)
+
+// DefaultRoute is the partition of the flows without routing field
+const DefaultRoute = "default"
+
+// Router creates the storages of the partitions of a RoutedStorage, one
+// index or bucket per partition, and lists the ones already created
+type Router interface {
+	NewRoute(name string) (Storage, error)
+	Routes() ([]string, error)
+}
+
+// RouteName returns the name of the partition of a routing field value,
+// lowered and restricted to the characters accepted by the index names
+func RouteName(value string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r - 'A' + 'a'
+		}
+		return '_'
+	}, value)
+
+	if name == "" {
+		return DefaultRoute
+	}
+	return name
+}
+
+// RoutedStorage stores each flow in the partition of the value of its
+// routing field (tenant, region), the searches being sent to the partition
+// of the routing field value they filter on, or to all of them, and their
+// results merged.
+type RoutedStorage struct {
+	sync.RWMutex
+	Field   string
+	router  Router
+	routes  map[string]Storage
+	started bool
+	limit   int
+}
+
+// route returns the storage of the partition, created and started the
+// first time
+func (s *RoutedStorage) route(name string) (Storage, error) {
+	s.RLock()
+	st, ok := s.routes[name]
+	s.RUnlock()
+	if ok {
+		return st, nil
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	if st, ok = s.routes[name]; ok {
+		return st, nil
+	}
+
+	st, err := s.router.NewRoute(name)
+	if err != nil {
+		return nil, err
+	}
+	if s.started {
+		st.Start()
+	}
+	s.routes[name] = st
+
+	logging.GetLogger().Infof("Partition %s of the flows routed on %s opened", name, s.Field)
+	return st, nil
+}
+
+// selected returns the partitions the search of the filters is sent to
+func (s *RoutedStorage) selected(filters Filters) []Storage {
+	s.RLock()
+	defer s.RUnlock()
+
+	if value, ok := filters[s.Field].(string); ok {
+		if st, ok := s.routes[RouteName(value)]; ok {
+			return []Storage{st}
+		}
+		return nil
+	}
+
+	routes := make([]Storage, 0, len(s.routes))
+	for _, st := range s.routes {
+		routes = append(routes, st)
+	}
+	return routes
+}
+
+// discover opens the partitions created before, retrying until the
+// storage answers or is stopped
+func (s *RoutedStorage) discover() {
+	for {
+		names, err := s.router.Routes()
+		if err == nil {
+			for _, name := range names {
+				if _, err := s.route(name); err != nil {
+					logging.GetLogger().Errorf("Unable to open the partition %s of the storage: %s", name, err.Error())
+				}
+			}
+			return
+		}
+		logging.GetLogger().Errorf("Unable to list the partitions of the storage: %s", err.Error())
+
+		time.Sleep(time.Second)
+
+		s.RLock()
+		started := s.started
+		s.RUnlock()
+		if !started {
+			return
+		}
+	}
+}
+
+func (s *RoutedStorage) Start() {
+	s.Lock()
+	s.started = true
+	for _, st := range s.routes {
+		st.Start()
+	}
+	s.Unlock()
+
+	go s.discover()
+}
+
+func (s *RoutedStorage) Stop() {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, st := range s.routes {
+		st.Stop()
+	}
+	s.started = false
+}
+
+func (s *RoutedStorage) StoreFlows(flows []*flow.Flow) error {
+	return s.storeFlows(flows, func(st Storage, flows []*flow.Flow) error {
+		return st.StoreFlows(flows)
+	})
+}
+
+// StoreFlowsAcked stores the flows in their partitions, failing if one of
+// the partitions didn't acknowledge its flows
+func (s *RoutedStorage) StoreFlowsAcked(flows []*flow.Flow) error {
+	return s.storeFlows(flows, StoreFlowsAcked)
+}
+
+func (s *RoutedStorage) storeFlows(flows []*flow.Flow, store func(st Storage, flows []*flow.Flow) error) error {
+	routed := make(map[string][]*flow.Flow)
+	for _, f := range flows {
+		name := RouteName(f.GetFilterValue(s.Field))
+		routed[name] = append(routed[name], f)
+	}
+
+	var lastErr error
+	for name, flows := range routed {
+		st, err := s.route(name)
+		if err == nil {
+			err = store(st, flows)
+		}
+		if err != nil {
+			logging.GetLogger().Errorf("Unable to store %d flows in the partition %s: %s", len(flows), name, err.Error())
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+type routeResult struct {
+	flows []*flow.Flow
+	err   error
+}
+
+// fanOut runs the search in each selected partition at once, returning the
+// flows of all of them sorted by Statistics.Last in the descending order, at
+// most limit of them
+func (s *RoutedStorage) fanOut(ctx context.Context, filters Filters, limit int, search func(st Storage) ([]*flow.Flow, error)) ([]*flow.Flow, error) {
+	routes := s.selected(filters)
+
+	results := make(chan routeResult, len(routes))
+	for _, st := range routes {
+		go func(st Storage) {
+			flows, err := search(st)
+			results <- routeResult{flows: flows, err: err}
+		}(st)
+	}
+
+	flows := []*flow.Flow{}
+	for range routes {
+		select {
+		case res := <-results:
+			if res.err != nil {
+				return nil, res.err
+			}
+			flows = append(flows, res.flows...)
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	sort.Sort(sortByLastDesc(flows))
+	if limit > 0 && len(flows) > limit {
+		flows = flows[:limit]
+	}
+	return flows, nil
+}
+
+func (s *RoutedStorage) SearchFlows(filters Filters) ([]*flow.Flow, error) {
+	return s.SearchFlowsContext(context.Background(), filters)
+}
+
+func (s *RoutedStorage) SearchFlowsContext(ctx context.Context, filters Filters) ([]*flow.Flow, error) {
+	return s.fanOut(ctx, filters, s.limit, func(st Storage) ([]*flow.Flow, error) {
+		if cs, ok := st.(ContextSearcher); ok {
+			return cs.SearchFlowsContext(ctx, filters)
+		}
+		return st.SearchFlows(filters)
+	})
+}
+
+// Partitions returns the time partitions of the storages of the routes, the
+// searches of a time partition being sent to all the selected routes
+func (s *RoutedStorage) Partitions(r Range) []Range {
+	for _, st := range s.selected(nil) {
+		if ps, ok := st.(PartitionedStorage); ok {
+			return ps.Partitions(r)
+		}
+	}
+	return []Range{r}
+}
+
+func (s *RoutedStorage) SearchPartition(ctx context.Context, r Range, filters Filters, limit int) ([]*flow.Flow, error) {
+	return s.fanOut(ctx, filters, limit, func(st Storage) ([]*flow.Flow, error) {
+		if ps, ok := st.(PartitionedStorage); ok {
+			return ps.SearchPartition(ctx, r, filters, limit)
+		}
+		return st.SearchFlows(filters)
+	})
+}
+
+func (s *RoutedStorage) CountFlows(filters Filters) (int, error) {
+	count := 0
+	for _, st := range s.selected(filters) {
+		c, err := st.CountFlows(filters)
+		if err != nil {
+			return 0, err
+		}
+		count += c
+	}
+	return count, nil
+}
+
+// Ping checks that the storages of all the partitions are reachable
+func (s *RoutedStorage) Ping(ctx context.Context) error {
+	s.RLock()
+	routes := make(map[string]Storage, len(s.routes))
+	for name, st := range s.routes {
+		routes[name] = st
+	}
+	s.RUnlock()
+
+	for name, st := range routes {
+		if err := Ping(ctx, st); err != nil {
+			return fmt.Errorf("Partition %s: %s", name, err.Error())
+		}
+	}
+	return nil
+}
+
+type sortByLastDesc []*flow.Flow
+
+func (s sortByLastDesc) Len() int {
+	return len(s)
+}
+
+func (s sortByLastDesc) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s sortByLastDesc) Less(i, j int) bool {
+	return flowLast(s[i]) > flowLast(s[j])
+}
+
+func flowLast(f *flow.Flow) int64 {
+	if f.Statistics == nil {
+		return 0
+	}
+	return f.Statistics.Last
+}
+
+// NewRoutedStorage returns a storage routing the flows on the field, the
+// searches returning at most limit flows, 0 meaning no limit
+func NewRoutedStorage(field string, router Router, limit int) (*RoutedStorage, error) {
+	if !flow.IsFilterKey(field) {
+		return nil, fmt.Errorf("Invalid routing field: %s", field)
+	}
+
+	return &RoutedStorage{
+		Field:  field,
+		router: router,
+		routes: make(map[string]Storage),
+		limit:  limit,
+	}, nil
+}
+
+// NewRoutedStorageFromConfig returns a storage routing the flows on the
+// storage.routing.field
+func NewRoutedStorageFromConfig(router Router) (*RoutedStorage, error) {
+	return NewRoutedStorage(
+		config.GetConfig().GetString("storage.routing.field"),
+		router,
+		config.GetConfig().GetInt("analyzer.query_max_results"),
+	)
+}
@@ -0,0 +1,226 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package storage
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/redhat-cip/skydive/flow"
+)
+
+// memoryRoute is a partition keeping its flows, counting its searches
+type memoryRoute struct {
+	recordingStorage
+	sync.Mutex
+	flows    []*flow.Flow
+	searches int
+}
+
+func (r *memoryRoute) StoreFlows(flows []*flow.Flow) error {
+	r.Lock()
+	r.flows = append(r.flows, flows...)
+	r.Unlock()
+	return nil
+}
+
+func (r *memoryRoute) SearchFlows(filters Filters) ([]*flow.Flow, error) {
+	r.Lock()
+	defer r.Unlock()
+	r.searches++
+	return append([]*flow.Flow{}, r.flows...), nil
+}
+
+func (r *memoryRoute) CountFlows(filters Filters) (int, error) {
+	r.Lock()
+	defer r.Unlock()
+	return len(r.flows), nil
+}
+
+type memoryRouter struct {
+	sync.Mutex
+	routes   map[string]*memoryRoute
+	existing []string
+}
+
+func (m *memoryRouter) NewRoute(name string) (Storage, error) {
+	m.Lock()
+	defer m.Unlock()
+	r := &memoryRoute{}
+	m.routes[name] = r
+	return r, nil
+}
+
+func (m *memoryRouter) Routes() ([]string, error) {
+	return m.existing, nil
+}
+
+func (m *memoryRouter) route(name string) *memoryRoute {
+	m.Lock()
+	defer m.Unlock()
+	return m.routes[name]
+}
+
+func tenantFlow(i int, tenant string) *flow.Flow {
+	f := &flow.Flow{
+		UUID:       strconv.Itoa(i),
+		Statistics: &flow.FlowStatistics{Last: int64(100 + i)},
+	}
+	if tenant != "" {
+		f.Attributes = map[string]string{"Tenant": tenant}
+	}
+	return f
+}
+
+func TestRoutedStorage(t *testing.T) {
+	router := &memoryRouter{routes: make(map[string]*memoryRoute)}
+	s, err := NewRoutedStorage("Attributes.Tenant", router, 5)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	s.Start()
+	defer s.Stop()
+
+	tenants := []string{"Blue", "red", "", "Blue", "red", "Blue"}
+	var flows []*flow.Flow
+	for i, tenant := range tenants {
+		flows = append(flows, tenantFlow(i, tenant))
+	}
+	if err := s.StoreFlows(flows); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// each flow lands in the partition of its tenant
+	for name, expected := range map[string][]string{"blue": {"0", "3", "5"}, "red": {"1", "4"}, DefaultRoute: {"2"}} {
+		route := router.route(name)
+		if route == nil {
+			t.Fatalf("Partition %s not created", name)
+		}
+
+		var uuids []string
+		for _, f := range route.flows {
+			uuids = append(uuids, f.UUID)
+		}
+		sort.Strings(uuids)
+		if len(uuids) != len(expected) || (len(uuids) > 0 && uuids[0] != expected[0]) {
+			t.Errorf("Wrong flows in the partition %s: %v, expected %v", name, uuids, expected)
+		}
+	}
+
+	// a search without the tenant aggregates all the partitions, most
+	// recent first, up to the limit
+	all, err := s.SearchFlows(Filters{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	var uuids []string
+	for _, f := range all {
+		uuids = append(uuids, f.UUID)
+	}
+	if len(uuids) != 5 || uuids[0] != "5" || uuids[4] != "1" {
+		t.Errorf("Expected the 5 most recent flows of all the partitions, got %v", uuids)
+	}
+
+	// a search filtering on the tenant only hits its partition
+	blue, err := s.SearchFlows(Filters{"Attributes.Tenant": "Blue"})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(blue) != 3 || router.route("red").searches != 1 {
+		t.Errorf("Expected the search to hit the blue partition only, got %d flows, %d searches of red", len(blue), router.route("red").searches)
+	}
+
+	if flows, err := s.SearchFlows(Filters{"Attributes.Tenant": "green"}); err != nil || len(flows) != 0 {
+		t.Errorf("Expected no flow of an unknown tenant: %v, %v", flows, err)
+	}
+
+	if count, err := s.CountFlows(Filters{}); err != nil || count != 6 {
+		t.Errorf("Expected the count of all the partitions, got %d, %v", count, err)
+	}
+}
+
+func TestRoutedStorageDiscovery(t *testing.T) {
+	router := &memoryRouter{routes: make(map[string]*memoryRoute), existing: []string{"blue", "red"}}
+	s, err := NewRoutedStorage("Attributes.Tenant", router, 0)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	s.Start()
+	defer s.Stop()
+
+	// the partitions created before are searched
+	for i := 0; i < 100 && router.route("red") == nil; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	router.route("red").StoreFlows([]*flow.Flow{tenantFlow(1, "red")})
+
+	if flows, err := s.SearchFlows(Filters{}); err != nil || len(flows) != 1 {
+		t.Errorf("Expected the flow of the existing partition, got %v, %v", flows, err)
+	}
+
+	if _, err := NewRoutedStorage("Tenant", router, 0); err == nil {
+		t.Error("Expected an unknown routing field to be refused")
+	}
+}
+
+// unreachableStorage fails to count its flows
+type unreachableStorage struct {
+	recordingStorage
+}
+
+func (s *unreachableStorage) CountFlows(filters Filters) (int, error) {
+	return 0, errors.New("connection refused")
+}
+
+func TestRoutedStoragePing(t *testing.T) {
+	router := &memoryRouter{routes: make(map[string]*memoryRoute)}
+	s, err := NewRoutedStorage("Attributes.Tenant", router, 5)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	s.Start()
+	defer s.Stop()
+
+	if err := s.StoreFlows([]*flow.Flow{tenantFlow(0, "blue"), tenantFlow(1, "red")}); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	aliased := NewAliasedStorage(s, nil)
+	if err := Ping(context.Background(), aliased); err != nil {
+		t.Errorf("Expected the partitions to be reachable, got %s", err.Error())
+	}
+
+	s.Lock()
+	s.routes["green"] = &unreachableStorage{}
+	s.Unlock()
+
+	if err := Ping(context.Background(), aliased); err == nil {
+		t.Error("Expected an unreachable partition to fail the ping")
+	}
+}
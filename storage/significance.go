@@ -0,0 +1,282 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package storage
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"hash/fnv"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redhat-cip/skydive/config"
+	"github.com/redhat-cip/skydive/flow"
+)
+
+// NoiseKey is the attribute set to true on the noise records, the noise
+// filter alias selecting them
+const NoiseKey = "Noise"
+
+// noiseSketchBits is the size of the bitmap estimating the distinct
+// destinations of a noise record by linear counting
+const noiseSketchBits = 4096
+
+// FlowSignificance gives the thresholds under which a flow isn't
+// significant: fewer packets, fewer bytes and a shorter duration, in
+// seconds, than all the thresholds set, the null ones being ignored.
+type FlowSignificance struct {
+	Packets  uint64 `json:",omitempty"`
+	Bytes    uint64 `json:",omitempty"`
+	Duration int64  `json:",omitempty"`
+}
+
+// Enabled returns whether a threshold is set
+func (s FlowSignificance) Enabled() bool {
+	return s.Packets != 0 || s.Bytes != 0 || s.Duration != 0
+}
+
+// Significant returns whether the flow reaches one of the thresholds
+func (s FlowSignificance) Significant(f *flow.Flow) bool {
+	if !s.Enabled() {
+		return true
+	}
+	bytes, packets := flowCounters(f)
+	return (s.Packets != 0 && packets >= s.Packets) ||
+		(s.Bytes != 0 && bytes >= s.Bytes) ||
+		(s.Duration != 0 && f.Duration >= s.Duration)
+}
+
+// flowCounters returns the counters of the outermost layer of the flow
+func flowCounters(f *flow.Flow) (bytes uint64, packets uint64) {
+	if f.Statistics == nil || len(f.Statistics.Endpoints) == 0 || f.Statistics.Endpoints[0] == nil {
+		return 0, 0
+	}
+	if e := f.Statistics.Endpoints[0].AB; e != nil {
+		bytes, packets = bytes+e.Bytes, packets+e.Packets
+	}
+	if e := f.Statistics.Endpoints[0].BA; e != nil {
+		bytes, packets = bytes+e.Bytes, packets+e.Packets
+	}
+	return
+}
+
+// flowSource returns the endpoints of the network layer of the flow, the
+// link layer ones without network layer
+func flowSource(f *flow.Flow) (t flow.FlowEndpointType, source string, destination string) {
+	if f.Statistics == nil {
+		return
+	}
+	for _, e := range f.Statistics.Endpoints {
+		if e == nil || e.AB == nil || e.BA == nil {
+			continue
+		}
+		if e.Type == flow.FlowEndpointType_IPV4 || source == "" {
+			t, source, destination = e.Type, e.AB.Value, e.BA.Value
+		}
+	}
+	return
+}
+
+type noiseKey struct {
+	start  int64
+	source string
+}
+
+// noiseRecord sums the insignificant flows of a source during an interval
+type noiseRecord struct {
+	endpointType flow.FlowEndpointType
+	flows        int
+	bytes        uint64
+	packets      uint64
+	destinations [noiseSketchBits / 64]uint64
+}
+
+func (r *noiseRecord) add(f *flow.Flow, destination string) {
+	bytes, packets := flowCounters(f)
+	r.flows++
+	r.bytes += bytes
+	r.packets += packets
+
+	h := fnv.New32a()
+	h.Write([]byte(destination))
+	bit := h.Sum32() % noiseSketchBits
+	r.destinations[bit/64] |= 1 << (bit % 64)
+}
+
+// distinctDestinations estimates the number of distinct destinations from
+// the ratio of the bits left unset
+func (r *noiseRecord) distinctDestinations() int {
+	zeros := 0
+	for _, word := range r.destinations {
+		for i := uint(0); i < 64; i++ {
+			if word&(1<<i) == 0 {
+				zeros++
+			}
+		}
+	}
+	if zeros == 0 {
+		zeros = 1
+	}
+	return int(math.Floor(-noiseSketchBits*math.Log(float64(zeros)/noiseSketchBits) + 0.5))
+}
+
+// flow returns the record as a flow, stored along with the other ones
+func (r *noiseRecord) flow(key noiseKey, interval int64) *flow.Flow {
+	id := sha1.Sum([]byte(strconv.FormatInt(key.start, 10) + "/" + key.source))
+
+	return &flow.Flow{
+		UUID:       hex.EncodeToString(id[:]),
+		LayersPath: NoiseKey,
+		Statistics: &flow.FlowStatistics{
+			Start: key.start,
+			Last:  key.start + interval - 1,
+			Endpoints: []*flow.FlowEndpointsStatistics{
+				{
+					Type: r.endpointType,
+					AB:   &flow.FlowEndpointStatistics{Value: key.source, Bytes: r.bytes, Packets: r.packets},
+					BA:   &flow.FlowEndpointStatistics{},
+				},
+			},
+		},
+		Attributes: map[string]string{
+			NoiseKey:                   "true",
+			NoiseKey + ".Flows":        strconv.Itoa(r.flows),
+			NoiseKey + ".Destinations": strconv.Itoa(r.distinctDestinations()),
+		},
+	}
+}
+
+// SignificanceFilter keeps the insignificant flows out of the storage,
+// before the storage only so that the flow table keeps all of them. The
+// expired ones are folded into noise records, by source and by Interval
+// seconds, stored once the interval is over. The thresholds of the
+// capture of the flows, given by probe node by Captures, apply instead of
+// the Default ones. The flows carrying tags or retained by Retain, ex: the
+// ones matched by an alert, are always stored.
+type SignificanceFilter struct {
+	sync.Mutex
+	Default  FlowSignificance
+	Interval int64
+	Captures func() map[string]FlowSignificance
+	Retain   func(f *flow.Flow) bool
+	noise    map[noiseKey]*noiseRecord
+}
+
+func (s *SignificanceFilter) filter(flows []*flow.Flow, expired bool, now time.Time) []*flow.Flow {
+	var captures map[string]FlowSignificance
+	if s.Captures != nil {
+		captures = s.Captures()
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	stored := make([]*flow.Flow, 0, len(flows))
+	for _, f := range flows {
+		thresholds, ok := captures[f.ProbeNodeUUID]
+		if !ok {
+			thresholds = s.Default
+		}
+
+		if thresholds.Significant(f) || len(f.GetTags()) > 0 || (s.Retain != nil && s.Retain(f)) {
+			stored = append(stored, f)
+			continue
+		}
+
+		// the updates of a flow are skipped, its last state being folded
+		// once expired
+		if !expired {
+			continue
+		}
+
+		t, source, destination := flowSource(f)
+		var start int64
+		if fs := f.GetStatistics(); fs != nil {
+			start = fs.Last - fs.Last%s.Interval
+		}
+		key := noiseKey{start: start, source: source}
+
+		record, ok := s.noise[key]
+		if !ok {
+			record = &noiseRecord{endpointType: t}
+			s.noise[key] = record
+		}
+		record.add(f, destination)
+	}
+
+	for key, record := range s.noise {
+		if key.start+s.Interval <= now.Unix() {
+			stored = append(stored, record.flow(key, s.Interval))
+			delete(s.noise, key)
+		}
+	}
+
+	return stored
+}
+
+// Filter returns the flows to store, along with the noise records of the
+// intervals over
+func (s *SignificanceFilter) Filter(flows []*flow.Flow, expired bool) []*flow.Flow {
+	return s.filter(flows, expired, time.Now())
+}
+
+// Flush returns the noise records of all the intervals
+func (s *SignificanceFilter) Flush() []*flow.Flow {
+	s.Lock()
+	defer s.Unlock()
+
+	var records []*flow.Flow
+	for key, record := range s.noise {
+		records = append(records, record.flow(key, s.Interval))
+		delete(s.noise, key)
+	}
+	return records
+}
+
+func NewSignificanceFilter(thresholds FlowSignificance, interval int64) *SignificanceFilter {
+	if interval <= 0 {
+		interval = 60
+	}
+	return &SignificanceFilter{
+		Default:  thresholds,
+		Interval: interval,
+		noise:    make(map[noiseKey]*noiseRecord),
+	}
+}
+
+// NewSignificanceFilterFromConfig returns the filter configured by
+// analyzer.flow_significance, nil when disabled
+func NewSignificanceFilterFromConfig() *SignificanceFilter {
+	cfg := config.GetConfig()
+	if !cfg.GetBool("analyzer.flow_significance.enabled") {
+		return nil
+	}
+
+	return NewSignificanceFilter(FlowSignificance{
+		Packets:  uint64(cfg.GetInt("analyzer.flow_significance.packets")),
+		Bytes:    uint64(cfg.GetInt("analyzer.flow_significance.bytes")),
+		Duration: int64(cfg.GetInt("analyzer.flow_significance.duration")),
+	}, int64(cfg.GetInt("analyzer.flow_significance.interval")))
+}
@@ -0,0 +1,140 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package storage
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/redhat-cip/skydive/flow"
+)
+
+func newSignificanceFlow(uuid string, src string, dst string, packets uint64, bytes uint64, last int64) *flow.Flow {
+	return &flow.Flow{
+		UUID:          uuid,
+		ProbeNodeUUID: "probe",
+		Statistics: &flow.FlowStatistics{
+			Start: last,
+			Last:  last,
+			Endpoints: []*flow.FlowEndpointsStatistics{
+				{
+					Type: flow.FlowEndpointType_ETHERNET,
+					AB:   &flow.FlowEndpointStatistics{Value: "00:00:00:00:00:01", Packets: packets, Bytes: bytes},
+					BA:   &flow.FlowEndpointStatistics{Value: "00:00:00:00:00:02"},
+				},
+				{
+					Type: flow.FlowEndpointType_IPV4,
+					AB:   &flow.FlowEndpointStatistics{Value: src, Packets: packets, Bytes: bytes},
+					BA:   &flow.FlowEndpointStatistics{Value: dst},
+				},
+			},
+		},
+	}
+}
+
+func TestSignificanceFilterScan(t *testing.T) {
+	now := time.Unix(6000, 0)
+	sf := NewSignificanceFilter(FlowSignificance{Packets: 3, Bytes: 1000, Duration: 5}, 60)
+
+	var flows []*flow.Flow
+	for i := 0; i < 2000; i++ {
+		dst := fmt.Sprintf("10.1.%d.%d", i/256, i%256)
+		flows = append(flows, newSignificanceFlow("scan"+strconv.Itoa(i), "10.0.0.1", dst, 1, 60, now.Unix()))
+	}
+	for i := 0; i < 10; i++ {
+		flows = append(flows, newSignificanceFlow("session"+strconv.Itoa(i), "10.0.0.2", "10.0.0.3", 100, 150000, now.Unix()))
+	}
+
+	// the updates of the scan flows are skipped
+	if stored := sf.filter(flows, false, now); len(stored) != 10 {
+		t.Fatalf("Expected the 10 significant flows to be stored, got %d", len(stored))
+	}
+
+	// the interval isn't over, the noise isn't stored yet
+	if stored := sf.filter(flows, true, now); len(stored) != 10 {
+		t.Fatalf("Expected the 10 significant flows to be stored, got %d", len(stored))
+	}
+
+	stored := sf.filter(nil, true, now.Add(time.Minute))
+	if len(stored) != 1 {
+		t.Fatalf("Expected a single noise record, got %d", len(stored))
+	}
+
+	noise := stored[0]
+	attrs := noise.GetAttributes()
+	if attrs[NoiseKey] != "true" || attrs[NoiseKey+".Flows"] != "2000" {
+		t.Errorf("Unexpected noise record attributes: %v", attrs)
+	}
+	if destinations, _ := strconv.Atoi(attrs[NoiseKey+".Destinations"]); destinations < 1900 || destinations > 2100 {
+		t.Errorf("Expected about 2000 distinct destinations, got %d", destinations)
+	}
+
+	e := noise.Statistics.Endpoints[0]
+	if e.Type != flow.FlowEndpointType_IPV4 || e.AB.Value != "10.0.0.1" || e.AB.Packets != 2000 || e.AB.Bytes != 120000 {
+		t.Errorf("Unexpected noise record endpoints: %v", e)
+	}
+	if noise.Statistics.Start != 6000 || noise.Statistics.Last != 6059 {
+		t.Errorf("Unexpected noise record interval: %v", noise.Statistics)
+	}
+
+	filter, err := flow.ParseFilter("Attributes.Noise=true")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !filter.Match(noise) || filter.Match(flows[0]) {
+		t.Error("The noise filter should only select the noise records")
+	}
+}
+
+func TestSignificanceFilterRetained(t *testing.T) {
+	now := time.Unix(6000, 0)
+	sf := NewSignificanceFilter(FlowSignificance{Packets: 3}, 60)
+	sf.Retain = func(f *flow.Flow) bool {
+		return f.UUID == "alert"
+	}
+	sf.Captures = func() map[string]FlowSignificance {
+		return map[string]FlowSignificance{"captured": {Packets: 100}}
+	}
+
+	labeled := newSignificanceFlow("labeled", "10.0.0.1", "10.0.0.2", 1, 60, now.Unix())
+	labeled.SetTags(map[string]string{"Tag.env": "prod"})
+
+	alert := newSignificanceFlow("alert", "10.0.0.1", "10.0.0.2", 1, 60, now.Unix())
+	tiny := newSignificanceFlow("tiny", "10.0.0.1", "10.0.0.2", 1, 60, now.Unix())
+
+	// significant globally but not for its capture
+	captured := newSignificanceFlow("captured", "10.0.0.1", "10.0.0.2", 10, 600, now.Unix())
+	captured.ProbeNodeUUID = "captured"
+
+	stored := sf.filter([]*flow.Flow{labeled, alert, tiny, captured}, true, now)
+	if len(stored) != 2 || stored[0].UUID != "labeled" || stored[1].UUID != "alert" {
+		t.Fatalf("Expected the labeled and the alert flows to be stored, got %v", stored)
+	}
+
+	records := sf.Flush()
+	if len(records) != 1 || records[0].GetAttributes()[NoiseKey+".Flows"] != "2" {
+		t.Fatalf("Expected the tiny and the captured flows folded into a noise record, got %v", records)
+	}
+}
@@ -23,14 +23,217 @@
 package storage
 
 import (
+	"errors"
+	"sort"
+
+	"golang.org/x/net/context"
+
 	"github.com/redhat-cip/skydive/flow"
 )
 
 type Filters map[string]interface{}
 
+// Range filters the values between the bounds, a zero bound being ignored
+type Range struct {
+	Gte int64 `json:"gte,omitempty"`
+	Lt  int64 `json:"lt,omitempty"`
+	Lte int64 `json:"lte,omitempty"`
+}
+
+func (r Range) Match(value int64) bool {
+	return (r.Gte == 0 || value >= r.Gte) && (r.Lt == 0 || value < r.Lt) && (r.Lte == 0 || value <= r.Lte)
+}
+
+// In filters the values equal to one of the given ones, int64 for the
+// numeric fields
+type In []interface{}
+
+// Exists filters the flows having the field, or the endpoint side, when
+// true, and the ones not having it when false
+type Exists bool
+
+// Intersect returns the range of the values between the bounds of both
+// ranges
+func (r Range) Intersect(o Range) Range {
+	if o.Gte > r.Gte {
+		r.Gte = o.Gte
+	}
+	if o.Lt != 0 && (r.Lt == 0 || o.Lt < r.Lt) {
+		r.Lt = o.Lt
+	}
+	if o.Lte != 0 && (r.Lte == 0 || o.Lte < r.Lte) {
+		r.Lte = o.Lte
+	}
+	return r
+}
+
+// NumericFilter returns the filter value of a flow numeric filter, the
+// strict lower bound and the upper bound being shifted so that a zero
+// bound (Duration=lte:0) isn't ignored
+func NumericFilter(nf flow.NumericFilter) interface{} {
+	switch nf.Op {
+	case "gt":
+		return Range{Gte: nf.Value + 1}
+	case "gte":
+		return Range{Gte: nf.Value}
+	case "lt":
+		return Range{Lt: nf.Value}
+	case "lte":
+		return Range{Lt: nf.Value + 1}
+	}
+	return nf.Value
+}
+
+// ErrFlowFeedNotSupported is returned by the storages not implementing
+// FlowFeed
+var ErrFlowFeedNotSupported = errors.New("The storage doesn't support the flow feed")
+
+// FlowFeed is implemented by the storages numbering the flows in the order
+// they are stored. SearchFlowsSince returns, in this order, at most limit
+// flows stored after the cursor along with the cursor of the last one. A
+// flow is stored, and so returned, again at each update.
+type FlowFeed interface {
+	SearchFlowsSince(filters Filters, cursor int64, limit int) ([]*flow.Flow, int64, error)
+}
+
+// BulkLimiter is implemented by the storages sending the flows in bulks of
+// at most MaxBulkSize flows. Larger batches are still stored, split into
+// bulks, the batches of the analyzer being bounded to this size.
+type BulkLimiter interface {
+	MaxBulkSize() int
+}
+
+// SplitBulks splits the flows into bulks of at most size flows, a size of 0
+// meaning a single bulk
+func SplitBulks(flows []*flow.Flow, size int) [][]*flow.Flow {
+	if size <= 0 || len(flows) <= size {
+		return [][]*flow.Flow{flows}
+	}
+
+	bulks := make([][]*flow.Flow, 0, (len(flows)+size-1)/size)
+	for len(flows) > size {
+		bulks = append(bulks, flows[:size])
+		flows = flows[size:]
+	}
+	return append(bulks, flows)
+}
+
+// AckedStorage is implemented by the storages confirming that the flows
+// were stored, StoreFlowsAcked returning once the storage acknowledged them
+// and failing otherwise. The flows being indexed by UUID, storing them again
+// updates them in place.
+type AckedStorage interface {
+	StoreFlowsAcked(flows []*flow.Flow) error
+}
+
+// Pinger is implemented by the storages able to tell cheaply whether they
+// are reachable
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Ping checks that the storage is reachable, by counting its flows when it
+// can't be pinged
+func Ping(ctx context.Context, s Storage) error {
+	if p, ok := s.(Pinger); ok {
+		return p.Ping(ctx)
+	}
+	_, err := s.CountFlows(Filters{})
+	return err
+}
+
+// Sort orders the flows by a numeric field
+type Sort struct {
+	Field string
+	Desc  bool
+}
+
+// DefaultSort orders the flows from the most recent one
+var DefaultSort = Sort{Field: "Statistics.Last", Desc: true}
+
+// SearchQuery is a search of a page of the flows, at most Limit flows, 0
+// meaning no limit, after skipping Offset ones. The flows are ordered by
+// Sort, DefaultSort when not set.
+type SearchQuery struct {
+	Filters Filters
+	Limit   int
+	Offset  int
+	Sort    Sort
+}
+
+// SortOrder returns the order of the flows of the query
+func (q SearchQuery) SortOrder() Sort {
+	if q.Sort.Field == "" {
+		return DefaultSort
+	}
+	return q.Sort
+}
+
+// PagedSearcher is implemented by the storages limiting the searches
+// themselves. SearchFlowsPaged returns the page of the query, the flows
+// being sorted as requested, along with the number of flows matching the
+// filters, the search being cancelled along with the context.
+type PagedSearcher interface {
+	SearchFlowsPaged(ctx context.Context, q SearchQuery) ([]*flow.Flow, int, error)
+}
+
+// sortFlows orders the flows by a numeric field
+type sortFlows struct {
+	flows []*flow.Flow
+	order Sort
+}
+
+func (s sortFlows) Len() int {
+	return len(s.flows)
+}
+
+func (s sortFlows) Swap(i, j int) {
+	s.flows[i], s.flows[j] = s.flows[j], s.flows[i]
+}
+
+func (s sortFlows) Less(i, j int) bool {
+	a, _ := s.flows[i].GetFieldInt64(s.order.Field)
+	b, _ := s.flows[j].GetFieldInt64(s.order.Field)
+	if s.order.Desc {
+		return a > b
+	}
+	return a < b
+}
+
+// PageFlows returns the page of the flows searched by a storage not limiting
+// the searches, sorted as by PagedSearcher
+func PageFlows(flows []*flow.Flow, q SearchQuery) []*flow.Flow {
+	sort.Stable(sortFlows{flows: flows, order: q.SortOrder()})
+
+	if q.Offset >= len(flows) {
+		return []*flow.Flow{}
+	}
+	flows = flows[q.Offset:]
+	if q.Limit > 0 && len(flows) > q.Limit {
+		flows = flows[:q.Limit]
+	}
+	return flows
+}
+
+// SearchFlowsPaged searches a page of the flows of the storage, all the
+// flows being searched and then paged when the storage doesn't limit the
+// searches
+func SearchFlowsPaged(ctx context.Context, s Storage, q SearchQuery) ([]*flow.Flow, int, error) {
+	if ps, ok := s.(PagedSearcher); ok {
+		return ps.SearchFlowsPaged(ctx, q)
+	}
+
+	flows, err := searchFlowsContext(ctx, s, q.Filters)
+	if err != nil {
+		return nil, 0, err
+	}
+	return PageFlows(flows, q), len(flows), nil
+}
+
 type Storage interface {
 	Start()
 	StoreFlows(flows []*flow.Flow) error
 	SearchFlows(filters Filters) ([]*flow.Flow, error)
+	CountFlows(filters Filters) (int, error)
 	Stop()
 }
@@ -0,0 +1,398 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redhat-cip/skydive/config"
+	"github.com/redhat-cip/skydive/flow"
+	"github.com/redhat-cip/skydive/logging"
+)
+
+const walSuffix = ".wal"
+
+// ErrWALFull is returned when logging a batch would exceed the size bounds
+// of the write-ahead log
+var ErrWALFull = errors.New("The write-ahead log is full")
+
+// WALStatus reports the entries of the write-ahead log
+type WALStatus struct {
+	Entries  int
+	Size     int64
+	Pending  int
+	Replayed int64
+	Bypassed int64
+}
+
+// WAL logs the batches of flows on disk before storing them, an entry being
+// removed once the storage acknowledged its flows. The entries left by a
+// crash or by a storage failure are replayed at start, then periodically,
+// the flows being upserted by UUID so that replaying an entry stored already
+// doesn't duplicate its flows. The batches that don't fit in the size bounds
+// are stored without being logged.
+type WAL struct {
+	sync.Mutex
+	Dir           string
+	MaxSize       int64
+	MaxEntries    int
+	RetryInterval time.Duration
+	storage       Storage
+	// size of the entries by name
+	entries map[string]int64
+	// entries whose flows weren't acknowledged
+	pending  map[string]bool
+	size     int64
+	last     int64
+	replayed int64
+	bypassed int64
+	quit     chan bool
+	wg       sync.WaitGroup
+}
+
+// StoreFlowsAcked stores the flows, waiting for the storage to acknowledge
+// them when it supports it
+func StoreFlowsAcked(s Storage, flows []*flow.Flow) error {
+	if acked, ok := s.(AckedStorage); ok {
+		return acked.StoreFlowsAcked(flows)
+	}
+	return s.StoreFlows(flows)
+}
+
+func encodeWALEntry(flows []*flow.Flow) ([]byte, error) {
+	var buf bytes.Buffer
+	header := make([]byte, 4)
+	for _, f := range flows {
+		data, err := f.GetData()
+		if err != nil {
+			return nil, err
+		}
+		binary.BigEndian.PutUint32(header, uint32(len(data)))
+		buf.Write(header)
+		buf.Write(data)
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeWALEntry(data []byte) ([]*flow.Flow, error) {
+	var flows []*flow.Flow
+	r := bytes.NewReader(data)
+	header := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(r, header); err == io.EOF {
+			return flows, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		size := binary.BigEndian.Uint32(header)
+		if int64(size) > int64(r.Len()) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		data := make([]byte, size)
+		r.Read(data)
+
+		f, err := flow.FromData(data)
+		if err != nil {
+			return nil, err
+		}
+		flows = append(flows, f)
+	}
+}
+
+// nextName returns the name of a new entry, sorting after the previous
+// ones, the pid keeping apart the entries of the analyzers sharing the log
+// during a handover
+func (w *WAL) nextName() string {
+	next := time.Now().UnixNano()
+	if next <= w.last {
+		next = w.last + 1
+	}
+	w.last = next
+	return fmt.Sprintf("%016x-%d%s", next, os.Getpid(), walSuffix)
+}
+
+// Open loads the entries left in the directory, the partially written ones
+// being removed
+func (w *WAL) Open() error {
+	if err := os.MkdirAll(w.Dir, 0700); err != nil {
+		return err
+	}
+
+	files, err := ioutil.ReadDir(w.Dir)
+	if err != nil {
+		return err
+	}
+
+	w.Lock()
+	defer w.Unlock()
+
+	for _, fi := range files {
+		switch {
+		case strings.HasPrefix(fi.Name(), "."):
+			os.Remove(filepath.Join(w.Dir, fi.Name()))
+		case strings.HasSuffix(fi.Name(), walSuffix):
+			w.entries[fi.Name()] = fi.Size()
+			w.pending[fi.Name()] = true
+			w.size += fi.Size()
+		}
+	}
+
+	if len(w.pending) > 0 {
+		logging.GetLogger().Noticef("%d batches of flows to replay from the write-ahead log %s", len(w.pending), w.Dir)
+	}
+	return nil
+}
+
+// append writes the entry through a temporary file synced to disk, so that
+// an entry is either complete or missing after a crash
+func (w *WAL) append(flows []*flow.Flow) (string, error) {
+	data, err := encodeWALEntry(flows)
+	if err != nil {
+		return "", err
+	}
+
+	w.Lock()
+	if (w.MaxSize > 0 && w.size+int64(len(data)) > w.MaxSize) || (w.MaxEntries > 0 && len(w.entries) >= w.MaxEntries) {
+		w.Unlock()
+		return "", ErrWALFull
+	}
+	name := w.nextName()
+	w.entries[name] = int64(len(data))
+	w.size += int64(len(data))
+	w.Unlock()
+
+	if err := w.write(name, data); err != nil {
+		w.remove(name)
+		return "", err
+	}
+	return name, nil
+}
+
+func (w *WAL) write(name string, data []byte) error {
+	tmp, err := ioutil.TempFile(w.Dir, "."+name)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), filepath.Join(w.Dir, name)); err != nil {
+		return err
+	}
+
+	// the rename is durable once the directory is synced
+	dir, err := os.Open(w.Dir)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
+
+func (w *WAL) remove(name string) {
+	if err := os.Remove(filepath.Join(w.Dir, name)); err != nil && !os.IsNotExist(err) {
+		logging.GetLogger().Errorf("Unable to remove the entry %s of the write-ahead log: %s", name, err.Error())
+		return
+	}
+
+	w.Lock()
+	w.size -= w.entries[name]
+	delete(w.entries, name)
+	delete(w.pending, name)
+	w.Unlock()
+}
+
+// StoreFlows logs the flows then stores them, the entry being kept to be
+// replayed if the storage doesn't acknowledge them
+func (w *WAL) StoreFlows(flows []*flow.Flow) error {
+	if len(flows) == 0 {
+		return nil
+	}
+
+	name, err := w.append(flows)
+	if err != nil {
+		w.Lock()
+		w.bypassed++
+		w.Unlock()
+		logging.GetLogger().Warningf("%d flows stored without being logged: %s", len(flows), err.Error())
+		return w.storage.StoreFlows(flows)
+	}
+
+	if err := StoreFlowsAcked(w.storage, flows); err != nil {
+		w.Lock()
+		w.pending[name] = true
+		w.Unlock()
+		return err
+	}
+
+	w.remove(name)
+	return nil
+}
+
+// Replay stores the flows of the pending entries in the order they were
+// logged, stopping at the first storage failure. The entries that can't be
+// decoded are dropped.
+func (w *WAL) Replay() (int, error) {
+	w.Lock()
+	names := make([]string, 0, len(w.pending))
+	for name := range w.pending {
+		names = append(names, name)
+	}
+	w.Unlock()
+	sort.Strings(names)
+
+	replayed := 0
+	for _, name := range names {
+		data, err := ioutil.ReadFile(filepath.Join(w.Dir, name))
+		if os.IsNotExist(err) {
+			// replayed by the other analyzer of a handover
+			w.remove(name)
+			continue
+		}
+
+		var flows []*flow.Flow
+		if err == nil {
+			flows, err = decodeWALEntry(data)
+		}
+		if err != nil {
+			logging.GetLogger().Errorf("Dropping the corrupted entry %s of the write-ahead log: %s", name, err.Error())
+			w.remove(name)
+			continue
+		}
+
+		if err := StoreFlowsAcked(w.storage, flows); err != nil {
+			return replayed, err
+		}
+
+		w.remove(name)
+		replayed += len(flows)
+	}
+
+	w.Lock()
+	w.replayed += int64(replayed)
+	w.Unlock()
+
+	return replayed, nil
+}
+
+func (w *WAL) retry() {
+	defer w.wg.Done()
+
+	for {
+		if n, err := w.Replay(); err != nil {
+			logging.GetLogger().Errorf("Unable to replay the write-ahead log: %s", err.Error())
+		} else if n > 0 {
+			logging.GetLogger().Infof("%d flows replayed from the write-ahead log", n)
+		}
+
+		select {
+		case <-w.quit:
+			return
+		case <-time.After(w.RetryInterval):
+		}
+	}
+}
+
+// Start replays the pending entries, retrying every RetryInterval
+func (w *WAL) Start() {
+	w.wg.Add(1)
+	go w.retry()
+}
+
+// Stop stops the replay, the pending entries being kept on disk
+func (w *WAL) Stop() {
+	close(w.quit)
+	w.wg.Wait()
+}
+
+// Status returns the state of the write-ahead log
+func (w *WAL) Status() WALStatus {
+	w.Lock()
+	defer w.Unlock()
+
+	return WALStatus{
+		Entries:  len(w.entries),
+		Size:     w.size,
+		Pending:  len(w.pending),
+		Replayed: w.replayed,
+		Bypassed: w.bypassed,
+	}
+}
+
+// NewWAL returns the write-ahead log of the flows stored in s, its entries
+// being loaded from dir
+func NewWAL(s Storage, dir string, maxSize int64, maxEntries int, retry time.Duration) (*WAL, error) {
+	w := &WAL{
+		Dir:           dir,
+		MaxSize:       maxSize,
+		MaxEntries:    maxEntries,
+		RetryInterval: retry,
+		storage:       s,
+		entries:       make(map[string]int64),
+		pending:       make(map[string]bool),
+		quit:          make(chan bool),
+	}
+	if err := w.Open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// NewWALFromConfig returns the write-ahead log configured by storage.wal,
+// nil if storage.wal.dir is not set
+func NewWALFromConfig(s Storage) (*WAL, error) {
+	cfg := config.GetConfig()
+
+	dir := cfg.GetString("storage.wal.dir")
+	if dir == "" {
+		return nil, nil
+	}
+
+	return NewWAL(s, dir,
+		int64(cfg.GetInt("storage.wal.max_size"))*1024*1024,
+		cfg.GetInt("storage.wal.max_entries"),
+		time.Duration(cfg.GetInt("storage.wal.retry_interval"))*time.Second)
+}
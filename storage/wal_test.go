@@ -0,0 +1,242 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redhat-cip/skydive/flow"
+)
+
+// ackingStorage upserts the flows by UUID, acknowledging them unless it
+// fails or blocks
+type ackingStorage struct {
+	recordingStorage
+	sync.Mutex
+	flows   map[string]*flow.Flow
+	unacked int
+	fail    error
+	block   chan bool
+}
+
+func newAckingStorage() *ackingStorage {
+	return &ackingStorage{flows: make(map[string]*flow.Flow)}
+}
+
+func (s *ackingStorage) StoreFlows(flows []*flow.Flow) error {
+	s.Lock()
+	s.unacked += len(flows)
+	s.Unlock()
+	return nil
+}
+
+func (s *ackingStorage) StoreFlowsAcked(flows []*flow.Flow) error {
+	if s.block != nil {
+		<-s.block
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	if s.fail != nil {
+		return s.fail
+	}
+	for _, f := range flows {
+		s.flows[f.UUID] = f
+	}
+	return nil
+}
+
+func walTestFlows(prefix string, n int) []*flow.Flow {
+	flows := make([]*flow.Flow, n)
+	for i := range flows {
+		flows[i] = &flow.Flow{
+			UUID:       fmt.Sprintf("%s-%d", prefix, i),
+			LayersPath: "Ethernet/IPv4/TCP",
+			Statistics: &flow.FlowStatistics{Start: 1000, Last: int64(1000 + i)},
+		}
+	}
+	return flows
+}
+
+func walDir(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "skydive-wal")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+func walEntries(t *testing.T, dir string) []string {
+	entries, err := filepath.Glob(filepath.Join(dir, "*"+walSuffix))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return entries
+}
+
+func TestWALReplayAfterCrash(t *testing.T) {
+	dir, cleanup := walDir(t)
+	defer cleanup()
+
+	// the analyzer crashes once the flows are logged, before the storage
+	// acknowledges them
+	crashed := newAckingStorage()
+	crashed.block = make(chan bool)
+	defer close(crashed.block)
+
+	w, err := NewWAL(crashed, dir, 0, 0, time.Second)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	go w.StoreFlows(walTestFlows("first", 3))
+	go w.StoreFlows(walTestFlows("second", 2))
+
+	for i := 0; len(walEntries(t, dir)) != 2; i++ {
+		if i == 500 {
+			t.Fatalf("Expected 2 entries in the write-ahead log, got %v", walEntries(t, dir))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// the restarted analyzer replays the entries
+	st := newAckingStorage()
+	if w, err = NewWAL(st, dir, 0, 0, time.Second); err != nil {
+		t.Fatal(err.Error())
+	}
+	if status := w.Status(); status.Pending != 2 {
+		t.Errorf("Expected 2 pending entries, got %+v", status)
+	}
+
+	n, err := w.Replay()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if n != 5 || len(st.flows) != 5 {
+		t.Errorf("Expected the 5 flows to be replayed, got %d stored %d", n, len(st.flows))
+	}
+	for _, expected := range append(walTestFlows("first", 3), walTestFlows("second", 2)...) {
+		f, ok := st.flows[expected.UUID]
+		if !ok || f.Statistics.Last != expected.Statistics.Last || f.LayersPath != expected.LayersPath {
+			t.Errorf("Flow %s not recovered: %v", expected.UUID, f)
+		}
+	}
+
+	if entries := walEntries(t, dir); len(entries) != 0 {
+		t.Errorf("Expected the replayed entries to be removed, got %v", entries)
+	}
+	if status := w.Status(); status.Entries != 0 || status.Pending != 0 || status.Size != 0 || status.Replayed != 5 {
+		t.Errorf("Wrong status after the replay: %+v", status)
+	}
+}
+
+func TestWALStorageFailure(t *testing.T) {
+	dir, cleanup := walDir(t)
+	defer cleanup()
+
+	st := newAckingStorage()
+	st.fail = errors.New("connection refused")
+
+	w, err := NewWAL(st, dir, 0, 0, time.Second)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if err := w.StoreFlows(walTestFlows("flow", 2)); err == nil {
+		t.Error("Expected the storage failure to be reported")
+	}
+	if n, err := w.Replay(); err == nil || n != 0 {
+		t.Errorf("Expected the replay to fail, got %d flows replayed", n)
+	}
+	if status := w.Status(); status.Pending != 1 || len(walEntries(t, dir)) != 1 {
+		t.Errorf("Expected the entry to be kept, got %+v", status)
+	}
+
+	st.fail = nil
+	if n, err := w.Replay(); err != nil || n != 2 {
+		t.Errorf("Expected the 2 flows to be replayed, got %d: %v", n, err)
+	}
+
+	// stored again by the replay, the flows aren't duplicated
+	if err := w.StoreFlows(walTestFlows("flow", 2)); err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(st.flows) != 2 || len(walEntries(t, dir)) != 0 {
+		t.Errorf("Expected 2 flows stored and no entry left, got %d flows, entries %v", len(st.flows), walEntries(t, dir))
+	}
+}
+
+func TestWALFull(t *testing.T) {
+	dir, cleanup := walDir(t)
+	defer cleanup()
+
+	st := newAckingStorage()
+	w, err := NewWAL(st, dir, 16, 0, time.Second)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// the batch exceeding the size bound is stored without being logged
+	if err := w.StoreFlows(walTestFlows("flow", 3)); err != nil {
+		t.Fatal(err.Error())
+	}
+	if st.unacked != 3 || len(st.flows) != 0 {
+		t.Errorf("Expected the flows to be stored without acknowledgement, got %d", st.unacked)
+	}
+	if status := w.Status(); status.Bypassed != 1 || status.Entries != 0 {
+		t.Errorf("Wrong status: %+v", status)
+	}
+}
+
+func TestWALOpen(t *testing.T) {
+	dir, cleanup := walDir(t)
+	defer cleanup()
+
+	// a partially written entry and a corrupted one
+	ioutil.WriteFile(filepath.Join(dir, ".0000000000000001-1.wal123"), []byte{0, 0}, 0600)
+	ioutil.WriteFile(filepath.Join(dir, "0000000000000002-1.wal"), []byte{0, 0, 0, 10, 1}, 0600)
+
+	st := newAckingStorage()
+	w, err := NewWAL(st, dir, 0, 0, time.Second)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if files, _ := ioutil.ReadDir(dir); len(files) != 1 {
+		t.Errorf("Expected the partial entry to be removed, got %d files", len(files))
+	}
+
+	if n, err := w.Replay(); err != nil || n != 0 {
+		t.Errorf("Expected the corrupted entry to be dropped, got %d: %v", n, err)
+	}
+	if entries := walEntries(t, dir); len(entries) != 0 {
+		t.Errorf("Expected no entry left, got %v", entries)
+	}
+}
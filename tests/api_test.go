@@ -27,6 +27,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"os"
+	"reflect"
 	"testing"
 	"time"
 
@@ -126,7 +127,7 @@ func (c *testAPIClient) create(kind string, resource interface{}) error {
 }
 
 func (s *testAPIServer) GetClient() (*testAPIClient, error) {
-	authenticationOpts := shttp.AuthenticationOpts{"admin", "password"}
+	authenticationOpts := shttp.AuthenticationOpts{Username: "admin", Password: "password"}
 	client := shttp.NewCrudClient(s.analyzer.HTTPServer.Addr, s.analyzer.HTTPServer.Port, &authenticationOpts, "api")
 	if client == nil {
 		return nil, errors.New("Failed to create client")
@@ -162,6 +163,8 @@ func TestAlertApi(t *testing.T) {
 	}
 
 	alert := api.NewAlert()
+	alert.Select = "Name"
+	alert.Test = "Name == \"eth0\""
 	if err := apiClient.create("alert", alert); err != nil {
 		t.Fatalf("Failed to create alert: %s", err.Error())
 	}
@@ -224,7 +227,7 @@ func TestCaptureApi(t *testing.T) {
 		t.Error(err)
 	}
 
-	if *capture != *capture2 {
+	if !reflect.DeepEqual(capture, capture2) {
 		t.Errorf("Capture corrupted: %+v != %+v", capture, capture2)
 	}
 
@@ -237,7 +240,7 @@ func TestCaptureApi(t *testing.T) {
 		}
 	}
 
-	if captures[capture.ProbePath] != *capture {
+	if c := captures[capture.ProbePath]; !reflect.DeepEqual(&c, capture) {
 		t.Errorf("Capture corrupted: %+v != %+v", captures[capture.ProbePath], capture)
 	}
 
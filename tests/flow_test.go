@@ -23,6 +23,8 @@
 package tests
 
 import (
+	"fmt"
+	"io/ioutil"
 	"path/filepath"
 	"sync"
 	"testing"
@@ -31,6 +33,7 @@ import (
 	"github.com/redhat-cip/skydive/api"
 	cmd "github.com/redhat-cip/skydive/cmd/client"
 	"github.com/redhat-cip/skydive/flow"
+	fprobes "github.com/redhat-cip/skydive/flow/probes"
 	"github.com/redhat-cip/skydive/http"
 	"github.com/redhat-cip/skydive/storage"
 	"github.com/redhat-cip/skydive/tests/helper"
@@ -136,6 +139,10 @@ func (s *TestStorage) SearchFlows(filters storage.Filters) ([]*flow.Flow, error)
 	return nil, nil
 }
 
+func (s *TestStorage) CountFlows(filters storage.Filters) (int, error) {
+	return 0, nil
+}
+
 func (s *TestStorage) GetFlows() []*flow.Flow {
 	s.lock.Lock()
 	defer s.lock.Unlock()
@@ -493,6 +500,90 @@ func TestPCAPProbe(t *testing.T) {
 	client.Delete("capture", "*/br-pcap[Type=bridge]")
 }
 
+const (
+	churnInterfaces = 40
+	churnBatch      = 10
+	churnRatio      = 0.9
+)
+
+func openFileDescriptors(t *testing.T) int {
+	fds, err := ioutil.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return len(fds)
+}
+
+func TestPCAPProbeChurn(t *testing.T) {
+	ts := NewTestStorage()
+
+	aa := helper.NewAgentAnalyzerWithConfig(t, confAgentAnalyzer, ts)
+	aa.Start()
+	defer aa.Stop()
+
+	client := api.NewCrudClientFromConfig(&http.AuthenticationOpts{})
+	for i := 0; i < churnInterfaces; i++ {
+		capture := &api.Capture{ProbePath: fmt.Sprintf("*/churn-%d[Type=veth]", i)}
+		if err := client.Create("capture", &capture); err != nil {
+			t.Fatal(err.Error())
+		}
+		defer client.Delete("capture", capture.ProbePath)
+	}
+	time.Sleep(1 * time.Second)
+
+	baseFds := openFileDescriptors(t)
+
+	for b := 0; b < churnInterfaces; b += churnBatch {
+		var wg sync.WaitGroup
+		for i := b; i < b+churnBatch; i++ {
+			helper.ExecCmds(t,
+				helper.Cmd{fmt.Sprintf("ip netns add churn-%d", i), true},
+				helper.Cmd{fmt.Sprintf("ip link add churn-%d type veth peer name churn-%d-p", i, i), true},
+				helper.Cmd{fmt.Sprintf("ip link set churn-%d-p netns churn-%d", i, i), true},
+				helper.Cmd{fmt.Sprintf("ip netns exec churn-%d ip address add 169.254.91.2/24 dev churn-%d-p", i, i), true},
+				helper.Cmd{fmt.Sprintf("ip netns exec churn-%d ip link set churn-%d-p up", i, i), true},
+				helper.Cmd{fmt.Sprintf("ip link set churn-%d up", i), true},
+			)
+
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				helper.ExecCmds(t, helper.Cmd{fmt.Sprintf("ip netns exec churn-%d ping -c 5 -i 0.2 169.254.91.1", i), false})
+			}(i)
+		}
+		wg.Wait()
+
+		aa.Flush()
+
+		for i := b; i < b+churnBatch; i++ {
+			helper.ExecCmds(t,
+				helper.Cmd{fmt.Sprintf("ip link del churn-%d", i), false},
+				helper.Cmd{fmt.Sprintf("ip netns del churn-%d", i), true},
+			)
+		}
+	}
+
+	probes := make(map[string]bool)
+	for _, f := range ts.GetFlows() {
+		probes[f.ProbeNodeUUID] = true
+	}
+	if len(probes) < int(churnRatio*churnInterfaces) {
+		t.Errorf("Flows captured on %d interfaces, expected at least %d", len(probes), int(churnRatio*churnInterfaces))
+	}
+
+	handler := aa.Agent.FlowProbeBundle.GetProbe("pcap").(*fprobes.PcapProbesHandler)
+	for i := 0; handler.ActiveProbes() != 0 && i < 10; i++ {
+		time.Sleep(500 * time.Millisecond)
+	}
+	if n := handler.ActiveProbes(); n != 0 {
+		t.Errorf("%d captures still running after interface deletion", n)
+	}
+
+	if fds := openFileDescriptors(t); fds > baseFds+5 {
+		t.Errorf("File descriptors leaked: %d opened, %d before the churn", fds, baseFds)
+	}
+}
+
 func TestSFlowSrcDstPath(t *testing.T) {
 	ts := NewTestStorage()
 
@@ -158,7 +158,9 @@ func NewAgentAnalyzerWithConfig(t *testing.T, conf string, s storage.Storage, pa
 }
 
 func (h *HelperAgentAnalyzer) startAnalyzer() {
-	h.Analyzer.ListenAndServe()
+	if err := h.Analyzer.Start(); err != nil {
+		h.t.Fatal(err)
+	}
 	WaitApi(h.t, h.Analyzer)
 }
 
@@ -223,7 +225,9 @@ func WaitApi(t *testing.T, analyzer *analyzer.Server) {
 func StartAnalyzerWithConfig(t *testing.T, conf string, s storage.Storage, params ...HelperParams) *analyzer.Server {
 	InitConfig(t, conf, params...)
 	analyzer := NewAnalyzerStorage(t, s)
-	analyzer.ListenAndServe()
+	if err := analyzer.Start(); err != nil {
+		t.Fatal(err)
+	}
 	WaitApi(t, analyzer)
 	return analyzer
 }
@@ -0,0 +1,238 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package alert
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/redhat-cip/skydive/api"
+	"github.com/redhat-cip/skydive/flow"
+	"github.com/redhat-cip/skydive/logging"
+	"github.com/redhat-cip/skydive/metrics"
+	"github.com/redhat-cip/skydive/storage"
+)
+
+// AbsenceReason is the ReasonData of the absence alert messages, Firing is
+// false once a matching flow shows up again.
+type AbsenceReason struct {
+	Firing     bool
+	FlowFilter string
+	Window     int
+	LastMatch  time.Time
+}
+
+// absenceRule tracks the last time a flow matched the filter of an absence
+// alert. Times are durations since the manager epoch so that they are based
+// on the monotonic clock and not affected by wall clock adjustments.
+type absenceRule struct {
+	alert  *api.Alert
+	filter flow.Filter
+	window time.Duration
+	// registration time, a rule never fires before a full window elapsed
+	registered int64
+	lastMatch  int64
+	firing     int32
+	// whether the last match has to be retrieved from the storage
+	seed int32
+}
+
+func (a *AlertManager) newAbsenceRule(al *api.Alert, seed bool) (*absenceRule, error) {
+	filter, err := flow.ParseFilter(al.FlowFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	now := int64(a.now())
+	r := &absenceRule{
+		alert:      al,
+		filter:     filter,
+		window:     time.Duration(al.Window) * time.Second,
+		registered: now,
+		lastMatch:  now,
+	}
+	if seed {
+		r.seed = 1
+	}
+	return r, nil
+}
+
+// setAbsence registers an absence alert, the state of the rule is kept if
+// the alert is updated without changing its condition.
+func (a *AlertManager) setAbsence(al *api.Alert, seed bool) {
+	if r, ok := a.absences[al.UUID]; ok && r.alert.FlowFilter == al.FlowFilter && r.alert.Window == al.Window {
+		r.alert = al
+		return
+	}
+
+	r, err := a.newAbsenceRule(al, seed)
+	if err != nil {
+		logging.GetLogger().Errorf("Invalid absence alert %s: %s", al.UUID, err.Error())
+		return
+	}
+	a.absences[al.UUID] = r
+}
+
+func (a *AlertManager) notifyAbsence(r *absenceRule, firing bool) {
+	now := time.Now()
+	lastMatch := a.wallTime(time.Duration(atomic.LoadInt64(&r.lastMatch)))
+
+	msg := AlertMessage{
+		UUID:      r.alert.UUID,
+		Type:      ABSENCE,
+		Timestamp: now,
+		Count:     r.alert.Count,
+		Reason:    r.alert.Action,
+		ReasonData: &AbsenceReason{
+			Firing:     firing,
+			FlowFilter: r.alert.FlowFilter,
+			Window:     r.alert.Window,
+			LastMatch:  lastMatch,
+		},
+	}
+	if firing {
+		// the window is measured on the clock of the manager
+		a.fires.recordAbsence(r.alert, lastMatch, a.wallTime(a.now()))
+		msg.Fire = api.AlertFireID(r.alert.UUID, r.alert.Count)
+	}
+
+	logging.GetLogger().Debugf("AlertMessage to WS : " + r.alert.UUID + " " + msg.String())
+	for _, l := range a.eventListeners {
+		l.OnAlert(&msg)
+	}
+}
+
+// EvalFlows records the last match of the absence alerts, called on the
+// ingestion path so it has to stay cheap.
+func (a *AlertManager) EvalFlows(flows []*flow.Flow) {
+	now := int64(a.now())
+
+	a.alertsLock.RLock()
+	defer a.alertsLock.RUnlock()
+
+	for _, r := range a.absences {
+		for _, f := range flows {
+			if r.filter.Match(f) {
+				atomic.StoreInt64(&r.lastMatch, now)
+				if atomic.CompareAndSwapInt32(&r.firing, 1, 0) {
+					a.notifyAbsence(r, false)
+				}
+				break
+			}
+		}
+	}
+}
+
+// MatchFlow returns whether the flow matches the flow filter of an absence
+// alert
+func (a *AlertManager) MatchFlow(f *flow.Flow) bool {
+	a.alertsLock.RLock()
+	defer a.alertsLock.RUnlock()
+
+	for _, r := range a.absences {
+		if r.filter.Match(f) {
+			return true
+		}
+	}
+	return false
+}
+
+// seedAbsence retrieves the last match from the storage, using a bounded
+// query on the most recent flows.
+func (a *AlertManager) seedAbsence(r *absenceRule) {
+	flows, err := a.storage.SearchFlows(storage.Filters(r.filter.FieldsOnly()))
+	if err != nil {
+		// storage not ready yet, retried at the next evaluation
+		logging.GetLogger().Debugf("Unable to retrieve the last match of the alert %s: %s", r.alert.UUID, err.Error())
+		return
+	}
+	atomic.StoreInt32(&r.seed, 0)
+
+	for _, f := range flows {
+		if fs := f.GetStatistics(); fs != nil && r.filter.Match(f) {
+			// agents clock may be ahead
+			age := time.Now().Sub(time.Unix(fs.Last, 0))
+			if age < 0 {
+				age = 0
+			}
+
+			// only if no flow has been seen in the meantime
+			atomic.CompareAndSwapInt64(&r.lastMatch, r.registered, int64(a.now()-age))
+			return
+		}
+	}
+}
+
+func (a *AlertManager) EvalAbsences() {
+	a.alertsLock.RLock()
+	var seeds []*absenceRule
+	for _, r := range a.absences {
+		if atomic.LoadInt32(&r.seed) == 1 {
+			seeds = append(seeds, r)
+		}
+	}
+	a.alertsLock.RUnlock()
+
+	if a.storage != nil {
+		for _, r := range seeds {
+			a.seedAbsence(r)
+		}
+	}
+
+	now := a.now()
+
+	a.alertsLock.RLock()
+	defer a.alertsLock.RUnlock()
+
+	metrics.AlertEvaluations.Add(float64(len(a.absences)))
+	for _, r := range a.absences {
+		if now-time.Duration(atomic.LoadInt64(&r.lastMatch)) < r.window {
+			continue
+		}
+
+		if atomic.CompareAndSwapInt32(&r.firing, 0, 1) {
+			r.alert.Count++
+			a.notifyAbsence(r, true)
+		}
+	}
+}
+
+func (a *AlertManager) wallTime(d time.Duration) time.Time {
+	return a.epoch.Add(d)
+}
+
+func (a *AlertManager) evalAbsencesLoop(interval time.Duration, quit chan bool) {
+	defer a.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			a.EvalAbsences()
+		case <-quit:
+			return
+		}
+	}
+}
@@ -0,0 +1,198 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package alert
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/redhat-cip/skydive/api"
+	"github.com/redhat-cip/skydive/flow"
+	"github.com/redhat-cip/skydive/storage"
+)
+
+type testAlertListener struct {
+	messages []*AlertMessage
+}
+
+func (l *testAlertListener) OnAlert(msg *AlertMessage) {
+	l.messages = append(l.messages, msg)
+}
+
+type testFlowStorage struct {
+	flows []*flow.Flow
+	err   error
+}
+
+func (s *testFlowStorage) Start() {
+}
+
+func (s *testFlowStorage) Stop() {
+}
+
+func (s *testFlowStorage) StoreFlows(flows []*flow.Flow) error {
+	return nil
+}
+
+func (s *testFlowStorage) SearchFlows(filters storage.Filters) ([]*flow.Flow, error) {
+	return s.flows, s.err
+}
+
+func (s *testFlowStorage) CountFlows(filters storage.Filters) (int, error) {
+	return len(s.flows), s.err
+}
+
+// newTestAbsenceManager returns a manager with a clock set by hand
+func newTestAbsenceManager() (*AlertManager, *time.Duration, *testAlertListener) {
+	am := NewAlertManager(nil, nil)
+
+	clock := new(time.Duration)
+	am.now = func() time.Duration {
+		return *clock
+	}
+
+	listener := &testAlertListener{}
+	am.AddEventListener(listener)
+
+	return am, clock, listener
+}
+
+func newTestAbsenceAlert() *api.Alert {
+	alert := api.NewAlert()
+	alert.Type = api.ABSENCE
+	alert.FlowFilter = "IPV4.A=192.168.0.1,IPV4.B=192.168.0.2"
+	alert.Window = 60
+	return alert
+}
+
+func newTestAbsenceFlow(a, b string, last int64) *flow.Flow {
+	return &flow.Flow{
+		LayersPath: "Ethernet/IPv4/TCP",
+		Statistics: &flow.FlowStatistics{
+			Last: last,
+			Endpoints: []*flow.FlowEndpointsStatistics{
+				{
+					Type: flow.FlowEndpointType_IPV4,
+					AB:   &flow.FlowEndpointStatistics{Value: a},
+					BA:   &flow.FlowEndpointStatistics{Value: b},
+				},
+			},
+		},
+	}
+}
+
+func lastAbsenceReason(t *testing.T, l *testAlertListener) *AbsenceReason {
+	if len(l.messages) == 0 {
+		t.Fatal("No alert message received")
+	}
+	return l.messages[len(l.messages)-1].ReasonData.(*AbsenceReason)
+}
+
+func TestAbsenceAlert(t *testing.T) {
+	am, clock, listener := newTestAbsenceManager()
+
+	*clock = 10 * time.Second
+	am.SetAlert(newTestAbsenceAlert())
+
+	// created mid-window, no fire before a full window
+	*clock = 60 * time.Second
+	am.EvalAbsences()
+	if len(listener.messages) != 0 {
+		t.Fatalf("Alert fired during the grace period: %+v", listener.messages)
+	}
+
+	*clock = 71 * time.Second
+	am.EvalAbsences()
+	if len(listener.messages) != 1 || !lastAbsenceReason(t, listener).Firing {
+		t.Fatalf("Alert should have fired: %+v", listener.messages)
+	}
+
+	// still firing, no new message
+	*clock = 80 * time.Second
+	am.EvalAbsences()
+	if len(listener.messages) != 1 {
+		t.Fatalf("Alert should fire only once: %+v", listener.messages)
+	}
+
+	// flows not matching don't clear the alert
+	am.EvalFlows([]*flow.Flow{newTestAbsenceFlow("192.168.0.1", "192.168.0.3", 0)})
+	if len(listener.messages) != 1 {
+		t.Fatalf("Alert cleared by a not matching flow: %+v", listener.messages)
+	}
+
+	*clock = 90 * time.Second
+	am.EvalFlows([]*flow.Flow{newTestAbsenceFlow("192.168.0.1", "192.168.0.2", 0)})
+	if len(listener.messages) != 2 || lastAbsenceReason(t, listener).Firing {
+		t.Fatalf("Alert should have been cleared: %+v", listener.messages)
+	}
+
+	*clock = 149 * time.Second
+	am.EvalAbsences()
+	if len(listener.messages) != 2 {
+		t.Fatalf("Alert fired before the end of the window: %+v", listener.messages)
+	}
+
+	*clock = 151 * time.Second
+	am.EvalAbsences()
+	if len(listener.messages) != 3 || !lastAbsenceReason(t, listener).Firing {
+		t.Fatalf("Alert should have fired again: %+v", listener.messages)
+	}
+}
+
+func TestAbsenceAlertSeed(t *testing.T) {
+	am, clock, listener := newTestAbsenceManager()
+
+	// last matching flow seen 50 seconds ago before the restart
+	last := time.Now().Add(-50 * time.Second).Unix()
+	s := &testFlowStorage{err: errors.New("not started")}
+	am.SetStorage(s)
+
+	*clock = time.Hour
+	am.onApiWatcherEvent("init", "", newTestAbsenceAlert())
+
+	// storage not ready, the rule keeps its grace period
+	*clock = time.Hour + 30*time.Second
+	am.EvalAbsences()
+	if len(listener.messages) != 0 {
+		t.Fatalf("Unexpected alert: %+v", listener.messages)
+	}
+
+	s.flows = []*flow.Flow{
+		newTestAbsenceFlow("192.168.0.1", "192.168.0.3", last),
+		newTestAbsenceFlow("192.168.0.1", "192.168.0.2", last),
+	}
+	s.err = nil
+
+	// seeded, 50+ seconds elapsed since the last match, not yet 60
+	am.EvalAbsences()
+	if len(listener.messages) != 0 {
+		t.Fatalf("Unexpected alert: %+v", listener.messages)
+	}
+
+	*clock = time.Hour + 45*time.Second
+	am.EvalAbsences()
+	if len(listener.messages) != 1 || !lastAbsenceReason(t, listener).Firing {
+		t.Fatalf("Alert should have fired using the seeded last match: %+v", listener.messages)
+	}
+}
@@ -0,0 +1,193 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package alert
+
+import (
+	"go/ast"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redhat-cip/skydive/api"
+	"github.com/redhat-cip/skydive/config"
+	"github.com/redhat-cip/skydive/flow"
+	"github.com/redhat-cip/skydive/topology/graph"
+)
+
+// fireRecorder keeps the last fires of each alert with the references of
+// what triggered them
+type fireRecorder struct {
+	sync.RWMutex
+	fires     map[string][]*api.AlertFire
+	flowTable *flow.Table
+	maxFires  int
+	maxFlows  int
+	window    time.Duration
+}
+
+type sortRefsByUUID []api.AlertFlowRef
+
+func (s sortRefsByUUID) Len() int {
+	return len(s)
+}
+
+func (s sortRefsByUUID) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s sortRefsByUUID) Less(i, j int) bool {
+	return s[i].UUID < s[j].UUID
+}
+
+// setFlowRefs references the flows of the table matching the query of the
+// fire, up to maxFlows of them in the UUID order
+func (r *fireRecorder) setFlowRefs(fire *api.AlertFire, probe string) {
+	if r.flowTable == nil {
+		return
+	}
+
+	filter, err := flow.ParseFilter(fire.Query.FlowFilter)
+	if err != nil {
+		return
+	}
+
+	var flows []*flow.Flow
+	if probe != "" {
+		flows = r.flowTable.GetFlows(flow.FlowQueryFilter{ProbeNodeUUID: probe})
+	} else {
+		flows = r.flowTable.GetFlows()
+	}
+
+	var refs []api.AlertFlowRef
+	for _, f := range flows {
+		fs := f.GetStatistics()
+		if fs == nil || fs.Last < fire.Query.From || fs.Start > fire.Query.To || !filter.Match(f) {
+			continue
+		}
+		refs = append(refs, api.AlertFlowRef{UUID: f.UUID, TrackingID: f.TrackingID})
+	}
+	sort.Sort(sortRefsByUUID(refs))
+
+	if r.maxFlows > 0 && len(refs) > r.maxFlows {
+		fire.MoreFlows = len(refs) - r.maxFlows
+		refs = refs[:r.maxFlows]
+	}
+	fire.Flows = refs
+}
+
+func (r *fireRecorder) record(fire *api.AlertFire) {
+	r.Lock()
+	defer r.Unlock()
+
+	fires := append(r.fires[fire.Alert], fire)
+	if r.maxFires > 0 && len(fires) > r.maxFires {
+		fires = fires[len(fires)-r.maxFires:]
+	}
+	r.fires[fire.Alert] = fires
+}
+
+// recordNode records the fire of an alert on a node, the flows of the
+// window being the ones captured on the node
+func (r *fireRecorder) recordNode(al *api.Alert, expr ast.Expr, n *graph.Node, now time.Time) *api.AlertFire {
+	metadata := n.Metadata()
+
+	fire := &api.AlertFire{
+		Alert:     al.UUID,
+		N:         al.Count,
+		Timestamp: now,
+		Test:      al.Test,
+		Values:    make(map[string]interface{}),
+		Nodes:     []string{string(n.ID)},
+		Query: api.AlertFlowQuery{
+			FlowFilter: "ProbeNodeUUID=" + string(n.ID),
+			From:       now.Add(-r.window).Unix(),
+			To:         now.Unix(),
+		},
+	}
+
+	// the dotted metadata keys are given to the tests with underscores
+	idents := make(map[string]bool)
+	for _, ident := range api.ExpressionIdents(expr) {
+		idents[ident] = true
+	}
+	for k, v := range metadata {
+		if name := strings.Replace(k, ".", "_", -1); idents[name] {
+			fire.Values[name] = v
+		}
+	}
+
+	r.setFlowRefs(fire, string(n.ID))
+	r.record(fire)
+
+	return fire
+}
+
+// recordAbsence records the fire of an absence alert, the window going
+// from the last match, no flow matching by definition
+func (r *fireRecorder) recordAbsence(al *api.Alert, lastMatch time.Time, now time.Time) *api.AlertFire {
+	fire := &api.AlertFire{
+		Alert:     al.UUID,
+		N:         al.Count,
+		Timestamp: now,
+		Query: api.AlertFlowQuery{
+			FlowFilter: al.FlowFilter,
+			From:       lastMatch.Unix(),
+			To:         now.Unix(),
+		},
+	}
+	r.record(fire)
+
+	return fire
+}
+
+func (r *fireRecorder) forget(id string) {
+	r.Lock()
+	delete(r.fires, id)
+	r.Unlock()
+}
+
+// GetAlertFire returns the nth fire of an alert, nil if unknown or no more
+// kept
+func (r *fireRecorder) GetAlertFire(id string, n int) *api.AlertFire {
+	r.RLock()
+	defer r.RUnlock()
+
+	for _, fire := range r.fires[id] {
+		if fire.N == n {
+			return fire
+		}
+	}
+	return nil
+}
+
+func newFireRecorderFromConfig() *fireRecorder {
+	cfg := config.GetConfig()
+
+	return &fireRecorder{
+		fires:    make(map[string][]*api.AlertFire),
+		maxFires: cfg.GetInt("analyzer.alert_context.max_fires"),
+		maxFlows: cfg.GetInt("analyzer.alert_context.max_flows"),
+		window:   time.Duration(cfg.GetInt("analyzer.alert_context.window")) * time.Second,
+	}
+}
@@ -0,0 +1,170 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package alert
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redhat-cip/skydive/api"
+	"github.com/redhat-cip/skydive/flow"
+	"github.com/redhat-cip/skydive/topology/graph"
+)
+
+func newTestContextFlow(i int, probe string, last int64) *flow.Flow {
+	return &flow.Flow{
+		UUID:          fmt.Sprintf("flow-%d", i),
+		TrackingID:    fmt.Sprintf("tracking-%d", i),
+		LayersPath:    "Ethernet/IPv4/TCP",
+		ProbeNodeUUID: probe,
+		Statistics:    &flow.FlowStatistics{Start: last - 10, Last: last},
+	}
+}
+
+func TestAlertFireContext(t *testing.T) {
+	b, err := graph.NewMemoryBackend()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	g, err := graph.NewGraph(b)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	g.Lock()
+	n := g.NewNode(graph.GenID(), graph.Metadata{"Name": "eth0", "MTU": 1500, "Type": "veth"})
+	g.Unlock()
+
+	// five flows of the node in the window, one of another node and one of
+	// the node but older than the window
+	now := time.Now().Unix()
+	var flows []*flow.Flow
+	for i := 0; i < 5; i++ {
+		flows = append(flows, newTestContextFlow(i, string(n.ID), now))
+	}
+	flows = append(flows, newTestContextFlow(5, "other", now), newTestContextFlow(6, string(n.ID), now-3600))
+
+	table := flow.NewTableFromFlows(flows)
+
+	am := NewAlertManager(g, nil)
+	am.SetFlowTable(table)
+	am.fires.maxFlows = 3
+	listener := &testAlertListener{}
+	am.AddEventListener(listener)
+
+	alert := newTestAlert("Name", `MTU > 1400 && Name == "eth0"`)
+	am.SetAlert(alert)
+	am.EvalNodes()
+
+	if len(listener.messages) != 1 {
+		t.Fatalf("Expected the alert to fire once, got %d messages", len(listener.messages))
+	}
+	id, number, err := api.ParseAlertFireID(listener.messages[0].Fire)
+	if err != nil || id != alert.UUID || number != 1 {
+		t.Fatalf("Wrong fire identifier %s: %v", listener.messages[0].Fire, err)
+	}
+
+	// the flows are then expired to the storage
+	ca := &api.AlertContextApi{
+		Fires:    am,
+		Graph:    g,
+		Storage:  &testFlowStorage{flows: flows},
+		MaxFlows: 3,
+	}
+
+	ctx, err := ca.Context(id, number)
+	if err != nil || ctx == nil {
+		t.Fatalf("Expected the context of the fire: %v", err)
+	}
+
+	if ctx.Expression != `1500 > 1400 && "eth0" == "eth0"` {
+		t.Errorf("Wrong rendered expression: %s", ctx.Expression)
+	}
+	if len(ctx.Fire.Values) != 2 || ctx.Fire.Values["MTU"] != 1500 {
+		t.Errorf("Wrong values of the fire: %v", ctx.Fire.Values)
+	}
+	if len(ctx.Nodes) != 1 || ctx.Nodes[0].ID != n.ID {
+		t.Errorf("Expected the node of the fire, got %v", ctx.Nodes)
+	}
+
+	// the first page gives the recorded flows, the others being counted
+	if len(ctx.Flows) != 3 || len(ctx.Fire.Flows) != 3 || ctx.MoreFlows != 2 || ctx.Fire.MoreFlows != 2 {
+		t.Fatalf("Expected 3 flows and 2 more, got %d flows and %d more, %d recorded and %d more",
+			len(ctx.Flows), ctx.MoreFlows, len(ctx.Fire.Flows), ctx.Fire.MoreFlows)
+	}
+	for i, f := range ctx.Flows {
+		ref := ctx.Fire.Flows[i]
+		if f.UUID != ref.UUID || f.TrackingID != ref.TrackingID || f.UUID != fmt.Sprintf("flow-%d", i) {
+			t.Errorf("Flow %s of the context doesn't match the recorded %v", f.UUID, ref)
+		}
+	}
+
+	// the continuation runs the stored query from the offset
+	if !strings.HasSuffix(ctx.Next, "/fires/1/flows?offset=3") {
+		t.Errorf("Wrong continuation: %s", ctx.Next)
+	}
+	page, err := ca.FlowPage(ctx.Fire, 3)
+	if err != nil || len(page.Flows) != 2 || page.MoreFlows != 0 || page.Flows[1].UUID != "flow-4" {
+		t.Errorf("Expected the last 2 flows of the window, got %v, %v", page, err)
+	}
+
+	// the node being removed afterwards, it is reported missing
+	g.Lock()
+	g.DelNode(n)
+	g.Unlock()
+	if ctx, _ = ca.Context(id, number); len(ctx.Nodes) != 0 || len(ctx.MissingNodes) != 1 {
+		t.Errorf("Expected the node to be missing, got %v, %v", ctx.Nodes, ctx.MissingNodes)
+	}
+
+	// the fires are forgotten along with the alert
+	am.DeleteAlert(alert.UUID)
+	if ctx, err = ca.Context(id, number); ctx != nil || err != nil {
+		t.Errorf("Expected no context once the alert deleted: %v, %v", ctx, err)
+	}
+}
+
+func TestAbsenceFireContext(t *testing.T) {
+	am, clock, listener := newTestAbsenceManager()
+
+	alert := newTestAbsenceAlert()
+	am.SetAlert(alert)
+
+	*clock = 90 * time.Second
+	am.EvalAbsences()
+
+	fire := am.GetAlertFire(alert.UUID, 1)
+	if fire == nil || listener.messages[0].Fire != api.AlertFireID(alert.UUID, 1) {
+		t.Fatalf("Expected the absence fire to be recorded: %v", listener.messages)
+	}
+	if fire.Query.FlowFilter != alert.FlowFilter || fire.Query.To-fire.Query.From < 89 {
+		t.Errorf("Expected the query of the filter over the window: %v", fire.Query)
+	}
+
+	ca := &api.AlertContextApi{Fires: am, Storage: &testFlowStorage{}}
+	ctx, err := ca.Context(alert.UUID, 1)
+	if err != nil || !strings.HasPrefix(ctx.Expression, "no flow matching "+alert.FlowFilter) || len(ctx.Flows) != 0 {
+		t.Errorf("Wrong context of the absence fire: %v, %v", ctx, err)
+	}
+}
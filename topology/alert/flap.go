@@ -0,0 +1,247 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package alert
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redhat-cip/skydive/config"
+	"github.com/redhat-cip/skydive/logging"
+	"github.com/redhat-cip/skydive/topology/graph"
+)
+
+type flapTransition struct {
+	state string
+	time  time.Time
+}
+
+type flapState struct {
+	state       string
+	transitions []time.Time
+	history     []flapTransition
+	flapping    bool
+}
+
+// FlapDetector marks with the Flapping metadata the nodes whose State
+// changed at least threshold times during the window, until it changed at
+// most clearThreshold times. The last transitions are kept in the
+// FlapHistory metadata, "DOWN@1476604800 UP@1476604802", the most recent
+// last, and their number during the window in FlapScore.
+type FlapDetector struct {
+	graph.DefaultGraphListener
+	Graph          *graph.Graph
+	threshold      int
+	clearThreshold int
+	window         time.Duration
+	history        int
+	nodes          map[graph.Identifier]*flapState
+	onFlapping     func(n *graph.Node)
+	now            func() time.Time
+	quit           chan bool
+	wg             sync.WaitGroup
+}
+
+// prune forgets the transitions older than the window
+func (d *FlapDetector) prune(st *flapState, now time.Time) {
+	i := 0
+	for i < len(st.transitions) && now.Sub(st.transitions[i]) > d.window {
+		i++
+	}
+	st.transitions = st.transitions[i:]
+}
+
+func (st *flapState) historyString() string {
+	entries := make([]string, len(st.history))
+	for i, t := range st.history {
+		entries[i] = fmt.Sprintf("%s@%d", t.state, t.time.Unix())
+	}
+	return strings.Join(entries, " ")
+}
+
+// sync sets the flap metadata of the node if they differ from the state
+// of the detector. Must be called under graph lock
+func (d *FlapDetector) sync(n *graph.Node, st *flapState) {
+	if len(st.history) == 0 {
+		return
+	}
+
+	expected := map[string]interface{}{
+		"Flapping":    st.flapping,
+		"FlapScore":   len(st.transitions),
+		"FlapHistory": st.historyString(),
+	}
+
+	metadata := n.Metadata()
+	changed := false
+	for k, v := range expected {
+		// the backends may change the type of the numbers
+		if fmt.Sprint(metadata[k]) != fmt.Sprint(v) {
+			changed = true
+		}
+	}
+	if !changed {
+		return
+	}
+
+	m := make(graph.Metadata)
+	for k, v := range metadata {
+		m[k] = v
+	}
+	for k, v := range expected {
+		m[k] = v
+	}
+	d.Graph.SetMetadata(n, m)
+}
+
+func (d *FlapDetector) OnNodeAdded(n *graph.Node) {
+	d.OnNodeUpdated(n)
+}
+
+func (d *FlapDetector) OnNodeUpdated(n *graph.Node) {
+	state, ok := n.Metadata()["State"].(string)
+	if !ok {
+		return
+	}
+
+	st, ok := d.nodes[n.ID]
+	if !ok {
+		d.nodes[n.ID] = &flapState{state: state}
+		return
+	}
+
+	if state != st.state {
+		now := d.now()
+
+		st.state = state
+		st.transitions = append(st.transitions, now)
+		d.prune(st, now)
+
+		st.history = append(st.history, flapTransition{state: state, time: now})
+		if len(st.history) > d.history {
+			st.history = st.history[len(st.history)-d.history:]
+		}
+
+		if !st.flapping && len(st.transitions) >= d.threshold {
+			st.flapping = true
+			logging.GetLogger().Warningf("Node %s is flapping, %d state changes in %s", n.ID, len(st.transitions), d.window)
+
+			defer d.onFlapping(n)
+		}
+	}
+
+	d.sync(n, st)
+}
+
+func (d *FlapDetector) OnNodeDeleted(n *graph.Node) {
+	delete(d.nodes, n.ID)
+}
+
+// Check clears the flapping state of the nodes whose state stopped
+// changing
+func (d *FlapDetector) Check(now time.Time) {
+	d.Graph.Lock()
+	defer d.Graph.Unlock()
+
+	for id, st := range d.nodes {
+		if len(st.transitions) == 0 {
+			continue
+		}
+		d.prune(st, now)
+
+		if st.flapping && len(st.transitions) <= d.clearThreshold {
+			st.flapping = false
+			logging.GetLogger().Infof("Node %s stopped flapping", id)
+		}
+
+		if n := d.Graph.GetNode(id); n != nil {
+			d.sync(n, st)
+		}
+	}
+}
+
+func (d *FlapDetector) Start() {
+	d.Graph.AddEventListener(d)
+
+	interval := d.window / 4
+	if interval < time.Second {
+		interval = time.Second
+	}
+
+	d.quit = make(chan bool)
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-d.quit:
+				return
+			case <-ticker.C:
+				d.Check(d.now())
+			}
+		}
+	}()
+}
+
+func (d *FlapDetector) Stop() {
+	if d.quit != nil {
+		close(d.quit)
+		d.wg.Wait()
+		d.quit = nil
+	}
+	d.Graph.RemoveEventListener(d)
+}
+
+func NewFlapDetector(g *graph.Graph, threshold int, clearThreshold int, window time.Duration, history int, onFlapping func(n *graph.Node)) *FlapDetector {
+	return &FlapDetector{
+		Graph:          g,
+		threshold:      threshold,
+		clearThreshold: clearThreshold,
+		window:         window,
+		history:        history,
+		nodes:          make(map[graph.Identifier]*flapState),
+		onFlapping:     onFlapping,
+		now:            time.Now,
+	}
+}
+
+// NewFlapDetectorFromConfig returns the detector configured by
+// analyzer.flap_detection, nil if disabled
+func NewFlapDetectorFromConfig(g *graph.Graph, onFlapping func(n *graph.Node)) *FlapDetector {
+	threshold := config.GetConfig().GetInt("analyzer.flap_detection.threshold")
+	if threshold <= 0 || g == nil {
+		return nil
+	}
+
+	return NewFlapDetector(g, threshold,
+		config.GetConfig().GetInt("analyzer.flap_detection.clear_threshold"),
+		time.Duration(config.GetConfig().GetInt("analyzer.flap_detection.window"))*time.Second,
+		config.GetConfig().GetInt("analyzer.flap_detection.history"),
+		onFlapping)
+}
@@ -0,0 +1,152 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package alert
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redhat-cip/skydive/api"
+	"github.com/redhat-cip/skydive/topology/graph"
+)
+
+func newTestFlapManager(t *testing.T) (*AlertManager, *graph.Node, *time.Time, *testAlertListener) {
+	b, err := graph.NewMemoryBackend()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	g, err := graph.NewGraph(b)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	am := NewAlertManager(g, nil)
+
+	clock := new(time.Time)
+	*clock = time.Unix(1476604800, 0)
+	am.flaps = NewFlapDetector(g, 5, 1, time.Minute, 3, am.onFlapping)
+	am.flaps.now = func() time.Time {
+		return *clock
+	}
+
+	listener := &testAlertListener{}
+	am.AddEventListener(listener)
+
+	g.AddEventListener(am.flaps)
+	g.AddEventListener(am)
+
+	alert := api.NewAlert()
+	alert.Select = "State"
+	alert.Test = `State == "DOWN"`
+	am.SetAlert(alert)
+
+	g.Lock()
+	n := g.NewNode(graph.GenID(), graph.Metadata{"Name": "eth0", "State": "UP"})
+	g.Unlock()
+
+	return am, n, clock, listener
+}
+
+func countAlerts(l *testAlertListener, alertType int) int {
+	count := 0
+	for _, msg := range l.messages {
+		if msg.Type == alertType {
+			count++
+		}
+	}
+	return count
+}
+
+// flap changes the state of the node every 5 seconds
+func flap(am *AlertManager, n *graph.Node, clock *time.Time, states ...string) {
+	for _, state := range states {
+		*clock = clock.Add(5 * time.Second)
+
+		am.Graph.Lock()
+		am.Graph.AddMetadata(n, "State", state)
+		am.Graph.Unlock()
+	}
+}
+
+func TestFlapDetector(t *testing.T) {
+	am, n, clock, listener := newTestFlapManager(t)
+
+	flap(am, n, clock, "DOWN", "UP", "DOWN", "UP")
+	if n.Metadata()["Flapping"] != false || n.Metadata()["FlapScore"] != 4 {
+		t.Fatalf("Node shouldn't be flapping yet: %v", n.Metadata())
+	}
+	if countAlerts(listener, FIXED) == 0 {
+		t.Fatal("State alerts expected before flapping")
+	}
+
+	// the fifth change within the window, the alerts on the state of the
+	// node are replaced by a single flapping alert
+	listener.messages = nil
+	flap(am, n, clock, "DOWN", "UP", "DOWN")
+
+	if n.Metadata()["Flapping"] != true {
+		t.Fatalf("Node should be flapping: %v", n.Metadata())
+	}
+	if countAlerts(listener, FLAPPING) != 1 || countAlerts(listener, FIXED) != 0 {
+		t.Errorf("A single flapping alert expected: %d flapping, %d fixed", countAlerts(listener, FLAPPING), countAlerts(listener, FIXED))
+	}
+
+	history := n.Metadata()["FlapHistory"].(string)
+	if entries := strings.Split(history, " "); len(entries) != 3 || entries[2] != "DOWN@1476604835" {
+		t.Errorf("Wrong bounded flap history: %s", history)
+	}
+
+	// hysteresis, still flapping with fewer changes than the threshold
+	am.flaps.Check(clock.Add(45 * time.Second))
+	if n.Metadata()["Flapping"] != true || n.Metadata()["FlapScore"] != 4 {
+		t.Errorf("Node should still be flapping: %v", n.Metadata())
+	}
+
+	// cleared once the changes stopped, the state alerts being back
+	listener.messages = nil
+	am.flaps.Check(clock.Add(time.Minute))
+	if n.Metadata()["Flapping"] != false || n.Metadata()["FlapScore"] != 1 {
+		t.Errorf("Node should have stopped flapping: %v", n.Metadata())
+	}
+	if countAlerts(listener, FIXED) == 0 {
+		t.Error("State alerts expected after flapping")
+	}
+}
+
+func TestFlapDetector_slowChanges(t *testing.T) {
+	am, n, clock, listener := newTestFlapManager(t)
+
+	// changes spread over more than the window never make the node flap
+	for i := 0; i != 10; i++ {
+		*clock = clock.Add(20 * time.Second)
+		flap(am, n, clock, "DOWN")
+		*clock = clock.Add(20 * time.Second)
+		flap(am, n, clock, "UP")
+	}
+
+	if n.Metadata()["Flapping"] != false || countAlerts(listener, FLAPPING) != 0 {
+		t.Errorf("Node shouldn't be flapping: %v", n.Metadata())
+	}
+}
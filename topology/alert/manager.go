@@ -25,20 +25,34 @@ package alert
 import (
 	"encoding/json"
 	"fmt"
-	"go/token"
+	"go/ast"
+	"regexp"
 	"sync"
 	"time"
 
 	eval "github.com/sbinet/go-eval"
 
 	"github.com/redhat-cip/skydive/api"
+	"github.com/redhat-cip/skydive/config"
+	"github.com/redhat-cip/skydive/flow"
 	"github.com/redhat-cip/skydive/logging"
+	"github.com/redhat-cip/skydive/metrics"
+	"github.com/redhat-cip/skydive/storage"
 	"github.com/redhat-cip/skydive/topology/graph"
 )
 
 const (
 	FIXED = 1 + iota
 	THRESHOLD
+	ABSENCE
+	// sent once when a node starts flapping, in place of the alerts
+	// testing its state
+	FLAPPING
+	// sent once when an alert exceeds a limit of the sandbox
+	QUARANTINED
+	// sent once when a synthetic probe exceeds its loss or round trip time
+	// thresholds
+	PROBE
 )
 
 type AlertManager struct {
@@ -47,8 +61,20 @@ type AlertManager struct {
 	AlertHandler   api.ApiHandler
 	watcher        api.StoppableWatcher
 	alerts         map[string]*api.Alert
+	absences       map[string]*absenceRule
 	alertsLock     sync.RWMutex
 	eventListeners map[AlertEventListener]AlertEventListener
+	storage        storage.Storage
+	flaps          *FlapDetector
+	sandbox        *Sandbox
+	rules          map[string]ast.Expr
+	quarantined    map[string]string
+	quarantineLock sync.Mutex
+	fires          *fireRecorder
+	epoch          time.Time
+	now            func() time.Duration
+	quit           chan bool
+	wg             sync.WaitGroup
 }
 
 type AlertMessage struct {
@@ -58,6 +84,8 @@ type AlertMessage struct {
 	Count      int
 	Reason     string
 	ReasonData interface{}
+	// identifier of the fire, to retrieve its context
+	Fire string `json:",omitempty"`
 }
 
 func (am *AlertMessage) Marshal() []byte {
@@ -92,39 +120,54 @@ func (a *AlertManager) EvalNodes() {
 	defer a.alertsLock.RUnlock()
 
 	for _, al := range a.alerts {
+		if al.Type == api.ABSENCE {
+			continue
+		}
+
+		a.quarantineLock.Lock()
+		expr, ok := a.rules[al.UUID]
+		a.quarantineLock.Unlock()
+		if !ok {
+			// quarantined
+			continue
+		}
+
 		nodes := a.Graph.LookupNodesFromKey(al.Select)
+		if err := a.sandbox.CheckSelected(len(nodes)); err != nil {
+			a.quarantine(al, err.Error())
+			continue
+		}
+
 		for _, n := range nodes {
-			w := eval.NewWorld()
-			defConst := func(name string, val interface{}) {
-				t, v := toTypeValue(val)
-				w.DefineConst(name, t, v)
-			}
-			for k, v := range n.Metadata() {
-				defConst(k, v)
-			}
-			fs := token.NewFileSet()
-			toEval := "(" + al.Test + ") == true"
-			expr, err := w.Compile(fs, toEval)
-			if err != nil {
-				logging.GetLogger().Error("Can't compile expression : " + toEval)
+			if n.Metadata()["Flapping"] == true && stateTestRegexp.MatchString(al.Test) {
 				continue
 			}
-			ret, err := expr.Run()
+
+			metrics.AlertEvaluations.Inc()
+			ret, err := a.sandbox.Eval(expr, al.Test, n.Metadata())
 			if err != nil {
-				logging.GetLogger().Error("Can't evaluate expression : " + toEval)
+				if _, ok := err.(*SandboxViolation); ok {
+					a.quarantine(al, err.Error())
+					break
+				}
+				logging.GetLogger().Error(err.Error())
 				continue
 			}
 
-			if ret.String() == "true" {
+			if ret {
 				al.Count++
 
+				now := time.Now()
+				a.fires.recordNode(al, expr, n, now)
+
 				msg := AlertMessage{
 					UUID:       al.UUID,
 					Type:       FIXED,
-					Timestamp:  time.Now(),
+					Timestamp:  now,
 					Count:      al.Count,
 					Reason:     al.Action,
 					ReasonData: n,
+					Fire:       api.AlertFireID(al.UUID, al.Count),
 				}
 
 				logging.GetLogger().Debugf("AlertMessage to WS : " + al.UUID + " " + msg.String())
@@ -136,6 +179,68 @@ func (a *AlertManager) EvalNodes() {
 	}
 }
 
+// quarantine stops the evaluation of an alert until it gets updated, the
+// listeners being notified with the reason
+func (a *AlertManager) quarantine(al *api.Alert, reason string) {
+	a.quarantineLock.Lock()
+	delete(a.rules, al.UUID)
+	a.quarantined[al.UUID] = reason
+	a.quarantineLock.Unlock()
+
+	logging.GetLogger().Warningf("Alert %s quarantined: %s", al.UUID, reason)
+
+	msg := AlertMessage{
+		UUID:       al.UUID,
+		Type:       QUARANTINED,
+		Timestamp:  time.Now(),
+		Count:      1,
+		Reason:     reason,
+		ReasonData: al,
+	}
+	for _, l := range a.eventListeners {
+		l.OnAlert(&msg)
+	}
+}
+
+// Quarantined returns the reasons of the quarantined alerts by UUID
+func (a *AlertManager) Quarantined() map[string]string {
+	a.quarantineLock.Lock()
+	defer a.quarantineLock.Unlock()
+
+	quarantined := make(map[string]string, len(a.quarantined))
+	for id, reason := range a.quarantined {
+		quarantined[id] = reason
+	}
+	return quarantined
+}
+
+// stateTestRegexp matches the alert tests suppressed on flapping nodes
+var stateTestRegexp = regexp.MustCompile(`\bState\b`)
+
+// onFlapping replaces the alerts on the state of the node by a single one
+func (a *AlertManager) onFlapping(n *graph.Node) {
+	msg := AlertMessage{
+		UUID:       "flapping",
+		Type:       FLAPPING,
+		Timestamp:  time.Now(),
+		Count:      1,
+		Reason:     "Interface flapping",
+		ReasonData: n,
+	}
+
+	a.Notify(&msg)
+}
+
+// Notify sends an alert raised outside of the manager to the listeners
+func (a *AlertManager) Notify(msg *AlertMessage) {
+	a.alertsLock.RLock()
+	defer a.alertsLock.RUnlock()
+
+	for _, l := range a.eventListeners {
+		l.OnAlert(msg)
+	}
+}
+
 func (a *AlertManager) OnNodeUpdated(n *graph.Node) {
 	a.EvalNodes()
 }
@@ -144,13 +249,39 @@ func (a *AlertManager) OnNodeAdded(n *graph.Node) {
 	a.EvalNodes()
 }
 
-func (a *AlertManager) SetAlert(at *api.Alert) {
+func (a *AlertManager) setAlert(at *api.Alert, seed bool) {
 	logging.GetLogger().Debugf("New alert added: %v", at)
 
 	a.alertsLock.Lock()
 	defer a.alertsLock.Unlock()
 
 	a.alerts[at.UUID] = at
+
+	a.quarantineLock.Lock()
+	delete(a.rules, at.UUID)
+	delete(a.quarantined, at.UUID)
+	a.quarantineLock.Unlock()
+
+	if at.Type == api.ABSENCE {
+		a.setAbsence(at, seed)
+		return
+	}
+	delete(a.absences, at.UUID)
+
+	// the alerts stored before the validation of the tests are checked here
+	expr, err := a.sandbox.Parse(at.Test)
+	if err != nil {
+		a.quarantine(at, fmt.Sprintf("Invalid test: %s", err.Error()))
+		return
+	}
+
+	a.quarantineLock.Lock()
+	a.rules[at.UUID] = expr
+	a.quarantineLock.Unlock()
+}
+
+func (a *AlertManager) SetAlert(at *api.Alert) {
+	a.setAlert(at, false)
 }
 
 func (a *AlertManager) DeleteAlert(id string) {
@@ -160,11 +291,22 @@ func (a *AlertManager) DeleteAlert(id string) {
 	defer a.alertsLock.Unlock()
 
 	delete(a.alerts, id)
+	delete(a.absences, id)
+	a.fires.forget(id)
+
+	a.quarantineLock.Lock()
+	delete(a.rules, id)
+	delete(a.quarantined, id)
+	a.quarantineLock.Unlock()
 }
 
 func (a *AlertManager) onApiWatcherEvent(action string, id string, resource api.ApiResource) {
 	switch action {
-	case "init", "create", "set", "update":
+	case "init":
+		// alerts existing before the start, the last match of the absence
+		// alerts has to be retrieved from the storage
+		a.setAlert(resource.(*api.Alert), true)
+	case "create", "set", "update":
 		a.SetAlert(resource.(*api.Alert))
 	case "expire", "delete":
 		a.DeleteAlert(id)
@@ -174,20 +316,69 @@ func (a *AlertManager) onApiWatcherEvent(action string, id string, resource api.
 func (a *AlertManager) Start() {
 	a.watcher = a.AlertHandler.AsyncWatch(a.onApiWatcherEvent)
 
+	// the detector flags the nodes before the alerts are evaluated
+	if a.flaps != nil {
+		a.flaps.Start()
+	}
 	a.Graph.AddEventListener(a)
+
+	interval := time.Duration(config.GetConfig().GetInt("analyzer.alert_absence_interval")) * time.Second
+
+	if interval > 0 {
+		a.quit = make(chan bool)
+		a.wg.Add(1)
+		go a.evalAbsencesLoop(interval, a.quit)
+	}
 }
 
 func (a *AlertManager) Stop() {
+	if a.flaps != nil {
+		a.flaps.Stop()
+	}
+	if a.quit != nil {
+		close(a.quit)
+		a.wg.Wait()
+		a.quit = nil
+	}
+}
 
+// SetStorage sets the storage used to retrieve the last match of the
+// absence alerts at startup
+func (a *AlertManager) SetStorage(s storage.Storage) {
+	a.storage = s
+}
+
+// SetFlowTable sets the flow table the flows triggering the alerts are
+// referenced from
+func (a *AlertManager) SetFlowTable(ft *flow.Table) {
+	a.fires.flowTable = ft
+}
+
+// GetAlertFire returns the nth fire of an alert, recorded with what
+// triggered it
+func (a *AlertManager) GetAlertFire(id string, n int) *api.AlertFire {
+	return a.fires.GetAlertFire(id, n)
 }
 
 func NewAlertManager(g *graph.Graph, ah api.ApiHandler) *AlertManager {
-	return &AlertManager{
+	a := &AlertManager{
 		Graph:          g,
 		AlertHandler:   ah,
 		alerts:         make(map[string]*api.Alert),
+		absences:       make(map[string]*absenceRule),
 		eventListeners: make(map[AlertEventListener]AlertEventListener),
+		sandbox:        NewSandboxFromConfig(),
+		rules:          make(map[string]ast.Expr),
+		quarantined:    make(map[string]string),
+		fires:          newFireRecorderFromConfig(),
+		epoch:          time.Now(),
+	}
+	a.now = func() time.Duration {
+		return time.Since(a.epoch)
 	}
+	a.flaps = NewFlapDetectorFromConfig(g, a.onFlapping)
+
+	return a
 }
 
 /*
@@ -0,0 +1,192 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package alert
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strings"
+	"time"
+
+	eval "github.com/sbinet/go-eval"
+
+	"github.com/redhat-cip/skydive/api"
+	"github.com/redhat-cip/skydive/config"
+	"github.com/redhat-cip/skydive/topology/graph"
+)
+
+// Sandbox bounds the evaluation of the alert tests, an alert exceeding one
+// of the limits is quarantined
+type Sandbox struct {
+	Limits      api.ExpressionLimits
+	MaxSelected int
+	MaxMemory   int
+	Timeout     time.Duration
+}
+
+// SandboxViolation is returned when an alert test exceeds a limit of the
+// sandbox
+type SandboxViolation struct {
+	Reason string
+}
+
+func (v *SandboxViolation) Error() string {
+	return v.Reason
+}
+
+// intSize is the size accounted for the non string values
+const intSize = 8
+
+// allocBound returns an upper bound of the size of the value of the
+// expression, accumulating in alloc the bytes allocated by the string
+// concatenations
+func allocBound(e ast.Expr, sizes map[string]int, alloc *int) int {
+	switch e := e.(type) {
+	case *ast.BasicLit:
+		if e.Kind == token.STRING {
+			return len(e.Value)
+		}
+	case *ast.Ident:
+		if size, ok := sizes[e.Name]; ok {
+			return size
+		}
+	case *ast.ParenExpr:
+		return allocBound(e.X, sizes, alloc)
+	case *ast.UnaryExpr:
+		allocBound(e.X, sizes, alloc)
+	case *ast.BinaryExpr:
+		size := allocBound(e.X, sizes, alloc) + allocBound(e.Y, sizes, alloc)
+		if e.Op == token.ADD {
+			*alloc += size
+			return size
+		}
+	case *ast.IndexExpr:
+		allocBound(e.X, sizes, alloc)
+		allocBound(e.Index, sizes, alloc)
+	case *ast.SliceExpr:
+		for _, i := range []ast.Expr{e.Low, e.High, e.Max} {
+			if i != nil {
+				allocBound(i, sizes, alloc)
+			}
+		}
+		return allocBound(e.X, sizes, alloc)
+	case *ast.CallExpr:
+		// conversions may return their argument
+		size := intSize
+		for _, arg := range e.Args {
+			if s := allocBound(arg, sizes, alloc); s > size {
+				size = s
+			}
+		}
+		return size
+	}
+
+	return intSize
+}
+
+func (s *Sandbox) defineConsts(w *eval.World, metadata graph.Metadata) map[string]int {
+	sizes := make(map[string]int, len(metadata))
+	for k, v := range metadata {
+		// dotted keys like Kernel.Version are not valid identifiers
+		name := strings.Replace(k, ".", "_", -1)
+		if t, v := toTypeValue(v); t != nil {
+			w.DefineConst(name, t, v)
+		}
+
+		if str, ok := v.(string); ok {
+			sizes[name] = len(str)
+		} else {
+			sizes[name] = intSize
+		}
+	}
+	return sizes
+}
+
+// Parse validates the test of an alert against the structural limits
+func (s *Sandbox) Parse(test string) (ast.Expr, error) {
+	return api.ParseExpression(test, s.Limits)
+}
+
+// CheckSelected checks the number of nodes selected by an alert
+func (s *Sandbox) CheckSelected(count int) error {
+	if count > s.MaxSelected {
+		return &SandboxViolation{Reason: fmt.Sprintf("Select matched %d nodes, more than the limit of %d", count, s.MaxSelected)}
+	}
+	return nil
+}
+
+// Eval evaluates a test, parsed by Parse, with the metadata of a node. The
+// violations of the limits are returned as SandboxViolation.
+func (s *Sandbox) Eval(expr ast.Expr, test string, metadata graph.Metadata) (bool, error) {
+	w := eval.NewWorld()
+	sizes := s.defineConsts(w, metadata)
+
+	alloc := 0
+	allocBound(expr, sizes, &alloc)
+	if alloc > s.MaxMemory {
+		return false, &SandboxViolation{Reason: fmt.Sprintf("intermediate results of up to %d bytes exceed the limit of %d bytes", alloc, s.MaxMemory)}
+	}
+
+	toEval := "(" + test + ") == true"
+	code, err := w.Compile(token.NewFileSet(), toEval)
+	if err != nil {
+		return false, fmt.Errorf("Can't compile expression : %s: %s", toEval, err.Error())
+	}
+
+	type result struct {
+		value eval.Value
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		v, err := code.Run()
+		done <- result{value: v, err: err}
+	}()
+
+	timer := time.NewTimer(s.Timeout)
+	defer timer.Stop()
+
+	select {
+	case r := <-done:
+		if r.err != nil {
+			return false, fmt.Errorf("Can't evaluate expression : %s: %s", toEval, r.err.Error())
+		}
+		return r.value.String() == "true", nil
+	case <-timer.C:
+		// the evaluation can't be interrupted, its goroutine is left to
+		// complete but the alert won't be evaluated anymore
+		return false, &SandboxViolation{Reason: fmt.Sprintf("evaluation took more than %s", s.Timeout)}
+	}
+}
+
+func NewSandboxFromConfig() *Sandbox {
+	cfg := config.GetConfig()
+
+	return &Sandbox{
+		Limits:      api.NewExpressionLimitsFromConfig(),
+		MaxSelected: cfg.GetInt("analyzer.alert_sandbox.max_selected_nodes"),
+		MaxMemory:   cfg.GetInt("analyzer.alert_sandbox.max_memory"),
+		Timeout:     time.Duration(cfg.GetInt("analyzer.alert_sandbox.timeout")) * time.Millisecond,
+	}
+}
@@ -0,0 +1,146 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package alert
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redhat-cip/skydive/api"
+	"github.com/redhat-cip/skydive/topology/graph"
+)
+
+func newTestSandboxManager(t *testing.T, sandbox *Sandbox) (*AlertManager, *testAlertListener) {
+	b, err := graph.NewMemoryBackend()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	g, err := graph.NewGraph(b)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	am := NewAlertManager(g, nil)
+	am.sandbox = sandbox
+
+	listener := &testAlertListener{}
+	am.AddEventListener(listener)
+
+	big := strings.Repeat("x", 1024*1024)
+
+	g.Lock()
+	g.NewNode(graph.GenID(), graph.Metadata{"Name": "eth0", "Big": big})
+	for i := 1; i <= 3; i++ {
+		g.NewNode(graph.GenID(), graph.Metadata{"Index": i})
+	}
+	g.Unlock()
+
+	return am, listener
+}
+
+func newTestAlert(sel, test string) *api.Alert {
+	alert := api.NewAlert()
+	alert.Select = sel
+	alert.Test = test
+	return alert
+}
+
+func TestSandboxQuarantine(t *testing.T) {
+	sandbox := NewSandboxFromConfig()
+	sandbox.MaxSelected = 2
+
+	am, listener := newTestSandboxManager(t, sandbox)
+
+	benign := newTestAlert("Name", `Name == "eth0"`)
+	hostiles := map[string]*api.Alert{
+		"Invalid test: column 1: only calls to named functions are allowed": newTestAlert("Name", `func() bool { for {} }()`),
+		"Select matched 3 nodes, more than the limit of 2":                  newTestAlert("Index", `Index > 0`),
+		"intermediate results of up to 5242880 bytes exceed the limit":      newTestAlert("Big", `len(Big+Big+Big) > 0`),
+	}
+
+	am.SetAlert(benign)
+	for _, alert := range hostiles {
+		am.SetAlert(alert)
+	}
+
+	for round := 0; round < 2; round++ {
+		start := time.Now()
+		am.EvalNodes()
+		if elapsed := time.Since(start); elapsed > sandbox.Timeout {
+			t.Errorf("evaluation round %d took %s", round, elapsed)
+		}
+
+		if count := countAlerts(listener, FIXED); count != round+1 {
+			t.Fatalf("benign alert should have fired %d times, got %d", round+1, count)
+		}
+	}
+
+	if count := countAlerts(listener, QUARANTINED); count != len(hostiles) {
+		t.Fatalf("expected %d quarantine messages, got %d", len(hostiles), count)
+	}
+
+	quarantined := am.Quarantined()
+	if _, ok := quarantined[benign.UUID]; ok {
+		t.Error("benign alert should not be quarantined")
+	}
+	for reason, alert := range hostiles {
+		if !strings.HasPrefix(quarantined[alert.UUID], reason) {
+			t.Errorf("expected quarantine reason %q, got %q", reason, quarantined[alert.UUID])
+		}
+	}
+
+	// an update lifts the quarantine
+	fixed := hostiles["Select matched 3 nodes, more than the limit of 2"]
+	fixed.Select = "Name"
+	fixed.Test = `len(Name) == 4`
+	am.SetAlert(fixed)
+	if _, ok := am.Quarantined()[fixed.UUID]; ok {
+		t.Error("updated alert should not be quarantined anymore")
+	}
+
+	am.EvalNodes()
+	if count := countAlerts(listener, FIXED); count != 4 {
+		t.Errorf("the updated alert should have fired, got %d alerts", count)
+	}
+}
+
+func TestSandboxTimeout(t *testing.T) {
+	sandbox := NewSandboxFromConfig()
+	sandbox.MaxMemory = 1 << 30
+	sandbox.Timeout = time.Nanosecond
+
+	am, listener := newTestSandboxManager(t, sandbox)
+
+	alert := newTestAlert("Big", "len("+strings.Repeat("Big+", 20)+"Big) > 0")
+	am.SetAlert(alert)
+	am.EvalNodes()
+
+	if count := countAlerts(listener, QUARANTINED); count != 1 {
+		t.Fatalf("expected a quarantine message, got %d", count)
+	}
+	if reason := am.Quarantined()[alert.UUID]; reason != "evaluation took more than 1ns" {
+		t.Errorf("unexpected quarantine reason: %s", reason)
+	}
+}
@@ -0,0 +1,265 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package topology
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/redhat-cip/skydive/flow"
+	"github.com/redhat-cip/skydive/storage"
+	"github.com/redhat-cip/skydive/topology/graph"
+)
+
+// CaptureLookup returns the probe path and the tags of a capture, false
+// when the capture doesn't exist
+type CaptureLookup func(id string) (probePath string, tags map[string]string, ok bool)
+
+// FlowTraversalExtension adds the Flows step returning the flows of the
+// nodes of the previous step, or all the flows right after G. Without At
+// the live flows of the table are returned, otherwise the flows active in
+// the window preceding the time of At are searched in the storage.
+type FlowTraversalExtension struct {
+	flowsToken graph.Token
+	FlowTable  *flow.Table
+	Storage    storage.Storage
+	Captures   CaptureLookup
+	Window     time.Duration
+	MaxResults int
+}
+
+type FlowGremlinTraversalStep struct {
+	extension *FlowTraversalExtension
+}
+
+type FlowTraversalStep struct {
+	flows []*flow.Flow
+}
+
+func (f *FlowTraversalStep) Values() []interface{} {
+	s := make([]interface{}, len(f.flows))
+	for i, fl := range f.flows {
+		s[i] = fl
+	}
+	return s
+}
+
+func (f *FlowTraversalStep) Error() error {
+	return nil
+}
+
+func (f *FlowTraversalStep) Limit(n int) graph.GraphTraversalStep {
+	if len(f.flows) <= n {
+		return f
+	}
+	return &FlowTraversalStep{flows: f.flows[:n]}
+}
+
+func NewFlowTraversalExtension(table *flow.Table, store storage.Storage) *FlowTraversalExtension {
+	return &FlowTraversalExtension{
+		flowsToken: graph.Token(1001),
+		FlowTable:  table,
+		Storage:    store,
+	}
+}
+
+func (e *FlowTraversalExtension) ScanIdent(s string) (graph.Token, bool) {
+	switch s {
+	case "FLOWS":
+		return e.flowsToken, true
+	}
+	return graph.IDENT, false
+}
+
+func (e *FlowTraversalExtension) ParseStep(t graph.Token, p graph.GremlinTraversalStepParams) (graph.GremlinTraversalStep, error) {
+	switch t {
+	case e.flowsToken:
+		if len(p) != 0 {
+			return nil, errors.New("Flows predicate accept no parameter")
+		}
+		return &FlowGremlinTraversalStep{extension: e}, nil
+	}
+
+	return nil, nil
+}
+
+// flowScope is the part of the scope of a traversal applying to the flows
+type flowScope struct {
+	from, to  int64
+	probeNode string
+	tags      map[string]string
+}
+
+func (s *flowScope) match(f *flow.Flow) bool {
+	if s.to != 0 {
+		fs := f.GetStatistics()
+		if fs == nil || fs.Last < s.from || fs.Start > s.to {
+			return false
+		}
+	}
+	if s.probeNode != "" && f.ProbeNodeUUID != s.probeNode {
+		return false
+	}
+	if len(s.tags) > 0 {
+		attrs := f.GetAttributes()
+		for k, v := range s.tags {
+			if attrs[k] != v {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (e *FlowTraversalExtension) flowScope(t *graph.GraphTraversal) (*flowScope, error) {
+	scope := &flowScope{}
+
+	if !t.Scope.Time.IsZero() {
+		scope.to = t.Scope.Time.Unix()
+		scope.from = t.Scope.Time.Add(-e.Window).Unix()
+	}
+
+	if t.Scope.Capture != "" {
+		var probePath string
+		var ok bool
+		if e.Captures != nil {
+			probePath, scope.tags, ok = e.Captures(t.Scope.Capture)
+		}
+		if !ok {
+			return nil, fmt.Errorf("Unknown capture %s", t.Scope.Capture)
+		}
+
+		// the probe node identifies the flows of the capture, none when
+		// the interface isn't in the topology
+		node := LookupNodeFromNodePathString(t.Graph, probePath)
+		if node == nil {
+			t.Warnf("Interface %s of capture %s not found in the topology", probePath, t.Scope.Capture)
+			return nil, nil
+		}
+		scope.probeNode = string(node.ID)
+	}
+
+	return scope, nil
+}
+
+func (e *FlowTraversalExtension) searchFlows(t *graph.GraphTraversal, scope *flowScope) ([]*flow.Flow, error) {
+	if scope.to == 0 || e.Storage == nil {
+		if scope.to != 0 {
+			t.Warnf("No flow storage, the flows of %s are looked up in the live flows", t.Scope.Time.UTC().Format(time.RFC3339))
+		}
+		if e.FlowTable == nil {
+			return nil, errors.New("No flow table available")
+		}
+		return e.FlowTable.GetFlows(), nil
+	}
+
+	filters := storage.Filters{
+		"Statistics.Last":  storage.Range{Gte: scope.from},
+		"Statistics.Start": storage.Range{Lte: scope.to},
+	}
+	if scope.probeNode != "" {
+		filters["ProbeNodeUUID"] = scope.probeNode
+	}
+
+	if cs, ok := e.Storage.(storage.ContextSearcher); ok {
+		return cs.SearchFlowsContext(t.Context(), filters)
+	}
+	return e.Storage.SearchFlows(filters)
+}
+
+func flowLast(f *flow.Flow) int64 {
+	if fs := f.GetStatistics(); fs != nil {
+		return fs.Last
+	}
+	return 0
+}
+
+type sortByLastDesc []*flow.Flow
+
+func (s sortByLastDesc) Len() int {
+	return len(s)
+}
+
+func (s sortByLastDesc) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s sortByLastDesc) Less(i, j int) bool {
+	return flowLast(s[i]) > flowLast(s[j])
+}
+
+func (s *FlowGremlinTraversalStep) Exec(last graph.GraphTraversalStep) (graph.GraphTraversalStep, error) {
+	var t *graph.GraphTraversal
+	var nodes map[string]bool
+
+	switch tv := last.(type) {
+	case *graph.GraphTraversal:
+		t = tv
+	case *graph.GraphTraversalV:
+		if err := tv.Error(); err != nil {
+			return nil, err
+		}
+		t = tv.GraphTraversal
+		nodes = make(map[string]bool)
+		for _, i := range tv.Values() {
+			nodes[string(i.(*graph.Node).ID)] = true
+		}
+	default:
+		return nil, graph.ExecutionError
+	}
+
+	scope, err := s.extension.flowScope(t)
+	if err != nil {
+		return nil, err
+	}
+	if scope == nil {
+		return &FlowTraversalStep{}, nil
+	}
+
+	candidates, err := s.extension.searchFlows(t, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	flows := []*flow.Flow{}
+	for _, f := range candidates {
+		if nodes != nil && !nodes[f.ProbeNodeUUID] && !nodes[f.IfSrcNodeUUID] && !nodes[f.IfDstNodeUUID] {
+			continue
+		}
+		if scope.match(f) {
+			flows = append(flows, f)
+		}
+	}
+
+	// the most recent flows first, the ones kept by the limits
+	sort.Stable(sortByLastDesc(flows))
+
+	if max := s.extension.MaxResults; max > 0 && len(flows) > max {
+		t.Warnf("Flows truncated to the %d most recent ones", max)
+		flows = flows[:max]
+	}
+
+	return &FlowTraversalStep{flows: flows}, nil
+}
@@ -0,0 +1,164 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package topology
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redhat-cip/skydive/flow"
+	"github.com/redhat-cip/skydive/storage"
+	"github.com/redhat-cip/skydive/topology/graph"
+)
+
+type fakeFlowStorage struct {
+	flows   []*flow.Flow
+	filters storage.Filters
+}
+
+func (s *fakeFlowStorage) Start() {}
+func (s *fakeFlowStorage) Stop()  {}
+
+func (s *fakeFlowStorage) StoreFlows(flows []*flow.Flow) error {
+	return nil
+}
+
+func (s *fakeFlowStorage) SearchFlows(filters storage.Filters) ([]*flow.Flow, error) {
+	s.filters = filters
+	return s.flows, nil
+}
+
+func (s *fakeFlowStorage) CountFlows(filters storage.Filters) (int, error) {
+	return len(s.flows), nil
+}
+
+func newFlow(uuid string, probeNode string, last time.Time, tags map[string]string) *flow.Flow {
+	f := &flow.Flow{
+		UUID:          uuid,
+		ProbeNodeUUID: probeNode,
+		Statistics:    &flow.FlowStatistics{Start: last.Add(-time.Minute).Unix(), Last: last.Unix()},
+	}
+	f.SetTags(tags)
+	return f
+}
+
+func execFlowsQuery(t *testing.T, g *graph.Graph, e *FlowTraversalExtension, query string) ([]interface{}, []string, error) {
+	tp := graph.NewGremlinTraversalParser(strings.NewReader(query), g)
+	tp.AddTraversalExtension(e)
+
+	ts, err := tp.Parse()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	res, err := ts.Exec()
+	if err != nil {
+		return nil, nil, err
+	}
+	return res.Values(), ts.Warnings(), nil
+}
+
+func TestFlowsTraversal(t *testing.T) {
+	g := newGraph(t)
+	host := g.NewNode(graph.GenID(), graph.Metadata{"Type": "host", "Name": "localhost"})
+	eth0 := g.NewNode(graph.GenID(), graph.Metadata{"Name": "eth0", "Type": "device"})
+	eth1 := g.NewNode(graph.GenID(), graph.Metadata{"Name": "eth1", "Type": "device"})
+	g.Link(host, eth0, graph.Metadata{"RelationType": "ownership"})
+	g.Link(host, eth1, graph.Metadata{"RelationType": "ownership"})
+
+	now := time.Now()
+	table := flow.NewTableFromFlows([]*flow.Flow{
+		newFlow("f1", string(eth0.ID), now.Add(-time.Minute), nil),
+		newFlow("f2", string(eth0.ID), now, nil),
+		newFlow("f3", string(eth1.ID), now, nil),
+	})
+	e := NewFlowTraversalExtension(table, nil)
+
+	values, _, err := execFlowsQuery(t, g, e, `G.V().Has("Name", "eth0").Flows()`)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(values) != 2 || values[0].(*flow.Flow).UUID != "f2" {
+		t.Fatalf("Should return the 2 flows of eth0, the most recent first, returned: %v", values)
+	}
+
+	values, _, err = execFlowsQuery(t, g, e, `G.V().Flows().Limit(1)`)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(values) != 1 {
+		t.Fatalf("Should return 1 flow, returned: %v", values)
+	}
+
+	values, _, err = execFlowsQuery(t, g, e, `G.Flows().Count()`)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if values[0] != 3 {
+		t.Fatalf("Should count 3 flows, returned: %v", values)
+	}
+}
+
+func TestFlowsTraversalScope(t *testing.T) {
+	g := newGraph(t)
+	host := g.NewNode(graph.GenID(), graph.Metadata{"Type": "host", "Name": "localhost"})
+	eth0 := g.NewNode(graph.GenID(), graph.Metadata{"Name": "eth0", "Type": "device"})
+	g.Link(host, eth0, graph.Metadata{"RelationType": "ownership"})
+
+	at := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	store := &fakeFlowStorage{flows: []*flow.Flow{
+		newFlow("before", string(eth0.ID), at.Add(-time.Hour), nil),
+		newFlow("at", string(eth0.ID), at, map[string]string{"Tag.env": "prod"}),
+		newFlow("untagged", string(eth0.ID), at, nil),
+	}}
+	e := NewFlowTraversalExtension(flow.NewTable(), store)
+	e.Window = time.Minute
+	e.Captures = func(id string) (string, map[string]string, bool) {
+		if id != "my-capture" {
+			return "", nil, false
+		}
+		return "localhost[Type=host]/eth0[Type=device]", map[string]string{"Tag.env": "prod"}, true
+	}
+
+	query := `G.At("` + at.Format(time.RFC3339) + `").Capture("my-capture").Flows()`
+	values, warnings, err := execFlowsQuery(t, g, e, query)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(values) != 1 || values[0].(*flow.Flow).UUID != "at" {
+		t.Fatalf("Should return the flow of the capture at the time, returned: %v", values)
+	}
+	if len(warnings) != 1 {
+		t.Fatalf("Expected a warning about the topology history, got: %v", warnings)
+	}
+
+	expected := storage.Range{Gte: at.Add(-time.Minute).Unix()}
+	if store.filters["Statistics.Last"] != expected || store.filters["ProbeNodeUUID"] != string(eth0.ID) {
+		t.Fatalf("Unexpected storage filters: %v", store.filters)
+	}
+
+	if _, _, err = execFlowsQuery(t, g, e, `G.Capture("unknown").Flows()`); err == nil || err.Error() != "Unknown capture unknown" {
+		t.Fatalf("Expected an unknown capture error, got: %v", err)
+	}
+}
@@ -0,0 +1,149 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package graph
+
+import (
+	"math"
+	"time"
+)
+
+// TraversalEstimate gives an approximate result size of a traversal. The
+// filtering steps are evaluated on the elements returned by the previous
+// step while the other ones, possibly expanding the result, are evaluated on
+// a sample, the ratio between the sizes being applied to the estimated count.
+type TraversalEstimate struct {
+	Count int
+	// indexes used to select the elements, a scan being required otherwise
+	Indexes []string
+	Scan    bool
+	// false when the time budget was exhausted before the last step
+	Complete bool
+}
+
+func sampleNodes(nodes []*Node, size int) []*Node {
+	sample := make([]*Node, size)
+	for i := range sample {
+		sample[i] = nodes[i*len(nodes)/size]
+	}
+	return sample
+}
+
+func sampleEdges(edges []*Edge, size int) []*Edge {
+	sample := make([]*Edge, size)
+	for i := range sample {
+		sample[i] = edges[i*len(edges)/size]
+	}
+	return sample
+}
+
+// sampleStep keeps at most size elements, evenly spread, of the result of a step
+func sampleStep(step GraphTraversalStep, size int) GraphTraversalStep {
+	switch st := step.(type) {
+	case *GraphTraversalV:
+		if len(st.nodes) > size {
+			return &GraphTraversalV{GraphTraversal: st.GraphTraversal, nodes: sampleNodes(st.nodes, size)}
+		}
+	case *GraphTraversalE:
+		if len(st.edges) > size {
+			return &GraphTraversalE{GraphTraversal: st.GraphTraversal, edges: sampleEdges(st.edges, size)}
+		}
+	}
+	return step
+}
+
+// Estimate evaluates the sequence using samples of at most sampleSize
+// elements, stopping at the deadline. The sequence can't be executed afterwards.
+func (s *GremlinTraversalSequence) Estimate(sampleSize int, deadline time.Time) (*TraversalEstimate, error) {
+	estimate := &TraversalEstimate{Indexes: []string{}, Complete: true}
+	if len(s.steps) == 0 {
+		return estimate, nil
+	}
+
+	var last GraphTraversalStep = s.GraphTraversal
+	count := float64(len(last.Values()))
+
+	var step GremlinTraversalStep
+	for i := 0; i != -1; {
+		if time.Now().After(deadline) {
+			estimate.Complete = false
+			break
+		}
+
+		step, i = s.nextStepToExec(i)
+
+		if v, ok := step.(*gremlinTraversalStepV); ok {
+			if len(v.params) > 0 {
+				// direct lookup by identifier
+				estimate.Indexes = append(estimate.Indexes, "ID")
+			} else {
+				estimate.Scan = true
+			}
+		}
+
+		input := last
+		switch step.(type) {
+		case *gremlinTraversalStepHas, *gremlinTraversalStepDedup:
+		default:
+			input = sampleStep(last, sampleSize)
+		}
+
+		next, err := step.Exec(input)
+		if err != nil {
+			return nil, err
+		}
+		if err = next.Error(); err != nil {
+			return nil, err
+		}
+
+		switch st := step.(type) {
+		case *gremlinTraversalStepLimit:
+			// a sample doesn't reach the limit
+			count = math.Min(count, float64(st.limit))
+		case *gremlinTraversalStepCount:
+			count = 1
+		default:
+			if in := len(input.Values()); in > 0 {
+				count *= float64(len(next.Values())) / float64(in)
+			} else {
+				count = 0
+			}
+		}
+
+		// the duplicates of the whole set can't be seen on a sample
+		if _, ok := step.(*gremlinTraversalStepDedup); ok {
+			g := s.GraphTraversal.Graph
+			switch next.(type) {
+			case *GraphTraversalV:
+				count = math.Min(count, float64(len(g.GetNodes())))
+			case *GraphTraversalE:
+				count = math.Min(count, float64(len(g.GetEdges())))
+			}
+		}
+
+		last = next
+	}
+
+	estimate.Count = int(count + 0.5)
+
+	return estimate, nil
+}
@@ -0,0 +1,117 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package graph
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newEstimateGraph(t *testing.T) *Graph {
+	g := newGraph(t)
+
+	for i := 0; i != 100; i++ {
+		host := g.NewNode(GenID(), Metadata{"Type": "host"})
+		for j := 0; j != 20; j++ {
+			state := "DOWN"
+			if j%4 == 0 {
+				state = "UP"
+			}
+			g.Link(host, g.NewNode(GenID(), Metadata{"Type": "intf", "State": state}))
+		}
+	}
+
+	return g
+}
+
+func parseTestQuery(t *testing.T, g *Graph, query string) *GremlinTraversalSequence {
+	ts, err := NewGremlinTraversalParser(strings.NewReader(query), g).Parse()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return ts
+}
+
+func TestTraversalEstimate(t *testing.T) {
+	g := newEstimateGraph(t)
+
+	queries := []string{
+		"G.V()",
+		"G.V().Has('Type', 'intf')",
+		"G.V().Has('Type', 'intf', 'State', 'UP')",
+		"G.V().Has('Type', 'host').Out()",
+		"G.V().Has('Type', 'host').OutE()",
+		"G.V().Has('Type', 'intf').In().Has('Type', 'host')",
+		"G.V().Has('Type', 'unknown')",
+	}
+
+	for _, query := range queries {
+		res, err := parseTestQuery(t, g, query).Exec()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		actual := len(res.Values())
+
+		estimate, err := parseTestQuery(t, g, query).Estimate(500, time.Now().Add(time.Second))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		if !estimate.Complete || !estimate.Scan {
+			t.Errorf("%s: wrong estimate %+v", query, estimate)
+		}
+
+		if math.Abs(float64(estimate.Count-actual)) > 0.2*float64(actual) {
+			t.Errorf("%s: estimate %d too far from the actual count %d", query, estimate.Count, actual)
+		}
+	}
+}
+
+func TestTraversalEstimateIndex(t *testing.T) {
+	g := newEstimateGraph(t)
+	node := g.GetNodes()[0]
+
+	estimate, err := parseTestQuery(t, g, "G.V('"+string(node.ID)+"')").Estimate(500, time.Now().Add(time.Second))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if estimate.Count != 1 || estimate.Scan || len(estimate.Indexes) != 1 || estimate.Indexes[0] != "ID" {
+		t.Errorf("Lookup by ID should use the index: %+v", estimate)
+	}
+}
+
+func TestTraversalEstimateDeadline(t *testing.T) {
+	g := newEstimateGraph(t)
+
+	estimate, err := parseTestQuery(t, g, "G.V().Has('Type', 'host')").Estimate(500, time.Now())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if estimate.Complete {
+		t.Error("Estimate shouldn't be complete once the deadline is reached")
+	}
+}
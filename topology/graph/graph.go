@@ -46,6 +46,23 @@ type GraphEventListener interface {
 	OnEdgeDeleted(e *Edge)
 }
 
+// GraphBatchListener is implemented by the listeners notified once of all
+// the changes of a batch instead of once per change
+type GraphBatchListener interface {
+	OnBatchApplied(b *GraphBatch)
+}
+
+// GraphBatchEvent is a change of a batch, Type being the name of the
+// GraphEventListener callback without the On prefix, NodeAdded for instance.
+type GraphBatchEvent struct {
+	Type    string
+	Element interface{}
+}
+
+type GraphBatch struct {
+	Events []GraphBatchEvent
+}
+
 type Metadata map[string]interface{}
 
 type MetadataTransaction struct {
@@ -93,6 +110,10 @@ type Graph struct {
 	backend        GraphBackend
 	host           string
 	eventListeners []GraphEventListener
+	batch          *GraphBatch
+	mergeRules     []MergeRule
+	merged         map[Identifier]Identifier
+	idStrategy     IDStrategy
 }
 
 type MetadataMatcher interface {
@@ -192,6 +213,14 @@ func (n *Node) JsonRawMessage() *json.RawMessage {
 	return &raw
 }
 
+func (n *Node) UnmarshalJSON(b []byte) error {
+	var i interface{}
+	if err := json.Unmarshal(b, &i); err != nil {
+		return err
+	}
+	return n.Decode(i)
+}
+
 func (n *Node) Decode(i interface{}) error {
 	objMap, ok := i.(map[string]interface{})
 	if !ok {
@@ -505,6 +534,14 @@ func (g *Graph) LookupNodesFromKey(key string) []*Node {
 }
 
 func (g *Graph) AddEdge(e *Edge) bool {
+	if parent, child := g.resolve(e.parent), g.resolve(e.child); parent != e.parent || child != e.child {
+		// linking nodes merged together
+		if parent == child {
+			return false
+		}
+		e.parent, e.child = parent, child
+	}
+
 	if !g.backend.AddEdge(e) {
 		return false
 	}
@@ -517,7 +554,14 @@ func (g *Graph) GetEdge(i Identifier) *Edge {
 	return g.backend.GetEdge(i)
 }
 
+// AddNode adds the node, unless it matches a merge rule, the node being then
+// coalesced in the one it matches
 func (g *Graph) AddNode(n *Node) bool {
+	if into := g.mergeTarget(n); into != nil {
+		g.merge(into, n)
+		return true
+	}
+
 	if !g.backend.AddNode(n) {
 		return false
 	}
@@ -548,6 +592,10 @@ func (g *Graph) NewNode(i Identifier, m Metadata) *Node {
 		return nil
 	}
 
+	if id := g.resolve(i); id != i {
+		return g.backend.GetNode(id)
+	}
+
 	return n
 }
 
@@ -586,6 +634,7 @@ func (g *Graph) DelNode(n *Node) {
 	}
 
 	if g.backend.DelNode(n) {
+		g.forgetMerged(n)
 		g.NotifyNodeDeleted(n)
 	}
 }
@@ -639,39 +688,91 @@ func (g *Graph) MarshalJSON() ([]byte, error) {
 	})
 }
 
+func (g *Graph) addBatchEvent(t string, e interface{}) {
+	if g.batch != nil {
+		g.batch.Events = append(g.batch.Events, GraphBatchEvent{Type: t, Element: e})
+	}
+}
+
+func (g *Graph) isBatched(l GraphEventListener) bool {
+	if g.batch == nil {
+		return false
+	}
+	_, ok := l.(GraphBatchListener)
+	return ok
+}
+
+// Batch applies the changes done by fn, the batch listeners being notified
+// of all of them once fn returns and the other listeners as usual. The graph
+// lock has to be held.
+func (g *Graph) Batch(fn func()) {
+	g.batch = &GraphBatch{}
+	fn()
+	batch := g.batch
+	g.batch = nil
+
+	if len(batch.Events) == 0 {
+		return
+	}
+
+	for _, l := range g.eventListeners {
+		if bl, ok := l.(GraphBatchListener); ok {
+			bl.OnBatchApplied(batch)
+		}
+	}
+}
+
 func (g *Graph) NotifyNodeUpdated(n *Node) {
+	g.addBatchEvent("NodeUpdated", n)
 	for _, l := range g.eventListeners {
-		l.OnNodeUpdated(n)
+		if !g.isBatched(l) {
+			l.OnNodeUpdated(n)
+		}
 	}
 }
 
 func (g *Graph) NotifyNodeDeleted(n *Node) {
+	g.addBatchEvent("NodeDeleted", n)
 	for _, l := range g.eventListeners {
-		l.OnNodeDeleted(n)
+		if !g.isBatched(l) {
+			l.OnNodeDeleted(n)
+		}
 	}
 }
 
 func (g *Graph) NotifyNodeAdded(n *Node) {
+	g.addBatchEvent("NodeAdded", n)
 	for _, l := range g.eventListeners {
-		l.OnNodeAdded(n)
+		if !g.isBatched(l) {
+			l.OnNodeAdded(n)
+		}
 	}
 }
 
 func (g *Graph) NotifyEdgeUpdated(e *Edge) {
+	g.addBatchEvent("EdgeUpdated", e)
 	for _, l := range g.eventListeners {
-		l.OnEdgeUpdated(e)
+		if !g.isBatched(l) {
+			l.OnEdgeUpdated(e)
+		}
 	}
 }
 
 func (g *Graph) NotifyEdgeDeleted(e *Edge) {
+	g.addBatchEvent("EdgeDeleted", e)
 	for _, l := range g.eventListeners {
-		l.OnEdgeDeleted(e)
+		if !g.isBatched(l) {
+			l.OnEdgeDeleted(e)
+		}
 	}
 }
 
 func (g *Graph) NotifyEdgeAdded(e *Edge) {
+	g.addBatchEvent("EdgeAdded", e)
 	for _, l := range g.eventListeners {
-		l.OnEdgeAdded(e)
+		if !g.isBatched(l) {
+			l.OnEdgeAdded(e)
+		}
 	}
 }
 
@@ -701,8 +802,11 @@ func NewGraph(b GraphBackend) (*Graph, error) {
 	}
 
 	return &Graph{
-		backend: b,
-		host:    h,
+		backend:    b,
+		host:       h,
+		mergeRules: MergeRulesFromConfig(),
+		merged:     make(map[Identifier]Identifier),
+		idStrategy: IDStrategyFromConfig(),
 	}, nil
 }
 
@@ -31,6 +31,7 @@ import (
 	"net/url"
 	"strconv"
 
+	shttp "github.com/redhat-cip/skydive/http"
 	"github.com/redhat-cip/skydive/logging"
 )
 
@@ -65,6 +66,7 @@ type responseParser interface {
 type restclient struct {
 	Endpoint       string
 	responseParser responseParser
+	client         *http.Client
 }
 
 type gremlinServerParser struct {
@@ -208,13 +210,7 @@ func (c *restclient) query(q string) ([]byte, error) {
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	tr := &http.Transport{
-		DisableKeepAlives:  true,
-		DisableCompression: true,
-	}
-	client := &http.Client{Transport: tr}
-
-	resp, err := client.Do(req)
+	resp, err := c.client.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -252,6 +248,14 @@ func (c *restclient) detectServer() error {
 func (c *restclient) connect(endpoint string) error {
 	c.Endpoint = endpoint
 
+	transport, err := shttp.NewTransportFromConfig("gremlin")
+	if err != nil {
+		return err
+	}
+	transport.DisableKeepAlives = true
+	transport.DisableCompression = true
+	c.client = &http.Client{Transport: transport}
+
 	if err := c.detectServer(); err != nil {
 		return err
 	}
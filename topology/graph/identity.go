@@ -0,0 +1,245 @@
+/*
+ * Copyright (C) 2015 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package graph
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/nu7hatch/gouuid"
+
+	"github.com/redhat-cip/skydive/config"
+	"github.com/redhat-cip/skydive/logging"
+)
+
+// policies applied to an interface recreated with the same name but a new
+// MAC address
+const (
+	// the interface is a new one, with a new identifier
+	RecreatedNew = "new"
+	// the interface keeps the identifier of the previous one
+	RecreatedKeep = "keep"
+)
+
+// stableIDNamespace is the namespace of the stable identifiers
+var stableIDNamespace, _ = uuid.ParseHex("0b9d2f8e-5a37-4c1e-9d65-3f1c2a7e8b40")
+
+// IDStrategy gives the identifiers of the nodes created by the probes, key
+// being the natural key of the node, the durable metadata identifying it on
+// its host such as the name and the MAC of an interface
+type IDStrategy interface {
+	NodeID(host string, key Metadata) Identifier
+}
+
+// RandomIDStrategy gives a new identifier to each node, the nodes getting
+// new identifiers when the agent restarts
+type RandomIDStrategy struct {
+}
+
+func (s *RandomIDStrategy) NodeID(host string, key Metadata) Identifier {
+	return GenID()
+}
+
+// StableIDStrategy derives the identifiers from the host and the natural
+// key, the nodes keeping their identifiers across the agent restarts. With
+// the RecreatedKeep policy the MAC address isn't part of the key, an
+// interface recreated with the same name keeping its identifier.
+type StableIDStrategy struct {
+	Recreated string
+}
+
+func (s *StableIDStrategy) NodeID(host string, key Metadata) Identifier {
+	var kv []string
+	for k, v := range key {
+		if k == "MAC" && s.Recreated == RecreatedKeep {
+			continue
+		}
+		kv = append(kv, fmt.Sprintf("%s=%v", k, v))
+	}
+	if len(kv) == 0 {
+		return GenID()
+	}
+	sort.Strings(kv)
+
+	u, err := uuid.NewV5(stableIDNamespace, []byte(host+"/"+strings.Join(kv, ",")))
+	if err != nil {
+		return GenID()
+	}
+	return Identifier(u.String())
+}
+
+// GenNodeID returns the identifier of a new node of the graph given its
+// natural key, a random one if the strategy gives the identifier of an
+// existing node, two probes reporting the same device for instance
+func (g *Graph) GenNodeID(key Metadata) Identifier {
+	id := g.idStrategy.NodeID(g.host, key)
+	if g.backend.GetNode(id) != nil {
+		logging.GetLogger().Debugf("Node %s already exists, %v gets a random identifier", id, key)
+		return GenID()
+	}
+	return id
+}
+
+func (g *Graph) SetIDStrategy(s IDStrategy) {
+	g.idStrategy = s
+}
+
+// IDStrategyFromConfig returns the strategy of graph.id_strategy, random or
+// stable, the interfaces recreated with a new MAC being handled according
+// to graph.recreated_policy
+func IDStrategyFromConfig() IDStrategy {
+	if config.GetConfig().GetString("graph.id_strategy") == "random" {
+		return &RandomIDStrategy{}
+	}
+	return &StableIDStrategy{Recreated: config.GetConfig().GetString("graph.recreated_policy")}
+}
+
+// identityKey returns the key matching a node deleted by a resync with the
+// one re-added by the agent, false if the node has no name
+func identityKey(n *Node, recreated string) (string, bool) {
+	name, ok := n.metadata["Name"]
+	if !ok || name == "" {
+		return "", false
+	}
+
+	key := fmt.Sprintf("%v/%v", name, n.metadata["Type"])
+	if recreated != RecreatedKeep {
+		key += fmt.Sprintf("/%v", n.metadata["MAC"])
+	}
+	return key, true
+}
+
+// hostResync holds what has to be restored when an agent re-adds its nodes
+// after a resync: the edges not created by the agent, user edges for
+// instance, linking its deleted nodes, and the identities of these nodes so
+// that the ones re-added under new identifiers, when the agent moves to
+// stable identifiers, are matched with the deleted ones.
+type hostResync struct {
+	recreated string
+	deleted   map[Identifier]bool
+	// identifier of the deleted node of each identity, empty if several
+	// nodes share the identity
+	identities map[string]Identifier
+	edges      []*Edge
+}
+
+func newHostResync(nodes []*Node, edges []*Edge, recreated string) *hostResync {
+	r := &hostResync{
+		recreated:  recreated,
+		deleted:    make(map[Identifier]bool),
+		identities: make(map[string]Identifier),
+		edges:      edges,
+	}
+
+	for _, n := range nodes {
+		r.deleted[n.ID] = true
+
+		if key, ok := identityKey(n, recreated); ok {
+			if _, found := r.identities[key]; found {
+				r.identities[key] = ""
+			} else {
+				r.identities[key] = n.ID
+			}
+		}
+	}
+
+	return r
+}
+
+// restore matches the re-added node with a deleted one, the identifier of
+// the deleted one designating the new one from now on, and re-adds the
+// pending edges whose nodes are back
+func (r *hostResync) restore(g *Graph, n *Node) {
+	old := n.ID
+	if !r.deleted[n.ID] {
+		key, ok := identityKey(n, r.recreated)
+		if !ok || r.identities[key] == "" {
+			return
+		}
+		old = r.identities[key]
+		delete(r.identities, key)
+
+		logging.GetLogger().Infof("Node %s of %s re-added as %s", old, n.host, n.ID)
+		g.merged[old] = n.ID
+	}
+	delete(r.deleted, old)
+
+	var pending []*Edge
+	for _, e := range r.edges {
+		if g.GetNode(g.resolve(e.parent)) == nil || g.GetNode(g.resolve(e.child)) == nil {
+			pending = append(pending, e)
+			continue
+		}
+		g.AddEdge(e)
+	}
+	r.edges = pending
+}
+
+// done tells whether all the deleted nodes were re-added and their edges
+// restored
+func (r *hostResync) done() bool {
+	return len(r.deleted) == 0 && len(r.edges) == 0
+}
+
+// delHostSubGraph deletes the nodes of the host of n reachable from n
+// through nodes of this host, n excepted, returning them along with the
+// edges linking them not created by this host
+func (g *Graph) delHostSubGraph(n *Node) ([]*Node, []*Edge) {
+	visited := map[Identifier]bool{n.ID: true}
+	var nodes []*Node
+
+	queue := []*Node{n}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		for _, e := range g.backend.GetNodeEdges(current) {
+			parent, child := g.backend.GetEdgeNodes(e)
+			for _, o := range []*Node{parent, child} {
+				if o != nil && !visited[o.ID] && o.host == n.host {
+					visited[o.ID] = true
+					nodes = append(nodes, o)
+					queue = append(queue, o)
+				}
+			}
+		}
+	}
+
+	var edges []*Edge
+	seen := make(map[Identifier]bool)
+	for _, o := range nodes {
+		for _, e := range g.backend.GetNodeEdges(o) {
+			if e.host != n.host && !seen[e.ID] {
+				seen[e.ID] = true
+				edges = append(edges, e)
+			}
+		}
+	}
+
+	for _, o := range nodes {
+		g.DelNode(o)
+	}
+
+	return nodes, edges
+}
@@ -0,0 +1,184 @@
+/*
+ * Copyright (C) 2015 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package graph
+
+import (
+	"encoding/json"
+	"testing"
+
+	shttp "github.com/redhat-cip/skydive/http"
+)
+
+func TestStableIDStrategy(t *testing.T) {
+	s := &StableIDStrategy{Recreated: RecreatedNew}
+	key := Metadata{"Name": "eth0", "MAC": "aa:bb:cc:dd:ee:ff"}
+
+	id := s.NodeID("host1", key)
+	if s.NodeID("host1", Metadata{"MAC": "aa:bb:cc:dd:ee:ff", "Name": "eth0"}) != id {
+		t.Error("Expected the same identifier for the same key")
+	}
+	if s.NodeID("host2", key) == id {
+		t.Error("Expected another identifier on another host")
+	}
+	if s.NodeID("host1", Metadata{"Name": "eth0", "MAC": "aa:bb:cc:dd:ee:00"}) == id {
+		t.Error("Expected a new identifier for a new MAC with the new policy")
+	}
+
+	s.Recreated = RecreatedKeep
+	if s.NodeID("host1", key) != s.NodeID("host1", Metadata{"Name": "eth0", "MAC": "aa:bb:cc:dd:ee:00"}) {
+		t.Error("Expected the same identifier for a new MAC with the keep policy")
+	}
+
+	if s.NodeID("host1", nil) == s.NodeID("host1", nil) {
+		t.Error("Expected random identifiers without key")
+	}
+}
+
+func TestGenNodeIDExisting(t *testing.T) {
+	g := newGraph(t)
+	g.SetIDStrategy(&StableIDStrategy{})
+
+	key := Metadata{"Name": "eth0"}
+	n := g.NewNode(g.GenNodeID(key), key)
+	if id := g.GenNodeID(key); id == n.ID {
+		t.Error("Expected a random identifier when the stable one is taken")
+	}
+}
+
+// newAgentGraph returns the graph of an agent of host holding its host node
+// and the interface eth0
+func newAgentGraph(t *testing.T, host string, strategy IDStrategy, mac string) (*Graph, *Node) {
+	g := newGraph(t)
+	g.host = host
+	g.SetIDStrategy(strategy)
+
+	root := g.NewNode(Identifier(host), Metadata{"Name": host, "Type": "host"})
+	eth0 := g.NewNode(g.GenNodeID(Metadata{"Root": host, "Name": "eth0", "MAC": mac}),
+		Metadata{"Name": "eth0", "Type": "device", "MAC": mac})
+	g.Link(root, eth0, Metadata{"RelationType": "ownership"})
+
+	return g, eth0
+}
+
+// resync sends to the server the messages of the resync of an agent
+func resync(s *GraphServer, g *Graph) {
+	send := func(msgType string, obj *json.RawMessage) {
+		s.OnMessage(nil, shttp.WSMessage{Namespace: Namespace, Type: msgType, Obj: obj})
+	}
+
+	send("SubGraphDeleted", g.GetNode(Identifier(g.host)).JsonRawMessage())
+	for _, n := range g.GetNodes() {
+		send("NodeAdded", n.JsonRawMessage())
+	}
+	for _, e := range g.GetEdges() {
+		send("EdgeAdded", e.JsonRawMessage())
+	}
+}
+
+func newResyncTestServer(t *testing.T, recreated string) (*GraphServer, *Node) {
+	g := newGraph(t)
+	s := &GraphServer{Graph: g, Recreated: recreated, resyncs: make(map[string]*hostResync)}
+
+	// the rack the user attached to eth0
+	rack := g.NewNode(GenID(), Metadata{"Name": "rack1", OriginKey: "user"})
+	return s, rack
+}
+
+// userEdgeChild returns the node the rack is linked to, nil if none
+func userEdgeChild(s *GraphServer, rack *Node) *Node {
+	for _, e := range s.Graph.backend.GetNodeEdges(rack) {
+		if _, child := s.Graph.GetEdgeNodes(e); child != nil && child.ID != rack.ID {
+			return child
+		}
+	}
+	return nil
+}
+
+func TestResyncStableIdentity(t *testing.T) {
+	s, rack := newResyncTestServer(t, RecreatedNew)
+
+	// an agent giving random identifiers
+	agent, eth0 := newAgentGraph(t, "agent1", &RandomIDStrategy{}, "aa:bb:cc:dd:ee:ff")
+	resync(s, agent)
+
+	s.Graph.Link(rack, s.Graph.GetNode(eth0.ID), Metadata{"RelationType": "membership", OriginKey: "user"})
+
+	// restarted with stable identifiers
+	agent, stable := newAgentGraph(t, "agent1", &StableIDStrategy{Recreated: RecreatedNew}, "aa:bb:cc:dd:ee:ff")
+	resync(s, agent)
+
+	if s.Graph.GetNode(eth0.ID) != nil {
+		t.Errorf("The node of the previous identifier should have been replaced: %s", s.Graph.String())
+	}
+	if child := userEdgeChild(s, rack); child == nil || child.ID != stable.ID {
+		t.Fatalf("Expected the rack to be linked to %s: %s", stable.ID, s.Graph.String())
+	}
+	if s.Graph.resolve(eth0.ID) != stable.ID {
+		t.Errorf("Expected the previous identifier to designate %s", stable.ID)
+	}
+
+	// restarted again, the identifier doesn't change
+	agent, again := newAgentGraph(t, "agent1", &StableIDStrategy{Recreated: RecreatedNew}, "aa:bb:cc:dd:ee:ff")
+	resync(s, agent)
+
+	if again.ID != stable.ID {
+		t.Fatalf("Expected the identifier %s to be stable, got %s", stable.ID, again.ID)
+	}
+	if child := userEdgeChild(s, rack); child == nil || child.ID != stable.ID {
+		t.Fatalf("Expected the rack to be linked to %s: %s", stable.ID, s.Graph.String())
+	}
+	if len(s.Graph.GetNodes()) != 3 {
+		t.Errorf("Expected the host, eth0 and the rack, got %s", s.Graph.String())
+	}
+}
+
+func TestResyncRecreatedInterface(t *testing.T) {
+	for _, test := range []struct {
+		policy string
+		kept   bool
+	}{
+		{RecreatedNew, false},
+		{RecreatedKeep, true},
+	} {
+		s, rack := newResyncTestServer(t, test.policy)
+		strategy := &StableIDStrategy{Recreated: test.policy}
+
+		agent, eth0 := newAgentGraph(t, "agent1", strategy, "aa:bb:cc:dd:ee:ff")
+		resync(s, agent)
+		s.Graph.Link(rack, s.Graph.GetNode(eth0.ID), Metadata{"RelationType": "membership", OriginKey: "user"})
+
+		// eth0 recreated with a new MAC
+		agent, recreated := newAgentGraph(t, "agent1", strategy, "aa:bb:cc:dd:ee:00")
+		resync(s, agent)
+
+		if (recreated.ID == eth0.ID) != test.kept {
+			t.Errorf("%s policy: unexpected identifier %s, previous one %s", test.policy, recreated.ID, eth0.ID)
+		}
+		if child := userEdgeChild(s, rack); (child != nil && child.ID == recreated.ID) != test.kept {
+			t.Errorf("%s policy: unexpected user edge: %s", test.policy, s.Graph.String())
+		}
+		if s.Graph.GetNode(rack.ID) == nil {
+			t.Errorf("%s policy: the user node should survive the resync", test.policy)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package graph
+
+import (
+	"github.com/redhat-cip/skydive/common"
+	"github.com/redhat-cip/skydive/config"
+)
+
+// MetadataKeyCatalog maintains the catalog of the metadata keys of the
+// nodes. The added nodes are always observed while only a sample of the
+// updates is, a key appearing on updates being reported after at most
+// sampling updates.
+type MetadataKeyCatalog struct {
+	DefaultGraphListener
+	*common.KeyCatalog
+}
+
+func (c *MetadataKeyCatalog) OnNodeAdded(n *Node) {
+	c.Observe(n.metadata)
+}
+
+func (c *MetadataKeyCatalog) OnNodeUpdated(n *Node) {
+	if c.Sample() {
+		c.Observe(n.metadata)
+	}
+}
+
+// NewMetadataKeyCatalog returns a catalog of the keys of the nodes of the
+// graph, observing the nodes already there
+func NewMetadataKeyCatalog(g *Graph, sampling int, maxExamples int, maxKeys int) *MetadataKeyCatalog {
+	c := &MetadataKeyCatalog{KeyCatalog: common.NewKeyCatalog(sampling, maxExamples, maxKeys)}
+
+	g.Lock()
+	for _, n := range g.GetNodes() {
+		c.Observe(n.metadata)
+	}
+	g.eventListeners = append(g.eventListeners, c)
+	g.Unlock()
+
+	return c
+}
+
+// NewMetadataKeyCatalogFromConfig returns the catalog of the keys of the
+// graph configured by <service>.key_catalog
+func NewMetadataKeyCatalogFromConfig(service string, g *Graph) *MetadataKeyCatalog {
+	cfg := config.GetConfig()
+	return NewMetadataKeyCatalog(g, cfg.GetInt(service+".key_catalog.sampling"),
+		cfg.GetInt(service+".key_catalog.max_examples"), cfg.GetInt(service+".key_catalog.max_keys"))
+}
@@ -0,0 +1,118 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package graph
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/redhat-cip/skydive/common"
+)
+
+func keyInfo(c *MetadataKeyCatalog, key string) *common.KeyInfo {
+	keys, _ := c.Keys()
+	for _, info := range keys {
+		if info.Key == key {
+			return &info
+		}
+	}
+	return nil
+}
+
+func TestMetadataKeyCatalog(t *testing.T) {
+	g := newGraph(t)
+	g.NewNode(GenID(), Metadata{"Name": "eth0", "Type": "device"})
+
+	c := NewMetadataKeyCatalog(g, 4, 2, 0)
+	_, version := c.Keys()
+
+	// the nodes added are always observed, the nested keys being dotted
+	n := g.NewNode(GenID(), Metadata{"Name": "eth1", "MTU": int64(1500), "IPV4": []string{"10.0.0.1/24"},
+		"Ovs": map[string]interface{}{"Port": "port1"}})
+	n2 := g.NewNode(GenID(), Metadata{"Name": "eth2", "Type": "device"})
+
+	for key, types := range map[string][]string{
+		"Name": {"string"}, "Type": {"string"}, "MTU": {"number"}, "IPV4": {"array"}, "Ovs.Port": {"string"},
+	} {
+		info := keyInfo(c, key)
+		if info == nil {
+			t.Fatalf("Key %s not in the catalog", key)
+		}
+		if !reflect.DeepEqual(info.Types, types) {
+			t.Errorf("Expected the types %v of %s, got %v", types, key, info.Types)
+		}
+	}
+
+	// the examples are bounded per key
+	if info := keyInfo(c, "Name"); info.Count != 3 || len(info.Examples) != 2 {
+		t.Errorf("Expected 3 occurrences and 2 examples of Name, got %+v", info)
+	}
+	if info := keyInfo(c, "IPV4"); len(info.Examples) != 0 {
+		t.Errorf("Expected no example of an array, got %v", info.Examples)
+	}
+
+	_, v := c.Keys()
+	if v == version {
+		t.Error("Expected a new version of the catalog")
+	}
+	version = v
+
+	// a key appearing on updates is reported within sampling writes
+	for i := 0; i < 4; i++ {
+		g.AddMetadata(n2, "Seq", int64(i))
+	}
+	if info := keyInfo(c, "Seq"); info == nil || info.Count != 1 || info.Examples[0] != int64(3) {
+		t.Fatalf("Expected Seq to be observed within 4 updates, got %+v", info)
+	}
+
+	// the counts only don't change the version
+	g.NewNode(GenID(), Metadata{"Name": "eth0"})
+	if _, v = c.Keys(); v == version {
+		t.Error("Expected a new version of the catalog")
+	}
+	version = v
+	g.NewNode(GenID(), Metadata{"Name": "eth0"})
+	if _, v = c.Keys(); v != version {
+		t.Errorf("Expected the version %s to be kept, got %s", version, v)
+	}
+	for i := 0; i < 4; i++ {
+		g.AddMetadata(n, "MTU", fmt.Sprintf("%d", 9000+i))
+	}
+	if info := keyInfo(c, "MTU"); info == nil || len(info.Types) != 2 {
+		t.Errorf("Expected MTU to be a number or a string, got %+v", info)
+	}
+}
+
+func TestMetadataKeyCatalogMaxKeys(t *testing.T) {
+	g := newGraph(t)
+	c := NewMetadataKeyCatalog(g, 1, 5, 2)
+
+	g.NewNode(GenID(), Metadata{"Name": "eth0"})
+	g.NewNode(GenID(), Metadata{"Type": "device"})
+	g.NewNode(GenID(), Metadata{"MTU": 1500})
+
+	if keys, _ := c.Keys(); len(keys) != 2 {
+		t.Errorf("Expected the catalog to be bounded to 2 keys, got %v", keys)
+	}
+}
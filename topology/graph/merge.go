@@ -0,0 +1,155 @@
+/*
+ * Copyright (C) 2015 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package graph
+
+import (
+	"strings"
+
+	"github.com/redhat-cip/skydive/config"
+	"github.com/redhat-cip/skydive/logging"
+)
+
+// MergeRule coalesces the nodes having the same values for all the
+// metadata keys of the rule, the MAC address of an interface reported under
+// different identifiers for instance
+type MergeRule []string
+
+// metadata returns the values of the node for the keys of the rule, false
+// if the node doesn't have all of them
+func (r MergeRule) metadata(n *Node) (Metadata, bool) {
+	if len(r) == 0 {
+		return nil, false
+	}
+
+	m := Metadata{}
+	for _, k := range r {
+		v, ok := n.metadata[k]
+		if !ok || v == "" {
+			return nil, false
+		}
+		m[k] = v
+	}
+	return m, true
+}
+
+// resolve returns the identifier of the node a merged node was coalesced in
+func (g *Graph) resolve(i Identifier) Identifier {
+	if id, ok := g.merged[i]; ok {
+		return id
+	}
+	return i
+}
+
+// mergeTarget returns the node the added node has to be coalesced in, if
+// any
+func (g *Graph) mergeTarget(n *Node) *Node {
+	for _, rule := range g.mergeRules {
+		m, ok := rule.metadata(n)
+		if !ok {
+			continue
+		}
+
+		for _, o := range g.backend.GetNodes() {
+			if o.ID != n.ID && o.matchMetadata(m) {
+				return o
+			}
+		}
+	}
+	return nil
+}
+
+// merge coalesces the node n in the node into, the edges of n being
+// re-pointed to into and the metadata into doesn't have copied from n. The
+// identifier of n keeps designating into so that the edges added later
+// between n and other nodes are linked to into.
+func (g *Graph) merge(into *Node, n *Node) {
+	logging.GetLogger().Debugf("Merging node %s in %s", n.ID, into.ID)
+
+	if g.backend.GetNode(n.ID) != nil {
+		for _, e := range g.backend.GetNodeEdges(n) {
+			parent, child := g.backend.GetEdgeNodes(e)
+			if parent == nil || child == nil {
+				continue
+			}
+
+			g.DelEdge(e)
+
+			if parent.ID == n.ID && child.ID != into.ID {
+				g.Link(into, child, e.metadata)
+			} else if child.ID == n.ID && parent.ID != into.ID {
+				g.Link(parent, into, e.metadata)
+			}
+		}
+
+		if g.backend.DelNode(n) {
+			g.NotifyNodeDeleted(n)
+		}
+	}
+
+	g.merged[n.ID] = into.ID
+
+	m := Metadata{}
+	for k, v := range into.metadata {
+		m[k] = v
+	}
+
+	updated := false
+	for k, v := range n.metadata {
+		if _, ok := m[k]; !ok {
+			m[k] = v
+			updated = true
+		}
+	}
+
+	if updated {
+		g.SetMetadata(into, m)
+	}
+}
+
+// forgetMerged drops the identifiers of the nodes merged in the deleted
+// node
+func (g *Graph) forgetMerged(n *Node) {
+	for from, to := range g.merged {
+		if to == n.ID {
+			delete(g.merged, from)
+		}
+	}
+}
+
+// MergeRulesFromConfig returns the rules of graph.merge_rules, each rule
+// being a comma separated list of metadata keys
+func MergeRulesFromConfig() []MergeRule {
+	var rules []MergeRule
+	for _, r := range config.GetConfig().GetStringSlice("graph.merge_rules") {
+		var rule MergeRule
+		for _, k := range strings.Split(r, ",") {
+			if k = strings.TrimSpace(k); k != "" {
+				rule = append(rule, k)
+			}
+		}
+		if len(rule) > 0 {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
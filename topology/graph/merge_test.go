@@ -0,0 +1,122 @@
+/*
+ * Copyright (C) 2015 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package graph
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/redhat-cip/skydive/config"
+)
+
+func TestMergeOnAdd(t *testing.T) {
+	g := newGraph(t)
+	g.mergeRules = []MergeRule{{"MAC"}}
+
+	n1 := g.NewNode(GenID(), Metadata{"Name": "eth0", "MAC": "aa:bb:cc:dd:ee:ff"})
+	c1 := g.NewNode(GenID(), Metadata{"Name": "ovs"})
+	g.Link(n1, c1)
+
+	// the same interface reported by its index
+	n2 := g.NewNode(GenID(), Metadata{"IfIndex": 2, "MAC": "aa:bb:cc:dd:ee:ff"})
+	if n2 != n1 {
+		t.Fatalf("Expected the node to be merged in %s, got %s", n1.ID, n2.ID)
+	}
+
+	c2 := g.NewNode(GenID(), Metadata{"Name": "netns"})
+	n3 := &Node{graphElement: graphElement{ID: GenID(), metadata: Metadata{"MAC": "aa:bb:cc:dd:ee:ff", "Name": "eth1"}}}
+	if !g.AddNode(n3) {
+		t.Fatal("Expected the merged node to be added")
+	}
+	g.NewEdge(GenID(), c2, n3, nil)
+	g.NewEdge(GenID(), n1, n3, nil)
+
+	if nodes := g.LookupNodes(Metadata{"MAC": "aa:bb:cc:dd:ee:ff"}); len(nodes) != 1 {
+		t.Fatalf("Expected a single node, got %v", nodes)
+	}
+	if g.GetNode(n3.ID) != nil {
+		t.Error("The merged node shouldn't be in the graph")
+	}
+
+	if !g.AreLinked(n1, c1) || !g.AreLinked(n1, c2) || len(g.GetEdges()) != 2 {
+		t.Errorf("Expected the edges of both nodes: %s", g.String())
+	}
+
+	expected := Metadata{"Name": "eth0", "IfIndex": 2, "MAC": "aa:bb:cc:dd:ee:ff"}
+	if !reflect.DeepEqual(n1.Metadata(), expected) {
+		t.Errorf("Expected the metadata to be combined, got %v", n1.Metadata())
+	}
+
+	g.DelNode(n1)
+	if len(g.merged) != 0 {
+		t.Errorf("Expected the merged identifiers to be forgotten, got %v", g.merged)
+	}
+}
+
+func TestMergeRepointEdges(t *testing.T) {
+	g := newGraph(t)
+
+	n1 := g.NewNode(GenID(), Metadata{"MAC": "aa:bb:cc:dd:ee:ff"})
+	n2 := g.NewNode(GenID(), Metadata{"MAC": "aa:bb:cc:dd:ee:ff"})
+	c1 := g.NewNode(GenID(), Metadata{"Name": "c1"})
+	c2 := g.NewNode(GenID(), Metadata{"Name": "c2"})
+	g.Link(n1, c1)
+	g.Link(c2, n2, Metadata{"RelationType": "layer2"})
+	g.Link(n1, n2)
+
+	// added again once the rule applies
+	g.mergeRules = []MergeRule{{"MAC"}}
+	g.AddNode(n2)
+
+	if g.GetNode(n2.ID) != nil || len(g.GetNodes()) != 3 {
+		t.Fatalf("Expected the nodes to be merged: %s", g.String())
+	}
+	if !g.AreLinked(n1, c1) || !g.AreLinked(c2, n1) || len(g.GetEdges()) != 2 {
+		t.Fatalf("Expected the edges to be re-pointed: %s", g.String())
+	}
+
+	for _, e := range g.GetEdges() {
+		if parent, _ := g.GetEdgeNodes(e); parent.ID == c2.ID && e.Metadata()["RelationType"] != "layer2" {
+			t.Errorf("Expected the edge metadata to be kept, got %v", e.Metadata())
+		}
+	}
+}
+
+func TestMergeRulesFromConfig(t *testing.T) {
+	config.GetConfig().Set("graph.merge_rules", []string{"Type, MAC", ""})
+	defer config.GetConfig().Set("graph.merge_rules", []string{})
+
+	g := newGraph(t)
+	if !reflect.DeepEqual(g.mergeRules, []MergeRule{{"Type", "MAC"}}) {
+		t.Fatalf("Unexpected rules: %v", g.mergeRules)
+	}
+
+	g.NewNode(GenID(), Metadata{"Type": "veth", "MAC": "aa:bb:cc:dd:ee:ff"})
+	g.NewNode(GenID(), Metadata{"Type": "device", "MAC": "aa:bb:cc:dd:ee:ff"})
+	g.NewNode(GenID(), Metadata{"MAC": "aa:bb:cc:dd:ee:ff"})
+	g.NewNode(GenID(), Metadata{"Type": "veth", "MAC": "aa:bb:cc:dd:ee:ff", "Name": "eth0"})
+
+	if len(g.GetNodes()) != 3 {
+		t.Errorf("Expected only the nodes matching all the keys to be merged: %s", g.String())
+	}
+}
@@ -25,6 +25,7 @@ package graph
 import (
 	"encoding/json"
 
+	"github.com/redhat-cip/skydive/config"
 	shttp "github.com/redhat-cip/skydive/http"
 	"github.com/redhat-cip/skydive/logging"
 )
@@ -37,6 +38,10 @@ type GraphServer struct {
 	shttp.DefaultWSServerEventHandler
 	WSServer *shttp.WSServer
 	Graph    *Graph
+	// policy of the interfaces recreated with a new MAC, see identityKey
+	Recreated string
+	// what is left to restore of the last resync of each agent host
+	resyncs map[string]*hostResync
 }
 
 func UnmarshalWSMessage(msg shttp.WSMessage) (string, interface{}, error) {
@@ -107,9 +112,12 @@ func (s *GraphServer) OnMessage(c *shttp.WSClient, msg shttp.WSMessage) {
 
 		logging.GetLogger().Debugf("Got SubGraphDeleted event from the node %s", n.ID)
 
+		// the edges added by the others, the users for instance, to the
+		// nodes of the agent are restored once the agent re-adds them
 		node := s.Graph.GetNode(n.ID)
 		if node != nil {
-			s.Graph.DelSubGraph(node)
+			nodes, edges := s.Graph.delHostSubGraph(node)
+			s.resyncs[node.host] = newHostResync(nodes, edges, s.Recreated)
 		}
 	case "NodeUpdated":
 		n := obj.(*Node)
@@ -123,6 +131,13 @@ func (s *GraphServer) OnMessage(c *shttp.WSClient, msg shttp.WSMessage) {
 		n := obj.(*Node)
 		if s.Graph.GetNode(n.ID) == nil {
 			s.Graph.AddNode(n)
+
+			if r, ok := s.resyncs[n.host]; ok {
+				r.restore(s.Graph, n)
+				if r.done() {
+					delete(s.resyncs, n.host)
+				}
+			}
 		}
 	case "EdgeUpdated":
 		e := obj.(*Edge)
@@ -188,10 +203,36 @@ func (s *GraphServer) OnEdgeDeleted(e *Edge) {
 	})
 }
 
+// OnBatchApplied broadcasts the changes of a batch in a single BatchApplied
+// message holding the list of the NodeAdded, EdgeDeleted... messages.
+func (s *GraphServer) OnBatchApplied(b *GraphBatch) {
+	msgs := make([]shttp.WSMessage, len(b.Events))
+	for i, event := range b.Events {
+		msgs[i] = shttp.WSMessage{Namespace: Namespace, Type: event.Type}
+		switch e := event.Element.(type) {
+		case *Node:
+			msgs[i].Obj = e.JsonRawMessage()
+		case *Edge:
+			msgs[i].Obj = e.JsonRawMessage()
+		}
+	}
+
+	r, _ := json.Marshal(msgs)
+	raw := json.RawMessage(r)
+
+	s.WSServer.BroadcastWSMessage(shttp.WSMessage{
+		Namespace: Namespace,
+		Type:      "BatchApplied",
+		Obj:       &raw,
+	})
+}
+
 func NewServer(g *Graph, server *shttp.WSServer) *GraphServer {
 	s := &GraphServer{
-		Graph:    g,
-		WSServer: server,
+		Graph:     g,
+		WSServer:  server,
+		Recreated: config.GetConfig().GetString("graph.recreated_policy"),
+		resyncs:   make(map[string]*hostResync),
 	}
 	s.Graph.AddEventListener(s)
 	server.AddEventHandler(s)
@@ -25,6 +25,9 @@ package graph
 import (
 	"errors"
 	"fmt"
+	"regexp"
+
+	"golang.org/x/net/context"
 
 	"github.com/redhat-cip/skydive/common"
 )
@@ -35,7 +38,11 @@ type GraphTraversalStep interface {
 }
 
 type GraphTraversal struct {
-	Graph *Graph
+	Graph    *Graph
+	Scope    TraversalScope
+	writes   *writeTransaction
+	ctx      context.Context
+	warnings []string
 }
 
 type GraphTraversalV struct {
@@ -108,6 +115,28 @@ func Ne(s interface{}) *NEMetadataMatcher {
 	return &NEMetadataMatcher{value: s}
 }
 
+type RegexMetadataMatcher struct {
+	regexp *regexp.Regexp
+}
+
+func (r *RegexMetadataMatcher) Match(v interface{}) bool {
+	s, ok := v.(string)
+	if !ok {
+		return false
+	}
+
+	return r.regexp.MatchString(s)
+}
+
+func Regex(expr string) (*RegexMetadataMatcher, error) {
+	r, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RegexMetadataMatcher{regexp: r}, nil
+}
+
 func sliceToMetadata(s ...interface{}) (Metadata, error) {
 	m := Metadata{}
 	if len(s)%2 != 0 {
@@ -130,6 +159,20 @@ func NewGrahTraversal(g *Graph) *GraphTraversal {
 	return &GraphTraversal{Graph: g}
 }
 
+// graphTraversalOf returns the traversal a step starts from, V() being
+// allowed in the middle of a traversal
+func graphTraversalOf(last GraphTraversalStep) *GraphTraversal {
+	switch tv := last.(type) {
+	case *GraphTraversal:
+		return tv
+	case *GraphTraversalV:
+		return tv.GraphTraversal
+	case *GraphTraversalE:
+		return tv.GraphTraversal
+	}
+	return nil
+}
+
 func (t *GraphTraversal) Values() []interface{} {
 	return []interface{}{t.Graph}
 }
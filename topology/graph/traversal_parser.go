@@ -26,7 +26,10 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"reflect"
 	"strconv"
+
+	"golang.org/x/net/context"
 )
 
 type (
@@ -78,8 +81,8 @@ func (s *gremlinTraversalStepG) Exec(last GraphTraversalStep) (GraphTraversalSte
 }
 
 func (s *gremlinTraversalStepV) Exec(last GraphTraversalStep) (GraphTraversalStep, error) {
-	g, ok := last.(*GraphTraversal)
-	if !ok {
+	g := graphTraversalOf(last)
+	if g == nil {
 		return nil, ExecutionError
 	}
 
@@ -256,12 +259,24 @@ func (s *GremlinTraversalSequence) nextStepToExec(i int) (GremlinTraversalStep,
 }
 
 func (s *GremlinTraversalSequence) Exec() (GraphTraversalStep, error) {
+	return s.ExecContext(context.Background())
+}
+
+// ExecContext executes the sequence, stopped between two steps once the
+// context is done, before committing the writes
+func (s *GremlinTraversalSequence) ExecContext(ctx context.Context) (GraphTraversalStep, error) {
 	var step GremlinTraversalStep
 	var last GraphTraversalStep
 	var err error
 
+	s.GraphTraversal.ctx = ctx
+
 	last = s.GraphTraversal
 	for i := 0; i != -1; {
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+
 		step, i = s.nextStepToExec(i)
 
 		if last, err = step.Exec(last); err != nil {
@@ -274,6 +289,15 @@ func (s *GremlinTraversalSequence) Exec() (GraphTraversalStep, error) {
 		return nil, ExecutionError
 	}
 
+	if w := s.GraphTraversal.writes; w != nil && len(w.ops) > 0 {
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err = w.commit(s.GraphTraversal.Graph); err != nil {
+			return nil, err
+		}
+	}
+
 	return res, nil
 }
 
@@ -343,6 +367,23 @@ func (p *GremlinTraversalParser) parserStepParams() (GremlinTraversalStepParams,
 				return nil, fmt.Errorf("One parameter expected to EQ: %v", withParams)
 			}
 			params = append(params, Ne(withParams[0]))
+		case REGEX:
+			withParams, err := p.parserStepParams()
+			if err != nil {
+				return nil, err
+			}
+			if len(withParams) != 1 {
+				return nil, fmt.Errorf("One parameter expected to REGEX: %v", withParams)
+			}
+			expr, ok := withParams[0].(string)
+			if !ok {
+				return nil, fmt.Errorf("String expected as REGEX parameter: %v", withParams[0])
+			}
+			matcher, err := Regex(expr)
+			if err != nil {
+				return nil, err
+			}
+			params = append(params, matcher)
 		default:
 			return nil, fmt.Errorf("Unexpected token while parsing parameters, got: %s", lit)
 		}
@@ -394,6 +435,52 @@ func (p *GremlinTraversalParser) parserStep() (GremlinTraversalStep, error) {
 		return &gremlinTraversalStepShortestPathTo{params: params}, nil
 	case BOTH:
 		return &gremlinTraversalStepBoth{params: params}, nil
+	case ADDV:
+		return &gremlinTraversalStepAddV{params: params}, nil
+	case ADDE:
+		if len(params) != 3 {
+			return nil, fmt.Errorf("AddE predicate accept only 3 parameters")
+		}
+		return &gremlinTraversalStepAddE{params: params}, nil
+	case PROPERTY:
+		if len(params) != 2 {
+			return nil, fmt.Errorf("Property predicate accept only 2 parameters")
+		}
+		return &gremlinTraversalStepProperty{params: params}, nil
+	case DROP:
+		if len(params) != 0 {
+			return nil, fmt.Errorf("Drop predicate accept no parameter")
+		}
+		return &gremlinTraversalStepDrop{}, nil
+	case AT:
+		t, err := parseTraversalTime(params)
+		if err != nil {
+			return nil, err
+		}
+		return &gremlinTraversalStepAt{time: t}, nil
+	case CAPTURE:
+		if len(params) != 1 {
+			return nil, fmt.Errorf("Capture predicate accept only 1 parameter")
+		}
+		capture, ok := params[0].(string)
+		if !ok || capture == "" {
+			return nil, fmt.Errorf("Capture predicate expects a capture identifier, got: %v", params[0])
+		}
+		return &gremlinTraversalStepCapture{capture: capture}, nil
+	case LIMIT:
+		if len(params) != 1 {
+			return nil, fmt.Errorf("Limit predicate accept only 1 parameter")
+		}
+		limit, ok := params[0].(int64)
+		if !ok || limit < 0 {
+			return nil, fmt.Errorf("Limit predicate expects a positive integer, got: %v", params[0])
+		}
+		return &gremlinTraversalStepLimit{limit: int(limit)}, nil
+	case COUNT:
+		if len(params) != 0 {
+			return nil, fmt.Errorf("Count predicate accept no parameter")
+		}
+		return &gremlinTraversalStepCount{}, nil
 	}
 
 	// extensions
@@ -437,6 +524,18 @@ func (p *GremlinTraversalParser) Parse() (*GremlinTraversalSequence, error) {
 		if err != nil {
 			return nil, err
 		}
+
+		// the scope applies to the whole traversal
+		if isScopeStep(step) {
+			for _, previous := range seq.steps {
+				if !isScopeStep(previous) {
+					return nil, fmt.Errorf("At and Capture have to directly follow G")
+				}
+				if reflect.TypeOf(previous) == reflect.TypeOf(step) {
+					return nil, fmt.Errorf("At and Capture can be used only once")
+				}
+			}
+		}
 		seq.steps = append(seq.steps, step)
 	}
 
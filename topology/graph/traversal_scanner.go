@@ -66,6 +66,15 @@ const (
 	SHORTESTPATHTO
 	NE
 	BOTH
+	REGEX
+	ADDV
+	ADDE
+	PROPERTY
+	DROP
+	AT
+	CAPTURE
+	LIMIT
+	COUNT
 
 	// extensions token have to start after 1000
 )
@@ -215,6 +224,24 @@ func (s *GremlinTraversalScanner) scanIdent() (tok Token, lit string) {
 		return NE, buf.String()
 	case "BOTH":
 		return BOTH, buf.String()
+	case "REGEX":
+		return REGEX, buf.String()
+	case "ADDV":
+		return ADDV, buf.String()
+	case "ADDE":
+		return ADDE, buf.String()
+	case "PROPERTY":
+		return PROPERTY, buf.String()
+	case "DROP":
+		return DROP, buf.String()
+	case "AT":
+		return AT, buf.String()
+	case "CAPTURE":
+		return CAPTURE, buf.String()
+	case "LIMIT":
+		return LIMIT, buf.String()
+	case "COUNT":
+		return COUNT, buf.String()
 	}
 
 	for _, e := range s.extensions {
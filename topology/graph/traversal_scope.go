@@ -0,0 +1,177 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package graph
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// TraversalScope scopes a traversal to a time and to a capture, set by the
+// At and Capture steps following G. The steps of the extensions returning
+// flows only return the flows of this time and of this capture.
+type TraversalScope struct {
+	Time    time.Time
+	Capture string
+}
+
+// GraphTraversalLimiter is implemented by the results of the steps that can
+// be truncated by Limit
+type GraphTraversalLimiter interface {
+	Limit(n int) GraphTraversalStep
+}
+
+// GraphTraversalValue is the result of a step computing a value, as Count
+type GraphTraversalValue struct {
+	value interface{}
+}
+
+func (v *GraphTraversalValue) Values() []interface{} {
+	return []interface{}{v.value}
+}
+
+func (v *GraphTraversalValue) Error() error {
+	return nil
+}
+
+type (
+	gremlinTraversalStepAt      struct{ time time.Time }
+	gremlinTraversalStepCapture struct{ capture string }
+	gremlinTraversalStepLimit   struct{ limit int }
+	gremlinTraversalStepCount   struct{}
+)
+
+// Context returns the context of the execution of the traversal, cancelled
+// once the traversal has to be stopped
+func (t *GraphTraversal) Context() context.Context {
+	if t.ctx == nil {
+		return context.Background()
+	}
+	return t.ctx
+}
+
+// Warnf records a warning about the result of the traversal, ex: a scope
+// that couldn't be applied
+func (t *GraphTraversal) Warnf(format string, args ...interface{}) {
+	t.warnings = append(t.warnings, fmt.Sprintf(format, args...))
+}
+
+// Warnings returns the warnings recorded while executing the traversal
+func (t *GraphTraversal) Warnings() []string {
+	return t.warnings
+}
+
+// Warnings returns the warnings recorded while executing the sequence
+func (s *GremlinTraversalSequence) Warnings() []string {
+	return s.GraphTraversal.Warnings()
+}
+
+func (tv *GraphTraversalV) Limit(n int) GraphTraversalStep {
+	if tv.error != nil || len(tv.nodes) <= n {
+		return tv
+	}
+	return &GraphTraversalV{GraphTraversal: tv.GraphTraversal, nodes: tv.nodes[:n]}
+}
+
+func (te *GraphTraversalE) Limit(n int) GraphTraversalStep {
+	if te.error != nil || len(te.edges) <= n {
+		return te
+	}
+	return &GraphTraversalE{GraphTraversal: te.GraphTraversal, edges: te.edges[:n]}
+}
+
+// parseTraversalTime parses the time of At, a RFC 3339 date or a number of
+// seconds since epoch, that can't be in the future
+func parseTraversalTime(params GremlinTraversalStepParams) (time.Time, error) {
+	if len(params) != 1 {
+		return time.Time{}, errors.New("At predicate accept only 1 parameter")
+	}
+
+	var t time.Time
+	switch p := params[0].(type) {
+	case string:
+		var err error
+		if t, err = time.Parse(time.RFC3339, p); err != nil {
+			return time.Time{}, fmt.Errorf("At predicate expects a RFC 3339 time: %s", p)
+		}
+	case int64:
+		t = time.Unix(p, 0)
+	default:
+		return time.Time{}, fmt.Errorf("At predicate expects a time, got: %v", p)
+	}
+
+	if t.After(time.Now()) {
+		return time.Time{}, fmt.Errorf("At time %s is in the future", t.UTC().Format(time.RFC3339))
+	}
+	return t, nil
+}
+
+func (s *gremlinTraversalStepAt) Exec(last GraphTraversalStep) (GraphTraversalStep, error) {
+	t, ok := last.(*GraphTraversal)
+	if !ok {
+		return nil, ExecutionError
+	}
+
+	// the backends don't keep the past states of the graph
+	t.Scope.Time = s.time
+	t.Warnf("No topology history, the current topology is used in place of the one at %s", s.time.UTC().Format(time.RFC3339))
+
+	return t, nil
+}
+
+func (s *gremlinTraversalStepCapture) Exec(last GraphTraversalStep) (GraphTraversalStep, error) {
+	t, ok := last.(*GraphTraversal)
+	if !ok {
+		return nil, ExecutionError
+	}
+
+	t.Scope.Capture = s.capture
+	return t, nil
+}
+
+func (s *gremlinTraversalStepLimit) Exec(last GraphTraversalStep) (GraphTraversalStep, error) {
+	l, ok := last.(GraphTraversalLimiter)
+	if !ok {
+		return nil, ExecutionError
+	}
+	return l.Limit(s.limit), nil
+}
+
+func (s *gremlinTraversalStepCount) Exec(last GraphTraversalStep) (GraphTraversalStep, error) {
+	if err := last.Error(); err != nil {
+		return nil, err
+	}
+	return &GraphTraversalValue{value: len(last.Values())}, nil
+}
+
+// isScopeStep returns whether the step sets the scope of the traversal
+func isScopeStep(step GremlinTraversalStep) bool {
+	switch step.(type) {
+	case *gremlinTraversalStepAt, *gremlinTraversalStepCapture:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package graph
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTraversalLimitCount(t *testing.T) {
+	g := newTrasversalGraph(t)
+
+	res := execTraversalQuery(t, g, `G.V().Limit(3)`)
+	if len(res.Values()) != 3 {
+		t.Fatalf("Should return 3 nodes, returned: %v", res.Values())
+	}
+
+	res = execTraversalQuery(t, g, `G.V().Has("Type", "intf").Count()`)
+	if len(res.Values()) != 1 || res.Values()[0] != 2 {
+		t.Fatalf("Should count 2 nodes, returned: %v", res.Values())
+	}
+
+	res = execTraversalQuery(t, g, `G.V().Limit(10).Count()`)
+	if res.Values()[0] != 4 {
+		t.Fatalf("Should count 4 nodes, returned: %v", res.Values())
+	}
+}
+
+func TestTraversalScope(t *testing.T) {
+	g := newTrasversalGraph(t)
+
+	at := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	query := `G.At("` + at.Format(time.RFC3339) + `").Capture("my-capture").V().Has("Type", "intf")`
+
+	ts, err := NewGremlinTraversalParser(strings.NewReader(query), g).Parse()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	res, err := ts.Exec()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// no history, the nodes of the current topology are returned
+	if len(res.Values()) != 2 {
+		t.Fatalf("Should return 2 nodes, returned: %v", res.Values())
+	}
+	if len(ts.Warnings()) != 1 {
+		t.Fatalf("Expected a warning about the topology history, got: %v", ts.Warnings())
+	}
+
+	scope := ts.GraphTraversal.Scope
+	if !scope.Time.Equal(at) || scope.Capture != "my-capture" {
+		t.Fatalf("Unexpected scope: %+v", scope)
+	}
+}
+
+func TestTraversalScopeErrors(t *testing.T) {
+	g := newTrasversalGraph(t)
+
+	future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	for _, query := range []string{
+		`G.At("` + future + `").V()`,
+		`G.At("yesterday").V()`,
+		`G.At(1, 2).V()`,
+		`G.Capture(1).V()`,
+		`G.V().At(1464750000)`,
+		`G.At(1464750000).At(1464750000).V()`,
+		`G.V().Limit(-1)`,
+		`G.V().Count(1)`,
+	} {
+		if _, err := NewGremlinTraversalParser(strings.NewReader(query), g).Parse(); err == nil {
+			t.Errorf("Expected a parse error for %s", query)
+		}
+	}
+}
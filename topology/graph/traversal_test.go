@@ -25,6 +25,8 @@ package graph
 import (
 	"strings"
 	"testing"
+
+	"golang.org/x/net/context"
 )
 
 func newTrasversalGraph(t *testing.T) *Graph {
@@ -311,6 +313,20 @@ func TestTraversalParser(t *testing.T) {
 		t.Fatalf("Should return 1 node, returned: %v", res.Values())
 	}
 
+	// next traversal test
+	query = `G.V().Has("Name", Regex("^N.*"))`
+	res = execTraversalQuery(t, g, query)
+	if len(res.Values()) != 1 {
+		t.Fatalf("Should return 1 node, returned: %v", res.Values())
+	}
+
+	// next traversal test
+	query = `G.V().Has("Name", Regex("^X"))`
+	res = execTraversalQuery(t, g, query)
+	if len(res.Values()) != 0 {
+		t.Fatalf("Should return no node, returned: %v", res.Values())
+	}
+
 	// next traversal test
 	query = `G.V().Has("Value", 2).Both()`
 	res = execTraversalQuery(t, g, query)
@@ -318,3 +334,19 @@ func TestTraversalParser(t *testing.T) {
 		t.Fatalf("Should return 2 nodes, returned: %v", res.Values())
 	}
 }
+
+func TestTraversalExecContext(t *testing.T) {
+	g := newTrasversalGraph(t)
+
+	ts, err := NewGremlinTraversalParser(strings.NewReader(`G.V().Has("Type", "intf")`), g).Parse()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err = ts.ExecContext(ctx); err != context.Canceled {
+		t.Fatalf("Expected the query to be cancelled, got: %v", err)
+	}
+}
@@ -0,0 +1,400 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package graph
+
+import (
+	"errors"
+	"fmt"
+)
+
+// OriginKey is the metadata key giving the origin of the elements created by
+// the write steps
+const OriginKey = "Origin"
+
+var (
+	ErrWriteNotPermitted = errors.New("Write steps are not permitted")
+)
+
+type (
+	gremlinTraversalStepAddV     struct{ params GremlinTraversalStepParams }
+	gremlinTraversalStepAddE     struct{ params GremlinTraversalStepParams }
+	gremlinTraversalStepProperty struct{ params GremlinTraversalStepParams }
+	gremlinTraversalStepDrop     struct{}
+)
+
+// OriginError is returned when a write step targets an element not belonging
+// to the writable origin, a node reported by an agent for instance.
+type OriginError struct {
+	ID     Identifier
+	Origin string
+}
+
+func (e *OriginError) Error() string {
+	return fmt.Sprintf("%s belongs to %s and can't be modified", e.ID, e.Origin)
+}
+
+type writeOp struct {
+	kind string
+	node *Node
+	edge *Edge
+}
+
+// writeTransaction stages the changes of the write steps, applied all at once
+// if the whole sequence succeeds.
+type writeTransaction struct {
+	origin   string
+	ops      []writeOp
+	staged   map[Identifier]bool
+	dropped  map[Identifier]bool
+	metadata map[Identifier]Metadata
+}
+
+func newWriteTransaction(origin string) *writeTransaction {
+	return &writeTransaction{
+		origin:   origin,
+		staged:   make(map[Identifier]bool),
+		dropped:  make(map[Identifier]bool),
+		metadata: make(map[Identifier]Metadata),
+	}
+}
+
+func (t *writeTransaction) checkOrigin(g *Graph, e *graphElement) error {
+	if t.staged[e.ID] {
+		return nil
+	}
+
+	origin, _ := e.metadata[OriginKey].(string)
+	if origin != t.origin || e.host != g.host {
+		if origin == "" {
+			origin = "agent " + e.host
+		}
+		return &OriginError{ID: e.ID, Origin: origin}
+	}
+
+	return nil
+}
+
+func (t *writeTransaction) newMetadata(params GremlinTraversalStepParams) (Metadata, error) {
+	var m Metadata
+	if len(params) == 1 {
+		metadata, ok := params[0].(Metadata)
+		if !ok {
+			return nil, fmt.Errorf("Metadata expected, got: %v", params[0])
+		}
+		m = make(Metadata)
+		for k, v := range metadata {
+			m[k] = v
+		}
+	} else {
+		var err error
+		if m, err = sliceToMetadata(params...); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, ok := m[OriginKey]; ok {
+		return nil, fmt.Errorf("%s can't be set", OriginKey)
+	}
+	m[OriginKey] = t.origin
+
+	return m, nil
+}
+
+func (t *writeTransaction) lookupNode(g *Graph, id Identifier) *Node {
+	if t.dropped[id] {
+		return nil
+	}
+
+	for _, op := range t.ops {
+		if op.kind == "addNode" && op.node.ID == id {
+			return op.node
+		}
+	}
+
+	return g.GetNode(id)
+}
+
+func (t *writeTransaction) setProperty(g *Graph, e *graphElement, op writeOp, k string, v interface{}) error {
+	if err := t.checkOrigin(g, e); err != nil {
+		return err
+	}
+
+	if t.staged[e.ID] {
+		e.metadata[k] = v
+		return nil
+	}
+
+	m, ok := t.metadata[e.ID]
+	if !ok {
+		m = make(Metadata)
+		for k, v := range e.metadata {
+			m[k] = v
+		}
+		t.metadata[e.ID] = m
+		t.ops = append(t.ops, op)
+	}
+	m[k] = v
+
+	return nil
+}
+
+func (t *writeTransaction) drop(g *Graph, e *graphElement, op writeOp) error {
+	if err := t.checkOrigin(g, e); err != nil {
+		return err
+	}
+
+	if !t.dropped[e.ID] {
+		t.dropped[e.ID] = true
+		t.ops = append(t.ops, op)
+	}
+
+	return nil
+}
+
+// check verifies, with the graph lock held, that the elements changed by the
+// transaction still exist and are still writable
+func (t *writeTransaction) check(g *Graph) error {
+	for _, op := range t.ops {
+		switch op.kind {
+		case "addEdge":
+			for _, id := range []Identifier{op.edge.parent, op.edge.child} {
+				if !t.staged[id] && g.GetNode(id) == nil {
+					return fmt.Errorf("Node %s doesn't exist anymore", id)
+				}
+			}
+		case "setNodeMetadata", "delNode":
+			if t.staged[op.node.ID] {
+				continue
+			}
+			n := g.GetNode(op.node.ID)
+			if n == nil {
+				return fmt.Errorf("Node %s doesn't exist anymore", op.node.ID)
+			}
+			if err := t.checkOrigin(g, &n.graphElement); err != nil {
+				return err
+			}
+		case "setEdgeMetadata", "delEdge":
+			if t.staged[op.edge.ID] {
+				continue
+			}
+			e := g.GetEdge(op.edge.ID)
+			if e == nil {
+				return fmt.Errorf("Edge %s doesn't exist anymore", op.edge.ID)
+			}
+			if err := t.checkOrigin(g, &e.graphElement); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// commit applies the staged changes as a single graph batch if they can all
+// be applied, none otherwise
+func (t *writeTransaction) commit(g *Graph) error {
+	g.Lock()
+	defer g.Unlock()
+
+	if err := t.check(g); err != nil {
+		return err
+	}
+
+	g.Batch(func() {
+		for _, op := range t.ops {
+			switch op.kind {
+			case "addNode":
+				g.AddNode(op.node)
+			case "addEdge":
+				g.AddEdge(op.edge)
+			case "setNodeMetadata":
+				if n := g.GetNode(op.node.ID); n != nil {
+					g.SetMetadata(n, t.metadata[n.ID])
+				}
+			case "setEdgeMetadata":
+				if e := g.GetEdge(op.edge.ID); e != nil {
+					g.SetMetadata(e, t.metadata[e.ID])
+				}
+			case "delNode":
+				if n := g.GetNode(op.node.ID); n != nil {
+					g.DelNode(n)
+				}
+			case "delEdge":
+				if e := g.GetEdge(op.edge.ID); e != nil {
+					g.DelEdge(e)
+				}
+			}
+		}
+	})
+
+	return nil
+}
+
+func (s *gremlinTraversalStepAddV) Exec(last GraphTraversalStep) (GraphTraversalStep, error) {
+	gt := graphTraversalOf(last)
+	if gt == nil {
+		return nil, ExecutionError
+	}
+
+	t := gt.writes
+	if t == nil {
+		return nil, ErrWriteNotPermitted
+	}
+
+	m, err := t.newMetadata(s.params)
+	if err != nil {
+		return nil, err
+	}
+
+	n := &Node{graphElement: graphElement{ID: GenID(), metadata: m, host: gt.Graph.host}}
+	t.staged[n.ID] = true
+	t.ops = append(t.ops, writeOp{kind: "addNode", node: n})
+
+	return &GraphTraversalV{GraphTraversal: gt, nodes: []*Node{n}}, nil
+}
+
+func (s *gremlinTraversalStepAddE) Exec(last GraphTraversalStep) (GraphTraversalStep, error) {
+	gt := graphTraversalOf(last)
+	if gt == nil {
+		return nil, ExecutionError
+	}
+
+	t := gt.writes
+	if t == nil {
+		return nil, ErrWriteNotPermitted
+	}
+
+	var ids []Identifier
+	for _, param := range s.params {
+		id, ok := param.(string)
+		if !ok {
+			return nil, fmt.Errorf("AddE expects string parameters: %v", s.params)
+		}
+		ids = append(ids, Identifier(id))
+	}
+
+	parent, child := t.lookupNode(gt.Graph, ids[1]), t.lookupNode(gt.Graph, ids[2])
+	if parent == nil || child == nil {
+		return nil, fmt.Errorf("AddE nodes not found: %s, %s", ids[1], ids[2])
+	}
+
+	e := &Edge{
+		graphElement: graphElement{
+			ID:       GenID(),
+			metadata: Metadata{"RelationType": string(ids[0]), OriginKey: t.origin},
+			host:     gt.Graph.host,
+		},
+		parent: parent.ID,
+		child:  child.ID,
+	}
+	t.staged[e.ID] = true
+	t.ops = append(t.ops, writeOp{kind: "addEdge", edge: e})
+
+	return &GraphTraversalE{GraphTraversal: gt, edges: []*Edge{e}}, nil
+}
+
+func (s *gremlinTraversalStepProperty) Exec(last GraphTraversalStep) (GraphTraversalStep, error) {
+	k, ok := s.params[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("Property key should be a string: %v", s.params[0])
+	}
+	if k == OriginKey {
+		return nil, fmt.Errorf("%s can't be set", OriginKey)
+	}
+	v := s.params[1]
+
+	switch tv := last.(type) {
+	case *GraphTraversalV:
+		t := tv.GraphTraversal.writes
+		if t == nil {
+			return nil, ErrWriteNotPermitted
+		}
+		for _, n := range tv.nodes {
+			if err := t.setProperty(tv.GraphTraversal.Graph, &n.graphElement, writeOp{kind: "setNodeMetadata", node: n}, k, v); err != nil {
+				return nil, err
+			}
+		}
+		return tv, nil
+	case *GraphTraversalE:
+		t := tv.GraphTraversal.writes
+		if t == nil {
+			return nil, ErrWriteNotPermitted
+		}
+		for _, e := range tv.edges {
+			if err := t.setProperty(tv.GraphTraversal.Graph, &e.graphElement, writeOp{kind: "setEdgeMetadata", edge: e}, k, v); err != nil {
+				return nil, err
+			}
+		}
+		return tv, nil
+	}
+
+	return nil, ExecutionError
+}
+
+func (s *gremlinTraversalStepDrop) Exec(last GraphTraversalStep) (GraphTraversalStep, error) {
+	switch tv := last.(type) {
+	case *GraphTraversalV:
+		t := tv.GraphTraversal.writes
+		if t == nil {
+			return nil, ErrWriteNotPermitted
+		}
+		for _, n := range tv.nodes {
+			if err := t.drop(tv.GraphTraversal.Graph, &n.graphElement, writeOp{kind: "delNode", node: n}); err != nil {
+				return nil, err
+			}
+		}
+		return &GraphTraversalV{GraphTraversal: tv.GraphTraversal, nodes: []*Node{}}, nil
+	case *GraphTraversalE:
+		t := tv.GraphTraversal.writes
+		if t == nil {
+			return nil, ErrWriteNotPermitted
+		}
+		for _, e := range tv.edges {
+			if err := t.drop(tv.GraphTraversal.Graph, &e.graphElement, writeOp{kind: "delEdge", edge: e}); err != nil {
+				return nil, err
+			}
+		}
+		return &GraphTraversalE{GraphTraversal: tv.GraphTraversal, edges: []*Edge{}}, nil
+	}
+
+	return nil, ExecutionError
+}
+
+// EnableWrites permits the write steps, the elements they create belonging
+// to the origin, the only one whose elements they can modify. The changes are
+// applied at the end of Exec, which must then be called without the graph
+// lock held.
+func (s *GremlinTraversalSequence) EnableWrites(origin string) {
+	s.GraphTraversal.writes = newWriteTransaction(origin)
+}
+
+// HasWriteSteps returns whether the sequence modifies the graph
+func (s *GremlinTraversalSequence) HasWriteSteps() bool {
+	for _, step := range s.steps {
+		switch step.(type) {
+		case *gremlinTraversalStepAddV, *gremlinTraversalStepAddE, *gremlinTraversalStepProperty, *gremlinTraversalStepDrop:
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,236 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package graph
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+type fakeBatchListener struct {
+	FakeListener
+	batches []*GraphBatch
+}
+
+func (l *fakeBatchListener) OnBatchApplied(b *GraphBatch) {
+	l.batches = append(l.batches, b)
+}
+
+func execWriteQuery(g *Graph, query string, origin string) (GraphTraversalStep, error) {
+	ts, err := NewGremlinTraversalParser(strings.NewReader(query), g).Parse()
+	if err != nil {
+		return nil, err
+	}
+
+	if origin != "" {
+		ts.EnableWrites(origin)
+	}
+
+	return ts.Exec()
+}
+
+func newWriteGraph(t *testing.T) *Graph {
+	g := newGraph(t)
+
+	// a node reported by an agent
+	g.AddNode(&Node{graphElement: graphElement{ID: GenID(), metadata: Metadata{"Name": "eth0", "Type": "device"}, host: "agent1"}})
+
+	return g
+}
+
+func TestWriteNotPermitted(t *testing.T) {
+	g := newWriteGraph(t)
+
+	if _, err := execWriteQuery(g, `G.AddV("Name", "switch1")`, ""); err != ErrWriteNotPermitted {
+		t.Fatalf("Expected a permission error, got: %v", err)
+	}
+
+	if _, err := execWriteQuery(g, `G.V().Has("Name", "eth0").Drop()`, ""); err != ErrWriteNotPermitted {
+		t.Fatalf("Expected a permission error, got: %v", err)
+	}
+
+	if len(g.GetNodes()) != 1 {
+		t.Fatalf("The graph shouldn't have been modified: %s", g.String())
+	}
+}
+
+func TestWriteSteps(t *testing.T) {
+	g := newWriteGraph(t)
+
+	l := &fakeBatchListener{}
+	g.AddEventListener(l)
+
+	if _, err := execWriteQuery(g, `G.AddV(Metadata("Name", "switch1", "Type", "switch"))`, "user"); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	sw := g.LookupFirstNode(Metadata{"Name": "switch1"})
+	if sw == nil || sw.Metadata()[OriginKey] != "user" {
+		t.Fatalf("switch1 should have been created with the user origin: %s", g.String())
+	}
+
+	eth0 := g.LookupFirstNode(Metadata{"Name": "eth0"})
+	query := `G.AddE("layer2", "` + string(sw.ID) + `", "` + string(eth0.ID) + `").Property("Port", "ge-0/0/1")`
+	if _, err := execWriteQuery(g, query, "user"); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	edges := g.LookupChildren(sw, Metadata{})
+	if len(edges) != 1 || g.GetEdges()[0].Metadata()["Port"] != "ge-0/0/1" {
+		t.Fatalf("switch1 should be linked to eth0: %s", g.String())
+	}
+
+	if _, err := execWriteQuery(g, `G.V().Has("Name", "switch1").Property("Rack", "r1")`, "user"); err != nil {
+		t.Fatal(err.Error())
+	}
+	if sw.Metadata()["Rack"] != "r1" {
+		t.Fatalf("switch1 should have been updated: %s", g.String())
+	}
+
+	if _, err := execWriteQuery(g, `G.V().Has("Name", "switch1").Drop()`, "user"); err != nil {
+		t.Fatal(err.Error())
+	}
+	if g.GetNode(sw.ID) != nil || len(g.GetEdges()) != 0 {
+		t.Fatalf("switch1 and its edge should have been removed: %s", g.String())
+	}
+
+	if len(l.batches) != 4 {
+		t.Fatalf("Expected one batch per query, got %d", len(l.batches))
+	}
+	if len(l.batches[0].Events) != 1 || l.batches[0].Events[0].Type != "NodeAdded" {
+		t.Errorf("Unexpected batch: %+v", l.batches[0])
+	}
+	if len(l.batches[1].Events) != 1 || l.batches[1].Events[0].Type != "EdgeAdded" {
+		t.Errorf("Unexpected batch: %+v", l.batches[1])
+	}
+}
+
+func TestWriteOriginProtection(t *testing.T) {
+	g := newWriteGraph(t)
+
+	for _, query := range []string{
+		`G.V().Has("Name", "eth0").Drop()`,
+		`G.V().Has("Name", "eth0").Property("MTU", 9000)`,
+	} {
+		_, err := execWriteQuery(g, query, "user")
+		oerr, ok := err.(*OriginError)
+		if !ok {
+			t.Fatalf("Expected an origin error for %s, got: %v", query, err)
+		}
+		if oerr.Origin != "agent agent1" || !strings.Contains(oerr.Error(), "agent agent1") {
+			t.Errorf("The error should name the agent: %s", oerr.Error())
+		}
+	}
+
+	if _, err := execWriteQuery(g, `G.AddV("Name", "switch1", "Origin", "agent")`, "user"); err == nil {
+		t.Error("The origin shouldn't be settable")
+	}
+
+	// elements of another origin can't be modified either
+	if _, err := execWriteQuery(g, `G.AddV("Name", "switch1")`, "lab"); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := execWriteQuery(g, `G.V().Has("Name", "switch1").Drop()`, "user"); err == nil {
+		t.Error("switch1 belongs to the lab origin")
+	}
+
+	if eth0 := g.LookupFirstNode(Metadata{"Name": "eth0"}); eth0 == nil || eth0.Metadata()["MTU"] != nil {
+		t.Fatalf("eth0 shouldn't have been modified: %s", g.String())
+	}
+}
+
+func TestWriteAtomicity(t *testing.T) {
+	g := newWriteGraph(t)
+
+	l := &fakeBatchListener{}
+	g.AddEventListener(l)
+
+	// the drop of the agent node fails after the creation of switch1
+	query := `G.AddV("Name", "switch1").V().Has("Name", "eth0").Drop()`
+	if _, err := execWriteQuery(g, query, "user"); err == nil {
+		t.Fatal("The query should have failed")
+	} else if _, ok := err.(*OriginError); !ok {
+		t.Fatalf("Expected an origin error, got: %v", err)
+	}
+
+	if len(g.GetNodes()) != 1 || len(l.batches) != 0 || l.lastNodeAdded != nil {
+		t.Fatalf("The graph shouldn't have been modified: %s", g.String())
+	}
+}
+
+func TestWriteSnapshotRoundTrip(t *testing.T) {
+	g := newWriteGraph(t)
+
+	if _, err := execWriteQuery(g, `G.AddV("Name", "switch1")`, "user"); err != nil {
+		t.Fatal(err.Error())
+	}
+	sw := g.LookupFirstNode(Metadata{"Name": "switch1"})
+	eth0 := g.LookupFirstNode(Metadata{"Name": "eth0"})
+	if _, err := execWriteQuery(g, `G.AddE("layer2", "`+string(sw.ID)+`", "`+string(eth0.ID)+`")`, "user"); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	data, err := json.Marshal(g)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var snapshot struct {
+		Nodes []interface{}
+		Edges []interface{}
+	}
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	restored := newGraph(t)
+	restored.host = g.host
+	for _, i := range snapshot.Nodes {
+		var n Node
+		if err := n.Decode(i); err != nil {
+			t.Fatal(err.Error())
+		}
+		restored.AddNode(&n)
+	}
+	for _, i := range snapshot.Edges {
+		var e Edge
+		if err := e.Decode(i); err != nil {
+			t.Fatal(err.Error())
+		}
+		restored.AddEdge(&e)
+	}
+
+	// the restored elements keep their origin and stay writable
+	if _, err := execWriteQuery(restored, `G.V().Has("Name", "switch1").Property("Rack", "r1")`, "user"); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := execWriteQuery(restored, `G.V().Has("Name", "eth0").Drop()`, "user"); err == nil {
+		t.Fatal("eth0 should still be protected")
+	}
+
+	rsw := restored.GetNode(sw.ID)
+	if rsw == nil || rsw.Metadata()["Rack"] != "r1" || len(restored.GetEdges()) != 1 {
+		t.Fatalf("Unexpected restored graph: %s", restored.String())
+	}
+}
@@ -102,7 +102,7 @@ func (probe *DockerProbe) registerContainer(id string) {
 		"Docker.ContainerName": info.Name,
 		"Docker.ContainerPID":  info.State.Pid,
 	}
-	containerNode := probe.Graph.NewNode(graph.GenID(), metadata)
+	containerNode := probe.Graph.NewNode(probe.Graph.GenNodeID(graph.Metadata{"Docker.ContainerID": info.Id}), metadata)
 	probe.Graph.Link(n, containerNode, graph.Metadata{"RelationType": "membership"})
 	probe.Graph.Unlock()
 
@@ -0,0 +1,303 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package probes
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redhat-cip/skydive/config"
+	"github.com/redhat-cip/skydive/logging"
+	"github.com/redhat-cip/skydive/topology/graph"
+)
+
+const (
+	HostFactKernel         = "kernel"
+	HostFactCPU            = "cpu"
+	HostFactMemory         = "memory"
+	HostFactVirtualization = "virtualization"
+	HostFactOVS            = "ovs"
+)
+
+// metadata keys set on the host node
+var hostFactKeys = map[string]string{
+	HostFactKernel:         "Kernel.Version",
+	HostFactCPU:            "CPU.Count",
+	HostFactMemory:         "Memory.Total",
+	HostFactVirtualization: "Virtualization.Type",
+	HostFactOVS:            "OVS.Version",
+}
+
+var ovsVersionRegexp = regexp.MustCompile(`\(Open vSwitch\) ([0-9][^\s]*)`)
+
+// HostProbe attaches facts about the host (kernel, CPU, memory...) to the
+// host node. Facts that can't be retrieved are not set.
+type HostProbe struct {
+	Graph    *graph.Graph
+	Root     *graph.Node
+	facts    []string
+	interval time.Duration
+	procPath string
+	sysPath  string
+	quit     chan bool
+	wg       sync.WaitGroup
+}
+
+func readFileString(path string) (string, bool) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+func parseKernelVersion(osrelease string) (string, bool) {
+	version := strings.TrimSpace(osrelease)
+	return version, version != ""
+}
+
+func parseCPUCount(cpuinfo string) (int, bool) {
+	count := 0
+	scanner := bufio.NewScanner(strings.NewReader(cpuinfo))
+	for scanner.Scan() {
+		if fields := strings.SplitN(scanner.Text(), ":", 2); strings.TrimSpace(fields[0]) == "processor" {
+			count++
+		}
+	}
+	return count, count > 0
+}
+
+// parseMemoryTotal returns the total memory in bytes
+func parseMemoryTotal(meminfo string) (int, bool) {
+	scanner := bufio.NewScanner(strings.NewReader(meminfo))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "MemTotal:" {
+			continue
+		}
+
+		value, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return 0, false
+		}
+		if len(fields) > 2 && fields[2] == "kB" {
+			value *= 1024
+		}
+		return value, true
+	}
+	return 0, false
+}
+
+// parseVirtualizationType returns none if the CPU doesn't report an
+// hypervisor, the hypervisor guessed from the DMI vendor otherwise
+func parseVirtualizationType(cpuinfo string, vendor string) (string, bool) {
+	if cpuinfo == "" {
+		return "", false
+	}
+
+	hypervisor := false
+	scanner := bufio.NewScanner(strings.NewReader(cpuinfo))
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), ":", 2)
+		if len(fields) == 2 && strings.TrimSpace(fields[0]) == "flags" {
+			for _, flag := range strings.Fields(fields[1]) {
+				if flag == "hypervisor" {
+					hypervisor = true
+				}
+			}
+			break
+		}
+	}
+
+	if !hypervisor {
+		return "none", true
+	}
+
+	vendor = strings.ToLower(vendor)
+	for _, v := range []struct{ pattern, name string }{
+		{"qemu", "kvm"},
+		{"kvm", "kvm"},
+		{"vmware", "vmware"},
+		{"xen", "xen"},
+		{"innotek", "virtualbox"},
+		{"microsoft", "hyperv"},
+	} {
+		if strings.Contains(vendor, v.pattern) {
+			return v.name, true
+		}
+	}
+	return "", false
+}
+
+func parseOVSVersion(output string) (string, bool) {
+	if match := ovsVersionRegexp.FindStringSubmatch(output); match != nil {
+		return match[1], true
+	}
+	return "", false
+}
+
+func (h *HostProbe) collect() map[string]interface{} {
+	facts := make(map[string]interface{})
+
+	var cpuinfo string
+	for _, fact := range h.facts {
+		if fact == HostFactCPU || fact == HostFactVirtualization {
+			cpuinfo, _ = readFileString(h.procPath + "/cpuinfo")
+		}
+	}
+
+	for _, fact := range h.facts {
+		switch fact {
+		case HostFactKernel:
+			if osrelease, ok := readFileString(h.procPath + "/sys/kernel/osrelease"); ok {
+				if version, ok := parseKernelVersion(osrelease); ok {
+					facts[hostFactKeys[HostFactKernel]] = version
+				}
+			}
+		case HostFactCPU:
+			if count, ok := parseCPUCount(cpuinfo); ok {
+				facts[hostFactKeys[HostFactCPU]] = count
+			}
+		case HostFactMemory:
+			if meminfo, ok := readFileString(h.procPath + "/meminfo"); ok {
+				if total, ok := parseMemoryTotal(meminfo); ok {
+					facts[hostFactKeys[HostFactMemory]] = total
+				}
+			}
+		case HostFactVirtualization:
+			vendor, _ := readFileString(h.sysPath + "/class/dmi/id/sys_vendor")
+			if virt, ok := parseVirtualizationType(cpuinfo, vendor); ok {
+				facts[hostFactKeys[HostFactVirtualization]] = virt
+			}
+		case HostFactOVS:
+			if path, err := exec.LookPath("ovs-vsctl"); err == nil {
+				if output, err := exec.Command(path, "--version").Output(); err == nil {
+					if version, ok := parseOVSVersion(string(output)); ok {
+						facts[hostFactKeys[HostFactOVS]] = version
+					}
+				}
+			}
+		}
+	}
+
+	return facts
+}
+
+// update sets the facts on the host node if they changed, the facts no more
+// available are removed.
+func (h *HostProbe) update(facts map[string]interface{}) {
+	h.Graph.Lock()
+	defer h.Graph.Unlock()
+
+	m := make(graph.Metadata)
+	for k, v := range h.Root.Metadata() {
+		m[k] = v
+	}
+
+	updated := false
+	for _, key := range hostFactKeys {
+		if _, ok := facts[key]; !ok {
+			if _, ok := m[key]; ok {
+				delete(m, key)
+				updated = true
+			}
+		}
+	}
+
+	for k, v := range facts {
+		if ov, ok := m[k]; !ok || ov != v {
+			m[k] = v
+			updated = true
+		}
+	}
+
+	if updated {
+		h.Graph.SetMetadata(h.Root, m)
+	}
+}
+
+func (h *HostProbe) Start() {
+	h.quit = make(chan bool)
+	h.update(h.collect())
+
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+
+		ticker := time.NewTicker(h.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				h.update(h.collect())
+			case <-h.quit:
+				return
+			}
+		}
+	}()
+}
+
+func (h *HostProbe) Stop() {
+	close(h.quit)
+	h.wg.Wait()
+}
+
+func NewHostProbe(g *graph.Graph, n *graph.Node, facts []string, interval time.Duration) *HostProbe {
+	return &HostProbe{
+		Graph:    g,
+		Root:     n,
+		facts:    facts,
+		interval: interval,
+		procPath: "/proc",
+		sysPath:  "/sys",
+	}
+}
+
+func NewHostProbeFromConfig(g *graph.Graph, n *graph.Node) *HostProbe {
+	facts := config.GetConfig().GetStringSlice("agent.topology.host.facts")
+
+	// defaults on nested keys are not handled by viper
+	if len(facts) == 0 {
+		facts = []string{HostFactKernel, HostFactCPU, HostFactMemory, HostFactVirtualization, HostFactOVS}
+	}
+
+	for _, fact := range facts {
+		if _, ok := hostFactKeys[fact]; !ok {
+			logging.GetLogger().Errorf("Unknown host fact: %s", fact)
+		}
+	}
+
+	interval := config.GetConfig().GetInt("agent.topology.host.interval")
+	if interval <= 0 {
+		interval = 300
+	}
+
+	return NewHostProbe(g, n, facts, time.Duration(interval)*time.Second)
+}
@@ -0,0 +1,153 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package probes
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/redhat-cip/skydive/topology/graph"
+)
+
+const testCPUInfo = `processor	: 0
+vendor_id	: GenuineIntel
+cpu family	: 6
+model name	: Intel Core Processor (Haswell, no TSX)
+flags		: fpu vme de pse tsc msr pae mce cx8 apic sep mtrr pge hypervisor lahf_lm
+
+processor	: 1
+vendor_id	: GenuineIntel
+cpu family	: 6
+model name	: Intel Core Processor (Haswell, no TSX)
+flags		: fpu vme de pse tsc msr pae mce cx8 apic sep mtrr pge hypervisor lahf_lm
+`
+
+const testMemInfo = `MemTotal:        8175444 kB
+MemFree:          249760 kB
+MemAvailable:    5066012 kB
+Buffers:          375280 kB
+`
+
+const testOVSVersion = `ovs-vsctl (Open vSwitch) 2.5.0
+Compiled Mar 10 2016 14:16:49
+DB Schema 7.12.1
+`
+
+func TestHostFactsParsing(t *testing.T) {
+	if version, ok := parseKernelVersion("4.4.0-21-generic\n"); !ok || version != "4.4.0-21-generic" {
+		t.Errorf("Wrong kernel version: %s", version)
+	}
+
+	if count, ok := parseCPUCount(testCPUInfo); !ok || count != 2 {
+		t.Errorf("Wrong CPU count: %d", count)
+	}
+
+	if total, ok := parseMemoryTotal(testMemInfo); !ok || total != 8175444*1024 {
+		t.Errorf("Wrong memory total: %d", total)
+	}
+
+	if virt, ok := parseVirtualizationType(testCPUInfo, "QEMU\n"); !ok || virt != "kvm" {
+		t.Errorf("Wrong virtualization type: %s", virt)
+	}
+
+	if _, ok := parseVirtualizationType(testCPUInfo, "Unknown vendor\n"); ok {
+		t.Error("Unknown hypervisor should not be reported")
+	}
+
+	if virt, ok := parseVirtualizationType("flags		: fpu vme de\n", ""); !ok || virt != "none" {
+		t.Errorf("Wrong virtualization type: %s", virt)
+	}
+
+	if version, ok := parseOVSVersion(testOVSVersion); !ok || version != "2.5.0" {
+		t.Errorf("Wrong OVS version: %s", version)
+	}
+
+	if _, ok := parseKernelVersion("\n"); ok {
+		t.Error("Empty kernel version should not be reported")
+	}
+
+	for _, content := range []string{"", "garbage"} {
+		if _, ok := parseCPUCount(content); ok {
+			t.Errorf("No CPU count expected for %q", content)
+		}
+		if _, ok := parseMemoryTotal(content); ok {
+			t.Errorf("No memory total expected for %q", content)
+		}
+		if _, ok := parseOVSVersion(content); ok {
+			t.Errorf("No OVS version expected for %q", content)
+		}
+	}
+}
+
+func TestHostProbeMetadata(t *testing.T) {
+	dir, err := ioutil.TempDir("", "skydive-host")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	os.MkdirAll(filepath.Join(dir, "sys/kernel"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "sys/kernel/osrelease"), []byte("3.10.0-327.el7.x86_64\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "cpuinfo"), []byte(testCPUInfo), 0644)
+
+	backend, _ := graph.NewMemoryBackend()
+	g, _ := graph.NewGraph(backend)
+	root := g.NewNode(graph.Identifier("host"), graph.Metadata{"Name": "host", "Type": "host", "Memory.Total": 1024})
+
+	// kernel facts disabled, memory not available
+	probe := NewHostProbe(g, root, []string{HostFactCPU, HostFactMemory}, 0)
+	probe.procPath = dir
+	probe.update(probe.collect())
+
+	m := root.Metadata()
+	if _, ok := m["Kernel.Version"]; ok {
+		t.Error("Kernel version collected while disabled")
+	}
+	if _, ok := m["Memory.Total"]; ok {
+		t.Error("Memory total not available should be absent")
+	}
+	if m["CPU.Count"] != 2 || m["Name"] != "host" {
+		t.Errorf("Wrong host metadata: %v", m)
+	}
+
+	probe.facts = append(probe.facts, HostFactKernel)
+	probe.update(probe.collect())
+
+	tr := graph.NewGremlinTraversalParser(
+		strings.NewReader(`G.V().Has("Kernel.Version", Regex("^3\.")).Has("CPU.Count", 2)`), g)
+	ts, err := tr.Parse()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	res, err := ts.Exec()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(res.Values()) != 1 {
+		t.Errorf("Host node should be found by its kernel version: %v", res.Values())
+	}
+}
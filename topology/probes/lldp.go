@@ -0,0 +1,562 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package probes
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/nu7hatch/gouuid"
+
+	"github.com/redhat-cip/skydive/config"
+	"github.com/redhat-cip/skydive/logging"
+	"github.com/redhat-cip/skydive/topology/graph"
+)
+
+var (
+	lldpMulticastMAC = net.HardwareAddr{0x01, 0x80, 0xc2, 0x00, 0x00, 0x0e}
+	cdpMulticastMAC  = net.HardwareAddr{0x01, 0x00, 0x0c, 0xcc, 0xcc, 0xcc}
+
+	errNotDiscoveryFrame = errors.New("Not a LLDP or CDP frame")
+)
+
+// Neighbor is a device advertised by a LLDP or CDP frame
+type Neighbor struct {
+	Protocol        string
+	ChassisID       string
+	PortID          string
+	PortDescription string
+	SysName         string
+	SysDescription  string
+	MgmtAddress     string
+	TTL             time.Duration
+}
+
+type lldpNeighbor struct {
+	intf     graph.Identifier
+	switchID graph.Identifier
+	portID   graph.Identifier
+	expires  time.Time
+}
+
+type lldpListener struct {
+	fds     []int
+	running atomic.Value
+}
+
+// LLDPProbe listens for the LLDP, and optionally CDP, frames received on the
+// physical interfaces of the host. The advertised switches and ports are
+// added to the graph and linked to the interfaces with layer1 edges, which
+// expire after the TTL of the frames. The identifiers of the switch and port
+// nodes are derived from the advertised chassis and port IDs so that the
+// nodes reported by several agents are merged by the analyzer.
+type LLDPProbe struct {
+	sync.RWMutex
+	graph.DefaultGraphListener
+	Graph      *graph.Graph
+	Root       *graph.Node
+	interfaces []string
+	cdp        bool
+	listeners  map[graph.Identifier]*lldpListener
+	neighbors  map[string]*lldpNeighbor
+	quit       chan bool
+	wg         sync.WaitGroup
+}
+
+func lldpChassisID(id layers.LLDPChassisID) string {
+	switch id.Subtype {
+	case layers.LLDPChassisIDSubTypeMACAddr:
+		return net.HardwareAddr(id.ID).String()
+	case layers.LLDPChassisIDSubTypeNetworkAddr:
+		if len(id.ID) > 1 {
+			return net.IP(id.ID[1:]).String()
+		}
+	}
+	return string(id.ID)
+}
+
+func lldpPortID(id layers.LLDPPortID) string {
+	switch id.Subtype {
+	case layers.LLDPPortIDSubtypeMACAddr:
+		return net.HardwareAddr(id.ID).String()
+	case layers.LLDPPortIDSubtypeNetworkAddr:
+		if len(id.ID) > 1 {
+			return net.IP(id.ID[1:]).String()
+		}
+	}
+	return string(id.ID)
+}
+
+// DecodeNeighbor returns the neighbor advertised by a LLDP or CDP ethernet
+// frame
+func DecodeNeighbor(data []byte) (*Neighbor, error) {
+	packet := gopacket.NewPacket(data, layers.LayerTypeEthernet, gopacket.DecodeOptions{NoCopy: true})
+
+	if layer := packet.Layer(layers.LayerTypeLinkLayerDiscovery); layer != nil {
+		lldp := layer.(*layers.LinkLayerDiscovery)
+		n := &Neighbor{
+			Protocol:  "lldp",
+			ChassisID: lldpChassisID(lldp.ChassisID),
+			PortID:    lldpPortID(lldp.PortID),
+			TTL:       time.Duration(lldp.TTL) * time.Second,
+		}
+
+		if layer := packet.Layer(layers.LayerTypeLinkLayerDiscoveryInfo); layer != nil {
+			info := layer.(*layers.LinkLayerDiscoveryInfo)
+			n.PortDescription = info.PortDescription
+			n.SysName = info.SysName
+			n.SysDescription = info.SysDescription
+
+			switch info.MgmtAddress.Subtype {
+			case layers.IANAAddressFamilyIPV4, layers.IANAAddressFamilyIPV6:
+				n.MgmtAddress = net.IP(info.MgmtAddress.Address).String()
+			}
+		}
+
+		return n, nil
+	}
+
+	if layer := packet.Layer(layers.LayerTypeCiscoDiscovery); layer != nil {
+		cdp := layer.(*layers.CiscoDiscovery)
+		n := &Neighbor{
+			Protocol: "cdp",
+			TTL:      time.Duration(cdp.TTL) * time.Second,
+		}
+
+		if layer := packet.Layer(layers.LayerTypeCiscoDiscoveryInfo); layer != nil {
+			info := layer.(*layers.CiscoDiscoveryInfo)
+			n.ChassisID = info.DeviceID
+			n.PortID = info.PortID
+			n.SysName = info.SysName
+			n.SysDescription = info.Version
+
+			if len(info.MgmtAddresses) > 0 {
+				n.MgmtAddress = info.MgmtAddresses[0].String()
+			} else if len(info.Addresses) > 0 {
+				n.MgmtAddress = info.Addresses[0].String()
+			}
+		}
+
+		if n.ChassisID == "" {
+			return nil, errors.New("CDP frame without device ID")
+		}
+
+		return n, nil
+	}
+
+	if layer := packet.ErrorLayer(); layer != nil {
+		return nil, layer.Error()
+	}
+
+	return nil, errNotDiscoveryFrame
+}
+
+func neighborID(kind string, ids ...string) graph.Identifier {
+	u, _ := uuid.NewV5(uuid.NamespaceURL, []byte("skydive:"+kind+":"+strings.Join(ids, "/")))
+	return graph.Identifier(u.String())
+}
+
+func neighborKey(intf graph.Identifier, n *Neighbor) string {
+	return string(intf) + "/" + n.ChassisID + "/" + n.PortID
+}
+
+// updateMetadata sets the metadata on the node if they changed
+func (p *LLDPProbe) updateMetadata(node *graph.Node, metadata graph.Metadata) {
+	m := node.Metadata()
+
+	updated := false
+	for k, v := range metadata {
+		if ov, ok := m[k]; !ok || ov != v {
+			m[k] = v
+			updated = true
+		}
+	}
+
+	if updated {
+		p.Graph.SetMetadata(node, m)
+	}
+}
+
+func (p *LLDPProbe) lookupNode(id graph.Identifier, metadata graph.Metadata) *graph.Node {
+	node := p.Graph.GetNode(id)
+	if node == nil {
+		return p.Graph.NewNode(id, metadata)
+	}
+
+	// switches can be renamed, the node follows the advertised names
+	p.updateMetadata(node, metadata)
+
+	return node
+}
+
+// updateNeighbor adds or refreshes the neighbor seen on the interface, the
+// graph lock has to be held.
+func (p *LLDPProbe) updateNeighbor(intf *graph.Node, n *Neighbor, now time.Time) {
+	p.Lock()
+	defer p.Unlock()
+
+	key := neighborKey(intf.ID, n)
+
+	// a null TTL is sent by the neighbors shutting down LLDP
+	if n.TTL == 0 {
+		if neighbor, ok := p.neighbors[key]; ok {
+			p.removeNeighbor(key, neighbor)
+		}
+		return
+	}
+
+	name := n.SysName
+	if name == "" {
+		name = n.ChassisID
+	}
+	switchMetadata := graph.Metadata{
+		"Type":      "switch",
+		"Name":      name,
+		"ChassisID": n.ChassisID,
+		"Protocol":  n.Protocol,
+	}
+	if n.SysDescription != "" {
+		switchMetadata["SysDescription"] = n.SysDescription
+	}
+	if n.MgmtAddress != "" {
+		switchMetadata["MgmtAddress"] = n.MgmtAddress
+	}
+
+	portName := n.PortDescription
+	if portName == "" {
+		portName = n.PortID
+	}
+	portMetadata := graph.Metadata{
+		"Type":      "switchport",
+		"Name":      portName,
+		"PortID":    n.PortID,
+		"ChassisID": n.ChassisID,
+	}
+
+	neighbor := &lldpNeighbor{
+		intf:     intf.ID,
+		switchID: neighborID("switch", n.ChassisID),
+		portID:   neighborID("port", n.ChassisID, n.PortID),
+		expires:  now.Add(n.TTL),
+	}
+
+	sw := p.lookupNode(neighbor.switchID, switchMetadata)
+	port := p.lookupNode(neighbor.portID, portMetadata)
+
+	if !p.Graph.AreLinked(sw, port) {
+		p.Graph.NewEdge(neighborID("ownership", n.ChassisID, n.PortID), sw, port, graph.Metadata{"RelationType": "ownership"})
+	}
+
+	if !p.Graph.AreLinked(intf, port) {
+		logging.GetLogger().Debugf("%s neighbor %s port %s seen on %s", n.Protocol, name, portName, intf.Metadata()["Name"])
+		p.Graph.Link(intf, port, graph.Metadata{"RelationType": "layer1", "Protocol": n.Protocol})
+	}
+
+	p.neighbors[key] = neighbor
+}
+
+func (p *LLDPProbe) isReferenced(id graph.Identifier) bool {
+	for _, neighbor := range p.neighbors {
+		if neighbor.switchID == id || neighbor.portID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// removeNeighbor removes the layer1 edge of the neighbor along with the
+// switch and port nodes not referenced anymore, the graph and probe locks
+// have to be held.
+func (p *LLDPProbe) removeNeighbor(key string, neighbor *lldpNeighbor) {
+	delete(p.neighbors, key)
+
+	port := p.Graph.GetNode(neighbor.portID)
+	if port == nil {
+		return
+	}
+
+	if intf := p.Graph.GetNode(neighbor.intf); intf != nil {
+		p.Graph.Unlink(intf, port)
+	}
+
+	if !p.isReferenced(neighbor.portID) {
+		p.Graph.DelNode(port)
+	}
+
+	if !p.isReferenced(neighbor.switchID) {
+		if sw := p.Graph.GetNode(neighbor.switchID); sw != nil {
+			p.Graph.DelNode(sw)
+		}
+	}
+}
+
+func (p *LLDPProbe) expire(now time.Time) {
+	p.Graph.Lock()
+	defer p.Graph.Unlock()
+
+	p.Lock()
+	defer p.Unlock()
+
+	for key, neighbor := range p.neighbors {
+		if now.After(neighbor.expires) {
+			p.removeNeighbor(key, neighbor)
+		}
+	}
+}
+
+func (p *LLDPProbe) handleFrame(id graph.Identifier, data []byte) {
+	n, err := DecodeNeighbor(data)
+	if err != nil {
+		if err != errNotDiscoveryFrame {
+			logging.GetLogger().Debugf("Unable to decode LLDP/CDP frame: %s", err.Error())
+		}
+		return
+	}
+
+	p.Graph.Lock()
+	defer p.Graph.Unlock()
+
+	if intf := p.Graph.GetNode(id); intf != nil {
+		p.updateNeighbor(intf, n, time.Now())
+	}
+}
+
+// packetMreq is the packet_mreq structure of linux/if_packet.h
+type packetMreq struct {
+	Ifindex int32
+	Type    uint16
+	Alen    uint16
+	Address [8]byte
+}
+
+func htons(v uint16) uint16 {
+	return v<<8 | v>>8
+}
+
+// openDiscoverySocket returns a packet socket receiving the frames of the
+// protocol sent to the multicast address on the interface
+func openDiscoverySocket(ifIndex int, protocol uint16, mac net.HardwareAddr) (int, error) {
+	fd, err := syscall.Socket(syscall.AF_PACKET, syscall.SOCK_RAW, int(htons(protocol)))
+	if err != nil {
+		return -1, err
+	}
+
+	if err := syscall.Bind(fd, &syscall.SockaddrLinklayer{Protocol: htons(protocol), Ifindex: ifIndex}); err != nil {
+		syscall.Close(fd)
+		return -1, err
+	}
+
+	mreq := packetMreq{Ifindex: int32(ifIndex), Type: syscall.PACKET_MR_MULTICAST, Alen: uint16(len(mac))}
+	copy(mreq.Address[:], mac)
+	if _, _, errno := syscall.Syscall6(syscall.SYS_SETSOCKOPT, uintptr(fd), syscall.SOL_PACKET, syscall.PACKET_ADD_MEMBERSHIP,
+		uintptr(unsafe.Pointer(&mreq)), unsafe.Sizeof(mreq), 0); errno != 0 {
+		syscall.Close(fd)
+		return -1, errno
+	}
+
+	tv := syscall.NsecToTimeval(int64(time.Second))
+	if err := syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &tv); err != nil {
+		syscall.Close(fd)
+		return -1, err
+	}
+
+	return fd, nil
+}
+
+func (p *LLDPProbe) listen(id graph.Identifier, l *lldpListener, fd int) {
+	defer p.wg.Done()
+	defer syscall.Close(fd)
+
+	data := make([]byte, 1518)
+	for l.running.Load() == true {
+		n, _, err := syscall.Recvfrom(fd, data, 0)
+		if err != nil {
+			if err == syscall.EAGAIN || err == syscall.EINTR {
+				continue
+			}
+			logging.GetLogger().Errorf("Error while reading LLDP/CDP frames: %s", err.Error())
+			return
+		}
+
+		p.handleFrame(id, data[:n])
+	}
+}
+
+func (p *LLDPProbe) isListened(m graph.Metadata) bool {
+	if m["Type"] != "device" {
+		return false
+	}
+
+	if len(p.interfaces) == 0 {
+		return true
+	}
+
+	for _, name := range p.interfaces {
+		if m["Name"] == name {
+			return true
+		}
+	}
+	return false
+}
+
+// startListener starts listening on the interface, the graph lock has to be
+// held.
+func (p *LLDPProbe) startListener(intf *graph.Node) {
+	p.Lock()
+	defer p.Unlock()
+
+	if _, ok := p.listeners[intf.ID]; ok || p.quit == nil {
+		return
+	}
+
+	m := intf.Metadata()
+	index, ok := m["IfIndex"].(int64)
+	if !ok || !p.isListened(m) {
+		return
+	}
+
+	protocols := map[uint16]net.HardwareAddr{uint16(layers.EthernetTypeLinkLayerDiscovery): lldpMulticastMAC}
+	if p.cdp {
+		// CDP is sent over 802.3 with a LLC/SNAP header
+		protocols[syscall.ETH_P_802_2] = cdpMulticastMAC
+	}
+
+	l := &lldpListener{}
+	l.running.Store(true)
+	for protocol, mac := range protocols {
+		fd, err := openDiscoverySocket(int(index), protocol, mac)
+		if err != nil {
+			logging.GetLogger().Errorf("Unable to listen for LLDP/CDP frames on %s: %s", m["Name"], err.Error())
+			continue
+		}
+		l.fds = append(l.fds, fd)
+	}
+
+	logging.GetLogger().Debugf("Listening for LLDP/CDP frames on %s", m["Name"])
+	p.listeners[intf.ID] = l
+	for _, fd := range l.fds {
+		p.wg.Add(1)
+		go p.listen(intf.ID, l, fd)
+	}
+}
+
+// stopListener stops listening on the interface and removes its neighbors,
+// the graph lock has to be held. The sockets are closed by the listening
+// goroutines within a second.
+func (p *LLDPProbe) stopListener(id graph.Identifier) {
+	p.Lock()
+	defer p.Unlock()
+
+	if l, ok := p.listeners[id]; ok {
+		l.running.Store(false)
+		delete(p.listeners, id)
+	}
+
+	for key, neighbor := range p.neighbors {
+		if neighbor.intf == id {
+			p.removeNeighbor(key, neighbor)
+		}
+	}
+}
+
+func (p *LLDPProbe) OnEdgeAdded(e *graph.Edge) {
+	if e.Metadata()["RelationType"] != "ownership" {
+		return
+	}
+
+	parent, child := p.Graph.GetEdgeNodes(e)
+	if parent != nil && child != nil && parent.ID == p.Root.ID {
+		p.startListener(child)
+	}
+}
+
+func (p *LLDPProbe) OnNodeDeleted(n *graph.Node) {
+	p.stopListener(n.ID)
+}
+
+func (p *LLDPProbe) Start() {
+	p.quit = make(chan bool)
+	p.Graph.AddEventListener(p)
+
+	p.Graph.Lock()
+	for _, intf := range p.Graph.LookupChildren(p.Root, graph.Metadata{"Type": "device"}) {
+		p.startListener(intf)
+	}
+	p.Graph.Unlock()
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case now := <-ticker.C:
+				p.expire(now)
+			case <-p.quit:
+				return
+			}
+		}
+	}()
+}
+
+func (p *LLDPProbe) Stop() {
+	p.Graph.RemoveEventListener(p)
+
+	p.Graph.Lock()
+	for id := range p.listeners {
+		p.stopListener(id)
+	}
+	close(p.quit)
+	p.Graph.Unlock()
+
+	p.wg.Wait()
+}
+
+func NewLLDPProbe(g *graph.Graph, n *graph.Node, interfaces []string, cdp bool) *LLDPProbe {
+	return &LLDPProbe{
+		Graph:      g,
+		Root:       n,
+		interfaces: interfaces,
+		cdp:        cdp,
+		listeners:  make(map[graph.Identifier]*lldpListener),
+		neighbors:  make(map[string]*lldpNeighbor),
+	}
+}
+
+func NewLLDPProbeFromConfig(g *graph.Graph, n *graph.Node) *LLDPProbe {
+	interfaces := config.GetConfig().GetStringSlice("agent.topology.lldp.interfaces")
+	cdp := config.GetConfig().GetBool("agent.topology.lldp.cdp")
+
+	return NewLLDPProbe(g, n, interfaces, cdp)
+}
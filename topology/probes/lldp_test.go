@@ -0,0 +1,193 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package probes
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redhat-cip/skydive/topology/graph"
+)
+
+// frames modelled on the LLDPDUs of a Cisco Catalyst and a Juniper EX, the
+// Juniper one stopping LLDP, and on the CDP frames of a Cisco Nexus
+var (
+	testLLDPCisco    = "0180c200000e001ef7289c8788cc020704001ef7289c800408054769312f302f370602007808144769676162697445746865726e6574312f302f370a1573772d636f72652d312e6578616d706c652e636f6d0c4e436973636f20494f5320536f6674776172652c2043333735304520536f66747761726520284333373530452d554e4956455253414c4b392d4d292c2056657273696f6e2031352e302832295345350e0400140004100c05010a0000020200000007000000"
+	testLLDPJuniper  = "0180c200000e2c6bf541d20c88cc0207042c6bf541d2000404073532330602005a080b67652d302f302f31322e300a0c6578343230302d7261636b330c5a4a756e69706572204e6574776f726b732c20496e632e206578343230302d343874202c2076657273696f6e2031322e335231322e34204275696c6420646174653a20323031362d30312d32302030353a30313a303620555443200e0400140014fe060080c201002a0000"
+	testLLDPShutdown = "0180c200000e2c6bf541d20c88cc0207042c6bf541d200040407353233060200000000"
+	testCDPNexus     = "01000ccccccc002a6ab1071b00acaaaa0300000c200002b474490001001c6e657875732d746f722d3228464f5831323334414243442900020011000000010101cc00040a0001030003001045746865726e6574312f3234000400080000022800050046436973636f204e65787573204f7065726174696e672053797374656d20284e582d4f532920536f6674776172652c2056657273696f6e20372e3028332949342832290006000f4e394b2d43393337325058000a00060064"
+)
+
+func decodeTestFrame(t *testing.T, frame string) *Neighbor {
+	data, err := hex.DecodeString(frame)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	n, err := DecodeNeighbor(data)
+	if err != nil {
+		t.Fatalf("Unable to decode frame: %s", err.Error())
+	}
+	return n
+}
+
+func TestDecodeNeighbor(t *testing.T) {
+	expected := []struct {
+		frame    string
+		neighbor Neighbor
+	}{
+		{testLLDPCisco, Neighbor{
+			Protocol:        "lldp",
+			ChassisID:       "00:1e:f7:28:9c:80",
+			PortID:          "Gi1/0/7",
+			PortDescription: "GigabitEthernet1/0/7",
+			SysName:         "sw-core-1.example.com",
+			SysDescription:  "Cisco IOS Software, C3750E Software (C3750E-UNIVERSALK9-M), Version 15.0(2)SE5",
+			MgmtAddress:     "10.0.0.2",
+			TTL:             120 * time.Second,
+		}},
+		{testLLDPJuniper, Neighbor{
+			Protocol:        "lldp",
+			ChassisID:       "2c:6b:f5:41:d2:00",
+			PortID:          "523",
+			PortDescription: "ge-0/0/12.0",
+			SysName:         "ex4200-rack3",
+			SysDescription:  "Juniper Networks, Inc. ex4200-48t , version 12.3R12.4 Build date: 2016-01-20 05:01:06 UTC ",
+			TTL:             90 * time.Second,
+		}},
+		{testLLDPShutdown, Neighbor{
+			Protocol:  "lldp",
+			ChassisID: "2c:6b:f5:41:d2:00",
+			PortID:    "523",
+		}},
+		{testCDPNexus, Neighbor{
+			Protocol:       "cdp",
+			ChassisID:      "nexus-tor-2(FOX1234ABCD)",
+			PortID:         "Ethernet1/24",
+			SysDescription: "Cisco Nexus Operating System (NX-OS) Software, Version 7.0(3)I4(2)",
+			MgmtAddress:    "10.0.1.3",
+			TTL:            180 * time.Second,
+		}},
+	}
+
+	for _, e := range expected {
+		if n := decodeTestFrame(t, e.frame); *n != e.neighbor {
+			t.Errorf("Expected neighbor %+v, got %+v", e.neighbor, *n)
+		}
+	}
+
+	// an ARP request is not a discovery frame
+	arp, _ := hex.DecodeString("ffffffffffff525400123456080600010800060400015254001234560a0000010000000000000a000002")
+	if _, err := DecodeNeighbor(arp); err == nil {
+		t.Error("ARP frame decoded as a neighbor")
+	}
+
+	truncated, _ := hex.DecodeString(testLLDPCisco[:40])
+	if _, err := DecodeNeighbor(truncated); err == nil {
+		t.Error("Truncated LLDP frame decoded as a neighbor")
+	}
+}
+
+func lookupSwitchPort(t *testing.T, g *graph.Graph, intf string) []interface{} {
+	tr := graph.NewGremlinTraversalParser(
+		strings.NewReader(`G.V().Has("Name", "`+intf+`").Out().Has("Type", "switchport")`), g)
+	ts, err := tr.Parse()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	res, err := ts.Exec()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return res.Values()
+}
+
+func TestLLDPProbeNeighbors(t *testing.T) {
+	backend, _ := graph.NewMemoryBackend()
+	g, _ := graph.NewGraph(backend)
+
+	g.Lock()
+	defer g.Unlock()
+
+	root := g.NewNode(graph.Identifier("host"), graph.Metadata{"Name": "host", "Type": "host"})
+	eth0 := g.NewNode(graph.GenID(), graph.Metadata{"Name": "eth0", "Type": "device", "IfIndex": int64(2)})
+	g.Link(root, eth0, graph.Metadata{"RelationType": "ownership"})
+
+	probe := NewLLDPProbe(g, root, nil, true)
+	now := time.Now()
+
+	// an hypervisor trunk can see several neighbors
+	cisco := decodeTestFrame(t, testLLDPCisco)
+	juniper := decodeTestFrame(t, testLLDPJuniper)
+	probe.updateNeighbor(eth0, cisco, now)
+	probe.updateNeighbor(eth0, juniper, now)
+	probe.updateNeighbor(eth0, juniper, now)
+
+	if ports := lookupSwitchPort(t, g, "eth0"); len(ports) != 2 {
+		t.Fatalf("Expected 2 switch ports, got %v", ports)
+	}
+
+	sw := g.LookupFirstNode(graph.Metadata{"Type": "switch", "ChassisID": "00:1e:f7:28:9c:80"})
+	if sw == nil || sw.Metadata()["Name"] != "sw-core-1.example.com" || sw.Metadata()["MgmtAddress"] != "10.0.0.2" {
+		t.Fatalf("Wrong switch node: %v", sw)
+	}
+	if port := g.LookupFirstChild(sw, graph.Metadata{"PortID": "Gi1/0/7"}); port == nil || port.Metadata()["Name"] != "GigabitEthernet1/0/7" {
+		t.Errorf("Wrong switch port node: %v", port)
+	}
+
+	// the renamed switch stays the same node
+	renamed := *cisco
+	renamed.SysName = "sw-core-01.example.com"
+	probe.updateNeighbor(eth0, &renamed, now.Add(60*time.Second))
+	if n := g.GetNode(sw.ID); n == nil || n.Metadata()["Name"] != "sw-core-01.example.com" {
+		t.Errorf("Switch not renamed: %v", n)
+	}
+	if len(g.LookupNodes(graph.Metadata{"Type": "switch"})) != 2 {
+		t.Errorf("Renaming created a switch: %v", g.LookupNodes(graph.Metadata{"Type": "switch"}))
+	}
+
+	// the shutdown LLDPDU removes the Juniper switch
+	probe.updateNeighbor(eth0, decodeTestFrame(t, testLLDPShutdown), now)
+	if g.LookupFirstNode(graph.Metadata{"ChassisID": "2c:6b:f5:41:d2:00"}) != nil {
+		t.Error("Juniper switch not removed by the shutdown frame")
+	}
+
+	// the Cisco neighbor expires 120 seconds after the last frame
+	g.Unlock()
+	probe.expire(now.Add(150 * time.Second))
+	if ports := lookupSwitchPort(t, g, "eth0"); len(ports) != 1 {
+		t.Errorf("Neighbor expired too early: %v", ports)
+	}
+	probe.expire(now.Add(200 * time.Second))
+	g.Lock()
+
+	if ports := lookupSwitchPort(t, g, "eth0"); len(ports) != 0 {
+		t.Errorf("Neighbor not expired: %v", ports)
+	}
+	if len(g.LookupNodes(graph.Metadata{"Type": "switch"})) != 0 {
+		t.Errorf("Expired switch not removed: %v", g.LookupNodes(graph.Metadata{"Type": "switch"}))
+	}
+}
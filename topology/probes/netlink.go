@@ -158,6 +158,16 @@ func (u *NetLinkProbe) handleIntfIsBond(intf *graph.Node, link netlink.Link) {
 	// TODO(safchain) Add more info there like xmit_hash_policy
 }
 
+// linkID returns the identifier of a new interface, the interfaces of
+// different namespaces being told apart by their root
+func (u *NetLinkProbe) linkID(link netlink.Link) graph.Identifier {
+	return u.Graph.GenNodeID(graph.Metadata{
+		"Root": string(u.Root.ID),
+		"Name": link.Attrs().Name,
+		"MAC":  link.Attrs().HardwareAddr.String(),
+	})
+}
+
 func (u *NetLinkProbe) addGenericLinkToTopology(link netlink.Link, m graph.Metadata) *graph.Node {
 	name := link.Attrs().Name
 	index := int64(link.Attrs().Index)
@@ -180,7 +190,7 @@ func (u *NetLinkProbe) addGenericLinkToTopology(link netlink.Link, m graph.Metad
 	}
 
 	if intf == nil {
-		intf = u.Graph.NewNode(graph.GenID(), m)
+		intf = u.Graph.NewNode(u.linkID(link), m)
 	}
 
 	if intf == nil {
@@ -215,7 +225,7 @@ func (u *NetLinkProbe) addBridgeLinkToTopology(link netlink.Link, m graph.Metada
 	})
 
 	if intf == nil {
-		intf = u.Graph.NewNode(graph.GenID(), m)
+		intf = u.Graph.NewNode(u.linkID(link), m)
 	}
 
 	if !u.Graph.AreLinked(u.Root, intf) {
@@ -232,7 +242,7 @@ func (u *NetLinkProbe) addOvsLinkToTopology(link netlink.Link, m graph.Metadata)
 
 	intf := u.Graph.LookupFirstNode(graph.Metadata{"Name": name, "Driver": "openvswitch"})
 	if intf == nil {
-		intf = u.Graph.NewNode(graph.GenID(), m)
+		intf = u.Graph.NewNode(u.linkID(link), m)
 	}
 
 	if !u.Graph.AreLinked(u.Root, intf) {
@@ -175,7 +175,7 @@ func (u *NetNSProbe) Register(path string, extraMetadata graph.Metadata) *graph.
 			metadata[k] = v
 		}
 	}
-	n := u.Graph.NewNode(graph.GenID(), metadata)
+	n := u.Graph.NewNode(u.Graph.GenNodeID(graph.Metadata{"Type": "netns", "Path": path}), metadata)
 	u.Graph.Link(u.Root, n, graph.Metadata{"RelationType": "ownership"})
 
 	nu := NewNetNsNetLinkTopoUpdater(u.Graph, n)
@@ -37,6 +37,7 @@ import (
 	"github.com/pmylund/go-cache"
 
 	"github.com/redhat-cip/skydive/config"
+	shttp "github.com/redhat-cip/skydive/http"
 	"github.com/redhat-cip/skydive/logging"
 	"github.com/redhat-cip/skydive/topology/graph"
 )
@@ -218,11 +219,21 @@ func NewNeutronMapper(g *graph.Graph, authURL string, username string, password
 		AllowReauth:      true,
 	}
 
-	provider, err := openstack.AuthenticatedClient(opts)
+	provider, err := openstack.NewClient(authURL)
 	if err != nil {
 		return nil, err
 	}
 
+	httpClient, err := shttp.NewClientFromConfig("neutron")
+	if err != nil {
+		return nil, err
+	}
+	provider.HTTPClient = *httpClient
+
+	if err = openstack.Authenticate(provider, opts); err != nil {
+		return nil, err
+	}
+
 	/* TODO(safchain) add config param for the Availability */
 	client, err := openstack.NewNetworkV2(provider, gophercloud.EndpointOpts{
 		Name:         "neutron",
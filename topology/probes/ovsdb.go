@@ -61,7 +61,7 @@ func (o *OvsdbProbe) OnOvsBridgeAdd(monitor *ovsdb.OvsMonitor, uuid string, row
 
 	bridge := o.Graph.LookupFirstNode(graph.Metadata{"UUID": uuid})
 	if bridge == nil {
-		bridge = o.Graph.NewNode(graph.GenID(), graph.Metadata{"Name": name, "UUID": uuid, "Type": "ovsbridge"})
+		bridge = o.Graph.NewNode(o.Graph.GenNodeID(graph.Metadata{"UUID": uuid}), graph.Metadata{"Name": name, "UUID": uuid, "Type": "ovsbridge"})
 		o.Graph.Link(o.Root, bridge, graph.Metadata{"RelationType": "ownership"})
 	}
 
@@ -177,7 +177,7 @@ func (o *OvsdbProbe) OnOvsInterfaceAdd(monitor *ovsdb.OvsMonitor, uuid string, r
 	}
 
 	if intf == nil {
-		intf = o.Graph.NewNode(graph.GenID(), graph.Metadata{"Name": name, "UUID": uuid})
+		intf = o.Graph.NewNode(o.Graph.GenNodeID(graph.Metadata{"UUID": uuid}), graph.Metadata{"Name": name, "UUID": uuid})
 	} else if index > 0 {
 		// the index can be added after the interface creation, during an update so
 		// we need to check whether a interface with the same index exists at the first level
@@ -304,7 +304,7 @@ func (o *OvsdbProbe) OnOvsPortAdd(monitor *ovsdb.OvsMonitor, uuid string, row *l
 
 	port, ok := o.uuidToPort[uuid]
 	if !ok {
-		port = o.Graph.NewNode(graph.GenID(), graph.Metadata{
+		port = o.Graph.NewNode(o.Graph.GenNodeID(graph.Metadata{"UUID": uuid}), graph.Metadata{
 			"UUID": uuid,
 			"Name": row.New.Fields["name"].(string),
 			"Type": "ovsport",
@@ -39,7 +39,7 @@ func NewTopologyProbeBundleFromConfig(g *graph.Graph, n *graph.Node) *TopologyPr
 	// FIX(safchain) once viper setdefault on nested key will be fixed move this
 	// to config init
 	if len(list) == 0 {
-		list = []string{"netlink", "netns"}
+		list = []string{"netlink", "netns", "host"}
 	}
 
 	logging.GetLogger().Infof("Topology probes: %v", list)
@@ -55,8 +55,12 @@ func NewTopologyProbeBundleFromConfig(g *graph.Graph, n *graph.Node) *TopologyPr
 			probes[t] = NewNetLinkProbe(g, n)
 		case "netns":
 			probes[t] = NewNetNSProbeFromConfig(g, n)
+		case "host":
+			probes[t] = NewHostProbeFromConfig(g, n)
 		case "ovsdb":
 			probes[t] = NewOvsdbProbeFromConfig(g, n)
+		case "lldp":
+			probes[t] = NewLLDPProbeFromConfig(g, n)
 		case "docker":
 			probes[t] = NewDockerProbeFromConfig(g, n)
 		case "neutron":
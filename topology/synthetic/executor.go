@@ -0,0 +1,162 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package synthetic
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/redhat-cip/skydive/api"
+)
+
+// Executor runs the probes on the agent
+type Executor interface {
+	Execute(req *ProbeRequest) (*ProbeResult, error)
+}
+
+// CommandExecutor runs the probes with the ping and traceroute commands,
+// from the network namespace of the agent
+type CommandExecutor struct {
+	Ping       string
+	Traceroute string
+}
+
+var (
+	pingPacketsRegexp = regexp.MustCompile(`(\d+) packets transmitted, (\d+) (?:packets )?received`)
+	pingRTTRegexp     = regexp.MustCompile(`(?:rtt|round-trip) min/avg/max(?:/mdev)? = ([\d.]+)/([\d.]+)/([\d.]+)`)
+	tracerouteRegexp  = regexp.MustCompile(`^\s*(\d+)\s+(\S+)(?:\s+([\d.]+) ms)?`)
+	tracerouteTo      = regexp.MustCompile(`^traceroute to \S+ \(([^)]+)\)`)
+)
+
+// parsePing returns the result of the output of ping, the summary lines of
+// iputils and busybox being supported
+func parsePing(output string) (*ProbeResult, error) {
+	packets := pingPacketsRegexp.FindStringSubmatch(output)
+	if packets == nil {
+		return nil, errors.New("No ping statistics")
+	}
+
+	result := &ProbeResult{}
+	result.Sent, _ = strconv.Atoi(packets[1])
+	result.Received, _ = strconv.Atoi(packets[2])
+	if result.Sent > 0 {
+		result.Loss = 1 - float64(result.Received)/float64(result.Sent)
+	}
+
+	if rtt := pingRTTRegexp.FindStringSubmatch(output); rtt != nil {
+		result.MinRTT, _ = strconv.ParseFloat(rtt[1], 64)
+		result.AvgRTT, _ = strconv.ParseFloat(rtt[2], 64)
+		result.MaxRTT, _ = strconv.ParseFloat(rtt[3], 64)
+	}
+
+	return result, nil
+}
+
+// parseTraceroute returns the hops of the output of traceroute -n -q 1, the
+// target being received when it is the last hop
+func parseTraceroute(output string) (*ProbeResult, error) {
+	result := &ProbeResult{Sent: 1, Loss: 1}
+
+	var target string
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if to := tracerouteTo.FindStringSubmatch(line); to != nil {
+			target = to[1]
+			continue
+		}
+
+		hop := tracerouteRegexp.FindStringSubmatch(line)
+		if hop == nil {
+			continue
+		}
+
+		ttl, _ := strconv.Atoi(hop[1])
+		h := ProbeHop{TTL: ttl}
+		if hop[2] != "*" {
+			h.Address = hop[2]
+			h.RTT, _ = strconv.ParseFloat(hop[3], 64)
+		}
+		result.Hops = append(result.Hops, h)
+	}
+
+	if len(result.Hops) == 0 {
+		return nil, errors.New("No traceroute hop")
+	}
+
+	if last := result.Hops[len(result.Hops)-1]; last.Address != "" && last.Address == target {
+		result.Received, result.Loss = 1, 0
+		result.MinRTT, result.AvgRTT, result.MaxRTT = last.RTT, last.RTT, last.RTT
+	}
+
+	return result, nil
+}
+
+func (e *CommandExecutor) run(name string, args ...string) (string, error) {
+	output, err := exec.Command(name, args...).Output()
+	if _, ok := err.(*exec.ExitError); ok {
+		// ping exits with an error when a reply is missing
+		return string(output), nil
+	}
+	return string(output), err
+}
+
+func (e *CommandExecutor) Execute(req *ProbeRequest) (*ProbeResult, error) {
+	if !api.ValidProbeTarget(req.Target) {
+		return nil, fmt.Errorf("Invalid target %s", req.Target)
+	}
+
+	var result *ProbeResult
+	switch req.Type {
+	case api.ProbeJobPing:
+		output, err := e.run(e.Ping, "-n", "-c", strconv.Itoa(req.Count), "-W", strconv.Itoa(req.Timeout), req.Target)
+		if err != nil {
+			return nil, err
+		}
+		if result, err = parsePing(output); err != nil {
+			return nil, err
+		}
+	case api.ProbeJobTraceroute:
+		output, err := e.run(e.Traceroute, "-n", "-q", "1", "-w", strconv.Itoa(req.Timeout), "-m", strconv.Itoa(req.MaxHops), req.Target)
+		if err != nil {
+			return nil, err
+		}
+		if result, err = parseTraceroute(output); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("Unknown probe type %s", req.Type)
+	}
+
+	result.Job, result.Target = req.Job, req.Target
+	return result, nil
+}
+
+func NewCommandExecutor() *CommandExecutor {
+	return &CommandExecutor{Ping: "ping", Traceroute: "traceroute"}
+}
@@ -0,0 +1,104 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package synthetic
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePing(t *testing.T) {
+	iputils := `PING 10.0.0.2 (10.0.0.2) 56(84) bytes of data.
+64 bytes from 10.0.0.2: icmp_seq=1 ttl=64 time=0.412 ms
+64 bytes from 10.0.0.2: icmp_seq=3 ttl=64 time=0.630 ms
+
+--- 10.0.0.2 ping statistics ---
+4 packets transmitted, 2 received, 50% packet loss, time 3004ms
+rtt min/avg/max/mdev = 0.412/0.521/0.630/0.109 ms
+`
+	result, err := parsePing(iputils)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	expected := &ProbeResult{Sent: 4, Received: 2, Loss: 0.5, MinRTT: 0.412, AvgRTT: 0.521, MaxRTT: 0.630}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, result)
+	}
+
+	busybox := `--- 10.0.0.2 ping statistics ---
+3 packets transmitted, 3 packets received, 0% packet loss
+round-trip min/avg/max = 1.5/2.0/2.5 ms
+`
+	if result, err = parsePing(busybox); err != nil {
+		t.Fatal(err.Error())
+	}
+	expected = &ProbeResult{Sent: 3, Received: 3, MinRTT: 1.5, AvgRTT: 2, MaxRTT: 2.5}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, result)
+	}
+
+	unreachable := `--- 10.0.0.3 ping statistics ---
+3 packets transmitted, 0 received, +3 errors, 100% packet loss, time 2041ms
+`
+	if result, err = parsePing(unreachable); err != nil {
+		t.Fatal(err.Error())
+	}
+	if expected = (&ProbeResult{Sent: 3, Loss: 1}); !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, result)
+	}
+
+	if _, err = parsePing("ping: unknown host"); err == nil {
+		t.Error("Expected an error without statistics")
+	}
+}
+
+func TestParseTraceroute(t *testing.T) {
+	output := `traceroute to 10.0.0.2 (10.0.0.2), 30 hops max, 60 byte packets
+ 1  192.168.0.1  0.512 ms
+ 2  *
+ 3  10.0.0.2  1.250 ms
+`
+	result, err := parseTraceroute(output)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	expected := &ProbeResult{
+		Sent: 1, Received: 1, MinRTT: 1.25, AvgRTT: 1.25, MaxRTT: 1.25,
+		Hops: []ProbeHop{{TTL: 1, Address: "192.168.0.1", RTT: 0.512}, {TTL: 2}, {TTL: 3, Address: "10.0.0.2", RTT: 1.25}},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, result)
+	}
+
+	// the target never replied
+	output = `traceroute to host.example (10.0.0.3), 2 hops max, 60 byte packets
+ 1  192.168.0.1  0.512 ms
+ 2  *
+`
+	if result, err = parseTraceroute(output); err != nil {
+		t.Fatal(err.Error())
+	}
+	if result.Received != 0 || result.Loss != 1 || len(result.Hops) != 2 {
+		t.Errorf("Expected the target to be lost after 2 hops, got %+v", result)
+	}
+}
@@ -0,0 +1,96 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package synthetic
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	Namespace = "Probe"
+)
+
+// ProbeRequest asks an agent to run a probe of a job, Timeout in seconds
+type ProbeRequest struct {
+	Job     string
+	Type    string
+	Target  string
+	Count   int
+	MaxHops int `json:",omitempty"`
+	Timeout int
+}
+
+// ProbeHop is a hop of a traceroute, without address when it didn't reply
+type ProbeHop struct {
+	TTL     int
+	Address string  `json:",omitempty"`
+	RTT     float64 `json:",omitempty"`
+}
+
+// ProbeResult is the result of a probe, the round trip times in
+// milliseconds. A traceroute sends a single probe, received when the target
+// replied.
+type ProbeResult struct {
+	Job      string
+	Target   string
+	Sent     int
+	Received int
+	Loss     float64
+	MinRTT   float64
+	AvgRTT   float64
+	MaxRTT   float64
+	Hops     []ProbeHop `json:",omitempty"`
+	Error    string     `json:",omitempty"`
+}
+
+// probeLimiter is a token bucket holding up to limit probes, refilled of
+// limit probes per minute
+type probeLimiter struct {
+	sync.Mutex
+	limit  float64
+	tokens float64
+	last   time.Time
+}
+
+func (l *probeLimiter) allow(now time.Time) bool {
+	l.Lock()
+	defer l.Unlock()
+
+	l.tokens += now.Sub(l.last).Minutes() * l.limit
+	if l.tokens > l.limit {
+		l.tokens = l.limit
+	}
+	l.last = now
+
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+
+	return true
+}
+
+func newProbeLimiter(limit int, now time.Time) *probeLimiter {
+	return &probeLimiter{limit: float64(limit), tokens: float64(limit), last: now}
+}
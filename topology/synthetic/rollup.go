@@ -0,0 +1,137 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package synthetic
+
+import (
+	"time"
+)
+
+// ProbeStats sums up the runs of a probe from Start, a unix timestamp, over
+// a period of the rollup. Loss is the ratio of the packets lost and the
+// round trip times, in milliseconds, cover the runs which got a reply.
+type ProbeStats struct {
+	Start    int64
+	Runs     int
+	Errors   int
+	Sent     int
+	Received int
+	Loss     float64
+	MinRTT   float64
+	AvgRTT   float64
+	MaxRTT   float64
+}
+
+// ProbeSeries is the time series of the runs of a job from an agent, by
+// minute and by hour
+type ProbeSeries struct {
+	Job     string
+	Agent   string
+	Minutes []ProbeStats
+	Hours   []ProbeStats
+}
+
+type rollupBucket struct {
+	stats   ProbeStats
+	rttSum  float64
+	rttRuns int
+}
+
+func (b *rollupBucket) add(result *ProbeResult) {
+	b.stats.Runs++
+	if result.Error != "" {
+		b.stats.Errors++
+		return
+	}
+
+	b.stats.Sent += result.Sent
+	b.stats.Received += result.Received
+	if b.stats.Sent > 0 {
+		b.stats.Loss = 1 - float64(b.stats.Received)/float64(b.stats.Sent)
+	}
+
+	if result.Received == 0 {
+		return
+	}
+	if b.rttRuns == 0 || result.MinRTT < b.stats.MinRTT {
+		b.stats.MinRTT = result.MinRTT
+	}
+	if result.MaxRTT > b.stats.MaxRTT {
+		b.stats.MaxRTT = result.MaxRTT
+	}
+	b.rttSum += result.AvgRTT
+	b.rttRuns++
+	b.stats.AvgRTT = b.rttSum / float64(b.rttRuns)
+}
+
+// rollup keeps the stats of the last size periods of resolution
+type rollup struct {
+	resolution time.Duration
+	size       int
+	buckets    []*rollupBucket
+}
+
+func (r *rollup) add(result *ProbeResult, now time.Time) {
+	start := now.Truncate(r.resolution).Unix()
+
+	var bucket *rollupBucket
+	if n := len(r.buckets); n > 0 && r.buckets[n-1].stats.Start == start {
+		bucket = r.buckets[n-1]
+	} else {
+		bucket = &rollupBucket{stats: ProbeStats{Start: start}}
+		r.buckets = append(r.buckets, bucket)
+	}
+	bucket.add(result)
+
+	// the periods without run leave no bucket
+	oldest := now.Add(-time.Duration(r.size) * r.resolution).Unix()
+	for len(r.buckets) > 0 && r.buckets[0].stats.Start <= oldest {
+		r.buckets = r.buckets[1:]
+	}
+}
+
+func (r *rollup) stats() []ProbeStats {
+	stats := make([]ProbeStats, len(r.buckets))
+	for i, b := range r.buckets {
+		stats[i] = b.stats
+	}
+	return stats
+}
+
+// probeSeries rolls up the results of a job run from an agent by minute
+// over an hour and by hour over two days
+type probeSeries struct {
+	minutes rollup
+	hours   rollup
+}
+
+func (s *probeSeries) add(result *ProbeResult, now time.Time) {
+	s.minutes.add(result, now)
+	s.hours.add(result, now)
+}
+
+func newProbeSeries() *probeSeries {
+	return &probeSeries{
+		minutes: rollup{resolution: time.Minute, size: 60},
+		hours:   rollup{resolution: time.Hour, size: 48},
+	}
+}
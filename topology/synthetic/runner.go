@@ -0,0 +1,129 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package synthetic
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/redhat-cip/skydive/config"
+	shttp "github.com/redhat-cip/skydive/http"
+	"github.com/redhat-cip/skydive/logging"
+)
+
+// MessageSender sends the messages of the agent to the analyzer
+type MessageSender interface {
+	SendWSMessage(m shttp.WSMessage)
+}
+
+// Runner runs on the agent the probes requested by the analyzer, limited to
+// a number of probes per minute and of probes running at once, the requests
+// exceeding these limits being replied with an error
+type Runner struct {
+	shttp.DefaultWSClientEventHandler
+	Sender   MessageSender
+	Executor Executor
+	limiter  *probeLimiter
+	slots    chan bool
+}
+
+func (r *Runner) reply(uuid string, result *ProbeResult) {
+	b, _ := json.Marshal(result)
+	raw := json.RawMessage(b)
+
+	r.Sender.SendWSMessage(shttp.WSMessage{
+		Namespace: Namespace,
+		Type:      "ProbeResult",
+		UUID:      uuid,
+		Obj:       &raw,
+	})
+}
+
+func (r *Runner) run(uuid string, req *ProbeRequest) {
+	defer func() { <-r.slots }()
+
+	result, err := r.Executor.Execute(req)
+	if err != nil {
+		logging.GetLogger().Errorf("Probe of %s toward %s failed: %s", req.Job, req.Target, err.Error())
+		result = &ProbeResult{Error: err.Error()}
+	}
+	result.Job, result.Target = req.Job, req.Target
+
+	r.reply(uuid, result)
+}
+
+func (r *Runner) OnMessage(msg shttp.WSMessage) {
+	if msg.Namespace != Namespace || msg.Type != "ProbeRequest" || msg.Obj == nil {
+		return
+	}
+
+	var req ProbeRequest
+	if err := json.Unmarshal([]byte(*msg.Obj), &req); err != nil {
+		logging.GetLogger().Errorf("Unable to decode probe request %v", msg)
+		return
+	}
+
+	if r.limiter != nil && !r.limiter.allow(time.Now()) {
+		r.reply(msg.UUID, &ProbeResult{Job: req.Job, Target: req.Target, Error: "rate limited"})
+		return
+	}
+
+	select {
+	case r.slots <- true:
+		go r.run(msg.UUID, &req)
+	default:
+		r.reply(msg.UUID, &ProbeResult{Job: req.Job, Target: req.Target, Error: "too many probes running"})
+	}
+}
+
+func NewRunner(sender MessageSender, executor Executor, rateLimit int, maxConcurrent int) *Runner {
+	if maxConcurrent <= 0 {
+		maxConcurrent = 1
+	}
+
+	r := &Runner{
+		Sender:   sender,
+		Executor: executor,
+		slots:    make(chan bool, maxConcurrent),
+	}
+	if rateLimit > 0 {
+		r.limiter = newProbeLimiter(rateLimit, time.Now())
+	}
+
+	return r
+}
+
+// NewRunnerFromConfig returns the runner configured by
+// agent.synthetic_probes, replying through the client connection, nil when
+// disabled
+func NewRunnerFromConfig(client *shttp.WSAsyncClient) *Runner {
+	cfg := config.GetConfig()
+	if !cfg.GetBool("agent.synthetic_probes.enabled") {
+		return nil
+	}
+
+	r := NewRunner(client, NewCommandExecutor(), cfg.GetInt("agent.synthetic_probes.rate_limit"), cfg.GetInt("agent.synthetic_probes.max_concurrent"))
+	client.AddEventHandler(r)
+
+	return r
+}
@@ -0,0 +1,757 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package synthetic
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/abbot/go-http-auth"
+	"github.com/gorilla/mux"
+	"github.com/nu7hatch/gouuid"
+
+	"github.com/redhat-cip/skydive/api"
+	"github.com/redhat-cip/skydive/config"
+	shttp "github.com/redhat-cip/skydive/http"
+	"github.com/redhat-cip/skydive/logging"
+	"github.com/redhat-cip/skydive/topology"
+	"github.com/redhat-cip/skydive/topology/alert"
+	"github.com/redhat-cip/skydive/topology/graph"
+)
+
+const (
+	// origin of the edges and of the hop nodes added by the probes
+	ProbeOrigin = "probe"
+	// the requests not replied within pendingExpiry are forgotten
+	pendingExpiry = 10 * time.Minute
+)
+
+// AgentSender sends the messages of the analyzer to the agents
+type AgentSender interface {
+	SendWSMessageTo(msg shttp.WSMessage, host string) bool
+}
+
+// ProbeAlert is the reason data of the alerts of the probes
+type ProbeAlert struct {
+	Job    string
+	Name   string
+	Agent  string
+	Result *ProbeResult
+}
+
+// pendingProbe is a request sent to an agent, the source nodes being the
+// ones selected when it was sent
+type pendingProbe struct {
+	job     string
+	agent   string
+	sources []graph.Identifier
+	target  graph.Identifier
+	address string
+	sent    time.Time
+}
+
+type probeKey struct {
+	job   string
+	agent string
+}
+
+type probeState struct {
+	series  *probeSeries
+	failing bool
+	fires   int
+	sources []graph.Identifier
+	// probe edges by source node
+	edges map[graph.Identifier]graph.Identifier
+}
+
+// Scheduler sends the probe jobs to the agents when they are due, at most
+// agentLimit probes per minute to each agent, and enriches the topology with
+// their results: the source nodes get the loss and the round trip time of
+// the job in the Probe.<name> metadata, a probe edge links them to the
+// target node and, for the jobs discovering the hops, the hops of the
+// traceroutes are linked from the source to the target, the unknown ones
+// being added as probehop nodes. The results are rolled up in time series
+// and the jobs exceeding their thresholds fire alerts.
+type Scheduler struct {
+	shttp.DefaultWSServerEventHandler
+	sync.Mutex
+	Graph      *graph.Graph
+	handler    api.ApiHandler
+	sender     AgentSender
+	alerts     *alert.AlertManager
+	agentLimit int
+	interval   time.Duration
+	limiters   map[string]*probeLimiter
+	lastRun    map[string]time.Time
+	pending    map[string]*pendingProbe
+	probes     map[probeKey]*probeState
+	watcher    api.StoppableWatcher
+	running    bool
+	quit       chan bool
+	wg         sync.WaitGroup
+}
+
+var metadataNameReplacer = regexp.MustCompile("[^a-zA-Z0-9_-]+")
+
+// metadataPrefix returns the prefix of the metadata of a job on its source
+// nodes
+func metadataPrefix(job *api.ProbeJob) string {
+	return "Probe." + metadataNameReplacer.ReplaceAllString(job.Name, "_") + "."
+}
+
+// nodeAddresses returns the IPv4 addresses of a node, without their prefix
+// length
+func nodeAddresses(n *graph.Node) []string {
+	ipv4, _ := n.Metadata()["IPV4"].(string)
+
+	var addresses []string
+	for _, cidr := range strings.Split(ipv4, ",") {
+		if cidr = strings.TrimSpace(cidr); cidr != "" {
+			addresses = append(addresses, strings.SplitN(cidr, "/", 2)[0])
+		}
+	}
+	return addresses
+}
+
+// query returns the nodes selected by a Gremlin query, called with the graph
+// lock held
+func (s *Scheduler) query(q string) ([]*graph.Node, error) {
+	tr := graph.NewGremlinTraversalParser(strings.NewReader(q), s.Graph)
+	tr.AddTraversalExtension(topology.NewTopologyTraversalExtension())
+
+	ts, err := tr.Parse()
+	if err != nil {
+		return nil, err
+	}
+	if ts.HasWriteSteps() {
+		return nil, fmt.Errorf("Query %s modifies the graph", q)
+	}
+
+	res, err := ts.Exec()
+	if err != nil {
+		return nil, err
+	}
+
+	var nodes []*graph.Node
+	for _, value := range res.Values() {
+		if n, ok := value.(*graph.Node); ok {
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes, nil
+}
+
+// sources returns the source nodes of a job by agent
+func (s *Scheduler) sources(job *api.ProbeJob) (map[string][]graph.Identifier, error) {
+	s.Graph.RLock()
+	defer s.Graph.RUnlock()
+
+	sources := make(map[string][]graph.Identifier)
+	if job.Agent != "" {
+		sources[job.Agent] = nil
+		if n := s.Graph.LookupFirstNode(graph.Metadata{"Name": job.Agent, "Type": "host"}); n != nil {
+			sources[job.Agent] = []graph.Identifier{n.ID}
+		}
+		return sources, nil
+	}
+
+	nodes, err := s.query(job.Source)
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range nodes {
+		if host := n.Host(); host != "" {
+			sources[host] = append(sources[host], n.ID)
+		}
+	}
+	return sources, nil
+}
+
+// target returns the address probed by a job, along with the target node
+// when selected by TargetNode
+func (s *Scheduler) target(job *api.ProbeJob) (graph.Identifier, string, error) {
+	if job.Target != "" {
+		return "", job.Target, nil
+	}
+
+	s.Graph.RLock()
+	defer s.Graph.RUnlock()
+
+	nodes, err := s.query(job.TargetNode)
+	if err != nil {
+		return "", "", err
+	}
+	for _, n := range nodes {
+		if addresses := nodeAddresses(n); len(addresses) > 0 {
+			return n.ID, addresses[0], nil
+		}
+	}
+	return "", "", fmt.Errorf("No IPv4 address on the target nodes %s", job.TargetNode)
+}
+
+// allow tells whether a probe can be sent to the agent
+func (s *Scheduler) allow(agent string, now time.Time) bool {
+	if s.agentLimit <= 0 {
+		return true
+	}
+
+	s.Lock()
+	limiter, ok := s.limiters[agent]
+	if !ok {
+		limiter = newProbeLimiter(s.agentLimit, now)
+		s.limiters[agent] = limiter
+	}
+	s.Unlock()
+
+	return limiter.allow(now)
+}
+
+// dispatch sends a probe request of the job to each of its agents
+func (s *Scheduler) dispatch(job *api.ProbeJob, now time.Time) {
+	sources, err := s.sources(job)
+	if err != nil {
+		logging.GetLogger().Errorf("Unable to select the sources of the probe job %s: %s", job.Name, err.Error())
+		return
+	}
+	target, address, err := s.target(job)
+	if err != nil {
+		logging.GetLogger().Errorf("Unable to select the target of the probe job %s: %s", job.Name, err.Error())
+		return
+	}
+
+	var agents []string
+	for agent := range sources {
+		agents = append(agents, agent)
+	}
+	sort.Strings(agents)
+
+	req := &ProbeRequest{
+		Job:     job.UUID,
+		Type:    job.Type,
+		Target:  address,
+		Count:   job.ProbeCount(),
+		Timeout: job.ProbeTimeout(),
+	}
+	if job.Type == api.ProbeJobTraceroute {
+		req.Count, req.MaxHops = 1, job.ProbeMaxHops()
+	}
+	b, _ := json.Marshal(req)
+	raw := json.RawMessage(b)
+
+	for _, agent := range agents {
+		if !s.allow(agent, now) {
+			logging.GetLogger().Warningf("Probe of the job %s from %s skipped, limited to %d probes per minute", job.Name, agent, s.agentLimit)
+			continue
+		}
+
+		u, _ := uuid.NewV4()
+		id := u.String()
+
+		// registered first, the reply may come before the send returns
+		s.Lock()
+		s.pending[id] = &pendingProbe{job: job.UUID, agent: agent, sources: sources[agent], target: target, address: address, sent: now}
+		s.Unlock()
+
+		msg := shttp.WSMessage{Namespace: Namespace, Type: "ProbeRequest", UUID: id, Obj: &raw}
+		if !s.sender.SendWSMessageTo(msg, agent) {
+			logging.GetLogger().Debugf("Probe of the job %s not sent, agent %s not connected", job.Name, agent)
+
+			s.Lock()
+			delete(s.pending, id)
+			s.Unlock()
+		}
+	}
+}
+
+// runDue dispatches the jobs due, forgetting the requests never replied
+func (s *Scheduler) runDue(now time.Time) {
+	for _, resource := range s.handler.Index() {
+		job := resource.(*api.ProbeJob)
+
+		s.Lock()
+		last, ok := s.lastRun[job.UUID]
+		due := !ok || now.Sub(last) >= time.Duration(job.Interval)*time.Second
+		if due {
+			s.lastRun[job.UUID] = now
+		}
+		s.Unlock()
+
+		if due {
+			s.dispatch(job, now)
+		}
+	}
+
+	s.Lock()
+	for id, p := range s.pending {
+		if now.Sub(p.sent) > pendingExpiry {
+			delete(s.pending, id)
+		}
+	}
+	s.Unlock()
+}
+
+// violation returns the reason of the alert of a result exceeding the
+// thresholds of the job, if any
+func violation(job *api.ProbeJob, agent string, result *ProbeResult) (bool, string) {
+	if job.MaxLoss > 0 && result.Loss > job.MaxLoss {
+		return true, fmt.Sprintf("Probe %s from %s toward %s lost %.0f%% of its packets, above %.0f%%",
+			job.Name, agent, result.Target, result.Loss*100, job.MaxLoss*100)
+	}
+	if job.MaxRTT > 0 && result.Received > 0 && result.AvgRTT > job.MaxRTT {
+		return true, fmt.Sprintf("Probe %s from %s toward %s replied in %.3fms, above %.3fms",
+			job.Name, agent, result.Target, result.AvgRTT, job.MaxRTT)
+	}
+	return false, ""
+}
+
+// hopNode returns the node of a hop of a traceroute, a node having its
+// address or the hop node added by a previous traceroute, added if none,
+// called with the graph lock held
+func (s *Scheduler) hopNode(address string, known map[string]*graph.Node) *graph.Node {
+	if n, ok := known[address]; ok {
+		return n
+	}
+
+	n := s.Graph.LookupFirstNode(graph.Metadata{"Type": "probehop", "Address": address})
+	if n == nil {
+		n = s.Graph.NewNode(graph.GenID(), graph.Metadata{
+			"Name":          address,
+			"Type":          "probehop",
+			"Address":       address,
+			graph.OriginKey: ProbeOrigin,
+		})
+	}
+	known[address] = n
+	return n
+}
+
+// linkHops links the hops of a traceroute from the source nodes, the hops
+// which didn't reply being skipped, called with the graph lock held
+func (s *Scheduler) linkHops(sources []*graph.Node, target *graph.Node, p *pendingProbe, result *ProbeResult) {
+	// the nodes known by their addresses, the target first
+	known := make(map[string]*graph.Node)
+	if target != nil {
+		known[p.address] = target
+	}
+
+	wanted := make(map[string]bool)
+	for _, hop := range result.Hops {
+		if hop.Address != "" && known[hop.Address] == nil {
+			wanted[hop.Address] = true
+		}
+	}
+	for _, n := range s.Graph.GetNodes() {
+		if n.Metadata()["Type"] == "probehop" {
+			continue
+		}
+		for _, address := range nodeAddresses(n) {
+			if wanted[address] && known[address] == nil {
+				known[address] = n
+			}
+		}
+	}
+
+	previous := sources
+	for _, hop := range result.Hops {
+		if hop.Address == "" {
+			continue
+		}
+
+		n := s.hopNode(hop.Address, known)
+		for _, prev := range previous {
+			if prev.ID != n.ID && !s.Graph.AreLinked(prev, n) {
+				s.Graph.Link(prev, n, graph.Metadata{"RelationType": "probehop", graph.OriginKey: ProbeOrigin})
+			}
+		}
+		previous = []*graph.Node{n}
+	}
+}
+
+// enrich sets the result on the source nodes and on the probe edges
+func (s *Scheduler) enrich(job *api.ProbeJob, st *probeState, p *pendingProbe, result *ProbeResult, now time.Time) {
+	s.Graph.Lock()
+	defer s.Graph.Unlock()
+
+	var path []string
+	for _, hop := range result.Hops {
+		if hop.Address == "" {
+			path = append(path, "*")
+		} else {
+			path = append(path, hop.Address)
+		}
+	}
+
+	var sources []*graph.Node
+	prefix := metadataPrefix(job)
+	for _, id := range p.sources {
+		n := s.Graph.GetNode(id)
+		if n == nil {
+			continue
+		}
+		sources = append(sources, n)
+
+		tr := s.Graph.StartMetadataTransaction(n)
+		tr.AddMetadata(prefix+"Target", result.Target)
+		tr.AddMetadata(prefix+"Loss", result.Loss)
+		tr.AddMetadata(prefix+"RTT", result.AvgRTT)
+		tr.AddMetadata(prefix+"Updated", now.Unix())
+		tr.Commit()
+	}
+
+	var target *graph.Node
+	if p.target != "" {
+		target = s.Graph.GetNode(p.target)
+	}
+
+	if target != nil {
+		for _, n := range sources {
+			s.Lock()
+			edge := s.Graph.GetEdge(st.edges[n.ID])
+			s.Unlock()
+
+			if edge == nil {
+				edge = s.Graph.NewEdge(graph.GenID(), n, target, graph.Metadata{
+					"RelationType":  "probe",
+					"Probe.Job":     job.UUID,
+					graph.OriginKey: ProbeOrigin,
+				})
+				if edge == nil {
+					continue
+				}
+
+				s.Lock()
+				st.edges[n.ID] = edge.ID
+				s.Unlock()
+			}
+
+			tr := s.Graph.StartMetadataTransaction(edge)
+			tr.AddMetadata("Probe.Name", job.Name)
+			tr.AddMetadata("Probe.Loss", result.Loss)
+			tr.AddMetadata("Probe.RTT", result.AvgRTT)
+			tr.AddMetadata("Probe.Updated", now.Unix())
+			if len(path) > 0 {
+				tr.AddMetadata("Probe.Path", strings.Join(path, " "))
+			}
+			tr.Commit()
+		}
+	}
+
+	if job.DiscoverHops && len(sources) > 0 {
+		s.linkHops(sources, target, p, result)
+	}
+}
+
+// record rolls up the result of a probe, enriches the topology and fires
+// the alert of the job when it starts exceeding its thresholds
+func (s *Scheduler) record(job *api.ProbeJob, p *pendingProbe, result *ProbeResult, now time.Time) {
+	key := probeKey{job: job.UUID, agent: p.agent}
+
+	s.Lock()
+	st, ok := s.probes[key]
+	if !ok {
+		st = &probeState{series: newProbeSeries(), edges: make(map[graph.Identifier]graph.Identifier)}
+		s.probes[key] = st
+	}
+	st.series.add(result, now)
+
+	var msg *alert.AlertMessage
+	if result.Error == "" {
+		st.sources = p.sources
+
+		// fired once, until the results are back to normal
+		violated, reason := violation(job, p.agent, result)
+		if violated && !st.failing {
+			st.fires++
+			msg = &alert.AlertMessage{
+				UUID:       job.UUID,
+				Type:       alert.PROBE,
+				Timestamp:  now,
+				Count:      st.fires,
+				Reason:     reason,
+				ReasonData: &ProbeAlert{Job: job.UUID, Name: job.Name, Agent: p.agent, Result: result},
+			}
+		}
+		st.failing = violated
+	}
+	s.Unlock()
+
+	if result.Error != "" {
+		logging.GetLogger().Warningf("Probe of the job %s failed on %s: %s", job.Name, p.agent, result.Error)
+		return
+	}
+
+	s.enrich(job, st, p, result, now)
+
+	if msg != nil && s.alerts != nil {
+		logging.GetLogger().Warning(msg.Reason)
+		s.alerts.Notify(msg)
+	}
+}
+
+// onMessage handles the results replied by the agent host, the results
+// not matching a request sent to this agent being ignored
+func (s *Scheduler) onMessage(host string, m shttp.WSMessage, now time.Time) {
+	if m.Namespace != Namespace || m.Type != "ProbeResult" || m.Obj == nil {
+		return
+	}
+
+	var result ProbeResult
+	if err := json.Unmarshal([]byte(*m.Obj), &result); err != nil {
+		logging.GetLogger().Errorf("Unable to decode the probe result of %s: %s", host, err.Error())
+		return
+	}
+
+	s.Lock()
+	p, ok := s.pending[m.UUID]
+	if ok && p.agent == host {
+		delete(s.pending, m.UUID)
+	}
+	s.Unlock()
+
+	if !ok || p.agent != host {
+		logging.GetLogger().Debugf("Unexpected probe result %s from %s", m.UUID, host)
+		return
+	}
+
+	resource, ok := s.handler.Get(p.job)
+	if !ok {
+		return
+	}
+	s.record(resource.(*api.ProbeJob), p, &result, now)
+}
+
+func (s *Scheduler) OnMessage(c *shttp.WSClient, m shttp.WSMessage) {
+	s.onMessage(c.Host(), m, time.Now())
+}
+
+type sortSeriesByAgent []ProbeSeries
+
+func (s sortSeriesByAgent) Len() int {
+	return len(s)
+}
+
+func (s sortSeriesByAgent) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s sortSeriesByAgent) Less(i, j int) bool {
+	return s[i].Agent < s[j].Agent
+}
+
+// Series returns the time series of a job by agent
+func (s *Scheduler) Series(id string) []ProbeSeries {
+	s.Lock()
+	defer s.Unlock()
+
+	series := []ProbeSeries{}
+	for key, st := range s.probes {
+		if key.job == id {
+			series = append(series, ProbeSeries{
+				Job:     key.job,
+				Agent:   key.agent,
+				Minutes: st.series.minutes.stats(),
+				Hours:   st.series.hours.stats(),
+			})
+		}
+	}
+	sort.Sort(sortSeriesByAgent(series))
+
+	return series
+}
+
+// forget drops the state of a deleted job, along with its probe edges and
+// its metadata
+func (s *Scheduler) forget(id string) {
+	s.Lock()
+	delete(s.lastRun, id)
+	for uuid, p := range s.pending {
+		if p.job == id {
+			delete(s.pending, uuid)
+		}
+	}
+
+	var edges, sources []graph.Identifier
+	for key, st := range s.probes {
+		if key.job != id {
+			continue
+		}
+		for _, edge := range st.edges {
+			edges = append(edges, edge)
+		}
+		sources = append(sources, st.sources...)
+		delete(s.probes, key)
+	}
+	s.Unlock()
+
+	s.Graph.Lock()
+	defer s.Graph.Unlock()
+
+	for _, id := range edges {
+		if e := s.Graph.GetEdge(id); e != nil {
+			s.Graph.DelEdge(e)
+		}
+	}
+	for _, id := range sources {
+		n := s.Graph.GetNode(id)
+		if n == nil {
+			continue
+		}
+
+		m := make(graph.Metadata)
+		removed := false
+		for k, v := range n.Metadata() {
+			if strings.HasPrefix(k, "Probe.") {
+				removed = true
+				continue
+			}
+			m[k] = v
+		}
+		if removed {
+			s.Graph.SetMetadata(n, m)
+		}
+	}
+}
+
+func (s *Scheduler) onApiWatcherEvent(action string, id string, resource api.ApiResource) {
+	switch action {
+	case "expire", "delete":
+		s.forget(id)
+	}
+}
+
+func (s *Scheduler) Start() {
+	s.Lock()
+	s.running = true
+	s.quit = make(chan bool)
+	s.Unlock()
+
+	s.watcher = s.handler.AsyncWatch(s.onApiWatcherEvent)
+
+	s.wg.Add(1)
+	go func(quit chan bool) {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-quit:
+				return
+			case now := <-ticker.C:
+				s.runDue(now)
+			}
+		}
+	}(s.quit)
+}
+
+func (s *Scheduler) Stop() {
+	s.Lock()
+	if !s.running {
+		s.Unlock()
+		return
+	}
+	s.running = false
+	close(s.quit)
+	s.Unlock()
+
+	if s.watcher != nil {
+		s.watcher.Stop()
+		s.watcher = nil
+	}
+	s.wg.Wait()
+}
+
+func (s *Scheduler) series(w http.ResponseWriter, r *auth.AuthenticatedRequest) {
+	id := mux.Vars(&r.Request)["id"]
+	if _, ok := s.handler.Get(id); !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(s.Series(id)); err != nil {
+		logging.GetLogger().Criticalf("Failed to display the series of the probe job %s: %s", id, err.Error())
+	}
+}
+
+// NewScheduler returns a scheduler checking the jobs due every interval
+func NewScheduler(handler api.ApiHandler, g *graph.Graph, sender AgentSender, am *alert.AlertManager, agentLimit int, interval time.Duration) *Scheduler {
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	return &Scheduler{
+		Graph:      g,
+		handler:    handler,
+		sender:     sender,
+		alerts:     am,
+		agentLimit: agentLimit,
+		interval:   interval,
+		limiters:   make(map[string]*probeLimiter),
+		lastRun:    make(map[string]time.Time),
+		pending:    make(map[string]*pendingProbe),
+		probes:     make(map[probeKey]*probeState),
+	}
+}
+
+// RegisterProbeJobApi registers the probe jobs, created and deleted on the
+// admin address, along with the route giving their time series, GET
+// /api/probejob/<id>/series. The scheduler returned sends the jobs to the
+// agents connected to the websocket server.
+func RegisterProbeJobApi(a *api.ApiServer, g *graph.Graph, server *shttp.WSServer, am *alert.AlertManager) (*Scheduler, error) {
+	handler := &api.BasicApiHandler{
+		ResourceHandler: &api.ProbeJobHandler{},
+		EtcdKeyAPI:      a.EtcdKeyAPI,
+	}
+
+	cfg := config.GetConfig()
+	scheduler := NewScheduler(handler, g, server, am,
+		cfg.GetInt("analyzer.synthetic_probes.agent_rate_limit"),
+		time.Duration(cfg.GetInt("analyzer.synthetic_probes.check_interval"))*time.Second)
+
+	// registered before the generic routes of the jobs to take precedence
+	// over the one showing a job
+	a.HTTPServer.RegisterRoutes([]shttp.Route{
+		{
+			"ProbeJobSeries",
+			"GET",
+			"/api/probejob/{id}/series",
+			scheduler.series,
+		},
+	})
+
+	if err := a.RegisterAdminApiHandler(handler); err != nil {
+		return nil, err
+	}
+	server.AddEventHandler(scheduler)
+
+	return scheduler, nil
+}
@@ -0,0 +1,403 @@
+/*
+ * Copyright (C) 2016 Red Hat, Inc.
+ *
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *  http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ *
+ */
+
+package synthetic
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redhat-cip/skydive/api"
+	shttp "github.com/redhat-cip/skydive/http"
+	"github.com/redhat-cip/skydive/topology/alert"
+	"github.com/redhat-cip/skydive/topology/graph"
+)
+
+// memoryJobHandler keeps the probe jobs in memory in place of etcd
+type memoryJobHandler struct {
+	api.ProbeJobHandler
+	jobs map[string]api.ApiResource
+}
+
+func (h *memoryJobHandler) Index() map[string]api.ApiResource {
+	return h.jobs
+}
+
+func (h *memoryJobHandler) Get(id string) (api.ApiResource, bool) {
+	job, ok := h.jobs[id]
+	return job, ok
+}
+
+func (h *memoryJobHandler) Create(resource api.ApiResource) error {
+	h.jobs[resource.ID()] = resource
+	return nil
+}
+
+func (h *memoryJobHandler) Delete(id string) error {
+	delete(h.jobs, id)
+	return nil
+}
+
+func (h *memoryJobHandler) AsyncWatch(f api.ApiWatcherCallback) api.StoppableWatcher {
+	return nil
+}
+
+// scriptedExecutor replies the scripted results in turn
+type scriptedExecutor struct {
+	sync.Mutex
+	results  []*ProbeResult
+	requests []*ProbeRequest
+}
+
+func (e *scriptedExecutor) Execute(req *ProbeRequest) (*ProbeResult, error) {
+	e.Lock()
+	defer e.Unlock()
+
+	e.requests = append(e.requests, req)
+	if len(e.results) == 0 {
+		return nil, errors.New("no scripted result")
+	}
+	result := *e.results[0]
+	e.results = e.results[1:]
+	return &result, nil
+}
+
+type agentReply struct {
+	host string
+	msg  shttp.WSMessage
+}
+
+// simulatedAgent is the connection of an agent running the probes with a
+// scripted executor, its replies being delivered by the test
+type simulatedAgent struct {
+	host     string
+	runner   *Runner
+	executor *scriptedExecutor
+	replies  chan agentReply
+}
+
+func (a *simulatedAgent) SendWSMessage(m shttp.WSMessage) {
+	a.replies <- agentReply{host: a.host, msg: m}
+}
+
+type simulatedAgents struct {
+	agents  map[string]*simulatedAgent
+	replies chan agentReply
+}
+
+func (s *simulatedAgents) SendWSMessageTo(msg shttp.WSMessage, host string) bool {
+	agent, ok := s.agents[host]
+	if !ok {
+		return false
+	}
+	agent.runner.OnMessage(msg)
+	return true
+}
+
+func (s *simulatedAgents) add(host string, rateLimit int, results ...*ProbeResult) *simulatedAgent {
+	agent := &simulatedAgent{
+		host:     host,
+		executor: &scriptedExecutor{results: results},
+		replies:  s.replies,
+	}
+	agent.runner = NewRunner(agent, agent.executor, rateLimit, 4)
+	s.agents[host] = agent
+	return agent
+}
+
+// deliver hands n replies of the agents over to the scheduler
+func (s *simulatedAgents) deliver(t *testing.T, scheduler *Scheduler, n int, now time.Time) {
+	for i := 0; i < n; i++ {
+		select {
+		case reply := <-s.replies:
+			scheduler.onMessage(reply.host, reply.msg, now)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Reply %d of %d not received", i+1, n)
+		}
+	}
+}
+
+type alertListener struct {
+	messages []*alert.AlertMessage
+}
+
+func (l *alertListener) OnAlert(msg *alert.AlertMessage) {
+	l.messages = append(l.messages, msg)
+}
+
+// addAgentNode adds a node reported by the agent host
+func addAgentNode(t *testing.T, g *graph.Graph, host string, id string, m graph.Metadata) *graph.Node {
+	data, _ := json.Marshal(map[string]interface{}{"ID": id, "Host": host, "Metadata": m})
+
+	var n graph.Node
+	if err := json.Unmarshal(data, &n); err != nil {
+		t.Fatal(err.Error())
+	}
+	g.AddNode(&n)
+
+	return g.GetNode(graph.Identifier(id))
+}
+
+func newTestScheduler(t *testing.T, agentLimit int) (*Scheduler, *simulatedAgents, *alertListener) {
+	b, _ := graph.NewMemoryBackend()
+	g, _ := graph.NewGraph(b)
+
+	agent1 := addAgentNode(t, g, "agent1", "agent1", graph.Metadata{"Name": "agent1", "Type": "host"})
+	eth0 := addAgentNode(t, g, "agent1", "agent1-eth0", graph.Metadata{"Name": "eth0", "Type": "device", "IPV4": "10.0.0.1/24"})
+	g.Link(agent1, eth0, graph.Metadata{"RelationType": "ownership"})
+
+	agent2 := addAgentNode(t, g, "agent2", "agent2", graph.Metadata{"Name": "agent2", "Type": "host"})
+	eth0 = addAgentNode(t, g, "agent2", "agent2-eth0", graph.Metadata{"Name": "eth0", "Type": "device", "IPV4": "10.0.0.2/24, 172.16.0.2/16"})
+	g.Link(agent2, eth0, graph.Metadata{"RelationType": "ownership"})
+
+	// monitored by another agent, found on the path of the traceroutes
+	addAgentNode(t, g, "router", "router-eth1", graph.Metadata{"Name": "eth1", "Type": "device", "IPV4": "192.168.1.1/24"})
+
+	am := alert.NewAlertManager(g, nil)
+	listener := &alertListener{}
+	am.AddEventListener(listener)
+
+	agents := &simulatedAgents{agents: make(map[string]*simulatedAgent), replies: make(chan agentReply, 100)}
+	handler := &memoryJobHandler{jobs: make(map[string]api.ApiResource)}
+
+	return NewScheduler(handler, g, agents, am, agentLimit, time.Second), agents, listener
+}
+
+func newTestJob(s *Scheduler, name string) *api.ProbeJob {
+	job := api.NewProbeJob()
+	job.Name = name
+	job.Interval = 10
+	s.handler.Create(job)
+	return job
+}
+
+func probeEdges(g *graph.Graph, relation string) []*graph.Edge {
+	var edges []*graph.Edge
+	for _, e := range g.GetEdges() {
+		if e.Metadata()["RelationType"] == relation {
+			edges = append(edges, e)
+		}
+	}
+	return edges
+}
+
+func TestSchedulerPing(t *testing.T) {
+	s, agents, listener := newTestScheduler(t, 0)
+
+	job := newTestJob(s, "agent1 to agent2")
+	job.Agent = "agent1"
+	job.TargetNode = "G.V().Has('Name', 'eth0', 'IPV4', '10.0.0.2/24, 172.16.0.2/16')"
+	job.MaxLoss, job.MaxRTT = 0.2, 50
+
+	agent := agents.add("agent1", 0,
+		&ProbeResult{Sent: 3, Received: 3, MinRTT: 1, AvgRTT: 2, MaxRTT: 3},
+		&ProbeResult{Sent: 3, Received: 1, Loss: 2.0 / 3, MinRTT: 5, AvgRTT: 5, MaxRTT: 5},
+		&ProbeResult{Sent: 3, Received: 1, Loss: 2.0 / 3, MinRTT: 5, AvgRTT: 5, MaxRTT: 5},
+		&ProbeResult{Sent: 3, Received: 3, MinRTT: 1, AvgRTT: 2, MaxRTT: 3},
+		&ProbeResult{Sent: 3, Received: 3, MinRTT: 70, AvgRTT: 80, MaxRTT: 90},
+	)
+
+	start := time.Unix(1500000000, 0).Truncate(time.Minute)
+	for i := 0; i < 10; i++ {
+		// due every 10 seconds
+		now := start.Add(time.Duration(i) * 5 * time.Second)
+		s.runDue(now)
+		if i%2 == 0 {
+			agents.deliver(t, s, 1, now)
+		}
+	}
+
+	if n := len(agent.executor.requests); n != 5 {
+		t.Fatalf("Expected 5 runs of the job, got %d", n)
+	}
+	if req := agent.executor.requests[0]; req.Target != "10.0.0.2" || req.Count != 3 || req.Type != "ping" {
+		t.Errorf("Expected 3 pings toward the first address of the target node, got %+v", req)
+	}
+
+	// the last result on the source node and on the probe edge
+	m := s.Graph.GetNode("agent1").Metadata()
+	if m["Probe.agent1_to_agent2.RTT"] != 80.0 || m["Probe.agent1_to_agent2.Loss"] != 0.0 || m["Probe.agent1_to_agent2.Target"] != "10.0.0.2" {
+		t.Errorf("Wrong metadata of the source node: %v", m)
+	}
+
+	edges := probeEdges(s.Graph, "probe")
+	if len(edges) != 1 {
+		t.Fatalf("Expected a probe edge, got %d", len(edges))
+	}
+	parent, child := s.Graph.GetEdgeNodes(edges[0])
+	if m := edges[0].Metadata(); parent.ID != "agent1" || child.ID != "agent2-eth0" || m["Probe.RTT"] != 80.0 || m[graph.OriginKey] != ProbeOrigin {
+		t.Errorf("Wrong probe edge %s -> %s: %v", parent.ID, child.ID, m)
+	}
+
+	// fired when the loss exceeds its threshold, then when the round trip
+	// time does
+	if len(listener.messages) != 2 {
+		t.Fatalf("Expected 2 alerts, got %d", len(listener.messages))
+	}
+	for i, msg := range listener.messages {
+		if msg.UUID != job.UUID || msg.Type != alert.PROBE || msg.Count != i+1 {
+			t.Errorf("Wrong alert %d: %+v", i+1, msg)
+		}
+	}
+	if data := listener.messages[0].ReasonData.(*ProbeAlert); data.Agent != "agent1" || data.Result.Received != 1 {
+		t.Errorf("Wrong reason of the loss alert: %+v", data)
+	}
+	if data := listener.messages[1].ReasonData.(*ProbeAlert); data.Result.AvgRTT != 80 {
+		t.Errorf("Wrong reason of the round trip time alert: %+v", data)
+	}
+
+	series := s.Series(job.UUID)
+	if len(series) != 1 || series[0].Agent != "agent1" {
+		t.Fatalf("Expected the series of agent1, got %+v", series)
+	}
+	received, sent := 11.0, 15.0
+	expected := ProbeStats{Start: start.Unix(), Runs: 5, Sent: 15, Received: 11, Loss: 1 - received/sent, MinRTT: 1, AvgRTT: 18.8, MaxRTT: 90}
+	if len(series[0].Minutes) != 1 || series[0].Minutes[0] != expected {
+		t.Errorf("Expected the minute %+v, got %+v", expected, series[0].Minutes)
+	}
+	expected.Start = start.Truncate(time.Hour).Unix()
+	if len(series[0].Hours) != 1 || series[0].Hours[0] != expected {
+		t.Errorf("Expected the hour %+v, got %+v", expected, series[0].Hours)
+	}
+
+	// the results not requested to the agent are ignored
+	raw := json.RawMessage(`{"Sent": 3}`)
+	s.onMessage("agent2", shttp.WSMessage{Namespace: Namespace, Type: "ProbeResult", UUID: "unknown", Obj: &raw}, start)
+	if len(s.Series(job.UUID)) != 1 {
+		t.Error("Unexpected result recorded")
+	}
+}
+
+func TestSchedulerTracerouteHops(t *testing.T) {
+	s, agents, _ := newTestScheduler(t, 0)
+
+	job := newTestJob(s, "path")
+	job.Type = api.ProbeJobTraceroute
+	job.Source = "G.V().Has('Name', 'agent1')"
+	job.TargetNode = "G.V().Has('Name', 'eth0', 'IPV4', '10.0.0.2/24, 172.16.0.2/16')"
+	job.DiscoverHops = true
+
+	trace := &ProbeResult{
+		Sent: 1, Received: 1, MinRTT: 2, AvgRTT: 2, MaxRTT: 2,
+		Hops: []ProbeHop{
+			{TTL: 1, Address: "10.0.0.254", RTT: 0.5},
+			{TTL: 2},
+			{TTL: 3, Address: "192.168.1.1", RTT: 1},
+			{TTL: 4, Address: "10.0.0.2", RTT: 2},
+		},
+	}
+	agent := agents.add("agent1", 0, trace, trace)
+
+	now := time.Unix(1500000000, 0)
+	for i := 0; i < 2; i++ {
+		s.runDue(now)
+		agents.deliver(t, s, 1, now)
+		now = now.Add(10 * time.Second)
+	}
+
+	if req := agent.executor.requests[0]; req.Type != "traceroute" || req.Count != 1 || req.MaxHops != 30 {
+		t.Errorf("Wrong traceroute request %+v", req)
+	}
+
+	// the hop not monitored is added once, the other ones being the nodes
+	// having their addresses
+	hops := s.Graph.LookupNodes(graph.Metadata{"Type": "probehop"})
+	if len(hops) != 1 || hops[0].Metadata()["Address"] != "10.0.0.254" || hops[0].Metadata()[graph.OriginKey] != ProbeOrigin {
+		t.Fatalf("Expected a single hop node for 10.0.0.254, got %v", hops)
+	}
+
+	links := make(map[string]bool)
+	for _, e := range probeEdges(s.Graph, "probehop") {
+		parent, child := s.Graph.GetEdgeNodes(e)
+		links[string(parent.ID)+" -> "+string(child.ID)] = true
+	}
+	for _, link := range []string{"agent1 -> " + string(hops[0].ID), string(hops[0].ID) + " -> router-eth1", "router-eth1 -> agent2-eth0"} {
+		if !links[link] {
+			t.Errorf("Missing hop link %s in %v", link, links)
+		}
+	}
+	if len(links) != 3 {
+		t.Errorf("Expected 3 hop links, got %v", links)
+	}
+
+	edges := probeEdges(s.Graph, "probe")
+	if len(edges) != 1 || edges[0].Metadata()["Probe.Path"] != "10.0.0.254 * 192.168.1.1 10.0.0.2" {
+		t.Fatalf("Expected a probe edge with the path, got %v", edges)
+	}
+
+	// the probe edge and the metadata go away with the job, the hops
+	// discovered staying in the graph
+	s.forget(job.UUID)
+	if edges := probeEdges(s.Graph, "probe"); len(edges) != 0 {
+		t.Errorf("Expected the probe edge to be deleted, got %v", edges)
+	}
+	if m := s.Graph.GetNode("agent1").Metadata(); m["Probe.path.RTT"] != nil || m["Name"] != "agent1" {
+		t.Errorf("Expected the probe metadata to be removed, got %v", m)
+	}
+	if len(s.Graph.LookupNodes(graph.Metadata{"Type": "probehop"})) != 1 {
+		t.Error("Expected the hop node to stay")
+	}
+}
+
+func TestSchedulerRateLimit(t *testing.T) {
+	s, agents, _ := newTestScheduler(t, 2)
+
+	// agent1 limited by the analyzer, agent2 by itself
+	ok := &ProbeResult{Sent: 3, Received: 3, MinRTT: 1, AvgRTT: 1, MaxRTT: 1}
+	agent1 := agents.add("agent1", 0, ok, ok, ok)
+	agent2 := agents.add("agent2", 1, ok, ok, ok)
+
+	var jobs []*api.ProbeJob
+	for _, name := range []string{"a", "b", "c"} {
+		job := newTestJob(s, name)
+		job.Source = "G.V().Has('Type', 'host')"
+		job.Target = "192.168.1.1"
+		jobs = append(jobs, job)
+	}
+
+	now := time.Unix(1500000000, 0)
+	s.runDue(now)
+
+	// 2 requests to agent1, 2 to agent2 which runs one
+	agents.deliver(t, s, 4, now)
+
+	if n := len(agent1.executor.requests); n != 2 {
+		t.Errorf("Expected 2 probes sent to agent1, got %d", n)
+	}
+	if n := len(agent2.executor.requests); n != 1 {
+		t.Errorf("Expected agent2 to run a single probe, got %d", n)
+	}
+
+	var runs, errors int
+	for _, job := range jobs {
+		for _, series := range s.Series(job.UUID) {
+			runs += series.Minutes[0].Runs
+			errors += series.Minutes[0].Errors
+		}
+	}
+	if runs != 4 || errors != 1 {
+		t.Errorf("Expected 4 runs, 1 of them rate limited by the agent, got %d runs and %d errors", runs, errors)
+	}
+}
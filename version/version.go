@@ -16,6 +16,15 @@ var Package = "github.com/redhat-cip/skydive"
 // used if Skydive is run after a go get based install.
 var Version = "v0.3.0+unknown"
 
+// APIVersion is the major.minor version of the REST API, the major being
+// increased by the incompatible changes
+var APIVersion = "2.0"
+
+// MinClientAPIVersion is the oldest client API version served by the
+// analyzer, the clients of the previous major getting the formats they
+// expect
+var MinClientAPIVersion = "1.0"
+
 // FprintVersion outputs the version string to the writer, in the following
 // format, followed by a newline:
 //